@@ -11,6 +11,32 @@ const axiosInstance = axios.create({
   },
 });
 
+// the refresh in progress, shared by the concurrent requests since a refresh token can only be used once
+let refreshing: Promise<string> | null = null;
+
+const refreshAccessToken = (refreshToken: string) => {
+  if (!refreshing) {
+    refreshing = axios
+      .post(`${BASE_URL}/auth/refresh-token`, null, {
+        headers: {
+          "x-refresh-token": refreshToken,
+        },
+      })
+      .then(({ data }) => {
+        TokenService.setAccessTokenCookie(data.data.access_token);
+        // the refresh token is rotated on each refresh
+        if (data.data.refresh_token) {
+          TokenService.setRefreshTokenCookie(data.data.refresh_token);
+        }
+        return data.data.access_token as string;
+      })
+      .finally(() => {
+        refreshing = null;
+      });
+  }
+  return refreshing;
+};
+
 axiosInstance.interceptors.request.use(async (req) => {
   const accessToken = TokenService.getAccessTokenCookie();
   const refreshToken = TokenService.getRefreshTokenCookie();
@@ -31,14 +57,7 @@ axiosInstance.interceptors.request.use(async (req) => {
   }
 
   if (!accessToken || isTokenExpired(accessToken as string)) {
-    const { data } = await axios.post(`${BASE_URL}/auth/refresh-token`, null, {
-      headers: {
-        "x-refresh-token": refreshToken as string,
-      },
-    });
-
-    const accessToken = data.data.access_token;
-    TokenService.setAccessTokenCookie(accessToken);
+    const accessToken = await refreshAccessToken(refreshToken as string);
     req.headers = { Authorization: `Bearer ${accessToken}` };
     return req;
   }
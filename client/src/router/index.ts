@@ -6,6 +6,7 @@ import Dashboard from "../views/Dashboard.vue";
 import ForgotPassword from "../views/ForgotPassword.vue";
 import ResetPassword from "../views/ResetPassword.vue";
 import VerifyEmail from "../views/VerifyEmail.vue";
+import UnlockAccount from "../views/UnlockAccount.vue";
 import {
   Buckets,
   UserSettings,
@@ -52,6 +53,11 @@ const routes = [
     name: "ResetPassword",
     component: ResetPassword,
   },
+  {
+    path: "/unlock-account",
+    name: "UnlockAccount",
+    component: UnlockAccount,
+  },
   {
     path: "/dashboard",
     component: Dashboard,
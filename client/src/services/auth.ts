@@ -36,6 +36,34 @@ class AuthService {
     return new Promise((resolve, reject) => {
       axios
         .post("/auth/login", data)
+        .then((response) => {
+          const { data } = response;
+          // with two-factor authentication, the tokens are issued by loginTwoFactor
+          if (!data.data.two_factor_required) {
+            TokenService.setAccessTokenCookie(data.data.access_token);
+            TokenService.setRefreshTokenCookie(data.data.refresh_token);
+          }
+          resolve(data);
+        })
+        .catch((error) => {
+          console.log(error);
+          reject(error.response.data);
+        });
+    });
+  }
+
+  /**
+   * Complete a login with two-factor authentication
+   * @param token the two-factor token returned by the login
+   * @param code a two-factor code, or a recovery code
+   * @returns
+   */
+  loginTwoFactor(token: string, code: string) {
+    const body =
+      code.length === 6 ? { token, code } : { token, recovery_code: code };
+    return new Promise((resolve, reject) => {
+      axios
+        .post("/auth/login/2fa", body)
         .then((response) => {
           const { data } = response;
           TokenService.setAccessTokenCookie(data.data.access_token);
@@ -43,16 +71,23 @@ class AuthService {
           resolve(data);
         })
         .catch((error) => {
-          console.log(error);
           reject(error.response.data);
         });
     });
   }
 
   /**
-   * Logout a user
+   * Logout a user, revoking the session before removing its tokens
    */
   logout() {
+    const token = TokenService.getAccessTokenCookie();
+    if (token) {
+      axios
+        .post("/auth/logout", null, {
+          headers: { Authorization: `Bearer ${token}` },
+        })
+        .catch(() => undefined);
+    }
     Promise.all([
       TokenService.removeAccessTokenCookie(),
       TokenService.removeLocalAccessToken(),
@@ -79,6 +114,25 @@ class AuthService {
         });
     });
   }
+
+  /**
+   * Unlock the logins to an account locked after failed login attempts
+   * @param token the token of the unlock link
+   * @returns
+   */
+  unlockLogin(token: string) {
+    return new Promise((resolve, reject) => {
+      axios
+        .post("/auth/unlock", { token })
+        .then((response) => {
+          const { data } = response;
+          resolve(data);
+        })
+        .catch((error) => {
+          reject(error.response.data);
+        });
+    });
+  }
 }
 
 export default new AuthService();
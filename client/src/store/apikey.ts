@@ -26,7 +26,7 @@ export const useAPIKeyStore = defineStore("apikey", {
         this.apikeys = apiKeys;
         this.isLoadingAPIKeys = false;
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
         this.apikeys = [];
         this.isLoadingAPIKeys = false;
       }
@@ -39,7 +39,7 @@ export const useAPIKeyStore = defineStore("apikey", {
         this.activeKeyID = newAPIKey.id;
         this.activeKey = newAPIKey.key;
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
     async updateAPIKey(id: string, data: CreateAPIKeyData) {
@@ -48,7 +48,7 @@ export const useAPIKeyStore = defineStore("apikey", {
         toast.success("Successfully updated API key.");
         this.fetchUserAPIKeys();
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
     async deleteAPIKey(id: string) {
@@ -57,7 +57,7 @@ export const useAPIKeyStore = defineStore("apikey", {
         toast.success("API Key Deleted Successfully! 🎉");
         this.fetchUserAPIKeys();
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
     async revokeAPIKey(id: string) {
@@ -66,7 +66,7 @@ export const useAPIKeyStore = defineStore("apikey", {
         toast.success("Successfully revoked API key.");
         this.fetchUserAPIKeys();
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
     async deleteAPIKeys() {},
@@ -32,7 +32,7 @@ export const useBucketStore = defineStore("bucket", {
         this.buckets = buckets;
         this.isLoadingBuckets = false;
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
         this.buckets = [];
         this.isLoadingBuckets = false;
       }
@@ -43,7 +43,7 @@ export const useBucketStore = defineStore("bucket", {
         toast.success("Bucket Created Successfully! 🎉");
         this.fetchBuckets();
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
     async findBucket() {},
@@ -53,7 +53,7 @@ export const useBucketStore = defineStore("bucket", {
         toast.success("Bucket Updated Successfully! 🎉");
         this.fetchBuckets();
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
     async deleteBucket(uid: string) {
@@ -63,7 +63,7 @@ export const useBucketStore = defineStore("bucket", {
         router.push("/dashboard");
         this.fetchBuckets();
       } catch (error: any) {
-        toast.error(error.error);
+        toast.error(error.detail || error.message);
       }
     },
   },
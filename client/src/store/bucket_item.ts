@@ -31,7 +31,7 @@ export const useBucketItemStore = defineStore("bucket_item", {
         this.activeBucketItemsResponse = result.data;
       } catch (error: any) {
         toast.error(
-          `failed to fetch bucket items: ${error.error || error.message}`
+          `failed to fetch bucket items: ${error.detail || error.message}`
         );
       }
     },
@@ -43,7 +43,7 @@ export const useBucketItemStore = defineStore("bucket_item", {
         await this.fetchBucketItems(bucketUID);
       } catch (error: any) {
         toast.error(
-          `failed to create bucket item: ${error.error || error.message}`
+          `failed to create bucket item: ${error.detail || error.message}`
         );
       }
     },
@@ -62,7 +62,7 @@ export const useBucketItemStore = defineStore("bucket_item", {
         await this.fetchBucketItems(bucketUID);
       } catch (error: any) {
         toast.error(
-          `failed to update bucket item: ${error.error || error.message}`
+          `failed to update bucket item: ${error.detail || error.message}`
         );
       }
     },
@@ -74,7 +74,7 @@ export const useBucketItemStore = defineStore("bucket_item", {
         await this.fetchBucketItems(bucketUID);
       } catch (error: any) {
         toast.error(
-          `failed to delete bucket item: ${error.error || error.message}`
+          `failed to delete bucket item: ${error.detail || error.message}`
         );
       }
     },
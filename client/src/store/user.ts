@@ -29,7 +29,7 @@ export const useUserStore = defineStore("user", {
         router.push("/login");
       } catch (error: any) {
         // console.log(error);
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
 
@@ -41,7 +41,7 @@ export const useUserStore = defineStore("user", {
         setTimeout(() => router.push("/dashboard"), 1000);
       } catch (error: any) {
         //console.log(error);
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
 
@@ -52,7 +52,7 @@ export const useUserStore = defineStore("user", {
         this.user = null; //clear the user state
         router.push("/");
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
 
@@ -62,7 +62,7 @@ export const useUserStore = defineStore("user", {
         toast.success("User Updated Successfully! 🎉");
         await this.fetchUser();
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
     async updateUserPassword(data: { password: string }) {
@@ -70,7 +70,7 @@ export const useUserStore = defineStore("user", {
         await UserService.updateUserPassword(data);
         toast.success("Password Updated Successfully! 🎉");
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
     async deleteUser() {
@@ -79,7 +79,7 @@ export const useUserStore = defineStore("user", {
         toast.success("User Account Deleted Successfully! 🎉");
         router.push("/login");
       } catch (error: any) {
-        toast.error(error.error || error.message);
+        toast.error(error.detail || error.message);
       }
     },
   },
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"keeper/pkg/client"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+func loginCmd(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user")
+	password := fs.String("password", os.Getenv("KIPA_PASSWORD"), "password of the user, prompted for when empty, defaults to $KIPA_PASSWORD")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var err error
+	if *email == "" {
+		if *email, err = prompt("Email: ", false); err != nil {
+			return err
+		}
+	}
+	if *password == "" {
+		if *password, err = prompt("Password: ", true); err != nil {
+			return err
+		}
+	}
+	// a new login is never authenticated with a previous one
+	cl := client.New(c.server)
+	tokens, err := cl.Login(ctx, *email, *password)
+	if err != nil {
+		return err
+	}
+	creds := &credentials{
+		Server:       c.server,
+		Email:        *email,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}
+	if err := creds.save(); err != nil {
+		return err
+	}
+	c.tokenAt = ""
+	fmt.Fprintf(c.stdout, "Logged in to %s as %s\n", c.server, *email)
+	return nil
+}
+
+func logoutCmd(ctx context.Context, c *cli, args []string) error {
+	c.tokenAt = ""
+	if err := c.creds.remove(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "Logged out")
+	return nil
+}
+
+func lsCmd(ctx context.Context, c *cli, args []string) error {
+	switch len(args) {
+	case 0:
+		buckets, err := c.client.ListAllBuckets(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return c.printBuckets(buckets)
+	case 1:
+		items, err := c.client.ListItems(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return c.printItems(items)
+	}
+	return errors.New("usage: kipa ls [bucket]")
+}
+
+func getCmd(ctx context.Context, c *cli, args []string) error {
+	switch len(args) {
+	case 1:
+		bucket, err := c.client.GetBucket(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return c.printJSON(bucket)
+		}
+		if err := c.printBuckets([]client.Bucket{*bucket}); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stdout)
+		return c.printItems(bucket.Items)
+	case 2:
+		var value interface{}
+		if err := c.client.GetItemValue(ctx, args[0], args[1], &value); err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return c.printJSON(value)
+		}
+		fmt.Fprintln(c.stdout, formatValue(value))
+		return nil
+	}
+	return errors.New("usage: kipa get <bucket> [key]")
+}
+
+func setCmd(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	ttl := fs.Int("ttl", 0, "time to live of the item in seconds, 0 never expires")
+	description := fs.String("description", "", "description of the bucket")
+	permissions := fs.String("permissions", "", "comma separated public permissions of the bucket")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	switch len(args) {
+	case 1:
+		bucket, err := c.client.CreateBucket(ctx, client.BucketInput{
+			Name:        args[0],
+			Description: *description,
+			Permissions: splitList(*permissions),
+		})
+		if err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return c.printJSON(bucket)
+		}
+		fmt.Fprintln(c.stdout, bucket.UID)
+		return nil
+	case 3:
+		created, err := c.client.SetItem(ctx, args[0], client.ItemInput{
+			Key:  args[1],
+			Data: parseValue(args[2]),
+			TTL:  *ttl,
+		})
+		if err != nil {
+			return err
+		}
+		if created {
+			fmt.Fprintf(c.stdout, "Created '%s'\n", args[1])
+		} else {
+			fmt.Fprintf(c.stdout, "Updated '%s'\n", args[1])
+		}
+		return nil
+	}
+	return errors.New("usage: kipa set <name> | kipa set <bucket> <key> <value>")
+}
+
+func delCmd(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kipa del <bucket> [key...]")
+	}
+	if len(args) == 1 {
+		if err := c.client.DeleteBucket(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Moved bucket '%s' to the trash\n", args[0])
+		return nil
+	}
+	for _, key := range args[1:] {
+		if err := c.client.DeleteItem(ctx, args[0], key); err != nil {
+			return fmt.Errorf("deleting '%s': %w", key, err)
+		}
+		fmt.Fprintf(c.stdout, "Moved '%s' to the trash\n", key)
+	}
+	return nil
+}
+
+func incrCmd(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errors.New("usage: kipa incr <bucket> <key> [amount]")
+	}
+	amount := int64(1)
+	if len(args) == 3 {
+		var err error
+		if amount, err = strconv.ParseInt(args[2], 10, 64); err != nil {
+			return fmt.Errorf("invalid amount %q", args[2])
+		}
+	}
+	if err := c.client.IncrementItem(ctx, args[0], args[1], amount); err != nil {
+		return err
+	}
+	var value interface{}
+	if err := c.client.GetItemValue(ctx, args[0], args[1], &value); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, formatValue(value))
+	return nil
+}
+
+func importCmd(ctx context.Context, c *cli, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	ttl := fs.Int("ttl", 0, "time to live of the imported items in seconds, 0 never expires")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errors.New("usage: kipa import <bucket> <file>")
+	}
+	var data []byte
+	if args[1] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[1])
+	}
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("the file must hold a JSON object of keys and values: %w", err)
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	created, updated := 0, 0
+	for _, key := range keys {
+		isNew, err := c.client.SetItem(ctx, args[0], client.ItemInput{Key: key, Data: values[key], TTL: *ttl})
+		if err != nil {
+			return fmt.Errorf("importing '%s': %w", key, err)
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+	fmt.Fprintf(c.stdout, "Imported %d items (%d created, %d updated)\n", len(keys), created, updated)
+	return nil
+}
+
+func exportCmd(ctx context.Context, c *cli, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errors.New("usage: kipa export <bucket> [file]")
+	}
+	items, err := c.client.ListItems(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	for _, item := range items {
+		if item.Type == "binary" {
+			fmt.Fprintf(os.Stderr, "kipa: skipping binary item '%s'\n", item.Key)
+			continue
+		}
+		values[item.Key] = item.Data
+	}
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if len(args) == 1 || args[1] == "-" {
+		_, err = c.stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(args[1], data, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d items to %s\n", len(values), args[1])
+	return nil
+}
+
+func apiKeyCmd(ctx context.Context, c *cli, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kipa apikey <ls|create|revoke>")
+	}
+	switch args[0] {
+	case "ls":
+		apiKeys, err := c.client.ListAPIKeys(ctx)
+		if err != nil && !errors.Is(err, client.ErrAPIKeysNotFound) {
+			return err
+		}
+		return c.printAPIKeys(apiKeys)
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := fs.String("name", "", "name of the API key")
+		permissions := fs.String("permissions", "read:bucket,read:item", "comma separated permissions of the API key")
+		expires := fs.Duration("expires", 30*24*time.Hour, "lifetime of the API key")
+		if _, err := parseFlags(fs, args[1:]); err != nil {
+			return err
+		}
+		if *name == "" {
+			return errors.New("usage: kipa apikey create --name <name> [--permissions p,...] [--expires 720h]")
+		}
+		expiresAt := time.Now().Add(*expires)
+		apiKey, err := c.client.CreateAPIKey(ctx, client.APIKeyInput{
+			Name:        *name,
+			Permissions: splitList(*permissions),
+			ExpiresAt:   &expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return c.printJSON(apiKey)
+		}
+		fmt.Fprintf(c.stdout, "Created API key %s, it is only shown once:\n%s\n", apiKey.ID, apiKey.Key)
+		return nil
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: kipa apikey revoke <id>")
+		}
+		if err := c.client.RevokeAPIKey(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Revoked API key %s\n", args[1])
+		return nil
+	}
+	return fmt.Errorf("unknown apikey command %q", args[0])
+}
+
+func execCmd(ctx context.Context, c *cli, args []string) error {
+	sep := -1
+	for i, arg := range args {
+		if arg == "--" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || sep == len(args)-1 {
+		return errors.New("usage: kipa exec [--prefix p] <bucket> -- <command> [args...]")
+	}
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	prefix := fs.String("prefix", "", "prefix of the environment variable names")
+	positional, err := parseFlags(fs, args[:sep])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: kipa exec [--prefix p] <bucket> -- <command> [args...]")
+	}
+	items, err := c.client.ListItems(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(args[sep+1], args[sep+2:]...)
+	cmd.Env = append(os.Environ(), itemsEnv(items, *prefix)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &exitError{code: exitErr.ExitCode()}
+		}
+		return err
+	}
+	return nil
+}
+
+// Returns the items as environment variables, the keys are upper cased
+// and the characters not allowed in a variable name are replaced with '_'
+func itemsEnv(items []client.Item, prefix string) []string {
+	env := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Type == "binary" {
+			continue
+		}
+		name := strings.Map(func(r rune) rune {
+			if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+				return r
+			}
+			if r >= 'a' && r <= 'z' {
+				return r - 'a' + 'A'
+			}
+			return '_'
+		}, prefix+item.Key)
+		env = append(env, name+"="+formatValue(item.Data))
+	}
+	return env
+}
+
+// Parses a value passed on the command line as JSON, falling back to a string
+func parseValue(arg string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(arg), &value); err == nil {
+		return value
+	}
+	return arg
+}
+
+// Reads a line from the terminal, without echoing it for secrets
+func prompt(label string, secret bool) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	fd := int(os.Stdin.Fd())
+	if secret && term.IsTerminal(fd) {
+		value, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(value), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"keeper/pkg/client"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// request received by the fake API
+type apiRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Auth   string
+	Body   string
+}
+
+// fake Kipa API answering the routes keyed by method and path, e.g. "GET /api/v1/bucket/b1",
+// the requests it receives are recorded in order
+type fakeAPI struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []apiRequest
+}
+
+func newFakeAPI(t *testing.T, routes map[string]http.HandlerFunc) *fakeAPI {
+	api := &fakeAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		api.mu.Lock()
+		api.requests = append(api.requests, apiRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.RawQuery,
+			Auth:   r.Header.Get("Authorization"),
+			Body:   string(body),
+		})
+		api.mu.Unlock()
+		route, ok := routes[r.Method+" "+r.URL.Path]
+		if !ok {
+			writeProblem(w, http.StatusNotFound, "not_found")
+			return
+		}
+		route(w, r)
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+// Returns the requests received so far
+func (api *fakeAPI) received() []apiRequest {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return append([]apiRequest{}, api.requests...)
+}
+
+// Returns a route answering with the data in the envelope of the API
+func respondData(status int, data interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": data})
+	}
+}
+
+func writeProblem(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "code": code, "detail": strings.ReplaceAll(code, "_", " ")})
+}
+
+// Returns a route answering with a problem
+func respondProblem(status int, code string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, status, code)
+	}
+}
+
+// Returns a cli authenticated with an API key against the fake API, its output is written to the returned buffer
+func newTestCLI(api *fakeAPI, output string) (*cli, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &cli{
+		server: api.URL,
+		apiKey: "test-key",
+		output: output,
+		stdout: out,
+		client: client.New(api.URL, client.WithAPIKey("test-key"), client.WithRetry(0, 0, 0)),
+	}, out
+}
+
+func TestCommands_ItemsEnv(t *testing.T) {
+	tt := []struct {
+		name   string
+		items  []client.Item
+		prefix string
+		want   []string
+	}{
+		{
+			name:  "should_upper_case_the_keys",
+			items: []client.Item{{Key: "db_host", Data: "localhost", Type: "string"}},
+			want:  []string{"DB_HOST=localhost"},
+		},
+		{
+			name:  "should_replace_the_characters_not_allowed_in_a_name",
+			items: []client.Item{{Key: "api.key-v2", Data: "secret", Type: "string"}},
+			want:  []string{"API_KEY_V2=secret"},
+		},
+		{
+			name:   "should_prefix_the_names",
+			items:  []client.Item{{Key: "port", Data: 5432.0, Type: "number"}},
+			prefix: "app_",
+			want:   []string{"APP_PORT=5432"},
+		},
+		{
+			name:  "should_encode_the_other_values_as_json",
+			items: []client.Item{{Key: "hosts", Data: []interface{}{"a", "b"}, Type: "array"}},
+			want:  []string{`HOSTS=["a","b"]`},
+		},
+		{
+			name: "should_skip_the_binary_items",
+			items: []client.Item{
+				{Key: "cert", Data: nil, Type: "binary"},
+				{Key: "debug", Data: true, Type: "boolean"},
+			},
+			want: []string{"DEBUG=true"},
+		},
+		{
+			name:  "should_return_no_variables_without_items",
+			items: []client.Item{},
+			want:  []string{},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, itemsEnv(tc.items, tc.prefix))
+		})
+	}
+}
+
+func TestCommands_ParseValue(t *testing.T) {
+	tt := []struct {
+		name string
+		arg  string
+		want interface{}
+	}{
+		{name: "should_parse_numbers", arg: "42", want: 42.0},
+		{name: "should_parse_booleans", arg: "true", want: true},
+		{name: "should_parse_objects", arg: `{"a":1}`, want: map[string]interface{}{"a": 1.0}},
+		{name: "should_parse_quoted_strings", arg: `"42"`, want: "42"},
+		{name: "should_keep_the_other_values_as_strings", arg: "hello world", want: "hello world"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, parseValue(tc.arg))
+		})
+	}
+}
+
+func TestCommands_ParseFlags(t *testing.T) {
+	tt := []struct {
+		name           string
+		args           []string
+		wantPositional []string
+		wantTTL        int
+		wantErr        bool
+	}{
+		{name: "should_parse_flags_before_the_arguments", args: []string{"--ttl", "30", "b1", "key", "value"}, wantPositional: []string{"b1", "key", "value"}, wantTTL: 30},
+		{name: "should_parse_flags_after_the_arguments", args: []string{"b1", "key", "value", "--ttl", "30"}, wantPositional: []string{"b1", "key", "value"}, wantTTL: 30},
+		{name: "should_parse_flags_among_the_arguments", args: []string{"b1", "--ttl=30", "key"}, wantPositional: []string{"b1", "key"}, wantTTL: 30},
+		{name: "should_return_no_arguments", args: []string{}, wantPositional: []string{}},
+		{name: "should_fail_unknown_flag", args: []string{"b1", "--unknown"}, wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			ttl := fs.Int("ttl", 0, "")
+			positional, err := parseFlags(fs, tc.args)
+			if tc.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantPositional, positional)
+			assert.Equal(t, tc.wantTTL, *ttl)
+		})
+	}
+}
+
+func TestCommands_SplitList(t *testing.T) {
+	assert.Equal(t, []string{}, splitList(""))
+	assert.Equal(t, []string{"read:bucket", "read:item"}, splitList("read:bucket, read:item,"))
+}
+
+func TestCommands_SetItem(t *testing.T) {
+	tt := []struct {
+		name       string
+		routes     map[string]http.HandlerFunc
+		args       []string
+		wantOutput string
+		wantMethod string
+		wantBody   map[string]interface{}
+	}{
+		{
+			name: "should_create_a_missing_item",
+			routes: map[string]http.HandlerFunc{
+				"GET /api/v1/item/b1/count": respondProblem(http.StatusNotFound, "bucket_item_not_found"),
+				"POST /api/v1/item/b1":      respondData(http.StatusCreated, map[string]interface{}{"key": "count"}),
+			},
+			args:       []string{"b1", "count", "5", "--ttl", "30"},
+			wantOutput: "Created 'count'\n",
+			wantMethod: http.MethodPost,
+			wantBody:   map[string]interface{}{"key": "count", "data": 5.0, "ttl": 30.0},
+		},
+		{
+			name: "should_update_an_existing_item",
+			routes: map[string]http.HandlerFunc{
+				"GET /api/v1/item/b1/name": respondData(http.StatusOK, map[string]interface{}{"key": "name", "data": "old"}),
+				"PUT /api/v1/item/b1/name": respondData(http.StatusOK, nil),
+			},
+			args:       []string{"b1", "name", "new value"},
+			wantOutput: "Updated 'name'\n",
+			wantMethod: http.MethodPut,
+			wantBody:   map[string]interface{}{"key": "name", "data": "new value"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			api := newFakeAPI(t, tc.routes)
+			c, out := newTestCLI(api, "table")
+
+			err := setCmd(context.Background(), c, tc.args)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantOutput, out.String())
+			requests := api.received()
+			last := requests[len(requests)-1]
+			assert.Equal(t, tc.wantMethod, last.Method)
+			assert.Equal(t, "Bearer test-key", last.Auth)
+			body := map[string]interface{}{}
+			assert.Nil(t, json.Unmarshal([]byte(last.Body), &body))
+			assert.Equal(t, tc.wantBody, body)
+		})
+	}
+}
+
+func TestCommands_CreateBucket(t *testing.T) {
+	api := newFakeAPI(t, map[string]http.HandlerFunc{
+		"POST /api/v1/bucket": respondData(http.StatusCreated, map[string]interface{}{"uid": "new-bucket-uid", "name": "configs"}),
+	})
+	c, out := newTestCLI(api, "table")
+
+	err := setCmd(context.Background(), c, []string{"configs", "--permissions", "public:read, public:read:item", "--description", "the configs"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "new-bucket-uid\n", out.String())
+	body := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(api.received()[0].Body), &body))
+	assert.Equal(t, "configs", body["name"])
+	assert.Equal(t, "the configs", body["description"])
+	assert.Equal(t, []interface{}{"public:read", "public:read:item"}, body["permissions"])
+}
+
+func TestCommands_GetItemValue(t *testing.T) {
+	api := newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /api/v1/item/b1/config": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"debug":true}`))
+		},
+	})
+
+	for output, want := range map[string]string{
+		"table": "{\"debug\":true}\n",
+		"json":  "{\n  \"debug\": true\n}\n",
+	} {
+		c, out := newTestCLI(api, output)
+		err := getCmd(context.Background(), c, []string{"b1", "config"})
+		assert.Nil(t, err)
+		assert.Equal(t, want, out.String())
+	}
+}
+
+func TestCommands_ListItems(t *testing.T) {
+	api := newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /api/v1/items/b1": respondData(http.StatusOK, []map[string]interface{}{
+			{"key": "host", "data": "localhost", "type": "string"},
+			{"key": "port", "data": 5432, "type": "number", "ttl": 60},
+		}),
+	})
+	c, out := newTestCLI(api, "table")
+
+	err := lsCmd(context.Background(), c, []string{"b1"})
+
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"host", "localhost", "string", "0", "-"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"port", "5432", "number", "60", "-"}, strings.Fields(lines[2]))
+}
+
+func TestCommands_DeleteItems(t *testing.T) {
+	api := newFakeAPI(t, map[string]http.HandlerFunc{
+		"DELETE /api/v1/item/b1/a":       respondData(http.StatusOK, nil),
+		"DELETE /api/v1/item/b1/b":       respondData(http.StatusOK, nil),
+		"DELETE /api/v1/item/b1/missing": respondProblem(http.StatusNotFound, "bucket_item_not_found"),
+	})
+	c, out := newTestCLI(api, "table")
+
+	err := delCmd(context.Background(), c, []string{"b1", "a", "b"})
+	missingErr := delCmd(context.Background(), c, []string{"b1", "missing"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Moved 'a' to the trash\nMoved 'b' to the trash\n", out.String())
+	assert.ErrorIs(t, missingErr, client.ErrBucketItemNotFound)
+	assert.Contains(t, missingErr.Error(), "deleting 'missing'")
+}
+
+func TestCommands_Increment(t *testing.T) {
+	api := newFakeAPI(t, map[string]http.HandlerFunc{
+		"PUT /api/v1/item/b1/count": respondData(http.StatusOK, nil),
+		"GET /api/v1/item/b1/count": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("7"))
+		},
+	})
+	c, out := newTestCLI(api, "table")
+
+	err := incrCmd(context.Background(), c, []string{"b1", "count", "-3"})
+	invalidErr := incrCmd(context.Background(), c, []string{"b1", "count", "many"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "7\n", out.String())
+	assert.Equal(t, "-3", api.received()[0].Body)
+	assert.EqualError(t, invalidErr, `invalid amount "many"`)
+}
+
+func TestCommands_Usage(t *testing.T) {
+	api := newFakeAPI(t, map[string]http.HandlerFunc{})
+	c, _ := newTestCLI(api, "table")
+
+	tt := []struct {
+		name string
+		cmd  command
+		args []string
+	}{
+		{name: "ls", cmd: lsCmd, args: []string{"b1", "extra"}},
+		{name: "get", cmd: getCmd, args: []string{}},
+		{name: "set", cmd: setCmd, args: []string{"b1", "key"}},
+		{name: "del", cmd: delCmd, args: []string{}},
+		{name: "incr", cmd: incrCmd, args: []string{"b1"}},
+		{name: "export", cmd: exportCmd, args: []string{}},
+		{name: "apikey", cmd: apiKeyCmd, args: []string{}},
+		{name: "exec", cmd: execCmd, args: []string{"b1", "./app"}},
+	}
+
+	for _, tc := range tt {
+		t.Run("should_print_the_usage_of_"+tc.name, func(t *testing.T) {
+			err := tc.cmd(context.Background(), c, tc.args)
+			assert.NotNil(t, err)
+			assert.True(t, strings.HasPrefix(err.Error(), "usage: kipa "+tc.name), err.Error())
+		})
+	}
+	// the arguments are checked before anything is sent
+	assert.Empty(t, api.received())
+}
+
+func TestRun_Arguments(t *testing.T) {
+	t.Setenv("KIPA_CONFIG", filepath.Join(t.TempDir(), "credentials.json"))
+	t.Setenv("KIPA_SERVER", "")
+	t.Setenv("KIPA_API_KEY", "")
+	api := newFakeAPI(t, map[string]http.HandlerFunc{
+		"GET /api/v1/item/b1/count": respondProblem(http.StatusNotFound, "bucket_item_not_found"),
+		"POST /api/v1/item/b1":      respondData(http.StatusCreated, map[string]interface{}{"key": "count"}),
+		"GET /api/v1/item/b1/other": respondProblem(http.StatusUnauthorized, "unauthorized"),
+	})
+
+	tt := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{name: "should_print_the_usage", args: []string{"help"}, wantCode: 0},
+		{name: "should_print_the_usage_without_command", args: []string{}, wantCode: 0},
+		{name: "should_fail_unknown_command", args: []string{"unknown"}, wantCode: 2},
+		{name: "should_fail_unknown_output_format", args: []string{"-o", "xml", "ls"}, wantCode: 2},
+		{name: "should_fail_unknown_global_flag", args: []string{"--unknown", "ls"}, wantCode: 2},
+		{name: "should_fail_command_usage", args: []string{"--server", api.URL, "get"}, wantCode: 1},
+		{name: "should_fail_unauthorized", args: []string{"--server", api.URL, "--api-key", "test-key", "get", "b1", "other"}, wantCode: 1},
+		{name: "should_run_the_command", args: []string{"--server", api.URL, "--api-key", "test-key", "set", "b1", "count", "1", "--ttl", "5"}, wantCode: 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.wantCode, run(tc.args))
+		})
+	}
+
+	// the global flags configure the client of the command
+	paths := []string{}
+	for _, r := range api.received() {
+		assert.Equal(t, "Bearer test-key", r.Auth)
+		paths = append(paths, r.Method+" "+r.Path)
+	}
+	sort.Strings(paths)
+	assert.Equal(t, []string{"GET /api/v1/item/b1/count", "GET /api/v1/item/b1/other", "POST /api/v1/item/b1"}, paths)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentials_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kipa", "credentials.json")
+	t.Setenv("KIPA_CONFIG", path)
+	creds := &credentials{
+		Server:       "http://kipa.test",
+		Email:        "me@example.com",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+	}
+
+	err := creds.save()
+	assert.Nil(t, err)
+	info, statErr := os.Stat(path)
+	assert.Nil(t, statErr)
+	// the tokens are readable by the current user only
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	dirInfo, statErr := os.Stat(filepath.Dir(path))
+	assert.Nil(t, statErr)
+	assert.Equal(t, os.FileMode(0o700), dirInfo.Mode().Perm())
+
+	loaded, err := loadCredentials()
+	assert.Nil(t, err)
+	assert.Equal(t, creds, loaded)
+}
+
+func TestCredentials_LoadMissing(t *testing.T) {
+	t.Setenv("KIPA_CONFIG", filepath.Join(t.TempDir(), "credentials.json"))
+
+	creds, err := loadCredentials()
+
+	assert.Nil(t, err)
+	assert.Equal(t, &credentials{}, creds)
+}
+
+func TestCredentials_LoadMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("KIPA_CONFIG", path)
+	assert.Nil(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+	_, err := loadCredentials()
+
+	assert.NotNil(t, err)
+}
+
+func TestCredentials_Remove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("KIPA_CONFIG", path)
+	creds := &credentials{Server: "http://kipa.test", AccessToken: "access"}
+	assert.Nil(t, creds.save())
+
+	err := creds.remove()
+	_, statErr := os.Stat(path)
+	// removing credentials that are already gone is not an error
+	secondErr := creds.remove()
+
+	assert.Nil(t, err)
+	assert.ErrorIs(t, statErr, os.ErrNotExist)
+	assert.Nil(t, secondErr)
+}
@@ -0,0 +1,203 @@
+// Command kipa is the command-line client of the Kipa API.
+//
+//	kipa login --email me@example.com
+//	kipa set <bucket> <key> <value>
+//	kipa exec <bucket> -- ./app
+//
+// Run 'kipa help' for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"keeper/pkg/client"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const usage = `Usage: kipa [global flags] <command> [flags] [args]
+
+Commands:
+  login [--email e] [--password p]       log in and store the tokens locally
+  logout                                 remove the stored tokens
+  ls [bucket]                            list the buckets, or the items of a bucket
+  get <bucket> [key]                     print a bucket, or the value of an item
+  set <name> [--description d] [--permissions p,...]
+                                         create a bucket
+  set <bucket> <key> <value> [--ttl s]   create or update an item, the value is parsed as JSON when valid
+  del <bucket> [key...]                  move a bucket, or some of its items, to the trash
+  incr <bucket> <key> [amount]           increment an integer item, by 1 unless an amount is given
+  import <bucket> <file>                 create or update the items of a JSON object file ('-' for stdin)
+  export <bucket> [file]                 write the items of a bucket as a JSON object
+  apikey ls                              list your API keys
+  apikey create --name n [--permissions p,...] [--expires 720h]
+                                         create an API key
+  apikey revoke <id>                     revoke an API key
+  exec <bucket> -- <command> [args...]   run a command with the items of a bucket as environment variables
+
+Global flags:
+`
+
+// global flags and the client they configure
+type cli struct {
+	server  string
+	apiKey  string
+	output  string
+	creds   *credentials
+	client  *client.Client
+	stdout  io.Writer
+	tokenAt string // access token the client started with, to persist a refreshed one
+}
+
+type command func(ctx context.Context, c *cli, args []string) error
+
+var commands = map[string]command{
+	"login":  loginCmd,
+	"logout": logoutCmd,
+	"ls":     lsCmd,
+	"get":    getCmd,
+	"set":    setCmd,
+	"del":    delCmd,
+	"incr":   incrCmd,
+	"import": importCmd,
+	"export": exportCmd,
+	"apikey": apiKeyCmd,
+	"exec":   execCmd,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	c := &cli{stdout: os.Stdout}
+	fs := flag.NewFlagSet("kipa", flag.ContinueOnError)
+	fs.StringVar(&c.server, "server", os.Getenv("KIPA_SERVER"), "URL of the Kipa API, defaults to $KIPA_SERVER or the server of the last login")
+	fs.StringVar(&c.apiKey, "api-key", os.Getenv("KIPA_API_KEY"), "API key to authenticate with instead of the stored tokens, defaults to $KIPA_API_KEY")
+	fs.StringVar(&c.output, "o", "table", "output format, 'table' or 'json'")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 || fs.Arg(0) == "help" {
+		fs.Usage()
+		return 0
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "kipa: unknown output format %q\n", c.output)
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "kipa: unknown command %q, run 'kipa help' for usage\n", fs.Arg(0))
+		return 2
+	}
+
+	if err := c.init(); err != nil {
+		fmt.Fprintf(os.Stderr, "kipa: %v\n", err)
+		return 1
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := cmd(ctx, c, fs.Args()[1:])
+	if saveErr := c.saveRefreshedToken(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			return exitErr.code
+		}
+		fmt.Fprintf(os.Stderr, "kipa: %v\n", err)
+		if errors.Is(err, client.ErrUnauthorized) {
+			fmt.Fprintln(os.Stderr, "kipa: run 'kipa login' or pass an API key with --api-key")
+		}
+		return 1
+	}
+	return 0
+}
+
+// Loads the stored credentials and creates the client
+func (c *cli) init() error {
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	c.creds = creds
+	if c.server == "" {
+		c.server = creds.Server
+	}
+	if c.server == "" {
+		c.server = defaultServer
+	}
+	opts := []client.Option{}
+	if c.apiKey != "" {
+		opts = append(opts, client.WithAPIKey(c.apiKey))
+	}
+	c.client = client.New(c.server, opts...)
+	// stored tokens are only used against the server they were issued by
+	if c.apiKey == "" && creds.Server == c.server {
+		c.client.SetTokens(client.Tokens{AccessToken: creds.AccessToken, RefreshToken: creds.RefreshToken})
+		c.tokenAt = creds.AccessToken
+	}
+	return nil
+}
+
+// Stores the access token refreshed by the client during a command
+func (c *cli) saveRefreshedToken() error {
+	if c.client == nil || c.tokenAt == "" {
+		return nil
+	}
+	tokens := c.client.Tokens()
+	if tokens.AccessToken == c.tokenAt {
+		return nil
+	}
+	c.creds.AccessToken = tokens.AccessToken
+	return c.creds.save()
+}
+
+// Parses the flags of a command wherever they are placed among its arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Splits a comma separated list
+func splitList(value string) []string {
+	list := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// error carrying the exit code of a child process
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
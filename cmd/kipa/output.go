@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"keeper/pkg/client"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Prints a value as indented JSON, or as a table built by rows
+func (c *cli) print(value interface{}, header []string, rows func() [][]string) error {
+	if c.output == "json" || rows == nil {
+		return c.printJSON(value)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows() {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func (c *cli) printJSON(value interface{}) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+func (c *cli) printBuckets(buckets []client.Bucket) error {
+	return c.print(buckets, []string{"UID", "NAME", "DESCRIPTION", "PERMISSIONS", "CREATED"}, func() [][]string {
+		rows := make([][]string, 0, len(buckets))
+		for _, b := range buckets {
+			rows = append(rows, []string{b.UID, b.Name, b.Description, strings.Join(b.Permissions, ","), formatTime(b.CreatedAt)})
+		}
+		return rows
+	})
+}
+
+func (c *cli) printItems(items []client.Item) error {
+	return c.print(items, []string{"KEY", "VALUE", "TYPE", "TTL", "UPDATED"}, func() [][]string {
+		rows := make([][]string, 0, len(items))
+		for _, i := range items {
+			updated := i.UpdatedAt
+			if updated.IsZero() || updated.Unix() == 0 {
+				updated = i.CreatedAt
+			}
+			rows = append(rows, []string{i.Key, truncate(formatValue(i.Data), 60), i.Type, fmt.Sprint(i.TTL), formatTime(updated)})
+		}
+		return rows
+	})
+}
+
+func (c *cli) printAPIKeys(apiKeys []client.APIKey) error {
+	return c.print(apiKeys, []string{"ID", "NAME", "PERMISSIONS", "REVOKED", "EXPIRES"}, func() [][]string {
+		rows := make([][]string, 0, len(apiKeys))
+		for _, k := range apiKeys {
+			rows = append(rows, []string{k.ID, k.Name, strings.Join(k.Permissions, ","), fmt.Sprint(k.Revoked), formatTime(k.ExpiresAt)})
+		}
+		return rows
+	})
+}
+
+// Formats an item value, strings are printed as is and other values as JSON
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() || t.Unix() == 0 {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"keeper/pkg/client"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutput_FormatValue(t *testing.T) {
+	tt := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{name: "should_print_strings_as_is", value: "plain text", want: "plain text"},
+		{name: "should_print_numbers_as_json", value: 42.5, want: "42.5"},
+		{name: "should_print_booleans_as_json", value: true, want: "true"},
+		{name: "should_print_objects_as_json", value: map[string]interface{}{"a": 1}, want: `{"a":1}`},
+		{name: "should_print_lists_as_json", value: []interface{}{"a", 1}, want: `["a",1]`},
+		{name: "should_print_null_as_json", value: nil, want: "null"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, formatValue(tc.value))
+		})
+	}
+}
+
+func TestOutput_FormatTime(t *testing.T) {
+	at := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+
+	assert.Equal(t, "-", formatTime(time.Time{}))
+	assert.Equal(t, "-", formatTime(time.Unix(0, 0)))
+	assert.Equal(t, at.Local().Format(time.RFC3339), formatTime(at))
+}
+
+func TestOutput_Truncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "exactly10!", truncate("exactly10!", 10))
+	assert.Equal(t, "a longe...", truncate("a longer value", 10))
+}
+
+func TestOutput_PrintItems(t *testing.T) {
+	created := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	items := []client.Item{
+		{Key: "name", Data: "kipa", Type: "string", CreatedAt: created},
+		{Key: "config", Data: map[string]interface{}{"debug": true}, Type: "object", TTL: 60, CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+		{Key: "long", Data: strings.Repeat("x", 100), Type: "string", CreatedAt: created},
+	}
+
+	tt := []struct {
+		name   string
+		output string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			name:   "should_print_a_table",
+			output: "table",
+			check: func(t *testing.T, out string) {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				assert.Len(t, lines, 4)
+				assert.Equal(t, []string{"KEY", "VALUE", "TYPE", "TTL", "UPDATED"}, strings.Fields(lines[0]))
+				assert.Equal(t, []string{"name", "kipa", "string", "0", formatTime(created)}, strings.Fields(lines[1]))
+				// the time of the last update is shown when there is one
+				assert.Equal(t, []string{"config", `{"debug":true}`, "object", "60", formatTime(created.Add(time.Hour))}, strings.Fields(lines[2]))
+				assert.Contains(t, lines[3], strings.Repeat("x", 57)+"...")
+				assert.NotContains(t, lines[3], strings.Repeat("x", 58))
+			},
+		},
+		{
+			name:   "should_print_json",
+			output: "json",
+			check: func(t *testing.T, out string) {
+				decoded := []client.Item{}
+				assert.Nil(t, json.Unmarshal([]byte(out), &decoded))
+				assert.Len(t, decoded, 3)
+				assert.Equal(t, "config", decoded[1].Key)
+				assert.Equal(t, strings.Repeat("x", 100), decoded[2].Data)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			c := &cli{output: tc.output, stdout: out}
+			assert.Nil(t, c.printItems(items))
+			tc.check(t, out.String())
+		})
+	}
+}
+
+func TestOutput_PrintBuckets(t *testing.T) {
+	out := &bytes.Buffer{}
+	c := &cli{output: "table", stdout: out}
+
+	err := c.printBuckets([]client.Bucket{{UID: "b1", Name: "first", Description: "one", Permissions: []string{"public:read", "public:read:item"}}})
+
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"b1", "first", "one", "public:read,public:read:item", "-"}, strings.Fields(lines[1]))
+}
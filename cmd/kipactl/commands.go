@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"keeper/internal/migrations"
+	"keeper/internal/models"
+	"keeper/internal/services"
+	"keeper/internal/utils"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+// length of the generated passwords
+const generatedPasswordLength = 20
+
+func userCmd(ctx context.Context, c *ctl, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kipactl user <ls|create|reset-password|verify>")
+	}
+	switch args[0] {
+	case "ls":
+		users, err := c.users.FindAllUsers(ctx)
+		if err != nil {
+			return err
+		}
+		return c.print(users, []string{"ID", "EMAIL", "NAME", "ROLE", "VERIFIED", "CREATED"}, func() [][]string {
+			rows := make([][]string, 0, len(users))
+			for _, u := range users {
+				name := strings.TrimSpace(u.Firstname + " " + u.Lastname)
+				rows = append(rows, []string{u.ID.Hex(), u.Email, name, u.Role, fmt.Sprint(u.EmailVerified), formatTime(u.CreatedAt)})
+			}
+			return rows
+		})
+	case "create":
+		return createUser(ctx, c, args[1:])
+	case "reset-password":
+		return resetPassword(ctx, c, args[1:])
+	case "verify":
+		if len(args) != 2 {
+			return errors.New("usage: kipactl user verify <email>")
+		}
+		user, err := c.users.FindUserByEmail(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		user.EmailVerified = true
+		user.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+		if err := c.users.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Verified the email of %s\n", user.Email)
+		return nil
+	}
+	return fmt.Errorf("unknown user command %q", args[0])
+}
+
+func createUser(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the user")
+	password := fs.String("password", "", "password of the user, generated when empty")
+	firstname := fs.String("firstname", "", "first name of the user")
+	lastname := fs.String("lastname", "", "last name of the user")
+	admin := fs.Bool("admin", false, "make the user an administrator")
+	verified := fs.Bool("verified", false, "mark the email of the user as verified")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("usage: kipactl user create --email <email> [--password p] [--admin] [--verified]")
+	}
+	if _, err := c.users.FindUserByEmail(ctx, *email); err == nil {
+		return models.ErrUserAlreadyExists
+	} else if !errors.Is(err, models.ErrUserNotFound) {
+		return err
+	}
+	generated, err := passwordOrGenerate(password)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := utils.HashPassword(*password)
+	if err != nil {
+		return err
+	}
+	now := primitive.NewDateTimeFromTime(time.Now())
+	user := &models.User{
+		ID:            primitive.NewObjectID(),
+		Firstname:     *firstname,
+		Lastname:      *lastname,
+		Email:         *email,
+		Password:      hashedPassword,
+		EmailVerified: *verified,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if *admin {
+		user.Role = models.UserRoleAdmin
+	}
+	if err := c.users.CreateUser(ctx, user); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Created user %s (%s)\n", user.Email, user.ID.Hex())
+	if generated {
+		fmt.Fprintf(c.stdout, "Password: %s\n", *password)
+	}
+	return nil
+}
+
+func resetPassword(ctx context.Context, c *ctl, args []string) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "new password of the user, generated when empty")
+	args, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("usage: kipactl user reset-password <email> [--password p]")
+	}
+	user, err := c.users.FindUserByEmail(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	generated, err := passwordOrGenerate(password)
+	if err != nil {
+		return err
+	}
+	if user.Password, err = utils.HashPassword(*password); err != nil {
+		return err
+	}
+	user.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	if err := c.users.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	// the sessions opened with the previous password are closed, as for a reset through the API
+	revoked, err := c.sessions.RevokeUserSessions(ctx, user.ID, models.SessionRevokedPasswordReset)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Reset the password of %s, revoked %d sessions\n", user.Email, revoked)
+	if generated {
+		fmt.Fprintf(c.stdout, "Password: %s\n", *password)
+	}
+	return nil
+}
+
+// Generates a password when none is given, returns whether it was generated
+func passwordOrGenerate(password *string) (bool, error) {
+	if *password != "" {
+		return false, nil
+	}
+	generated, err := utils.GenerateRandomString(generatedPasswordLength)
+	if err != nil {
+		return false, err
+	}
+	*password = generated
+	return true, nil
+}
+
+func apiKeyCmd(ctx context.Context, c *ctl, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: kipactl apikey <ls|revoke>")
+	}
+	switch args[0] {
+	case "ls":
+		fs := flag.NewFlagSet("apikey ls", flag.ContinueOnError)
+		email := fs.String("user", "", "email of the user whose API keys are listed")
+		if _, err := parseFlags(fs, args[1:]); err != nil {
+			return err
+		}
+		var apiKeys []models.APIKey
+		var err error
+		if *email != "" {
+			user, err := c.users.FindUserByEmail(ctx, *email)
+			if err != nil {
+				return err
+			}
+			apiKeys, err = c.apiKeys.FindUserAPIKeys(ctx, user.ID.Hex())
+			if err != nil {
+				return err
+			}
+		} else if apiKeys, err = c.apiKeys.FindAllAPIKeys(ctx); err != nil {
+			return err
+		}
+		return c.print(apiKeys, []string{"ID", "USER", "NAME", "PERMISSIONS", "REVOKED", "EXPIRES"}, func() [][]string {
+			rows := make([][]string, 0, len(apiKeys))
+			for _, k := range apiKeys {
+				permissions := make([]string, 0, len(k.Permissions))
+				for _, p := range k.Permissions {
+					permissions = append(permissions, string(p))
+				}
+				rows = append(rows, []string{k.ID.Hex(), k.UserID.Hex(), k.Name, strings.Join(permissions, ","), fmt.Sprint(k.Revoked), formatTime(k.ExpiresAt)})
+			}
+			return rows
+		})
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: kipactl apikey revoke <id>")
+		}
+		// revoking upserts, an unknown ID must not create a key
+		if _, err := c.apiKeys.FindAPIKeyByID(ctx, args[1]); err != nil {
+			return err
+		}
+		if err := c.apiKeys.RevokeAPIKey(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Revoked API key %s\n", args[1])
+		return nil
+	}
+	return fmt.Errorf("unknown apikey command %q", args[0])
+}
+
+func bucketCmd(ctx context.Context, c *ctl, args []string) error {
+	if len(args) != 3 || args[0] != "chown" {
+		return errors.New("usage: kipactl bucket chown <bucket> <email>")
+	}
+	bucket, err := c.buckets.FindBucketByUID(ctx, args[1])
+	if err != nil {
+		return err
+	}
+	user, err := c.users.FindUserByEmail(ctx, args[2])
+	if err != nil {
+		return err
+	}
+	if bucket.UserID == user.ID {
+		fmt.Fprintf(c.stdout, "%s already owns bucket %s\n", user.Email, bucket.UID)
+		return nil
+	}
+	// the usage of the bucket moves first, the new owner must have room for it in its quota
+	usage := services.NewUsageService(c.cfg, c.usage, c.users)
+	previousOwner := bucket.UserID
+	if err := usage.MoveBucketUsage(ctx, bucket.UID, previousOwner, user.ID); err != nil {
+		return err
+	}
+	bucket.UserID = user.ID
+	bucket.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	if err := c.buckets.UpdateBucket(ctx, bucket); err != nil {
+		if moveErr := usage.MoveBucketUsage(ctx, bucket.UID, user.ID, previousOwner); moveErr != nil {
+			return fmt.Errorf("%w, and the usage was not given back: %v", err, moveErr)
+		}
+		return err
+	}
+	fmt.Fprintf(c.stdout, "%s now owns bucket %s\n", user.Email, bucket.UID)
+	return nil
+}
+
+func compressCmd(ctx context.Context, c *ctl, args []string) error {
+	if len(args) > 1 {
+		return errors.New("usage: kipactl compress [bucket]")
+	}
+	// the items of every bucket are compressed unless a bucket is given
+	bucketUID := ""
+	if len(args) == 1 {
+		bucket, err := c.buckets.FindBucketByUID(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		bucketUID = bucket.UID
+	}
+	count, err := c.bucketItems.CompressBucketItems(ctx, bucketUID)
+	if err != nil {
+		return fmt.Errorf("%w, %d items were compressed", err, count)
+	}
+	fmt.Fprintf(c.stdout, "Compressed %d items\n", count)
+	return nil
+}
+
+func migrateCmd(ctx context.Context, c *ctl, args []string) error {
+	if len(args) == 1 && args[0] == "status" {
+		statuses, err := migrations.Status(ctx, c.db)
+		if err != nil {
+			return err
+		}
+		return c.print(statuses, []string{"VERSION", "DESCRIPTION", "APPLIED"}, func() [][]string {
+			rows := make([][]string, 0, len(statuses))
+			for _, s := range statuses {
+				rows = append(rows, []string{fmt.Sprint(s.Version), s.Description, formatTime(s.AppliedAt)})
+			}
+			return rows
+		})
+	}
+	if len(args) != 0 {
+		return errors.New("usage: kipactl migrate [status]")
+	}
+	applied, err := migrations.Run(ctx, c.db)
+	for _, m := range applied {
+		fmt.Fprintf(c.stdout, "Applied %d: %s\n", m.Version, m.Description)
+	}
+	if err == nil && len(applied) == 0 {
+		fmt.Fprintln(c.stdout, "The database is up to date")
+	}
+	return err
+}
+
+func purgeExpiredCmd(ctx context.Context, c *ctl, args []string) error {
+	// the usage of the purged items is given back to their owners, the items are not audited
+	bucketItems := services.NewBucketItemService(c.cfg, c.bucketItems, c.buckets, nil, nil, c.usage, nil)
+	count, err := bucketItems.PurgeExpiredBucketItems(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Purged %d expired items\n", count)
+	return nil
+}
+
+// statistics of a collection
+type collectionStats struct {
+	Name      string `json:"name"`
+	Documents int64  `json:"documents"`
+}
+
+// statistics of the database, the sizes are numbers of the type reported by the server
+type databaseStats struct {
+	Database    string            `json:"database"`
+	DataSize    interface{}       `json:"data_size"`
+	StorageSize interface{}       `json:"storage_size"`
+	IndexSize   interface{}       `json:"index_size"`
+	Indexes     interface{}       `json:"indexes"`
+	Collections []collectionStats `json:"collections"`
+}
+
+func statsCmd(ctx context.Context, c *ctl, args []string) error {
+	stats, err := c.stats(ctx)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return printJSON(c.stdout, stats)
+	}
+	fmt.Fprintf(c.stdout, "Database:      %s\n", stats.Database)
+	fmt.Fprintf(c.stdout, "Data size:     %s\n", formatBytes(stats.DataSize))
+	fmt.Fprintf(c.stdout, "Storage size:  %s\n", formatBytes(stats.StorageSize))
+	fmt.Fprintf(c.stdout, "Index size:    %s\n", formatBytes(stats.IndexSize))
+	fmt.Fprintf(c.stdout, "Indexes:       %v\n\n", stats.Indexes)
+	return c.print(stats.Collections, []string{"COLLECTION", "DOCUMENTS"}, func() [][]string {
+		rows := make([][]string, 0, len(stats.Collections))
+		for _, col := range stats.Collections {
+			rows = append(rows, []string{col.Name, fmt.Sprint(col.Documents)})
+		}
+		return rows
+	})
+}
+
+// Reads the statistics of the database and the number of documents of its collections
+func readDatabaseStats(ctx context.Context, db *mongodriver.Database) (*databaseStats, error) {
+	dbStats := bson.M{}
+	if err := db.RunCommand(ctx, bson.D{primitive.E{Key: "dbStats", Value: 1}}).Decode(&dbStats); err != nil {
+		return nil, err
+	}
+	names, err := db.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	collections := make([]collectionStats, 0, len(names))
+	for _, name := range names {
+		count, err := db.Collection(name).EstimatedDocumentCount(ctx)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, collectionStats{Name: name, Documents: count})
+	}
+	return &databaseStats{
+		Database:    db.Name(),
+		DataSize:    dbStats["dataSize"],
+		StorageSize: dbStats["storageSize"],
+		IndexSize:   dbStats["indexSize"],
+		Indexes:     dbStats["indexes"],
+		Collections: collections,
+	}, nil
+}
+
+func printJSON(w io.Writer, value interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+func formatTime(t primitive.DateTime) string {
+	if t == 0 {
+		return "-"
+	}
+	return t.Time().Local().Format(time.RFC3339)
+}
+
+// Formats a size in bytes reported by the database, the number type depends on the server
+func formatBytes(value interface{}) string {
+	var size float64
+	switch v := value.(type) {
+	case int32:
+		size = float64(v)
+	case int64:
+		size = float64(v)
+	case float64:
+		size = v
+	default:
+		return fmt.Sprint(value)
+	}
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", size, units[i])
+}
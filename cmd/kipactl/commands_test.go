@@ -0,0 +1,568 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"keeper/internal/utils"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// kipactl mocks, the output of the commands is written to out
+type testCtl struct {
+	*ctl
+	users       *mocks.MockIUserRepository
+	apiKeys     *mocks.MockIAPIKeyRepository
+	buckets     *mocks.MockIBucketRepository
+	bucketItems *mocks.MockIBucketItemRepository
+	sessions    *mocks.MockISessionRepository
+	usage       *mocks.MockIUsageRepository
+	out         *bytes.Buffer
+}
+
+func newTestCtl(ctrl *gomock.Controller, output string) *testCtl {
+	tc := &testCtl{
+		users:       mocks.NewMockIUserRepository(ctrl),
+		apiKeys:     mocks.NewMockIAPIKeyRepository(ctrl),
+		buckets:     mocks.NewMockIBucketRepository(ctrl),
+		bucketItems: mocks.NewMockIBucketItemRepository(ctrl),
+		sessions:    mocks.NewMockISessionRepository(ctrl),
+		usage:       mocks.NewMockIUsageRepository(ctrl),
+		out:         &bytes.Buffer{},
+	}
+	tc.ctl = &ctl{
+		cfg: &config.Config{
+			Env:                    "test",
+			QuotaMaxBuckets:        2,
+			QuotaMaxItemsPerBucket: 10,
+			QuotaMaxValueBytes:     64,
+			QuotaMaxStorageBytes:   1024,
+		},
+		users:       tc.users,
+		apiKeys:     tc.apiKeys,
+		buckets:     tc.buckets,
+		bucketItems: tc.bucketItems,
+		sessions:    tc.sessions,
+		usage:       tc.usage,
+		output:      output,
+		stdout:      tc.out,
+	}
+	return tc
+}
+
+// Returns the password printed by a command
+func printedPassword(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "Password: ") {
+			return strings.TrimPrefix(line, "Password: ")
+		}
+	}
+	return ""
+}
+
+func TestUserCmd_Create(t *testing.T) {
+	tt := []struct {
+		name    string
+		args    []string
+		stubFn  func(tc *testCtl)
+		check   func(t *testing.T, out string)
+		wantErr error
+	}{
+		{
+			name: "should_create_a_user_with_a_generated_password",
+			args: []string{"create", "--email", "new@example.com", "--firstname", "New"},
+			stubFn: func(tc *testCtl) {
+				tc.users.EXPECT().FindUserByEmail(gomock.Any(), "new@example.com").
+					Times(1).Return(nil, models.ErrUserNotFound)
+				tc.users.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(func(_ context.Context, user *models.User) error {
+					assert.Equal(t, "new@example.com", user.Email)
+					assert.Equal(t, "New", user.Firstname)
+					assert.Empty(t, user.Role)
+					assert.False(t, user.EmailVerified)
+					assert.False(t, user.ID.IsZero())
+					return nil
+				})
+			},
+			check: func(t *testing.T, out string) {
+				assert.Contains(t, out, "Created user new@example.com")
+				assert.Len(t, printedPassword(out), generatedPasswordLength)
+			},
+		},
+		{
+			name: "should_create_a_verified_admin_with_the_given_password",
+			args: []string{"create", "--admin", "--verified", "--email", "admin@example.com", "--password", "s3cret-password"},
+			stubFn: func(tc *testCtl) {
+				tc.users.EXPECT().FindUserByEmail(gomock.Any(), "admin@example.com").
+					Times(1).Return(nil, models.ErrUserNotFound)
+				tc.users.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(func(_ context.Context, user *models.User) error {
+					assert.Equal(t, models.UserRoleAdmin, user.Role)
+					assert.True(t, user.EmailVerified)
+					assert.Nil(t, utils.ComparePasswordHash("s3cret-password", user.Password))
+					return nil
+				})
+			},
+			check: func(t *testing.T, out string) {
+				// a given password is not printed back
+				assert.Empty(t, printedPassword(out))
+			},
+		},
+		{
+			name: "should_fail_user_already_exists",
+			args: []string{"create", "--email", "taken@example.com"},
+			stubFn: func(tc *testCtl) {
+				tc.users.EXPECT().FindUserByEmail(gomock.Any(), "taken@example.com").
+					Times(1).Return(&models.User{Email: "taken@example.com"}, nil)
+				tc.users.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: models.ErrUserAlreadyExists,
+		},
+		{
+			name:   "should_fail_email_missing",
+			args:   []string{"create", "--admin"},
+			stubFn: func(tc *testCtl) {},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newTestCtl(ctrl, "table")
+			tc.stubFn(c)
+
+			err := userCmd(context.Background(), c.ctl, tc.args)
+			if tc.check == nil {
+				assert.NotNil(t, err)
+				if tc.wantErr != nil {
+					assert.ErrorIs(t, err, tc.wantErr)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			tc.check(t, c.out.String())
+		})
+	}
+}
+
+func TestUserCmd_ResetPassword(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Email: "me@example.com", Password: "previous-hash"}
+	sessionsErr := errors.New("sessions unavailable")
+
+	tt := []struct {
+		name    string
+		args    []string
+		stubFn  func(tc *testCtl)
+		check   func(t *testing.T, out string)
+		wantErr error
+	}{
+		{
+			name: "should_reset_the_password_and_revoke_the_sessions",
+			args: []string{"reset-password", "me@example.com"},
+			stubFn: func(tc *testCtl) {
+				updated := *user
+				tc.users.EXPECT().FindUserByEmail(gomock.Any(), "me@example.com").
+					Times(1).Return(&updated, nil)
+				gomock.InOrder(
+					tc.users.EXPECT().UpdateUser(gomock.Any(), &updated).Times(1).Return(nil),
+					tc.sessions.EXPECT().RevokeUserSessions(gomock.Any(), user.ID, models.SessionRevokedPasswordReset).
+						Times(1).Return(int64(2), nil),
+				)
+			},
+			check: func(t *testing.T, out string) {
+				assert.Contains(t, out, "Reset the password of me@example.com, revoked 2 sessions")
+				assert.Len(t, printedPassword(out), generatedPasswordLength)
+			},
+		},
+		{
+			name: "should_reset_to_the_given_password",
+			args: []string{"reset-password", "--password", "new-password", "me@example.com"},
+			stubFn: func(tc *testCtl) {
+				tc.users.EXPECT().FindUserByEmail(gomock.Any(), "me@example.com").
+					Times(1).Return(&models.User{ID: user.ID, Email: user.Email, Password: user.Password}, nil)
+				tc.users.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(func(_ context.Context, u *models.User) error {
+					assert.Nil(t, utils.ComparePasswordHash("new-password", u.Password))
+					return nil
+				})
+				tc.sessions.EXPECT().RevokeUserSessions(gomock.Any(), user.ID, models.SessionRevokedPasswordReset).
+					Times(1).Return(int64(0), nil)
+			},
+			check: func(t *testing.T, out string) {
+				assert.Contains(t, out, "revoked 0 sessions")
+				assert.Empty(t, printedPassword(out))
+			},
+		},
+		{
+			name: "should_fail_user_not_found",
+			args: []string{"reset-password", "unknown@example.com"},
+			stubFn: func(tc *testCtl) {
+				tc.users.EXPECT().FindUserByEmail(gomock.Any(), "unknown@example.com").
+					Times(1).Return(nil, models.ErrUserNotFound)
+				tc.users.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(0)
+				tc.sessions.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: models.ErrUserNotFound,
+		},
+		{
+			name: "should_fail_sessions_not_revoked",
+			args: []string{"reset-password", "me@example.com"},
+			stubFn: func(tc *testCtl) {
+				tc.users.EXPECT().FindUserByEmail(gomock.Any(), "me@example.com").
+					Times(1).Return(&models.User{ID: user.ID, Email: user.Email}, nil)
+				tc.users.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				tc.sessions.EXPECT().RevokeUserSessions(gomock.Any(), user.ID, models.SessionRevokedPasswordReset).
+					Times(1).Return(int64(0), sessionsErr)
+			},
+			wantErr: sessionsErr,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newTestCtl(ctrl, "table")
+			tc.stubFn(c)
+
+			err := userCmd(context.Background(), c.ctl, tc.args)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			tc.check(t, c.out.String())
+		})
+	}
+}
+
+func TestAPIKeyCmd_Revoke(t *testing.T) {
+	apiKeyID := primitive.NewObjectID().Hex()
+
+	tt := []struct {
+		name    string
+		stubFn  func(tc *testCtl)
+		wantErr error
+	}{
+		{
+			name: "should_revoke_the_api_key",
+			stubFn: func(tc *testCtl) {
+				tc.apiKeys.EXPECT().FindAPIKeyByID(gomock.Any(), apiKeyID).
+					Times(1).Return(&models.APIKey{}, nil)
+				tc.apiKeys.EXPECT().RevokeAPIKey(gomock.Any(), apiKeyID).
+					Times(1).Return(nil)
+			},
+		},
+		{
+			name: "should_fail_api_key_not_found",
+			stubFn: func(tc *testCtl) {
+				tc.apiKeys.EXPECT().FindAPIKeyByID(gomock.Any(), apiKeyID).
+					Times(1).Return(nil, models.ErrAPIKeyNotFound)
+				// revoking upserts, an unknown key must not be created
+				tc.apiKeys.EXPECT().RevokeAPIKey(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: models.ErrAPIKeyNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newTestCtl(ctrl, "table")
+			tc.stubFn(c)
+
+			err := apiKeyCmd(context.Background(), c.ctl, []string{"revoke", apiKeyID})
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, "Revoked API key "+apiKeyID+"\n", c.out.String())
+		})
+	}
+}
+
+func TestBucketCmd_Chown(t *testing.T) {
+	previousOwner := primitive.NewObjectID()
+	newOwner := &models.User{ID: primitive.NewObjectID(), Email: "new@example.com"}
+	moved := models.UsageDelta{Buckets: 1, Items: 3, Bytes: 300}
+	updateErr := errors.New("update failed")
+
+	// the usage of the bucket is read before it moves
+	expectBucketUsage := func(tc *testCtl) {
+		tc.usage.EXPECT().FindBucketUsage(gomock.Any(), "12345").
+			Times(1).Return(&models.Usage{BucketUID: "12345", Items: 3, Bytes: 300}, nil)
+	}
+
+	tt := []struct {
+		name    string
+		owner   primitive.ObjectID
+		stubFn  func(tc *testCtl)
+		wantOut string
+		wantErr error
+	}{
+		{
+			name: "should_move_the_bucket_and_its_usage",
+			stubFn: func(tc *testCtl) {
+				expectBucketUsage(tc)
+				tc.usage.EXPECT().FindUserUsage(gomock.Any(), newOwner.ID).
+					Times(1).Return(&models.Usage{UserID: newOwner.ID}, nil)
+				gomock.InOrder(
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), newOwner.ID, "", moved, gomock.Any()).
+						Times(1).Return(nil),
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), previousOwner, "", moved.Negate(), models.Quota{}).
+						Times(1).Return(nil),
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), newOwner.ID, "12345", models.UsageDelta{}, models.Quota{}).
+						Times(1).Return(nil),
+					tc.buckets.EXPECT().UpdateBucket(gomock.Any(), gomock.Any()).
+						Times(1).DoAndReturn(func(_ context.Context, bucket *models.Bucket) error {
+						assert.Equal(t, newOwner.ID, bucket.UserID)
+						return nil
+					}),
+				)
+			},
+			wantOut: "new@example.com now owns bucket 12345\n",
+		},
+		{
+			name:  "should_not_move_a_bucket_already_owned",
+			owner: newOwner.ID,
+			stubFn: func(tc *testCtl) {
+				tc.buckets.EXPECT().UpdateBucket(gomock.Any(), gomock.Any()).Times(0)
+				tc.usage.EXPECT().IncrementUsage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantOut: "new@example.com already owns bucket 12345\n",
+		},
+		{
+			name: "should_fail_bucket_quota_of_the_new_owner_exceeded",
+			stubFn: func(tc *testCtl) {
+				expectBucketUsage(tc)
+				tc.usage.EXPECT().FindUserUsage(gomock.Any(), newOwner.ID).
+					Times(1).Return(&models.Usage{UserID: newOwner.ID, Buckets: 2}, nil)
+				tc.usage.EXPECT().IncrementUsage(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+				tc.buckets.EXPECT().UpdateBucket(gomock.Any(), gomock.Any()).Times(0)
+			},
+			wantErr: models.ErrBucketQuotaExceeded,
+		},
+		{
+			name: "should_give_the_usage_back_bucket_not_updated",
+			stubFn: func(tc *testCtl) {
+				tc.usage.EXPECT().FindBucketUsage(gomock.Any(), "12345").
+					Times(2).Return(&models.Usage{BucketUID: "12345", Items: 3, Bytes: 300}, nil)
+				tc.usage.EXPECT().FindUserUsage(gomock.Any(), newOwner.ID).
+					Times(1).Return(&models.Usage{UserID: newOwner.ID}, nil)
+				tc.usage.EXPECT().FindUserUsage(gomock.Any(), previousOwner).
+					Times(1).Return(&models.Usage{UserID: previousOwner}, nil)
+				gomock.InOrder(
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), newOwner.ID, "", moved, gomock.Any()).Times(1).Return(nil),
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), previousOwner, "", moved.Negate(), models.Quota{}).Times(1).Return(nil),
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), newOwner.ID, "12345", models.UsageDelta{}, models.Quota{}).Times(1).Return(nil),
+					tc.buckets.EXPECT().UpdateBucket(gomock.Any(), gomock.Any()).Times(1).Return(updateErr),
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), previousOwner, "", moved, gomock.Any()).Times(1).Return(nil),
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), newOwner.ID, "", moved.Negate(), models.Quota{}).Times(1).Return(nil),
+					tc.usage.EXPECT().IncrementUsage(gomock.Any(), previousOwner, "12345", models.UsageDelta{}, models.Quota{}).Times(1).Return(nil),
+				)
+			},
+			wantErr: updateErr,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newTestCtl(ctrl, "table")
+			bucket := &models.Bucket{UID: "12345", UserID: previousOwner}
+			if !tc.owner.IsZero() {
+				bucket.UserID = tc.owner
+			}
+			c.buckets.EXPECT().FindBucketByUID(gomock.Any(), "12345").Times(1).Return(bucket, nil)
+			c.users.EXPECT().FindUserByEmail(gomock.Any(), "new@example.com").Times(1).Return(newOwner, nil)
+			tc.stubFn(c)
+
+			err := bucketCmd(context.Background(), c.ctl, []string{"chown", "12345", "new@example.com"})
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantOut, c.out.String())
+		})
+	}
+}
+
+func TestPurgeExpiredCmd(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	c := newTestCtl(ctrl, "table")
+	ownerID := primitive.NewObjectID()
+
+	c.bucketItems.EXPECT().PurgeExpiredBucketItems(gomock.Any(), gomock.Any()).
+		Times(1).Return([]models.BucketItem{{BucketUID: "12345", Key: "a", Type: models.BucketItemTypeBinary, Size: 40}}, nil)
+	c.buckets.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+		Times(1).Return(&models.Bucket{UID: "12345", UserID: ownerID}, nil)
+	// the usage of the purged items is given back to their owner
+	c.usage.EXPECT().IncrementUsage(gomock.Any(), ownerID, "12345", models.UsageDelta{Items: -1, Bytes: -40}, models.Quota{}).
+		Times(1).Return(nil)
+
+	err := purgeExpiredCmd(context.Background(), c.ctl, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "Purged 1 expired items\n", c.out.String())
+}
+
+func TestCompressCmd(t *testing.T) {
+	tt := []struct {
+		name    string
+		args    []string
+		stubFn  func(tc *testCtl)
+		wantOut string
+		wantErr error
+	}{
+		{
+			name: "should_compress_the_items_of_every_bucket",
+			stubFn: func(tc *testCtl) {
+				tc.bucketItems.EXPECT().CompressBucketItems(gomock.Any(), "").
+					Times(1).Return(int64(12), nil)
+			},
+			wantOut: "Compressed 12 items\n",
+		},
+		{
+			name: "should_compress_the_items_of_a_bucket",
+			args: []string{"12345"},
+			stubFn: func(tc *testCtl) {
+				tc.buckets.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+				tc.bucketItems.EXPECT().CompressBucketItems(gomock.Any(), "12345").
+					Times(1).Return(int64(3), nil)
+			},
+			wantOut: "Compressed 3 items\n",
+		},
+		{
+			name: "should_fail_with_an_unknown_bucket",
+			args: []string{"unknown"},
+			stubFn: func(tc *testCtl) {
+				tc.buckets.EXPECT().FindBucketByUID(gomock.Any(), "unknown").
+					Times(1).Return(nil, models.ErrBucketNotFound)
+				tc.bucketItems.EXPECT().CompressBucketItems(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			wantErr: models.ErrBucketNotFound,
+		},
+		{
+			name: "should_fail_when_the_compression_fails",
+			stubFn: func(tc *testCtl) {
+				tc.bucketItems.EXPECT().CompressBucketItems(gomock.Any(), "").
+					Times(1).Return(int64(5), models.ErrCompressingItem)
+			},
+			wantErr: models.ErrCompressingItem,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newTestCtl(ctrl, "table")
+			tc.stubFn(c)
+
+			err := compressCmd(context.Background(), c.ctl, tc.args)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.wantOut, c.out.String())
+		})
+	}
+}
+
+func TestStatsCmd(t *testing.T) {
+	stats := &databaseStats{
+		Database:    "keeper",
+		DataSize:    int32(2048),
+		StorageSize: int64(3 * 1024 * 1024),
+		IndexSize:   float64(512),
+		Indexes:     int32(7),
+		Collections: []collectionStats{{Name: "bucketitems", Documents: 42}, {Name: "users", Documents: 3}},
+	}
+
+	tt := []struct {
+		name   string
+		output string
+		check  func(t *testing.T, out string)
+	}{
+		{
+			name:   "should_print_a_table",
+			output: "table",
+			check: func(t *testing.T, out string) {
+				assert.Contains(t, out, "Database:      keeper\n")
+				assert.Contains(t, out, "Data size:     2.0 KiB\n")
+				assert.Contains(t, out, "Storage size:  3.0 MiB\n")
+				assert.Contains(t, out, "Index size:    512.0 B\n")
+				assert.Contains(t, out, "Indexes:       7\n")
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				assert.Equal(t, []string{"COLLECTION", "DOCUMENTS"}, strings.Fields(lines[len(lines)-3]))
+				assert.Equal(t, []string{"bucketitems", "42"}, strings.Fields(lines[len(lines)-2]))
+				assert.Equal(t, []string{"users", "3"}, strings.Fields(lines[len(lines)-1]))
+			},
+		},
+		{
+			name:   "should_print_json",
+			output: "json",
+			check: func(t *testing.T, out string) {
+				decoded := map[string]interface{}{}
+				assert.Nil(t, json.Unmarshal([]byte(out), &decoded))
+				assert.Equal(t, "keeper", decoded["database"])
+				assert.Equal(t, float64(2048), decoded["data_size"])
+				assert.Len(t, decoded["collections"], 2)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := newTestCtl(ctrl, tc.output)
+			c.stats = func(ctx context.Context) (*databaseStats, error) {
+				return stats, nil
+			}
+
+			err := statsCmd(context.Background(), c.ctl, nil)
+
+			assert.Nil(t, err)
+			tc.check(t, c.out.String())
+		})
+	}
+
+	t.Run("should_fail_stats_not_read", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		c := newTestCtl(ctrl, "table")
+		readErr := errors.New("dbStats failed")
+		c.stats = func(ctx context.Context) (*databaseStats, error) {
+			return nil, readErr
+		}
+
+		assert.ErrorIs(t, statsCmd(context.Background(), c.ctl, nil), readErr)
+		assert.Empty(t, c.out.String())
+	})
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "100.0 B", formatBytes(int32(100)))
+	assert.Equal(t, "1.5 KiB", formatBytes(int64(1536)))
+	assert.Equal(t, "1.0 GiB", formatBytes(float64(1<<30)))
+	// the values of an unexpected type are printed as is
+	assert.Equal(t, "<nil>", formatBytes(nil))
+}
@@ -0,0 +1,176 @@
+// Command kipactl is the administration tool of the Kipa operators,
+// it works on the configured database directly instead of through the API.
+//
+//	kipactl user create --email admin@example.com --admin
+//	kipactl apikey revoke <id>
+//	kipactl stats
+//
+// Run 'kipactl help' for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"keeper/internal/config"
+	"keeper/internal/repository"
+	"keeper/pkg/mongo"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+)
+
+const usage = `Usage: kipactl [-o table|json] <command> [flags] [args]
+
+Commands:
+  user ls                                 list the users
+  user create --email e [--password p] [--firstname f] [--lastname l] [--admin] [--verified]
+                                          create a user, a password is generated unless given
+  user reset-password <email> [--password p]
+                                          set a new password and revoke the sessions of the user,
+                                          a password is generated unless given
+  user verify <email>                     mark the email of a user as verified
+  apikey ls [--user email]                list the API keys of every user, or of a single user
+  apikey revoke <id>                      revoke an API key
+  bucket chown <bucket> <email>           make a user the owner of a bucket, within the quota of the user
+  compress [bucket]                       compress the stored items above the compression threshold,
+                                          of every bucket unless one is given
+  migrate                                 apply the pending index, schema and data migrations
+  migrate status                          list the migrations and when they were applied
+  purge-expired                           permanently delete the items whose TTL ran out
+  stats                                   print database statistics
+
+The database is configured with the environment variables, or the .env file, of the server.
+
+Flags:
+`
+
+// database and repositories the commands work on
+type ctl struct {
+	cfg         *config.Config
+	db          *mongodriver.Database
+	users       repository.IUserRepository
+	apiKeys     repository.IAPIKeyRepository
+	buckets     repository.IBucketRepository
+	bucketItems repository.IBucketItemRepository
+	sessions    repository.ISessionRepository
+	usage       repository.IUsageRepository
+	stats       func(ctx context.Context) (*databaseStats, error)
+	output      string
+	stdout      io.Writer
+}
+
+type command func(ctx context.Context, c *ctl, args []string) error
+
+var commands = map[string]command{
+	"user":          userCmd,
+	"apikey":        apiKeyCmd,
+	"bucket":        bucketCmd,
+	"compress":      compressCmd,
+	"migrate":       migrateCmd,
+	"purge-expired": purgeExpiredCmd,
+	"stats":         statsCmd,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) (code int) {
+	c := &ctl{stdout: os.Stdout}
+	fs := flag.NewFlagSet("kipactl", flag.ContinueOnError)
+	fs.StringVar(&c.output, "o", "table", "output format, 'table' or 'json'")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 || fs.Arg(0) == "help" {
+		fs.Usage()
+		return 0
+	}
+	if c.output != "table" && c.output != "json" {
+		fmt.Fprintf(os.Stderr, "kipactl: unknown output format %q\n", c.output)
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "kipactl: unknown command %q, run 'kipactl help' for usage\n", fs.Arg(0))
+		return 2
+	}
+
+	// the repositories log every lookup, only their warnings are relevant here
+	logrus.SetLevel(logrus.WarnLevel)
+	// the connection panics when the database cannot be reached
+	defer func() {
+		if r := recover(); r != nil {
+			if entry, ok := r.(*logrus.Entry); ok {
+				r = entry.Message
+			}
+			fmt.Fprintf(os.Stderr, "kipactl: %v\n", r)
+			code = 1
+		}
+	}()
+	cfg := config.New()
+	conn := mongo.NewConnection(cfg)
+	defer conn.Disconnect()
+	c.cfg = cfg
+	c.db = conn.Client.Database(cfg.DbName)
+	c.users = repository.NewUserRepository(cfg, conn.Client)
+	c.apiKeys = repository.NewAPIKeyRepository(cfg, conn.Client)
+	c.buckets = repository.NewBucketRepository(cfg, conn.Client)
+	c.bucketItems = repository.NewBucketItemRepository(cfg, conn.Client)
+	c.sessions = repository.NewSessionRepository(cfg, conn.Client)
+	c.usage = repository.NewUsageRepository(cfg, conn.Client)
+	c.stats = func(ctx context.Context) (*databaseStats, error) {
+		return readDatabaseStats(ctx, c.db)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := cmd(ctx, c, fs.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "kipactl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// Prints a value as indented JSON, or as a table built by rows
+func (c *ctl) print(value interface{}, header []string, rows func() [][]string) error {
+	if c.output == "json" {
+		return printJSON(c.stdout, value)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows() {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// Parses the flags of a command wherever they are placed among its arguments
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -1,25 +1,104 @@
 package main
 
 import (
+	"context"
+	"keeper/internal/auth/jwt"
 	"keeper/internal/config"
+	"keeper/internal/migrations"
+	"keeper/internal/pkg/tracing"
 	"keeper/internal/queue"
 	"keeper/internal/queue/tasks"
+	"keeper/internal/repository"
 	"keeper/internal/server"
+	"keeper/internal/services"
+	"keeper/pkg/log"
 	"keeper/pkg/mongo"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
 )
 
 func main() {
 	cfg := config.New()
+	if err := log.SetLevel(cfg.LogLevel); err != nil {
+		logrus.WithError(err).Fatal("invalid log level")
+	}
+	shutdownTracing, err := tracing.Init(cfg)
+	if err != nil {
+		logrus.WithError(err).Fatal("failed to set up tracing")
+	}
+	defer shutdownTracing(context.Background())
+
+	// the signing keys are checked before the tokens are issued with them
+	if _, err := jwt.Keys(cfg); err != nil {
+		logrus.WithError(err).Fatal("failed to load the JWT signing keys")
+	}
+
 	db := mongo.NewConnection(cfg)
 	defer db.Disconnect()
 
+	if cfg.RunMigrations {
+		// the indexes and unique constraints are in place before any request is served
+		if _, err := migrations.Run(context.Background(), db.Client.Database(cfg.DbName)); err != nil {
+			logrus.WithError(err).Fatal("failed to migrate the database")
+		}
+	}
+
 	if cfg.WithWorkers {
 		// register the asynq worker
 		consumer := queue.NewConsumer(cfg)
 		consumer.RegisterHandler(tasks.TypeUserVerificationMail, tasks.SendUserVerificationMail)
 		consumer.RegisterHandler(tasks.TypeUserResetPasswordMail, tasks.SendResetPasswordMail)
+		consumer.RegisterHandler(tasks.TypeLoginUnlockMail, tasks.SendLoginUnlockMail)
+		consumer.RegisterHandler(tasks.TypeCompressBucketItems, tasks.CompressBucketItems(cfg, db.Client))
+		consumer.RegisterHandler(tasks.TypePurgeTrash, tasks.PurgeTrash(cfg, db.Client))
+		consumer.RegisterHandler(tasks.TypeDeleteUser, tasks.DeleteUser(services.NewUserService(
+			cfg,
+			repository.NewUserRepository(cfg, db.Client),
+			repository.NewBucketRepository(cfg, db.Client),
+			repository.NewBucketItemRepository(cfg, db.Client),
+			repository.NewBucketSnapshotRepository(cfg, db.Client),
+			repository.NewAPIKeyRepository(cfg, db.Client),
+			repository.NewTwoFactorRepository(cfg, db.Client),
+			repository.NewSessionRepository(cfg, db.Client),
+			repository.NewUsageRepository(cfg, db.Client),
+			repository.NewLoginAttemptRepository(cfg, db.Client),
+			repository.NewWebhookRepository(cfg, db.Client),
+		)))
+
+		webhookService := services.NewWebhookService(
+			cfg,
+			repository.NewWebhookRepository(cfg, db.Client),
+			repository.NewWebhookDeliveryRepository(cfg, db.Client),
+			repository.NewBucketRepository(cfg, db.Client),
+		)
+		consumer.RegisterHandler(tasks.TypeDispatchWebhookEvent, tasks.DispatchWebhookEvent(webhookService))
+		consumer.RegisterHandler(tasks.TypeDeliverWebhook, tasks.DeliverWebhook(webhookService))
+		consumer.RegisterHandler(tasks.TypeExpireBucketItems, tasks.ExpireBucketItems(services.NewBucketItemService(
+			cfg,
+			repository.NewBucketItemRepository(cfg, db.Client),
+			repository.NewBucketRepository(cfg, db.Client),
+			repository.NewBucketItemBlobRepository(cfg, db.Client),
+			nil, // the expiry sweep is not audited
+			repository.NewUsageRepository(cfg, db.Client),
+			webhookService,
+		)))
+
+		consumer.RegisterHandler(tasks.TypeRecomputeUsage, tasks.RecomputeUsage(services.NewUsageService(
+			cfg,
+			repository.NewUsageRepository(cfg, db.Client),
+			repository.NewUserRepository(cfg, db.Client),
+		)))
 
 		go consumer.Start()
+
+		// register the periodic tasks
+		scheduler := queue.NewScheduler(cfg)
+		scheduler.Register(cfg.TrashPurgeSchedule, tasks.NewPurgeTrashTask(), asynq.Queue("low"))
+		scheduler.Register(cfg.ItemExpirySweepSchedule, tasks.NewExpireBucketItemsTask(), asynq.Queue("low"))
+		scheduler.Register(cfg.UsageRecomputeSchedule, tasks.NewRecomputeUsageTask(), asynq.Queue("low"))
+
+		go scheduler.Start()
 	}
 
 	server := server.NewServer(cfg, db.Client)
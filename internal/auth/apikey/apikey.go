@@ -2,6 +2,7 @@ package apikey
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
@@ -16,7 +17,7 @@ import (
 )
 
 type IAPIKeyService interface {
-	Authenticate(credential *auth.Credential) (*auth.AuthResponse, error)
+	Authenticate(ctx context.Context, credential *auth.Credential) (*auth.AuthResponse, error)
 }
 
 type APIKeyService struct {
@@ -43,7 +44,7 @@ var (
 )
 
 // Authenticate an API Key
-func (a *APIKeyService) Authenticate(credential *auth.Credential) (*auth.AuthResponse, error) {
+func (a *APIKeyService) Authenticate(ctx context.Context, credential *auth.Credential) (*auth.AuthResponse, error) {
 	if credential.Type != auth.CredentialTypeAPIKey {
 		return nil, ErrAPIKeyCredentialType
 	}
@@ -58,7 +59,7 @@ func (a *APIKeyService) Authenticate(credential *auth.Credential) (*auth.AuthRes
 	// obtain the mask ID
 	maskID := keySplit[1]
 	// find the API Key
-	apiKey, err := a.apiKeyRepo.FindAPIKeyByMaskID(maskID)
+	apiKey, err := a.apiKeyRepo.FindAPIKeyByMaskID(ctx, maskID)
 	if err != nil {
 		return nil, ErrAPIKeyDoesNotExist
 	}
@@ -87,7 +88,7 @@ func (a *APIKeyService) Authenticate(credential *auth.Credential) (*auth.AuthRes
 	}
 
 	// get the user payload
-	user, err := a.userRepo.FindUserById(apiKey.UserID.Hex())
+	user, err := a.userRepo.FindUserById(ctx, apiKey.UserID.Hex())
 	if err != nil {
 		return nil, models.ErrUserNotFound
 	}
@@ -97,6 +98,7 @@ func (a *APIKeyService) Authenticate(credential *auth.Credential) (*auth.AuthRes
 		Credential:  *credential,
 		User:        user,
 		Permissions: apiKey.Permissions,
+		RateLimit:   apiKey.RateLimit,
 	}
 
 	return authResponse, nil
@@ -1,6 +1,7 @@
 package apikey
 
 import (
+	"context"
 	"errors"
 	"keeper/internal/auth"
 	"keeper/internal/mocks"
@@ -52,8 +53,8 @@ func TestAPIKeyService_Authenticate(t *testing.T) {
 			},
 			stubFn: func(mockAPIKeyRepo *mocks.MockIAPIKeyRepository, mockUserRepo *mocks.MockIUserRepository) {
 				// stubs
-				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any()).Times(1).Return(testAPIKey, nil)
-				mockUserRepo.EXPECT().FindUserById(gomock.Any()).Times(1).Return(testUser, nil)
+				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any(), gomock.Any()).Times(1).Return(testAPIKey, nil)
+				mockUserRepo.EXPECT().FindUserById(gomock.Any(), gomock.Any()).Times(1).Return(testUser, nil)
 			},
 			wantErr:    false,
 			wantErrMsg: "",
@@ -76,7 +77,7 @@ func TestAPIKeyService_Authenticate(t *testing.T) {
 			},
 			stubFn: func(mockAPIKeyRepo *mocks.MockIAPIKeyRepository, mockUserRepo *mocks.MockIUserRepository) {
 				// stubs
-				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any()).Times(1).Return(nil, errors.New("api key does not exist"))
+				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any(), gomock.Any()).Times(1).Return(nil, errors.New("api key does not exist"))
 			},
 			wantErr:    true,
 			wantErrMsg: ErrAPIKeyDoesNotExist.Error(),
@@ -92,7 +93,7 @@ func TestAPIKeyService_Authenticate(t *testing.T) {
 			},
 			stubFn: func(mockAPIKeyRepo *mocks.MockIAPIKeyRepository, mockUserRepo *mocks.MockIUserRepository) {
 				// stubs
-				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any()).Times(1).Return(&models.APIKey{
+				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any(), gomock.Any()).Times(1).Return(&models.APIKey{
 					Hash:   "abcde",
 					MaskID: "Ml7nXwRH3Nw3uX3x",
 					Salt:   "JaOhInSZpNeq8DYNdGmfAxBl",
@@ -137,7 +138,7 @@ func TestAPIKeyService_Authenticate(t *testing.T) {
 			},
 			stubFn: func(mockAPIKeyRepo *mocks.MockIAPIKeyRepository, mockUserRepo *mocks.MockIUserRepository) {
 				// stubs
-				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any()).Times(1).Return(testAPIKey, nil)
+				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any(), gomock.Any()).Times(1).Return(testAPIKey, nil)
 			},
 			wantErr:    true,
 			wantErrMsg: ErrInvalidAPIKey.Error(),
@@ -154,7 +155,7 @@ func TestAPIKeyService_Authenticate(t *testing.T) {
 			stubFn: func(mockAPIKeyRepo *mocks.MockIAPIKeyRepository, mockUserRepo *mocks.MockIUserRepository) {
 				// stubs
 				testAPIKey.ExpiresAt = primitive.NewDateTimeFromTime(time.Now().Add(-time.Hour))
-				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any()).Times(1).Return(testAPIKey, nil)
+				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any(), gomock.Any()).Times(1).Return(testAPIKey, nil)
 			},
 			wantErr:    true,
 			wantErrMsg: ErrExpiredAPIKey.Error(),
@@ -171,7 +172,7 @@ func TestAPIKeyService_Authenticate(t *testing.T) {
 				// stubs
 				testAPIKey.ExpiresAt = primitive.NewDateTimeFromTime(time.Now().Add(time.Hour))
 				testAPIKey.Revoked = true
-				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any()).Times(1).Return(testAPIKey, nil)
+				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any(), gomock.Any()).Times(1).Return(testAPIKey, nil)
 			},
 			wantErr:    true,
 			wantErrMsg: ErrRevokedAPIKey.Error(),
@@ -187,8 +188,8 @@ func TestAPIKeyService_Authenticate(t *testing.T) {
 			stubFn: func(mockAPIKeyRepo *mocks.MockIAPIKeyRepository, mockUserRepo *mocks.MockIUserRepository) {
 				// stubs
 				testAPIKey.Revoked = false
-				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any()).Times(1).Return(testAPIKey, nil)
-				mockUserRepo.EXPECT().FindUserById(gomock.Any()).Times(1).Return(nil, models.ErrUserNotFound)
+				mockAPIKeyRepo.EXPECT().FindAPIKeyByMaskID(gomock.Any(), gomock.Any()).Times(1).Return(testAPIKey, nil)
+				mockUserRepo.EXPECT().FindUserById(gomock.Any(), gomock.Any()).Times(1).Return(nil, models.ErrUserNotFound)
 			},
 			wantErr:    true,
 			wantErrMsg: models.ErrUserNotFound.Error(),
@@ -206,7 +207,7 @@ func TestAPIKeyService_Authenticate(t *testing.T) {
 				tc.stubFn(mockAPIKeyRepo, mockUserRepo)
 			}
 
-			resp, err := apiKeyChainSvc.Authenticate(tc.args.credential)
+			resp, err := apiKeyChainSvc.Authenticate(context.Background(), tc.args.credential)
 			if tc.wantErr {
 				require.Equal(t, tc.wantErrMsg, err.Error())
 				return
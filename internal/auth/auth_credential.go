@@ -22,6 +22,8 @@ type AuthResponse struct {
 	Credential  Credential                   `json:"credential"`
 	User        *models.User                 `json:"user"`
 	Permissions models.APIKeyPermissionsList `json:"permissions"`
+	RateLimit   *models.APIKeyRateLimit      `json:"rate_limit"`
+	SessionID   string                       `json:"-"` // session of the jwt, if any
 }
 
 const (
@@ -1,6 +1,7 @@
 package auth_realm
 
 import (
+	"context"
 	"errors"
 	"keeper/internal/auth"
 	"keeper/internal/auth/apikey"
@@ -15,22 +16,22 @@ type AuthRealm struct {
 }
 
 type IAuthRealm interface {
-	Authenticate(credential *auth.Credential) (*auth.AuthResponse, error)
+	Authenticate(ctx context.Context, credential *auth.Credential) (*auth.AuthResponse, error)
 }
 
-func NewAuthRealm(cfg *config.Config, apiKeyRepository repository.IAPIKeyRepository, userRepository repository.IUserRepository) IAuthRealm {
+func NewAuthRealm(cfg *config.Config, apiKeyRepository repository.IAPIKeyRepository, userRepository repository.IUserRepository, sessionRepository repository.ISessionRepository) IAuthRealm {
 	return &AuthRealm{
-		jwtSvc:    jwt.NewJwtService(cfg, userRepository),
+		jwtSvc:    jwt.NewJwtService(cfg, userRepository, sessionRepository),
 		apiKeySvc: apikey.NewAPIKeyService(apiKeyRepository, userRepository),
 	}
 }
 
-func (r *AuthRealm) Authenticate(credential *auth.Credential) (*auth.AuthResponse, error) {
+func (r *AuthRealm) Authenticate(ctx context.Context, credential *auth.Credential) (*auth.AuthResponse, error) {
 	if credential.Type == auth.CredentialTypeAPIKey {
-		return r.apiKeySvc.Authenticate(credential)
+		return r.apiKeySvc.Authenticate(ctx, credential)
 	}
 	if credential.Type == auth.CredentialTypeJWT || credential.Type == auth.CredentialTypeRefreshJWT {
-		return r.jwtSvc.Authenticate(credential)
+		return r.jwtSvc.Authenticate(ctx, credential)
 	}
 	return nil, errors.New("credential type is invalid")
 }
@@ -1,10 +1,12 @@
 package jwt
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"keeper/internal/auth"
 	"keeper/internal/config"
+	"keeper/internal/models"
 	"keeper/internal/repository"
 	_ "keeper/pkg/log"
 	"strconv"
@@ -15,37 +17,57 @@ import (
 )
 
 type IJwtService interface {
-	GenerateToken(payload map[string]interface{}, expiresIn string, secret string) (string, error)
+	GenerateToken(payload map[string]interface{}, tokenType string, expiresIn string) (string, error)
 	ValidateToken(tokenString string) (*jwt.Token, error)
-	DecodeToken(tokenString string, secret string) (*JwtCustomClaims, error)
+	DecodeToken(tokenString string, tokenType string) (*JwtCustomClaims, error)
 	GenerateAccessToken(payload map[string]interface{}) (string, error)
 	GenerateRefreshToken(payload map[string]interface{}) (string, error)
 	GenerateEmailVerificationToken(payload map[string]interface{}) (string, error)
 	GenerateResetPasswordToken(payload map[string]interface{}) (string, error)
-	Authenticate(credential *auth.Credential) (*auth.AuthResponse, error)
+	GenerateLoginUnlockToken(payload map[string]interface{}) (string, error)
+	GenerateTwoFactorToken(payload map[string]interface{}) (string, error)
+	Authenticate(ctx context.Context, credential *auth.Credential) (*auth.AuthResponse, error)
 }
 
+// Types of the tokens, set in the typ claim so that one kind of token cannot be used as another
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypeResetPassword     = "reset_password"
+	TokenTypeLoginUnlock       = "login_unlock"
+	TokenTypeTwoFactor         = "two_factor"
+)
+
+// audience of the access tokens when JWT_AUDIENCE is not set
+const defaultAudience = "kipa"
+
 // JWT custom claims
 type JwtCustomClaims struct {
 	Payload map[string]interface{}
+	Type    string `json:"typ"`
 	jwt.StandardClaims
 }
 
 type JwtService struct {
-	cfg      *config.Config
-	issuer   string
-	userRepo repository.IUserRepository
+	cfg         *config.Config
+	issuer      string
+	userRepo    repository.IUserRepository
+	sessionRepo repository.ISessionRepository
 }
 
 var (
 	ErrInvalidExpiresIn = errors.New("invalid expires in duration")
+	ErrInvalidTokenType = errors.New("invalid token type")
+	ErrInvalidAudience  = errors.New("invalid token audience")
 )
 
-func NewJwtService(cfg *config.Config, userRepo repository.IUserRepository) *JwtService {
+func NewJwtService(cfg *config.Config, userRepo repository.IUserRepository, sessionRepo repository.ISessionRepository) *JwtService {
 	return &JwtService{
-		cfg:      cfg,
-		issuer:   "rexsimiloluwa@gmail.com",
-		userRepo: userRepo,
+		cfg:         cfg,
+		issuer:      "rexsimiloluwa@gmail.com",
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
 	}
 }
 
@@ -74,9 +96,35 @@ func parseExpiresInTime(expiresInStr string) (int64, error) {
 	return result, nil
 }
 
+// Returns the audience of a token type, only the access tokens are meant for the other services
+func (jwtSrv *JwtService) audience(tokenType string) string {
+	audience := jwtSrv.cfg.JwtAudience
+	if audience == "" {
+		audience = defaultAudience
+	}
+	if tokenType == TokenTypeAccess {
+		return audience
+	}
+	return audience + ":" + tokenType
+}
+
+// Returns the HS256 secret of a token type, used when no signing keys are configured
+func (jwtSrv *JwtService) secret(tokenType string) string {
+	switch tokenType {
+	case TokenTypeEmailVerification:
+		return jwtSrv.cfg.EmailVerificationTokenSecretKey
+	case TokenTypeResetPassword:
+		return jwtSrv.cfg.ResetPasswordTokenSecretKey
+	case TokenTypeLoginUnlock:
+		return jwtSrv.cfg.LoginUnlockTokenSecretKey
+	}
+	return jwtSrv.cfg.JwtSecretKey
+}
+
 // Generate a token
-// Accepts the payload, expiry duration, and JWT secret key for the token.
-func (jwtSrv *JwtService) GenerateToken(payload map[string]interface{}, expiresIn string, secret string) (string, error) {
+// Accepts the payload, type and expiry duration of the token.
+// The token is signed with the active signing key, or with the HS256 secret of its type when no keys are configured
+func (jwtSrv *JwtService) GenerateToken(payload map[string]interface{}, tokenType string, expiresIn string) (string, error) {
 	// parse the expires in string
 	expiresInUnix, err := parseExpiresInTime(expiresIn)
 	if err != nil {
@@ -86,18 +134,26 @@ func (jwtSrv *JwtService) GenerateToken(payload map[string]interface{}, expiresI
 	// initialize the JWT claims
 	jwtClaims := &JwtCustomClaims{
 		payload,
+		tokenType,
 		jwt.StandardClaims{
+			Audience:  jwtSrv.audience(tokenType),
 			ExpiresAt: expiresInUnix,
 			Issuer:    jwtSrv.issuer,
 			IssuedAt:  time.Now().Unix(),
 		},
 	}
 
-	// create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims)
-
-	// generated the encoded token using the secret key
-	encodedToken, err := token.SignedString([]byte(secret))
+	keySet, err := Keys(jwtSrv.cfg)
+	if err != nil {
+		logrus.Errorf("signing keys could not be loaded: %s", err.Error())
+		return "", err
+	}
+	var encodedToken string
+	if keySet != nil {
+		encodedToken, err = keySet.sign(jwtClaims)
+	} else {
+		encodedToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims).SignedString([]byte(jwtSrv.secret(tokenType)))
+	}
 	if err != nil {
 		logrus.Errorf("encoded token could not be generated: %s", err.Error())
 		return "", err
@@ -109,60 +165,88 @@ func (jwtSrv *JwtService) GenerateToken(payload map[string]interface{}, expiresI
 
 // Generate a new access token
 func (jwtSrv *JwtService) GenerateAccessToken(payload map[string]interface{}) (string, error) {
-	return jwtSrv.GenerateToken(
-		payload,
-		jwtSrv.cfg.AccessTokenJwtExpiresIn,
-		jwtSrv.cfg.JwtSecretKey,
-	)
+	return jwtSrv.GenerateToken(payload, TokenTypeAccess, jwtSrv.cfg.AccessTokenJwtExpiresIn)
 }
 
 // Generate a new refresh token
 func (jwtSrv *JwtService) GenerateRefreshToken(payload map[string]interface{}) (string, error) {
-	return jwtSrv.GenerateToken(
-		payload,
-		jwtSrv.cfg.RefreshTokenJwtExpiresIn,
-		jwtSrv.cfg.JwtSecretKey,
-	)
+	return jwtSrv.GenerateToken(payload, TokenTypeRefresh, jwtSrv.cfg.RefreshTokenJwtExpiresIn)
 }
 
 // Generate a new email verification token
 func (jwtSrv *JwtService) GenerateEmailVerificationToken(payload map[string]interface{}) (string, error) {
-	return jwtSrv.GenerateToken(
-		payload,
-		jwtSrv.cfg.EmailVerificationTokenExpiresIn,
-		jwtSrv.cfg.EmailVerificationTokenSecretKey,
-	)
+	return jwtSrv.GenerateToken(payload, TokenTypeEmailVerification, jwtSrv.cfg.EmailVerificationTokenExpiresIn)
 }
 
 // Generate a new reset password token
 func (jwtSrv *JwtService) GenerateResetPasswordToken(payload map[string]interface{}) (string, error) {
-	return jwtSrv.GenerateToken(
-		payload,
-		jwtSrv.cfg.ResetPasswordTokenExpiresIn,
-		jwtSrv.cfg.ResetPasswordTokenSecretKey,
-	)
+	return jwtSrv.GenerateToken(payload, TokenTypeResetPassword, jwtSrv.cfg.ResetPasswordTokenExpiresIn)
 }
 
-// Check if a token is valid
-// Accepts the string value of the token
-func (jwtSrv *JwtService) ValidateToken(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+// Generate a new login unlock token
+func (jwtSrv *JwtService) GenerateLoginUnlockToken(payload map[string]interface{}) (string, error) {
+	return jwtSrv.GenerateToken(payload, TokenTypeLoginUnlock, jwtSrv.cfg.LoginUnlockTokenExpiresIn)
+}
+
+// Generate a new two-factor token, exchanged for the access and refresh tokens along with a two-factor code
+func (jwtSrv *JwtService) GenerateTwoFactorToken(payload map[string]interface{}) (string, error) {
+	return jwtSrv.GenerateToken(payload, TokenTypeTwoFactor, jwtSrv.cfg.TwoFactorTokenExpiresIn)
+}
+
+// Returns the key function verifying the signature of a token of a type
+func (jwtSrv *JwtService) keyFunc(tokenType string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		keySet, err := Keys(jwtSrv.cfg)
+		if err != nil {
+			return nil, err
+		}
+		if keySet != nil {
+			key, err := keySet.verificationKey(token)
+			if errors.Is(err, ErrUnknownKeyID) {
+				if keySet, err = reloadKeys(jwtSrv.cfg); err != nil {
+					return nil, err
+				}
+				return keySet.verificationKey(token)
+			}
+			return key, err
+		}
 		// Signing method validation
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			// Signing method validation
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		// Return the secret signing key if otherwise
-		return []byte(jwtSrv.cfg.JwtSecretKey), nil
-	})
+		return []byte(jwtSrv.secret(tokenType)), nil
+	}
 }
 
-// Decode a token, returns the JWT claims
+// Check if an access token is valid
 // Accepts the string value of the token
-func (jwtSrv *JwtService) DecodeToken(tokenString string, secret string) (*JwtCustomClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+func (jwtSrv *JwtService) ValidateToken(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, jwtSrv.keyFunc(TokenTypeAccess))
+	if err != nil {
+		return nil, err
+	}
+	if err := jwtSrv.verifyClaims(token.Claims.(*JwtCustomClaims), TokenTypeAccess); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Checks the type and audience of the claims of a token
+func (jwtSrv *JwtService) verifyClaims(claims *JwtCustomClaims, tokenType string) error {
+	if claims.Type != tokenType {
+		return ErrInvalidTokenType
+	}
+	if !claims.VerifyAudience(jwtSrv.audience(tokenType), true) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// Decode a token of a type, returns the JWT claims
+// Accepts the string value and the expected type of the token
+func (jwtSrv *JwtService) DecodeToken(tokenString string, tokenType string) (*JwtCustomClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, jwtSrv.keyFunc(tokenType))
 
 	if err != nil {
 		logrus.Errorf("error parsing token: %s", err.Error())
@@ -173,23 +257,43 @@ func (jwtSrv *JwtService) DecodeToken(tokenString string, secret string) (*JwtCu
 	if !ok || !token.Valid {
 		return &JwtCustomClaims{}, fmt.Errorf("invalid or expired jwt")
 	}
+	if err := jwtSrv.verifyClaims(claims, tokenType); err != nil {
+		return &JwtCustomClaims{}, err
+	}
 	return claims, nil
 }
 
 // Authenticate a token, for use in the Auth chain.
-func (jwtSrv *JwtService) Authenticate(credential *auth.Credential) (*auth.AuthResponse, error) {
+func (jwtSrv *JwtService) Authenticate(ctx context.Context, credential *auth.Credential) (*auth.AuthResponse, error) {
 	if credential.Type != auth.CredentialTypeJWT && credential.Type != auth.CredentialTypeRefreshJWT {
 		return nil, errors.New("credential must be of jwt type")
 	}
 	tokenString := credential.JWT
+	// a refresh token only authenticates the refresh of the tokens
+	tokenType := TokenTypeAccess
+	if credential.Type == auth.CredentialTypeRefreshJWT {
+		tokenType = TokenTypeRefresh
+	}
 
-	claims, err := jwtSrv.DecodeToken(tokenString, jwtSrv.cfg.JwtSecretKey)
+	claims, err := jwtSrv.DecodeToken(tokenString, tokenType)
 	if err != nil {
 		return nil, err
 	}
 
+	// the tokens issued for a session are rejected once it is revoked
+	sessionID, _ := claims.Payload["sid"].(string)
+	if sessionID != "" && jwtSrv.sessionRepo != nil {
+		session, err := jwtSrv.sessionRepo.FindSessionByID(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !session.IsActive(time.Now()) {
+			return nil, models.ErrSessionRevoked
+		}
+	}
+
 	userID := claims.Payload["id"]
-	user, err := jwtSrv.userRepo.FindUserById(userID.(string))
+	user, err := jwtSrv.userRepo.FindUserById(ctx, userID.(string))
 
 	if err != nil {
 		return nil, err
@@ -199,5 +303,6 @@ func (jwtSrv *JwtService) Authenticate(credential *auth.Credential) (*auth.AuthR
 		AuthMode:   auth.CredentialTypeJWT,
 		Credential: *credential,
 		User:       user,
+		SessionID:  sessionID,
 	}, nil
 }
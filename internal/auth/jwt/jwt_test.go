@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"context"
 	"errors"
 	"keeper/internal/auth"
 	"keeper/internal/config"
@@ -54,12 +55,12 @@ func TestJwtService_GenerateToken(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
-	jwtSvc := NewJwtService(&config.Config{}, mockUserRepo)
+	jwtSvc := NewJwtService(&config.Config{}, mockUserRepo, nil)
 
 	type args struct {
 		payload   map[string]interface{}
+		tokenType string
 		expiresIn string
-		secret    string
 	}
 
 	tt := []struct {
@@ -75,7 +76,7 @@ func TestJwtService_GenerateToken(t *testing.T) {
 					"email": "test@gmail.com",
 					"id":    1,
 				},
-				secret:    "secret",
+				tokenType: TokenTypeAccess,
 				expiresIn: "30m",
 			},
 			wantErr: false,
@@ -87,7 +88,7 @@ func TestJwtService_GenerateToken(t *testing.T) {
 					"email": "test@gmail.com",
 					"id":    1,
 				},
-				secret:    "secret",
+				tokenType: TokenTypeAccess,
 				expiresIn: "30x",
 			},
 			wantErr:    true,
@@ -97,7 +98,7 @@ func TestJwtService_GenerateToken(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			token, err := jwtSvc.GenerateToken(tc.args.payload, tc.args.expiresIn, tc.args.secret)
+			token, err := jwtSvc.GenerateToken(tc.args.payload, tc.args.tokenType, tc.args.expiresIn)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, tc.wantErrMsg, err.Error())
@@ -119,7 +120,7 @@ func TestJwtService_GenerateAccessToken(t *testing.T) {
 		AccessTokenJwtExpiresIn: "30m",
 	}
 	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
-	jwtSvc := NewJwtService(cfg, mockUserRepo)
+	jwtSvc := NewJwtService(cfg, mockUserRepo, nil)
 
 	type jwtArgs struct {
 		payload map[string]interface{}
@@ -182,7 +183,7 @@ func TestJwtService_GenerateRefreshToken(t *testing.T) {
 		RefreshTokenJwtExpiresIn:        "7d",
 	}
 	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
-	jwtSvc := NewJwtService(cfg, mockUserRepo)
+	jwtSvc := NewJwtService(cfg, mockUserRepo, nil)
 
 	type jwtArgs struct {
 		payload map[string]interface{}
@@ -245,7 +246,7 @@ func TestJwtService_GenerateEmailVerificationToken(t *testing.T) {
 		EmailVerificationTokenExpiresIn: "7d",
 	}
 	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
-	jwtSvc := NewJwtService(cfg, mockUserRepo)
+	jwtSvc := NewJwtService(cfg, mockUserRepo, nil)
 
 	type jwtArgs struct {
 		payload map[string]interface{}
@@ -308,7 +309,7 @@ func TestJwtService_GenerateResetPasswordToken(t *testing.T) {
 		ResetPasswordTokenExpiresIn: "7d",
 	}
 	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
-	jwtSvc := NewJwtService(cfg, mockUserRepo)
+	jwtSvc := NewJwtService(cfg, mockUserRepo, nil)
 
 	type jwtArgs struct {
 		payload map[string]interface{}
@@ -371,7 +372,7 @@ func TestJwtService_ValidateToken(t *testing.T) {
 		AccessTokenJwtExpiresIn: "30m",
 	}
 	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
-	jwtSvc := NewJwtService(cfg, mockUserRepo)
+	jwtSvc := NewJwtService(cfg, mockUserRepo, nil)
 
 	type args struct {
 		tokenString string
@@ -434,7 +435,7 @@ func TestJwtService_DecodeToken(t *testing.T) {
 		AccessTokenJwtExpiresIn: "15m",
 	}
 	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
-	jwtSvc := NewJwtService(cfg, mockUserRepo)
+	jwtSvc := NewJwtService(cfg, mockUserRepo, nil)
 
 	// test token string
 	payload := map[string]interface{}{
@@ -476,7 +477,7 @@ func TestJwtService_DecodeToken(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			claims, err := jwtSvc.DecodeToken(tc.args.tokenString, cfg.JwtSecretKey)
+			claims, err := jwtSvc.DecodeToken(tc.args.tokenString, TokenTypeAccess)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -500,7 +501,7 @@ func TestJwtService_Authenticate(t *testing.T) {
 		AccessTokenJwtExpiresIn: "15m",
 	}
 	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
-	jwtSvc := NewJwtService(cfg, mockUserRepo)
+	jwtSvc := NewJwtService(cfg, mockUserRepo, nil)
 
 	testUser := &models.User{
 		ID:    primitive.NewObjectID(),
@@ -528,7 +529,7 @@ func TestJwtService_Authenticate(t *testing.T) {
 				},
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().FindUserById(gomock.Any()).
+				userRepo.EXPECT().FindUserById(gomock.Any(), gomock.Any()).
 					Times(1).Return(testUser, nil)
 			},
 		},
@@ -541,7 +542,7 @@ func TestJwtService_Authenticate(t *testing.T) {
 				},
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().FindUserById(gomock.Any()).
+				userRepo.EXPECT().FindUserById(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("user does not exist"))
 			},
 			wantErr:    true,
@@ -563,7 +564,7 @@ func TestJwtService_Authenticate(t *testing.T) {
 		if tc.stubFn != nil {
 			tc.stubFn(mockUserRepo)
 		}
-		authResponse, err := jwtSvc.Authenticate(tc.args.credential)
+		authResponse, err := jwtSvc.Authenticate(context.Background(), tc.args.credential)
 		if tc.wantErr {
 			require.NotNil(t, err)
 			require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -573,3 +574,71 @@ func TestJwtService_Authenticate(t *testing.T) {
 		require.Equal(t, authResponse.AuthMode, auth.CredentialTypeJWT)
 	}
 }
+
+func TestJwtService_Authenticate_Session(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := &config.Config{
+		JwtSecretKey:            "secret",
+		AccessTokenJwtExpiresIn: "15m",
+	}
+	mockUserRepo := mocks.NewMockIUserRepository(ctrl)
+	mockSessionRepo := mocks.NewMockISessionRepository(ctrl)
+	jwtSvc := NewJwtService(cfg, mockUserRepo, mockSessionRepo)
+	testUser := &models.User{ID: primitive.NewObjectID(), Email: "test-user@gmail.com"}
+	session := &models.Session{ID: primitive.NewObjectID(), UserID: testUser.ID}
+	accessToken, _ := jwtSvc.GenerateAccessToken(map[string]interface{}{"id": testUser.ID.Hex(), "email": testUser.Email, "sid": session.ID.Hex()})
+	credential := &auth.Credential{Type: auth.CredentialTypeJWT, JWT: accessToken}
+
+	// active session
+	session.ExpiresAt = primitive.NewDateTimeFromTime(time.Now().Add(time.Hour))
+	mockSessionRepo.EXPECT().FindSessionByID(gomock.Any(), session.ID.Hex()).Times(1).Return(session, nil)
+	mockUserRepo.EXPECT().FindUserById(gomock.Any(), testUser.ID.Hex()).Times(1).Return(testUser, nil)
+	resp, err := jwtSvc.Authenticate(context.Background(), credential)
+	require.Nil(t, err)
+	require.Equal(t, session.ID.Hex(), resp.SessionID)
+
+	// revoked session
+	session.RevokedAt = primitive.NewDateTimeFromTime(time.Now())
+	mockSessionRepo.EXPECT().FindSessionByID(gomock.Any(), session.ID.Hex()).Times(1).Return(session, nil)
+	_, err = jwtSvc.Authenticate(context.Background(), credential)
+	require.ErrorIs(t, err, models.ErrSessionRevoked)
+}
+
+func TestJwtService_DecodeToken_Type(t *testing.T) {
+	cfg := &config.Config{
+		JwtSecretKey:                    "secret",
+		EmailVerificationTokenSecretKey: "secret",
+		AccessTokenJwtExpiresIn:         "15m",
+		RefreshTokenJwtExpiresIn:        "7d",
+		EmailVerificationTokenExpiresIn: "24h",
+		JwtAudience:                     "kipa",
+	}
+	jwtSvc := NewJwtService(cfg, nil, nil)
+	payload := map[string]interface{}{"id": "1"}
+	accessToken, _ := jwtSvc.GenerateAccessToken(payload)
+	refreshToken, _ := jwtSvc.GenerateRefreshToken(payload)
+	// signed with the same secret as the access tokens, only its type tells them apart
+	verificationToken, _ := jwtSvc.GenerateEmailVerificationToken(payload)
+
+	claims, err := jwtSvc.DecodeToken(accessToken, TokenTypeAccess)
+	require.Nil(t, err)
+	require.Equal(t, TokenTypeAccess, claims.Type)
+	require.Equal(t, "kipa", claims.Audience)
+	claims, err = jwtSvc.DecodeToken(refreshToken, TokenTypeRefresh)
+	require.Nil(t, err)
+	require.Equal(t, "kipa:refresh", claims.Audience)
+
+	_, err = jwtSvc.DecodeToken(refreshToken, TokenTypeAccess)
+	require.ErrorIs(t, err, ErrInvalidTokenType)
+	_, err = jwtSvc.DecodeToken(verificationToken, TokenTypeAccess)
+	require.ErrorIs(t, err, ErrInvalidTokenType)
+	_, err = jwtSvc.DecodeToken(accessToken, TokenTypeRefresh)
+	require.ErrorIs(t, err, ErrInvalidTokenType)
+
+	// the tokens of another audience are rejected
+	cfg.JwtAudience = "other"
+	_, err = jwtSvc.DecodeToken(accessToken, TokenTypeAccess)
+	require.ErrorIs(t, err, ErrInvalidAudience)
+}
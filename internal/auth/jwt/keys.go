@@ -0,0 +1,238 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"keeper/internal/config"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// the RSA keys shorter than this are rejected
+	minRSAKeyBits = 2048
+	// the keys are read again after this long, so that a rotation of the directory is picked up without a restart
+	keySetMaxAge = 5 * time.Minute
+	// a token signed with an unknown key reloads the keys at most once per interval
+	keySetReloadInterval = 30 * time.Second
+)
+
+var (
+	ErrUnknownKeyID   = errors.New("unknown signing key id")
+	ErrNoActiveKey    = errors.New("the active signing key is missing or has no private key")
+	ErrUnsupportedKey = errors.New("unsupported signing key, expected an RSA or Ed25519 key")
+)
+
+// Signing keys of the tokens, loaded from the PEM files of a directory
+// the name of each file without its .pem extension is the key ID (kid)
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+type signingKey struct {
+	id     string
+	method jwt.SigningMethod
+	// nil for the retired keys, kept only to verify the tokens they signed
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// JSON Web Key Set, as published at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// cache of the loaded key sets, by directory and active key
+var keySets sync.Map
+
+// a loaded key set and the time it was read
+type cachedKeySet struct {
+	mu       sync.Mutex
+	keySet   *KeySet
+	loadedAt time.Time
+}
+
+// Returns the key set configured with JWT_KEYS_DIR and JWT_ACTIVE_KEY_ID,
+// or nil when no directory is set and the tokens are signed with the HS256 secrets
+func Keys(cfg *config.Config) (*KeySet, error) {
+	if cfg.JwtKeysDir == "" {
+		return nil, nil
+	}
+	return cachedKeys(cfg).get(cfg, keySetMaxAge)
+}
+
+// Reads the keys again for a token signed with a key the loaded set does not know,
+// e.g. one added by a rotation since, unless they were read within keySetReloadInterval
+func reloadKeys(cfg *config.Config) (*KeySet, error) {
+	return cachedKeys(cfg).get(cfg, keySetReloadInterval)
+}
+
+func cachedKeys(cfg *config.Config) *cachedKeySet {
+	cached, _ := keySets.LoadOrStore(cfg.JwtKeysDir+"|"+cfg.JwtActiveKeyID, &cachedKeySet{})
+	return cached.(*cachedKeySet)
+}
+
+// Returns the key set, read again when it is older than maxAge
+func (c *cachedKeySet) get(cfg *config.Config, maxAge time.Duration) (*KeySet, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keySet != nil && time.Since(c.loadedAt) < maxAge {
+		return c.keySet, nil
+	}
+	keySet, err := LoadKeySet(cfg.JwtKeysDir, cfg.JwtActiveKeyID)
+	if err != nil {
+		if c.keySet == nil {
+			return nil, err
+		}
+		// a directory in the middle of a rotation may not load, the previous keys are kept until the next reload
+		logrus.Warnf("signing keys could not be reloaded, keeping the previous ones: %s", err.Error())
+		c.loadedAt = time.Now()
+		return c.keySet, nil
+	}
+	c.keySet, c.loadedAt = keySet, time.Now()
+	return keySet, nil
+}
+
+// Loads the *.pem keys of a directory, the active key signs the new tokens and must hold a private key
+func LoadKeySet(dir string, activeKeyID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	keySet := &KeySet{keys: map[string]*signingKey{}}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading signing key %s: %w", path, err)
+		}
+		key, err := parseSigningKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing signing key %s: %w", path, err)
+		}
+		key.id = strings.TrimSuffix(filepath.Base(path), ".pem")
+		keySet.keys[key.id] = key
+	}
+	active, ok := keySet.keys[activeKeyID]
+	if !ok || active.private == nil {
+		return nil, fmt.Errorf("%w: %q", ErrNoActiveKey, activeKeyID)
+	}
+	keySet.active = active
+	return keySet, nil
+}
+
+// Parses a PEM encoded RSA or Ed25519 key, a public key is only used to verify the tokens
+func parseSigningKey(data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key of %d bits, at least %d are required", key.N.BitLen(), minRSAKeyBits)
+		}
+		return &signingKey{method: jwt.SigningMethodRS256, private: key, public: &key.PublicKey}, nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key of %d bits, at least %d are required", key.N.BitLen(), minRSAKeyBits)
+		}
+		return &signingKey{method: jwt.SigningMethodRS256, public: key}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{method: jwt.SigningMethodEdDSA, private: key, public: key.Public()}, nil
+	case ed25519.PublicKey:
+		return &signingKey{method: jwt.SigningMethodEdDSA, public: key}, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// Signs a token with the active key, its ID is set in the kid header
+func (k *KeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.id
+	return token.SignedString(k.active.private)
+}
+
+// Returns the public key a token was signed with, found by its kid header
+func (k *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+	}
+	// the algorithm of the header must be the one of the key, e.g. an HS256 token is never checked with a public key
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// Returns the public keys, the services verifying the tokens offline fetch them by kid
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		key := k.keys[id]
+		jwk := JWK{Kid: id, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
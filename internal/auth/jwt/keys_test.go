@@ -0,0 +1,191 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"keeper/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// Writes a PEM encoded key in the directory, named by its key ID
+func writeKey(t *testing.T, dir string, kid string, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.Nil(t, os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0600))
+}
+
+func TestJwtService_SigningKeys(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	writeKey(t, dir, "2023-01", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.Nil(t, err)
+	writeKey(t, dir, "2023-02", "PRIVATE KEY", edDER)
+
+	cfg := &config.Config{
+		JwtKeysDir:              dir,
+		JwtActiveKeyID:          "2023-01",
+		JwtSecretKey:            "secret",
+		AccessTokenJwtExpiresIn: "15m",
+	}
+	jwtSvc := NewJwtService(cfg, nil, nil)
+	payload := map[string]interface{}{"id": "1"}
+
+	rsaToken, err := jwtSvc.GenerateAccessToken(payload)
+	require.Nil(t, err)
+	token, err := jwtSvc.ValidateToken(rsaToken)
+	require.Nil(t, err)
+	require.Equal(t, "RS256", token.Method.Alg())
+	require.Equal(t, "2023-01", token.Header["kid"])
+
+	// the tokens signed with the HS256 secret are rejected once the keys are configured
+	hsToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{
+		Payload:        payload,
+		Type:           TokenTypeAccess,
+		StandardClaims: jwt.StandardClaims{Audience: defaultAudience},
+	}).SignedString([]byte("secret"))
+	require.Nil(t, err)
+	_, err = jwtSvc.DecodeToken(hsToken, TokenTypeAccess)
+	require.NotNil(t, err)
+
+	// rotation, the previous key now only verifies the tokens it signed
+	require.Nil(t, os.Remove(filepath.Join(dir, "2023-01.pem")))
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.Nil(t, err)
+	writeKey(t, dir, "2023-01", "PUBLIC KEY", publicDER)
+	rotatedSvc := NewJwtService(&config.Config{
+		JwtKeysDir:              dir,
+		JwtActiveKeyID:          "2023-02",
+		AccessTokenJwtExpiresIn: "15m",
+	}, nil, nil)
+
+	edToken, err := rotatedSvc.GenerateAccessToken(payload)
+	require.Nil(t, err)
+	token, err = rotatedSvc.ValidateToken(edToken)
+	require.Nil(t, err)
+	require.Equal(t, "EdDSA", token.Method.Alg())
+	require.Equal(t, "2023-02", token.Header["kid"])
+	claims, err := rotatedSvc.DecodeToken(rsaToken, TokenTypeAccess)
+	require.Nil(t, err)
+	require.Equal(t, "1", claims.Payload["id"])
+
+	keySet, err := Keys(rotatedSvc.cfg)
+	require.Nil(t, err)
+	jwks := keySet.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	require.Equal(t, JWK{Kty: "RSA", Kid: "2023-01", Use: "sig", Alg: "RS256", N: jwks.Keys[0].N, E: "AQAB"}, jwks.Keys[0])
+	require.Equal(t, "OKP", jwks.Keys[1].Kty)
+	require.Equal(t, "Ed25519", jwks.Keys[1].Crv)
+	require.NotEmpty(t, jwks.Keys[1].X)
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(edKey.Public())
+	require.Nil(t, err)
+	writeKey(t, dir, "retired", "PUBLIC KEY", publicDER)
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.Nil(t, err)
+
+	// the active key must exist and hold a private key
+	_, err = LoadKeySet(dir, "missing")
+	require.ErrorIs(t, err, ErrNoActiveKey)
+	_, err = LoadKeySet(dir, "retired")
+	require.ErrorIs(t, err, ErrNoActiveKey)
+
+	writeKey(t, dir, "small", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(smallKey))
+	_, err = LoadKeySet(dir, "small")
+	require.ErrorContains(t, err, "at least 2048")
+}
+
+// Writes a new Ed25519 private key in the directory, named by its key ID
+func writeEd25519Key(t *testing.T, dir string, kid string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.Nil(t, err)
+	writeKey(t, dir, kid, "PRIVATE KEY", der)
+}
+
+// Signs an access token with a key of the directory, as another instance of the server would
+func signWith(t *testing.T, dir string, kid string) string {
+	keySet, err := LoadKeySet(dir, kid)
+	require.Nil(t, err)
+	token, err := keySet.sign(&JwtCustomClaims{
+		Payload:        map[string]interface{}{"id": "1"},
+		Type:           TokenTypeAccess,
+		StandardClaims: jwt.StandardClaims{Audience: defaultAudience, ExpiresAt: time.Now().Add(time.Minute).Unix()},
+	})
+	require.Nil(t, err)
+	return token
+}
+
+func TestKeys_ReloadUnknownKeyID(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "2023-01")
+	cfg := &config.Config{JwtKeysDir: dir, JwtActiveKeyID: "2023-01", AccessTokenJwtExpiresIn: "15m"}
+	jwtSvc := NewJwtService(cfg, nil, nil)
+	_, err := Keys(cfg)
+	require.Nil(t, err)
+
+	// a key added since the keys were read is rejected until the interval has passed
+	writeEd25519Key(t, dir, "2023-02")
+	token := signWith(t, dir, "2023-02")
+	_, err = jwtSvc.ValidateToken(token)
+	require.ErrorIs(t, err, ErrUnknownKeyID)
+
+	// then the first token it signed reads the keys again
+	cached := cachedKeys(cfg)
+	cached.loadedAt = cached.loadedAt.Add(-keySetReloadInterval)
+	_, err = jwtSvc.ValidateToken(token)
+	require.Nil(t, err)
+
+	// a kid that is still unknown after the reload is rejected
+	otherDir := t.TempDir()
+	writeEd25519Key(t, otherDir, "2023-03")
+	cached.loadedAt = cached.loadedAt.Add(-keySetReloadInterval)
+	_, err = jwtSvc.ValidateToken(signWith(t, otherDir, "2023-03"))
+	require.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestKeys_ReloadAfterMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "active")
+	writeEd25519Key(t, dir, "retired")
+	cfg := &config.Config{JwtKeysDir: dir, JwtActiveKeyID: "active"}
+	keySet, err := Keys(cfg)
+	require.Nil(t, err)
+	require.Len(t, keySet.JWKS().Keys, 2)
+
+	// the removed key is published until the keys are read again
+	require.Nil(t, os.Remove(filepath.Join(dir, "retired.pem")))
+	keySet, err = Keys(cfg)
+	require.Nil(t, err)
+	require.Len(t, keySet.JWKS().Keys, 2)
+
+	cached := cachedKeys(cfg)
+	cached.loadedAt = cached.loadedAt.Add(-keySetMaxAge)
+	keySet, err = Keys(cfg)
+	require.Nil(t, err)
+	require.Len(t, keySet.JWKS().Keys, 1)
+
+	// the previous keys are kept when the directory cannot be loaded
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "broken.pem"), []byte("not a key"), 0600))
+	cached.loadedAt = cached.loadedAt.Add(-keySetMaxAge)
+	reloaded, err := Keys(cfg)
+	require.Nil(t, err)
+	require.Same(t, keySet, reloaded)
+}
@@ -21,6 +21,8 @@ import (
 
 type Config struct {
 	Port                            string
+	GRPCPort                        string
+	MetricsPort                     string
 	Env                             string
 	JwtSecretKey                    string
 	EmailVerificationTokenSecretKey string
@@ -44,6 +46,47 @@ type Config struct {
 	WithWorkers                     bool
 	EmailSenderName                 string
 	EmailSenderAddr                 string
+	BinaryItemInlineThreshold       int
+	BinaryItemMaxSize               int
+	ItemCompressionThreshold        int
+	ItemCompressionAlgorithm        string
+	TrashRetentionDays              int
+	TrashPurgeSchedule              string
+	BucketChangeRetentionDays       int
+	UserDeletionGracePeriodHours    int
+	WebhookMaxRetries               int
+	WebhookTimeoutSeconds           int
+	WebhookAllowPrivateNetworks     bool
+	ItemExpirySweepSchedule         string
+	RunMigrations                   bool
+	RequestTimeoutSeconds           int
+	RouteTimeouts                   []string
+	TracingExporter                 string
+	TracingServiceName              string
+	TracingSampleRatio              float64
+	LogLevel                        string
+	RateLimitEnabled                bool
+	RateLimitBackend                string
+	RateLimitDefault                string
+	RateLimitGroups                 []string
+	RateLimitIP                     string
+	TrustedProxies                  []string
+	QuotaMaxBuckets                 int
+	QuotaMaxItemsPerBucket          int
+	QuotaMaxValueBytes              int
+	QuotaMaxStorageBytes            int
+	UsageRecomputeSchedule          string
+	LoginMaxFailures                int
+	LoginMaxFailuresPerIP           int
+	LoginFailureWindowMinutes       int
+	LoginLockoutBaseSeconds         int
+	LoginLockoutMaxSeconds          int
+	LoginUnlockTokenSecretKey       string
+	LoginUnlockTokenExpiresIn       string
+	JwtKeysDir                      string
+	JwtActiveKeyID                  string
+	JwtAudience                     string
+	TwoFactorTokenExpiresIn         string
 }
 
 // New() creates a new Config struct with the loaded environment variables
@@ -53,6 +96,8 @@ func New() *Config {
 	}
 	return &Config{
 		Port:                            getEnv("PORT", "1323"),
+		GRPCPort:                        getEnv("GRPC_PORT", "5051"),
+		MetricsPort:                     getEnv("METRICS_PORT", "5052"),
 		Env:                             getEnv("ENV", "development"),
 		JwtSecretKey:                    getEnv("JWT_SECRET_KEY", ""),
 		EmailVerificationTokenSecretKey: getEnv("EMAIL_VERIFICATION_TOKEN_SECRET_KEY", ""),
@@ -76,6 +121,47 @@ func New() *Config {
 		WithWorkers:                     getEnvAsBool("WITH_WORKERS", true),
 		EmailSenderName:                 getEnv("EMAIL_SENDER_NAME", "kipa"),
 		EmailSenderAddr:                 getEnv("EMAIL_SENDER_ADDR", "rexsimiloluwa@gmail.com"),
+		BinaryItemInlineThreshold:       getEnvAsInt("BINARY_ITEM_INLINE_THRESHOLD", 1<<20),
+		BinaryItemMaxSize:               getEnvAsInt("BINARY_ITEM_MAX_SIZE", 64<<20),
+		ItemCompressionThreshold:        getEnvAsInt("ITEM_COMPRESSION_THRESHOLD", 4<<10),
+		ItemCompressionAlgorithm:        getEnv("ITEM_COMPRESSION_ALGORITHM", "zstd"),
+		TrashRetentionDays:              getEnvAsInt("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeSchedule:              getEnv("TRASH_PURGE_SCHEDULE", "@hourly"),
+		BucketChangeRetentionDays:       getEnvAsInt("BUCKET_CHANGE_RETENTION_DAYS", 30),
+		UserDeletionGracePeriodHours:    getEnvAsInt("USER_DELETION_GRACE_PERIOD_HOURS", 0),
+		WebhookMaxRetries:               getEnvAsInt("WEBHOOK_MAX_RETRIES", 8),
+		WebhookTimeoutSeconds:           getEnvAsInt("WEBHOOK_TIMEOUT_SECONDS", 10),
+		WebhookAllowPrivateNetworks:     getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+		ItemExpirySweepSchedule:         getEnv("ITEM_EXPIRY_SWEEP_SCHEDULE", "@every 1m"),
+		RunMigrations:                   getEnvAsBool("RUN_MIGRATIONS", true),
+		RequestTimeoutSeconds:           getEnvAsInt("REQUEST_TIMEOUT_SECONDS", 30),
+		RouteTimeouts:                   getEnvAsSlice("ROUTE_TIMEOUTS", []string{}, ","),
+		TracingExporter:                 getEnv("TRACING_EXPORTER", "none"),
+		TracingServiceName:              getEnv("TRACING_SERVICE_NAME", "kipa"),
+		TracingSampleRatio:              getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+		LogLevel:                        getEnv("LOG_LEVEL", "info"),
+		RateLimitEnabled:                getEnvAsBool("RATE_LIMIT_ENABLED", true),
+		RateLimitBackend:                getEnv("RATE_LIMIT_BACKEND", "redis"),
+		RateLimitDefault:                getEnv("RATE_LIMIT_DEFAULT", "600/1m"),
+		RateLimitGroups:                 getEnvAsSlice("RATE_LIMIT_GROUPS", []string{}, ","),
+		RateLimitIP:                     getEnv("RATE_LIMIT_IP", "1200/1m"),
+		TrustedProxies:                  getEnvAsSlice("TRUSTED_PROXIES", []string{}, ","),
+		QuotaMaxBuckets:                 getEnvAsInt("QUOTA_MAX_BUCKETS", 0),
+		QuotaMaxItemsPerBucket:          getEnvAsInt("QUOTA_MAX_ITEMS_PER_BUCKET", 0),
+		QuotaMaxValueBytes:              getEnvAsInt("QUOTA_MAX_VALUE_BYTES", 0),
+		QuotaMaxStorageBytes:            getEnvAsInt("QUOTA_MAX_STORAGE_BYTES", 0),
+		UsageRecomputeSchedule:          getEnv("USAGE_RECOMPUTE_SCHEDULE", "@daily"),
+		LoginMaxFailures:                getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+		LoginMaxFailuresPerIP:           getEnvAsInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+		LoginFailureWindowMinutes:       getEnvAsInt("LOGIN_FAILURE_WINDOW_MINUTES", 15),
+		LoginLockoutBaseSeconds:         getEnvAsInt("LOGIN_LOCKOUT_BASE_SECONDS", 60),
+		LoginLockoutMaxSeconds:          getEnvAsInt("LOGIN_LOCKOUT_MAX_SECONDS", 3600),
+		LoginUnlockTokenSecretKey:       getEnv("LOGIN_UNLOCK_TOKEN_SECRET_KEY", ""),
+		LoginUnlockTokenExpiresIn:       getEnv("LOGIN_UNLOCK_TOKEN_EXPIRES_IN", "1h"),
+		JwtKeysDir:                      getEnv("JWT_KEYS_DIR", ""),
+		JwtActiveKeyID:                  getEnv("JWT_ACTIVE_KEY_ID", ""),
+		JwtAudience:                     getEnv("JWT_AUDIENCE", "kipa"),
+		TwoFactorTokenExpiresIn:         getEnv("TWO_FACTOR_TOKEN_EXPIRES_IN", "5m"),
 	}
 }
 
@@ -86,6 +172,8 @@ func NewTest() *Config {
 	// }
 	return &Config{
 		Port:                            getEnv("PORT", "1323"),
+		GRPCPort:                        getEnv("GRPC_PORT", "5051"),
+		MetricsPort:                     getEnv("METRICS_PORT", "5052"),
 		Env:                             getEnv("ENV", "test"),
 		JwtSecretKey:                    getEnv("JWT_SECRET_KEY", ""),
 		EmailVerificationTokenSecretKey: getEnv("EMAIL_VERIFICATION_TOKEN_SECRET_KEY", ""),
@@ -104,6 +192,47 @@ func NewTest() *Config {
 		RedisHost:                       getEnv("REDIS_HOST", ""),
 		RedisPort:                       getEnv("REDIS_PORT", ""),
 		RedisProdUri:                    getEnv("REDIS_PROD_URI", ""),
+		BinaryItemInlineThreshold:       getEnvAsInt("BINARY_ITEM_INLINE_THRESHOLD", 1<<20),
+		BinaryItemMaxSize:               getEnvAsInt("BINARY_ITEM_MAX_SIZE", 64<<20),
+		ItemCompressionThreshold:        getEnvAsInt("ITEM_COMPRESSION_THRESHOLD", 4<<10),
+		ItemCompressionAlgorithm:        getEnv("ITEM_COMPRESSION_ALGORITHM", "zstd"),
+		TrashRetentionDays:              getEnvAsInt("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeSchedule:              getEnv("TRASH_PURGE_SCHEDULE", "@hourly"),
+		BucketChangeRetentionDays:       getEnvAsInt("BUCKET_CHANGE_RETENTION_DAYS", 30),
+		UserDeletionGracePeriodHours:    getEnvAsInt("USER_DELETION_GRACE_PERIOD_HOURS", 0),
+		WebhookMaxRetries:               getEnvAsInt("WEBHOOK_MAX_RETRIES", 8),
+		WebhookTimeoutSeconds:           getEnvAsInt("WEBHOOK_TIMEOUT_SECONDS", 10),
+		WebhookAllowPrivateNetworks:     getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+		ItemExpirySweepSchedule:         getEnv("ITEM_EXPIRY_SWEEP_SCHEDULE", "@every 1m"),
+		RunMigrations:                   getEnvAsBool("RUN_MIGRATIONS", true),
+		RequestTimeoutSeconds:           getEnvAsInt("REQUEST_TIMEOUT_SECONDS", 30),
+		RouteTimeouts:                   getEnvAsSlice("ROUTE_TIMEOUTS", []string{}, ","),
+		TracingExporter:                 getEnv("TRACING_EXPORTER", "none"),
+		TracingServiceName:              getEnv("TRACING_SERVICE_NAME", "kipa"),
+		TracingSampleRatio:              getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+		LogLevel:                        getEnv("LOG_LEVEL", "info"),
+		RateLimitEnabled:                getEnvAsBool("RATE_LIMIT_ENABLED", true),
+		RateLimitBackend:                getEnv("RATE_LIMIT_BACKEND", "redis"),
+		RateLimitDefault:                getEnv("RATE_LIMIT_DEFAULT", "600/1m"),
+		RateLimitGroups:                 getEnvAsSlice("RATE_LIMIT_GROUPS", []string{}, ","),
+		RateLimitIP:                     getEnv("RATE_LIMIT_IP", "1200/1m"),
+		TrustedProxies:                  getEnvAsSlice("TRUSTED_PROXIES", []string{}, ","),
+		QuotaMaxBuckets:                 getEnvAsInt("QUOTA_MAX_BUCKETS", 0),
+		QuotaMaxItemsPerBucket:          getEnvAsInt("QUOTA_MAX_ITEMS_PER_BUCKET", 0),
+		QuotaMaxValueBytes:              getEnvAsInt("QUOTA_MAX_VALUE_BYTES", 0),
+		QuotaMaxStorageBytes:            getEnvAsInt("QUOTA_MAX_STORAGE_BYTES", 0),
+		UsageRecomputeSchedule:          getEnv("USAGE_RECOMPUTE_SCHEDULE", "@daily"),
+		LoginMaxFailures:                getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+		LoginMaxFailuresPerIP:           getEnvAsInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+		LoginFailureWindowMinutes:       getEnvAsInt("LOGIN_FAILURE_WINDOW_MINUTES", 15),
+		LoginLockoutBaseSeconds:         getEnvAsInt("LOGIN_LOCKOUT_BASE_SECONDS", 60),
+		LoginLockoutMaxSeconds:          getEnvAsInt("LOGIN_LOCKOUT_MAX_SECONDS", 3600),
+		LoginUnlockTokenSecretKey:       getEnv("LOGIN_UNLOCK_TOKEN_SECRET_KEY", ""),
+		LoginUnlockTokenExpiresIn:       getEnv("LOGIN_UNLOCK_TOKEN_EXPIRES_IN", "1h"),
+		JwtKeysDir:                      getEnv("JWT_KEYS_DIR", ""),
+		JwtActiveKeyID:                  getEnv("JWT_ACTIVE_KEY_ID", ""),
+		JwtAudience:                     getEnv("JWT_AUDIENCE", "kipa"),
+		TwoFactorTokenExpiresIn:         getEnv("TWO_FACTOR_TOKEN_EXPIRES_IN", "5m"),
 	}
 }
 
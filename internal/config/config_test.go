@@ -17,11 +17,46 @@ func TestConfig_New(t *testing.T) {
 		{
 			name: "should_return_default_config",
 			want: &Config{
-				Port:                     "1323",
-				Env:                      "development",
-				AccessTokenJwtExpiresIn:  "15m",
-				RefreshTokenJwtExpiresIn: "7d",
-				DbName:                   "keeper",
+				Port:                         "1323",
+				GRPCPort:                     "5051",
+				MetricsPort:                  "5052",
+				Env:                          "development",
+				AccessTokenJwtExpiresIn:      "15m",
+				RefreshTokenJwtExpiresIn:     "7d",
+				DbName:                       "keeper",
+				BinaryItemInlineThreshold:    1 << 20,
+				BinaryItemMaxSize:            64 << 20,
+				ItemCompressionThreshold:     4 << 10,
+				ItemCompressionAlgorithm:     "zstd",
+				TrashRetentionDays:           30,
+				TrashPurgeSchedule:           "@hourly",
+				BucketChangeRetentionDays:    30,
+				UserDeletionGracePeriodHours: 0,
+				WebhookMaxRetries:            8,
+				WebhookTimeoutSeconds:        10,
+				ItemExpirySweepSchedule:      "@every 1m",
+				RunMigrations:                true,
+				RequestTimeoutSeconds:        30,
+				RouteTimeouts:                []string{},
+				TracingExporter:              "none",
+				TracingServiceName:           "kipa",
+				TracingSampleRatio:           1,
+				LogLevel:                     "info",
+				RateLimitEnabled:             true,
+				RateLimitBackend:             "redis",
+				RateLimitDefault:             "600/1m",
+				RateLimitGroups:              []string{},
+				RateLimitIP:                  "1200/1m",
+				TrustedProxies:               []string{},
+				UsageRecomputeSchedule:       "@daily",
+				LoginMaxFailures:             5,
+				LoginMaxFailuresPerIP:        20,
+				LoginFailureWindowMinutes:    15,
+				LoginLockoutBaseSeconds:      60,
+				LoginLockoutMaxSeconds:       3600,
+				LoginUnlockTokenExpiresIn:    "1h",
+				JwtAudience:                  "kipa",
+				TwoFactorTokenExpiresIn:      "5m",
 			},
 		},
 	}
@@ -47,11 +82,46 @@ func TestConfig_NewTest(t *testing.T) {
 		{
 			name: "should_return_default_test_config",
 			want: &Config{
-				Port:                     "1323",
-				Env:                      "test",
-				AccessTokenJwtExpiresIn:  "15m",
-				RefreshTokenJwtExpiresIn: "7d",
-				DbName:                   "keeper-go-test",
+				Port:                         "1323",
+				GRPCPort:                     "5051",
+				MetricsPort:                  "5052",
+				Env:                          "test",
+				AccessTokenJwtExpiresIn:      "15m",
+				RefreshTokenJwtExpiresIn:     "7d",
+				DbName:                       "keeper-go-test",
+				BinaryItemInlineThreshold:    1 << 20,
+				BinaryItemMaxSize:            64 << 20,
+				ItemCompressionThreshold:     4 << 10,
+				ItemCompressionAlgorithm:     "zstd",
+				TrashRetentionDays:           30,
+				TrashPurgeSchedule:           "@hourly",
+				BucketChangeRetentionDays:    30,
+				UserDeletionGracePeriodHours: 0,
+				WebhookMaxRetries:            8,
+				WebhookTimeoutSeconds:        10,
+				ItemExpirySweepSchedule:      "@every 1m",
+				RunMigrations:                true,
+				RequestTimeoutSeconds:        30,
+				RouteTimeouts:                []string{},
+				TracingExporter:              "none",
+				TracingServiceName:           "kipa",
+				TracingSampleRatio:           1,
+				LogLevel:                     "info",
+				RateLimitEnabled:             true,
+				RateLimitBackend:             "redis",
+				RateLimitDefault:             "600/1m",
+				RateLimitGroups:              []string{},
+				RateLimitIP:                  "1200/1m",
+				TrustedProxies:               []string{},
+				UsageRecomputeSchedule:       "@daily",
+				LoginMaxFailures:             5,
+				LoginMaxFailuresPerIP:        20,
+				LoginFailureWindowMinutes:    15,
+				LoginLockoutBaseSeconds:      60,
+				LoginLockoutMaxSeconds:       3600,
+				LoginUnlockTokenExpiresIn:    "1h",
+				JwtAudience:                  "kipa",
+				TwoFactorTokenExpiresIn:      "5m",
 			},
 		},
 	}
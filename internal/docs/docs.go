@@ -1598,7 +1598,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Update a user's password",
+                "description": "Update a user's password, the other sessions of the user are revoked",
                 "produces": [
                     "application/json"
                 ],
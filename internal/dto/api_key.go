@@ -21,6 +21,7 @@ type UpdateAPIKeyInputDTO struct {
 	Role        string                       `json:"role" form:"role" validate:"max=150" swaggertype:"string" example:""`
 	Permissions models.APIKeyPermissionsList `json:"permissions" form:"permissions" swaggertype:"array,string" example:""`
 	ExpiresAt   *time.Time                   `json:"expires_at" form:"expires_at" swaggertype:"primitive,string" example:"2022-09-30T15:04:05-07:00"`
+	RateLimit   *models.APIKeyRateLimit      `json:"rate_limit" form:"rate_limit" validate:"omitempty"`
 }
 
 type CreateAPIKeyOutputDTO struct {
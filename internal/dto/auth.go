@@ -1,17 +1,22 @@
 package dto
 
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
 type LoginUserInputDTO struct {
 	Email    string `json:"email" validate:"required,email" swaggertype:"string" example:"me@gmail.com"`
 	Password string `json:"password" validate:"required" swaggertype:"string" example:"********"`
 }
 
 type LoginUserOutputDTO struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken       string `json:"access_token"`
+	RefreshToken      string `json:"refresh_token"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"` // the tokens are only issued once a two-factor code is given
+	TwoFactorToken    string `json:"two_factor_token,omitempty"`    // exchanged for the tokens at /auth/login/2fa along with the code
 }
 
 type RefreshTokenOutputDTO struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"` // replaces the refresh token of the request, which can no longer be used
 }
 
 type ForgotPasswordInputDTO struct {
@@ -22,3 +27,47 @@ type ResetPasswordInputDTO struct {
 	Token       string `json:"token" validate:"required"`
 	NewPassword string `json:"new_password" validate:"required,password" swaggertype:"string" example:"********"`
 }
+
+type UnlockLoginInputDTO struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type SessionOutputDTO struct {
+	ID         string             `json:"id"`
+	IP         string             `json:"ip,omitempty" example:"203.0.113.7"`
+	UserAgent  string             `json:"user_agent,omitempty" example:"Mozilla/5.0"`
+	CreatedAt  primitive.DateTime `json:"created_at"`
+	LastUsedAt primitive.DateTime `json:"last_used_at"`
+	ExpiresAt  primitive.DateTime `json:"expires_at"`
+	Current    bool               `json:"current"` // the session of the request
+}
+
+type LogoutAllOutputDTO struct {
+	Sessions int64 `json:"sessions"` // number of revoked sessions
+}
+
+type TwoFactorLoginInputDTO struct {
+	Token        string `json:"token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode" swaggertype:"string" example:"123456"`
+	RecoveryCode string `json:"recovery_code" swaggertype:"string" example:"a1b2c-3d4e5"`
+}
+
+type TwoFactorEnrollOutputDTO struct {
+	Secret          string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	ProvisioningURI string `json:"provisioning_uri" example:"otpauth://totp/Kipa:me@gmail.com?secret=JBSWY3DPEHPK3PXP&issuer=Kipa"` // rendered as a QR code for the authenticator apps
+}
+
+type TwoFactorCodeInputDTO struct {
+	Code string `json:"code" validate:"required" swaggertype:"string" example:"123456"`
+}
+
+// the password and a two-factor or recovery code of the user, required to change its two-factor authentication
+type TwoFactorReauthInputDTO struct {
+	Password     string `json:"password" validate:"required" swaggertype:"string" example:"********"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode" swaggertype:"string" example:"123456"`
+	RecoveryCode string `json:"recovery_code" swaggertype:"string" example:"a1b2c-3d4e5"`
+}
+
+type RecoveryCodesOutputDTO struct {
+	RecoveryCodes []string `json:"recovery_codes"` // shown once, each code can be used once instead of a two-factor code
+}
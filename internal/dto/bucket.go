@@ -18,6 +18,10 @@ type UpdateBucketInputDTO struct {
 	Permissions models.BucketPermissionsList `json:"permissions" form:"permissions" swaggertype:"array,string" example:""`
 }
 
+type BucketTwoFactorInputDTO struct {
+	Required *bool `json:"required" validate:"required" example:"true"`
+}
+
 type CreateBucketOutputDTO struct {
 	ID          primitive.ObjectID           `json:"id"`
 	UID         string                       `json:"uid"`
@@ -28,13 +32,72 @@ type CreateBucketOutputDTO struct {
 }
 
 type BucketDetailsOutput struct {
-	ID          primitive.ObjectID           `json:"id"`
-	UID         string                       `json:"uid"`
-	UserID      primitive.ObjectID           `json:"user_id"`
-	Name        string                       `json:"name"`
-	Description string                       `json:"description"`
-	Permissions models.BucketPermissionsList `json:"permissions"`
-	CreatedAt   primitive.DateTime           `json:"created_at"`
-	UpdatedAt   primitive.DateTime           `json:"updated_at"`
-	BucketItems []models.BucketItem          `json:"bucket_items"`
+	ID               primitive.ObjectID           `json:"id"`
+	UID              string                       `json:"uid"`
+	UserID           primitive.ObjectID           `json:"user_id"`
+	Name             string                       `json:"name"`
+	Description      string                       `json:"description"`
+	Permissions      models.BucketPermissionsList `json:"permissions"`
+	RequireTwoFactor bool                         `json:"require_two_factor"`
+	CreatedAt        primitive.DateTime           `json:"created_at"`
+	UpdatedAt        primitive.DateTime           `json:"updated_at"`
+	BucketItems      []models.BucketItem          `json:"bucket_items"`
+}
+
+type CloneBucketInputDTO struct {
+	Name        string `json:"name" form:"name" validate:"required,min=2,max=150" swaggertype:"string" example:"test-bucket-copy"`
+	Description string `json:"description" form:"description" validate:"omitempty,min=2,max=200" swaggertype:"string" example:""`
+	Snapshot    string `json:"snapshot" form:"snapshot" validate:"omitempty" swaggertype:"string" example:""` // clone the bucket as it was in a snapshot
+}
+
+type CreateBucketSnapshotInputDTO struct {
+	Name        string `json:"name" form:"name" validate:"required,min=1,max=100" swaggertype:"string" example:"v1"`
+	Description string `json:"description" form:"description" validate:"omitempty,max=200" swaggertype:"string" example:""`
+}
+
+type BucketSnapshotDetailsOutput struct {
+	models.BucketSnapshot
+	BucketItems []models.BucketItem `json:"bucket_items"`
+}
+
+// Reference to the items of a bucket, or of one of its snapshots
+type BucketRefDTO struct {
+	BucketUID string `json:"bucket_uid"`
+	Snapshot  string `json:"snapshot,omitempty"`
+}
+
+type BucketItemDiffDTO struct {
+	Key  string             `json:"key"`
+	From *models.BucketItem `json:"from,omitempty"`
+	To   *models.BucketItem `json:"to,omitempty"`
+}
+
+type BucketDiffOutputDTO struct {
+	From    BucketRefDTO        `json:"from"`
+	To      BucketRefDTO        `json:"to"`
+	Added   []BucketItemDiffDTO `json:"added"`   // keys only in 'to'
+	Removed []BucketItemDiffDTO `json:"removed"` // keys only in 'from'
+	Changed []BucketItemDiffDTO `json:"changed"` // keys in both with different values
+}
+
+type MergeBucketInputDTO struct {
+	SourceBucketUID string `json:"source_bucket_uid" form:"source_bucket_uid" validate:"omitempty" swaggertype:"string" example:""` // defaults to the target bucket, to merge one of its snapshots
+	SourceSnapshot  string `json:"source_snapshot" form:"source_snapshot" validate:"omitempty" swaggertype:"string" example:""`
+	Strategy        string `json:"strategy" form:"strategy" validate:"omitempty,oneof=fail overwrite skip" swaggertype:"string" example:"fail"`
+	DeleteMissing   bool   `json:"delete_missing" form:"delete_missing" swaggertype:"boolean" example:"false"` // move the keys missing from the source to the trash
+}
+
+type MergeBucketOutputDTO struct {
+	Created []string `json:"created"`
+	Updated []string `json:"updated"`
+	Deleted []string `json:"deleted"`
+	Skipped []string `json:"skipped"`
+}
+
+type BucketChangesOutputDTO struct {
+	BucketUID  string                `json:"bucket_uid"`
+	Changes    []models.BucketChange `json:"changes"`
+	NextCursor int64                 `json:"next_cursor"` // pass as 'since' to resume after the returned changes
+	HasMore    bool                  `json:"has_more"`
+	LatestSeq  int64                 `json:"latest_seq"`
 }
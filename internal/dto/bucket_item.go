@@ -1,6 +1,11 @@
 package dto
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"io"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type CreateBucketItemInputDTO struct {
 	Key  string      `json:"key" form:"key" validate:"required,min=2,max=200" swaggertype:"string" example:"test-key"`
@@ -14,14 +19,33 @@ type UpdateBucketItemInputDTO struct {
 	TTL  int         `json:"ttl" form:"ttl" swaggertype:"integer" example:""`
 }
 
+type CreateBinaryBucketItemInputDTO struct {
+	Key         string `json:"key" validate:"required,min=2,max=200"`
+	ContentType string `json:"content_type"`
+	TTL         int    `json:"ttl" validate:"min=0"`
+}
+
 type CreateBucketItemOutputDTO struct {
-	ID        primitive.ObjectID `json:"id"`
-	BucketUID string             `json:"bucket_uid"`
-	Key       string             `json:"key"`
-	Data      interface{}        `json:"data"`
-	TTL       int                `json:"ttl"`
-	Type      string             `json:"type"`
-	CreatedAt primitive.DateTime `json:"created_at"`
+	ID          primitive.ObjectID `json:"id"`
+	BucketUID   string             `json:"bucket_uid"`
+	Key         string             `json:"key"`
+	Data        interface{}        `json:"data"`
+	TTL         int                `json:"ttl"`
+	Type        string             `json:"type"`
+	ContentType string             `json:"content_type,omitempty"`
+	Size        int64              `json:"size,omitempty"`
+	Hash        string             `json:"hash,omitempty"`
+	CreatedAt   primitive.DateTime `json:"created_at"`
+}
+
+// Streamable content of a binary bucket item
+type BinaryBucketItemContentDTO struct {
+	Key         string
+	ContentType string
+	Size        int64
+	Hash        string
+	UpdatedAt   time.Time
+	Content     io.ReadSeekCloser
 }
 
 type BucketItemDetailDTO struct {
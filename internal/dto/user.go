@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"keeper/internal/models"
+	"time"
+)
+
 type CreateUserInputDTO struct {
 	Firstname string `json:"firstname" validate:"required,min=2" swaggertype:"string" example:"Similoluwa"`
 	Lastname  string `json:"lastname" validate:"required,min=2" swaggertype:"string" example:"Okunowo"`
@@ -22,3 +27,26 @@ type UpdateUserPasswordInputDTO struct {
 type VerifyEmailInputDTO struct {
 	Token string `json:"token" validate:"required" swaggertype:"string"`
 }
+
+type UserDeletionStatusOutputDTO struct {
+	State       string                       `json:"state"`                  // asynq task state, e.g. scheduled, active, completed
+	ScheduledAt *time.Time                   `json:"scheduled_at,omitempty"` // when the deletion starts, if it is still pending
+	Cancellable bool                         `json:"cancellable"`
+	Progress    *models.UserDeletionProgress `json:"progress,omitempty"`
+}
+
+type UserUsageOutputDTO struct {
+	Buckets int64          `json:"buckets"`
+	Items   int64          `json:"items"`
+	Bytes   int64          `json:"bytes"`
+	Quota   models.Quota   `json:"quota"` // effective quota, a limit of 0 is unlimited
+	Usages  []models.Usage `json:"bucket_usages"`
+}
+
+// the limits left at 0 are the deployment defaults, the negative ones are unlimited
+type UpdateUserQuotaInputDTO struct {
+	MaxBuckets        int64 `json:"max_buckets" example:"100"`
+	MaxItemsPerBucket int64 `json:"max_items_per_bucket" example:"10000"`
+	MaxValueBytes     int64 `json:"max_value_bytes" example:"1048576"`
+	MaxStorageBytes   int64 `json:"max_storage_bytes" example:"-1"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CreateWebhookInputDTO struct {
+	URL    string   `json:"url" form:"url" validate:"required,url" swaggertype:"string" example:"https://example.com/hooks/kipa"`
+	Events []string `json:"events" form:"events" swaggertype:"array,string" example:"item.created,item.updated"` // subscribes to every event if empty
+}
+
+type CreateWebhookOutputDTO struct {
+	ID        primitive.ObjectID `json:"id"`
+	BucketUID string             `json:"bucket_uid"`
+	URL       string             `json:"url"`
+	Events    []string           `json:"events"`
+	Secret    string             `json:"secret"` // only returned on creation, used to verify the signature of the deliveries
+	CreatedAt primitive.DateTime `json:"created_at"`
+}
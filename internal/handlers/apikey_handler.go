@@ -31,7 +31,8 @@ type IAPIKeyHandler interface {
 
 func NewAPIKeyHandler(cfg *config.Config, dbClient *mongo.Client) IAPIKeyHandler {
 	apiKeyRepo := repository.NewAPIKeyRepository(cfg, dbClient)
-	apiKeyService := services.NewAPIKeyService(cfg, apiKeyRepo)
+	auditRepo := repository.NewAuditEventRepository(cfg, dbClient)
+	apiKeyService := services.NewAPIKeyService(cfg, apiKeyRepo, auditRepo)
 	return &APIKeyHandler{
 		apiKeySvc: apiKeyService,
 		validator: validators.NewValidator(),
@@ -46,25 +47,25 @@ func NewAPIKeyHandler(cfg *config.Config, dbClient *mongo.Client) IAPIKeyHandler
 // @Param        data body dto.CreateAPIKeyInputDTO true "Create API Key Data"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /api_key [post]
 func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
 	// retrieve user from context
 	user := c.Get("user").(*models.User)
 	data := new(dto.CreateAPIKeyInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 
-	resp, err := h.apiKeySvc.CreateAPIKey(*data, user.ID)
+	resp, err := h.apiKeySvc.CreateAPIKey(c.Request().Context(), *data, user.ID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusCreated, &models.SuccessResponse{
 		Status:  true,
@@ -82,16 +83,16 @@ func (h *APIKeyHandler) CreateAPIKey(c echo.Context) error {
 // @Param        apiKeyId path string true "API Key ID"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /api_key/{apiKeyId} [get]
 func (h *APIKeyHandler) FindAPIKeyByID(c echo.Context) error {
 	// retrieve the apiKeyID
 	apiKeyID := c.Param("apiKeyId")
-	apiKey, err := h.apiKeySvc.FindAPIKeyByID(apiKeyID)
+	apiKey, err := h.apiKeySvc.FindAPIKeyByID(c.Request().Context(), apiKeyID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -108,16 +109,16 @@ func (h *APIKeyHandler) FindAPIKeyByID(c echo.Context) error {
 // @Produce      json
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /api_keys [get]
 func (h *APIKeyHandler) FindUserAPIKeys(c echo.Context) error {
 	// retrieve the user from context
 	user := c.Get("user").(*models.User)
-	apiKeys, err := h.apiKeySvc.FindUserAPIKeys(user.ID.Hex())
+	apiKeys, err := h.apiKeySvc.FindUserAPIKeys(c.Request().Context(), user.ID.Hex())
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -136,25 +137,25 @@ func (h *APIKeyHandler) FindUserAPIKeys(c echo.Context) error {
 // @Param 		 apiKeyId path string true "API Key ID"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /api_key/{apiKeyId} [put]
 func (h *APIKeyHandler) UpdateAPIKey(c echo.Context) error {
 	// retrieve apiKeyID from param
 	apiKeyId := c.Param("apiKeyId")
 	data := new(dto.UpdateAPIKeyInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 
-	err := h.apiKeySvc.UpdateAPIKey(apiKeyId, *data)
+	err := h.apiKeySvc.UpdateAPIKey(c.Request().Context(), apiKeyId, *data)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
@@ -172,22 +173,22 @@ func (h *APIKeyHandler) UpdateAPIKey(c echo.Context) error {
 // @Param        data body dto.APIKeysIDsInputDTO true "API Key IDs"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /api_keys/revoke [put]
 func (h *APIKeyHandler) RevokeAPIKeys(c echo.Context) error {
 	apiKeyIds := new(dto.APIKeysIDsInputDTO)
 	if err := c.Bind(apiKeyIds); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(apiKeyIds); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
-	err := h.apiKeySvc.RevokeAPIKeys(apiKeyIds.Ids)
+	err := h.apiKeySvc.RevokeAPIKeys(c.Request().Context(), apiKeyIds.Ids)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully revoked API Key(s)!"})
@@ -202,22 +203,22 @@ func (h *APIKeyHandler) RevokeAPIKeys(c echo.Context) error {
 // @Param        data body dto.APIKeysIDsInputDTO true "API Key IDs"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /api_keys [delete]
 func (h *APIKeyHandler) DeleteAPIKeys(c echo.Context) error {
 	apiKeyIds := new(dto.APIKeysIDsInputDTO)
 	if err := c.Bind(apiKeyIds); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(apiKeyIds); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
-	err := h.apiKeySvc.DeleteAPIKeys(apiKeyIds.Ids)
+	err := h.apiKeySvc.DeleteAPIKeys(c.Request().Context(), apiKeyIds.Ids)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully deleted API Key(s)!"})
@@ -232,16 +233,16 @@ func (h *APIKeyHandler) DeleteAPIKeys(c echo.Context) error {
 // @Param        apiKeyId path string true "API Key ID"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /api_key/{apiKeyId}/revoke [put]
 func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
 	// retrieve apiKeyID from param
 	apiKeyId := c.Param("apiKeyId")
-	err := h.apiKeySvc.RevokeAPIKey(apiKeyId)
+	err := h.apiKeySvc.RevokeAPIKey(c.Request().Context(), apiKeyId)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
@@ -259,16 +260,16 @@ func (h *APIKeyHandler) RevokeAPIKey(c echo.Context) error {
 // @Param        apiKeyId path string true "API Key ID"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /api_key/{apiKeyId} [delete]
 func (h *APIKeyHandler) DeleteAPIKey(c echo.Context) error {
 	// retrieve apiKeyID from param
 	apiKeyId := c.Param("apiKeyId")
-	err := h.apiKeySvc.DeleteAPIKey(apiKeyId)
+	err := h.apiKeySvc.DeleteAPIKey(c.Request().Context(), apiKeyId)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
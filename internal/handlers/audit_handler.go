@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"fmt"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"keeper/internal/repository"
+	"keeper/internal/services"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Content type of the audit log exports, one JSON event per line
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+type AuditHandler struct {
+	auditSvc services.IAuditService
+}
+
+type IAuditHandler interface {
+	ListUserAuditEvents(c echo.Context) error
+	ExportUserAuditEvents(c echo.Context) error
+	ListBucketAuditEvents(c echo.Context) error
+	ExportBucketAuditEvents(c echo.Context) error
+}
+
+func NewAuditHandler(cfg *config.Config, dbClient *mongo.Client) IAuditHandler {
+	auditRepo := repository.NewAuditEventRepository(cfg, dbClient)
+	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
+	auditService := services.NewAuditService(cfg, auditRepo, bucketRepo)
+	return &AuditHandler{
+		auditSvc: auditService,
+	}
+}
+
+// ListUserAuditEvents  godoc
+// @Summary      ListUserAuditEvents
+// @Description  Page through the audit log of the authenticated user, most recent events first
+// @Tags         Audit
+// @Produce      json
+// @Param        page query integer false "Current Page"
+// @Param        perPage query integer false "Per Page"
+// @Param        action query string false "Action, e.g. auth.login_failed"
+// @Param        key query string false "Bucket item key"
+// @Param        credential_type query string false "Credential type, i.e. password, jwt, x-refresh-token or api_key"
+// @Param        api_key_mask_id query string false "Mask ID of the api key used"
+// @Param        from query string false "Start of the time range (RFC 3339)"
+// @Param        to query string false "End of the time range (RFC 3339), excluded"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.PaginatedSuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /user/audit [get]
+func (h *AuditHandler) ListUserAuditEvents(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	events, pageInfo, err := h.auditSvc.ListUserAuditEvents(c.Request().Context(), user.ID.Hex(), c.QueryParams())
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.PaginatedSuccessResponse{
+		Status:   true,
+		Message:  fmt.Sprintf("Successfully fetched %d audit events!", len(events)),
+		Data:     events,
+		PageInfo: pageInfo,
+	})
+}
+
+// ExportUserAuditEvents  godoc
+// @Summary      ExportUserAuditEvents
+// @Description  Export the audit log of the authenticated user as newline-delimited JSON, oldest events first
+// @Tags         Audit
+// @Produce      application/x-ndjson
+// @Param        action query string false "Action, e.g. auth.login_failed"
+// @Param        from query string false "Start of the time range (RFC 3339)"
+// @Param        to query string false "End of the time range (RFC 3339), excluded"
+// @Security     BearerAuth
+// @Success      200
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /user/audit/export [get]
+func (h *AuditHandler) ExportUserAuditEvents(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	return exportAuditEvents(c, "user-audit.ndjson", func() error {
+		return h.auditSvc.ExportUserAuditEvents(c.Request().Context(), user.ID.Hex(), c.QueryParams(), c.Response())
+	})
+}
+
+// ListBucketAuditEvents  godoc
+// @Summary      ListBucketAuditEvents
+// @Description  Page through the audit log of a bucket owned by the authenticated user, most recent events first
+// @Tags         Audit
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        page query integer false "Current Page"
+// @Param        perPage query integer false "Per Page"
+// @Param        action query string false "Action, e.g. item.update"
+// @Param        key query string false "Bucket item key"
+// @Param        from query string false "Start of the time range (RFC 3339)"
+// @Param        to query string false "End of the time range (RFC 3339), excluded"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.PaginatedSuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/audit [get]
+func (h *AuditHandler) ListBucketAuditEvents(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	events, pageInfo, err := h.auditSvc.ListBucketAuditEvents(c.Request().Context(), c.Param("bucketUID"), user.ID.Hex(), c.QueryParams())
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.PaginatedSuccessResponse{
+		Status:   true,
+		Message:  fmt.Sprintf("Successfully fetched %d audit events!", len(events)),
+		Data:     events,
+		PageInfo: pageInfo,
+	})
+}
+
+// ExportBucketAuditEvents  godoc
+// @Summary      ExportBucketAuditEvents
+// @Description  Export the audit log of a bucket owned by the authenticated user as newline-delimited JSON, oldest events first
+// @Tags         Audit
+// @Produce      application/x-ndjson
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        action query string false "Action, e.g. item.update"
+// @Param        from query string false "Start of the time range (RFC 3339)"
+// @Param        to query string false "End of the time range (RFC 3339), excluded"
+// @Security     BearerAuth
+// @Success      200
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/audit/export [get]
+func (h *AuditHandler) ExportBucketAuditEvents(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	bucketUID := c.Param("bucketUID")
+	return exportAuditEvents(c, fmt.Sprintf("bucket-%s-audit.ndjson", bucketUID), func() error {
+		return h.auditSvc.ExportBucketAuditEvents(c.Request().Context(), bucketUID, user.ID.Hex(), c.QueryParams(), c.Response())
+	})
+}
+
+// Streams an audit log export, the response is only committed with the first event
+// so that the errors returned before it are still rendered as problems
+func exportAuditEvents(c echo.Context, filename string, export func() error) error {
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, MIMEApplicationNDJSON)
+	header.Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	if err := export(); err != nil {
+		if c.Response().Committed {
+			// the export is cut short, the client sees a truncated stream
+			return err
+		}
+		header.Del(echo.HeaderContentDisposition)
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	if !c.Response().Committed {
+		c.Response().WriteHeader(http.StatusOK)
+	}
+	return nil
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"keeper/internal/config"
 	"keeper/internal/dto"
 	"keeper/internal/models"
+	"keeper/internal/pkg/metrics"
 	"keeper/internal/repository"
 	"keeper/internal/services"
 	"keeper/internal/validators"
@@ -26,12 +28,31 @@ type IAuthHandler interface {
 	GetAuthUser(c echo.Context) error
 	ForgotPassword(c echo.Context) error
 	ResetPassword(c echo.Context) error
+	UnlockLogin(c echo.Context) error
+	Logout(c echo.Context) error
+	LogoutAll(c echo.Context) error
+	GetSessions(c echo.Context) error
+	LoginTwoFactor(c echo.Context) error
+	EnrollTwoFactor(c echo.Context) error
+	ConfirmTwoFactor(c echo.Context) error
+	DisableTwoFactor(c echo.Context) error
+	RegenerateRecoveryCodes(c echo.Context) error
 }
 
 func NewAuthHandler(cfg *config.Config, dbClient *mongo.Client) IAuthHandler {
 	userRepo := repository.NewUserRepository(cfg, dbClient)
-	authService := services.NewAuthService(cfg, userRepo)
-	userService := services.NewUserService(cfg, userRepo)
+	auditRepo := repository.NewAuditEventRepository(cfg, dbClient)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(cfg, dbClient)
+	sessionRepo := repository.NewSessionRepository(cfg, dbClient)
+	twoFactorRepo := repository.NewTwoFactorRepository(cfg, dbClient)
+	authService := services.NewAuthService(cfg, userRepo, auditRepo, loginAttemptRepo, sessionRepo, twoFactorRepo)
+	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
+	bucketItemRepo := repository.NewBucketItemRepository(cfg, dbClient)
+	bucketSnapshotRepo := repository.NewBucketSnapshotRepository(cfg, dbClient)
+	apiKeyRepo := repository.NewAPIKeyRepository(cfg, dbClient)
+	usageRepo := repository.NewUsageRepository(cfg, dbClient)
+	webhookRepo := repository.NewWebhookRepository(cfg, dbClient)
+	userService := services.NewUserService(cfg, userRepo, bucketRepo, bucketItemRepo, bucketSnapshotRepo, apiKeyRepo, twoFactorRepo, sessionRepo, usageRepo, loginAttemptRepo, webhookRepo)
 	return &AuthHandler{
 		authSvc:   authService,
 		userSvc:   userService,
@@ -47,22 +68,23 @@ func NewAuthHandler(cfg *config.Config, dbClient *mongo.Client) IAuthHandler {
 // @Produce      json
 // @Param        data body dto.CreateUserInputDTO true "User Register Data"
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /auth/register [post]
 func (h *AuthHandler) Register(c echo.Context) error {
 	data := new(dto.CreateUserInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
-	err := h.userSvc.Register(*data)
+	err := h.userSvc.Register(c.Request().Context(), *data)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusCreated, &models.SuccessResponse{Status: true, Message: "Successfully registered user!"})
 }
@@ -70,51 +92,57 @@ func (h *AuthHandler) Register(c echo.Context) error {
 // LoginUser godoc
 // @Summary      Login user
 // @Description  Login an existing user, returns the access and refresh tokens
+// @Description  Repeated failures lock the logins to the account and from the IP address for a growing duration
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
 // @Param        data body dto.LoginUserInputDTO true "User Login Data"
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      401  {object}  models.Problem
+// @Failure      429  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c echo.Context) error {
 	data := new(dto.LoginUserInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 
-	resp, err := h.authSvc.Login(*data)
+	resp, err := h.authSvc.Login(c.Request().Context(), *data)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		if errors.Is(err, models.ErrInvalidCredentials) || errors.Is(err, models.ErrLoginLocked) {
+			metrics.IncAuthFailure(metrics.CredentialTypePassword)
+		}
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully logged in user!", Data: resp})
 }
 
 // RefreshToken godoc
 // @Summary      Refresh token
-// @Description  Refreshes a user's access token
+// @Description  Refreshes a user's access token and replaces its refresh token, reusing a replaced refresh token revokes its session
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
 // @Param        x-refresh-token header string true "Refresh token"
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      401  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /auth/refresh-token [post]
 func (h *AuthHandler) RefreshToken(c echo.Context) error {
 	// retrieve user from context
 	user := c.Get("user").(*models.User)
 
-	resp, err := h.authSvc.RefreshToken(user)
+	resp, err := h.authSvc.RefreshToken(c.Request().Context(), user, c.Request().Header.Get("x-refresh-token"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully refreshed token!", Data: resp})
 }
@@ -127,9 +155,9 @@ func (h *AuthHandler) RefreshToken(c echo.Context) error {
 // @Produce      json
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /auth/user [get]
 func (h *AuthHandler) GetAuthUser(c echo.Context) error {
 	// retrieve the user from context
@@ -146,23 +174,23 @@ func (h *AuthHandler) GetAuthUser(c echo.Context) error {
 // @Produce      json
 // @Param        data body dto.ForgotPasswordInputDTO true "Forgot Password Data"
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /auth/forgot-password [post]
 func (h *AuthHandler) ForgotPassword(c echo.Context) error {
 	data := new(dto.ForgotPasswordInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 
-	err := h.authSvc.ForgotPassword(*data)
+	err := h.authSvc.ForgotPassword(c.Request().Context(), *data)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully sent reset password link!"})
 }
@@ -175,23 +203,266 @@ func (h *AuthHandler) ForgotPassword(c echo.Context) error {
 // @Produce      json
 // @Param        data body dto.ResetPasswordInputDTO true "Reset Password Data"
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /auth/reset-password [post]
 func (h *AuthHandler) ResetPassword(c echo.Context) error {
 	data := new(dto.ResetPasswordInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 
-	err := h.authSvc.ResetPassword(*data)
+	err := h.authSvc.ResetPassword(c.Request().Context(), *data)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully reset password!"})
 }
+
+// UnlockLogin godoc
+// @Summary      Unlock Login
+// @Description  Unlocks the logins to an account locked after repeated failures, using the token mailed to its owner
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        data body dto.UnlockLoginInputDTO true "Unlock Login Data"
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/unlock [post]
+func (h *AuthHandler) UnlockLogin(c echo.Context) error {
+	data := new(dto.UnlockLoginInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+
+	err := h.authSvc.UnlockLogin(c.Request().Context(), *data)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully unlocked login!"})
+}
+
+// Logout godoc
+// @Summary      Logout
+// @Description  Revokes the session of the access token, its access and refresh tokens can no longer be used
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      401  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	sessionID, _ := c.Get("session_id").(string)
+
+	err := h.authSvc.Logout(c.Request().Context(), user, sessionID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully logged out!"})
+}
+
+// LogoutAll godoc
+// @Summary      Logout all sessions
+// @Description  Revokes all the sessions of the authenticated user
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      401  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+
+	revoked, err := h.authSvc.LogoutAll(c.Request().Context(), user)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully logged out of all sessions!", Data: dto.LogoutAllOutputDTO{Sessions: revoked}})
+}
+
+// GetSessions godoc
+// @Summary      Get sessions
+// @Description  Returns the active sessions of the authenticated user with their IP and user agent, most recently used first
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      401  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/sessions [get]
+func (h *AuthHandler) GetSessions(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	sessionID, _ := c.Get("session_id").(string)
+
+	sessions, err := h.authSvc.GetSessions(c.Request().Context(), user, sessionID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully fetched sessions.", Data: sessions})
+}
+
+// LoginTwoFactor godoc
+// @Summary      Login with two-factor authentication
+// @Description  Exchanges the two-factor token returned by the login and a two-factor or recovery code for the access and refresh token
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        data body dto.TwoFactorLoginInputDTO true "Two-Factor Login Data"
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      401  {object}  models.Problem
+// @Failure      429  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/login/2fa [post]
+func (h *AuthHandler) LoginTwoFactor(c echo.Context) error {
+	data := new(dto.TwoFactorLoginInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+
+	resp, err := h.authSvc.LoginTwoFactor(c.Request().Context(), *data)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidTwoFactorCode) || errors.Is(err, models.ErrLoginLocked) {
+			metrics.IncAuthFailure(metrics.CredentialTypePassword)
+		}
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully logged in user!", Data: resp})
+}
+
+// EnrollTwoFactor godoc
+// @Summary      Enroll two-factor authentication
+// @Description  Returns a new TOTP secret and its provisioning URI, to be rendered as a QR code. The two-factor authentication is enabled once a code is verified
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      401  {object} 	models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/2fa/enroll [post]
+func (h *AuthHandler) EnrollTwoFactor(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+
+	resp, err := h.authSvc.EnrollTwoFactor(c.Request().Context(), user)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully enrolled two-factor authentication.", Data: resp})
+}
+
+// ConfirmTwoFactor godoc
+// @Summary      Verify two-factor authentication
+// @Description  Enables the two-factor authentication with a code of the enrolled secret, returns the recovery codes
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        data body dto.TwoFactorCodeInputDTO true "Two-Factor Code"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      401  {object} 	models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/2fa/verify [post]
+func (h *AuthHandler) ConfirmTwoFactor(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	data := new(dto.TwoFactorCodeInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+
+	resp, err := h.authSvc.ConfirmTwoFactor(c.Request().Context(), user, *data)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully enabled two-factor authentication!", Data: resp})
+}
+
+// DisableTwoFactor godoc
+// @Summary      Disable two-factor authentication
+// @Description  Disables the two-factor authentication, requires the password and a two-factor or recovery code
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        data body dto.TwoFactorReauthInputDTO true "Re-authentication Data"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      401  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/2fa/disable [post]
+func (h *AuthHandler) DisableTwoFactor(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	data := new(dto.TwoFactorReauthInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+
+	err := h.authSvc.DisableTwoFactor(c.Request().Context(), user, *data)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully disabled two-factor authentication!"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      Regenerate recovery codes
+// @Description  Replaces the recovery codes, requires the password and a two-factor or recovery code
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        data body dto.TwoFactorReauthInputDTO true "Re-authentication Data"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      401  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /auth/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	data := new(dto.TwoFactorReauthInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+
+	resp, err := h.authSvc.RegenerateRecoveryCodes(c.Request().Context(), user, *data)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully regenerated recovery codes!", Data: resp})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"keeper/internal/repository"
+	"keeper/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type BucketChangeHandler struct {
+	bucketChangeSvc services.IBucketChangeService
+}
+
+type IBucketChangeHandler interface {
+	ListBucketChanges(c echo.Context) error
+}
+
+func NewBucketChangeHandler(cfg *config.Config, dbClient *mongo.Client) IBucketChangeHandler {
+	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
+	bucketChangeRepo := repository.NewBucketChangeRepository(cfg, dbClient)
+	bucketChangeService := services.NewBucketChangeService(cfg, bucketRepo, bucketChangeRepo)
+	return &BucketChangeHandler{
+		bucketChangeSvc: bucketChangeService,
+	}
+}
+
+// ListBucketChanges  godoc
+// @Summary      ListBucketChanges
+// @Description  Page through the change feed of a bucket, resuming after the 'since' cursor
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        since query int false "Sequence number to resume after, 0 to start from the beginning"
+// @Param        limit query int false "Maximum number of changes to return"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/changes [get]
+func (h *BucketChangeHandler) ListBucketChanges(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	var since, limit int64
+	var err error
+	if param := c.QueryParam("since"); param != "" {
+		if since, err = strconv.ParseInt(param, 10, 64); err != nil {
+			return models.NewAPIError(http.StatusBadRequest, errors.New("invalid since cursor"))
+		}
+	}
+	if param := c.QueryParam("limit"); param != "" {
+		if limit, err = strconv.ParseInt(param, 10, 64); err != nil {
+			return models.NewAPIError(http.StatusBadRequest, errors.New("invalid limit"))
+		}
+	}
+	changes, err := h.bucketChangeSvc.ListBucketChanges(c.Request().Context(), bucketUID, since, limit)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully fetched bucket changes!",
+		Data:    changes,
+	})
+}
@@ -26,13 +26,30 @@ type IBucketHandler interface {
 	ListUserBuckets(c echo.Context) error
 	ListUserBucketsPaged(c echo.Context) error
 	UpdateBucket(c echo.Context) error
+	SetBucketTwoFactor(c echo.Context) error
 	DeleteBucket(c echo.Context) error
+	ListTrashedBuckets(c echo.Context) error
+	RestoreBucket(c echo.Context) error
+	PermanentlyDeleteBucket(c echo.Context) error
+	CloneBucket(c echo.Context) error
+	CreateBucketSnapshot(c echo.Context) error
+	ListBucketSnapshots(c echo.Context) error
+	FindBucketSnapshot(c echo.Context) error
+	DeleteBucketSnapshot(c echo.Context) error
+	DiffBuckets(c echo.Context) error
+	MergeBuckets(c echo.Context) error
 }
 
 func NewBucketHandler(cfg *config.Config, dbClient *mongo.Client) IBucketHandler {
 	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
 	bucketItemRepo := repository.NewBucketItemRepository(cfg, dbClient)
-	bucketService := services.NewBucketService(cfg, bucketRepo, bucketItemRepo)
+	bucketSnapshotRepo := repository.NewBucketSnapshotRepository(cfg, dbClient)
+	blobRepo := repository.NewBucketItemBlobRepository(cfg, dbClient)
+	auditRepo := repository.NewAuditEventRepository(cfg, dbClient)
+	usageRepo := repository.NewUsageRepository(cfg, dbClient)
+	webhookRepo := repository.NewWebhookRepository(cfg, dbClient)
+	webhookService := services.NewWebhookService(cfg, webhookRepo, repository.NewWebhookDeliveryRepository(cfg, dbClient), bucketRepo)
+	bucketService := services.NewBucketService(cfg, bucketRepo, bucketItemRepo, bucketSnapshotRepo, blobRepo, auditRepo, usageRepo, webhookRepo, webhookService)
 	return &BucketHandler{
 		bucketSvc: bucketService,
 		validator: validators.NewValidator(),
@@ -48,9 +65,10 @@ func NewBucketHandler(cfg *config.Config, dbClient *mongo.Client) IBucketHandler
 // @Param        full query bool false "Should return full response"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /bucket [post]
 func (h *BucketHandler) CreateBucket(c echo.Context) error {
 	// retrieve user from context
@@ -58,15 +76,15 @@ func (h *BucketHandler) CreateBucket(c echo.Context) error {
 	q := c.QueryParam("full")
 	data := new(dto.CreateBucketInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
-	resp, err := h.bucketSvc.CreateBucket(*data, user.ID)
+	resp, err := h.bucketSvc.CreateBucket(c.Request().Context(), *data, user.ID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	// if 'full' is true, return the full response
 	if full, _ := strconv.ParseBool(q); full {
@@ -87,19 +105,16 @@ func (h *BucketHandler) CreateBucket(c echo.Context) error {
 // @Param        bucketUID path string true "Bucket UID"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /bucket/{bucketUID} [get]
 func (h *BucketHandler) FindBucketByUID(c echo.Context) error {
 	// retrieve the bucketUID
 	bucketUID := c.Param("bucketUID")
-	bucket, err := h.bucketSvc.FindBucketByUID(bucketUID)
+	bucket, err := h.bucketSvc.FindBucketByUID(c.Request().Context(), bucketUID)
 	if err != nil {
-		if err == models.ErrBucketNotFound {
-			return c.JSON(http.StatusNotFound, &models.ErrorResponse{Status: false, Error: err.Error()})
-		}
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -115,16 +130,16 @@ func (h *BucketHandler) FindBucketByUID(c echo.Context) error {
 // @Produce      json
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /buckets/all [get]
 func (h *BucketHandler) ListUserBuckets(c echo.Context) error {
 	// retrieve the user from context
 	user := c.Get("user").(*models.User)
-	buckets, err := h.bucketSvc.ListUserBuckets(user.ID.Hex())
+	buckets, err := h.bucketSvc.ListUserBuckets(c.Request().Context(), user.ID.Hex())
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -143,16 +158,16 @@ func (h *BucketHandler) ListUserBuckets(c echo.Context) error {
 // @Param        sortBy query string false "Sort By"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.PaginatedSuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /buckets [get]
 func (h *BucketHandler) ListUserBucketsPaged(c echo.Context) error {
 	// retrieve the user from context
 	user := c.Get("user").(*models.User)
-	buckets, pageInfo, err := h.bucketSvc.ListUserBucketsPaged(user.ID.Hex(), c.QueryParams())
+	buckets, pageInfo, err := h.bucketSvc.ListUserBucketsPaged(c.Request().Context(), user.ID.Hex(), c.QueryParams())
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.PaginatedSuccessResponse{
 		Status:   true,
@@ -171,46 +186,375 @@ func (h *BucketHandler) ListUserBucketsPaged(c echo.Context) error {
 // @Param        bucketUID path string true "Bucket UID"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /bucket/{bucketUID} [put]
 func (h *BucketHandler) UpdateBucket(c echo.Context) error {
 	// retrieve the bucket UID
 	bucketUID := c.Param("bucketUID")
 	data := new(dto.UpdateBucketInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
-	err := h.bucketSvc.UpdateBucket(bucketUID, *data)
+	err := h.bucketSvc.UpdateBucket(c.Request().Context(), bucketUID, *data)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully updated bucket!"})
 }
 
+// SetBucketTwoFactor  godoc
+// @Summary      SetBucketTwoFactor
+// @Description  Require two-factor authentication on a bucket, its owner then needs it to access the bucket and the other users to change it. Only the owner, with two-factor authentication enabled, can require it
+// @Tags         Bucket
+// @Produce      json
+// @Param        data body dto.BucketTwoFactorInputDTO true "Bucket Two-Factor Data"
+// @Param        bucketUID path string true "Bucket UID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      403  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/two-factor [put]
+func (h *BucketHandler) SetBucketTwoFactor(c echo.Context) error {
+	// retrieve the bucket UID and the user
+	bucketUID := c.Param("bucketUID")
+	user := c.Get("user").(*models.User)
+	data := new(dto.BucketTwoFactorInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+	err := h.bucketSvc.SetBucketTwoFactor(c.Request().Context(), bucketUID, user, *data.Required)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully updated bucket two-factor requirement!"})
+}
+
 // DeleteBucket  godoc
 // @Summary      DeleteBucket
-// @Description  Delete a user's bucket
+// @Description  Move a user's bucket and its items to the trash
 // @Tags         Bucket
 // @Produce      json
 // @Param        bucketUID path string true "Bucket UID"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /bucket/{bucketUID} [delete]
 func (h *BucketHandler) DeleteBucket(c echo.Context) error {
 	// retrieve the bucket UID
 	bucketUID := c.Param("bucketUID")
-	err := h.bucketSvc.DeleteBucket(bucketUID)
+	err := h.bucketSvc.DeleteBucket(c.Request().Context(), bucketUID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully moved bucket to the trash!"})
+}
+
+// ListTrashedBuckets  godoc
+// @Summary      ListTrashedBuckets
+// @Description  Returns a list of the authenticated user's trashed buckets
+// @Tags         Bucket
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /buckets/trash [get]
+func (h *BucketHandler) ListTrashedBuckets(c echo.Context) error {
+	// retrieve the user from context
+	user := c.Get("user").(*models.User)
+	buckets, err := h.bucketSvc.ListTrashedBuckets(c.Request().Context(), user.ID.Hex())
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: fmt.Sprintf("Successfully fetched %d trashed buckets!", len(buckets)),
+		Data:    buckets,
+	})
+}
+
+// RestoreBucket  godoc
+// @Summary      RestoreBucket
+// @Description  Restore a trashed bucket along with the items that were trashed with it
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/restore [post]
+func (h *BucketHandler) RestoreBucket(c echo.Context) error {
+	// retrieve the user from context
+	user := c.Get("user").(*models.User)
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	err := h.bucketSvc.RestoreBucket(c.Request().Context(), bucketUID, user.ID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully restored bucket!"})
+}
+
+// PermanentlyDeleteBucket  godoc
+// @Summary      PermanentlyDeleteBucket
+// @Description  Permanently delete a bucket and all its items, whether they are in the trash or not (admin only)
+// @Tags         Admin
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      403  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /admin/bucket/{bucketUID} [delete]
+func (h *BucketHandler) PermanentlyDeleteBucket(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	err := h.bucketSvc.PermanentlyDeleteBucket(c.Request().Context(), bucketUID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully deleted bucket permanently!"})
+}
+
+// CloneBucket  godoc
+// @Summary      CloneBucket
+// @Description  Clone a bucket, or one of its snapshots, into a new bucket with the same permissions and items
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        data body dto.CloneBucketInputDTO true "Clone Bucket Data"
+// @Security     BearerAuth
+// @Success      201  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/clone [post]
+func (h *BucketHandler) CloneBucket(c echo.Context) error {
+	// retrieve the user from context
+	user := c.Get("user").(*models.User)
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	data := new(dto.CloneBucketInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+	resp, err := h.bucketSvc.CloneBucket(c.Request().Context(), bucketUID, *data, user.ID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusCreated, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully cloned bucket!",
+		Data:    resp,
+	})
+}
+
+// CreateBucketSnapshot  godoc
+// @Summary      CreateBucketSnapshot
+// @Description  Take a named, read-only snapshot of the items of a bucket
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        data body dto.CreateBucketSnapshotInputDTO true "Create Bucket Snapshot Data"
+// @Security     BearerAuth
+// @Success      201  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/snapshots [post]
+func (h *BucketHandler) CreateBucketSnapshot(c echo.Context) error {
+	// retrieve the user from context
+	user := c.Get("user").(*models.User)
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	data := new(dto.CreateBucketSnapshotInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+	snapshot, err := h.bucketSvc.CreateBucketSnapshot(c.Request().Context(), bucketUID, *data, user.ID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusCreated, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully created bucket snapshot!",
+		Data:    snapshot,
+	})
+}
+
+// ListBucketSnapshots  godoc
+// @Summary      ListBucketSnapshots
+// @Description  List the snapshots of a bucket
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/snapshots [get]
+func (h *BucketHandler) ListBucketSnapshots(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	snapshots, err := h.bucketSvc.ListBucketSnapshots(c.Request().Context(), bucketUID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: fmt.Sprintf("Successfully fetched %d bucket snapshots!", len(snapshots)),
+		Data:    snapshots,
+	})
+}
+
+// FindBucketSnapshot  godoc
+// @Summary      FindBucketSnapshot
+// @Description  Returns a bucket snapshot along with its items
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        name path string true "Snapshot name"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/snapshots/{name} [get]
+func (h *BucketHandler) FindBucketSnapshot(c echo.Context) error {
+	// retrieve the bucket UID and snapshot name
+	bucketUID := c.Param("bucketUID")
+	name := c.Param("name")
+	snapshot, err := h.bucketSvc.FindBucketSnapshot(c.Request().Context(), bucketUID, name)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully fetched bucket snapshot!",
+		Data:    snapshot,
+	})
+}
+
+// DeleteBucketSnapshot  godoc
+// @Summary      DeleteBucketSnapshot
+// @Description  Delete a bucket snapshot
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        name path string true "Snapshot name"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/snapshots/{name} [delete]
+func (h *BucketHandler) DeleteBucketSnapshot(c echo.Context) error {
+	// retrieve the bucket UID and snapshot name
+	bucketUID := c.Param("bucketUID")
+	name := c.Param("name")
+	err := h.bucketSvc.DeleteBucketSnapshot(c.Request().Context(), bucketUID, name)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully deleted bucket snapshot!"})
+}
+
+// DiffBuckets  godoc
+// @Summary      DiffBuckets
+// @Description  Compare a bucket or one of its snapshots with another bucket or snapshot
+// @Description  the keys are reported as added, removed or changed going from the first to the second
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        snapshot query string false "Snapshot of the bucket to compare from"
+// @Param        to query string false "Bucket UID to compare to, defaults to the bucket itself"
+// @Param        to_snapshot query string false "Snapshot of the bucket to compare to"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/diff [get]
+func (h *BucketHandler) DiffBuckets(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	from := dto.BucketRefDTO{BucketUID: bucketUID, Snapshot: c.QueryParam("snapshot")}
+	to := dto.BucketRefDTO{BucketUID: c.QueryParam("to"), Snapshot: c.QueryParam("to_snapshot")}
+	if to.BucketUID == "" {
+		to.BucketUID = bucketUID
+	}
+	diff, err := h.bucketSvc.DiffBuckets(c.Request().Context(), from, to)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
-	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully deleted bucket!"})
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully compared buckets!",
+		Data:    diff,
+	})
+}
+
+// MergeBuckets  godoc
+// @Summary      MergeBuckets
+// @Description  Merge the items of a bucket or snapshot into a bucket
+// @Description  the keys that differ are resolved with the strategy: fail (default), overwrite or skip
+// @Tags         Bucket
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        data body dto.MergeBucketInputDTO true "Merge Bucket Data"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/merge [post]
+func (h *BucketHandler) MergeBuckets(c echo.Context) error {
+	// retrieve the user from context
+	user := c.Get("user").(*models.User)
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	data := new(dto.MergeBucketInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+	result, err := h.bucketSvc.MergeBuckets(c.Request().Context(), bucketUID, *data, user.ID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully merged buckets!",
+		Data:    result,
+	})
 }
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"keeper/internal/config"
 	"keeper/internal/dto"
@@ -11,6 +15,7 @@ import (
 	"keeper/internal/validators"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -23,6 +28,8 @@ type BucketItemHandler struct {
 
 type IBucketItemHandler interface {
 	CreateBucketItem(c echo.Context) error
+	CreateBinaryBucketItem(c echo.Context) error
+	DownloadBinaryBucketItem(c echo.Context) error
 	FindBucketItemByID(c echo.Context) error
 	ListBucketItemsByBucketUID(c echo.Context) error
 	ListBucketItemsPaged(c echo.Context) error
@@ -31,12 +38,21 @@ type IBucketItemHandler interface {
 	DeleteBucketItemById(c echo.Context) error
 	DeleteBucketItemsById(c echo.Context) error
 	DeleteBucketItemByKeyName(c echo.Context) error
+	GetCompressionStats(c echo.Context) error
+	CompressBucketItems(c echo.Context) error
+	ListTrashedBucketItems(c echo.Context) error
+	RestoreBucketItem(c echo.Context) error
+	PermanentlyDeleteBucketItem(c echo.Context) error
 }
 
 func NewBucketItemHandler(cfg *config.Config, dbClient *mongo.Client) IBucketItemHandler {
 	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
 	bucketItemRepo := repository.NewBucketItemRepository(cfg, dbClient)
-	bucketItemService := services.NewBucketItemService(cfg, bucketItemRepo, bucketRepo)
+	bucketItemBlobRepo := repository.NewBucketItemBlobRepository(cfg, dbClient)
+	auditRepo := repository.NewAuditEventRepository(cfg, dbClient)
+	usageRepo := repository.NewUsageRepository(cfg, dbClient)
+	webhookService := services.NewWebhookService(cfg, repository.NewWebhookRepository(cfg, dbClient), repository.NewWebhookDeliveryRepository(cfg, dbClient), bucketRepo)
+	bucketItemService := services.NewBucketItemService(cfg, bucketItemRepo, bucketRepo, bucketItemBlobRepo, auditRepo, usageRepo, webhookService)
 	return &BucketItemHandler{
 		bucketItemSvc: bucketItemService,
 	}
@@ -52,9 +68,10 @@ func NewBucketItemHandler(cfg *config.Config, dbClient *mongo.Client) IBucketIte
 // @Param        full query bool false "Should return full response"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /item/{bucketUID} [post]
 func (h *BucketItemHandler) CreateBucketItem(c echo.Context) error {
 	// retrieve the bucket UID
@@ -64,15 +81,15 @@ func (h *BucketItemHandler) CreateBucketItem(c echo.Context) error {
 	user := c.Get("user").(*models.User)
 	data := new(dto.CreateBucketItemInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	// if err := h.validator.Validate(data); err != nil {
-	// 	return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+	// 	return err
 	// }
-	resp, err := h.bucketItemSvc.CreateBucketItem(*data, user.ID, bucketUID)
+	resp, err := h.bucketItemSvc.CreateBucketItem(c.Request().Context(), *data, user.ID, bucketUID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	if full, _ := strconv.ParseBool(q); full {
 		return c.JSON(http.StatusCreated, &models.SuccessResponse{
@@ -84,6 +101,108 @@ func (h *BucketItemHandler) CreateBucketItem(c echo.Context) error {
 	return c.JSON(http.StatusCreated, fmt.Sprintf("Successfully created '%s' in bucket '%s'", data.Key, bucketUID))
 }
 
+// CreateBinaryBucketItem  godoc
+// @Summary      CreateBinaryBucketItem
+// @Description  Create a new binary bucket item from the raw request body or the 'file' field of a multipart form
+// @Tags         BucketItem
+// @Accept       application/octet-stream,multipart/form-data
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        key path string true "Key name"
+// @Param        ttl query integer false "Time to live (in seconds)"
+// @Param        file formData file false "Binary value (multipart uploads)"
+// @Security     BearerAuth
+// @Success      201  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      413  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /item/{bucketUID}/{key}/binary [post]
+func (h *BucketItemHandler) CreateBinaryBucketItem(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	// retrieve the key
+	key := c.Param("key")
+	// retrieve user from context
+	user := c.Get("user").(*models.User)
+	data := dto.CreateBinaryBucketItemInputDTO{Key: key}
+	if q := c.QueryParam("ttl"); q != "" {
+		ttl, err := strconv.Atoi(q)
+		if err != nil || ttl < 0 {
+			return models.NewAPIError(http.StatusBadRequest, errors.New("ttl must be a positive integer"))
+		}
+		data.TTL = ttl
+	}
+
+	// the value is either the raw request body or the 'file' field of a multipart form
+	var source io.Reader
+	req := c.Request()
+	if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return models.NewAPIError(http.StatusBadRequest, err)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return models.ToAPIError(err, http.StatusInternalServerError)
+		}
+		defer file.Close()
+		source = file
+		data.ContentType = fileHeader.Header.Get(echo.HeaderContentType)
+	} else {
+		source = req.Body
+		data.ContentType = req.Header.Get(echo.HeaderContentType)
+	}
+
+	resp, err := h.bucketItemSvc.CreateBinaryBucketItem(c.Request().Context(), data, source, user.ID, bucketUID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusCreated, &models.SuccessResponse{
+		Status:  true,
+		Message: fmt.Sprintf("Successfully created '%s' in bucket '%s'", key, bucketUID),
+		Data:    resp,
+	})
+}
+
+// DownloadBinaryBucketItem  godoc
+// @Summary      DownloadBinaryBucketItem
+// @Description  Streams the value of a binary bucket item, supports range and conditional requests
+// @Tags         BucketItem
+// @Produce      application/octet-stream
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        key path string true "Key name"
+// @Param        Range header string false "Byte range"
+// @Security     BearerAuth
+// @Success      200
+// @Success      206
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /item/{bucketUID}/{key}/binary [get]
+func (h *BucketItemHandler) DownloadBinaryBucketItem(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	// retrieve the key
+	key := c.Param("key")
+	content, err := h.bucketItemSvc.OpenBinaryBucketItem(c.Request().Context(), bucketUID, key)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	defer content.Content.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, content.ContentType)
+	header.Set("ETag", fmt.Sprintf("\"%s\"", content.Hash))
+	header.Set("X-Content-SHA256", content.Hash)
+	if digest, err := hex.DecodeString(content.Hash); err == nil {
+		header.Set("Digest", fmt.Sprintf("sha-256=%s", base64.StdEncoding.EncodeToString(digest)))
+	}
+	// handles range, if-range and if-none-match requests
+	http.ServeContent(c.Response(), c.Request(), content.Key, content.UpdatedAt, content.Content)
+	return nil
+}
+
 func (h *BucketItemHandler) FindBucketItemByID(c echo.Context) error {
 	panic("not implemented") // TODO: Implement
 }
@@ -97,18 +216,17 @@ func (h *BucketItemHandler) FindBucketItemByID(c echo.Context) error {
 // @Param        full query bool false "Should return full response"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /items/{bucketUID} [get]
 func (h *BucketItemHandler) ListBucketItemsByBucketUID(c echo.Context) error {
 	// retrieve the bucket UID
 	bucketUID := c.Param("bucketUID")
-	bucketItems, err := h.bucketItemSvc.ListBucketItems(bucketUID)
+	bucketItems, err := h.bucketItemSvc.ListBucketItems(c.Request().Context(), bucketUID)
 
-	fmt.Println(bucketItems)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -117,29 +235,76 @@ func (h *BucketItemHandler) ListBucketItemsByBucketUID(c echo.Context) error {
 	})
 }
 
+// GetCompressionStats  godoc
+// @Summary      GetCompressionStats
+// @Description  Returns the compression statistics (compressed items, raw and stored sizes, ratio) of the items in a bucket
+// @Tags         BucketItem
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse{data=models.BucketItemCompressionStats}
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /items/{bucketUID}/compression [get]
+func (h *BucketItemHandler) GetCompressionStats(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	stats, err := h.bucketItemSvc.GetCompressionStats(c.Request().Context(), bucketUID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully fetched bucket item compression stats!",
+		Data:    stats,
+	})
+}
+
+// CompressBucketItems  godoc
+// @Summary      CompressBucketItems
+// @Description  Starts a background job that compresses the existing items of a bucket that are above the compression threshold
+// @Tags         BucketItem
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Security     BearerAuth
+// @Success      202  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /items/{bucketUID}/compression [post]
+func (h *BucketItemHandler) CompressBucketItems(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	if err := h.bucketItemSvc.CompressBucketItems(c.Request().Context(), bucketUID); err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusAccepted, &models.SuccessResponse{
+		Status:  true,
+		Message: fmt.Sprintf("Started compressing the items in bucket '%s'", bucketUID),
+	})
+}
+
 // ListBucketItemsPaged  godoc
 // @Summary      ListBucketItemsPaged
 // @Description  Returns a list of all the items contained in a bucket (supports pagination, filtering, and sorting)
 // @Tags         BucketItem
 // @Produce      json
-// @Param        bucketUID path string true "Bucket UID"
+// @Param        bucket_uid query string true "Bucket UID"
 // @Param        full query bool false "Should return full response"
 // @Param        page query integer false "Current Page"
 // @Param        perPage query integer false "Per Page"
 // @Param        sortBy query string false "Sort By"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.PaginatedSuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
-// @Router /items/{bucketUID} [get]
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /items [get]
 func (h *BucketItemHandler) ListBucketItemsPaged(c echo.Context) error {
 	queryParams := c.QueryParams()
-	bucketItems, pageInfo, err := h.bucketItemSvc.ListBucketItemsPaged(queryParams)
+	bucketItems, pageInfo, err := h.bucketItemSvc.ListBucketItemsPaged(c.Request().Context(), queryParams)
 
-	fmt.Println(bucketItems)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.PaginatedSuccessResponse{
 		Status:   true,
@@ -159,9 +324,9 @@ func (h *BucketItemHandler) ListBucketItemsPaged(c echo.Context) error {
 // @Param        full query bool false "Should return full response"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /item/{bucketUID}/{key} [get]
 func (h *BucketItemHandler) FindBucketItemByKeyName(c echo.Context) error {
 	// retrieve the bucket UID
@@ -169,12 +334,9 @@ func (h *BucketItemHandler) FindBucketItemByKeyName(c echo.Context) error {
 	q := c.QueryParam("full")
 	// retrieve the key
 	key := c.Param("key")
-	bucketItem, err := h.bucketItemSvc.FindBucketItemByKeyName(bucketUID, key)
+	bucketItem, err := h.bucketItemSvc.FindBucketItemByKeyName(c.Request().Context(), bucketUID, key)
 	if err != nil {
-		if err == models.ErrBucketItemNotFound {
-			return c.JSON(http.StatusNotFound, &models.ErrorResponse{Status: false, Error: err.Error()})
-		}
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	if full, _ := strconv.ParseBool(q); full {
 		return c.JSON(http.StatusOK, &models.SuccessResponse{
@@ -195,9 +357,10 @@ func (h *BucketItemHandler) FindBucketItemByKeyName(c echo.Context) error {
 // @Param        key path string true "Key name"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /items/{bucketUID}/{key} [put]
 func (h *BucketItemHandler) UpdateBucketItemByKeyName(c echo.Context) error {
 	// retrieve the bucket UID
@@ -211,20 +374,20 @@ func (h *BucketItemHandler) UpdateBucketItemByKeyName(c echo.Context) error {
 		value := string(body)
 		amount, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+			return models.NewAPIError(http.StatusBadRequest, err)
 		}
-		err = h.bucketItemSvc.IncrementIntValue(bucketUID, key, int(amount))
+		err = h.bucketItemSvc.IncrementIntValue(c.Request().Context(), bucketUID, key, int(amount))
 		if err != nil {
-			return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+			return models.ToAPIError(err, http.StatusInternalServerError)
 		}
 		return c.JSON(http.StatusOK, &models.SuccessResponse{
 			Status:  true,
 			Message: fmt.Sprintf("Successfully updated '%s'!", key),
 		})
 	}
-	err := h.bucketItemSvc.UpdateBucketItemByKeyName(*data, bucketUID, key)
+	err := h.bucketItemSvc.UpdateBucketItemByKeyName(c.Request().Context(), *data, bucketUID, key)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -242,28 +405,111 @@ func (h *BucketItemHandler) DeleteBucketItemsById(c echo.Context) error {
 
 // DeleteBucketItemByKeyName  godoc
 // @Summary      DeleteBucketItemByKeyName
-// @Description  Delete an item from a bucket matching the passed key
+// @Description  Move an item matching the passed key from a bucket to the trash
 // @Tags         BucketItem
 // @Produce      json
 // @Param        bucketUID path string true "Bucket UID"
 // @Param        key path string true "Key name"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /items/{bucketUID}/{key} [delete]
 func (h *BucketItemHandler) DeleteBucketItemByKeyName(c echo.Context) error {
 	// retrieve the bucket UID
 	bucketUID := c.Param("bucketUID")
 	// retrieve the key
 	key := c.Param("key")
-	err := h.bucketItemSvc.DeleteBucketItemByKeyName(bucketUID, key)
+	err := h.bucketItemSvc.DeleteBucketItemByKeyName(c.Request().Context(), bucketUID, key)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: fmt.Sprintf("Successfully moved '%s' to the trash", key),
+	})
+}
+
+// ListTrashedBucketItems  godoc
+// @Summary      ListTrashedBucketItems
+// @Description  Returns a list of the trashed items of a bucket
+// @Tags         BucketItem
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /items/{bucketUID}/trash [get]
+func (h *BucketItemHandler) ListTrashedBucketItems(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	bucketItems, err := h.bucketItemSvc.ListTrashedBucketItems(c.Request().Context(), bucketUID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: fmt.Sprintf("Successfully fetched %d trashed bucket items!", len(bucketItems)),
+		Data:    bucketItems,
+	})
+}
+
+// RestoreBucketItem  godoc
+// @Summary      RestoreBucketItem
+// @Description  Restore a trashed bucket item
+// @Tags         BucketItem
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        itemID path string true "Bucket item ID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /items/{bucketUID}/trash/{itemID}/restore [post]
+func (h *BucketItemHandler) RestoreBucketItem(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	// retrieve the bucket item ID
+	itemID := c.Param("itemID")
+	err := h.bucketItemSvc.RestoreBucketItem(c.Request().Context(), bucketUID, itemID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully restored bucket item!",
+	})
+}
+
+// PermanentlyDeleteBucketItem  godoc
+// @Summary      PermanentlyDeleteBucketItem
+// @Description  Permanently delete a bucket item, whether it is in the trash or not (admin only)
+// @Tags         Admin
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        itemID path string true "Bucket item ID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      403  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /admin/item/{bucketUID}/{itemID} [delete]
+func (h *BucketItemHandler) PermanentlyDeleteBucketItem(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	// retrieve the bucket item ID
+	itemID := c.Param("itemID")
+	err := h.bucketItemSvc.PermanentlyDeleteBucketItem(c.Request().Context(), bucketUID, itemID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
-		Message: fmt.Sprintf("Successfully deleted '%s'", key),
+		Message: "Successfully deleted bucket item permanently!",
 	})
 }
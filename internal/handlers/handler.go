@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"keeper/internal/config"
+	"keeper/internal/models"
+	"net/http"
 
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -12,6 +19,10 @@ type Handler struct {
 	AuthHandler         IAuthHandler
 	BucketHandler       IBucketHandler
 	BucketItemHandler   IBucketItemHandler
+	WebhookHandler      IWebhookHandler
+	BucketChangeHandler IBucketChangeHandler
+	AuditHandler        IAuditHandler
+	UsageHandler        IUsageHandler
 	PublicRoutesHandler IPublicRoutesHandler
 }
 
@@ -22,7 +33,43 @@ func NewHandler(cfg *config.Config, dbClient *mongo.Client) *Handler {
 		AuthHandler:         NewAuthHandler(cfg, dbClient),
 		BucketHandler:       NewBucketHandler(cfg, dbClient),
 		BucketItemHandler:   NewBucketItemHandler(cfg, dbClient),
-		PublicRoutesHandler: NewPublicRoutesHandler(),
+		WebhookHandler:      NewWebhookHandler(cfg, dbClient),
+		BucketChangeHandler: NewBucketChangeHandler(cfg, dbClient),
+		AuditHandler:        NewAuditHandler(cfg, dbClient),
+		UsageHandler:        NewUsageHandler(cfg, dbClient),
+		PublicRoutesHandler: NewPublicRoutesHandler(cfg),
 	}
 	return h
 }
+
+// Renders the errors returned by the handlers and middlewares as problem+json responses,
+// the models errors are given their status and code, the other errors are internal errors
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	var apiErr *models.APIError
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		// errors of echo itself, e.g. an unknown route or a malformed body
+		apiErr = models.NewAPIError(httpErr.Code, errors.New(fmt.Sprint(httpErr.Message)))
+	} else if errors.Is(c.Request().Context().Err(), context.DeadlineExceeded) {
+		// the timeout of the route expired, whatever the error the services made of it
+		apiErr = models.NewAPIError(http.StatusGatewayTimeout, models.ErrRequestTimeout)
+	} else {
+		apiErr = models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	if apiErr.Status >= http.StatusInternalServerError {
+		logrus.WithContext(c.Request().Context()).WithError(err).Errorf("error handling %s %s", c.Request().Method, c.Request().URL.Path)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, models.MIMEProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.Status)
+	} else {
+		err = c.JSON(apiErr.Status, apiErr.Problem(c.Request().URL.Path))
+	}
+	if err != nil {
+		logrus.WithContext(c.Request().Context()).WithError(err).Error("error writing the error response")
+	}
+}
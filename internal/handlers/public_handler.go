@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"keeper/internal/auth/jwt"
+	"keeper/internal/config"
 	"keeper/internal/models"
 	"net/http"
 
@@ -11,13 +13,15 @@ type IPublicRoutesHandler interface {
 	HealthCheck(c echo.Context) error
 	GetAPIKeyPermissionsList(c echo.Context) error
 	GetBucketPermissionsList(c echo.Context) error
+	GetJWKS(c echo.Context) error
 }
 
 type PublicRoutesHandler struct {
+	cfg *config.Config
 }
 
-func NewPublicRoutesHandler() IPublicRoutesHandler {
-	return &PublicRoutesHandler{}
+func NewPublicRoutesHandler(cfg *config.Config) IPublicRoutesHandler {
+	return &PublicRoutesHandler{cfg: cfg}
 }
 
 // HealthCheck godoc
@@ -26,7 +30,7 @@ func NewPublicRoutesHandler() IPublicRoutesHandler {
 // @Tags         PublicRoutes
 // @Produce      json
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.Problem
 // @Router /public/healthcheck [get]
 func (h *PublicRoutesHandler) HealthCheck(c echo.Context) error {
 	return c.String(http.StatusOK, "Server is healthy!")
@@ -38,7 +42,7 @@ func (h *PublicRoutesHandler) HealthCheck(c echo.Context) error {
 // @Tags         PublicRoutes
 // @Produce      json
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.Problem
 // @Router /public/apikey-permissions [get]
 func (h *PublicRoutesHandler) GetAPIKeyPermissionsList(c echo.Context) error {
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
@@ -54,7 +58,7 @@ func (h *PublicRoutesHandler) GetAPIKeyPermissionsList(c echo.Context) error {
 // @Tags         PublicRoutes
 // @Produce      json
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.Problem
 // @Router /public/bucket-permissions [get]
 func (h *PublicRoutesHandler) GetBucketPermissionsList(c echo.Context) error {
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
@@ -63,3 +67,25 @@ func (h *PublicRoutesHandler) GetBucketPermissionsList(c echo.Context) error {
 		Data:    models.BUCKET_PERMISSIONS,
 	})
 }
+
+// GetJWKS godoc
+// @Summary      GetJWKS
+// @Description  Returns the public keys verifying the tokens as a JSON Web Key Set, empty when the tokens are signed with HS256 secrets
+// @Tags         PublicRoutes
+// @Produce      json
+// @Success      200  {object} 	jwt.JWKS
+// @Failure      500  {object}  models.Problem
+// @Router /.well-known/jwks.json [get]
+func (h *PublicRoutesHandler) GetJWKS(c echo.Context) error {
+	keySet, err := jwt.Keys(h.cfg)
+	if err != nil {
+		return err
+	}
+	jwks := jwt.JWKS{Keys: []jwt.JWK{}}
+	if keySet != nil {
+		jwks = keySet.JWKS()
+	}
+	// the verifiers may cache the keys for five minutes
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, jwks)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/repository"
+	"keeper/internal/services"
+	"keeper/internal/validators"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UsageHandler struct {
+	usageSvc  services.IUsageService
+	validator validators.IValidator
+}
+
+type IUsageHandler interface {
+	GetUserUsage(c echo.Context) error
+	UpdateUserQuota(c echo.Context) error
+}
+
+func NewUsageHandler(cfg *config.Config, dbClient *mongo.Client) IUsageHandler {
+	usageRepo := repository.NewUsageRepository(cfg, dbClient)
+	userRepo := repository.NewUserRepository(cfg, dbClient)
+	usageService := services.NewUsageService(cfg, usageRepo, userRepo)
+	return &UsageHandler{
+		usageSvc:  usageService,
+		validator: validators.NewValidator(),
+	}
+}
+
+// GetUserUsage  godoc
+// @Summary      GetUserUsage
+// @Description  Get the storage used by the authenticated user and each of its buckets, with its quota
+// @Tags         Usage
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse{data=dto.UserUsageOutputDTO}
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /user/usage [get]
+func (h *UsageHandler) GetUserUsage(c echo.Context) error {
+	user := c.Get("user").(*models.User)
+	usage, err := h.usageSvc.GetUserUsage(c.Request().Context(), user.ID.Hex())
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully fetched usage!",
+		Data:    usage,
+	})
+}
+
+// UpdateUserQuota  godoc
+// @Summary      UpdateUserQuota
+// @Description  Override the quota of a user (admin only), the limits left at 0 are the defaults and the negative ones are unlimited
+// @Tags         Usage
+// @Accept       json
+// @Produce      json
+// @Param        data body dto.UpdateUserQuotaInputDTO true "User Quota"
+// @Param        userId path string true "User ID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      403  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /admin/user/{userId}/quota [put]
+func (h *UsageHandler) UpdateUserQuota(c echo.Context) error {
+	userId := c.Param("userId")
+	data := new(dto.UpdateUserQuotaInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+	if err := h.usageSvc.UpdateUserQuota(c.Request().Context(), userId, *data); err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully updated user quota!",
+	})
+}
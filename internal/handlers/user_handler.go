@@ -9,6 +9,7 @@ import (
 	"keeper/internal/validators"
 	"net/http"
 
+	"github.com/hibiken/asynq"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -19,6 +20,8 @@ type IUserHandler interface {
 	UpdateUser(c echo.Context) error
 	UpdateUserPassword(c echo.Context) error
 	DeleteUser(c echo.Context) error
+	GetUserDeletionStatus(c echo.Context) error
+	CancelUserDeletion(c echo.Context) error
 	VerifyEmail(c echo.Context) error
 }
 
@@ -29,7 +32,16 @@ type UserHandler struct {
 
 func NewUserHandler(cfg *config.Config, dbClient *mongo.Client) IUserHandler {
 	userRepo := repository.NewUserRepository(cfg, dbClient)
-	userService := services.NewUserService(cfg, userRepo)
+	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
+	bucketItemRepo := repository.NewBucketItemRepository(cfg, dbClient)
+	bucketSnapshotRepo := repository.NewBucketSnapshotRepository(cfg, dbClient)
+	apiKeyRepo := repository.NewAPIKeyRepository(cfg, dbClient)
+	twoFactorRepo := repository.NewTwoFactorRepository(cfg, dbClient)
+	sessionRepo := repository.NewSessionRepository(cfg, dbClient)
+	usageRepo := repository.NewUsageRepository(cfg, dbClient)
+	loginAttemptRepo := repository.NewLoginAttemptRepository(cfg, dbClient)
+	webhookRepo := repository.NewWebhookRepository(cfg, dbClient)
+	userService := services.NewUserService(cfg, userRepo, bucketRepo, bucketItemRepo, bucketSnapshotRepo, apiKeyRepo, twoFactorRepo, sessionRepo, usageRepo, loginAttemptRepo, webhookRepo)
 	return &UserHandler{
 		userSvc:   userService,
 		validator: validators.NewValidator(),
@@ -38,12 +50,12 @@ func NewUserHandler(cfg *config.Config, dbClient *mongo.Client) IUserHandler {
 
 func (h *UserHandler) GetUserByID(c echo.Context) error {
 	userId := c.Param("userId")
-	user, err := h.userSvc.FindUserByID(userId)
+	user, err := h.userSvc.FindUserByID(c.Request().Context(), userId)
 	if err != nil {
-		return c.JSON(400, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	if user == nil {
-		return c.JSON(http.StatusNotFound, &models.ErrorResponse{Status: false, Error: "user not found"})
+		return models.NewAPIError(http.StatusNotFound, models.ErrUserNotFound)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -53,9 +65,9 @@ func (h *UserHandler) GetUserByID(c echo.Context) error {
 }
 
 func (h *UserHandler) GetAllUsers(c echo.Context) error {
-	users, err := h.userSvc.FindAllUsers()
+	users, err := h.userSvc.FindAllUsers(c.Request().Context())
 	if err != nil {
-		return c.JSON(400, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -72,23 +84,23 @@ func (h *UserHandler) GetAllUsers(c echo.Context) error {
 // @Produce      json
 // @Param        data body dto.VerifyEmailInputDTO true "Email Verification Data"
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /user/verify-email [post]
 func (h *UserHandler) VerifyEmail(c echo.Context) error {
 	data := new(dto.VerifyEmailInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 
-	err := h.userSvc.VerifyEmail(*data)
+	err := h.userSvc.VerifyEmail(c.Request().Context(), *data)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Email verification successful!"})
 }
@@ -101,54 +113,56 @@ func (h *UserHandler) VerifyEmail(c echo.Context) error {
 // @Param        data body dto.UpdateUserInputDTO true "Update User Data"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /user [put]
 func (h *UserHandler) UpdateUser(c echo.Context) error {
 	data := new(dto.UpdateUserInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// retrieve user from context
 	user := c.Get("user").(*models.User)
-	err := h.userSvc.UpdateUser(user.ID.Hex(), *data)
+	err := h.userSvc.UpdateUser(c.Request().Context(), user.ID.Hex(), *data)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully updated user!"})
 }
 
 // UpdateUserPassword godoc
 // @Summary      UpdateUserPassword
-// @Description  Update a user's password
+// @Description  Update a user's password, the other sessions of the user are revoked
 // @Tags         User
 // @Produce      json
 // @Param        data body dto.UpdateUserPasswordInputDTO true "Update User Password Data"
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /user/password [put]
 func (h *UserHandler) UpdateUserPassword(c echo.Context) error {
 	data := new(dto.UpdateUserPasswordInputDTO)
 	if err := c.Bind(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// validate the request data
 	if err := h.validator.Validate(data); err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return err
 	}
 	// retrieve user from context
 	user := c.Get("user").(*models.User)
-	err := h.userSvc.UpdateUserPassword(user.ID.Hex(), *data)
+	sessionID, _ := c.Get("session_id").(string)
+	err := h.userSvc.UpdateUserPassword(c.Request().Context(), user.ID.Hex(), *data, sessionID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
@@ -158,24 +172,83 @@ func (h *UserHandler) UpdateUserPassword(c echo.Context) error {
 
 // DeleteUser godoc
 // @Summary      DeleteUser
-// @Description  Delete a user's account
+// @Description  Delete a user's account along with the user's buckets, items and API keys
+// @Description  the deletion runs in the background and can be cancelled during the grace period
 // @Tags         User
 // @Produce      json
 // @Security     BearerAuth
 // @Success      200  {object} 	models.SuccessResponse
-// @Failure      400  {object} 	models.ErrorResponse
-// @Failure      404  {object}  models.ErrorResponse
-// @Failure      500  {object}  models.ErrorResponse
+// @Success      202  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router /user [delete]
 func (h *UserHandler) DeleteUser(c echo.Context) error {
 	// retrieve user from context
 	user := c.Get("user").(*models.User)
-	err := h.userSvc.DeleteUser(user.ID.Hex())
+	status, err := h.userSvc.DeleteUser(c.Request().Context(), user.ID.Hex())
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, &models.ErrorResponse{Status: false, Error: err.Error()})
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	if status.State == asynq.TaskStateCompleted.String() {
+		return c.JSON(http.StatusOK, &models.SuccessResponse{
+			Status:  true,
+			Message: "Successfully deleted user!",
+			Data:    status,
+		})
+	}
+	return c.JSON(http.StatusAccepted, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully scheduled user deletion!",
+		Data:    status,
+	})
+}
+
+// GetUserDeletionStatus godoc
+// @Summary      GetUserDeletionStatus
+// @Description  Get the status and progress of a user's account deletion
+// @Tags         User
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /user/deletion [get]
+func (h *UserHandler) GetUserDeletionStatus(c echo.Context) error {
+	// retrieve user from context
+	user := c.Get("user").(*models.User)
+	status, err := h.userSvc.GetUserDeletionStatus(c.Request().Context(), user.ID.Hex())
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully fetched user deletion status!",
+		Data:    status,
+	})
+}
+
+// CancelUserDeletion godoc
+// @Summary      CancelUserDeletion
+// @Description  Cancel a user's account deletion that has not started yet
+// @Tags         User
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /user/deletion [delete]
+func (h *UserHandler) CancelUserDeletion(c echo.Context) error {
+	// retrieve user from context
+	user := c.Get("user").(*models.User)
+	err := h.userSvc.CancelUserDeletion(c.Request().Context(), user.ID.Hex())
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
 	}
 	return c.JSON(http.StatusOK, &models.SuccessResponse{
 		Status:  true,
-		Message: "Successfully deleted user!",
+		Message: "Successfully cancelled user deletion!",
 	})
 }
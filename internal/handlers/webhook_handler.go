@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"fmt"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/repository"
+	"keeper/internal/services"
+	"keeper/internal/validators"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type WebhookHandler struct {
+	webhookSvc services.IWebhookService
+	validator  validators.IValidator
+}
+
+type IWebhookHandler interface {
+	CreateWebhook(c echo.Context) error
+	ListWebhooks(c echo.Context) error
+	DeleteWebhook(c echo.Context) error
+	ListWebhookDeliveries(c echo.Context) error
+	ReplayWebhookDelivery(c echo.Context) error
+	SendTestEvent(c echo.Context) error
+}
+
+func NewWebhookHandler(cfg *config.Config, dbClient *mongo.Client) IWebhookHandler {
+	webhookRepo := repository.NewWebhookRepository(cfg, dbClient)
+	webhookDeliveryRepo := repository.NewWebhookDeliveryRepository(cfg, dbClient)
+	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
+	webhookService := services.NewWebhookService(cfg, webhookRepo, webhookDeliveryRepo, bucketRepo)
+	return &WebhookHandler{
+		webhookSvc: webhookService,
+		validator:  validators.NewValidator(),
+	}
+}
+
+// CreateWebhook  godoc
+// @Summary      CreateWebhook
+// @Description  Register a webhook notified of the item events of a bucket, the signing secret is only returned once
+// @Tags         Webhook
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        data body dto.CreateWebhookInputDTO true "Create Webhook Data"
+// @Security     BearerAuth
+// @Success      201  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c echo.Context) error {
+	// retrieve the user from context
+	user := c.Get("user").(*models.User)
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	data := new(dto.CreateWebhookInputDTO)
+	if err := c.Bind(data); err != nil {
+		return err
+	}
+	// validate the request data
+	if err := h.validator.Validate(data); err != nil {
+		return err
+	}
+	resp, err := h.webhookSvc.CreateWebhook(c.Request().Context(), bucketUID, *data, user.ID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusCreated, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully created webhook!",
+		Data:    resp,
+	})
+}
+
+// ListWebhooks  godoc
+// @Summary      ListWebhooks
+// @Description  List the webhooks of a bucket
+// @Tags         Webhook
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c echo.Context) error {
+	// retrieve the bucket UID
+	bucketUID := c.Param("bucketUID")
+	webhooks, err := h.webhookSvc.ListWebhooks(c.Request().Context(), bucketUID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: fmt.Sprintf("Successfully fetched %d webhooks!", len(webhooks)),
+		Data:    webhooks,
+	})
+}
+
+// DeleteWebhook  godoc
+// @Summary      DeleteWebhook
+// @Description  Delete a webhook along with its delivery log
+// @Tags         Webhook
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        webhookID path string true "Webhook ID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/webhooks/{webhookID} [delete]
+func (h *WebhookHandler) DeleteWebhook(c echo.Context) error {
+	// retrieve the bucket UID and webhook ID
+	bucketUID := c.Param("bucketUID")
+	webhookID := c.Param("webhookID")
+	err := h.webhookSvc.DeleteWebhook(c.Request().Context(), bucketUID, webhookID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{Status: true, Message: "Successfully deleted webhook!"})
+}
+
+// ListWebhookDeliveries  godoc
+// @Summary      ListWebhookDeliveries
+// @Description  Returns the delivery log of a webhook, most recent first
+// @Tags         Webhook
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        webhookID path string true "Webhook ID"
+// @Security     BearerAuth
+// @Success      200  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/webhooks/{webhookID}/deliveries [get]
+func (h *WebhookHandler) ListWebhookDeliveries(c echo.Context) error {
+	// retrieve the bucket UID and webhook ID
+	bucketUID := c.Param("bucketUID")
+	webhookID := c.Param("webhookID")
+	deliveries, err := h.webhookSvc.ListWebhookDeliveries(c.Request().Context(), bucketUID, webhookID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusOK, &models.SuccessResponse{
+		Status:  true,
+		Message: fmt.Sprintf("Successfully fetched %d webhook deliveries!", len(deliveries)),
+		Data:    deliveries,
+	})
+}
+
+// ReplayWebhookDelivery  godoc
+// @Summary      ReplayWebhookDelivery
+// @Description  Send the payload of a past delivery again as a new delivery
+// @Tags         Webhook
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        webhookID path string true "Webhook ID"
+// @Param        deliveryID path string true "Delivery ID"
+// @Security     BearerAuth
+// @Success      202  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/webhooks/{webhookID}/deliveries/{deliveryID}/replay [post]
+func (h *WebhookHandler) ReplayWebhookDelivery(c echo.Context) error {
+	// retrieve the bucket UID, webhook ID and delivery ID
+	bucketUID := c.Param("bucketUID")
+	webhookID := c.Param("webhookID")
+	deliveryID := c.Param("deliveryID")
+	delivery, err := h.webhookSvc.ReplayWebhookDelivery(c.Request().Context(), bucketUID, webhookID, deliveryID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusAccepted, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully replayed webhook delivery!",
+		Data:    delivery,
+	})
+}
+
+// SendTestEvent  godoc
+// @Summary      SendTestEvent
+// @Description  Send a test event to a webhook
+// @Tags         Webhook
+// @Produce      json
+// @Param        bucketUID path string true "Bucket UID"
+// @Param        webhookID path string true "Webhook ID"
+// @Security     BearerAuth
+// @Success      202  {object} 	models.SuccessResponse
+// @Failure      400  {object} 	models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router /bucket/{bucketUID}/webhooks/{webhookID}/test [post]
+func (h *WebhookHandler) SendTestEvent(c echo.Context) error {
+	// retrieve the bucket UID and webhook ID
+	bucketUID := c.Param("bucketUID")
+	webhookID := c.Param("webhookID")
+	delivery, err := h.webhookSvc.SendTestEvent(c.Request().Context(), bucketUID, webhookID)
+	if err != nil {
+		return models.ToAPIError(err, http.StatusInternalServerError)
+	}
+	return c.JSON(http.StatusAccepted, &models.SuccessResponse{
+		Status:  true,
+		Message: "Successfully sent webhook test event!",
+		Data:    delivery,
+	})
+}
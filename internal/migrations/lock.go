@@ -0,0 +1,119 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	migrationLockCollectionName = "migrationlocks"
+	migrationLockID             = "migrations"
+	// how long the lock is held without being renewed, a server that stops while migrating gives it up after it
+	migrationLockLease = time.Minute
+	// how often a server waiting for the lock tries to take it
+	migrationLockRetryInterval = time.Second
+)
+
+// Lock held by the server migrating the database
+type migrationLock struct {
+	ID        string             `bson:"_id"`
+	Owner     string             `bson:"owner"`
+	ExpiresAt primitive.DateTime `bson:"expires_at"`
+}
+
+// Takes the migration lock, waiting until the server holding it releases it or its lease runs out
+// the lease is renewed until the returned release function is called
+func acquireLock(ctx context.Context, db *mongo.Database) (func(), error) {
+	collection := db.Collection(migrationLockCollectionName)
+	owner := primitive.NewObjectID().Hex()
+	for waiting := false; ; waiting = true {
+		acquired, err := tryLock(ctx, collection, owner)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		if !waiting {
+			logrus.Info("waiting for another server to finish migrating the database")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error waiting for the migration lock: %w", ctx.Err())
+		case <-time.After(migrationLockRetryInterval):
+		}
+	}
+
+	renewCtx, stop := context.WithCancel(context.Background())
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(migrationLockLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				if err := renewLock(renewCtx, collection, owner); err != nil {
+					logrus.WithError(err).Error("error renewing the migration lock")
+				}
+			}
+		}
+	}()
+	return func() {
+		stop()
+		<-renewed
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		filter := bson.D{primitive.E{Key: "_id", Value: migrationLockID}, primitive.E{Key: "owner", Value: owner}}
+		if _, err := collection.DeleteOne(releaseCtx, filter); err != nil {
+			logrus.WithError(err).Error("error releasing the migration lock")
+		}
+	}, nil
+}
+
+// Takes the lock if it is free or its lease has run out
+// Returns whether the lock was taken and an error
+func tryLock(ctx context.Context, collection *mongo.Collection, owner string) (bool, error) {
+	now := time.Now()
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: migrationLockID},
+		primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$lt", Value: primitive.NewDateTimeFromTime(now)}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "owner", Value: owner},
+		primitive.E{Key: "expires_at", Value: primitive.NewDateTimeFromTime(now.Add(migrationLockLease))},
+	}}}
+	// a lock that is held does not match, and the upsert then conflicts with it
+	if _, err := collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error taking the migration lock: %w", err)
+	}
+	return true, nil
+}
+
+// Extends the lease of a lock that is still held by its owner
+func renewLock(ctx context.Context, collection *mongo.Collection, owner string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: migrationLockID}, primitive.E{Key: "owner", Value: owner}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "expires_at", Value: primitive.NewDateTimeFromTime(time.Now().Add(migrationLockLease))},
+	}}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("the migration lock was taken over after its lease ran out")
+	}
+	return nil
+}
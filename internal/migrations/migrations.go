@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	migrationCollectionName = "migrations"
+)
+
+// A versioned change to the database schema or data
+// the servers migrate one at a time, migrations must still be safe to apply more than once
+// as a server that stops midway leaves its migration unrecorded
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// Applied migration, as recorded in the database
+type AppliedMigration struct {
+	Version     int                `bson:"_id" json:"version"`
+	Description string             `bson:"description" json:"description"`
+	AppliedAt   primitive.DateTime `bson:"applied_at" json:"applied_at"`
+}
+
+// State of a migration
+type MigrationStatus struct {
+	Version     int                `json:"version"`
+	Description string             `json:"description"`
+	Applied     bool               `json:"applied"`
+	AppliedAt   primitive.DateTime `json:"applied_at,omitempty"`
+}
+
+// Returns the migrations in the order they are applied
+func All() []Migration {
+	all := make([]Migration, len(migrations))
+	copy(all, migrations)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// Applies the migrations that have not been applied yet, in the order of their versions
+// the migration lock is held meanwhile, a server starting at the same time waits for it
+// Returns the applied migrations and an error
+func Run(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	release, err := acquireLock(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	appliedMigrations, err := findApplied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	applied := []Migration{}
+	for _, m := range All() {
+		if _, ok := appliedMigrations[m.Version]; ok {
+			continue
+		}
+		logrus.Infof("applying migration %d: %s", m.Version, m.Description)
+		if err := m.Up(ctx, db); err != nil {
+			logrus.WithError(err).Errorf("failed to apply migration %d", m.Version)
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		if err := record(ctx, db, m); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// Returns the state of every migration
+func Status(ctx context.Context, db *mongo.Database) ([]MigrationStatus, error) {
+	appliedMigrations, err := findApplied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []MigrationStatus{}
+	for _, m := range All() {
+		status := MigrationStatus{Version: m.Version, Description: m.Description}
+		if applied, ok := appliedMigrations[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = applied.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Returns the applied migrations by version
+func findApplied(ctx context.Context, db *mongo.Database) (map[int]AppliedMigration, error) {
+	cursor, err := db.Collection(migrationCollectionName).Find(ctx, bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("error finding applied migrations: %w", err)
+	}
+	appliedMigrations := []AppliedMigration{}
+	if err := cursor.All(ctx, &appliedMigrations); err != nil {
+		return nil, fmt.Errorf("error finding applied migrations: %w", err)
+	}
+	byVersion := make(map[int]AppliedMigration, len(appliedMigrations))
+	for _, m := range appliedMigrations {
+		byVersion[m.Version] = m
+	}
+	return byVersion, nil
+}
+
+// Records a migration as applied
+func record(ctx context.Context, db *mongo.Database, m Migration) error {
+	applied := AppliedMigration{
+		Version:     m.Version,
+		Description: m.Description,
+		AppliedAt:   primitive.NewDateTimeFromTime(time.Now()),
+	}
+	// another server may have applied the migration concurrently
+	if _, err := db.Collection(migrationCollectionName).InsertOne(ctx, applied); err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("error recording migration %d: %w", m.Version, err)
+	}
+	return nil
+}
+
+// Returns a migration applying steps one after the other
+func steps(up ...func(ctx context.Context, db *mongo.Database) error) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, step := range up {
+			if err := step(ctx, db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Returns a migration step creating indexes on collections, creating an existing index does nothing
+func createIndexes(indexes map[string][]mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		collections := make([]string, 0, len(indexes))
+		for collection := range indexes {
+			collections = append(collections, collection)
+		}
+		sort.Strings(collections)
+		for _, collection := range collections {
+			if _, err := db.Collection(collection).Indexes().CreateMany(ctx, indexes[collection]); err != nil {
+				if mongo.IsDuplicateKeyError(err) {
+					return fmt.Errorf("%s holds duplicates of a unique index, remove them and migrate again: %w", collection, err)
+				}
+				return fmt.Errorf("error creating indexes on %s: %w", collection, err)
+			}
+		}
+		return nil
+	}
+}
+
+// Returns an ascending index on the fields, a field prefixed with '-' is descending
+func index(fields ...string) mongo.IndexModel {
+	keys := bson.D{}
+	for _, field := range fields {
+		order := 1
+		if field[0] == '-' {
+			field, order = field[1:], -1
+		}
+		keys = append(keys, primitive.E{Key: field, Value: order})
+	}
+	return mongo.IndexModel{Keys: keys}
+}
+
+// Returns a unique index on the fields
+func uniqueIndex(fields ...string) mongo.IndexModel {
+	model := index(fields...)
+	model.Options = options.Index().SetUnique(true)
+	return model
+}
+
+// Returns an index removing the documents once the date of the field has passed
+func expiryIndex(field string) mongo.IndexModel {
+	model := index(field)
+	model.Options = options.Index().SetExpireAfterSeconds(0)
+	return model
+}
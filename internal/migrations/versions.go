@@ -0,0 +1,288 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrations of the database, a released migration must never change, add a new version instead
+// the collection names are spelled out so that a migration does not depend on the current code
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create lookup indexes",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"apikeys": {
+				index("mask_id"),
+				index("hash"),
+				index("user_id"),
+			},
+			"buckets": {
+				index("user_id"),
+			},
+			"bucketitems": {
+				index("bucket_uid"),
+			},
+			"bucketchanges": {
+				index("bucket_uid", "seq"),
+			},
+			"webhooks": {
+				index("bucket_uid"),
+			},
+			"webhookdeliveries": {
+				index("webhook_id", "-created_at"),
+			},
+			"bucketsnapshots": {
+				index("bucket_uid"),
+			},
+		}),
+	},
+	{
+		Version:     2,
+		Description: "move duplicated bucket item keys to the trash",
+		Up:          trashDuplicatedBucketItems,
+	},
+	{
+		Version:     3,
+		Description: "create unique indexes on user emails, bucket uids and bucket item keys",
+		// the duplicates are set apart before the indexes are built, the steps only run where the indexes do not exist yet
+		Up: steps(renameDuplicatedUserEmails, renameDuplicatedBucketUIDs, createIndexes(map[string][]mongo.IndexModel{
+			"users": {
+				uniqueIndex("email"),
+			},
+			"buckets": {
+				uniqueIndex("uid"),
+			},
+			// trashed items keep their key, their time of deletion tells them apart
+			"bucketitems": {
+				uniqueIndex("bucket_uid", "key", "deleted_at"),
+			},
+			"bucketsnapshots": {
+				uniqueIndex("bucket_uid", "name"),
+			},
+		})),
+	},
+	{
+		Version:     4,
+		Description: "create audit log indexes",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"auditevents": {
+				index("user_id", "-created_at"),
+				index("bucket_uid", "-created_at"),
+			},
+		}),
+	},
+	{
+		Version:     5,
+		Description: "create usage indexes",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"usages": {
+				index("user_id", "-bytes"),
+			},
+		}),
+	},
+	{
+		Version:     6,
+		Description: "expire login attempts",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"loginattempts": {
+				expiryIndex("expires_at"),
+			},
+		}),
+	},
+	{
+		Version:     7,
+		Description: "create session indexes",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"sessions": {
+				index("user_id", "-last_used_at"),
+				expiryIndex("expires_at"),
+			},
+		}),
+	},
+	{
+		Version:     8,
+		Description: "make the bucket change sequence numbers unique",
+		Up:          uniqueBucketChangeSeq,
+	},
+	{
+		Version:     9,
+		Description: "expire bucket changes",
+		Up: steps(expireRecordedBucketChanges, createIndexes(map[string][]mongo.IndexModel{
+			"bucketchanges": {
+				expiryIndex("expires_at"),
+			},
+		})),
+	},
+}
+
+// Keeps the most recently updated of the bucket items sharing a key, the others are moved to the trash
+// so that the unique index on the keys can be created, trashed items are purged with the rest of the trash
+func trashDuplicatedBucketItems(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("bucketitems")
+	pipeline := mongo.Pipeline{
+		bson.D{primitive.E{Key: "$sort", Value: bson.D{
+			primitive.E{Key: "updated_at", Value: -1},
+			primitive.E{Key: "_id", Value: -1},
+		}}},
+		bson.D{primitive.E{Key: "$group", Value: bson.D{
+			primitive.E{Key: "_id", Value: bson.D{
+				primitive.E{Key: "bucket_uid", Value: "$bucket_uid"},
+				primitive.E{Key: "key", Value: "$key"},
+				primitive.E{Key: "deleted_at", Value: "$deleted_at"},
+			}},
+			primitive.E{Key: "ids", Value: bson.D{primitive.E{Key: "$push", Value: "$_id"}}},
+			primitive.E{Key: "count", Value: bson.D{primitive.E{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{primitive.E{Key: "$match", Value: bson.D{primitive.E{Key: "count", Value: bson.D{primitive.E{Key: "$gt", Value: 1}}}}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("error finding duplicated bucket items: %w", err)
+	}
+	duplicates := []struct {
+		ID struct {
+			BucketUID string             `bson:"bucket_uid"`
+			Key       string             `bson:"key"`
+			DeletedAt primitive.DateTime `bson:"deleted_at"`
+		} `bson:"_id"`
+		IDs []primitive.ObjectID `bson:"ids"`
+	}{}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return fmt.Errorf("error finding duplicated bucket items: %w", err)
+	}
+
+	now := time.Now()
+	for _, duplicate := range duplicates {
+		deletedAt := now
+		if duplicate.ID.DeletedAt != 0 {
+			deletedAt = duplicate.ID.DeletedAt.Time()
+		}
+		// every duplicate gets its own time of deletion to satisfy the unique index
+		for i, id := range duplicate.IDs[1:] {
+			at := primitive.NewDateTimeFromTime(deletedAt.Add(-time.Duration(i+1) * time.Millisecond))
+			update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "deleted_at", Value: at}}}}
+			if _, err := collection.UpdateByID(ctx, id, update); err != nil {
+				return fmt.Errorf("error trashing duplicated bucket item %s: %w", id.Hex(), err)
+			}
+		}
+		logrus.Warnf("moved %d duplicates of key '%s' of bucket %s to the trash", len(duplicate.IDs)-1, duplicate.ID.Key, duplicate.ID.BucketUID)
+	}
+	return nil
+}
+
+// Values of a field shared by several documents
+type duplicatedValue struct {
+	Value string               `bson:"_id"`
+	IDs   []primitive.ObjectID `bson:"ids"` // the documents holding the value, oldest first
+}
+
+// Returns the values of a field shared by several documents of a collection
+func findDuplicatedValues(ctx context.Context, collection *mongo.Collection, field string) ([]duplicatedValue, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{primitive.E{Key: "$sort", Value: bson.D{primitive.E{Key: "_id", Value: 1}}}},
+		bson.D{primitive.E{Key: "$group", Value: bson.D{
+			primitive.E{Key: "_id", Value: "$" + field},
+			primitive.E{Key: "ids", Value: bson.D{primitive.E{Key: "$push", Value: "$_id"}}},
+			primitive.E{Key: "count", Value: bson.D{primitive.E{Key: "$sum", Value: 1}}},
+		}}},
+		bson.D{primitive.E{Key: "$match", Value: bson.D{primitive.E{Key: "count", Value: bson.D{primitive.E{Key: "$gt", Value: 1}}}}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("error finding duplicated %s of %s: %w", field, collection.Name(), err)
+	}
+	duplicates := []duplicatedValue{}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return nil, fmt.Errorf("error finding duplicated %s of %s: %w", field, collection.Name(), err)
+	}
+	return duplicates, nil
+}
+
+// Keeps the email of the oldest of the users sharing it, the others get a placeholder email
+// so that the unique index on the emails can be created, they are reported to be merged or deleted by hand
+func renameDuplicatedUserEmails(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("users")
+	duplicates, err := findDuplicatedValues(ctx, collection, "email")
+	if err != nil {
+		return err
+	}
+	for _, duplicate := range duplicates {
+		for _, id := range duplicate.IDs[1:] {
+			email := fmt.Sprintf("duplicate-%s-%s", id.Hex(), duplicate.Value)
+			update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "email", Value: email}}}}
+			if _, err := collection.UpdateByID(ctx, id, update); err != nil {
+				return fmt.Errorf("error renaming the email of duplicated user %s: %w", id.Hex(), err)
+			}
+			logrus.Warnf("user %s shares the email '%s' with user %s, its email is now '%s'", id.Hex(), duplicate.Value, duplicate.IDs[0].Hex(), email)
+		}
+	}
+	return nil
+}
+
+// Keeps the uid of the oldest of the buckets sharing it, the others get a new uid along with their items
+// so that the unique index on the uids can be created, the rest of the data stored by uid stays with the oldest bucket
+func renameDuplicatedBucketUIDs(ctx context.Context, db *mongo.Database) error {
+	collection := db.Collection("buckets")
+	duplicates, err := findDuplicatedValues(ctx, collection, "uid")
+	if err != nil {
+		return err
+	}
+	for _, duplicate := range duplicates {
+		for _, id := range duplicate.IDs[1:] {
+			uid := uniuri.NewLen(16)
+			update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "uid", Value: uid}}}}
+			if _, err := collection.UpdateByID(ctx, id, update); err != nil {
+				return fmt.Errorf("error renaming the uid of duplicated bucket %s: %w", id.Hex(), err)
+			}
+			filter := bson.D{primitive.E{Key: "bucket_id", Value: id}}
+			update = bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "bucket_uid", Value: uid}}}}
+			if _, err := db.Collection("bucketitems").UpdateMany(ctx, filter, update); err != nil {
+				return fmt.Errorf("error moving the items of duplicated bucket %s: %w", id.Hex(), err)
+			}
+			logrus.Warnf("bucket %s shares the uid '%s' with bucket %s, its uid is now '%s'", id.Hex(), duplicate.Value, duplicate.IDs[0].Hex(), uid)
+		}
+	}
+	return nil
+}
+
+// retention of the bucket changes recorded before they expired, the default of BUCKET_CHANGE_RETENTION_DAYS
+const recordedBucketChangeRetention = 30 * 24 * time.Hour
+
+// Gives the bucket changes recorded before they expired the default retention, counted from their creation
+func expireRecordedBucketChanges(ctx context.Context, db *mongo.Database) error {
+	filter := bson.D{primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$exists", Value: false}}}}
+	update := mongo.Pipeline{bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "expires_at", Value: bson.D{
+		primitive.E{Key: "$add", Value: bson.A{"$created_at", recordedBucketChangeRetention.Milliseconds()}},
+	}}}}}}
+	result, err := db.Collection("bucketchanges").UpdateMany(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("error expiring the recorded bucket changes: %w", err)
+	}
+	if result.ModifiedCount > 0 {
+		logrus.Infof("%d recorded bucket changes expire %s after their creation", result.ModifiedCount, recordedBucketChangeRetention)
+	}
+	return nil
+}
+
+// Replaces the lookup index of the bucket changes by a unique one, a change is recorded
+// only once its sequence number is free, the index has the same name so the old one is dropped first
+func uniqueBucketChangeSeq(ctx context.Context, db *mongo.Database) error {
+	if _, err := db.Collection("bucketchanges").Indexes().DropOne(ctx, "bucket_uid_1_seq_1"); err != nil {
+		var commandErr mongo.CommandError
+		// the index or the collection does not exist yet
+		if !errors.As(err, &commandErr) || (commandErr.Name != "IndexNotFound" && commandErr.Name != "NamespaceNotFound") {
+			return fmt.Errorf("error dropping the bucket change index: %w", err)
+		}
+	}
+	return createIndexes(map[string][]mongo.IndexModel{"bucketchanges": {uniqueIndex("bucket_uid", "seq")}})(ctx, db)
+}
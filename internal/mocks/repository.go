@@ -5,9 +5,12 @@
 package mocks
 
 import (
+	context "context"
+	io "io"
 	models "keeper/internal/models"
 	utils "keeper/internal/utils"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	bson "go.mongodb.org/mongo-driver/bson"
@@ -39,90 +42,104 @@ func (m *MockIUserRepository) EXPECT() *MockIUserRepositoryMockRecorder {
 }
 
 // CreateUser mocks base method.
-func (m *MockIUserRepository) CreateUser(user *models.User) error {
+func (m *MockIUserRepository) CreateUser(ctx context.Context, user *models.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", user)
+	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateUser indicates an expected call of CreateUser.
-func (mr *MockIUserRepositoryMockRecorder) CreateUser(user interface{}) *gomock.Call {
+func (mr *MockIUserRepositoryMockRecorder) CreateUser(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockIUserRepository)(nil).CreateUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockIUserRepository)(nil).CreateUser), ctx, user)
 }
 
 // DeleteUser mocks base method.
-func (m *MockIUserRepository) DeleteUser(userId string) error {
+func (m *MockIUserRepository) DeleteUser(ctx context.Context, userId string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUser", userId)
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, userId)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteUser indicates an expected call of DeleteUser.
-func (mr *MockIUserRepositoryMockRecorder) DeleteUser(userId interface{}) *gomock.Call {
+func (mr *MockIUserRepositoryMockRecorder) DeleteUser(ctx, userId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockIUserRepository)(nil).DeleteUser), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockIUserRepository)(nil).DeleteUser), ctx, userId)
 }
 
 // FindAllUsers mocks base method.
-func (m *MockIUserRepository) FindAllUsers() ([]models.User, error) {
+func (m *MockIUserRepository) FindAllUsers(ctx context.Context) ([]models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindAllUsers")
+	ret := m.ctrl.Call(m, "FindAllUsers", ctx)
 	ret0, _ := ret[0].([]models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindAllUsers indicates an expected call of FindAllUsers.
-func (mr *MockIUserRepositoryMockRecorder) FindAllUsers() *gomock.Call {
+func (mr *MockIUserRepositoryMockRecorder) FindAllUsers(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllUsers", reflect.TypeOf((*MockIUserRepository)(nil).FindAllUsers))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllUsers", reflect.TypeOf((*MockIUserRepository)(nil).FindAllUsers), ctx)
 }
 
 // FindUserByEmail mocks base method.
-func (m *MockIUserRepository) FindUserByEmail(email string) (*models.User, error) {
+func (m *MockIUserRepository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindUserByEmail", email)
+	ret := m.ctrl.Call(m, "FindUserByEmail", ctx, email)
 	ret0, _ := ret[0].(*models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindUserByEmail indicates an expected call of FindUserByEmail.
-func (mr *MockIUserRepositoryMockRecorder) FindUserByEmail(email interface{}) *gomock.Call {
+func (mr *MockIUserRepositoryMockRecorder) FindUserByEmail(ctx, email interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserByEmail", reflect.TypeOf((*MockIUserRepository)(nil).FindUserByEmail), email)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserByEmail", reflect.TypeOf((*MockIUserRepository)(nil).FindUserByEmail), ctx, email)
 }
 
 // FindUserById mocks base method.
-func (m *MockIUserRepository) FindUserById(id string) (*models.User, error) {
+func (m *MockIUserRepository) FindUserById(ctx context.Context, id string) (*models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindUserById", id)
+	ret := m.ctrl.Call(m, "FindUserById", ctx, id)
 	ret0, _ := ret[0].(*models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindUserById indicates an expected call of FindUserById.
-func (mr *MockIUserRepositoryMockRecorder) FindUserById(id interface{}) *gomock.Call {
+func (mr *MockIUserRepositoryMockRecorder) FindUserById(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserById", reflect.TypeOf((*MockIUserRepository)(nil).FindUserById), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserById", reflect.TypeOf((*MockIUserRepository)(nil).FindUserById), ctx, id)
+}
+
+// SetUserTwoFactorEnabled mocks base method.
+func (m *MockIUserRepository) SetUserTwoFactorEnabled(ctx context.Context, userID primitive.ObjectID, enabled bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserTwoFactorEnabled", ctx, userID, enabled)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserTwoFactorEnabled indicates an expected call of SetUserTwoFactorEnabled.
+func (mr *MockIUserRepositoryMockRecorder) SetUserTwoFactorEnabled(ctx, userID, enabled interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserTwoFactorEnabled", reflect.TypeOf((*MockIUserRepository)(nil).SetUserTwoFactorEnabled), ctx, userID, enabled)
 }
 
 // UpdateUser mocks base method.
-func (m *MockIUserRepository) UpdateUser(user *models.User) error {
+func (m *MockIUserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", user)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateUser indicates an expected call of UpdateUser.
-func (mr *MockIUserRepositoryMockRecorder) UpdateUser(user interface{}) *gomock.Call {
+func (mr *MockIUserRepositoryMockRecorder) UpdateUser(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockIUserRepository)(nil).UpdateUser), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockIUserRepository)(nil).UpdateUser), ctx, user)
 }
 
 // MockIAPIKeyRepository is a mock of IAPIKeyRepository interface.
@@ -149,148 +166,178 @@ func (m *MockIAPIKeyRepository) EXPECT() *MockIAPIKeyRepositoryMockRecorder {
 }
 
 // CreateAPIKey mocks base method.
-func (m *MockIAPIKeyRepository) CreateAPIKey(apiKey *models.APIKey) (primitive.ObjectID, error) {
+func (m *MockIAPIKeyRepository) CreateAPIKey(ctx context.Context, apiKey *models.APIKey) (primitive.ObjectID, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateAPIKey", apiKey)
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, apiKey)
 	ret0, _ := ret[0].(primitive.ObjectID)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateAPIKey indicates an expected call of CreateAPIKey.
-func (mr *MockIAPIKeyRepositoryMockRecorder) CreateAPIKey(apiKey interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) CreateAPIKey(ctx, apiKey interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockIAPIKeyRepository)(nil).CreateAPIKey), apiKey)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockIAPIKeyRepository)(nil).CreateAPIKey), ctx, apiKey)
 }
 
 // DeleteAPIKey mocks base method.
-func (m *MockIAPIKeyRepository) DeleteAPIKey(apiKeyID string) error {
+func (m *MockIAPIKeyRepository) DeleteAPIKey(ctx context.Context, apiKeyID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAPIKey", apiKeyID)
+	ret := m.ctrl.Call(m, "DeleteAPIKey", ctx, apiKeyID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteAPIKey indicates an expected call of DeleteAPIKey.
-func (mr *MockIAPIKeyRepositoryMockRecorder) DeleteAPIKey(apiKeyID interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) DeleteAPIKey(ctx, apiKeyID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKey", reflect.TypeOf((*MockIAPIKeyRepository)(nil).DeleteAPIKey), apiKeyID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKey", reflect.TypeOf((*MockIAPIKeyRepository)(nil).DeleteAPIKey), ctx, apiKeyID)
 }
 
 // DeleteAPIKeys mocks base method.
-func (m *MockIAPIKeyRepository) DeleteAPIKeys(apiKeyIDs []string) error {
+func (m *MockIAPIKeyRepository) DeleteAPIKeys(ctx context.Context, apiKeyIDs []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAPIKeys", apiKeyIDs)
+	ret := m.ctrl.Call(m, "DeleteAPIKeys", ctx, apiKeyIDs)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteAPIKeys indicates an expected call of DeleteAPIKeys.
-func (mr *MockIAPIKeyRepositoryMockRecorder) DeleteAPIKeys(apiKeyIDs interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) DeleteAPIKeys(ctx, apiKeyIDs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKeys", reflect.TypeOf((*MockIAPIKeyRepository)(nil).DeleteAPIKeys), ctx, apiKeyIDs)
+}
+
+// DeleteUserAPIKeys mocks base method.
+func (m *MockIAPIKeyRepository) DeleteUserAPIKeys(ctx context.Context, userID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserAPIKeys", ctx, userID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUserAPIKeys indicates an expected call of DeleteUserAPIKeys.
+func (mr *MockIAPIKeyRepositoryMockRecorder) DeleteUserAPIKeys(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKeys", reflect.TypeOf((*MockIAPIKeyRepository)(nil).DeleteAPIKeys), apiKeyIDs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserAPIKeys", reflect.TypeOf((*MockIAPIKeyRepository)(nil).DeleteUserAPIKeys), ctx, userID)
 }
 
 // FindAPIKeyByHash mocks base method.
-func (m *MockIAPIKeyRepository) FindAPIKeyByHash(hash string) (*models.APIKey, error) {
+func (m *MockIAPIKeyRepository) FindAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindAPIKeyByHash", hash)
+	ret := m.ctrl.Call(m, "FindAPIKeyByHash", ctx, hash)
 	ret0, _ := ret[0].(*models.APIKey)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindAPIKeyByHash indicates an expected call of FindAPIKeyByHash.
-func (mr *MockIAPIKeyRepositoryMockRecorder) FindAPIKeyByHash(hash interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) FindAPIKeyByHash(ctx, hash interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAPIKeyByHash", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindAPIKeyByHash), hash)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAPIKeyByHash", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindAPIKeyByHash), ctx, hash)
 }
 
 // FindAPIKeyByID mocks base method.
-func (m *MockIAPIKeyRepository) FindAPIKeyByID(apiKeyID string) (*models.APIKey, error) {
+func (m *MockIAPIKeyRepository) FindAPIKeyByID(ctx context.Context, apiKeyID string) (*models.APIKey, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindAPIKeyByID", apiKeyID)
+	ret := m.ctrl.Call(m, "FindAPIKeyByID", ctx, apiKeyID)
 	ret0, _ := ret[0].(*models.APIKey)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindAPIKeyByID indicates an expected call of FindAPIKeyByID.
-func (mr *MockIAPIKeyRepositoryMockRecorder) FindAPIKeyByID(apiKeyID interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) FindAPIKeyByID(ctx, apiKeyID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAPIKeyByID", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindAPIKeyByID), apiKeyID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAPIKeyByID", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindAPIKeyByID), ctx, apiKeyID)
 }
 
 // FindAPIKeyByMaskID mocks base method.
-func (m *MockIAPIKeyRepository) FindAPIKeyByMaskID(maskID string) (*models.APIKey, error) {
+func (m *MockIAPIKeyRepository) FindAPIKeyByMaskID(ctx context.Context, maskID string) (*models.APIKey, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindAPIKeyByMaskID", maskID)
+	ret := m.ctrl.Call(m, "FindAPIKeyByMaskID", ctx, maskID)
 	ret0, _ := ret[0].(*models.APIKey)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindAPIKeyByMaskID indicates an expected call of FindAPIKeyByMaskID.
-func (mr *MockIAPIKeyRepositoryMockRecorder) FindAPIKeyByMaskID(maskID interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) FindAPIKeyByMaskID(ctx, maskID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAPIKeyByMaskID", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindAPIKeyByMaskID), maskID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAPIKeyByMaskID", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindAPIKeyByMaskID), ctx, maskID)
+}
+
+// FindAllAPIKeys mocks base method.
+func (m *MockIAPIKeyRepository) FindAllAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllAPIKeys", ctx)
+	ret0, _ := ret[0].([]models.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllAPIKeys indicates an expected call of FindAllAPIKeys.
+func (mr *MockIAPIKeyRepositoryMockRecorder) FindAllAPIKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllAPIKeys", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindAllAPIKeys), ctx)
 }
 
 // FindUserAPIKeys mocks base method.
-func (m *MockIAPIKeyRepository) FindUserAPIKeys(userID string) ([]models.APIKey, error) {
+func (m *MockIAPIKeyRepository) FindUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindUserAPIKeys", userID)
+	ret := m.ctrl.Call(m, "FindUserAPIKeys", ctx, userID)
 	ret0, _ := ret[0].([]models.APIKey)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindUserAPIKeys indicates an expected call of FindUserAPIKeys.
-func (mr *MockIAPIKeyRepositoryMockRecorder) FindUserAPIKeys(userID interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) FindUserAPIKeys(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserAPIKeys", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindUserAPIKeys), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserAPIKeys", reflect.TypeOf((*MockIAPIKeyRepository)(nil).FindUserAPIKeys), ctx, userID)
 }
 
 // RevokeAPIKey mocks base method.
-func (m *MockIAPIKeyRepository) RevokeAPIKey(apiKeyID string) error {
+func (m *MockIAPIKeyRepository) RevokeAPIKey(ctx context.Context, apiKeyID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RevokeAPIKey", apiKeyID)
+	ret := m.ctrl.Call(m, "RevokeAPIKey", ctx, apiKeyID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RevokeAPIKey indicates an expected call of RevokeAPIKey.
-func (mr *MockIAPIKeyRepositoryMockRecorder) RevokeAPIKey(apiKeyID interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) RevokeAPIKey(ctx, apiKeyID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockIAPIKeyRepository)(nil).RevokeAPIKey), apiKeyID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockIAPIKeyRepository)(nil).RevokeAPIKey), ctx, apiKeyID)
 }
 
 // RevokeAPIKeys mocks base method.
-func (m *MockIAPIKeyRepository) RevokeAPIKeys(apiKeyIDs []string) error {
+func (m *MockIAPIKeyRepository) RevokeAPIKeys(ctx context.Context, apiKeyIDs []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RevokeAPIKeys", apiKeyIDs)
+	ret := m.ctrl.Call(m, "RevokeAPIKeys", ctx, apiKeyIDs)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RevokeAPIKeys indicates an expected call of RevokeAPIKeys.
-func (mr *MockIAPIKeyRepositoryMockRecorder) RevokeAPIKeys(apiKeyIDs interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) RevokeAPIKeys(ctx, apiKeyIDs interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKeys", reflect.TypeOf((*MockIAPIKeyRepository)(nil).RevokeAPIKeys), apiKeyIDs)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKeys", reflect.TypeOf((*MockIAPIKeyRepository)(nil).RevokeAPIKeys), ctx, apiKeyIDs)
 }
 
 // UpdateAPIKey mocks base method.
-func (m *MockIAPIKeyRepository) UpdateAPIKey(apiKey *models.APIKey) error {
+func (m *MockIAPIKeyRepository) UpdateAPIKey(ctx context.Context, apiKey *models.APIKey) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateAPIKey", apiKey)
+	ret := m.ctrl.Call(m, "UpdateAPIKey", ctx, apiKey)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateAPIKey indicates an expected call of UpdateAPIKey.
-func (mr *MockIAPIKeyRepositoryMockRecorder) UpdateAPIKey(apiKey interface{}) *gomock.Call {
+func (mr *MockIAPIKeyRepositoryMockRecorder) UpdateAPIKey(ctx, apiKey interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAPIKey", reflect.TypeOf((*MockIAPIKeyRepository)(nil).UpdateAPIKey), apiKey)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAPIKey", reflect.TypeOf((*MockIAPIKeyRepository)(nil).UpdateAPIKey), ctx, apiKey)
 }
 
 // MockIBucketRepository is a mock of IBucketRepository interface.
@@ -317,97 +364,112 @@ func (m *MockIBucketRepository) EXPECT() *MockIBucketRepositoryMockRecorder {
 }
 
 // CreateBucket mocks base method.
-func (m *MockIBucketRepository) CreateBucket(bucket *models.Bucket) (primitive.ObjectID, error) {
+func (m *MockIBucketRepository) CreateBucket(ctx context.Context, bucket *models.Bucket) (primitive.ObjectID, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateBucket", bucket)
+	ret := m.ctrl.Call(m, "CreateBucket", ctx, bucket)
 	ret0, _ := ret[0].(primitive.ObjectID)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateBucket indicates an expected call of CreateBucket.
-func (mr *MockIBucketRepositoryMockRecorder) CreateBucket(bucket interface{}) *gomock.Call {
+func (mr *MockIBucketRepositoryMockRecorder) CreateBucket(ctx, bucket interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBucket", reflect.TypeOf((*MockIBucketRepository)(nil).CreateBucket), bucket)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBucket", reflect.TypeOf((*MockIBucketRepository)(nil).CreateBucket), ctx, bucket)
 }
 
 // DeleteBucketByID mocks base method.
-func (m *MockIBucketRepository) DeleteBucketByID(id string) error {
+func (m *MockIBucketRepository) DeleteBucketByID(ctx context.Context, id string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteBucketByID", id)
+	ret := m.ctrl.Call(m, "DeleteBucketByID", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteBucketByID indicates an expected call of DeleteBucketByID.
-func (mr *MockIBucketRepositoryMockRecorder) DeleteBucketByID(id interface{}) *gomock.Call {
+func (mr *MockIBucketRepositoryMockRecorder) DeleteBucketByID(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketByID", reflect.TypeOf((*MockIBucketRepository)(nil).DeleteBucketByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketByID", reflect.TypeOf((*MockIBucketRepository)(nil).DeleteBucketByID), ctx, id)
 }
 
 // DeleteBucketByUID mocks base method.
-func (m *MockIBucketRepository) DeleteBucketByUID(uid string) error {
+func (m *MockIBucketRepository) DeleteBucketByUID(ctx context.Context, uid string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteBucketByUID", uid)
+	ret := m.ctrl.Call(m, "DeleteBucketByUID", ctx, uid)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteBucketByUID indicates an expected call of DeleteBucketByUID.
-func (mr *MockIBucketRepositoryMockRecorder) DeleteBucketByUID(uid interface{}) *gomock.Call {
+func (mr *MockIBucketRepositoryMockRecorder) DeleteBucketByUID(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketByUID", reflect.TypeOf((*MockIBucketRepository)(nil).DeleteBucketByUID), ctx, uid)
+}
+
+// FindAllBucketUIDsByUserID mocks base method.
+func (m *MockIBucketRepository) FindAllBucketUIDsByUserID(ctx context.Context, userID string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllBucketUIDsByUserID", ctx, userID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllBucketUIDsByUserID indicates an expected call of FindAllBucketUIDsByUserID.
+func (mr *MockIBucketRepositoryMockRecorder) FindAllBucketUIDsByUserID(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketByUID", reflect.TypeOf((*MockIBucketRepository)(nil).DeleteBucketByUID), uid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllBucketUIDsByUserID", reflect.TypeOf((*MockIBucketRepository)(nil).FindAllBucketUIDsByUserID), ctx, userID)
 }
 
 // FindBucketByID mocks base method.
-func (m *MockIBucketRepository) FindBucketByID(id string) (*models.Bucket, error) {
+func (m *MockIBucketRepository) FindBucketByID(ctx context.Context, id string) (*models.Bucket, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindBucketByID", id)
+	ret := m.ctrl.Call(m, "FindBucketByID", ctx, id)
 	ret0, _ := ret[0].(*models.Bucket)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindBucketByID indicates an expected call of FindBucketByID.
-func (mr *MockIBucketRepositoryMockRecorder) FindBucketByID(id interface{}) *gomock.Call {
+func (mr *MockIBucketRepositoryMockRecorder) FindBucketByID(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketByID", reflect.TypeOf((*MockIBucketRepository)(nil).FindBucketByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketByID", reflect.TypeOf((*MockIBucketRepository)(nil).FindBucketByID), ctx, id)
 }
 
 // FindBucketByUID mocks base method.
-func (m *MockIBucketRepository) FindBucketByUID(uid string) (*models.Bucket, error) {
+func (m *MockIBucketRepository) FindBucketByUID(ctx context.Context, uid string) (*models.Bucket, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindBucketByUID", uid)
+	ret := m.ctrl.Call(m, "FindBucketByUID", ctx, uid)
 	ret0, _ := ret[0].(*models.Bucket)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindBucketByUID indicates an expected call of FindBucketByUID.
-func (mr *MockIBucketRepositoryMockRecorder) FindBucketByUID(uid interface{}) *gomock.Call {
+func (mr *MockIBucketRepositoryMockRecorder) FindBucketByUID(ctx, uid interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketByUID", reflect.TypeOf((*MockIBucketRepository)(nil).FindBucketByUID), uid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketByUID", reflect.TypeOf((*MockIBucketRepository)(nil).FindBucketByUID), ctx, uid)
 }
 
 // FindBucketsByUserID mocks base method.
-func (m *MockIBucketRepository) FindBucketsByUserID(userID string) ([]models.Bucket, error) {
+func (m *MockIBucketRepository) FindBucketsByUserID(ctx context.Context, userID string) ([]models.Bucket, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindBucketsByUserID", userID)
+	ret := m.ctrl.Call(m, "FindBucketsByUserID", ctx, userID)
 	ret0, _ := ret[0].([]models.Bucket)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindBucketsByUserID indicates an expected call of FindBucketsByUserID.
-func (mr *MockIBucketRepositoryMockRecorder) FindBucketsByUserID(userID interface{}) *gomock.Call {
+func (mr *MockIBucketRepositoryMockRecorder) FindBucketsByUserID(ctx, userID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketsByUserID", reflect.TypeOf((*MockIBucketRepository)(nil).FindBucketsByUserID), userID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketsByUserID", reflect.TypeOf((*MockIBucketRepository)(nil).FindBucketsByUserID), ctx, userID)
 }
 
 // FindBucketsByUserIDPaged mocks base method.
-func (m *MockIBucketRepository) FindBucketsByUserIDPaged(userID string, filter bson.M, findOpts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.Bucket, utils.PageInfo, error) {
+func (m *MockIBucketRepository) FindBucketsByUserIDPaged(ctx context.Context, userID string, filter bson.M, findOpts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.Bucket, utils.PageInfo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindBucketsByUserIDPaged", userID, filter, findOpts, paginationParams)
+	ret := m.ctrl.Call(m, "FindBucketsByUserIDPaged", ctx, userID, filter, findOpts, paginationParams)
 	ret0, _ := ret[0].([]models.Bucket)
 	ret1, _ := ret[1].(utils.PageInfo)
 	ret2, _ := ret[2].(error)
@@ -415,23 +477,110 @@ func (m *MockIBucketRepository) FindBucketsByUserIDPaged(userID string, filter b
 }
 
 // FindBucketsByUserIDPaged indicates an expected call of FindBucketsByUserIDPaged.
-func (mr *MockIBucketRepositoryMockRecorder) FindBucketsByUserIDPaged(userID, filter, findOpts, paginationParams interface{}) *gomock.Call {
+func (mr *MockIBucketRepositoryMockRecorder) FindBucketsByUserIDPaged(ctx, userID, filter, findOpts, paginationParams interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketsByUserIDPaged", reflect.TypeOf((*MockIBucketRepository)(nil).FindBucketsByUserIDPaged), ctx, userID, filter, findOpts, paginationParams)
+}
+
+// FindTrashedBucketByUID mocks base method.
+func (m *MockIBucketRepository) FindTrashedBucketByUID(ctx context.Context, uid string) (*models.Bucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTrashedBucketByUID", ctx, uid)
+	ret0, _ := ret[0].(*models.Bucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTrashedBucketByUID indicates an expected call of FindTrashedBucketByUID.
+func (mr *MockIBucketRepositoryMockRecorder) FindTrashedBucketByUID(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTrashedBucketByUID", reflect.TypeOf((*MockIBucketRepository)(nil).FindTrashedBucketByUID), ctx, uid)
+}
+
+// FindTrashedBucketsBefore mocks base method.
+func (m *MockIBucketRepository) FindTrashedBucketsBefore(ctx context.Context, before primitive.DateTime) ([]models.Bucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTrashedBucketsBefore", ctx, before)
+	ret0, _ := ret[0].([]models.Bucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTrashedBucketsBefore indicates an expected call of FindTrashedBucketsBefore.
+func (mr *MockIBucketRepositoryMockRecorder) FindTrashedBucketsBefore(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTrashedBucketsBefore", reflect.TypeOf((*MockIBucketRepository)(nil).FindTrashedBucketsBefore), ctx, before)
+}
+
+// FindTrashedBucketsByUserID mocks base method.
+func (m *MockIBucketRepository) FindTrashedBucketsByUserID(ctx context.Context, userID string) ([]models.Bucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTrashedBucketsByUserID", ctx, userID)
+	ret0, _ := ret[0].([]models.Bucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTrashedBucketsByUserID indicates an expected call of FindTrashedBucketsByUserID.
+func (mr *MockIBucketRepositoryMockRecorder) FindTrashedBucketsByUserID(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTrashedBucketsByUserID", reflect.TypeOf((*MockIBucketRepository)(nil).FindTrashedBucketsByUserID), ctx, userID)
+}
+
+// RestoreBucketByUID mocks base method.
+func (m *MockIBucketRepository) RestoreBucketByUID(ctx context.Context, uid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreBucketByUID", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreBucketByUID indicates an expected call of RestoreBucketByUID.
+func (mr *MockIBucketRepositoryMockRecorder) RestoreBucketByUID(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreBucketByUID", reflect.TypeOf((*MockIBucketRepository)(nil).RestoreBucketByUID), ctx, uid)
+}
+
+// SetBucketRequireTwoFactor mocks base method.
+func (m *MockIBucketRepository) SetBucketRequireTwoFactor(ctx context.Context, uid string, required bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetBucketRequireTwoFactor", ctx, uid, required)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetBucketRequireTwoFactor indicates an expected call of SetBucketRequireTwoFactor.
+func (mr *MockIBucketRepositoryMockRecorder) SetBucketRequireTwoFactor(ctx, uid, required interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBucketRequireTwoFactor", reflect.TypeOf((*MockIBucketRepository)(nil).SetBucketRequireTwoFactor), ctx, uid, required)
+}
+
+// TrashBucketByUID mocks base method.
+func (m *MockIBucketRepository) TrashBucketByUID(ctx context.Context, uid string, deletedAt primitive.DateTime) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrashBucketByUID", ctx, uid, deletedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TrashBucketByUID indicates an expected call of TrashBucketByUID.
+func (mr *MockIBucketRepositoryMockRecorder) TrashBucketByUID(ctx, uid, deletedAt interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketsByUserIDPaged", reflect.TypeOf((*MockIBucketRepository)(nil).FindBucketsByUserIDPaged), userID, filter, findOpts, paginationParams)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrashBucketByUID", reflect.TypeOf((*MockIBucketRepository)(nil).TrashBucketByUID), ctx, uid, deletedAt)
 }
 
 // UpdateBucket mocks base method.
-func (m *MockIBucketRepository) UpdateBucket(bucket *models.Bucket) error {
+func (m *MockIBucketRepository) UpdateBucket(ctx context.Context, bucket *models.Bucket) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateBucket", bucket)
+	ret := m.ctrl.Call(m, "UpdateBucket", ctx, bucket)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateBucket indicates an expected call of UpdateBucket.
-func (mr *MockIBucketRepositoryMockRecorder) UpdateBucket(bucket interface{}) *gomock.Call {
+func (mr *MockIBucketRepositoryMockRecorder) UpdateBucket(ctx, bucket interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBucket", reflect.TypeOf((*MockIBucketRepository)(nil).UpdateBucket), bucket)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBucket", reflect.TypeOf((*MockIBucketRepository)(nil).UpdateBucket), ctx, bucket)
 }
 
 // MockIBucketItemRepository is a mock of IBucketItemRepository interface.
@@ -457,126 +606,156 @@ func (m *MockIBucketItemRepository) EXPECT() *MockIBucketItemRepositoryMockRecor
 	return m.recorder
 }
 
+// AnonymizeUserBucketItems mocks base method.
+func (m *MockIBucketItemRepository) AnonymizeUserBucketItems(ctx context.Context, userID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AnonymizeUserBucketItems", ctx, userID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AnonymizeUserBucketItems indicates an expected call of AnonymizeUserBucketItems.
+func (mr *MockIBucketItemRepositoryMockRecorder) AnonymizeUserBucketItems(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AnonymizeUserBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).AnonymizeUserBucketItems), ctx, userID)
+}
+
+// CompressBucketItems mocks base method.
+func (m *MockIBucketItemRepository) CompressBucketItems(ctx context.Context, bucketUID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompressBucketItems", ctx, bucketUID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompressBucketItems indicates an expected call of CompressBucketItems.
+func (mr *MockIBucketItemRepositoryMockRecorder) CompressBucketItems(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompressBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).CompressBucketItems), ctx, bucketUID)
+}
+
 // CreateBucketItem mocks base method.
-func (m *MockIBucketItemRepository) CreateBucketItem(bucketItem *models.BucketItem) (primitive.ObjectID, error) {
+func (m *MockIBucketItemRepository) CreateBucketItem(ctx context.Context, bucketItem *models.BucketItem) (primitive.ObjectID, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateBucketItem", bucketItem)
+	ret := m.ctrl.Call(m, "CreateBucketItem", ctx, bucketItem)
 	ret0, _ := ret[0].(primitive.ObjectID)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateBucketItem indicates an expected call of CreateBucketItem.
-func (mr *MockIBucketItemRepositoryMockRecorder) CreateBucketItem(bucketItem interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) CreateBucketItem(ctx, bucketItem interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBucketItem", reflect.TypeOf((*MockIBucketItemRepository)(nil).CreateBucketItem), bucketItem)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBucketItem", reflect.TypeOf((*MockIBucketItemRepository)(nil).CreateBucketItem), ctx, bucketItem)
 }
 
 // DeleteBucketItemById mocks base method.
-func (m *MockIBucketItemRepository) DeleteBucketItemById(id string) error {
+func (m *MockIBucketItemRepository) DeleteBucketItemById(ctx context.Context, id string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteBucketItemById", id)
+	ret := m.ctrl.Call(m, "DeleteBucketItemById", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteBucketItemById indicates an expected call of DeleteBucketItemById.
-func (mr *MockIBucketItemRepositoryMockRecorder) DeleteBucketItemById(id interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) DeleteBucketItemById(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketItemById", reflect.TypeOf((*MockIBucketItemRepository)(nil).DeleteBucketItemById), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketItemById", reflect.TypeOf((*MockIBucketItemRepository)(nil).DeleteBucketItemById), ctx, id)
 }
 
 // DeleteBucketItemByKeyName mocks base method.
-func (m *MockIBucketItemRepository) DeleteBucketItemByKeyName(bucketUID, key string) error {
+func (m *MockIBucketItemRepository) DeleteBucketItemByKeyName(ctx context.Context, bucketUID, key string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteBucketItemByKeyName", bucketUID, key)
+	ret := m.ctrl.Call(m, "DeleteBucketItemByKeyName", ctx, bucketUID, key)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteBucketItemByKeyName indicates an expected call of DeleteBucketItemByKeyName.
-func (mr *MockIBucketItemRepositoryMockRecorder) DeleteBucketItemByKeyName(bucketUID, key interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) DeleteBucketItemByKeyName(ctx, bucketUID, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketItemByKeyName", reflect.TypeOf((*MockIBucketItemRepository)(nil).DeleteBucketItemByKeyName), bucketUID, key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketItemByKeyName", reflect.TypeOf((*MockIBucketItemRepository)(nil).DeleteBucketItemByKeyName), ctx, bucketUID, key)
 }
 
 // DeleteBucketItems mocks base method.
-func (m *MockIBucketItemRepository) DeleteBucketItems(bucketUID string) error {
+func (m *MockIBucketItemRepository) DeleteBucketItems(ctx context.Context, bucketUID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteBucketItems", bucketUID)
+	ret := m.ctrl.Call(m, "DeleteBucketItems", ctx, bucketUID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteBucketItems indicates an expected call of DeleteBucketItems.
-func (mr *MockIBucketItemRepositoryMockRecorder) DeleteBucketItems(bucketUID interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) DeleteBucketItems(ctx, bucketUID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).DeleteBucketItems), bucketUID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).DeleteBucketItems), ctx, bucketUID)
 }
 
 // DeleteBucketItemsById mocks base method.
-func (m *MockIBucketItemRepository) DeleteBucketItemsById(ids []string) error {
+func (m *MockIBucketItemRepository) DeleteBucketItemsById(ctx context.Context, ids []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteBucketItemsById", ids)
+	ret := m.ctrl.Call(m, "DeleteBucketItemsById", ctx, ids)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DeleteBucketItemsById indicates an expected call of DeleteBucketItemsById.
-func (mr *MockIBucketItemRepositoryMockRecorder) DeleteBucketItemsById(ids interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) DeleteBucketItemsById(ctx, ids interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketItemsById", reflect.TypeOf((*MockIBucketItemRepository)(nil).DeleteBucketItemsById), ids)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketItemsById", reflect.TypeOf((*MockIBucketItemRepository)(nil).DeleteBucketItemsById), ctx, ids)
 }
 
 // FindBucketItemByID mocks base method.
-func (m *MockIBucketItemRepository) FindBucketItemByID(id string) (*models.BucketItem, error) {
+func (m *MockIBucketItemRepository) FindBucketItemByID(ctx context.Context, id string) (*models.BucketItem, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindBucketItemByID", id)
+	ret := m.ctrl.Call(m, "FindBucketItemByID", ctx, id)
 	ret0, _ := ret[0].(*models.BucketItem)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindBucketItemByID indicates an expected call of FindBucketItemByID.
-func (mr *MockIBucketItemRepositoryMockRecorder) FindBucketItemByID(id interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) FindBucketItemByID(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketItemByID", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindBucketItemByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketItemByID", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindBucketItemByID), ctx, id)
 }
 
 // FindBucketItemByKeyName mocks base method.
-func (m *MockIBucketItemRepository) FindBucketItemByKeyName(bucketUID, key string) (*models.BucketItem, error) {
+func (m *MockIBucketItemRepository) FindBucketItemByKeyName(ctx context.Context, bucketUID, key string) (*models.BucketItem, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindBucketItemByKeyName", bucketUID, key)
+	ret := m.ctrl.Call(m, "FindBucketItemByKeyName", ctx, bucketUID, key)
 	ret0, _ := ret[0].(*models.BucketItem)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindBucketItemByKeyName indicates an expected call of FindBucketItemByKeyName.
-func (mr *MockIBucketItemRepositoryMockRecorder) FindBucketItemByKeyName(bucketUID, key interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) FindBucketItemByKeyName(ctx, bucketUID, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketItemByKeyName", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindBucketItemByKeyName), bucketUID, key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketItemByKeyName", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindBucketItemByKeyName), ctx, bucketUID, key)
 }
 
 // FindBucketItems mocks base method.
-func (m *MockIBucketItemRepository) FindBucketItems(bucketUID string) ([]models.BucketItem, error) {
+func (m *MockIBucketItemRepository) FindBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindBucketItems", bucketUID)
+	ret := m.ctrl.Call(m, "FindBucketItems", ctx, bucketUID)
 	ret0, _ := ret[0].([]models.BucketItem)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindBucketItems indicates an expected call of FindBucketItems.
-func (mr *MockIBucketItemRepositoryMockRecorder) FindBucketItems(bucketUID interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) FindBucketItems(ctx, bucketUID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindBucketItems), bucketUID)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindBucketItems), ctx, bucketUID)
 }
 
 // FindBucketItemsPaged mocks base method.
-func (m *MockIBucketItemRepository) FindBucketItemsPaged(filter bson.M, opts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.BucketItem, utils.PageInfo, error) {
+func (m *MockIBucketItemRepository) FindBucketItemsPaged(ctx context.Context, filter bson.M, opts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.BucketItem, utils.PageInfo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindBucketItemsPaged", filter, opts, paginationParams)
+	ret := m.ctrl.Call(m, "FindBucketItemsPaged", ctx, filter, opts, paginationParams)
 	ret0, _ := ret[0].([]models.BucketItem)
 	ret1, _ := ret[1].(utils.PageInfo)
 	ret2, _ := ret[2].(error)
@@ -584,35 +763,1227 @@ func (m *MockIBucketItemRepository) FindBucketItemsPaged(filter bson.M, opts *op
 }
 
 // FindBucketItemsPaged indicates an expected call of FindBucketItemsPaged.
-func (mr *MockIBucketItemRepositoryMockRecorder) FindBucketItemsPaged(filter, opts, paginationParams interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) FindBucketItemsPaged(ctx, filter, opts, paginationParams interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketItemsPaged", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindBucketItemsPaged), ctx, filter, opts, paginationParams)
+}
+
+// FindTrashedBucketItemByID mocks base method.
+func (m *MockIBucketItemRepository) FindTrashedBucketItemByID(ctx context.Context, id string) (*models.BucketItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTrashedBucketItemByID", ctx, id)
+	ret0, _ := ret[0].(*models.BucketItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTrashedBucketItemByID indicates an expected call of FindTrashedBucketItemByID.
+func (mr *MockIBucketItemRepositoryMockRecorder) FindTrashedBucketItemByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTrashedBucketItemByID", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindTrashedBucketItemByID), ctx, id)
+}
+
+// FindTrashedBucketItems mocks base method.
+func (m *MockIBucketItemRepository) FindTrashedBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTrashedBucketItems", ctx, bucketUID)
+	ret0, _ := ret[0].([]models.BucketItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTrashedBucketItems indicates an expected call of FindTrashedBucketItems.
+func (mr *MockIBucketItemRepositoryMockRecorder) FindTrashedBucketItems(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTrashedBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindTrashedBucketItems), ctx, bucketUID)
+}
+
+// GetCompressionStats mocks base method.
+func (m *MockIBucketItemRepository) GetCompressionStats(ctx context.Context, bucketUID string) (*models.BucketItemCompressionStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCompressionStats", ctx, bucketUID)
+	ret0, _ := ret[0].(*models.BucketItemCompressionStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCompressionStats indicates an expected call of GetCompressionStats.
+func (mr *MockIBucketItemRepositoryMockRecorder) GetCompressionStats(ctx, bucketUID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketItemsPaged", reflect.TypeOf((*MockIBucketItemRepository)(nil).FindBucketItemsPaged), filter, opts, paginationParams)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCompressionStats", reflect.TypeOf((*MockIBucketItemRepository)(nil).GetCompressionStats), ctx, bucketUID)
 }
 
 // IncrementIntItem mocks base method.
-func (m *MockIBucketItemRepository) IncrementIntItem(bucketUID, key string, amount int) error {
+func (m *MockIBucketItemRepository) IncrementIntItem(ctx context.Context, bucketUID, key string, amount int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IncrementIntItem", bucketUID, key, amount)
+	ret := m.ctrl.Call(m, "IncrementIntItem", ctx, bucketUID, key, amount)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // IncrementIntItem indicates an expected call of IncrementIntItem.
-func (mr *MockIBucketItemRepositoryMockRecorder) IncrementIntItem(bucketUID, key, amount interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) IncrementIntItem(ctx, bucketUID, key, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementIntItem", reflect.TypeOf((*MockIBucketItemRepository)(nil).IncrementIntItem), ctx, bucketUID, key, amount)
+}
+
+// MarkExpiredBucketItems mocks base method.
+func (m *MockIBucketItemRepository) MarkExpiredBucketItems(ctx context.Context, now primitive.DateTime, limit int64) ([]models.BucketItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkExpiredBucketItems", ctx, now, limit)
+	ret0, _ := ret[0].([]models.BucketItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkExpiredBucketItems indicates an expected call of MarkExpiredBucketItems.
+func (mr *MockIBucketItemRepositoryMockRecorder) MarkExpiredBucketItems(ctx, now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkExpiredBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).MarkExpiredBucketItems), ctx, now, limit)
+}
+
+// PurgeExpiredBucketItems mocks base method.
+func (m *MockIBucketItemRepository) PurgeExpiredBucketItems(ctx context.Context, before primitive.DateTime) ([]models.BucketItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeExpiredBucketItems", ctx, before)
+	ret0, _ := ret[0].([]models.BucketItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeExpiredBucketItems indicates an expected call of PurgeExpiredBucketItems.
+func (mr *MockIBucketItemRepositoryMockRecorder) PurgeExpiredBucketItems(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpiredBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).PurgeExpiredBucketItems), ctx, before)
+}
+
+// PurgeTrashedBucketItems mocks base method.
+func (m *MockIBucketItemRepository) PurgeTrashedBucketItems(ctx context.Context, before primitive.DateTime) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeTrashedBucketItems", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeTrashedBucketItems indicates an expected call of PurgeTrashedBucketItems.
+func (mr *MockIBucketItemRepositoryMockRecorder) PurgeTrashedBucketItems(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeTrashedBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).PurgeTrashedBucketItems), ctx, before)
+}
+
+// RestoreBucketItemByID mocks base method.
+func (m *MockIBucketItemRepository) RestoreBucketItemByID(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreBucketItemByID", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreBucketItemByID indicates an expected call of RestoreBucketItemByID.
+func (mr *MockIBucketItemRepositoryMockRecorder) RestoreBucketItemByID(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementIntItem", reflect.TypeOf((*MockIBucketItemRepository)(nil).IncrementIntItem), bucketUID, key, amount)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreBucketItemByID", reflect.TypeOf((*MockIBucketItemRepository)(nil).RestoreBucketItemByID), ctx, id)
+}
+
+// RestoreBucketItems mocks base method.
+func (m *MockIBucketItemRepository) RestoreBucketItems(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreBucketItems", ctx, bucketUID, deletedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreBucketItems indicates an expected call of RestoreBucketItems.
+func (mr *MockIBucketItemRepositoryMockRecorder) RestoreBucketItems(ctx, bucketUID, deletedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).RestoreBucketItems), ctx, bucketUID, deletedAt)
+}
+
+// TrashBucketItemByKeyName mocks base method.
+func (m *MockIBucketItemRepository) TrashBucketItemByKeyName(ctx context.Context, bucketUID, key string, deletedAt primitive.DateTime) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrashBucketItemByKeyName", ctx, bucketUID, key, deletedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TrashBucketItemByKeyName indicates an expected call of TrashBucketItemByKeyName.
+func (mr *MockIBucketItemRepositoryMockRecorder) TrashBucketItemByKeyName(ctx, bucketUID, key, deletedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrashBucketItemByKeyName", reflect.TypeOf((*MockIBucketItemRepository)(nil).TrashBucketItemByKeyName), ctx, bucketUID, key, deletedAt)
+}
+
+// TrashBucketItems mocks base method.
+func (m *MockIBucketItemRepository) TrashBucketItems(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrashBucketItems", ctx, bucketUID, deletedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TrashBucketItems indicates an expected call of TrashBucketItems.
+func (mr *MockIBucketItemRepositoryMockRecorder) TrashBucketItems(ctx, bucketUID, deletedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrashBucketItems", reflect.TypeOf((*MockIBucketItemRepository)(nil).TrashBucketItems), ctx, bucketUID, deletedAt)
 }
 
 // UpdateBucketItem mocks base method.
-func (m *MockIBucketItemRepository) UpdateBucketItem(bucketItem *models.BucketItem, key string) error {
+func (m *MockIBucketItemRepository) UpdateBucketItem(ctx context.Context, bucketItem *models.BucketItem, key string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateBucketItem", bucketItem, key)
+	ret := m.ctrl.Call(m, "UpdateBucketItem", ctx, bucketItem, key)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateBucketItem indicates an expected call of UpdateBucketItem.
-func (mr *MockIBucketItemRepositoryMockRecorder) UpdateBucketItem(bucketItem, key interface{}) *gomock.Call {
+func (mr *MockIBucketItemRepositoryMockRecorder) UpdateBucketItem(ctx, bucketItem, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBucketItem", reflect.TypeOf((*MockIBucketItemRepository)(nil).UpdateBucketItem), ctx, bucketItem, key)
+}
+
+// MockIBucketItemBlobRepository is a mock of IBucketItemBlobRepository interface.
+type MockIBucketItemBlobRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIBucketItemBlobRepositoryMockRecorder
+}
+
+// MockIBucketItemBlobRepositoryMockRecorder is the mock recorder for MockIBucketItemBlobRepository.
+type MockIBucketItemBlobRepositoryMockRecorder struct {
+	mock *MockIBucketItemBlobRepository
+}
+
+// NewMockIBucketItemBlobRepository creates a new mock instance.
+func NewMockIBucketItemBlobRepository(ctrl *gomock.Controller) *MockIBucketItemBlobRepository {
+	mock := &MockIBucketItemBlobRepository{ctrl: ctrl}
+	mock.recorder = &MockIBucketItemBlobRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIBucketItemBlobRepository) EXPECT() *MockIBucketItemBlobRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CopyBlob mocks base method.
+func (m *MockIBucketItemBlobRepository) CopyBlob(ctx context.Context, fileID primitive.ObjectID) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CopyBlob", ctx, fileID)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CopyBlob indicates an expected call of CopyBlob.
+func (mr *MockIBucketItemBlobRepositoryMockRecorder) CopyBlob(ctx, fileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CopyBlob", reflect.TypeOf((*MockIBucketItemBlobRepository)(nil).CopyBlob), ctx, fileID)
+}
+
+// DeleteBlob mocks base method.
+func (m *MockIBucketItemBlobRepository) DeleteBlob(ctx context.Context, fileID primitive.ObjectID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBlob", ctx, fileID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBlob indicates an expected call of DeleteBlob.
+func (mr *MockIBucketItemBlobRepositoryMockRecorder) DeleteBlob(ctx, fileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBlob", reflect.TypeOf((*MockIBucketItemBlobRepository)(nil).DeleteBlob), ctx, fileID)
+}
+
+// OpenBlob mocks base method.
+func (m *MockIBucketItemBlobRepository) OpenBlob(ctx context.Context, fileID primitive.ObjectID) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenBlob", ctx, fileID)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OpenBlob indicates an expected call of OpenBlob.
+func (mr *MockIBucketItemBlobRepositoryMockRecorder) OpenBlob(ctx, fileID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenBlob", reflect.TypeOf((*MockIBucketItemBlobRepository)(nil).OpenBlob), ctx, fileID)
+}
+
+// UploadBlob mocks base method.
+func (m *MockIBucketItemBlobRepository) UploadBlob(ctx context.Context, filename string, source io.Reader, metadata bson.M) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadBlob", ctx, filename, source, metadata)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadBlob indicates an expected call of UploadBlob.
+func (mr *MockIBucketItemBlobRepositoryMockRecorder) UploadBlob(ctx, filename, source, metadata interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadBlob", reflect.TypeOf((*MockIBucketItemBlobRepository)(nil).UploadBlob), ctx, filename, source, metadata)
+}
+
+// MockIBucketSnapshotRepository is a mock of IBucketSnapshotRepository interface.
+type MockIBucketSnapshotRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIBucketSnapshotRepositoryMockRecorder
+}
+
+// MockIBucketSnapshotRepositoryMockRecorder is the mock recorder for MockIBucketSnapshotRepository.
+type MockIBucketSnapshotRepositoryMockRecorder struct {
+	mock *MockIBucketSnapshotRepository
+}
+
+// NewMockIBucketSnapshotRepository creates a new mock instance.
+func NewMockIBucketSnapshotRepository(ctrl *gomock.Controller) *MockIBucketSnapshotRepository {
+	mock := &MockIBucketSnapshotRepository{ctrl: ctrl}
+	mock.recorder = &MockIBucketSnapshotRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIBucketSnapshotRepository) EXPECT() *MockIBucketSnapshotRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateSnapshot mocks base method.
+func (m *MockIBucketSnapshotRepository) CreateSnapshot(ctx context.Context, snapshot *models.BucketSnapshot, items []models.BucketItem) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSnapshot", ctx, snapshot, items)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSnapshot indicates an expected call of CreateSnapshot.
+func (mr *MockIBucketSnapshotRepositoryMockRecorder) CreateSnapshot(ctx, snapshot, items interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSnapshot", reflect.TypeOf((*MockIBucketSnapshotRepository)(nil).CreateSnapshot), ctx, snapshot, items)
+}
+
+// DeleteBucketSnapshots mocks base method.
+func (m *MockIBucketSnapshotRepository) DeleteBucketSnapshots(ctx context.Context, bucketUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucketSnapshots", ctx, bucketUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBucketSnapshots indicates an expected call of DeleteBucketSnapshots.
+func (mr *MockIBucketSnapshotRepositoryMockRecorder) DeleteBucketSnapshots(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketSnapshots", reflect.TypeOf((*MockIBucketSnapshotRepository)(nil).DeleteBucketSnapshots), ctx, bucketUID)
+}
+
+// DeleteSnapshot mocks base method.
+func (m *MockIBucketSnapshotRepository) DeleteSnapshot(ctx context.Context, snapshotID primitive.ObjectID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSnapshot", ctx, snapshotID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSnapshot indicates an expected call of DeleteSnapshot.
+func (mr *MockIBucketSnapshotRepositoryMockRecorder) DeleteSnapshot(ctx, snapshotID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSnapshot", reflect.TypeOf((*MockIBucketSnapshotRepository)(nil).DeleteSnapshot), ctx, snapshotID)
+}
+
+// FindSnapshotByName mocks base method.
+func (m *MockIBucketSnapshotRepository) FindSnapshotByName(ctx context.Context, bucketUID, name string) (*models.BucketSnapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSnapshotByName", ctx, bucketUID, name)
+	ret0, _ := ret[0].(*models.BucketSnapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSnapshotByName indicates an expected call of FindSnapshotByName.
+func (mr *MockIBucketSnapshotRepositoryMockRecorder) FindSnapshotByName(ctx, bucketUID, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSnapshotByName", reflect.TypeOf((*MockIBucketSnapshotRepository)(nil).FindSnapshotByName), ctx, bucketUID, name)
+}
+
+// FindSnapshotItems mocks base method.
+func (m *MockIBucketSnapshotRepository) FindSnapshotItems(ctx context.Context, snapshotID primitive.ObjectID) ([]models.BucketItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSnapshotItems", ctx, snapshotID)
+	ret0, _ := ret[0].([]models.BucketItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSnapshotItems indicates an expected call of FindSnapshotItems.
+func (mr *MockIBucketSnapshotRepositoryMockRecorder) FindSnapshotItems(ctx, snapshotID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSnapshotItems", reflect.TypeOf((*MockIBucketSnapshotRepository)(nil).FindSnapshotItems), ctx, snapshotID)
+}
+
+// FindSnapshotsByBucketUID mocks base method.
+func (m *MockIBucketSnapshotRepository) FindSnapshotsByBucketUID(ctx context.Context, bucketUID string) ([]models.BucketSnapshot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSnapshotsByBucketUID", ctx, bucketUID)
+	ret0, _ := ret[0].([]models.BucketSnapshot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSnapshotsByBucketUID indicates an expected call of FindSnapshotsByBucketUID.
+func (mr *MockIBucketSnapshotRepositoryMockRecorder) FindSnapshotsByBucketUID(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSnapshotsByBucketUID", reflect.TypeOf((*MockIBucketSnapshotRepository)(nil).FindSnapshotsByBucketUID), ctx, bucketUID)
+}
+
+// MockIWebhookRepository is a mock of IWebhookRepository interface.
+type MockIWebhookRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIWebhookRepositoryMockRecorder
+}
+
+// MockIWebhookRepositoryMockRecorder is the mock recorder for MockIWebhookRepository.
+type MockIWebhookRepositoryMockRecorder struct {
+	mock *MockIWebhookRepository
+}
+
+// NewMockIWebhookRepository creates a new mock instance.
+func NewMockIWebhookRepository(ctrl *gomock.Controller) *MockIWebhookRepository {
+	mock := &MockIWebhookRepository{ctrl: ctrl}
+	mock.recorder = &MockIWebhookRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIWebhookRepository) EXPECT() *MockIWebhookRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateWebhook mocks base method.
+func (m *MockIWebhookRepository) CreateWebhook(ctx context.Context, webhook *models.Webhook) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWebhook", ctx, webhook)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWebhook indicates an expected call of CreateWebhook.
+func (mr *MockIWebhookRepositoryMockRecorder) CreateWebhook(ctx, webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhook", reflect.TypeOf((*MockIWebhookRepository)(nil).CreateWebhook), ctx, webhook)
+}
+
+// DeleteBucketWebhooks mocks base method.
+func (m *MockIWebhookRepository) DeleteBucketWebhooks(ctx context.Context, bucketUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucketWebhooks", ctx, bucketUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBucketWebhooks indicates an expected call of DeleteBucketWebhooks.
+func (mr *MockIWebhookRepositoryMockRecorder) DeleteBucketWebhooks(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketWebhooks", reflect.TypeOf((*MockIWebhookRepository)(nil).DeleteBucketWebhooks), ctx, bucketUID)
+}
+
+// DeleteWebhook mocks base method.
+func (m *MockIWebhookRepository) DeleteWebhook(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhook", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhook indicates an expected call of DeleteWebhook.
+func (mr *MockIWebhookRepositoryMockRecorder) DeleteWebhook(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhook", reflect.TypeOf((*MockIWebhookRepository)(nil).DeleteWebhook), ctx, id)
+}
+
+// FindWebhookByID mocks base method.
+func (m *MockIWebhookRepository) FindWebhookByID(ctx context.Context, id string) (*models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindWebhookByID", ctx, id)
+	ret0, _ := ret[0].(*models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindWebhookByID indicates an expected call of FindWebhookByID.
+func (mr *MockIWebhookRepositoryMockRecorder) FindWebhookByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindWebhookByID", reflect.TypeOf((*MockIWebhookRepository)(nil).FindWebhookByID), ctx, id)
+}
+
+// FindWebhooksByBucketUID mocks base method.
+func (m *MockIWebhookRepository) FindWebhooksByBucketUID(ctx context.Context, bucketUID string) ([]models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindWebhooksByBucketUID", ctx, bucketUID)
+	ret0, _ := ret[0].([]models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindWebhooksByBucketUID indicates an expected call of FindWebhooksByBucketUID.
+func (mr *MockIWebhookRepositoryMockRecorder) FindWebhooksByBucketUID(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindWebhooksByBucketUID", reflect.TypeOf((*MockIWebhookRepository)(nil).FindWebhooksByBucketUID), ctx, bucketUID)
+}
+
+// FindWebhooksByEvent mocks base method.
+func (m *MockIWebhookRepository) FindWebhooksByEvent(ctx context.Context, bucketUID, event string) ([]models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindWebhooksByEvent", ctx, bucketUID, event)
+	ret0, _ := ret[0].([]models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindWebhooksByEvent indicates an expected call of FindWebhooksByEvent.
+func (mr *MockIWebhookRepositoryMockRecorder) FindWebhooksByEvent(ctx, bucketUID, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindWebhooksByEvent", reflect.TypeOf((*MockIWebhookRepository)(nil).FindWebhooksByEvent), ctx, bucketUID, event)
+}
+
+// MockIWebhookDeliveryRepository is a mock of IWebhookDeliveryRepository interface.
+type MockIWebhookDeliveryRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIWebhookDeliveryRepositoryMockRecorder
+}
+
+// MockIWebhookDeliveryRepositoryMockRecorder is the mock recorder for MockIWebhookDeliveryRepository.
+type MockIWebhookDeliveryRepositoryMockRecorder struct {
+	mock *MockIWebhookDeliveryRepository
+}
+
+// NewMockIWebhookDeliveryRepository creates a new mock instance.
+func NewMockIWebhookDeliveryRepository(ctrl *gomock.Controller) *MockIWebhookDeliveryRepository {
+	mock := &MockIWebhookDeliveryRepository{ctrl: ctrl}
+	mock.recorder = &MockIWebhookDeliveryRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIWebhookDeliveryRepository) EXPECT() *MockIWebhookDeliveryRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateDelivery mocks base method.
+func (m *MockIWebhookDeliveryRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDelivery", ctx, delivery)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDelivery indicates an expected call of CreateDelivery.
+func (mr *MockIWebhookDeliveryRepositoryMockRecorder) CreateDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDelivery", reflect.TypeOf((*MockIWebhookDeliveryRepository)(nil).CreateDelivery), ctx, delivery)
+}
+
+// DeleteWebhookDeliveries mocks base method.
+func (m *MockIWebhookDeliveryRepository) DeleteWebhookDeliveries(ctx context.Context, webhookID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebhookDeliveries", ctx, webhookID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebhookDeliveries indicates an expected call of DeleteWebhookDeliveries.
+func (mr *MockIWebhookDeliveryRepositoryMockRecorder) DeleteWebhookDeliveries(ctx, webhookID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebhookDeliveries", reflect.TypeOf((*MockIWebhookDeliveryRepository)(nil).DeleteWebhookDeliveries), ctx, webhookID)
+}
+
+// FindDeliveriesByWebhookID mocks base method.
+func (m *MockIWebhookDeliveryRepository) FindDeliveriesByWebhookID(ctx context.Context, webhookID string, limit int64) ([]models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDeliveriesByWebhookID", ctx, webhookID, limit)
+	ret0, _ := ret[0].([]models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDeliveriesByWebhookID indicates an expected call of FindDeliveriesByWebhookID.
+func (mr *MockIWebhookDeliveryRepositoryMockRecorder) FindDeliveriesByWebhookID(ctx, webhookID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDeliveriesByWebhookID", reflect.TypeOf((*MockIWebhookDeliveryRepository)(nil).FindDeliveriesByWebhookID), ctx, webhookID, limit)
+}
+
+// FindDeliveryByID mocks base method.
+func (m *MockIWebhookDeliveryRepository) FindDeliveryByID(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDeliveryByID", ctx, id)
+	ret0, _ := ret[0].(*models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDeliveryByID indicates an expected call of FindDeliveryByID.
+func (mr *MockIWebhookDeliveryRepositoryMockRecorder) FindDeliveryByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDeliveryByID", reflect.TypeOf((*MockIWebhookDeliveryRepository)(nil).FindDeliveryByID), ctx, id)
+}
+
+// UpdateDelivery mocks base method.
+func (m *MockIWebhookDeliveryRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDelivery", ctx, delivery)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDelivery indicates an expected call of UpdateDelivery.
+func (mr *MockIWebhookDeliveryRepositoryMockRecorder) UpdateDelivery(ctx, delivery interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDelivery", reflect.TypeOf((*MockIWebhookDeliveryRepository)(nil).UpdateDelivery), ctx, delivery)
+}
+
+// MockIBucketChangeRepository is a mock of IBucketChangeRepository interface.
+type MockIBucketChangeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIBucketChangeRepositoryMockRecorder
+}
+
+// MockIBucketChangeRepositoryMockRecorder is the mock recorder for MockIBucketChangeRepository.
+type MockIBucketChangeRepositoryMockRecorder struct {
+	mock *MockIBucketChangeRepository
+}
+
+// NewMockIBucketChangeRepository creates a new mock instance.
+func NewMockIBucketChangeRepository(ctrl *gomock.Controller) *MockIBucketChangeRepository {
+	mock := &MockIBucketChangeRepository{ctrl: ctrl}
+	mock.recorder = &MockIBucketChangeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIBucketChangeRepository) EXPECT() *MockIBucketChangeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DeleteBucketChanges mocks base method.
+func (m *MockIBucketChangeRepository) DeleteBucketChanges(ctx context.Context, bucketUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucketChanges", ctx, bucketUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBucketChanges indicates an expected call of DeleteBucketChanges.
+func (mr *MockIBucketChangeRepositoryMockRecorder) DeleteBucketChanges(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketChanges", reflect.TypeOf((*MockIBucketChangeRepository)(nil).DeleteBucketChanges), ctx, bucketUID)
+}
+
+// FindChanges mocks base method.
+func (m *MockIBucketChangeRepository) FindChanges(ctx context.Context, bucketUID string, since, limit int64) ([]models.BucketChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindChanges", ctx, bucketUID, since, limit)
+	ret0, _ := ret[0].([]models.BucketChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindChanges indicates an expected call of FindChanges.
+func (mr *MockIBucketChangeRepositoryMockRecorder) FindChanges(ctx, bucketUID, since, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindChanges", reflect.TypeOf((*MockIBucketChangeRepository)(nil).FindChanges), ctx, bucketUID, since, limit)
+}
+
+// LatestChangeSeq mocks base method.
+func (m *MockIBucketChangeRepository) LatestChangeSeq(ctx context.Context, bucketUID string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LatestChangeSeq", ctx, bucketUID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LatestChangeSeq indicates an expected call of LatestChangeSeq.
+func (mr *MockIBucketChangeRepositoryMockRecorder) LatestChangeSeq(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LatestChangeSeq", reflect.TypeOf((*MockIBucketChangeRepository)(nil).LatestChangeSeq), ctx, bucketUID)
+}
+
+// RecordChanges mocks base method.
+func (m *MockIBucketChangeRepository) RecordChanges(ctx context.Context, bucketUID, op string, keys ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, bucketUID, op}
+	for _, a := range keys {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RecordChanges", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordChanges indicates an expected call of RecordChanges.
+func (mr *MockIBucketChangeRepositoryMockRecorder) RecordChanges(ctx, bucketUID, op interface{}, keys ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, bucketUID, op}, keys...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordChanges", reflect.TypeOf((*MockIBucketChangeRepository)(nil).RecordChanges), varargs...)
+}
+
+// MockIAuditEventRepository is a mock of IAuditEventRepository interface.
+type MockIAuditEventRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIAuditEventRepositoryMockRecorder
+}
+
+// MockIAuditEventRepositoryMockRecorder is the mock recorder for MockIAuditEventRepository.
+type MockIAuditEventRepositoryMockRecorder struct {
+	mock *MockIAuditEventRepository
+}
+
+// NewMockIAuditEventRepository creates a new mock instance.
+func NewMockIAuditEventRepository(ctrl *gomock.Controller) *MockIAuditEventRepository {
+	mock := &MockIAuditEventRepository{ctrl: ctrl}
+	mock.recorder = &MockIAuditEventRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIAuditEventRepository) EXPECT() *MockIAuditEventRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateAuditEvent mocks base method.
+func (m *MockIAuditEventRepository) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAuditEvent", ctx, event)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAuditEvent indicates an expected call of CreateAuditEvent.
+func (mr *MockIAuditEventRepositoryMockRecorder) CreateAuditEvent(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuditEvent", reflect.TypeOf((*MockIAuditEventRepository)(nil).CreateAuditEvent), ctx, event)
+}
+
+// FindAuditEventsPaged mocks base method.
+func (m *MockIAuditEventRepository) FindAuditEventsPaged(ctx context.Context, filter bson.M, paginationParams utils.PaginationParams) ([]models.AuditEvent, utils.PageInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAuditEventsPaged", ctx, filter, paginationParams)
+	ret0, _ := ret[0].([]models.AuditEvent)
+	ret1, _ := ret[1].(utils.PageInfo)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindAuditEventsPaged indicates an expected call of FindAuditEventsPaged.
+func (mr *MockIAuditEventRepositoryMockRecorder) FindAuditEventsPaged(ctx, filter, paginationParams interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAuditEventsPaged", reflect.TypeOf((*MockIAuditEventRepository)(nil).FindAuditEventsPaged), ctx, filter, paginationParams)
+}
+
+// IterateAuditEvents mocks base method.
+func (m *MockIAuditEventRepository) IterateAuditEvents(ctx context.Context, filter bson.M, fn func(*models.AuditEvent) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IterateAuditEvents", ctx, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IterateAuditEvents indicates an expected call of IterateAuditEvents.
+func (mr *MockIAuditEventRepositoryMockRecorder) IterateAuditEvents(ctx, filter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IterateAuditEvents", reflect.TypeOf((*MockIAuditEventRepository)(nil).IterateAuditEvents), ctx, filter, fn)
+}
+
+// MockIUsageRepository is a mock of IUsageRepository interface.
+type MockIUsageRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIUsageRepositoryMockRecorder
+}
+
+// MockIUsageRepositoryMockRecorder is the mock recorder for MockIUsageRepository.
+type MockIUsageRepositoryMockRecorder struct {
+	mock *MockIUsageRepository
+}
+
+// NewMockIUsageRepository creates a new mock instance.
+func NewMockIUsageRepository(ctrl *gomock.Controller) *MockIUsageRepository {
+	mock := &MockIUsageRepository{ctrl: ctrl}
+	mock.recorder = &MockIUsageRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIUsageRepository) EXPECT() *MockIUsageRepositoryMockRecorder {
+	return m.recorder
+}
+
+// ComputeBucketUsage mocks base method.
+func (m *MockIUsageRepository) ComputeBucketUsage(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) (models.UsageDelta, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ComputeBucketUsage", ctx, bucketUID, deletedAt)
+	ret0, _ := ret[0].(models.UsageDelta)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ComputeBucketUsage indicates an expected call of ComputeBucketUsage.
+func (mr *MockIUsageRepositoryMockRecorder) ComputeBucketUsage(ctx, bucketUID, deletedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ComputeBucketUsage", reflect.TypeOf((*MockIUsageRepository)(nil).ComputeBucketUsage), ctx, bucketUID, deletedAt)
+}
+
+// DeleteBucketUsage mocks base method.
+func (m *MockIUsageRepository) DeleteBucketUsage(ctx context.Context, bucketUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucketUsage", ctx, bucketUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBucketUsage indicates an expected call of DeleteBucketUsage.
+func (mr *MockIUsageRepositoryMockRecorder) DeleteBucketUsage(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucketUsage", reflect.TypeOf((*MockIUsageRepository)(nil).DeleteBucketUsage), ctx, bucketUID)
+}
+
+// DeleteUserUsage mocks base method.
+func (m *MockIUsageRepository) DeleteUserUsage(ctx context.Context, userID primitive.ObjectID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserUsage", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserUsage indicates an expected call of DeleteUserUsage.
+func (mr *MockIUsageRepositoryMockRecorder) DeleteUserUsage(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserUsage", reflect.TypeOf((*MockIUsageRepository)(nil).DeleteUserUsage), ctx, userID)
+}
+
+// FindBucketUsage mocks base method.
+func (m *MockIUsageRepository) FindBucketUsage(ctx context.Context, bucketUID string) (*models.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindBucketUsage", ctx, bucketUID)
+	ret0, _ := ret[0].(*models.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindBucketUsage indicates an expected call of FindBucketUsage.
+func (mr *MockIUsageRepositoryMockRecorder) FindBucketUsage(ctx, bucketUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketUsage", reflect.TypeOf((*MockIUsageRepository)(nil).FindBucketUsage), ctx, bucketUID)
+}
+
+// FindBucketUsages mocks base method.
+func (m *MockIUsageRepository) FindBucketUsages(ctx context.Context, userID primitive.ObjectID) ([]models.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindBucketUsages", ctx, userID)
+	ret0, _ := ret[0].([]models.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindBucketUsages indicates an expected call of FindBucketUsages.
+func (mr *MockIUsageRepositoryMockRecorder) FindBucketUsages(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindBucketUsages", reflect.TypeOf((*MockIUsageRepository)(nil).FindBucketUsages), ctx, userID)
+}
+
+// FindUserUsage mocks base method.
+func (m *MockIUsageRepository) FindUserUsage(ctx context.Context, userID primitive.ObjectID) (*models.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUserUsage", ctx, userID)
+	ret0, _ := ret[0].(*models.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUserUsage indicates an expected call of FindUserUsage.
+func (mr *MockIUsageRepositoryMockRecorder) FindUserUsage(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUserUsage", reflect.TypeOf((*MockIUsageRepository)(nil).FindUserUsage), ctx, userID)
+}
+
+// IncrementUsage mocks base method.
+func (m *MockIUsageRepository) IncrementUsage(ctx context.Context, userID primitive.ObjectID, bucketUID string, delta models.UsageDelta, quota models.Quota) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrementUsage", ctx, userID, bucketUID, delta, quota)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrementUsage indicates an expected call of IncrementUsage.
+func (mr *MockIUsageRepositoryMockRecorder) IncrementUsage(ctx, userID, bucketUID, delta, quota interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementUsage", reflect.TypeOf((*MockIUsageRepository)(nil).IncrementUsage), ctx, userID, bucketUID, delta, quota)
+}
+
+// RecomputeUsage mocks base method.
+func (m *MockIUsageRepository) RecomputeUsage(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecomputeUsage", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecomputeUsage indicates an expected call of RecomputeUsage.
+func (mr *MockIUsageRepositoryMockRecorder) RecomputeUsage(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecomputeUsage", reflect.TypeOf((*MockIUsageRepository)(nil).RecomputeUsage), ctx)
+}
+
+// UpdateUserQuota mocks base method.
+func (m *MockIUsageRepository) UpdateUserQuota(ctx context.Context, userID primitive.ObjectID, quota *models.Quota) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserQuota", ctx, userID, quota)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUserQuota indicates an expected call of UpdateUserQuota.
+func (mr *MockIUsageRepositoryMockRecorder) UpdateUserQuota(ctx, userID, quota interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserQuota", reflect.TypeOf((*MockIUsageRepository)(nil).UpdateUserQuota), ctx, userID, quota)
+}
+
+// MockILoginAttemptRepository is a mock of ILoginAttemptRepository interface.
+type MockILoginAttemptRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockILoginAttemptRepositoryMockRecorder
+}
+
+// MockILoginAttemptRepositoryMockRecorder is the mock recorder for MockILoginAttemptRepository.
+type MockILoginAttemptRepositoryMockRecorder struct {
+	mock *MockILoginAttemptRepository
+}
+
+// NewMockILoginAttemptRepository creates a new mock instance.
+func NewMockILoginAttemptRepository(ctrl *gomock.Controller) *MockILoginAttemptRepository {
+	mock := &MockILoginAttemptRepository{ctrl: ctrl}
+	mock.recorder = &MockILoginAttemptRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockILoginAttemptRepository) EXPECT() *MockILoginAttemptRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DeleteLoginAttempts mocks base method.
+func (m *MockILoginAttemptRepository) DeleteLoginAttempts(ctx context.Context, ids ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range ids {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DeleteLoginAttempts", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLoginAttempts indicates an expected call of DeleteLoginAttempts.
+func (mr *MockILoginAttemptRepositoryMockRecorder) DeleteLoginAttempts(ctx interface{}, ids ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, ids...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLoginAttempts", reflect.TypeOf((*MockILoginAttemptRepository)(nil).DeleteLoginAttempts), varargs...)
+}
+
+// FindLoginAttempts mocks base method.
+func (m *MockILoginAttemptRepository) FindLoginAttempts(ctx context.Context, ids ...string) ([]models.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range ids {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FindLoginAttempts", varargs...)
+	ret0, _ := ret[0].([]models.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindLoginAttempts indicates an expected call of FindLoginAttempts.
+func (mr *MockILoginAttemptRepositoryMockRecorder) FindLoginAttempts(ctx interface{}, ids ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, ids...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindLoginAttempts", reflect.TypeOf((*MockILoginAttemptRepository)(nil).FindLoginAttempts), varargs...)
+}
+
+// LockLogin mocks base method.
+func (m *MockILoginAttemptRepository) LockLogin(ctx context.Context, id string, lockedUntil time.Time, window time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLogin", ctx, id, lockedUntil, window)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockLogin indicates an expected call of LockLogin.
+func (mr *MockILoginAttemptRepositoryMockRecorder) LockLogin(ctx, id, lockedUntil, window interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLogin", reflect.TypeOf((*MockILoginAttemptRepository)(nil).LockLogin), ctx, id, lockedUntil, window)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockILoginAttemptRepository) RecordLoginFailure(ctx context.Context, id string, window time.Duration) (*models.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, id, window)
+	ret0, _ := ret[0].(*models.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockILoginAttemptRepositoryMockRecorder) RecordLoginFailure(ctx, id, window interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockILoginAttemptRepository)(nil).RecordLoginFailure), ctx, id, window)
+}
+
+// MockISessionRepository is a mock of ISessionRepository interface.
+type MockISessionRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockISessionRepositoryMockRecorder
+}
+
+// MockISessionRepositoryMockRecorder is the mock recorder for MockISessionRepository.
+type MockISessionRepositoryMockRecorder struct {
+	mock *MockISessionRepository
+}
+
+// NewMockISessionRepository creates a new mock instance.
+func NewMockISessionRepository(ctrl *gomock.Controller) *MockISessionRepository {
+	mock := &MockISessionRepository{ctrl: ctrl}
+	mock.recorder = &MockISessionRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockISessionRepository) EXPECT() *MockISessionRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateSession mocks base method.
+func (m *MockISessionRepository) CreateSession(ctx context.Context, session *models.Session) (primitive.ObjectID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, session)
+	ret0, _ := ret[0].(primitive.ObjectID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockISessionRepositoryMockRecorder) CreateSession(ctx, session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockISessionRepository)(nil).CreateSession), ctx, session)
+}
+
+// DeleteUserSessions mocks base method.
+func (m *MockISessionRepository) DeleteUserSessions(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserSessions", ctx, userID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteUserSessions indicates an expected call of DeleteUserSessions.
+func (mr *MockISessionRepositoryMockRecorder) DeleteUserSessions(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserSessions", reflect.TypeOf((*MockISessionRepository)(nil).DeleteUserSessions), ctx, userID)
+}
+
+// FindActiveSessions mocks base method.
+func (m *MockISessionRepository) FindActiveSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindActiveSessions", ctx, userID)
+	ret0, _ := ret[0].([]models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindActiveSessions indicates an expected call of FindActiveSessions.
+func (mr *MockISessionRepositoryMockRecorder) FindActiveSessions(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindActiveSessions", reflect.TypeOf((*MockISessionRepository)(nil).FindActiveSessions), ctx, userID)
+}
+
+// FindSessionByID mocks base method.
+func (m *MockISessionRepository) FindSessionByID(ctx context.Context, id string) (*models.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSessionByID", ctx, id)
+	ret0, _ := ret[0].(*models.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSessionByID indicates an expected call of FindSessionByID.
+func (mr *MockISessionRepositoryMockRecorder) FindSessionByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSessionByID", reflect.TypeOf((*MockISessionRepository)(nil).FindSessionByID), ctx, id)
+}
+
+// RevokeSession mocks base method.
+func (m *MockISessionRepository) RevokeSession(ctx context.Context, id primitive.ObjectID, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, id, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockISessionRepositoryMockRecorder) RevokeSession(ctx, id, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockISessionRepository)(nil).RevokeSession), ctx, id, reason)
+}
+
+// RevokeUserSessions mocks base method.
+func (m *MockISessionRepository) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, reason string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserSessions", ctx, userID, reason)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeUserSessions indicates an expected call of RevokeUserSessions.
+func (mr *MockISessionRepositoryMockRecorder) RevokeUserSessions(ctx, userID, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserSessions", reflect.TypeOf((*MockISessionRepository)(nil).RevokeUserSessions), ctx, userID, reason)
+}
+
+// RotateSession mocks base method.
+func (m *MockISessionRepository) RotateSession(ctx context.Context, session *models.Session, previousHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSession", ctx, session, previousHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RotateSession indicates an expected call of RotateSession.
+func (mr *MockISessionRepositoryMockRecorder) RotateSession(ctx, session, previousHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSession", reflect.TypeOf((*MockISessionRepository)(nil).RotateSession), ctx, session, previousHash)
+}
+
+// MockITwoFactorRepository is a mock of ITwoFactorRepository interface.
+type MockITwoFactorRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockITwoFactorRepositoryMockRecorder
+}
+
+// MockITwoFactorRepositoryMockRecorder is the mock recorder for MockITwoFactorRepository.
+type MockITwoFactorRepositoryMockRecorder struct {
+	mock *MockITwoFactorRepository
+}
+
+// NewMockITwoFactorRepository creates a new mock instance.
+func NewMockITwoFactorRepository(ctrl *gomock.Controller) *MockITwoFactorRepository {
+	mock := &MockITwoFactorRepository{ctrl: ctrl}
+	mock.recorder = &MockITwoFactorRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockITwoFactorRepository) EXPECT() *MockITwoFactorRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DeleteTwoFactor mocks base method.
+func (m *MockITwoFactorRepository) DeleteTwoFactor(ctx context.Context, userID primitive.ObjectID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTwoFactor", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTwoFactor indicates an expected call of DeleteTwoFactor.
+func (mr *MockITwoFactorRepositoryMockRecorder) DeleteTwoFactor(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTwoFactor", reflect.TypeOf((*MockITwoFactorRepository)(nil).DeleteTwoFactor), ctx, userID)
+}
+
+// EnableTwoFactor mocks base method.
+func (m *MockITwoFactorRepository) EnableTwoFactor(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTwoFactor", ctx, userID, secret, recoveryCodes, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableTwoFactor indicates an expected call of EnableTwoFactor.
+func (mr *MockITwoFactorRepositoryMockRecorder) EnableTwoFactor(ctx, userID, secret, recoveryCodes, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTwoFactor", reflect.TypeOf((*MockITwoFactorRepository)(nil).EnableTwoFactor), ctx, userID, secret, recoveryCodes, step)
+}
+
+// FindTwoFactor mocks base method.
+func (m *MockITwoFactorRepository) FindTwoFactor(ctx context.Context, userID primitive.ObjectID) (*models.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTwoFactor", ctx, userID)
+	ret0, _ := ret[0].(*models.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTwoFactor indicates an expected call of FindTwoFactor.
+func (mr *MockITwoFactorRepositoryMockRecorder) FindTwoFactor(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTwoFactor", reflect.TypeOf((*MockITwoFactorRepository)(nil).FindTwoFactor), ctx, userID)
+}
+
+// ReplaceRecoveryCodes mocks base method.
+func (m *MockITwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID primitive.ObjectID, recoveryCodes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceRecoveryCodes", ctx, userID, recoveryCodes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceRecoveryCodes indicates an expected call of ReplaceRecoveryCodes.
+func (mr *MockITwoFactorRepositoryMockRecorder) ReplaceRecoveryCodes(ctx, userID, recoveryCodes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceRecoveryCodes", reflect.TypeOf((*MockITwoFactorRepository)(nil).ReplaceRecoveryCodes), ctx, userID, recoveryCodes)
+}
+
+// SavePendingSecret mocks base method.
+func (m *MockITwoFactorRepository) SavePendingSecret(ctx context.Context, userID primitive.ObjectID, secret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePendingSecret", ctx, userID, secret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SavePendingSecret indicates an expected call of SavePendingSecret.
+func (mr *MockITwoFactorRepositoryMockRecorder) SavePendingSecret(ctx, userID, secret interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePendingSecret", reflect.TypeOf((*MockITwoFactorRepository)(nil).SavePendingSecret), ctx, userID, secret)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockITwoFactorRepository) UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, userID, codeHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockITwoFactorRepositoryMockRecorder) UseRecoveryCode(ctx, userID, codeHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockITwoFactorRepository)(nil).UseRecoveryCode), ctx, userID, codeHash)
+}
+
+// UseTwoFactorStep mocks base method.
+func (m *MockITwoFactorRepository) UseTwoFactorStep(ctx context.Context, userID primitive.ObjectID, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTwoFactorStep", ctx, userID, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseTwoFactorStep indicates an expected call of UseTwoFactorStep.
+func (mr *MockITwoFactorRepositoryMockRecorder) UseTwoFactorStep(ctx, userID, step interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBucketItem", reflect.TypeOf((*MockIBucketItemRepository)(nil).UpdateBucketItem), bucketItem, key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTwoFactorStep", reflect.TypeOf((*MockITwoFactorRepository)(nil).UseTwoFactorStep), ctx, userID, step)
 }
@@ -15,7 +15,15 @@ type APIKey struct {
 	KeyType     string                `bson:"key_type,omitempty" json:"key_type"`
 	Role        string                `bson:"role,omitempty" json:"role"`
 	Permissions APIKeyPermissionsList `bson:"permissions,omitempty" json:"permissions"`
+	RateLimit   *APIKeyRateLimit      `bson:"rate_limit,omitempty" json:"rate_limit,omitempty"`
 	ExpiresAt   primitive.DateTime    `bson:"expires_at,omitempty" json:"expires_at"`
 	CreatedAt   primitive.DateTime    `bson:"created_at,omitempty" json:"created_at"`
 	UpdatedAt   primitive.DateTime    `bson:"updated_at,omitempty" json:"updated_at"`
 }
+
+// Rate limit of the requests made with an API key, it replaces the limits of the route groups it is lower than
+// a limit of 0 requests removes it
+type APIKeyRateLimit struct {
+	Requests      int `bson:"requests" json:"requests" validate:"gte=0" example:"100"`
+	PeriodSeconds int `bson:"period_seconds" json:"period_seconds" validate:"gte=0" example:"60"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Actions recorded in the audit log
+const (
+	AuditActionLogin             = "auth.login"
+	AuditActionLoginFailed       = "auth.login_failed"
+	AuditActionLoginLocked       = "auth.login_locked"
+	AuditActionLoginUnlocked     = "auth.login_unlocked"
+	AuditActionTokenRefresh      = "auth.token_refresh"
+	AuditActionTokenReuse        = "auth.refresh_token_reuse"
+	AuditActionLogout            = "auth.logout"
+	AuditActionLogoutAll         = "auth.logout_all"
+	AuditActionTwoFactorEnable   = "auth.two_factor_enable"
+	AuditActionTwoFactorDisable  = "auth.two_factor_disable"
+	AuditActionRecoveryCodeUse   = "auth.recovery_code_use"
+	AuditActionRecoveryCodesNew  = "auth.recovery_codes_regenerate"
+	AuditActionAPIKeyCreate      = "api_key.create"
+	AuditActionAPIKeyRevoke      = "api_key.revoke"
+	AuditActionAPIKeyUse         = "api_key.use"
+	AuditActionBucketPermissions = "bucket.permissions_change"
+	AuditActionBucketTwoFactor   = "bucket.two_factor_change"
+	AuditActionItemCreate        = "item.create"
+	AuditActionItemUpdate        = "item.update"
+	AuditActionItemDelete        = "item.delete"
+	AuditActionItemRestore       = "item.restore"
+)
+
+// Credential type of a login with an email and a password
+const AuditCredentialTypePassword = "password"
+
+// Audit actor struct - Who performed an audited action and from where
+type AuditActor struct {
+	UserID         primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	CredentialType string             `bson:"credential_type,omitempty" json:"credential_type,omitempty"`
+	APIKeyMaskID   string             `bson:"api_key_mask_id,omitempty" json:"api_key_mask_id,omitempty"`
+	IP             string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent      string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+}
+
+// Audit event struct - A security-relevant or data-changing action, kept as an append-only log
+// the user of a failed login is the owner of the account it targeted, if any
+type AuditEvent struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Action     string             `bson:"action" json:"action"`
+	AuditActor `bson:",inline"`
+	RequestID  string                 `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	BucketUID  string                 `bson:"bucket_uid,omitempty" json:"bucket_uid,omitempty"`
+	Key        string                 `bson:"key,omitempty" json:"key,omitempty"`
+	Target     string                 `bson:"target,omitempty" json:"target,omitempty"` // e.g. the api key or the email of a login
+	Before     map[string]interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After      map[string]interface{} `bson:"after,omitempty" json:"after,omitempty"`
+	CreatedAt  primitive.DateTime     `bson:"created_at" json:"created_at"`
+}
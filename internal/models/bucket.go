@@ -4,12 +4,19 @@ import "go.mongodb.org/mongo-driver/bson/primitive"
 
 // Bucket struct - A bucket is sort of a container that holds key/value pairs (for a specific group)
 type Bucket struct {
-	ID          primitive.ObjectID    `bson:"_id,omitempty" json:"id"`
-	UID         string                `bson:"uid,omitempty" json:"uid"`         // a shorter id for the bucket
-	UserID      primitive.ObjectID    `bson:"user_id,omitempty" json:"user_id"` // bucket owner
-	Name        string                `bson:"name,omitempty" json:"name"`
-	Description string                `bson:"description,omitempty" json:"description"`
-	Permissions BucketPermissionsList `bson:"permissions,omitempty" json:"permissions"`
-	CreatedAt   primitive.DateTime    `bson:"created_at,omitempty" json:"created_at"`
-	UpdatedAt   primitive.DateTime    `bson:"updated_at,omitempty" json:"updated_at"`
+	ID               primitive.ObjectID    `bson:"_id,omitempty" json:"id"`
+	UID              string                `bson:"uid,omitempty" json:"uid"`         // a shorter id for the bucket
+	UserID           primitive.ObjectID    `bson:"user_id,omitempty" json:"user_id"` // bucket owner
+	Name             string                `bson:"name,omitempty" json:"name"`
+	Description      string                `bson:"description,omitempty" json:"description"`
+	Permissions      BucketPermissionsList `bson:"permissions,omitempty" json:"permissions"`
+	RequireTwoFactor bool                  `bson:"require_two_factor,omitempty" json:"require_two_factor"` // the owner and the users writing to the bucket must have 2FA enabled
+	CreatedAt        primitive.DateTime    `bson:"created_at,omitempty" json:"created_at"`
+	UpdatedAt        primitive.DateTime    `bson:"updated_at,omitempty" json:"updated_at"`
+	DeletedAt        primitive.DateTime    `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"` // set when the bucket is moved to the trash
+}
+
+// Checks if the bucket is in the trash
+func (b *Bucket) IsTrashed() bool {
+	return b.DeletedAt != 0
 }
@@ -0,0 +1,23 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Operations recorded in the change feed of a bucket
+const (
+	BucketChangeOpSet    = "set"    // the item was created or its value changed
+	BucketChangeOpDelete = "delete" // the item was deleted or moved to the trash
+	BucketChangeOpExpire = "expire" // the TTL of the item ran out
+	BucketChangeOpReset  = "reset"  // many items changed at once, consumers should resync the whole bucket
+)
+
+// Bucket change struct - An entry of the change feed of a bucket
+// the sequence numbers of the changes of a bucket are monotonically increasing
+type BucketChange struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	BucketUID string             `bson:"bucket_uid" json:"bucket_uid"`
+	Seq       int64              `bson:"seq" json:"seq"`
+	Op        string             `bson:"op" json:"op"`
+	Key       string             `bson:"key,omitempty" json:"key,omitempty"`
+	CreatedAt primitive.DateTime `bson:"created_at" json:"created_at"`
+	ExpiresAt primitive.DateTime `bson:"expires_at,omitempty" json:"-"` // the change is deleted once the retention runs out
+}
@@ -1,19 +1,65 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	BucketItemTypeBinary = "binary"
+)
+
 // Bucket item struct
 type BucketItem struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"`
-	BucketID  primitive.ObjectID `bson:"bucket_id,omitempty" json:"bucket_id"`
-	BucketUID string             `bson:"bucket_uid,omitempty" json:"bucket_uid"`
-	Key       string             `bson:"key,omitempty" json:"key"`
-	Data      interface{}        `bson:"data,omitempty" json:"data"`
-	TTL       int                `bson:"ttl,omitempty" json:"ttl"`
-	Type      string             `bson:"type,omitempty" json:"type"`
-	CreatedAt primitive.DateTime `bson:"created_at,omitempty" json:"created_at"`
-	UpdatedAt primitive.DateTime `bson:"updated_at,omitempty" json:"updated_at"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"`
+	BucketID    primitive.ObjectID `bson:"bucket_id,omitempty" json:"bucket_id"`
+	BucketUID   string             `bson:"bucket_uid,omitempty" json:"bucket_uid"`
+	Key         string             `bson:"key,omitempty" json:"key"`
+	Data        interface{}        `bson:"data,omitempty" json:"data"`
+	TTL         int                `bson:"ttl,omitempty" json:"ttl"`
+	Type        string             `bson:"type,omitempty" json:"type"`
+	ContentType string             `bson:"content_type,omitempty" json:"content_type,omitempty"` // mime type of binary values
+	Size        int64              `bson:"size,omitempty" json:"size,omitempty"`                 // size of binary values in bytes
+	Hash        string             `bson:"hash,omitempty" json:"hash,omitempty"`                 // hex encoded sha256 of binary values
+	FileID      primitive.ObjectID `bson:"file_id,omitempty" json:"-"`                           // gridfs file id for large binary values
+	Compression string             `bson:"compression,omitempty" json:"-"`                       // algorithm used to compress the stored data
+	RawSize     int64              `bson:"raw_size,omitempty" json:"-"`                          // size of the encoded data before compression
+	StoredSize  int64              `bson:"stored_size,omitempty" json:"-"`                       // size of the compressed data
+	CreatedAt   primitive.DateTime `bson:"created_at,omitempty" json:"created_at"`
+	UpdatedAt   primitive.DateTime `bson:"updated_at,omitempty" json:"updated_at"`
+	DeletedAt   primitive.DateTime `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"` // set when the item is moved to the trash
+	ExpiredAt   primitive.DateTime `bson:"expired_at,omitempty" json:"-"`                    // set once the expiry of the item has been notified
+}
+
+// Checks if the bucket item has expired
+// the bucket item has expired if the current time is greater than the
+// sum of the time the item was created and the specified TTL (in seconds) of the item
+func (b *BucketItem) IsExpired() bool {
+	return b.TTL != 0 && time.Now().After(b.CreatedAt.Time().Add(time.Duration(b.TTL)*time.Second))
+}
+
+// Checks if the bucket item value is stored in GridFS
+func (b *BucketItem) IsStoredInGridFS() bool {
+	return !b.FileID.IsZero()
+}
+
+// Checks if the bucket item is in the trash
+func (b *BucketItem) IsTrashed() bool {
+	return b.DeletedAt != 0
+}
+
+// Checks if the bucket item data is stored compressed
+func (b *BucketItem) IsCompressed() bool {
+	return b.Compression != ""
+}
+
+// Compression statistics of the items in a bucket
+type BucketItemCompressionStats struct {
+	BucketUID       string  `bson:"_id" json:"bucket_uid"`
+	CompressedItems int64   `bson:"compressed_items" json:"compressed_items"`
+	RawSize         int64   `bson:"raw_size" json:"raw_size"`
+	StoredSize      int64   `bson:"stored_size" json:"stored_size"`
+	Ratio           float64 `bson:"-" json:"ratio"` // raw size divided by the stored size
 }
@@ -0,0 +1,21 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// Bucket snapshot struct - A named, read-only copy of the items of a bucket at a point in time
+type BucketSnapshot struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BucketUID   string             `bson:"bucket_uid,omitempty" json:"bucket_uid"`
+	UserID      primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"` // snapshot creator
+	Name        string             `bson:"name,omitempty" json:"name"`
+	Description string             `bson:"description,omitempty" json:"description"`
+	ItemCount   int                `bson:"item_count" json:"item_count"`
+	CreatedAt   primitive.DateTime `bson:"created_at,omitempty" json:"created_at"`
+}
+
+// Strategies for resolving the keys that differ between the source and target of a bucket merge
+const (
+	MergeStrategyFail      = "fail"      // abort the merge if any key differs
+	MergeStrategyOverwrite = "overwrite" // replace the target value with the source value
+	MergeStrategySkip      = "skip"      // keep the target value
+)
@@ -3,28 +3,166 @@ package models
 import "errors"
 
 var (
-	ErrUserNotFound        = errors.New("user not found")
-	ErrInvalidObjectID     = errors.New("invalid object id")
-	ErrUsersNotFound       = errors.New("users not found")
-	ErrUpdatingUser        = errors.New("error updating user")
-	ErrDeletingUser        = errors.New("error deleting user")
-	ErrUserAlreadyExists   = errors.New("user already exists")
-	ErrAPIKeyNotFound      = errors.New("api key not found")
-	ErrUpdatingAPIKey      = errors.New("error updating api key")
-	ErrRevokingAPIKey      = errors.New("error revoking api key")
-	ErrRevokingAPIKeys     = errors.New("error revoking api keys")
-	ErrDeletingAPIKey      = errors.New("error deleting api key")
-	ErrDeletingAPIKeys     = errors.New("error deleting api keys")
-	ErrAPIKeysNotFound     = errors.New("api keys not found")
-	ErrBucketNotFound      = errors.New("bucket not found")
-	ErrBucketsNotFound     = errors.New("buckets not found")
-	ErrUpdatingBucket      = errors.New("error updating bucket")
-	ErrDeletingBucket      = errors.New("error deleting bucket")
-	ErrBucketItemsNotFound = errors.New("bucket items not found")
-	ErrBucketItemNotFound  = errors.New("bucket item not found")
-	ErrBucketItemExpired   = errors.New("bucket item has expired")
-	ErrUpdatingBucketItem  = errors.New("error updating bucket item")
-	ErrDeletingBucketItem  = errors.New("error deleting bucket item")
-	ErrDeletingBucketItems = errors.New("error deleting bucket items")
-	ErrIncorrectPassword   = errors.New("incorrect password")
+	ErrUserNotFound             = errors.New("user not found")
+	ErrInvalidObjectID          = errors.New("invalid object id")
+	ErrUsersNotFound            = errors.New("users not found")
+	ErrUpdatingUser             = errors.New("error updating user")
+	ErrDeletingUser             = errors.New("error deleting user")
+	ErrUserAlreadyExists        = errors.New("user already exists")
+	ErrBucketAlreadyExists      = errors.New("bucket already exists")
+	ErrBucketItemAlreadyExists  = errors.New("bucket item already exists")
+	ErrConflict                 = errors.New("conflict")
+	ErrValidationFailed         = errors.New("validation failed")
+	ErrBadRequest               = errors.New("bad request")
+	ErrRequestTimeout           = errors.New("request timed out")
+	ErrAPIKeyNotFound           = errors.New("api key not found")
+	ErrUpdatingAPIKey           = errors.New("error updating api key")
+	ErrRevokingAPIKey           = errors.New("error revoking api key")
+	ErrRevokingAPIKeys          = errors.New("error revoking api keys")
+	ErrDeletingAPIKey           = errors.New("error deleting api key")
+	ErrDeletingAPIKeys          = errors.New("error deleting api keys")
+	ErrAPIKeysNotFound          = errors.New("api keys not found")
+	ErrBucketNotFound           = errors.New("bucket not found")
+	ErrBucketsNotFound          = errors.New("buckets not found")
+	ErrUpdatingBucket           = errors.New("error updating bucket")
+	ErrDeletingBucket           = errors.New("error deleting bucket")
+	ErrBucketItemsNotFound      = errors.New("bucket items not found")
+	ErrBucketItemNotFound       = errors.New("bucket item not found")
+	ErrBucketItemExpired        = errors.New("bucket item has expired")
+	ErrUpdatingBucketItem       = errors.New("error updating bucket item")
+	ErrDeletingBucketItem       = errors.New("error deleting bucket item")
+	ErrDeletingBucketItems      = errors.New("error deleting bucket items")
+	ErrIncorrectPassword        = errors.New("incorrect password")
+	ErrBucketItemTooLarge       = errors.New("bucket item value exceeds the maximum size")
+	ErrBucketItemNotBinary      = errors.New("bucket item is not a binary value")
+	ErrUploadingBlob            = errors.New("error uploading blob")
+	ErrDeletingBlob             = errors.New("error deleting blob")
+	ErrBlobNotFound             = errors.New("blob not found")
+	ErrCompressingItem          = errors.New("error compressing bucket item")
+	ErrDecompressingItem        = errors.New("error decompressing bucket item")
+	ErrEnqueuingTask            = errors.New("error enqueuing task")
+	ErrTrashingBucket           = errors.New("error moving bucket to the trash")
+	ErrTrashingBucketItem       = errors.New("error moving bucket item to the trash")
+	ErrRestoringBucket          = errors.New("error restoring bucket")
+	ErrRestoringBucketItem      = errors.New("error restoring bucket item")
+	ErrUserDeletionNotScheduled = errors.New("user deletion is not scheduled")
+	ErrUserDeletionInProgress   = errors.New("user deletion is already in progress")
+	ErrRecordingBucketChange    = errors.New("error recording bucket change")
+	ErrBucketChangesNotFound    = errors.New("bucket changes not found")
+	ErrSnapshotNotFound         = errors.New("snapshot not found")
+	ErrSnapshotAlreadyExists    = errors.New("snapshot already exists")
+	ErrCreatingSnapshot         = errors.New("error creating snapshot")
+	ErrDeletingSnapshot         = errors.New("error deleting snapshot")
+	ErrCopyingBlob              = errors.New("error copying blob")
+	ErrMergeConflict            = errors.New("merge conflict")
+	ErrWebhookNotFound          = errors.New("webhook not found")
+	ErrWebhooksNotFound         = errors.New("webhooks not found")
+	ErrDeletingWebhook          = errors.New("error deleting webhook")
+	ErrWebhookDeliveryNotFound  = errors.New("webhook delivery not found")
+	ErrUpdatingWebhookDelivery  = errors.New("error updating webhook delivery")
+	ErrRecordingAuditEvent      = errors.New("error recording audit event")
+	ErrAuditEventsNotFound      = errors.New("audit events not found")
+	ErrRateLimitExceeded        = errors.New("rate limit exceeded")
+	ErrBucketQuotaExceeded      = errors.New("bucket quota exceeded")
+	ErrItemQuotaExceeded        = errors.New("bucket item quota exceeded")
+	ErrStorageQuotaExceeded     = errors.New("storage quota exceeded")
+	ErrValueQuotaExceeded       = errors.New("bucket item value exceeds the value size quota")
+	ErrFindingUsage             = errors.New("error finding usage")
+	ErrUpdatingUsage            = errors.New("error updating usage")
+	ErrComputingUsage           = errors.New("error computing usage")
+	ErrInvalidCredentials       = errors.New("invalid email or password")
+	ErrLoginLocked              = errors.New("too many failed login attempts, try again later")
+	ErrInvalidUnlockToken       = errors.New("invalid or expired unlock token")
+	ErrRecordingLoginAttempt    = errors.New("error recording login attempt")
+	ErrFindingLoginAttempts     = errors.New("error finding login attempts")
+	ErrSessionNotFound          = errors.New("session not found")
+	ErrSessionRevoked           = errors.New("session has been revoked or has expired")
+	ErrRefreshTokenReused       = errors.New("refresh token has already been used")
+	ErrCreatingSession          = errors.New("error creating session")
+	ErrUpdatingSession          = errors.New("error updating session")
+	ErrFindingSessions          = errors.New("error finding sessions")
+	ErrDeletingSessions         = errors.New("error deleting sessions")
+	ErrTwoFactorAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotEnrolled     = errors.New("two-factor authentication enrollment has not been started")
+	ErrInvalidTwoFactorCode     = errors.New("invalid two-factor code")
+	ErrInvalidTwoFactorToken    = errors.New("invalid or expired two-factor token")
+	ErrTwoFactorRequired        = errors.New("two-factor authentication is required by the bucket")
+	ErrFindingTwoFactor         = errors.New("error finding two-factor authentication")
+	ErrUpdatingTwoFactor        = errors.New("error updating two-factor authentication")
 )
+
+// Error returned when a write conflicts with the unique field of an existing document
+// it matches ErrConflict and the error it wraps, e.g. ErrUserAlreadyExists, with errors.Is
+type ConflictError struct {
+	Err   error
+	Field string // the unique field holding the duplicated value
+	Value string
+}
+
+func NewConflictError(err error, field string, value string) *ConflictError {
+	return &ConflictError{Err: err, Field: field, Value: value}
+}
+
+func (e *ConflictError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Error returned when a request is invalid, e.g. a required parameter is empty
+// it matches ErrBadRequest and the error it wraps with errors.Is
+type BadRequestError struct {
+	Err error
+}
+
+func NewBadRequestError(err error) *BadRequestError {
+	return &BadRequestError{Err: err}
+}
+
+func (e *BadRequestError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BadRequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BadRequestError) Is(target error) bool {
+	return target == ErrBadRequest
+}
+
+// Error returned when the validation of a request fails, it holds an entry per invalid field
+// and matches ErrValidationFailed with errors.Is
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Validation failure of a single field
+type FieldError struct {
+	Field   string `json:"field" swaggertype:"string"` // the JSON name of the field
+	Rule    string `json:"rule" swaggertype:"string"`  // the failed validation rule, e.g. required
+	Message string `json:"message" swaggertype:"string"`
+}
+
+func NewValidationError(fields []FieldError) *ValidationError {
+	return &ValidationError{Fields: fields}
+}
+
+// Returns the message of the first invalid field
+func (e *ValidationError) Error() string {
+	if len(e.Fields) == 0 {
+		return ErrValidationFailed.Error()
+	}
+	return e.Fields[0].Message
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidationFailed
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Failed logins of an account or of an IP address, the document expires with its failure window or its lockout
+type LoginAttempt struct {
+	ID           string             `bson:"_id" json:"-"` // "email:<lowercased email>" or "ip:<ip address>"
+	Failures     int                `bson:"failures" json:"failures"`
+	LastFailedAt primitive.DateTime `bson:"last_failed_at" json:"last_failed_at"`
+	LockedUntil  primitive.DateTime `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	ExpiresAt    primitive.DateTime `bson:"expires_at" json:"expires_at"`
+}
+
+// Returns the ID of the login attempts of an account
+func LoginAttemptEmailID(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// Returns the ID of the login attempts of an IP address
+func LoginAttemptIPID(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Content type of the error responses, see RFC 7807
+const MIMEProblemJSON = "application/problem+json"
+
+// Stable machine-readable code of an API error, clients branch on it rather than on the message
+type ErrorCode string
+
+const (
+	CodeValidationFailed ErrorCode = "validation_failed"
+	CodeInternal         ErrorCode = "internal_error"
+)
+
+// Detail of the server errors, the code of the error tells them apart
+const internalErrorDetail = "the server could not complete the request"
+
+// status and code of the models errors, a wrapped error matches the first of its entries
+var apiErrors = []struct {
+	err    error
+	status int
+	code   ErrorCode
+}{
+	{ErrValidationFailed, http.StatusBadRequest, CodeValidationFailed},
+	{ErrInvalidObjectID, http.StatusBadRequest, "invalid_object_id"},
+	{ErrBadRequest, http.StatusBadRequest, "bad_request"},
+	{ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{ErrIncorrectPassword, http.StatusUnauthorized, "incorrect_password"},
+	{ErrInvalidUnlockToken, http.StatusBadRequest, "invalid_unlock_token"},
+	{ErrSessionRevoked, http.StatusUnauthorized, "session_revoked"},
+	{ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{ErrInvalidTwoFactorCode, http.StatusUnauthorized, "invalid_two_factor_code"},
+	{ErrInvalidTwoFactorToken, http.StatusUnauthorized, "invalid_two_factor_token"},
+	{ErrTwoFactorNotEnabled, http.StatusBadRequest, "two_factor_not_enabled"},
+	{ErrTwoFactorNotEnrolled, http.StatusBadRequest, "two_factor_not_enrolled"},
+	{ErrTwoFactorAlreadyEnabled, http.StatusConflict, "two_factor_already_enabled"},
+	{ErrTwoFactorRequired, http.StatusForbidden, "two_factor_required"},
+	{ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{ErrUsersNotFound, http.StatusNotFound, "users_not_found"},
+	{ErrAPIKeyNotFound, http.StatusNotFound, "api_key_not_found"},
+	{ErrAPIKeysNotFound, http.StatusNotFound, "api_keys_not_found"},
+	{ErrBucketNotFound, http.StatusNotFound, "bucket_not_found"},
+	{ErrBucketsNotFound, http.StatusNotFound, "buckets_not_found"},
+	{ErrBucketItemNotFound, http.StatusNotFound, "bucket_item_not_found"},
+	{ErrSessionNotFound, http.StatusNotFound, "session_not_found"},
+	{ErrBucketItemsNotFound, http.StatusNotFound, "bucket_items_not_found"},
+	{ErrBucketItemExpired, http.StatusNotFound, "bucket_item_expired"},
+	{ErrBucketChangesNotFound, http.StatusNotFound, "bucket_changes_not_found"},
+	{ErrBlobNotFound, http.StatusNotFound, "blob_not_found"},
+	{ErrSnapshotNotFound, http.StatusNotFound, "snapshot_not_found"},
+	{ErrWebhookNotFound, http.StatusNotFound, "webhook_not_found"},
+	{ErrWebhooksNotFound, http.StatusNotFound, "webhooks_not_found"},
+	{ErrWebhookDeliveryNotFound, http.StatusNotFound, "webhook_delivery_not_found"},
+	{ErrAuditEventsNotFound, http.StatusNotFound, "audit_events_not_found"},
+	{ErrUserDeletionNotScheduled, http.StatusNotFound, "user_deletion_not_scheduled"},
+	{ErrUserDeletionInProgress, http.StatusConflict, "user_deletion_in_progress"},
+	{ErrUserAlreadyExists, http.StatusConflict, "user_already_exists"},
+	{ErrBucketAlreadyExists, http.StatusConflict, "bucket_already_exists"},
+	{ErrBucketItemAlreadyExists, http.StatusConflict, "bucket_item_already_exists"},
+	{ErrSnapshotAlreadyExists, http.StatusConflict, "snapshot_already_exists"},
+	{ErrMergeConflict, http.StatusConflict, "merge_conflict"},
+	{ErrConflict, http.StatusConflict, "conflict"},
+	{ErrBucketItemTooLarge, http.StatusRequestEntityTooLarge, "bucket_item_too_large"},
+	{ErrBucketItemNotBinary, http.StatusBadRequest, "bucket_item_not_binary"},
+	{ErrBucketQuotaExceeded, http.StatusForbidden, "bucket_quota_exceeded"},
+	{ErrItemQuotaExceeded, http.StatusForbidden, "item_quota_exceeded"},
+	{ErrStorageQuotaExceeded, http.StatusForbidden, "storage_quota_exceeded"},
+	{ErrValueQuotaExceeded, http.StatusRequestEntityTooLarge, "value_quota_exceeded"},
+	{ErrEnqueuingTask, http.StatusInternalServerError, "task_enqueue_failed"},
+	{ErrRequestTimeout, http.StatusGatewayTimeout, "request_timeout"},
+	{ErrRateLimitExceeded, http.StatusTooManyRequests, "rate_limit_exceeded"},
+	{ErrLoginLocked, http.StatusTooManyRequests, "login_locked"},
+}
+
+// Error returned by the handlers and middlewares, the HTTP error handler renders it as a problem
+type APIError struct {
+	Status int
+	Code   ErrorCode
+	Err    error
+	Field  string       // the unique field of a conflict
+	Errors []FieldError // the invalid fields of a validation failure
+}
+
+// Returns the API error of err with the given status, the code is the one of the models error it wraps
+// or the generic code of the status
+func NewAPIError(status int, err error) *APIError {
+	apiErr := &APIError{Status: status, Code: StatusCode(status), Err: err}
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			apiErr.Code = e.code
+			break
+		}
+	}
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		apiErr.Field = conflict.Field
+	}
+	var validation *ValidationError
+	if errors.As(err, &validation) {
+		apiErr.Errors = validation.Fields
+	}
+	return apiErr
+}
+
+// Returns the API error of err, the status of the models error it wraps takes precedence over fallback
+// the handlers fall back to a 500, the invalid requests are the errors that match ErrBadRequest
+func ToAPIError(err error, fallback int) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			return NewAPIError(e.status, err)
+		}
+	}
+	return NewAPIError(fallback, err)
+}
+
+// Returns the generic code of an HTTP status, e.g. not_found for a 404
+func StatusCode(status int) ErrorCode {
+	if status == http.StatusInternalServerError {
+		return CodeInternal
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return ErrorCode(strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)))
+}
+
+func (e *APIError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Returns the problem details of the error for the request path
+// the detail of a server error is generic, its error may tell about the internals and is logged instead
+func (e *APIError) Problem(instance string) *Problem {
+	detail := e.Err.Error()
+	if e.Status >= http.StatusInternalServerError {
+		detail = internalErrorDetail
+	}
+	return &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(e.Status),
+		Status:   e.Status,
+		Detail:   detail,
+		Instance: instance,
+		Code:     e.Code,
+		Field:    e.Field,
+		Errors:   e.Errors,
+	}
+}
+
+// Body of the error responses, the problem details of RFC 7807 extended with the code of the error
+type Problem struct {
+	Type     string       `json:"type" swaggertype:"string" example:"about:blank"`
+	Title    string       `json:"title" swaggertype:"string" example:"Not Found"`
+	Status   int          `json:"status" swaggertype:"integer" example:"404"`
+	Detail   string       `json:"detail,omitempty" swaggertype:"string" example:"bucket not found"`
+	Instance string       `json:"instance,omitempty" swaggertype:"string" example:"/api/v1/bucket/uid"`
+	Code     ErrorCode    `json:"code" swaggertype:"string" example:"bucket_not_found"`
+	Field    string       `json:"field,omitempty" swaggertype:"string"` // the unique field of a conflict
+	Errors   []FieldError `json:"errors,omitempty"`                     // the invalid fields of a validation failure
+}
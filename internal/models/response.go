@@ -14,8 +14,3 @@ type PaginatedSuccessResponse struct {
 	Data     interface{}    `json:"data,omitempty" swaggertype:"array,object"`
 	PageInfo utils.PageInfo `json:"page_info,omitempty"`
 }
-
-type ErrorResponse struct {
-	Status bool   `json:"status" swaggertype:"boolean"`
-	Error  string `json:"error" swaggertype:"string"`
-}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons a session was revoked
+const (
+	SessionRevokedLogout         = "logout"
+	SessionRevokedLogoutAll      = "logout_all"
+	SessionRevokedTokenReuse     = "refresh_token_reuse"
+	SessionRevokedPasswordReset  = "password_reset"
+	SessionRevokedPasswordChange = "password_change"
+)
+
+// Session struct - The refresh tokens issued from one login, each refresh replaces the token of the session
+// a refresh token that was already replaced revokes the whole session
+type Session struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"-"`
+	TokenHash     string             `bson:"token_hash" json:"-"` // hex encoded sha256 of the current refresh token
+	IP            string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent     string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	CreatedAt     primitive.DateTime `bson:"created_at" json:"created_at"`
+	LastUsedAt    primitive.DateTime `bson:"last_used_at" json:"last_used_at"`
+	ExpiresAt     primitive.DateTime `bson:"expires_at" json:"expires_at"` // expiry of the current refresh token
+	RevokedAt     primitive.DateTime `bson:"revoked_at,omitempty" json:"-"`
+	RevokedReason string             `bson:"revoked_reason,omitempty" json:"-"`
+}
+
+// Checks if the session can still be refreshed and authenticate requests
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == 0 && s.ExpiresAt.Time().After(now)
+}
@@ -0,0 +1,21 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// TwoFactor struct - The TOTP secret and recovery codes of a user, kept apart from the user so that they are never loaded with it
+// the secret is pending until the user confirms it with a first code
+type TwoFactor struct {
+	UserID        primitive.ObjectID `bson:"_id" json:"-"`
+	Secret        string             `bson:"secret,omitempty" json:"-"`         // base32 encoded, set once the enrollment is confirmed
+	PendingSecret string             `bson:"pending_secret,omitempty" json:"-"` // secret of an enrollment waiting for its first code
+	RecoveryCodes []string           `bson:"recovery_codes,omitempty" json:"-"` // hex encoded sha256 of the unused recovery codes
+	LastUsedStep  int64              `bson:"last_used_step" json:"-"`           // time step of the last accepted code, a code is only accepted once
+	EnabledAt     primitive.DateTime `bson:"enabled_at,omitempty" json:"enabled_at,omitempty"`
+	CreatedAt     primitive.DateTime `bson:"created_at,omitempty" json:"created_at"`
+	UpdatedAt     primitive.DateTime `bson:"updated_at,omitempty" json:"updated_at"`
+}
+
+// Checks if the enrollment was confirmed
+func (t *TwoFactor) IsEnabled() bool {
+	return t.Secret != ""
+}
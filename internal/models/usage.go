@@ -0,0 +1,75 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Storage consumed by a user, or by one of its buckets, the trashed buckets and items are not counted
+// it is maintained on the writes and recomputed from the stored data by the workers
+type Usage struct {
+	ID        string             `bson:"_id" json:"-"` // "user:<user id>" or "bucket:<bucket uid>"
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	BucketUID string             `bson:"bucket_uid,omitempty" json:"bucket_uid,omitempty"`
+	Buckets   int64              `bson:"buckets" json:"buckets"` // only counted for the users
+	Items     int64              `bson:"items" json:"items"`
+	Bytes     int64              `bson:"bytes" json:"bytes"`
+	Quota     *Quota             `bson:"quota,omitempty" json:"-"` // quota override of a user
+	UpdatedAt primitive.DateTime `bson:"updated_at" json:"updated_at"`
+}
+
+// Change of the usage of a user and of one of its buckets
+type UsageDelta struct {
+	Buckets int64
+	Items   int64
+	Bytes   int64
+}
+
+// Returns the delta undoing d
+func (d UsageDelta) Negate() UsageDelta {
+	return UsageDelta{Buckets: -d.Buckets, Items: -d.Items, Bytes: -d.Bytes}
+}
+
+// Limits of the storage of a user, a limit of 0 is unlimited
+type Quota struct {
+	MaxBuckets        int64 `bson:"max_buckets" json:"max_buckets" example:"100"`
+	MaxItemsPerBucket int64 `bson:"max_items_per_bucket" json:"max_items_per_bucket" example:"10000"`
+	MaxValueBytes     int64 `bson:"max_value_bytes" json:"max_value_bytes" example:"1048576"`
+	MaxStorageBytes   int64 `bson:"max_storage_bytes" json:"max_storage_bytes" example:"1073741824"`
+}
+
+// Returns the quota with the limits of an override replacing its own,
+// the limits left at 0 in the override are kept and the negative ones are lifted
+func (q Quota) Override(override *Quota) Quota {
+	if override == nil {
+		return q
+	}
+	merge := func(limit int64, value int64) int64 {
+		switch {
+		case value < 0:
+			return 0
+		case value > 0:
+			return value
+		}
+		return limit
+	}
+	return Quota{
+		MaxBuckets:        merge(q.MaxBuckets, override.MaxBuckets),
+		MaxItemsPerBucket: merge(q.MaxItemsPerBucket, override.MaxItemsPerBucket),
+		MaxValueBytes:     merge(q.MaxValueBytes, override.MaxValueBytes),
+		MaxStorageBytes:   merge(q.MaxStorageBytes, override.MaxStorageBytes),
+	}
+}
+
+// Returns the number of bytes of a bucket item counted in the usage,
+// the size of a binary value or the size of the bson encoding of the other values
+func (b *BucketItem) UsageBytes() int64 {
+	if b.Type == BucketItemTypeBinary {
+		return b.Size
+	}
+	raw, err := bson.Marshal(bson.D{primitive.E{Key: "v", Value: b.Data}})
+	if err != nil {
+		return 0
+	}
+	return int64(len(raw))
+}
@@ -1,11 +1,22 @@
 package models
 
 import (
-	"fmt"
-
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	UserRoleAdmin = "admin"
+)
+
+// Stages of a user account deletion
+const (
+	UserDeletionStageDeletingAPIKeys  = "deleting_api_keys"
+	UserDeletionStageDeletingBuckets  = "deleting_buckets"
+	UserDeletionStageAnonymizingItems = "anonymizing_items"
+	UserDeletionStageDeletingUser     = "deleting_user"
+	UserDeletionStageCompleted        = "completed"
+)
+
 // User struct
 type User struct {
 	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
@@ -16,23 +27,22 @@ type User struct {
 	Password             string             `bson:"password,omitempty" json:"-"`
 	Role                 string             `bson:"role,omitempty" json:"role"`
 	RegistrationProvider string             `bson:"registration_provider,omitempty" json:"registration_provider"`
-	HashedRefreshToken   string             `bson:"hashed_refresh_token,omitempty" json:"-"`
 	EmailVerified        bool               `bson:"email_verified,omitempty" json:"email_verified"`
+	TwoFactorEnabled     bool               `bson:"two_factor_enabled,omitempty" json:"two_factor_enabled"`
 	CreatedAt            primitive.DateTime `bson:"created_at,omitempty" json:"created_at"`
 	UpdatedAt            primitive.DateTime `bson:"updated_at,omitempty" json:"updated_at"`
 }
 
-func (u User) String() {
-	fmt.Printf("ID: %s, Firstname: %s, Lastname: %s, Email: %s, Password: %s, RegistrationProvider: %s, HashedRefreshToken: %s, EmailVerified: %v, CreatedAt: %v, UpdatedAt: %v",
-		u.ID,
-		u.Firstname,
-		u.Lastname,
-		u.Email,
-		u.Password,
-		u.RegistrationProvider,
-		u.HashedRefreshToken,
-		u.EmailVerified,
-		u.CreatedAt,
-		u.UpdatedAt,
-	)
+// Progress of a user account deletion
+type UserDeletionProgress struct {
+	Stage           string `json:"stage"`
+	APIKeysDeleted  int64  `json:"api_keys_deleted"`
+	BucketsTotal    int    `json:"buckets_total"`
+	BucketsDeleted  int    `json:"buckets_deleted"`
+	ItemsAnonymized int64  `json:"items_anonymized"`
+}
+
+// Checks if the user is an administrator
+func (u *User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
 }
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Bucket item events that webhooks can subscribe to
+const (
+	WebhookEventItemCreated = "item.created"
+	WebhookEventItemUpdated = "item.updated"
+	WebhookEventItemDeleted = "item.deleted"
+	WebhookEventItemExpired = "item.expired"
+	WebhookEventTest        = "webhook.test" // sent on demand, whatever the subscribed events
+)
+
+var WEBHOOK_EVENTS = []string{
+	WebhookEventItemCreated,
+	WebhookEventItemUpdated,
+	WebhookEventItemDeleted,
+	WebhookEventItemExpired,
+}
+
+// Status of a webhook delivery
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusRetrying  = "retrying"
+	WebhookDeliveryStatusSucceeded = "succeeded"
+	WebhookDeliveryStatusFailed    = "failed"
+)
+
+// Webhook struct - An endpoint notified of the events of a bucket
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	BucketUID string             `bson:"bucket_uid,omitempty" json:"bucket_uid"`
+	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id"` // webhook creator
+	URL       string             `bson:"url,omitempty" json:"url"`
+	Events    []string           `bson:"events,omitempty" json:"events"`
+	Secret    string             `bson:"secret,omitempty" json:"-"` // key of the HMAC signature of the deliveries
+	CreatedAt primitive.DateTime `bson:"created_at,omitempty" json:"created_at"`
+	UpdatedAt primitive.DateTime `bson:"updated_at,omitempty" json:"updated_at"`
+}
+
+// Checks if the webhook is subscribed to an event
+func (w *Webhook) IsSubscribed(event string) bool {
+	if event == WebhookEventTest {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook delivery struct - A single event sent to a webhook, kept as a log that can be replayed
+type WebhookDelivery struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebhookID   primitive.ObjectID `bson:"webhook_id,omitempty" json:"webhook_id"`
+	BucketUID   string             `bson:"bucket_uid,omitempty" json:"bucket_uid"`
+	Event       string             `bson:"event,omitempty" json:"event"`
+	Payload     string             `bson:"payload,omitempty" json:"payload"` // the request body
+	Status      string             `bson:"status,omitempty" json:"status"`
+	Attempts    int                `bson:"attempts,omitempty" json:"attempts"`
+	StatusCode  int                `bson:"status_code,omitempty" json:"status_code,omitempty"` // response status of the last attempt
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`             // error of the last attempt
+	ReplayOf    primitive.ObjectID `bson:"replay_of,omitempty" json:"replay_of,omitempty"`
+	CreatedAt   primitive.DateTime `bson:"created_at,omitempty" json:"created_at"`
+	UpdatedAt   primitive.DateTime `bson:"updated_at,omitempty" json:"updated_at"`
+	DeliveredAt primitive.DateTime `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+}
+
+// Webhook event struct - The body of a webhook delivery
+type WebhookEvent struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	BucketUID string            `json:"bucket_uid"`
+	CreatedAt time.Time         `json:"created_at"`
+	Item      *WebhookEventItem `json:"item,omitempty"`
+}
+
+// The bucket item an event is about
+type WebhookEventItem struct {
+	Key  string      `json:"key"`
+	Type string      `json:"type,omitempty"`
+	Data interface{} `json:"data,omitempty"`
+	TTL  int         `json:"ttl,omitempty"`
+}
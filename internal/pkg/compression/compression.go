@@ -0,0 +1,124 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	AlgorithmZstd = "zstd"
+	AlgorithmGzip = "gzip"
+)
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported compression algorithm")
+
+// supported algorithms in order of preference
+var algorithms = []string{AlgorithmZstd, AlgorithmGzip}
+
+// Checks if the compression algorithm is supported
+func IsSupported(algorithm string) bool {
+	for _, a := range algorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// Compresses data with the specified algorithm
+// Accepts the algorithm and the data to compress
+// Returns the compressed data and an error
+func Compress(algorithm string, data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(algorithm, buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompresses data that was compressed with the specified algorithm
+// Accepts the algorithm and the compressed data
+// Returns the decompressed data and an error
+func Decompress(algorithm string, data []byte) ([]byte, error) {
+	switch algorithm {
+	case AlgorithmZstd:
+		r, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case AlgorithmGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// Creates a writer that compresses everything written to it into 'w'
+// the writer must be closed to flush the remaining compressed data
+func NewWriter(algorithm string, w io.Writer) (io.WriteCloser, error) {
+	switch algorithm {
+	case AlgorithmZstd:
+		return zstd.NewWriter(w)
+	case AlgorithmGzip:
+		return gzip.NewWriter(w), nil
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+// Picks the preferred supported algorithm from an Accept-Encoding header value
+// Returns an empty string if none of the supported algorithms is accepted
+func Negotiate(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		encoding := strings.ToLower(strings.TrimSpace(params[0]))
+		if encoding == "" {
+			continue
+		}
+		// an encoding with a quality value of 0 is explicitly rejected
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[encoding] = q > 0
+	}
+	for _, algorithm := range algorithms {
+		if ok, found := accepted[algorithm]; found {
+			if ok {
+				return algorithm
+			}
+			continue
+		}
+		if accepted["*"] {
+			return algorithm
+		}
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package compression
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression_RoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"feature_flags":{"dark_mode":true}}`), 100)
+
+	tt := []struct {
+		name      string
+		algorithm string
+		wantErr   bool
+	}{
+		{name: "should_round_trip_zstd", algorithm: AlgorithmZstd},
+		{name: "should_round_trip_gzip", algorithm: AlgorithmGzip},
+		{name: "should_fail_unsupported_algorithm", algorithm: "br", wantErr: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			compressed, err := Compress(tc.algorithm, data)
+			if tc.wantErr {
+				require.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+				return
+			}
+			require.Nil(t, err)
+			require.Less(t, len(compressed), len(data))
+
+			decompressed, err := Decompress(tc.algorithm, compressed)
+			require.Nil(t, err)
+			require.Equal(t, data, decompressed)
+		})
+	}
+}
+
+func TestCompression_Negotiate(t *testing.T) {
+	tt := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{name: "should_prefer_zstd", acceptEncoding: "gzip, deflate, br, zstd", want: AlgorithmZstd},
+		{name: "should_fallback_to_gzip", acceptEncoding: "gzip, deflate", want: AlgorithmGzip},
+		{name: "should_skip_rejected_encoding", acceptEncoding: "zstd;q=0, gzip;q=0.5", want: AlgorithmGzip},
+		{name: "should_accept_wildcard", acceptEncoding: "*", want: AlgorithmZstd},
+		{name: "should_return_empty_for_identity", acceptEncoding: "identity", want: ""},
+		{name: "should_return_empty_for_missing_header", acceptEncoding: "", want: ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, Negotiate(tc.acceptEncoding))
+		})
+	}
+}
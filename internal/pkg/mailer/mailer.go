@@ -76,6 +76,25 @@ func (m *Mailer) SendResetPasswordMail(receiverEmailAddr string, receiverName st
 	return err
 }
 
+// Send a mail containing the login unlock link
+func (m *Mailer) SendLoginUnlockMail(receiverEmailAddr string, receiverName string, subject string, templateData interface{}) error {
+	body, err := m.ParseTemplate(
+		"./templates/unlock-account.html",
+		templateData,
+	)
+	if err != nil {
+		logrus.WithError(err).Error(err.Error())
+		return err
+	}
+	err = m.SendEmail(
+		receiverEmailAddr,
+		receiverName,
+		subject,
+		body,
+	)
+	return err
+}
+
 func (m *Mailer) ParseTemplate(templateName string, templateData interface{}) (string, error) {
 	t, err := template.New("").ParseFiles(templateName, "./templates/base.html")
 	if err != nil {
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const namespace = "kipa"
+
+// Registry of the metrics of the process, it is served next to the queue metrics by Handler
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by route and status code.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the HTTP requests by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	bucketOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "bucket",
+		Name:      "operations_total",
+		Help:      "Number of read, write and delete requests by bucket.",
+	}, []string{"bucket", "operation"})
+	authFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "failures_total",
+		Help:      "Number of rejected credentials by credential type.",
+	}, []string{"credential_type"})
+	mongoOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mongo",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the Mongo commands by collection, command and outcome.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"collection", "command", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		bucketOperations,
+		authFailures,
+		mongoOperationDuration,
+	)
+}
+
+// Operations counted by bucket, derived from the method of the request
+const (
+	BucketOperationRead   = "read"
+	BucketOperationWrite  = "write"
+	BucketOperationDelete = "delete"
+)
+
+// Records a served HTTP request, route is the path of the matched route rather than the URL
+// so that the number of series stays bounded
+func ObserveHTTPRequest(method string, route string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// Records an operation on a bucket
+func IncBucketOperation(bucketUID string, operation string) {
+	bucketOperations.WithLabelValues(bucketUID, operation).Inc()
+}
+
+// Returns the bucket operation of a request method
+func BucketOperation(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return BucketOperationRead
+	case http.MethodDelete:
+		return BucketOperationDelete
+	default:
+		return BucketOperationWrite
+	}
+}
+
+// Credential types of the auth failures besides the ones of the auth package
+const (
+	CredentialTypePassword = "password" // the credentials of a login
+	CredentialTypeInvalid  = "invalid"  // a missing or malformed authorization header
+)
+
+// Records a rejected credential
+func IncAuthFailure(credentialType string) {
+	authFailures.WithLabelValues(credentialType).Inc()
+}
+
+// Returns a command monitor timing the commands sent to Mongo,
+// it is set on the client so that every repository call is measured
+func MongoMonitor() *event.CommandMonitor {
+	// collections of the commands in flight by request id, the finished events do not carry it
+	collections := sync.Map{}
+	observe := func(requestID int64, command string, status string, durationNanos int64) {
+		collection, ok := collections.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		mongoOperationDuration.WithLabelValues(collection.(string), command, status).
+			Observe(time.Duration(durationNanos).Seconds())
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			// the collection is the value of the command, except for the cursors
+			collection := ""
+			if elem, err := e.Command.IndexErr(0); err == nil {
+				collection, _ = elem.Value().StringValueOK()
+			}
+			if e.CommandName == "getMore" {
+				collection, _ = e.Command.Lookup("collection").StringValueOK()
+			}
+			collections.Store(e.RequestID, collection)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			observe(e.RequestID, e.CommandName, "success", e.DurationNanos)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			observe(e.RequestID, e.CommandName, "failure", e.DurationNanos)
+		},
+	}
+}
+
+// Returns the handler of the metrics endpoint, the queue metrics are read through the inspector on each scrape
+func Handler(inspector *asynq.Inspector) http.Handler {
+	queueRegistry := prometheus.NewRegistry()
+	queueRegistry.MustRegister(&queueCollector{inspector: inspector})
+	return promhttp.HandlerFor(
+		prometheus.Gatherers{Registry, queueRegistry},
+		promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError},
+	)
+}
+
+var (
+	queueTasksDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "queue", "tasks"),
+		"Number of tasks in the queue by state.",
+		[]string{"queue", "state"}, nil,
+	)
+	queueLatencyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "queue", "latency_seconds"),
+		"Age of the oldest pending task of the queue.",
+		[]string{"queue"}, nil,
+	)
+	queueProcessedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "queue", "processed_total"),
+		"Number of tasks processed by the queue.",
+		[]string{"queue"}, nil,
+	)
+	queueFailedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "queue", "failed_total"),
+		"Number of tasks of the queue that failed to be processed.",
+		[]string{"queue"}, nil,
+	)
+)
+
+// collects the depth and failures of the asynq queues
+type queueCollector struct {
+	inspector *asynq.Inspector
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueTasksDesc
+	ch <- queueLatencyDesc
+	ch <- queueProcessedDesc
+	ch <- queueFailedDesc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	queues, err := c.inspector.Queues()
+	if err != nil {
+		logrus.WithError(err).Error("error listing the queues")
+		return
+	}
+	for _, queue := range queues {
+		info, err := c.inspector.GetQueueInfo(queue)
+		if err != nil {
+			logrus.WithError(err).Errorf("error inspecting queue: %s", queue)
+			continue
+		}
+		for state, count := range map[string]int{
+			"pending":     info.Pending,
+			"active":      info.Active,
+			"scheduled":   info.Scheduled,
+			"retry":       info.Retry,
+			"archived":    info.Archived,
+			"completed":   info.Completed,
+			"aggregating": info.Aggregating,
+		} {
+			ch <- prometheus.MustNewConstMetric(queueTasksDesc, prometheus.GaugeValue, float64(count), queue, state)
+		}
+		ch <- prometheus.MustNewConstMetric(queueLatencyDesc, prometheus.GaugeValue, info.Latency.Seconds(), queue)
+		ch <- prometheus.MustNewConstMetric(queueProcessedDesc, prometheus.CounterValue, float64(info.ProcessedTotal), queue)
+		ch <- prometheus.MustNewConstMetric(queueFailedDesc, prometheus.CounterValue, float64(info.FailedTotal), queue)
+	}
+}
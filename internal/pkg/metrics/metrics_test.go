@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMetrics_BucketOperation(t *testing.T) {
+	tt := []struct {
+		method string
+		want   string
+	}{
+		{method: http.MethodGet, want: BucketOperationRead},
+		{method: http.MethodHead, want: BucketOperationRead},
+		{method: http.MethodPost, want: BucketOperationWrite},
+		{method: http.MethodPut, want: BucketOperationWrite},
+		{method: http.MethodPatch, want: BucketOperationWrite},
+		{method: http.MethodDelete, want: BucketOperationDelete},
+	}
+	for _, tc := range tt {
+		t.Run(tc.method, func(t *testing.T) {
+			assert.Equal(t, tc.want, BucketOperation(tc.method))
+		})
+	}
+}
+
+func TestMetrics_ObserveHTTPRequest(t *testing.T) {
+	ObserveHTTPRequest(http.MethodGet, "/api/v1/bucket/:bucketUID", http.StatusOK, 20*time.Millisecond)
+	ObserveHTTPRequest(http.MethodGet, "/api/v1/bucket/:bucketUID", http.StatusOK, 30*time.Millisecond)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/api/v1/bucket/:bucketUID", "200")))
+	assert.Equal(t, uint64(2), sampleCount(t, httpRequestDuration.WithLabelValues(http.MethodGet, "/api/v1/bucket/:bucketUID")))
+}
+
+func TestMetrics_MongoMonitor(t *testing.T) {
+	monitor := MongoMonitor()
+	find, _ := bson.Marshal(bson.D{{Key: "find", Value: "buckets"}})
+	getMore, _ := bson.Marshal(bson.D{{Key: "getMore", Value: int64(1)}, {Key: "collection", Value: "buckets"}})
+	ctx := context.Background()
+
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: find, CommandName: "find", RequestID: 1})
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: getMore, CommandName: "getMore", RequestID: 2})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1, DurationNanos: int64(time.Millisecond)}})
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "getMore", RequestID: 2, DurationNanos: int64(time.Millisecond)}})
+
+	assert.Equal(t, 2, testutil.CollectAndCount(mongoOperationDuration))
+	assert.Equal(t, uint64(1), sampleCount(t, mongoOperationDuration.WithLabelValues("buckets", "find", "success")))
+	assert.Equal(t, uint64(1), sampleCount(t, mongoOperationDuration.WithLabelValues("buckets", "getMore", "failure")))
+}
+
+// Returns the number of observations of a histogram
+func sampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+	metric := &dto.Metric{}
+	assert.Nil(t, observer.(prometheus.Histogram).Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// interval between the sweeps of the full buckets
+const memorySweepInterval = time.Minute
+
+type memoryBucket struct {
+	tokens float64
+	at     time.Time
+	full   time.Time // time at which the bucket is full again and can be forgotten
+}
+
+// Store holding the token buckets in memory, each server limits the requests it serves on its own
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   map[string]*memoryBucket{},
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(limit.Requests)
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, at: now}
+		s.buckets[key] = bucket
+	}
+	elapsed := float64(now.Sub(bucket.at).Milliseconds())
+	bucket.tokens = math.Min(capacity, bucket.tokens+math.Max(0, elapsed)*limit.rate())
+	bucket.at = now
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	result := newResult(limit, bucket.tokens, allowed)
+	bucket.full = now.Add(result.ResetAfter)
+	return result, nil
+}
+
+// forgets the full buckets, they are recreated full on the next take
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidLimit = errors.New("rate limit must be formatted as requests/period, e.g. 100/1m")
+)
+
+// Token bucket limit, the bucket holds up to Requests tokens and is refilled with Requests tokens per Period
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Parses a limit formatted as "requests/period", e.g. "100/1m"
+func ParseLimit(value string) (Limit, error) {
+	requests, period, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return Limit{}, ErrInvalidLimit
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// tokens added to the bucket per millisecond
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / (float64(l.Period) / float64(time.Millisecond))
+}
+
+// Outcome of taking a token from a bucket
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until a token is available, 0 if the request is allowed
+}
+
+// Returns the result of a take from a bucket of limit left with tokens
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	rate := limit.rate()
+	result := Result{
+		Allowed:    allowed,
+		Limit:      limit.Requests,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: time.Duration(math.Ceil((float64(limit.Requests)-tokens)/rate)) * time.Millisecond,
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration(math.Ceil((1-tokens)/rate)) * time.Millisecond
+	}
+	return result
+}
+
+// Store of the token buckets, shared by the servers when it is backed by Redis
+type Store interface {
+	// Takes a token from the bucket of key, the request is allowed if there was one
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// time during which a failed primary store is skipped
+const fallbackCooldown = 10 * time.Second
+
+// Store falling back to another one when its primary store fails, e.g. when Redis is unreachable,
+// the primary store is skipped for a while after a failure so that the requests are not slowed down by it
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+	retryAt  atomic.Int64
+}
+
+func NewFallbackStore(primary Store, fallback Store) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback}
+}
+
+func (s *FallbackStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	if time.Now().UnixNano() >= s.retryAt.Load() {
+		result, err := s.primary.Take(ctx, key, limit)
+		if err == nil {
+			return result, nil
+		}
+		logrus.WithContext(ctx).WithError(err).Warnf("rate limit store failed, falling back for %s", fallbackCooldown)
+		s.retryAt.Store(time.Now().Add(fallbackCooldown).UnixNano())
+	}
+	return s.fallback.Take(ctx, key, limit)
+}
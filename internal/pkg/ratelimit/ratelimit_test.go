@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tt := []struct {
+		name    string
+		value   string
+		want    Limit
+		wantErr error
+	}{
+		{name: "should_parse_limit", value: "100/1m", want: Limit{Requests: 100, Period: time.Minute}},
+		{name: "should_parse_limit_with_spaces", value: " 5 / 10s ", want: Limit{Requests: 5, Period: 10 * time.Second}},
+		{name: "should_fail_missing_period", value: "100", wantErr: ErrInvalidLimit},
+		{name: "should_fail_zero_requests", value: "0/1m", wantErr: ErrInvalidLimit},
+		{name: "should_fail_invalid_period", value: "100/minute", wantErr: ErrInvalidLimit},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			limit, err := ParseLimit(tc.value)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.want, limit)
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+	ctx := context.Background()
+
+	result, _ := store.Take(ctx, "user:1", limit)
+	require.True(t, result.Allowed)
+	require.Equal(t, 1, result.Remaining)
+	require.Equal(t, 5*time.Second, result.ResetAfter)
+
+	result, _ = store.Take(ctx, "user:1", limit)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	result, _ = store.Take(ctx, "user:1", limit)
+	require.False(t, result.Allowed)
+	require.Equal(t, 5*time.Second, result.RetryAfter)
+
+	// the buckets of the other keys are not drained
+	result, _ = store.Take(ctx, "user:2", limit)
+	require.True(t, result.Allowed)
+
+	// a token is added every 5 seconds
+	now = now.Add(5 * time.Second)
+	result, _ = store.Take(ctx, "user:1", limit)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+}
+
+type failingStore struct {
+	calls int
+}
+
+func (s *failingStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.calls++
+	return Result{}, errors.New("connection refused")
+}
+
+func TestFallbackStore_Take(t *testing.T) {
+	primary := &failingStore{}
+	store := NewFallbackStore(primary, NewMemoryStore())
+	limit := Limit{Requests: 1, Period: time.Second}
+
+	result, err := store.Take(context.Background(), "ip:203.0.113.7", limit)
+	require.Nil(t, err)
+	require.True(t, result.Allowed)
+
+	// the failed primary store is skipped during the cooldown
+	result, err = store.Take(context.Background(), "ip:203.0.113.7", limit)
+	require.Nil(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 1, primary.calls)
+}
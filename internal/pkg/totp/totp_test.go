@@ -0,0 +1,66 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOTP_Code(t *testing.T) {
+	// test vectors of RFC 6238 for HMAC-SHA1, truncated to the 6 digits of the codes
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tt := []struct {
+		name string
+		time int64
+		want string
+	}{
+		{name: "should_generate_code_at_59", time: 59, want: "287082"},
+		{name: "should_generate_code_at_1111111109", time: 1111111109, want: "081804"},
+		{name: "should_generate_code_at_1234567890", time: 1234567890, want: "005924"},
+		{name: "should_generate_code_at_2000000000", time: 2000000000, want: "279037"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			code, err := Code(secret, Step(time.Unix(tc.time, 0)))
+			require.Nil(t, err)
+			require.Equal(t, tc.want, code)
+		})
+	}
+}
+
+func TestTOTP_Validate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.Nil(t, err)
+	now := time.Now()
+	code, err := Code(secret, Step(now))
+	require.Nil(t, err)
+
+	step, ok := Validate(secret, code, now)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+	// the code of the previous step is accepted for the clock drift
+	_, ok = Validate(secret, code, now.Add(Period))
+	require.True(t, ok)
+	_, ok = Validate(secret, code, now.Add(3*Period))
+	require.False(t, ok)
+	_, ok = Validate(secret, "12345", now)
+	require.False(t, ok)
+	_, ok = Validate("not base32!", "123456", now)
+	require.False(t, ok)
+}
+
+func TestTOTP_ProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(ProvisioningURI("JBSWY3DPEHPK3PXP", "Kipa", "me@gmail.com"))
+	require.Nil(t, err)
+	require.Equal(t, "otpauth", uri.Scheme)
+	require.Equal(t, "totp", uri.Host)
+	require.Equal(t, "/Kipa:me@gmail.com", uri.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+	require.Equal(t, "Kipa", uri.Query().Get("issuer"))
+	require.Equal(t, "6", uri.Query().Get("digits"))
+}
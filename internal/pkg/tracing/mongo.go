@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Returns a command monitor starting a span for each command sent to Mongo,
+// the span is the child of the one of the context the repository was called with
+func MongoMonitor() *event.CommandMonitor {
+	// spans of the commands in flight by request id
+	spans := sync.Map{}
+	end := func(requestID int64, err error) {
+		if span, ok := spans.LoadAndDelete(requestID); ok {
+			End(span.(trace.Span), err)
+		}
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			collection := ""
+			if elem, err := e.Command.IndexErr(0); err == nil {
+				collection, _ = elem.Value().StringValueOK()
+			}
+			_, span := Start(ctx, "mongo."+e.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemMongoDB,
+					semconv.DBName(e.DatabaseName),
+					semconv.DBOperation(e.CommandName),
+					semconv.DBMongoDBCollection(collection),
+				),
+			)
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			end(e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			end(e.RequestID, errFailedCommand(e.Failure))
+		},
+	}
+}
+
+// error of a failed command, the event only carries its message
+type errFailedCommand string
+
+func (e errFailedCommand) Error() string {
+	return string(e)
+}
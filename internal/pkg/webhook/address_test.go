@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsForbiddenIP(t *testing.T) {
+	tt := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: false},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: false},
+		{ip: "127.0.0.1", want: true},
+		{ip: "::1", want: true},
+		{ip: "10.1.2.3", want: true},
+		{ip: "172.16.0.1", want: true},
+		{ip: "192.168.0.1", want: true},
+		{ip: "169.254.169.254", want: true},
+		{ip: "100.64.0.10", want: true},
+		{ip: "0.0.0.0", want: true},
+		{ip: "fd00::1", want: true},
+		{ip: "fe80::1", want: true},
+		{ip: "::ffff:10.0.0.1", want: true},
+		{ip: "64:ff9b::a00:1", want: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.ip, func(t *testing.T) {
+			require.Equal(t, tc.want, IsForbiddenIP(net.ParseIP(tc.ip)))
+		})
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	redirects := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirects++
+		http.Redirect(w, r, server.URL, http.StatusFound)
+	}))
+	defer server.Close()
+
+	// the loopback is refused when connecting
+	_, err := NewHTTPClient(time.Second, false).Get(server.URL)
+	require.ErrorIs(t, err, ErrForbiddenAddress)
+	require.Equal(t, 0, redirects)
+
+	// the redirects are capped
+	_, err = NewHTTPClient(time.Second, true).Get(server.URL)
+	require.NotNil(t, err)
+	require.Equal(t, maxRedirects, redirects)
+}
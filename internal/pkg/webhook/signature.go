@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every webhook delivery
+const (
+	HeaderEvent     = "X-Kipa-Event"
+	HeaderDelivery  = "X-Kipa-Delivery"
+	HeaderTimestamp = "X-Kipa-Timestamp"
+	HeaderSignature = "X-Kipa-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredSignature = errors.New("webhook signature has expired")
+)
+
+// Signs a webhook request body
+// the signature is the hex encoded HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret
+// Accepts the webhook secret, the unix timestamp of the request and the request body
+// Returns the value of the signature header
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifies the signature of a webhook request, as a receiver would
+// requests older than the tolerance are rejected to prevent replays, a zero tolerance disables the check
+// Accepts the webhook secret, the signature and timestamp headers, the request body and the tolerance
+// Returns an error if the signature is not valid
+func Verify(secret string, signature string, timestamp string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 && time.Since(time.Unix(ts, 0)) > tolerance {
+		return ErrExpiredSignature
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature_Verify(t *testing.T) {
+	body := []byte(`{"type":"item.created","bucket_uid":"12345"}`)
+	now := time.Now().Unix()
+	timestamp := strconv.FormatInt(now, 10)
+	signature := Sign("secret", now, body)
+
+	tt := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		tolerance time.Duration
+		wantErr   error
+	}{
+		{name: "should_verify_valid_signature", secret: "secret", signature: signature, timestamp: timestamp, body: body, tolerance: time.Minute},
+		{name: "should_fail_wrong_secret", secret: "other", signature: signature, timestamp: timestamp, body: body, wantErr: ErrInvalidSignature},
+		{name: "should_fail_tampered_body", secret: "secret", signature: signature, timestamp: timestamp, body: []byte(`{}`), wantErr: ErrInvalidSignature},
+		{name: "should_fail_tampered_timestamp", secret: "secret", signature: signature, timestamp: strconv.FormatInt(now+1, 10), body: body, wantErr: ErrInvalidSignature},
+		{name: "should_fail_malformed_signature", secret: "secret", signature: "abc", timestamp: timestamp, body: body, wantErr: ErrInvalidSignature},
+		{
+			name:      "should_fail_expired_signature",
+			secret:    "secret",
+			signature: Sign("secret", now-600, body),
+			timestamp: strconv.FormatInt(now-600, 10),
+			body:      body,
+			tolerance: time.Minute,
+			wantErr:   ErrExpiredSignature,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.secret, tc.signature, tc.timestamp, tc.body, tc.tolerance)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
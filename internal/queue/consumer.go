@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"keeper/internal/config"
+	"keeper/internal/pkg/tracing"
 
 	"github.com/hibiken/asynq"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Consumer struct {
@@ -35,6 +37,7 @@ func NewConsumer(cfg *config.Config) *Consumer {
 	)
 
 	mux := asynq.NewServeMux()
+	mux.Use(traceTask)
 
 	return &Consumer{
 		srv: server,
@@ -55,6 +58,16 @@ func (c *Consumer) Stop() {
 	c.srv.Shutdown()
 }
 
+// Middleware processing a task in a span continuing the request and trace carried by its payload
+func traceTask(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		ctx, span := tracing.Start(extractMetadata(ctx, t.Payload()), "process "+t.Type(), trace.WithSpanKind(trace.SpanKindConsumer))
+		err := next.ProcessTask(ctx, t)
+		tracing.End(span, err)
+		return err
+	})
+}
+
 func (c *Consumer) RegisterHandler(taskName string, handler func(context.Context, *asynq.Task) error) {
 	c.mux.HandleFunc(taskName, handler)
 }
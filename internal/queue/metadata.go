@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"keeper/internal/pkg/tracing"
+	"keeper/pkg/log"
+)
+
+// keys of the metadata added to the JSON payloads of the tasks
+const (
+	payloadRequestIDKey    = "request_id"
+	payloadTraceContextKey = "trace_context"
+)
+
+// metadata of the request a task was enqueued from
+type taskMetadata struct {
+	RequestID    string            `json:"request_id,omitempty"`
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+// Returns the payload with the request ID and the trace context of ctx added,
+// so that the logs and spans of the worker processing the task are tied to the request
+// The payload is returned unchanged when it is not a JSON object
+func injectMetadata(ctx context.Context, payload []byte) []byte {
+	metadata := map[string]interface{}{}
+	if requestID := log.RequestID(ctx); requestID != "" {
+		metadata[payloadRequestIDKey] = requestID
+	}
+	if traceContext := tracing.Inject(ctx); len(traceContext) > 0 {
+		metadata[payloadTraceContextKey] = traceContext
+	}
+	if len(metadata) == 0 {
+		return payload
+	}
+	fields := map[string]interface{}{}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return payload
+		}
+	}
+	for key, value := range metadata {
+		fields[key] = value
+	}
+	injected, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return injected
+}
+
+// Returns ctx with the request ID and the trace context carried by the payload of a task
+func extractMetadata(ctx context.Context, payload []byte) context.Context {
+	metadata := taskMetadata{}
+	if err := json.Unmarshal(payload, &metadata); err != nil {
+		return ctx
+	}
+	if metadata.RequestID != "" {
+		ctx = log.WithRequestID(ctx, metadata.RequestID)
+	}
+	if metadata.TraceContext != nil {
+		ctx = tracing.Extract(ctx, metadata.TraceContext)
+	}
+	return ctx
+}
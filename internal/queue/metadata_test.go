@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"keeper/internal/pkg/tracing"
+	"keeper/pkg/log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestQueue_Metadata(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer provider.Shutdown(context.Background())
+	ctx, span := tracing.Start(log.WithRequestID(context.Background(), "request-12345"), "parent")
+	defer span.End()
+
+	tt := []struct {
+		name          string
+		ctx           context.Context
+		payload       []byte
+		wantFields    map[string]interface{}
+		wantRequestID string
+		wantTraceID   trace.TraceID
+	}{
+		{
+			name:          "should_carry_the_request_of_an_object_payload",
+			ctx:           ctx,
+			payload:       []byte(`{"UserID":"12345"}`),
+			wantFields:    map[string]interface{}{"UserID": "12345"},
+			wantRequestID: "request-12345",
+			wantTraceID:   span.SpanContext().TraceID(),
+		},
+		{
+			name:          "should_carry_the_request_of_an_empty_payload",
+			ctx:           ctx,
+			payload:       nil,
+			wantFields:    map[string]interface{}{},
+			wantRequestID: "request-12345",
+			wantTraceID:   span.SpanContext().TraceID(),
+		},
+		{
+			name:       "should_leave_the_payload_outside_of_a_request",
+			ctx:        context.Background(),
+			payload:    []byte(`{"UserID":"12345"}`),
+			wantFields: map[string]interface{}{"UserID": "12345"},
+		},
+		{
+			name:    "should_leave_a_payload_that_is_not_an_object",
+			ctx:     ctx,
+			payload: []byte(`"12345"`),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := injectMetadata(tc.ctx, tc.payload)
+
+			if tc.wantFields != nil {
+				fields := map[string]interface{}{}
+				assert.Nil(t, json.Unmarshal(payload, &fields))
+				delete(fields, payloadRequestIDKey)
+				delete(fields, payloadTraceContextKey)
+				assert.Equal(t, tc.wantFields, fields)
+			} else {
+				assert.Equal(t, tc.payload, payload)
+			}
+			extracted := extractMetadata(context.Background(), payload)
+			assert.Equal(t, tc.wantRequestID, log.RequestID(extracted))
+			assert.Equal(t, tc.wantTraceID, trace.SpanContextFromContext(extracted).TraceID())
+		})
+	}
+}
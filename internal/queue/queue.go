@@ -1,11 +1,14 @@
 package queue
 
 import (
+	"context"
 	"fmt"
 	"keeper/internal/config"
+	"keeper/internal/pkg/tracing"
 
 	"github.com/hibiken/asynq"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type RedisQueue struct {
@@ -32,11 +35,22 @@ func (q *RedisQueue) Inspector() *asynq.Inspector {
 	return q.inspector
 }
 
-func (q *RedisQueue) Add(task *asynq.Task, opt asynq.Option) error {
-	info, err := q.client.Enqueue(task, opt)
+func (q *RedisQueue) Add(ctx context.Context, task *asynq.Task, opts ...asynq.Option) error {
+	_, err := q.Enqueue(ctx, task, opts...)
+	return err
+}
+
+// Enqueues a task and returns the information of the enqueued task,
+// the request ID and trace context of ctx are added to its payload but its cancellation does not stop the enqueuing
+func (q *RedisQueue) Enqueue(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	ctx, span := tracing.Start(ctx, "enqueue "+task.Type(), trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+	task = asynq.NewTask(task.Type(), injectMetadata(ctx, task.Payload()))
+	info, err := q.client.Enqueue(task, opts...)
 	if err != nil {
-		logrus.Info("enqueued task: id=%s, queue=%s", info.ID, info.Queue)
-		return err
+		logrus.WithError(err).Errorf("error enqueuing task: type=%s", task.Type())
+		return nil, err
 	}
-	return nil
+	logrus.Infof("enqueued task: id=%s, queue=%s", info.ID, info.Queue)
+	return info, nil
 }
@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"fmt"
+	"keeper/internal/config"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+)
+
+// Scheduler enqueues periodic tasks to be processed by the consumer
+type Scheduler struct {
+	scheduler *asynq.Scheduler
+}
+
+func NewScheduler(cfg *config.Config) *Scheduler {
+	redisAddr := fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort)
+	redisConnection := asynq.RedisClientOpt{
+		Addr: redisAddr,
+	}
+	return &Scheduler{
+		scheduler: asynq.NewScheduler(redisConnection, nil),
+	}
+}
+
+// Registers a task to be enqueued on a cron schedule (i.e. "@hourly", "0 3 * * *")
+func (s *Scheduler) Register(cronspec string, task *asynq.Task, opts ...asynq.Option) error {
+	entryID, err := s.scheduler.Register(cronspec, task, opts...)
+	if err != nil {
+		logrus.WithError(err).Errorf("error registering periodic task: type=%s", task.Type())
+		return err
+	}
+	logrus.Infof("registered periodic task: id=%s, type=%s, schedule=%s", entryID, task.Type(), cronspec)
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	logrus.Info("starting asynq scheduler...")
+	if err := s.scheduler.Run(); err != nil {
+		logrus.WithError(err).Fatal("error starting asynq scheduler")
+	}
+}
+
+func (s *Scheduler) Stop() {
+	logrus.Info("stopping asynq scheduler...")
+	s.scheduler.Shutdown()
+}
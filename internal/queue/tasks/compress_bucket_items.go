@@ -0,0 +1,33 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"keeper/internal/config"
+	"keeper/internal/repository"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Returns the handler that compresses the existing bucket items above the compression threshold
+func CompressBucketItems(cfg *config.Config, dbClient *mongo.Client) func(context.Context, *asynq.Task) error {
+	bucketItemRepo := repository.NewBucketItemRepository(cfg, dbClient)
+	return func(ctx context.Context, t *asynq.Task) error {
+		var p CompressBucketItemsPayload
+		if err := json.Unmarshal(t.Payload(), &p); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("failed to unmarshal compress bucket items payload")
+			return err
+		}
+
+		count, err := bucketItemRepo.CompressBucketItems(ctx, p.BucketUID)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("failed to compress bucket items, compressed %d items", count)
+			return err
+		}
+
+		logrus.WithContext(ctx).Infof("compressed %d bucket items", count)
+		return nil
+	}
+}
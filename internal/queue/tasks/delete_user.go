@@ -0,0 +1,44 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"keeper/internal/models"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+)
+
+// Deletes everything a user owns, reporting the progress of the deletion
+type UserDataDeleter interface {
+	DeleteUserData(ctx context.Context, userID string, onProgress func(progress models.UserDeletionProgress)) error
+}
+
+// Returns the handler that deletes a user account along with the user's buckets, items and API keys
+// the progress of the deletion is written to the task result so that it can be inspected
+func DeleteUser(deleter UserDataDeleter) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) error {
+		var p DeleteUserPayload
+		if err := json.Unmarshal(t.Payload(), &p); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("failed to unmarshal delete user payload")
+			return err
+		}
+
+		err := deleter.DeleteUserData(ctx, p.UserID, func(progress models.UserDeletionProgress) {
+			result, err := json.Marshal(progress)
+			if err != nil {
+				return
+			}
+			if _, err := t.ResultWriter().Write(result); err != nil {
+				logrus.WithContext(ctx).WithError(err).Errorf("failed to write user deletion progress: %s", p.UserID)
+			}
+		})
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("failed to delete user: %s", p.UserID)
+			return err
+		}
+
+		logrus.WithContext(ctx).Infof("deleted user: %s", p.UserID)
+		return nil
+	}
+}
@@ -0,0 +1,29 @@
+package tasks
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+)
+
+// Marks the bucket items whose TTL has run out as expired
+type BucketItemExpirer interface {
+	ExpireBucketItems(ctx context.Context) (int64, error)
+}
+
+// Returns the handler that looks for newly expired bucket items so that their expiry is notified
+func ExpireBucketItems(expirer BucketItemExpirer) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) error {
+		count, err := expirer.ExpireBucketItems(ctx)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("failed to expire bucket items, expired %d items", count)
+			return err
+		}
+
+		if count > 0 {
+			logrus.WithContext(ctx).Infof("expired %d bucket items", count)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,60 @@
+package tasks
+
+import (
+	"context"
+	"keeper/internal/config"
+	"keeper/internal/repository"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Returns the handler that permanently deletes the buckets and bucket items
+// that have been in the trash for longer than the trash retention period
+func PurgeTrash(cfg *config.Config, dbClient *mongo.Client) func(context.Context, *asynq.Task) error {
+	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
+	bucketItemRepo := repository.NewBucketItemRepository(cfg, dbClient)
+	bucketSnapshotRepo := repository.NewBucketSnapshotRepository(cfg, dbClient)
+	webhookRepo := repository.NewWebhookRepository(cfg, dbClient)
+	return func(ctx context.Context, t *asynq.Task) error {
+		retention := time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+		cutoff := primitive.NewDateTimeFromTime(time.Now().Add(-retention))
+
+		buckets, err := bucketRepo.FindTrashedBucketsBefore(ctx, cutoff)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("failed to find trashed buckets to purge")
+			return err
+		}
+		for _, bucket := range buckets {
+			if err := bucketSnapshotRepo.DeleteBucketSnapshots(ctx, bucket.UID); err != nil {
+				logrus.WithContext(ctx).WithError(err).Errorf("failed to purge snapshots for bucket: %s", bucket.UID)
+				return err
+			}
+			if err := webhookRepo.DeleteBucketWebhooks(ctx, bucket.UID); err != nil {
+				logrus.WithContext(ctx).WithError(err).Errorf("failed to purge webhooks for bucket: %s", bucket.UID)
+				return err
+			}
+			// the items are deleted first so that no orphaned items are left behind on failure
+			if err := bucketItemRepo.DeleteBucketItems(ctx, bucket.UID); err != nil {
+				logrus.WithContext(ctx).WithError(err).Errorf("failed to purge bucket items for bucket: %s", bucket.UID)
+				return err
+			}
+			if err := bucketRepo.DeleteBucketByUID(ctx, bucket.UID); err != nil {
+				logrus.WithContext(ctx).WithError(err).Errorf("failed to purge bucket: %s", bucket.UID)
+				return err
+			}
+		}
+
+		count, err := bucketItemRepo.PurgeTrashedBucketItems(ctx, cutoff)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("failed to purge trashed bucket items")
+			return err
+		}
+
+		logrus.WithContext(ctx).Infof("purged %d buckets and %d bucket items from the trash", len(buckets), count)
+		return nil
+	}
+}
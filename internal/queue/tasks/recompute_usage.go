@@ -0,0 +1,26 @@
+package tasks
+
+import (
+	"context"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+)
+
+// Recomputes the usage counters from the stored data
+type UsageRecomputer interface {
+	RecomputeUsage(ctx context.Context) error
+}
+
+// Returns the handler that recomputes the usage of the users and buckets,
+// correcting the counters for the writes they miss, e.g. the trash purges and user deletions
+func RecomputeUsage(recomputer UsageRecomputer) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) error {
+		if err := recomputer.RecomputeUsage(ctx); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("failed to recompute usage")
+			return err
+		}
+		logrus.WithContext(ctx).Info("recomputed usage")
+		return nil
+	}
+}
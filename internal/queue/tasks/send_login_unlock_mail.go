@@ -0,0 +1,36 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"keeper/internal/config"
+	"keeper/internal/pkg/mailer"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+)
+
+func SendLoginUnlockMail(ctx context.Context, t *asynq.Task) error {
+	var p LoginUnlockMailPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to unmarshal login unlock payload")
+		return err
+	}
+
+	cfg := config.New()
+	mailSvc := mailer.NewMailer(cfg)
+
+	err := mailSvc.SendLoginUnlockMail(
+		p.ReceiverEmailAddr,
+		p.ReceiverName,
+		p.Subject,
+		p.TemplateData,
+	)
+
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to send login unlock mail")
+		return err
+	}
+
+	return nil
+}
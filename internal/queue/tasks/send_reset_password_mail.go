@@ -13,7 +13,7 @@ import (
 func SendResetPasswordMail(ctx context.Context, t *asynq.Task) error {
 	var p UserResetPasswordMailPayload
 	if err := json.Unmarshal(t.Payload(), &p); err != nil {
-		logrus.WithError(err).Error("failed to unmarshal reset password payload")
+		logrus.WithContext(ctx).WithError(err).Error("failed to unmarshal reset password payload")
 		return err
 	}
 
@@ -28,7 +28,7 @@ func SendResetPasswordMail(ctx context.Context, t *asynq.Task) error {
 	)
 
 	if err != nil {
-		logrus.WithError(err).Error("failed to send reset password mail")
+		logrus.WithContext(ctx).WithError(err).Error("failed to send reset password mail")
 		return err
 	}
 
@@ -13,7 +13,7 @@ import (
 func SendUserVerificationMail(ctx context.Context, t *asynq.Task) error {
 	var p UserVerificationMailPayload
 	if err := json.Unmarshal(t.Payload(), &p); err != nil {
-		logrus.WithError(err).Error("failed to unmarshal email verification payload")
+		logrus.WithContext(ctx).WithError(err).Error("failed to unmarshal email verification payload")
 		return err
 	}
 
@@ -28,7 +28,7 @@ func SendUserVerificationMail(ctx context.Context, t *asynq.Task) error {
 	)
 
 	if err != nil {
-		logrus.WithError(err).Error("failed to send user verification mail")
+		logrus.WithContext(ctx).WithError(err).Error("failed to send user verification mail")
 		return err
 	}
 
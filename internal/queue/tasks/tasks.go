@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"encoding/json"
+	"keeper/internal/models"
 
 	"github.com/hibiken/asynq"
 )
@@ -9,6 +10,14 @@ import (
 const (
 	TypeUserVerificationMail  = "email:user_verification"
 	TypeUserResetPasswordMail = "email:reset_password"
+	TypeLoginUnlockMail       = "email:login_unlock"
+	TypeCompressBucketItems   = "bucket_item:compress"
+	TypePurgeTrash            = "trash:purge"
+	TypeDeleteUser            = "user:delete"
+	TypeDispatchWebhookEvent  = "webhook:dispatch"
+	TypeDeliverWebhook        = "webhook:deliver"
+	TypeExpireBucketItems     = "bucket_item:expire"
+	TypeRecomputeUsage        = "usage:recompute"
 )
 
 type UserVerificationMailPayload struct {
@@ -25,6 +34,25 @@ type UserResetPasswordMailPayload struct {
 	TemplateData      interface{}
 }
 
+type LoginUnlockMailPayload struct {
+	ReceiverEmailAddr string
+	ReceiverName      string
+	Subject           string
+	TemplateData      interface{}
+}
+
+type CompressBucketItemsPayload struct {
+	BucketUID string // all the buckets are migrated if empty
+}
+
+type DeleteUserPayload struct {
+	UserID string
+}
+
+type DeliverWebhookPayload struct {
+	DeliveryID string
+}
+
 // create the tasks
 func NewUserVerificationMailTask(receiverEmailAddr string, receiverName string, subject string, templateData interface{}) (*asynq.Task, error) {
 	payload, err := json.Marshal(UserVerificationMailPayload{
@@ -51,3 +79,66 @@ func NewUserResetPasswordMailTask(receiverEmailAddr string, receiverName string,
 	}
 	return asynq.NewTask(TypeUserResetPasswordMail, payload), nil
 }
+
+func NewLoginUnlockMailTask(receiverEmailAddr string, receiverName string, subject string, templateData interface{}) (*asynq.Task, error) {
+	payload, err := json.Marshal(LoginUnlockMailPayload{
+		ReceiverEmailAddr: receiverEmailAddr,
+		ReceiverName:      receiverName,
+		Subject:           subject,
+		TemplateData:      templateData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeLoginUnlockMail, payload), nil
+}
+
+func NewCompressBucketItemsTask(bucketUID string) (*asynq.Task, error) {
+	payload, err := json.Marshal(CompressBucketItemsPayload{
+		BucketUID: bucketUID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeCompressBucketItems, payload), nil
+}
+
+func NewPurgeTrashTask() *asynq.Task {
+	return asynq.NewTask(TypePurgeTrash, nil)
+}
+
+func NewDeleteUserTask(userID string) (*asynq.Task, error) {
+	payload, err := json.Marshal(DeleteUserPayload{
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeDeleteUser, payload), nil
+}
+
+func NewDispatchWebhookEventTask(event models.WebhookEvent) (*asynq.Task, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeDispatchWebhookEvent, payload), nil
+}
+
+func NewDeliverWebhookTask(deliveryID string) (*asynq.Task, error) {
+	payload, err := json.Marshal(DeliverWebhookPayload{
+		DeliveryID: deliveryID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(TypeDeliverWebhook, payload), nil
+}
+
+func NewExpireBucketItemsTask() *asynq.Task {
+	return asynq.NewTask(TypeExpireBucketItems, nil)
+}
+
+func NewRecomputeUsageTask() *asynq.Task {
+	return asynq.NewTask(TypeRecomputeUsage, nil)
+}
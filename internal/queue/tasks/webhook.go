@@ -0,0 +1,59 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"keeper/internal/models"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+)
+
+// Fans an event out to the webhooks subscribed to it
+type WebhookEventDispatcher interface {
+	DispatchEvent(ctx context.Context, event models.WebhookEvent) error
+}
+
+// Sends a single webhook delivery
+type WebhookDeliverer interface {
+	DeliverWebhook(ctx context.Context, deliveryID string, lastAttempt bool) error
+}
+
+// Returns the handler that creates a delivery for every webhook subscribed to an event
+func DispatchWebhookEvent(dispatcher WebhookEventDispatcher) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) error {
+		var event models.WebhookEvent
+		if err := json.Unmarshal(t.Payload(), &event); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("failed to unmarshal webhook event payload")
+			return err
+		}
+
+		if err := dispatcher.DispatchEvent(ctx, event); err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("failed to dispatch webhook event: %s", event.ID)
+			return err
+		}
+		return nil
+	}
+}
+
+// Returns the handler that sends a webhook delivery
+// a failed delivery is returned as an error so that asynq retries it with backoff
+func DeliverWebhook(deliverer WebhookDeliverer) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) error {
+		var p DeliverWebhookPayload
+		if err := json.Unmarshal(t.Payload(), &p); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("failed to unmarshal deliver webhook payload")
+			return err
+		}
+
+		retryCount, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+		if err := deliverer.DeliverWebhook(ctx, p.DeliveryID, retryCount >= maxRetry); err != nil {
+			logrus.WithContext(ctx).WithError(err).Warnf("failed to deliver webhook: %s, attempt %d", p.DeliveryID, retryCount+1)
+			return err
+		}
+
+		logrus.WithContext(ctx).Infof("delivered webhook: %s", p.DeliveryID)
+		return nil
+	}
+}
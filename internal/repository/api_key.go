@@ -20,7 +20,6 @@ const (
 )
 
 type APIKeyRepository struct {
-	ctx        context.Context
 	collection *mongo.Collection
 }
 
@@ -45,16 +44,15 @@ func NewAPIKeyRepository(cfg *config.Config, dbClient *mongo.Client) IAPIKeyRepo
 	apiKeyCollection := dbClient.Database(cfg.DbName).Collection(apiKeyCollectionName)
 	return &APIKeyRepository{
 		collection: apiKeyCollection,
-		ctx:        context.TODO(),
 	}
 }
 
 // Saves a new API Key in the database
 // Returns the ID of the saved API Key and an error
-func (r *APIKeyRepository) CreateAPIKey(apiKey *models.APIKey) (primitive.ObjectID, error) {
-	result, err := r.collection.InsertOne(r.ctx, apiKey)
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, apiKey *models.APIKey) (primitive.ObjectID, error) {
+	result, err := r.collection.InsertOne(ctx, apiKey)
 	if err != nil {
-		logrus.WithError(err).Error("error creating api key")
+		logrus.WithContext(ctx).WithError(err).Error("error creating api key")
 		return primitive.ObjectID{}, fmt.Errorf("error creating api key: %s", err.Error())
 	}
 	return result.InsertedID.(primitive.ObjectID), nil
@@ -63,11 +61,11 @@ func (r *APIKeyRepository) CreateAPIKey(apiKey *models.APIKey) (primitive.Object
 // Utility function for finding an API Key by a specific field name
 // Accepts the value and key name for the field
 // Returns the found API Key or an error
-func (r *APIKeyRepository) FindAPIKeyByKeyName(key string, value string) (*models.APIKey, error) {
+func (r *APIKeyRepository) FindAPIKeyByKeyName(ctx context.Context, key string, value string) (*models.APIKey, error) {
 	apiKey := &models.APIKey{}
 	filter := bson.D{primitive.E{Key: key, Value: value}}
 	opts := options.FindOne().SetProjection(apiKeyDetailsProjection)
-	if err := r.collection.FindOne(r.ctx, filter, opts).Decode(apiKey); err != nil {
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(apiKey); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, models.ErrAPIKeyNotFound
 		}
@@ -79,7 +77,7 @@ func (r *APIKeyRepository) FindAPIKeyByKeyName(key string, value string) (*model
 
 // Find an API Key by the ID
 // Accepts the API Key ID, Returns the found API Key and an error
-func (r *APIKeyRepository) FindAPIKeyByID(id string) (*models.APIKey, error) {
+func (r *APIKeyRepository) FindAPIKeyByID(ctx context.Context, id string) (*models.APIKey, error) {
 	apiKey := &models.APIKey{}
 	ID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -87,7 +85,7 @@ func (r *APIKeyRepository) FindAPIKeyByID(id string) (*models.APIKey, error) {
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
 	opts := options.FindOne().SetProjection(apiKeyDetailsProjection)
-	if err := r.collection.FindOne(r.ctx, filter, opts).Decode(apiKey); err != nil {
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(apiKey); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return &models.APIKey{}, models.ErrAPIKeyNotFound
 		}
@@ -99,19 +97,19 @@ func (r *APIKeyRepository) FindAPIKeyByID(id string) (*models.APIKey, error) {
 
 // Find an API Key by mask ID
 // Accepts the mask ID, Returns the found API Key and an error
-func (r *APIKeyRepository) FindAPIKeyByMaskID(maskID string) (*models.APIKey, error) {
-	return r.FindAPIKeyByKeyName("mask_id", maskID)
+func (r *APIKeyRepository) FindAPIKeyByMaskID(ctx context.Context, maskID string) (*models.APIKey, error) {
+	return r.FindAPIKeyByKeyName(ctx, "mask_id", maskID)
 }
 
 // Find an API Key by hash
 // Accepts the API Key hash, Returns the found API Key and an error
-func (r *APIKeyRepository) FindAPIKeyByHash(hash string) (*models.APIKey, error) {
-	return r.FindAPIKeyByKeyName("hash", hash)
+func (r *APIKeyRepository) FindAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return r.FindAPIKeyByKeyName(ctx, "hash", hash)
 }
 
 // Find a user's API Keys
 // Accepts the user ID, Returns a list of the user's API Keys and an error
-func (r *APIKeyRepository) FindUserAPIKeys(userID string) ([]models.APIKey, error) {
+func (r *APIKeyRepository) FindUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
 	apiKeys := []models.APIKey{}
 	ID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
@@ -119,21 +117,38 @@ func (r *APIKeyRepository) FindUserAPIKeys(userID string) ([]models.APIKey, erro
 	}
 	filter := bson.D{primitive.E{Key: "user_id", Value: ID}}
 	opts := options.Find().SetProjection(apiKeyDetailsProjection).SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}})
-	cursor, err := r.collection.Find(r.ctx, filter, opts)
+	cursor, err := r.collection.Find(ctx, filter, opts)
 
 	if err != nil {
-		logrus.WithError(err).Errorf("cannot find many apikeys")
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find many apikeys")
 		return nil, models.ErrAPIKeysNotFound
 	}
-	if err = cursor.All(r.ctx, &apiKeys); err != nil {
-		logrus.WithError(err).Errorf("cannot find many apikeys")
+	if err = cursor.All(ctx, &apiKeys); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find many apikeys")
 		return nil, models.ErrAPIKeysNotFound
 	}
 	// logrus.Debug("user api keys: ", apiKeys)
 	return apiKeys, nil
 }
 
-func (r *APIKeyRepository) UpdateAPIKey(apiKey *models.APIKey) error {
+// Find the API Keys of every user
+// Returns the list of API Keys, the most recent first, and an error
+func (r *APIKeyRepository) FindAllAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	apiKeys := []models.APIKey{}
+	opts := options.Find().SetProjection(apiKeyDetailsProjection).SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find many apikeys")
+		return nil, models.ErrAPIKeysNotFound
+	}
+	if err = cursor.All(ctx, &apiKeys); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find many apikeys")
+		return nil, models.ErrAPIKeysNotFound
+	}
+	return apiKeys, nil
+}
+
+func (r *APIKeyRepository) UpdateAPIKey(ctx context.Context, apiKey *models.APIKey) error {
 	filter := bson.D{primitive.E{Key: "_id", Value: apiKey.ID}}
 	apiKeyByte, err := bson.Marshal(apiKey)
 	if err != nil {
@@ -146,9 +161,9 @@ func (r *APIKeyRepository) UpdateAPIKey(apiKey *models.APIKey) error {
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err = r.collection.UpdateOne(r.ctx, filter, bson.D{primitive.E{Key: "$set", Value: update}}, opts)
+	_, err = r.collection.UpdateOne(ctx, filter, bson.D{primitive.E{Key: "$set", Value: update}}, opts)
 	if err != nil {
-		logrus.WithError(err).Error("error updating api key")
+		logrus.WithContext(ctx).WithError(err).Error("error updating api key")
 		return models.ErrUpdatingAPIKey
 	}
 	return nil
@@ -157,7 +172,7 @@ func (r *APIKeyRepository) UpdateAPIKey(apiKey *models.APIKey) error {
 // Revoke an API Key
 // Accepts the API Key ID and sets the 'revoked' field to true
 // Returns an error on failure
-func (r *APIKeyRepository) RevokeAPIKey(apiKeyID string) error {
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, apiKeyID string) error {
 	ID, err := primitive.ObjectIDFromHex(apiKeyID)
 	if err != nil {
 		return models.ErrInvalidObjectID
@@ -166,9 +181,9 @@ func (r *APIKeyRepository) RevokeAPIKey(apiKeyID string) error {
 	// create update query
 	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "revoked", Value: true}}}}
 	opts := options.Update().SetUpsert(true)
-	_, err = r.collection.UpdateOne(r.ctx, filter, update, opts)
+	_, err = r.collection.UpdateOne(ctx, filter, update, opts)
 	if err != nil {
-		logrus.WithError(err).Error("error revoking api key")
+		logrus.WithContext(ctx).WithError(err).Error("error revoking api key")
 		return models.ErrRevokingAPIKey
 	}
 	return nil
@@ -177,18 +192,18 @@ func (r *APIKeyRepository) RevokeAPIKey(apiKeyID string) error {
 // Revoke multiple API Keys
 // Accepts a list of API Key IDs and sets the 'revoked' status to true
 // Returns an error on failure
-func (r *APIKeyRepository) RevokeAPIKeys(apiKeyIDs []string) error {
+func (r *APIKeyRepository) RevokeAPIKeys(ctx context.Context, apiKeyIDs []string) error {
 	objectIDs, err := utils.MapIDsToObjectIDs(apiKeyIDs)
 	if err != nil {
-		return errors.New("invalid api key object id")
+		return models.ErrInvalidObjectID
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$in", Value: objectIDs}}}}
 	// create update query
 	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "revoked", Value: true}}}}
 	opts := options.Update().SetUpsert(true)
-	_, err = r.collection.UpdateMany(r.ctx, filter, update, opts)
+	_, err = r.collection.UpdateMany(ctx, filter, update, opts)
 	if err != nil {
-		logrus.WithError(err).Error("error revoking api keys")
+		logrus.WithContext(ctx).WithError(err).Error("error revoking api keys")
 		return models.ErrRevokingAPIKeys
 	}
 	return nil
@@ -196,15 +211,15 @@ func (r *APIKeyRepository) RevokeAPIKeys(apiKeyIDs []string) error {
 
 // Delete a single API Key from the database
 // Accepts an API Key ID, Returns an error on failure
-func (r *APIKeyRepository) DeleteAPIKey(apiKeyID string) error {
+func (r *APIKeyRepository) DeleteAPIKey(ctx context.Context, apiKeyID string) error {
 	ID, err := primitive.ObjectIDFromHex(apiKeyID)
 	if err != nil {
 		return err
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
-	_, err = r.collection.DeleteOne(r.ctx, filter)
+	_, err = r.collection.DeleteOne(ctx, filter)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting api key")
+		logrus.WithContext(ctx).WithError(err).Error("error deleting api key")
 		return models.ErrDeletingAPIKey
 	}
 	return nil
@@ -212,19 +227,35 @@ func (r *APIKeyRepository) DeleteAPIKey(apiKeyID string) error {
 
 // Delete multiple API Keys from the database
 // Accepts a list of API Key IDs to be deleted, Returns an error on failure
-func (r *APIKeyRepository) DeleteAPIKeys(apiKeyIDs []string) error {
+func (r *APIKeyRepository) DeleteAPIKeys(ctx context.Context, apiKeyIDs []string) error {
 	objectIDs, err := utils.MapIDsToObjectIDs(apiKeyIDs)
 	if err != nil {
-		return errors.New("invalid api key object id")
+		return models.ErrInvalidObjectID
 	}
 	if err != nil {
 		return err
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$in", Value: objectIDs}}}}
-	_, err = r.collection.DeleteMany(r.ctx, filter, nil)
+	_, err = r.collection.DeleteMany(ctx, filter, nil)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting api keys")
+		logrus.WithContext(ctx).WithError(err).Error("error deleting api keys")
 		return models.ErrDeletingAPIKeys
 	}
 	return nil
 }
+
+// Delete all the API Keys of a user from the database
+// Accepts the user ID, Returns the number of deleted API Keys and an error
+func (r *APIKeyRepository) DeleteUserAPIKeys(ctx context.Context, userID string) (int64, error) {
+	ID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "user_id", Value: ID}}
+	result, err := r.collection.DeleteMany(ctx, filter, nil)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting user api keys")
+		return 0, models.ErrDeletingAPIKeys
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"keeper/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	auditEventCollectionName = "auditevents"
+)
+
+type AuditEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditEventRepository(cfg *config.Config, dbClient *mongo.Client) IAuditEventRepository {
+	auditEventCollection := dbClient.Database(cfg.DbName).Collection(auditEventCollectionName)
+	return &AuditEventRepository{
+		collection: auditEventCollection,
+	}
+}
+
+// Appends an event to the audit log
+func (r *AuditEventRepository) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) (primitive.ObjectID, error) {
+	result, err := r.collection.InsertOne(ctx, event)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error recording %s audit event", event.Action)
+		return primitive.ObjectID{}, models.ErrRecordingAuditEvent
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// Finds a page of the audit events matching a filter, most recent first
+func (r *AuditEventRepository) FindAuditEventsPaged(ctx context.Context, filter bson.M, paginationParams utils.PaginationParams) ([]models.AuditEvent, utils.PageInfo, error) {
+	events := []models.AuditEvent{}
+	opts := options.Find().
+		SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}, primitive.E{Key: "_id", Value: -1}}).
+		SetSkip(paginationParams.Skip).
+		SetLimit(paginationParams.Limit)
+	results, pageInfo, err := utils.FindManyWithPagination(r.collection, nil, events, ctx, filter, opts, paginationParams)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find audit events")
+		return nil, utils.PageInfo{}, models.ErrAuditEventsNotFound
+	}
+	return results.([]models.AuditEvent), pageInfo, nil
+}
+
+// Calls fn with each of the audit events matching a filter, oldest first
+// the events are read from a cursor so that a whole audit log can be exported, fn stops the iteration by returning an error
+func (r *AuditEventRepository) IterateAuditEvents(ctx context.Context, filter bson.M, fn func(event *models.AuditEvent) error) error {
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "created_at", Value: 1}, primitive.E{Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find audit events")
+		return models.ErrAuditEventsNotFound
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		event := &models.AuditEvent{}
+		if err := cursor.Decode(event); err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
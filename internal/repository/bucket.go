@@ -7,6 +7,7 @@ import (
 	"keeper/internal/config"
 	"keeper/internal/models"
 	"keeper/internal/utils"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
@@ -26,35 +27,38 @@ var bucketDetailsProjection = bson.D{
 	primitive.E{Key: "name", Value: 1},
 	primitive.E{Key: "description", Value: 1},
 	primitive.E{Key: "permissions", Value: 1},
+	primitive.E{Key: "require_two_factor", Value: 1},
 	primitive.E{Key: "created_at", Value: 1},
 	primitive.E{Key: "updated_at", Value: 1},
+	primitive.E{Key: "deleted_at", Value: 1},
 }
 
 type BucketRepository struct {
 	collection *mongo.Collection
-	ctx        context.Context
 }
 
 func NewBucketRepository(cfg *config.Config, dbClient *mongo.Client) IBucketRepository {
 	bucketCollection := dbClient.Database(cfg.DbName).Collection(bucketCollectionName)
 	return &BucketRepository{
 		collection: bucketCollection,
-		ctx:        context.TODO(),
 	}
 }
 
 // Save a new bucket data
-func (r *BucketRepository) CreateBucket(bucket *models.Bucket) (primitive.ObjectID, error) {
-	result, err := r.collection.InsertOne(r.ctx, bucket)
+func (r *BucketRepository) CreateBucket(ctx context.Context, bucket *models.Bucket) (primitive.ObjectID, error) {
+	result, err := r.collection.InsertOne(ctx, bucket)
+	if mongo.IsDuplicateKeyError(err) {
+		return primitive.ObjectID{}, models.NewConflictError(models.ErrBucketAlreadyExists, "uid", bucket.UID)
+	}
 	if err != nil {
-		logrus.WithError(err).Error("error creating bucket")
+		logrus.WithContext(ctx).WithError(err).Error("error creating bucket")
 		return primitive.ObjectID{}, fmt.Errorf("error creating bucket: %s", err.Error())
 	}
 	return result.InsertedID.(primitive.ObjectID), nil
 }
 
 // Update bucket data
-func (r *BucketRepository) UpdateBucket(bucket *models.Bucket) error {
+func (r *BucketRepository) UpdateBucket(ctx context.Context, bucket *models.Bucket) error {
 	filter := bson.D{primitive.E{Key: "uid", Value: bucket.UID}} // find bucket by uid
 	bucketByte, err := bson.Marshal(bucket)
 	if err != nil {
@@ -67,50 +71,68 @@ func (r *BucketRepository) UpdateBucket(bucket *models.Bucket) error {
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err = r.collection.UpdateOne(r.ctx, filter, bson.D{primitive.E{Key: "$set", Value: update}}, opts)
+	_, err = r.collection.UpdateOne(ctx, filter, bson.D{primitive.E{Key: "$set", Value: update}}, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error updating bucket")
+		return models.ErrUpdatingBucket
+	}
+	return nil
+}
+
+// Sets whether the bucket requires its owner and writers to have two-factor authentication enabled
+func (r *BucketRepository) SetBucketRequireTwoFactor(ctx context.Context, uid string, required bool) error {
+	filter := bson.D{primitive.E{Key: "uid", Value: uid}, notTrashedFilter}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "require_two_factor", Value: required},
+		primitive.E{Key: "updated_at", Value: primitive.NewDateTimeFromTime(time.Now())},
+	}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		logrus.WithError(err).Error("error updating bucket")
+		logrus.WithContext(ctx).WithError(err).Error("error updating bucket two-factor requirement")
 		return models.ErrUpdatingBucket
 	}
+	if result.MatchedCount == 0 {
+		return models.ErrBucketNotFound
+	}
 	return nil
 }
 
 // Returns a single bucket by id
-func (r *BucketRepository) FindBucketByID(id string) (*models.Bucket, error) {
+func (r *BucketRepository) FindBucketByID(ctx context.Context, id string) (*models.Bucket, error) {
 	bucket := &models.Bucket{}
 	ID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, models.ErrInvalidObjectID
 	}
-	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
+	filter := bson.D{primitive.E{Key: "_id", Value: ID}, notTrashedFilter}
 	opts := options.FindOne().SetProjection(bucketDetailsProjection)
-	if err := r.collection.FindOne(r.ctx, filter, opts).Decode(bucket); err != nil {
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(bucket); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, models.ErrBucketNotFound
 		}
 		return nil, err
 	}
-	logrus.Info("found bucket: ", bucket)
+	logrus.WithContext(ctx).WithField("bucket_uid", bucket.UID).Debug("found bucket")
 	return bucket, nil
 }
 
 // Find a single bucket by uid
-func (r *BucketRepository) FindBucketByUID(uid string) (*models.Bucket, error) {
+func (r *BucketRepository) FindBucketByUID(ctx context.Context, uid string) (*models.Bucket, error) {
 	bucket := &models.Bucket{}
-	filter := bson.D{primitive.E{Key: "uid", Value: uid}}
+	filter := bson.D{primitive.E{Key: "uid", Value: uid}, notTrashedFilter}
 	opts := options.FindOne().SetProjection(bucketDetailsProjection)
-	if err := r.collection.FindOne(r.ctx, filter, opts).Decode(bucket); err != nil {
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(bucket); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, models.ErrBucketNotFound
 		}
 		return nil, err
 	}
-	logrus.Info("found bucket: ", bucket)
+	logrus.WithContext(ctx).WithField("bucket_uid", bucket.UID).Debug("found bucket")
 	return bucket, nil
 }
 
 // Finds an array of a user's buckets (using pagination and filtering)
-func (r *BucketRepository) FindBucketsByUserIDPaged(userID string, filter bson.M, findOpts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.Bucket, utils.PageInfo, error) {
+func (r *BucketRepository) FindBucketsByUserIDPaged(ctx context.Context, userID string, filter bson.M, findOpts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.Bucket, utils.PageInfo, error) {
 	// Finds bucket items (using pagination and filtering)
 	bucketItems := []models.Bucket{}
 
@@ -120,9 +142,9 @@ func (r *BucketRepository) FindBucketsByUserIDPaged(userID string, filter bson.M
 		return nil, utils.PageInfo{}, models.ErrInvalidObjectID
 	}
 	filter["user_id"] = ID
+	filter[notTrashedFilter.Key] = notTrashedFilter.Value
 
-	results, pageInfo, err := utils.FindManyWithPagination(r.collection, bucketDetailsProjection, bucketItems, r.ctx, filter, findOpts, paginationParams)
-	fmt.Println(results)
+	results, pageInfo, err := utils.FindManyWithPagination(r.collection, bucketDetailsProjection, bucketItems, ctx, filter, findOpts, paginationParams)
 	if err != nil {
 		return nil, utils.PageInfo{}, err
 	}
@@ -130,36 +152,36 @@ func (r *BucketRepository) FindBucketsByUserIDPaged(userID string, filter bson.M
 }
 
 // Returns an array of a user's buckets
-func (r *BucketRepository) FindBucketsByUserID(userID string) ([]models.Bucket, error) {
+func (r *BucketRepository) FindBucketsByUserID(ctx context.Context, userID string) ([]models.Bucket, error) {
 	buckets := []models.Bucket{}
 	ID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		return nil, models.ErrInvalidObjectID
 	}
-	filter := bson.D{primitive.E{Key: "user_id", Value: ID}}
+	filter := bson.D{primitive.E{Key: "user_id", Value: ID}, notTrashedFilter}
 	opts := options.Find().SetProjection(bucketDetailsProjection).SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}})
-	cursor, err := r.collection.Find(r.ctx, filter, opts)
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
-		logrus.WithError(err).Errorf("cannot find many buckets")
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find many buckets")
 		return nil, models.ErrBucketsNotFound
 	}
-	if err = cursor.All(r.ctx, &buckets); err != nil {
+	if err = cursor.All(ctx, &buckets); err != nil {
 		return nil, models.ErrBucketsNotFound
 	}
-	logrus.Debug("found buckets: ", buckets)
+	logrus.WithContext(ctx).WithField("count", len(buckets)).Debug("found buckets")
 	return buckets, nil
 }
 
 // Delete a bucket by ID
-func (r *BucketRepository) DeleteBucketByID(id string) error {
+func (r *BucketRepository) DeleteBucketByID(ctx context.Context, id string) error {
 	ID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return err
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
-	result, err := r.collection.DeleteOne(r.ctx, filter)
+	result, err := r.collection.DeleteOne(ctx, filter)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting bucket by id")
+		logrus.WithContext(ctx).WithError(err).Error("error deleting bucket by id")
 		return models.ErrDeletingBucket
 	}
 	if result.DeletedCount == 0 {
@@ -169,11 +191,11 @@ func (r *BucketRepository) DeleteBucketByID(id string) error {
 }
 
 // Delete a bucket by bucket UID
-func (r *BucketRepository) DeleteBucketByUID(uid string) error {
+func (r *BucketRepository) DeleteBucketByUID(ctx context.Context, uid string) error {
 	filter := bson.D{primitive.E{Key: "uid", Value: uid}}
-	result, err := r.collection.DeleteOne(r.ctx, filter)
+	result, err := r.collection.DeleteOne(ctx, filter)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting bucket by uid")
+		logrus.WithContext(ctx).WithError(err).Error("error deleting bucket by uid")
 		return models.ErrDeletingBucket
 	}
 	if result.DeletedCount == 0 {
@@ -181,3 +203,103 @@ func (r *BucketRepository) DeleteBucketByUID(uid string) error {
 	}
 	return nil
 }
+
+// Moves a bucket to the trash
+// Accepts the bucket UID and the time of deletion
+// Returns an error
+func (r *BucketRepository) TrashBucketByUID(ctx context.Context, uid string, deletedAt primitive.DateTime) error {
+	filter := bson.D{primitive.E{Key: "uid", Value: uid}, notTrashedFilter}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "deleted_at", Value: deletedAt}}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error moving bucket to the trash")
+		return models.ErrTrashingBucket
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrBucketNotFound
+	}
+	return nil
+}
+
+// Restores a bucket from the trash
+// Accepts the bucket UID
+// Returns an error
+func (r *BucketRepository) RestoreBucketByUID(ctx context.Context, uid string) error {
+	filter := bson.D{primitive.E{Key: "uid", Value: uid}, trashedFilter}
+	update := bson.D{primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "deleted_at", Value: ""}}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error restoring bucket")
+		return models.ErrRestoringBucket
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrBucketNotFound
+	}
+	return nil
+}
+
+// Find a single trashed bucket by uid
+func (r *BucketRepository) FindTrashedBucketByUID(ctx context.Context, uid string) (*models.Bucket, error) {
+	bucket := &models.Bucket{}
+	filter := bson.D{primitive.E{Key: "uid", Value: uid}, trashedFilter}
+	opts := options.FindOne().SetProjection(bucketDetailsProjection)
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(bucket); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, models.ErrBucketNotFound
+		}
+		return nil, err
+	}
+	return bucket, nil
+}
+
+// Returns an array of a user's trashed buckets
+func (r *BucketRepository) FindTrashedBucketsByUserID(ctx context.Context, userID string) ([]models.Bucket, error) {
+	ID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "user_id", Value: ID}, trashedFilter}
+	return r.findTrashedBuckets(ctx, filter)
+}
+
+// Returns an array of the buckets that were moved to the trash before a cutoff time
+func (r *BucketRepository) FindTrashedBucketsBefore(ctx context.Context, before primitive.DateTime) ([]models.Bucket, error) {
+	filter := bson.D{trashedBeforeFilter(before)}
+	return r.findTrashedBuckets(ctx, filter)
+}
+
+// Returns the trashed buckets matching a filter, most recently deleted first
+func (r *BucketRepository) findTrashedBuckets(ctx context.Context, filter bson.D) ([]models.Bucket, error) {
+	buckets := []models.Bucket{}
+	opts := options.Find().SetProjection(bucketDetailsProjection).SetSort(bson.D{primitive.E{Key: "deleted_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find trashed buckets")
+		return nil, models.ErrBucketsNotFound
+	}
+	if err = cursor.All(ctx, &buckets); err != nil {
+		return nil, models.ErrBucketsNotFound
+	}
+	return buckets, nil
+}
+
+// Returns the UIDs of all the buckets owned by a user, including the trashed buckets
+func (r *BucketRepository) FindAllBucketUIDsByUserID(ctx context.Context, userID string) ([]string, error) {
+	ID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "user_id", Value: ID}}
+	uids, err := r.collection.Distinct(ctx, "uid", filter)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find user bucket uids")
+		return nil, models.ErrBucketsNotFound
+	}
+	bucketUIDs := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		if uid, ok := uid.(string); ok {
+			bucketUIDs = append(bucketUIDs, uid)
+		}
+	}
+	return bucketUIDs, nil
+}
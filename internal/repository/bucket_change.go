@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	bucketChangeCollectionName        = "bucketchanges"
+	bucketChangeCounterCollectionName = "bucketchangecounters"
+)
+
+// the high-water mark of the sequence numbers recorded for the changes of a bucket,
+// it is raised once the changes are inserted
+type bucketChangeCounter struct {
+	BucketUID string `bson:"_id"`
+	Seq       int64  `bson:"seq"`
+}
+
+// number of times the recording of changes is retried when other changes took their sequence numbers
+const maxRecordChangesAttempts = 100
+
+type BucketChangeRepository struct {
+	collection        *mongo.Collection
+	counterCollection *mongo.Collection
+	retention         time.Duration
+}
+
+func NewBucketChangeRepository(cfg *config.Config, dbClient *mongo.Client) IBucketChangeRepository {
+	db := dbClient.Database(cfg.DbName)
+	return &BucketChangeRepository{
+		collection:        db.Collection(bucketChangeCollectionName),
+		counterCollection: db.Collection(bucketChangeCounterCollectionName),
+		retention:         time.Duration(cfg.BucketChangeRetentionDays) * 24 * time.Hour,
+	}
+}
+
+// Appends changes to the change feed of a bucket, one per key
+// a single change without a key is recorded if no key is given
+// the changes take the sequence numbers following the last recorded one, the unique index on the
+// sequence numbers refuses the changes whose number was taken concurrently and they are retried,
+// so a change is only visible once all the changes before it are
+// Accepts the bucket UID, the operation and the changed keys
+// Returns an error
+func (r *BucketChangeRepository) RecordChanges(ctx context.Context, bucketUID string, op string, keys ...string) error {
+	if len(keys) == 0 {
+		keys = []string{""}
+	}
+	now := time.Now()
+	createdAt := primitive.NewDateTimeFromTime(now)
+	// the changes are kept for good without a retention
+	var expiresAt primitive.DateTime
+	if r.retention > 0 {
+		expiresAt = primitive.NewDateTimeFromTime(now.Add(r.retention))
+	}
+	for attempt := 0; len(keys) > 0; attempt++ {
+		if attempt == maxRecordChangesAttempts {
+			logrus.WithContext(ctx).Errorf("gave up recording bucket changes for bucket: %s", bucketUID)
+			return models.ErrRecordingBucketChange
+		}
+		latest, err := r.LatestChangeSeq(ctx, bucketUID)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("error finding the latest bucket change for bucket: %s", bucketUID)
+			return models.ErrRecordingBucketChange
+		}
+		changes := make([]interface{}, 0, len(keys))
+		for i, key := range keys {
+			changes = append(changes, models.BucketChange{
+				BucketUID: bucketUID,
+				Seq:       latest + int64(i) + 1,
+				Op:        op,
+				Key:       key,
+				CreatedAt: createdAt,
+				ExpiresAt: expiresAt,
+			})
+		}
+		// the changes are inserted in order, the ones before a refused change are recorded
+		_, err = r.collection.InsertMany(ctx, changes, options.InsertMany().SetOrdered(true))
+		inserted := len(changes)
+		var writeErr mongo.BulkWriteException
+		if errors.As(err, &writeErr) && len(writeErr.WriteErrors) > 0 {
+			inserted = writeErr.WriteErrors[0].Index
+		} else if err != nil {
+			inserted = 0
+		}
+		if inserted > 0 {
+			if err := r.raiseCounter(ctx, bucketUID, latest+int64(inserted)); err != nil {
+				logrus.WithContext(ctx).WithError(err).Errorf("error raising the bucket change counter for bucket: %s", bucketUID)
+				return models.ErrRecordingBucketChange
+			}
+		}
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			logrus.WithContext(ctx).WithError(err).Errorf("error recording bucket changes for bucket: %s", bucketUID)
+			return models.ErrRecordingBucketChange
+		}
+		keys = keys[inserted:]
+	}
+	return nil
+}
+
+// Raises the counter of a bucket to a recorded sequence number, a lower number leaves it unchanged
+func (r *BucketChangeRepository) raiseCounter(ctx context.Context, bucketUID string, seq int64) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: bucketUID}}
+	update := bson.D{primitive.E{Key: "$max", Value: bson.D{primitive.E{Key: "seq", Value: seq}}}}
+	_, err := r.counterCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// Returns the changes of a bucket after a sequence number, in order
+// Accepts the bucket UID, the sequence number to resume after and the maximum number of changes
+func (r *BucketChangeRepository) FindChanges(ctx context.Context, bucketUID string, since int64, limit int64) ([]models.BucketChange, error) {
+	changes := []models.BucketChange{}
+	filter := bson.D{
+		primitive.E{Key: "bucket_uid", Value: bucketUID},
+		primitive.E{Key: "seq", Value: bson.D{primitive.E{Key: "$gt", Value: since}}},
+	}
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "seq", Value: 1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find bucket changes")
+		return nil, models.ErrBucketChangesNotFound
+	}
+	if err = cursor.All(ctx, &changes); err != nil {
+		return nil, models.ErrBucketChangesNotFound
+	}
+	return changes, nil
+}
+
+// Returns the last sequence number recorded for the changes of a bucket, 0 if none
+// the counter may lag behind a change that was just recorded, the greatest of both is returned
+func (r *BucketChangeRepository) LatestChangeSeq(ctx context.Context, bucketUID string) (int64, error) {
+	counter := &bucketChangeCounter{}
+	filter := bson.D{primitive.E{Key: "_id", Value: bucketUID}}
+	if err := r.counterCollection.FindOne(ctx, filter).Decode(counter); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, err
+	}
+	latest := &models.BucketChange{}
+	filter = bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}}
+	opts := options.FindOne().SetSort(bson.D{primitive.E{Key: "seq", Value: -1}}).SetProjection(bson.D{primitive.E{Key: "seq", Value: 1}})
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(latest); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return counter.Seq, nil
+		}
+		return 0, err
+	}
+	if latest.Seq > counter.Seq {
+		return latest.Seq, nil
+	}
+	return counter.Seq, nil
+}
+
+// Deletes the change feed of a bucket
+func (r *BucketChangeRepository) DeleteBucketChanges(ctx context.Context, bucketUID string) error {
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}}
+	if _, err := r.collection.DeleteMany(ctx, filter); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting bucket changes for bucket: %s", bucketUID)
+		return err
+	}
+	if _, err := r.counterCollection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: bucketUID}}); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting bucket change counter for bucket: %s", bucketUID)
+		return err
+	}
+	return nil
+}
@@ -6,8 +6,8 @@ import (
 	"fmt"
 	"keeper/internal/config"
 	"keeper/internal/models"
+	"keeper/internal/pkg/compression"
 	"keeper/internal/utils"
-	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
@@ -25,75 +25,117 @@ var bucketItemDetailsProjection = bson.D{
 	primitive.E{Key: "data", Value: 1},
 	primitive.E{Key: "ttl", Value: 1},
 	primitive.E{Key: "type", Value: 1},
+	primitive.E{Key: "content_type", Value: 1},
+	primitive.E{Key: "size", Value: 1},
+	primitive.E{Key: "hash", Value: 1},
+	primitive.E{Key: "file_id", Value: 1},
+	primitive.E{Key: "compression", Value: 1},
+	primitive.E{Key: "deleted_at", Value: 1},
 	primitive.E{Key: "created_at", Value: 1},
 	primitive.E{Key: "updated_at", Value: 1},
 }
 
 const (
 	bucketItemCollectionName = "bucketitems"
+	// code of the server error of an update whose operator does not apply to the stored type
+	typeMismatchErrorCode = 14
 )
 
 type BucketItemRepository struct {
-	collection *mongo.Collection
-	ctx        context.Context
+	collection           *mongo.Collection
+	blobRepo             IBucketItemBlobRepository
+	changeRepo           IBucketChangeRepository
+	compressionThreshold int
+	compressionAlgorithm string
 }
 
 func NewBucketItemRepository(cfg *config.Config, dbClient *mongo.Client) IBucketItemRepository {
 	bucketItemCollection := dbClient.Database(cfg.DbName).Collection(bucketItemCollectionName)
+	compressionAlgorithm := cfg.ItemCompressionAlgorithm
+	if !compression.IsSupported(compressionAlgorithm) {
+		logrus.Warnf("unsupported item compression algorithm '%s', using %s", compressionAlgorithm, compression.AlgorithmZstd)
+		compressionAlgorithm = compression.AlgorithmZstd
+	}
 	return &BucketItemRepository{
-		collection: bucketItemCollection,
-		ctx:        context.TODO(),
+		collection:           bucketItemCollection,
+		blobRepo:             NewBucketItemBlobRepository(cfg, dbClient),
+		changeRepo:           NewBucketChangeRepository(cfg, dbClient),
+		compressionThreshold: cfg.ItemCompressionThreshold,
+		compressionAlgorithm: compressionAlgorithm,
 	}
 }
 
 // Finds bucket items (using pagination and filtering)
-func (r *BucketItemRepository) FindBucketItemsPaged(filter bson.M, opts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.BucketItem, utils.PageInfo, error) {
+func (r *BucketItemRepository) FindBucketItemsPaged(ctx context.Context, filter bson.M, opts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.BucketItem, utils.PageInfo, error) {
 	bucketItems := []models.BucketItem{}
-	results, pageInfo, err := utils.FindManyWithPagination(r.collection, bucketItemDetailsProjection, bucketItems, r.ctx, filter, opts, paginationParams)
+	filter[notTrashedFilter.Key] = notTrashedFilter.Value
+	results, pageInfo, err := utils.FindManyWithPagination(r.collection, bucketItemDetailsProjection, bucketItems, ctx, filter, opts, paginationParams)
 	if err != nil {
 		return nil, utils.PageInfo{}, err
 	}
-	return results.([]models.BucketItem), pageInfo, nil
+	bucketItems = results.([]models.BucketItem)
+	if err := decompressBucketItems(bucketItems); err != nil {
+		return nil, utils.PageInfo{}, err
+	}
+	return bucketItems, pageInfo, nil
 }
 
 // Finds bucket items for a specific bucket UID
 // Accepts the bucket UID
 // Returns the list of bucket items and an error
-func (r *BucketItemRepository) FindBucketItems(bucketUID string) ([]models.BucketItem, error) {
+func (r *BucketItemRepository) FindBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error) {
 	bucketItems := []models.BucketItem{}
-	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}}
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, notTrashedFilter}
 	opts := options.Find().SetProjection(bucketItemDetailsProjection).SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}})
-	cursor, err := r.collection.Find(r.ctx, filter, opts)
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
-		logrus.WithError(err).Errorf("failed to find many bucket items")
+		logrus.WithContext(ctx).WithError(err).Errorf("failed to find many bucket items")
 		return nil, models.ErrBucketItemsNotFound
 	}
-	if err = cursor.All(r.ctx, &bucketItems); err != nil {
+	if err = cursor.All(ctx, &bucketItems); err != nil {
 		return nil, models.ErrBucketItemsNotFound
 	}
+	if err := decompressBucketItems(bucketItems); err != nil {
+		return nil, err
+	}
 	// logrus.Debug("found bucket items: ", bucketItems)
 	return bucketItems, nil
 }
 
 // Create a new bucket item
 // Accepts the new bucket item data, Returns an error on failure
-func (r *BucketItemRepository) CreateBucketItem(bucketItem *models.BucketItem) (primitive.ObjectID, error) {
-	result, err := r.collection.InsertOne(r.ctx, bucketItem)
+func (r *BucketItemRepository) CreateBucketItem(ctx context.Context, bucketItem *models.BucketItem) (primitive.ObjectID, error) {
+	stored, err := r.compressBucketItem(ctx, bucketItem)
 	if err != nil {
-		logrus.WithError(err).Error("error creating bucket item")
+		return primitive.ObjectID{}, err
+	}
+	result, err := r.collection.InsertOne(ctx, stored)
+	if mongo.IsDuplicateKeyError(err) {
+		return primitive.ObjectID{}, models.NewConflictError(models.ErrBucketItemAlreadyExists, "key", bucketItem.Key)
+	}
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating bucket item")
 		return primitive.ObjectID{}, fmt.Errorf("error creating bucket item: %s", err.Error())
 	}
+	if err := r.recordChanges(ctx, bucketItem.BucketUID, models.BucketChangeOpSet, bucketItem.Key); err != nil {
+		return result.InsertedID.(primitive.ObjectID), err
+	}
 	return result.InsertedID.(primitive.ObjectID), nil
 }
 
 // Update a bucket item data
 // Accepts the bucket item data, Returns an error on failure
-func (r *BucketItemRepository) UpdateBucketItem(bucketItem *models.BucketItem, key string) error {
+func (r *BucketItemRepository) UpdateBucketItem(ctx context.Context, bucketItem *models.BucketItem, key string) error {
 	filter := bson.D{
 		primitive.E{Key: "bucket_uid", Value: bucketItem.BucketUID},
 		primitive.E{Key: "key", Value: key},
+		notTrashedFilter,
 	}
-	bucketItemByte, err := bson.Marshal(bucketItem)
+	stored, err := r.compressBucketItem(ctx, bucketItem)
+	if err != nil {
+		return err
+	}
+	bucketItemByte, err := bson.Marshal(stored)
 	if err != nil {
 		return errors.New("error marshalling bucket item data")
 	}
@@ -101,147 +143,554 @@ func (r *BucketItemRepository) UpdateBucketItem(bucketItem *models.BucketItem, k
 	if err = bson.Unmarshal(bucketItemByte, update); err != nil {
 		return errors.New("error unmarshalling bucket item update data")
 	}
+	updateOps := bson.D{primitive.E{Key: "$set", Value: update}}
+	// clear the compression details of values that are no longer stored compressed
+	if !stored.IsCompressed() && stored.Data != nil {
+		updateOps = append(updateOps, primitive.E{Key: "$unset", Value: bson.D{
+			primitive.E{Key: "compression", Value: ""},
+			primitive.E{Key: "raw_size", Value: ""},
+			primitive.E{Key: "stored_size", Value: ""},
+		}})
+	}
 
 	opts := options.Update().SetUpsert(true)
 	_, err = r.collection.UpdateOne(
-		r.ctx,
+		ctx,
 		filter,
-		bson.D{primitive.E{Key: "$set", Value: update}},
+		updateOps,
 		opts,
 	)
+	// the item was renamed to an existing key, or created concurrently by another upsert
+	if mongo.IsDuplicateKeyError(err) {
+		return models.NewConflictError(models.ErrBucketItemAlreadyExists, "key", bucketItem.Key)
+	}
 	if err != nil {
-		logrus.WithError(err).Error("error updating bucket item")
+		logrus.WithContext(ctx).WithError(err).Error("error updating bucket item")
 		return err
 	}
-	return nil
+	// a renamed item is gone from its previous key
+	if bucketItem.Key != "" && bucketItem.Key != key {
+		if err := r.recordChanges(ctx, bucketItem.BucketUID, models.BucketChangeOpDelete, key); err != nil {
+			return err
+		}
+		key = bucketItem.Key
+	}
+	return r.recordChanges(ctx, bucketItem.BucketUID, models.BucketChangeOpSet, key)
 }
 
 // Increment a bucket item integer value
 // Accepts the bucket UID 'bucketUID', item key 'key', and increment amount 'amount'
 // Returns an error
-func (r *BucketItemRepository) IncrementIntItem(bucketUID string, key string, amount int) error {
+func (r *BucketItemRepository) IncrementIntItem(ctx context.Context, bucketUID string, key string, amount int) error {
 	filter := bson.D{
 		primitive.E{Key: "bucket_uid", Value: bucketUID},
 		primitive.E{Key: "key", Value: key},
+		notTrashedFilter,
 	}
 	opts := options.Update().SetUpsert(true)
 	_, err := r.collection.UpdateOne(
-		r.ctx,
+		ctx,
 		filter,
 		bson.D{primitive.E{Key: "$inc", Value: bson.D{primitive.E{Key: "data", Value: amount}}}},
 		opts,
 	)
 	if err != nil {
-		logrus.WithError(err).Error("error incrementing bucket item")
-		return errors.New("cannot increment non-numeric data")
+		logrus.WithContext(ctx).WithError(err).Error("error incrementing bucket item")
+		// the stored value is not a number
+		var serverErr mongo.ServerError
+		if errors.As(err, &serverErr) && serverErr.HasErrorCode(typeMismatchErrorCode) {
+			return models.NewBadRequestError(errors.New("cannot increment non-numeric data"))
+		}
+		return models.ErrUpdatingBucketItem
 	}
-	return nil
+	return r.recordChanges(ctx, bucketUID, models.BucketChangeOpSet, key)
 }
 
 // Find a single bucket item by the id field
 // Accepts a bucket item id
 // Returns the found bucket item and an error
-func (r *BucketItemRepository) FindBucketItemByID(id string) (*models.BucketItem, error) {
+func (r *BucketItemRepository) FindBucketItemByID(ctx context.Context, id string) (*models.BucketItem, error) {
 	bucketItem := &models.BucketItem{}
 	ID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, models.ErrInvalidObjectID
 	}
-	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
+	filter := bson.D{primitive.E{Key: "_id", Value: ID}, notTrashedFilter}
 	opts := options.FindOne().SetProjection(bucketItemDetailsProjection)
-	if err := r.collection.FindOne(r.ctx, filter, opts).Decode(bucketItem); err != nil {
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(bucketItem); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, models.ErrBucketItemNotFound
 		}
 		return nil, err
 	}
 	// Check if the bucket item is expired
-	// the bucket item has expired if the current time is greater than the\
-	// sum of the time the item was created and the specified TTL duration of the item
-	if bucketItem.TTL != 0 && time.Now().After(bucketItem.CreatedAt.Time().Add(time.Duration(bucketItem.TTL))) {
+	if bucketItem.IsExpired() {
 		return nil, models.ErrBucketItemExpired
 	}
-	logrus.Info("found bucket item: ", bucketItem)
+	if err := decompressBucketItem(bucketItem); err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).WithFields(logrus.Fields{"bucket_uid": bucketItem.BucketUID, "key": bucketItem.Key}).Debug("found bucket item")
 	return bucketItem, nil
 }
 
 // Find a single bucket item by the bucket UID and key name
 // Accepts a bucket UID and bucket item key name
 // Returns the found bucket item and an error
-func (r *BucketItemRepository) FindBucketItemByKeyName(bucketUID string, key string) (*models.BucketItem, error) {
+func (r *BucketItemRepository) FindBucketItemByKeyName(ctx context.Context, bucketUID string, key string) (*models.BucketItem, error) {
 	bucketItem := &models.BucketItem{}
-	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, primitive.E{Key: "key", Value: key}}
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, primitive.E{Key: "key", Value: key}, notTrashedFilter}
 	opts := options.FindOne().SetProjection(bucketItemDetailsProjection)
-	if err := r.collection.FindOne(r.ctx, filter, opts).Decode(bucketItem); err != nil {
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(bucketItem); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, models.ErrBucketItemNotFound
 		}
 		return nil, err
 	}
-	logrus.Info("found bucket item: ", bucketItem)
+	if err := decompressBucketItem(bucketItem); err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).WithFields(logrus.Fields{"bucket_uid": bucketItem.BucketUID, "key": bucketItem.Key}).Debug("found bucket item")
 	return bucketItem, nil
 }
 
 // Delete a single bucket item based on the id field
 // Accepts a bucket item id, Returns an error on failure
-func (r *BucketItemRepository) DeleteBucketItemById(id string) error {
+func (r *BucketItemRepository) DeleteBucketItemById(ctx context.Context, id string) error {
 	ID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return models.ErrInvalidObjectID
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
-	result, err := r.collection.DeleteOne(r.ctx, filter)
+	fileIDs, err := r.findBucketItemFileIDs(ctx, filter)
+	if err != nil {
+		return err
+	}
+	keys, err := r.findBucketItemKeys(ctx, filter)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, filter)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting bucket item by id")
+		logrus.WithContext(ctx).WithError(err).Error("error deleting bucket item by id")
 		return models.ErrDeletingBucketItem
 	}
 	if result.DeletedCount == 0 {
 		return models.ErrDeletingBucketItem
 	}
-	return nil
+	r.deleteBlobs(ctx, fileIDs)
+	return r.recordBucketItemKeyChanges(ctx, keys, models.BucketChangeOpDelete)
 }
 
 // Delete multiple bucket items based on the specified ids
 // Accepts a list of bucket item ids, Returns an error on failure
-func (r *BucketItemRepository) DeleteBucketItemsById(ids []string) error {
+func (r *BucketItemRepository) DeleteBucketItemsById(ctx context.Context, ids []string) error {
 	objectIDs, err := utils.MapIDsToObjectIDs(ids)
 	if err != nil {
-		return err
+		return models.ErrInvalidObjectID
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$in", Value: objectIDs}}}}
-	result, err := r.collection.DeleteMany(r.ctx, filter, nil)
+	fileIDs, err := r.findBucketItemFileIDs(ctx, filter)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting bucket items")
+		return err
+	}
+	keys, err := r.findBucketItemKeys(ctx, filter)
+	if err != nil {
+		return err
+	}
+	result, err := r.collection.DeleteMany(ctx, filter, nil)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting bucket items")
 		return models.ErrDeletingBucketItems
 	}
 	if result.DeletedCount == 0 {
 		return models.ErrDeletingBucketItems
 	}
-	return nil
+	r.deleteBlobs(ctx, fileIDs)
+	return r.recordBucketItemKeyChanges(ctx, keys, models.BucketChangeOpDelete)
 }
 
 // Delete a single bucket item based on the key field
-func (r *BucketItemRepository) DeleteBucketItemByKeyName(bucketUID string, key string) error {
-	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, primitive.E{Key: "key", Value: key}}
-	result, err := r.collection.DeleteOne(r.ctx, filter, nil)
+func (r *BucketItemRepository) DeleteBucketItemByKeyName(ctx context.Context, bucketUID string, key string) error {
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, primitive.E{Key: "key", Value: key}, notTrashedFilter}
+	fileIDs, err := r.findBucketItemFileIDs(ctx, filter)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting bucket item")
+		return err
+	}
+	result, err := r.collection.DeleteOne(ctx, filter, nil)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting bucket item")
 		return models.ErrDeletingBucketItem
 	}
 	if result.DeletedCount == 0 {
 		return models.ErrDeletingBucketItem
 	}
-	return nil
+	r.deleteBlobs(ctx, fileIDs)
+	return r.recordChanges(ctx, bucketUID, models.BucketChangeOpDelete, key)
 }
 
-// Deletes all the bucket items for a particular bucket
+// Deletes all the bucket items for a particular bucket, including the trashed ones
+// the bucket is going away, so its change feed is deleted as well
 // Accepts the bucket UID, Returns an error on failure
-func (r *BucketItemRepository) DeleteBucketItems(bucketUID string) error {
+func (r *BucketItemRepository) DeleteBucketItems(ctx context.Context, bucketUID string) error {
 	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}}
-	_, err := r.collection.DeleteMany(r.ctx, filter, nil)
+	fileIDs, err := r.findBucketItemFileIDs(ctx, filter)
+	if err != nil {
+		return err
+	}
+	_, err = r.collection.DeleteMany(ctx, filter, nil)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting bucket items")
+		logrus.WithContext(ctx).WithError(err).Error("error deleting bucket items")
 		return models.ErrDeletingBucketItems
 	}
+	r.deleteBlobs(ctx, fileIDs)
+	if err := r.changeRepo.DeleteBucketChanges(ctx, bucketUID); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting the change feed of bucket: %s", bucketUID)
+	}
 	return nil
 }
+
+// Moves a single bucket item to the trash
+// Accepts the bucket UID, item key name, and the time of deletion
+// Returns an error
+func (r *BucketItemRepository) TrashBucketItemByKeyName(ctx context.Context, bucketUID string, key string, deletedAt primitive.DateTime) error {
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, primitive.E{Key: "key", Value: key}, notTrashedFilter}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "deleted_at", Value: deletedAt}}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error moving bucket item to the trash")
+		return models.ErrTrashingBucketItem
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrBucketItemNotFound
+	}
+	return r.recordChanges(ctx, bucketUID, models.BucketChangeOpDelete, key)
+}
+
+// Moves all the bucket items of a bucket to the trash
+// the items share the time of deletion so that they can be restored along with the bucket
+// Accepts the bucket UID and the time of deletion
+// Returns an error
+func (r *BucketItemRepository) TrashBucketItems(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) error {
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, notTrashedFilter}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "deleted_at", Value: deletedAt}}}}
+	if _, err := r.collection.UpdateMany(ctx, filter, update); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error moving bucket items to the trash")
+		return models.ErrTrashingBucketItem
+	}
+	return r.recordChanges(ctx, bucketUID, models.BucketChangeOpReset)
+}
+
+// Restores a single bucket item from the trash
+// Accepts the bucket item id
+// Returns an error
+func (r *BucketItemRepository) RestoreBucketItemByID(ctx context.Context, id string) error {
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: ID}, trashedFilter}
+	keys, err := r.findBucketItemKeys(ctx, filter)
+	if err != nil {
+		return err
+	}
+	update := bson.D{primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "deleted_at", Value: ""}}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	// an item with the same key was created since the item was trashed
+	if mongo.IsDuplicateKeyError(err) {
+		key := ""
+		for _, bucketKeys := range keys {
+			key = bucketKeys[0]
+		}
+		return models.NewConflictError(models.ErrBucketItemAlreadyExists, "key", key)
+	}
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error restoring bucket item")
+		return models.ErrRestoringBucketItem
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrBucketItemNotFound
+	}
+	return r.recordBucketItemKeyChanges(ctx, keys, models.BucketChangeOpSet)
+}
+
+// Restores the bucket items that were moved to the trash along with their bucket
+// Accepts the bucket UID and the time the bucket was deleted
+// Returns an error
+func (r *BucketItemRepository) RestoreBucketItems(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) error {
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, primitive.E{Key: "deleted_at", Value: deletedAt}}
+	update := bson.D{primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "deleted_at", Value: ""}}}}
+	if _, err := r.collection.UpdateMany(ctx, filter, update); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error restoring bucket items")
+		return models.ErrRestoringBucketItem
+	}
+	return r.recordChanges(ctx, bucketUID, models.BucketChangeOpReset)
+}
+
+// Find a single trashed bucket item by the id field
+// Accepts a bucket item id
+// Returns the found bucket item and an error
+func (r *BucketItemRepository) FindTrashedBucketItemByID(ctx context.Context, id string) (*models.BucketItem, error) {
+	bucketItem := &models.BucketItem{}
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: ID}, trashedFilter}
+	opts := options.FindOne().SetProjection(bucketItemDetailsProjection)
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(bucketItem); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, models.ErrBucketItemNotFound
+		}
+		return nil, err
+	}
+	if err := decompressBucketItem(bucketItem); err != nil {
+		return nil, err
+	}
+	return bucketItem, nil
+}
+
+// Finds the trashed bucket items of a bucket, most recently deleted first
+// Accepts the bucket UID
+// Returns the list of trashed bucket items and an error
+func (r *BucketItemRepository) FindTrashedBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error) {
+	bucketItems := []models.BucketItem{}
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, trashedFilter}
+	opts := options.Find().SetProjection(bucketItemDetailsProjection).SetSort(bson.D{primitive.E{Key: "deleted_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("failed to find trashed bucket items")
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if err = cursor.All(ctx, &bucketItems); err != nil {
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if err := decompressBucketItems(bucketItems); err != nil {
+		return nil, err
+	}
+	return bucketItems, nil
+}
+
+// Permanently deletes the bucket items that were moved to the trash before a cutoff time
+// Accepts the cutoff time
+// Returns the number of deleted bucket items and an error
+func (r *BucketItemRepository) PurgeTrashedBucketItems(ctx context.Context, before primitive.DateTime) (int64, error) {
+	filter := bson.D{trashedBeforeFilter(before)}
+	fileIDs, err := r.findBucketItemFileIDs(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	result, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error purging trashed bucket items")
+		return 0, models.ErrDeletingBucketItems
+	}
+	r.deleteBlobs(ctx, fileIDs)
+	return result.DeletedCount, nil
+}
+
+// Removes the author of every bucket item a user wrote, including the items in other users' buckets
+// Accepts the user ID
+// Returns the number of anonymized bucket items and an error
+func (r *BucketItemRepository) AnonymizeUserBucketItems(ctx context.Context, userID string) (int64, error) {
+	ID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "user_id", Value: ID}}
+	update := bson.D{primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "user_id", Value: ""}}}}
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error anonymizing user bucket items")
+		return 0, models.ErrUpdatingBucketItem
+	}
+	return result.ModifiedCount, nil
+}
+
+// Marks the bucket items whose TTL has run out since the last call as expired
+// Accepts the current time and the maximum number of bucket items to mark
+// Returns the newly expired bucket items and an error
+func (r *BucketItemRepository) MarkExpiredBucketItems(ctx context.Context, now primitive.DateTime, limit int64) ([]models.BucketItem, error) {
+	bucketItems := []models.BucketItem{}
+	filter := append(expiredBeforeFilter(now),
+		primitive.E{Key: "expired_at", Value: bson.D{primitive.E{Key: "$exists", Value: false}}},
+		notTrashedFilter,
+	)
+	opts := options.Find().SetProjection(bucketItemDetailsProjection).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error finding expired bucket items")
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if err = cursor.All(ctx, &bucketItems); err != nil {
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if len(bucketItems) == 0 {
+		return bucketItems, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(bucketItems))
+	for _, bucketItem := range bucketItems {
+		ids = append(ids, bucketItem.ID)
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "expired_at", Value: now}}}}
+	_, err = r.collection.UpdateMany(ctx, bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$in", Value: ids}}}}, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error marking expired bucket items")
+		return nil, models.ErrUpdatingBucketItem
+	}
+	keys := make(map[string][]string)
+	for _, bucketItem := range bucketItems {
+		keys[bucketItem.BucketUID] = append(keys[bucketItem.BucketUID], bucketItem.Key)
+	}
+	// the items are expired either way, they are returned with the error
+	return bucketItems, r.recordBucketItemKeyChanges(ctx, keys, models.BucketChangeOpExpire)
+}
+
+// Permanently deletes the bucket items whose TTL ran out before a time, including the trashed ones
+// Accepts the time
+// Returns the deleted bucket items, without the blobs of the binary ones, and an error
+func (r *BucketItemRepository) PurgeExpiredBucketItems(ctx context.Context, before primitive.DateTime) ([]models.BucketItem, error) {
+	bucketItems := []models.BucketItem{}
+	filter := expiredBeforeFilter(before)
+	// the values are kept for the usage they free
+	opts := options.Find().SetProjection(bson.D{
+		primitive.E{Key: "bucket_uid", Value: 1},
+		primitive.E{Key: "key", Value: 1},
+		primitive.E{Key: "type", Value: 1},
+		primitive.E{Key: "data", Value: 1},
+		primitive.E{Key: "compression", Value: 1},
+		primitive.E{Key: "size", Value: 1},
+		primitive.E{Key: "file_id", Value: 1},
+		primitive.E{Key: "deleted_at", Value: 1},
+	})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error finding expired bucket items")
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if err = cursor.All(ctx, &bucketItems); err != nil {
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if len(bucketItems) == 0 {
+		return bucketItems, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(bucketItems))
+	fileIDs := []primitive.ObjectID{}
+	keys := make(map[string][]string)
+	for _, bucketItem := range bucketItems {
+		ids = append(ids, bucketItem.ID)
+		if !bucketItem.FileID.IsZero() {
+			fileIDs = append(fileIDs, bucketItem.FileID)
+		}
+		// trashed items already recorded their deletion
+		if !bucketItem.IsTrashed() {
+			keys[bucketItem.BucketUID] = append(keys[bucketItem.BucketUID], bucketItem.Key)
+		}
+	}
+	if _, err := r.collection.DeleteMany(ctx, bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$in", Value: ids}}}}); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error purging expired bucket items")
+		return nil, models.ErrDeletingBucketItems
+	}
+	r.deleteBlobs(ctx, fileIDs)
+	err = r.recordBucketItemKeyChanges(ctx, keys, models.BucketChangeOpDelete)
+	// the items are gone either way, a value that cannot be decompressed is reported and left as it is stored
+	for i := range bucketItems {
+		decompressBucketItem(&bucketItems[i])
+	}
+	return bucketItems, err
+}
+
+// Filter matching the bucket items with a TTL that ran out before a time
+func expiredBeforeFilter(before primitive.DateTime) bson.D {
+	return bson.D{
+		primitive.E{Key: "ttl", Value: bson.D{primitive.E{Key: "$gt", Value: 0}}},
+		// created_at + ttl (in seconds) has passed
+		primitive.E{Key: "$expr", Value: bson.D{primitive.E{Key: "$lt", Value: bson.A{
+			bson.D{primitive.E{Key: "$add", Value: bson.A{"$created_at", bson.D{primitive.E{Key: "$multiply", Value: bson.A{"$ttl", 1000}}}}}},
+			before,
+		}}}},
+	}
+}
+
+// Finds the GridFS file IDs of the binary items matching a filter
+// Returns the list of file IDs and an error
+func (r *BucketItemRepository) findBucketItemFileIDs(ctx context.Context, filter bson.D) ([]primitive.ObjectID, error) {
+	bucketItems := []models.BucketItem{}
+	filter = append(filter, primitive.E{Key: "file_id", Value: bson.D{primitive.E{Key: "$exists", Value: true}}})
+	opts := options.Find().SetProjection(bson.D{primitive.E{Key: "file_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("failed to find bucket item file ids")
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if err = cursor.All(ctx, &bucketItems); err != nil {
+		return nil, models.ErrBucketItemsNotFound
+	}
+	fileIDs := []primitive.ObjectID{}
+	for _, bucketItem := range bucketItems {
+		fileIDs = append(fileIDs, bucketItem.FileID)
+	}
+	return fileIDs, nil
+}
+
+// Finds the keys of the bucket items matching a filter
+// Returns the keys grouped by bucket UID and an error
+func (r *BucketItemRepository) findBucketItemKeys(ctx context.Context, filter bson.D) (map[string][]string, error) {
+	bucketItems := []models.BucketItem{}
+	opts := options.Find().SetProjection(bson.D{primitive.E{Key: "bucket_uid", Value: 1}, primitive.E{Key: "key", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("failed to find bucket item keys")
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if err = cursor.All(ctx, &bucketItems); err != nil {
+		return nil, models.ErrBucketItemsNotFound
+	}
+	keys := make(map[string][]string)
+	for _, bucketItem := range bucketItems {
+		keys[bucketItem.BucketUID] = append(keys[bucketItem.BucketUID], bucketItem.Key)
+	}
+	return keys, nil
+}
+
+// Records a change of the bucket items in the change feed of their bucket
+// the bucket items have already changed, a change that cannot be recorded is replaced by a reset
+// so that the consumers still resync, and an error is returned if the reset cannot be recorded either
+func (r *BucketItemRepository) recordChanges(ctx context.Context, bucketUID string, op string, keys ...string) error {
+	err := r.changeRepo.RecordChanges(ctx, bucketUID, op, keys...)
+	if err == nil {
+		return nil
+	}
+	logrus.WithContext(ctx).WithError(err).Errorf("error recording %s change for bucket: %s", op, bucketUID)
+	if op != models.BucketChangeOpReset {
+		if err = r.changeRepo.RecordChanges(ctx, bucketUID, models.BucketChangeOpReset); err == nil {
+			return nil
+		}
+		logrus.WithContext(ctx).WithError(err).Errorf("error recording reset change for bucket: %s", bucketUID)
+	}
+	return models.ErrRecordingBucketChange
+}
+
+// Records a change of bucket items grouped by bucket UID
+// every bucket is recorded, the error of the last one that failed is returned
+func (r *BucketItemRepository) recordBucketItemKeyChanges(ctx context.Context, keys map[string][]string, op string) error {
+	var err error
+	for bucketUID, bucketKeys := range keys {
+		if recordErr := r.recordChanges(ctx, bucketUID, op, bucketKeys...); recordErr != nil {
+			err = recordErr
+		}
+	}
+	return err
+}
+
+// Deletes the GridFS blobs left behind by deleted binary items
+// blobs that cannot be deleted are only logged, the bucket item itself is already gone
+func (r *BucketItemRepository) deleteBlobs(ctx context.Context, fileIDs []primitive.ObjectID) {
+	for _, fileID := range fileIDs {
+		if err := r.blobRepo.DeleteBlob(ctx, fileID); err != nil && !errors.Is(err, models.ErrBlobNotFound) {
+			logrus.WithContext(ctx).WithError(err).Errorf("error deleting blob: %s", fileID.Hex())
+		}
+	}
+}
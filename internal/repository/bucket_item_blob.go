@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"keeper/internal/config"
+	"keeper/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	bucketItemBlobBucketName = "bucketitemblobs"
+)
+
+type BucketItemBlobRepository struct {
+	db *mongo.Database
+}
+
+func NewBucketItemBlobRepository(cfg *config.Config, dbClient *mongo.Client) IBucketItemBlobRepository {
+	return &BucketItemBlobRepository{
+		db: dbClient.Database(cfg.DbName),
+	}
+}
+
+// Returns the GridFS bucket of the blobs bounded by the deadline of ctx,
+// the GridFS API of the driver takes deadlines rather than contexts
+func (r *BucketItemBlobRepository) bucket(ctx context.Context) (*gridfs.Bucket, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	bucket, err := gridfs.NewBucket(r.db, options.GridFSBucket().SetName(bucketItemBlobBucketName))
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating gridfs bucket")
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetReadDeadline(deadline)
+		bucket.SetWriteDeadline(deadline)
+	}
+	return bucket, nil
+}
+
+// Uploads a blob to GridFS
+// Accepts the filename, the source stream, and metadata to be stored with the file
+// Returns the ID of the stored file and an error
+func (r *BucketItemBlobRepository) UploadBlob(ctx context.Context, filename string, source io.Reader, metadata bson.M) (primitive.ObjectID, error) {
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return primitive.ObjectID{}, models.ErrUploadingBlob
+	}
+	opts := options.GridFSUpload().SetMetadata(metadata)
+	fileID, err := bucket.UploadFromStream(filename, source, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error uploading blob")
+		return primitive.ObjectID{}, models.ErrUploadingBlob
+	}
+	return fileID, nil
+}
+
+// Opens a stream for reading a blob from GridFS
+// Accepts the file ID, Returns the download stream and an error
+func (r *BucketItemBlobRepository) OpenBlob(ctx context.Context, fileID primitive.ObjectID) (io.ReadCloser, error) {
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := bucket.OpenDownloadStream(fileID)
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return nil, models.ErrBlobNotFound
+		}
+		logrus.WithContext(ctx).WithError(err).Error("error opening blob")
+		return nil, err
+	}
+	return stream, nil
+}
+
+// Deletes a blob and its chunks from GridFS
+// Accepts the file ID, Returns an error on failure
+func (r *BucketItemBlobRepository) DeleteBlob(ctx context.Context, fileID primitive.ObjectID) error {
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return models.ErrDeletingBlob
+	}
+	if err := bucket.Delete(fileID); err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return models.ErrBlobNotFound
+		}
+		logrus.WithContext(ctx).WithError(err).Error("error deleting blob")
+		return models.ErrDeletingBlob
+	}
+	return nil
+}
+
+// Copies a blob to a new GridFS file, keeping its filename and metadata
+// Accepts the file ID, Returns the ID of the copy and an error
+func (r *BucketItemBlobRepository) CopyBlob(ctx context.Context, fileID primitive.ObjectID) (primitive.ObjectID, error) {
+	bucket, err := r.bucket(ctx)
+	if err != nil {
+		return primitive.ObjectID{}, models.ErrCopyingBlob
+	}
+	stream, err := bucket.OpenDownloadStream(fileID)
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			return primitive.ObjectID{}, models.ErrBlobNotFound
+		}
+		logrus.WithContext(ctx).WithError(err).Error("error opening blob to copy")
+		return primitive.ObjectID{}, models.ErrCopyingBlob
+	}
+	defer stream.Close()
+	file := stream.GetFile()
+	opts := options.GridFSUpload()
+	if file.Metadata != nil {
+		opts.SetMetadata(file.Metadata)
+	}
+	copyID, err := bucket.UploadFromStream(file.Name, stream, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error copying blob")
+		return primitive.ObjectID{}, models.ErrCopyingBlob
+	}
+	return copyID, nil
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"keeper/internal/models"
+	"keeper/internal/pkg/compression"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// wraps a bucket item value so that any bson value can be encoded on its own
+type compressedBucketItemData struct {
+	Value interface{} `bson:"v"`
+}
+
+// Compresses the data of a bucket item whose encoded size is above the compression threshold
+// Returns a copy of the bucket item to store, the original bucket item is left untouched
+func (r *BucketItemRepository) compressBucketItem(ctx context.Context, bucketItem *models.BucketItem) (*models.BucketItem, error) {
+	stored := *bucketItem
+	if r.compressionThreshold <= 0 || bucketItem.Data == nil || bucketItem.IsCompressed() {
+		return &stored, nil
+	}
+	raw, err := bson.Marshal(compressedBucketItemData{Value: bucketItem.Data})
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error encoding bucket item data")
+		return nil, models.ErrCompressingItem
+	}
+	if len(raw) <= r.compressionThreshold {
+		return &stored, nil
+	}
+	compressed, err := compression.Compress(r.compressionAlgorithm, raw)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error compressing bucket item data")
+		return nil, models.ErrCompressingItem
+	}
+	// incompressible data is stored as is
+	if len(compressed) >= len(raw) {
+		return &stored, nil
+	}
+	stored.Data = primitive.Binary{Data: compressed}
+	stored.Compression = r.compressionAlgorithm
+	stored.RawSize = int64(len(raw))
+	stored.StoredSize = int64(len(compressed))
+	return &stored, nil
+}
+
+// Restores the original data of a compressed bucket item in place
+func decompressBucketItem(bucketItem *models.BucketItem) error {
+	if !bucketItem.IsCompressed() {
+		return nil
+	}
+	data, ok := bucketItem.Data.(primitive.Binary)
+	if !ok {
+		return models.ErrDecompressingItem
+	}
+	raw, err := compression.Decompress(bucketItem.Compression, data.Data)
+	if err != nil {
+		logrus.WithError(err).Errorf("error decompressing bucket item: %s", bucketItem.Key)
+		return models.ErrDecompressingItem
+	}
+	value := compressedBucketItemData{}
+	if err := bson.Unmarshal(raw, &value); err != nil {
+		logrus.WithError(err).Errorf("error decoding bucket item: %s", bucketItem.Key)
+		return models.ErrDecompressingItem
+	}
+	bucketItem.Data = value.Value
+	bucketItem.Compression = ""
+	bucketItem.RawSize = 0
+	bucketItem.StoredSize = 0
+	return nil
+}
+
+// Restores the original data of a list of bucket items in place
+func decompressBucketItems(bucketItems []models.BucketItem) error {
+	for i := range bucketItems {
+		if err := decompressBucketItem(&bucketItems[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the compression statistics of the items in a bucket
+// Accepts the bucket UID
+// Returns the compression statistics and an error
+func (r *BucketItemRepository) GetCompressionStats(ctx context.Context, bucketUID string) (*models.BucketItemCompressionStats, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{primitive.E{Key: "$match", Value: bson.D{
+			primitive.E{Key: "bucket_uid", Value: bucketUID},
+			primitive.E{Key: "compression", Value: bson.D{primitive.E{Key: "$exists", Value: true}}},
+		}}},
+		bson.D{primitive.E{Key: "$group", Value: bson.D{
+			primitive.E{Key: "_id", Value: "$bucket_uid"},
+			primitive.E{Key: "compressed_items", Value: bson.D{primitive.E{Key: "$sum", Value: 1}}},
+			primitive.E{Key: "raw_size", Value: bson.D{primitive.E{Key: "$sum", Value: "$raw_size"}}},
+			primitive.E{Key: "stored_size", Value: bson.D{primitive.E{Key: "$sum", Value: "$stored_size"}}},
+		}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error aggregating bucket item compression stats")
+		return nil, err
+	}
+	results := []models.BucketItemCompressionStats{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	stats := &models.BucketItemCompressionStats{BucketUID: bucketUID}
+	if len(results) > 0 {
+		stats = &results[0]
+	}
+	if stats.StoredSize > 0 {
+		stats.Ratio = float64(stats.RawSize) / float64(stats.StoredSize)
+	}
+	return stats, nil
+}
+
+// Compresses the existing uncompressed items that are above the compression threshold
+// Accepts the bucket UID, all the buckets are migrated if it is empty
+// Returns the number of compressed bucket items and an error
+func (r *BucketItemRepository) CompressBucketItems(ctx context.Context, bucketUID string) (int64, error) {
+	if r.compressionThreshold <= 0 {
+		return 0, nil
+	}
+	filter := bson.D{
+		primitive.E{Key: "compression", Value: bson.D{primitive.E{Key: "$exists", Value: false}}},
+		primitive.E{Key: "file_id", Value: bson.D{primitive.E{Key: "$exists", Value: false}}},
+	}
+	if bucketUID != "" {
+		filter = append(filter, primitive.E{Key: "bucket_uid", Value: bucketUID})
+	}
+	opts := options.Find().SetProjection(bson.D{
+		primitive.E{Key: "_id", Value: 1},
+		primitive.E{Key: "key", Value: 1},
+		primitive.E{Key: "data", Value: 1},
+	})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error finding bucket items to compress")
+		return 0, models.ErrBucketItemsNotFound
+	}
+	defer cursor.Close(ctx)
+
+	var count int64
+	for cursor.Next(ctx) {
+		bucketItem := &models.BucketItem{}
+		if err := cursor.Decode(bucketItem); err != nil {
+			return count, err
+		}
+		stored, err := r.compressBucketItem(ctx, bucketItem)
+		if err != nil {
+			return count, err
+		}
+		if !stored.IsCompressed() {
+			continue
+		}
+		update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "data", Value: stored.Data},
+			primitive.E{Key: "compression", Value: stored.Compression},
+			primitive.E{Key: "raw_size", Value: stored.RawSize},
+			primitive.E{Key: "stored_size", Value: stored.StoredSize},
+		}}}
+		// matching on the read data skips items that were rewritten in the meantime
+		itemFilter := bson.D{
+			primitive.E{Key: "_id", Value: bucketItem.ID},
+			primitive.E{Key: "data", Value: bucketItem.Data},
+			primitive.E{Key: "compression", Value: bson.D{primitive.E{Key: "$exists", Value: false}}},
+		}
+		if _, err := r.collection.UpdateOne(ctx, itemFilter, update); err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("error compressing bucket item: %s", bucketItem.Key)
+			return count, models.ErrCompressingItem
+		}
+		count++
+	}
+	return count, cursor.Err()
+}
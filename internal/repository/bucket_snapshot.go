@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	bucketSnapshotCollectionName     = "bucketsnapshots"
+	bucketSnapshotItemCollectionName = "bucketsnapshotitems"
+)
+
+// a bucket item as it was when the snapshot was taken
+type bucketSnapshotItem struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	SnapshotID primitive.ObjectID `bson:"snapshot_id"`
+	Item       models.BucketItem  `bson:"item"`
+}
+
+type BucketSnapshotRepository struct {
+	collection     *mongo.Collection
+	itemCollection *mongo.Collection
+	blobRepo       IBucketItemBlobRepository
+}
+
+func NewBucketSnapshotRepository(cfg *config.Config, dbClient *mongo.Client) IBucketSnapshotRepository {
+	db := dbClient.Database(cfg.DbName)
+	return &BucketSnapshotRepository{
+		collection:     db.Collection(bucketSnapshotCollectionName),
+		itemCollection: db.Collection(bucketSnapshotItemCollectionName),
+		blobRepo:       NewBucketItemBlobRepository(cfg, dbClient),
+	}
+}
+
+// Save a new snapshot along with its items
+// the blobs of binary items must already be copies owned by the snapshot
+// Accepts the snapshot and the list of bucket items
+// Returns the ID of the snapshot and an error
+func (r *BucketSnapshotRepository) CreateSnapshot(ctx context.Context, snapshot *models.BucketSnapshot, items []models.BucketItem) (primitive.ObjectID, error) {
+	snapshot.ID = primitive.NewObjectID()
+	snapshot.ItemCount = len(items)
+	if len(items) > 0 {
+		documents := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			documents = append(documents, bucketSnapshotItem{SnapshotID: snapshot.ID, Item: item})
+		}
+		if _, err := r.itemCollection.InsertMany(ctx, documents); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("error creating snapshot items")
+			r.itemCollection.DeleteMany(ctx, bson.D{primitive.E{Key: "snapshot_id", Value: snapshot.ID}})
+			return primitive.ObjectID{}, models.ErrCreatingSnapshot
+		}
+	}
+	// the snapshot is only saved once all its items are, so a partial snapshot is never visible
+	if _, err := r.collection.InsertOne(ctx, snapshot); err != nil {
+		r.itemCollection.DeleteMany(ctx, bson.D{primitive.E{Key: "snapshot_id", Value: snapshot.ID}})
+		if mongo.IsDuplicateKeyError(err) {
+			return primitive.ObjectID{}, models.NewConflictError(models.ErrSnapshotAlreadyExists, "name", snapshot.Name)
+		}
+		logrus.WithContext(ctx).WithError(err).Error("error creating snapshot")
+		return primitive.ObjectID{}, models.ErrCreatingSnapshot
+	}
+	return snapshot.ID, nil
+}
+
+// Find a bucket snapshot by its name
+// Accepts the bucket UID and the snapshot name
+// Returns the snapshot and an error
+func (r *BucketSnapshotRepository) FindSnapshotByName(ctx context.Context, bucketUID string, name string) (*models.BucketSnapshot, error) {
+	snapshot := &models.BucketSnapshot{}
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, primitive.E{Key: "name", Value: name}}
+	if err := r.collection.FindOne(ctx, filter).Decode(snapshot); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, models.ErrSnapshotNotFound
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Returns the snapshots of a bucket, most recent first
+func (r *BucketSnapshotRepository) FindSnapshotsByBucketUID(ctx context.Context, bucketUID string) ([]models.BucketSnapshot, error) {
+	snapshots := []models.BucketSnapshot{}
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}}
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find bucket snapshots")
+		return nil, models.ErrSnapshotNotFound
+	}
+	if err = cursor.All(ctx, &snapshots); err != nil {
+		return nil, models.ErrSnapshotNotFound
+	}
+	return snapshots, nil
+}
+
+// Returns the bucket items of a snapshot
+func (r *BucketSnapshotRepository) FindSnapshotItems(ctx context.Context, snapshotID primitive.ObjectID) ([]models.BucketItem, error) {
+	snapshotItems := []bucketSnapshotItem{}
+	filter := bson.D{primitive.E{Key: "snapshot_id", Value: snapshotID}}
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "item.key", Value: 1}})
+	cursor, err := r.itemCollection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find snapshot items")
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if err = cursor.All(ctx, &snapshotItems); err != nil {
+		return nil, models.ErrBucketItemsNotFound
+	}
+	items := make([]models.BucketItem, 0, len(snapshotItems))
+	for _, snapshotItem := range snapshotItems {
+		items = append(items, snapshotItem.Item)
+	}
+	return items, nil
+}
+
+// Delete a snapshot along with its items and blobs
+// Accepts the snapshot ID, Returns an error on failure
+func (r *BucketSnapshotRepository) DeleteSnapshot(ctx context.Context, snapshotID primitive.ObjectID) error {
+	return r.deleteSnapshots(ctx, []primitive.ObjectID{snapshotID})
+}
+
+// Delete all the snapshots of a bucket along with their items and blobs
+// Accepts the bucket UID, Returns an error on failure
+func (r *BucketSnapshotRepository) DeleteBucketSnapshots(ctx context.Context, bucketUID string) error {
+	snapshots, err := r.FindSnapshotsByBucketUID(ctx, bucketUID)
+	if err != nil {
+		return err
+	}
+	snapshotIDs := make([]primitive.ObjectID, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		snapshotIDs = append(snapshotIDs, snapshot.ID)
+	}
+	return r.deleteSnapshots(ctx, snapshotIDs)
+}
+
+func (r *BucketSnapshotRepository) deleteSnapshots(ctx context.Context, snapshotIDs []primitive.ObjectID) error {
+	if len(snapshotIDs) == 0 {
+		return nil
+	}
+	// the snapshots are deleted first so that a partially deleted snapshot is never visible
+	filter := bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$in", Value: snapshotIDs}}}}
+	if _, err := r.collection.DeleteMany(ctx, filter); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting snapshots")
+		return models.ErrDeletingSnapshot
+	}
+	itemFilter := bson.D{primitive.E{Key: "snapshot_id", Value: bson.D{primitive.E{Key: "$in", Value: snapshotIDs}}}}
+	fileIDs, err := r.findSnapshotItemFileIDs(ctx, itemFilter)
+	if err != nil {
+		return err
+	}
+	if _, err := r.itemCollection.DeleteMany(ctx, itemFilter); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting snapshot items")
+		return models.ErrDeletingSnapshot
+	}
+	for _, fileID := range fileIDs {
+		if err := r.blobRepo.DeleteBlob(ctx, fileID); err != nil && !errors.Is(err, models.ErrBlobNotFound) {
+			logrus.WithContext(ctx).WithError(err).Errorf("error deleting snapshot blob: %s", fileID.Hex())
+		}
+	}
+	return nil
+}
+
+// Finds the GridFS file IDs of the binary snapshot items matching a filter
+func (r *BucketSnapshotRepository) findSnapshotItemFileIDs(ctx context.Context, filter bson.D) ([]primitive.ObjectID, error) {
+	snapshotItems := []bucketSnapshotItem{}
+	filter = append(filter, primitive.E{Key: "item.file_id", Value: bson.D{primitive.E{Key: "$exists", Value: true}}})
+	opts := options.Find().SetProjection(bson.D{primitive.E{Key: "item.file_id", Value: 1}})
+	cursor, err := r.itemCollection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("failed to find snapshot item file ids")
+		return nil, models.ErrBucketItemsNotFound
+	}
+	if err = cursor.All(ctx, &snapshotItems); err != nil {
+		return nil, models.ErrBucketItemsNotFound
+	}
+	fileIDs := make([]primitive.ObjectID, 0, len(snapshotItems))
+	for _, snapshotItem := range snapshotItems {
+		fileIDs = append(fileIDs, snapshotItem.Item.FileID)
+	}
+	return fileIDs, nil
+}
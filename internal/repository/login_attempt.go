@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	loginAttemptCollectionName = "loginattempts"
+)
+
+type LoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLoginAttemptRepository(cfg *config.Config, dbClient *mongo.Client) ILoginAttemptRepository {
+	collection := dbClient.Database(cfg.DbName).Collection(loginAttemptCollectionName)
+	return &LoginAttemptRepository{
+		collection: collection,
+	}
+}
+
+// Finds the login attempts that have not expired among the given IDs
+func (r *LoginAttemptRepository) FindLoginAttempts(ctx context.Context, ids ...string) ([]models.LoginAttempt, error) {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$in", Value: ids}}},
+		primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$gt", Value: primitive.NewDateTimeFromTime(time.Now())}}},
+	}
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error finding login attempts")
+		return nil, models.ErrFindingLoginAttempts
+	}
+	attempts := []models.LoginAttempt{}
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, models.ErrFindingLoginAttempts
+	}
+	return attempts, nil
+}
+
+// Counts a failed login, the failures of an expired document are counted from zero
+// the document lives for at least the failure window after the failure
+func (r *LoginAttemptRepository) RecordLoginFailure(ctx context.Context, id string, window time.Duration) (*models.LoginAttempt, error) {
+	now := time.Now()
+	live := bson.D{primitive.E{Key: "$gt", Value: bson.A{"$expires_at", primitive.NewDateTimeFromTime(now)}}}
+	update := mongo.Pipeline{
+		bson.D{primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "failures", Value: bson.D{primitive.E{Key: "$cond", Value: bson.A{
+				live,
+				bson.D{primitive.E{Key: "$add", Value: bson.A{"$failures", 1}}},
+				1,
+			}}}},
+			primitive.E{Key: "locked_until", Value: bson.D{primitive.E{Key: "$cond", Value: bson.A{live, "$locked_until", "$$REMOVE"}}}},
+			primitive.E{Key: "last_failed_at", Value: primitive.NewDateTimeFromTime(now)},
+			primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$max", Value: bson.A{
+				"$expires_at",
+				primitive.NewDateTimeFromTime(now.Add(window)),
+			}}}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	attempt := &models.LoginAttempt{}
+	err := r.collection.FindOneAndUpdate(ctx, bson.D{primitive.E{Key: "_id", Value: id}}, update, opts).Decode(attempt)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error recording login failure: %s", id)
+		return nil, models.ErrRecordingLoginAttempt
+	}
+	return attempt, nil
+}
+
+// Locks the logins of an account or an IP address until the given time
+// the failures are kept for the failure window after the lockout, so that the next lockout is longer
+func (r *LoginAttemptRepository) LockLogin(ctx context.Context, id string, lockedUntil time.Time, window time.Duration) error {
+	update := bson.D{
+		primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "locked_until", Value: primitive.NewDateTimeFromTime(lockedUntil)},
+		}},
+		primitive.E{Key: "$max", Value: bson.D{
+			primitive.E{Key: "expires_at", Value: primitive.NewDateTimeFromTime(lockedUntil.Add(window))},
+		}},
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error locking login: %s", id)
+		return models.ErrRecordingLoginAttempt
+	}
+	return nil
+}
+
+// Deletes the login attempts with the given IDs, which unlocks them
+func (r *LoginAttemptRepository) DeleteLoginAttempts(ctx context.Context, ids ...string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.D{primitive.E{Key: "_id", Value: bson.D{primitive.E{Key: "$in", Value: ids}}}})
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting login attempts")
+		return models.ErrRecordingLoginAttempt
+	}
+	return nil
+}
@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"context"
+	"io"
 	"keeper/internal/models"
 	"keeper/internal/utils"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,48 +13,155 @@ import (
 )
 
 type IUserRepository interface {
-	CreateUser(user *models.User) error
-	FindUserByEmail(email string) (*models.User, error)
-	FindUserById(id string) (*models.User, error)
-	FindAllUsers() ([]models.User, error)
-	UpdateUser(user *models.User) error
-	DeleteUser(userId string) error
+	CreateUser(ctx context.Context, user *models.User) error
+	FindUserByEmail(ctx context.Context, email string) (*models.User, error)
+	FindUserById(ctx context.Context, id string) (*models.User, error)
+	FindAllUsers(ctx context.Context) ([]models.User, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	SetUserTwoFactorEnabled(ctx context.Context, userID primitive.ObjectID, enabled bool) error
+	DeleteUser(ctx context.Context, userId string) error
 }
 
 type IAPIKeyRepository interface {
-	CreateAPIKey(apiKey *models.APIKey) (primitive.ObjectID, error)
-	UpdateAPIKey(apiKey *models.APIKey) error
-	FindAPIKeyByID(apiKeyID string) (*models.APIKey, error)
-	FindAPIKeyByMaskID(maskID string) (*models.APIKey, error)
-	FindAPIKeyByHash(hash string) (*models.APIKey, error)
-	FindUserAPIKeys(userID string) ([]models.APIKey, error)
-	RevokeAPIKey(apiKeyID string) error
-	RevokeAPIKeys(apiKeyIDs []string) error
-	DeleteAPIKey(apiKeyID string) error
-	DeleteAPIKeys(apiKeyIDs []string) error
+	CreateAPIKey(ctx context.Context, apiKey *models.APIKey) (primitive.ObjectID, error)
+	UpdateAPIKey(ctx context.Context, apiKey *models.APIKey) error
+	FindAPIKeyByID(ctx context.Context, apiKeyID string) (*models.APIKey, error)
+	FindAPIKeyByMaskID(ctx context.Context, maskID string) (*models.APIKey, error)
+	FindAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	FindUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	FindAllAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, apiKeyID string) error
+	RevokeAPIKeys(ctx context.Context, apiKeyIDs []string) error
+	DeleteAPIKey(ctx context.Context, apiKeyID string) error
+	DeleteAPIKeys(ctx context.Context, apiKeyIDs []string) error
+	DeleteUserAPIKeys(ctx context.Context, userID string) (int64, error)
 }
 
 type IBucketRepository interface {
-	CreateBucket(bucket *models.Bucket) (primitive.ObjectID, error)
-	UpdateBucket(bucket *models.Bucket) error
-	DeleteBucketByID(id string) error
-	DeleteBucketByUID(uid string) error
-	FindBucketByID(id string) (*models.Bucket, error)
-	FindBucketByUID(uid string) (*models.Bucket, error)
-	FindBucketsByUserID(userID string) ([]models.Bucket, error)
-	FindBucketsByUserIDPaged(userID string, filter bson.M, findOpts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.Bucket, utils.PageInfo, error)
+	CreateBucket(ctx context.Context, bucket *models.Bucket) (primitive.ObjectID, error)
+	UpdateBucket(ctx context.Context, bucket *models.Bucket) error
+	DeleteBucketByID(ctx context.Context, id string) error
+	DeleteBucketByUID(ctx context.Context, uid string) error
+	FindBucketByID(ctx context.Context, id string) (*models.Bucket, error)
+	FindBucketByUID(ctx context.Context, uid string) (*models.Bucket, error)
+	FindBucketsByUserID(ctx context.Context, userID string) ([]models.Bucket, error)
+	FindBucketsByUserIDPaged(ctx context.Context, userID string, filter bson.M, findOpts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.Bucket, utils.PageInfo, error)
+	TrashBucketByUID(ctx context.Context, uid string, deletedAt primitive.DateTime) error
+	RestoreBucketByUID(ctx context.Context, uid string) error
+	FindTrashedBucketByUID(ctx context.Context, uid string) (*models.Bucket, error)
+	FindTrashedBucketsByUserID(ctx context.Context, userID string) ([]models.Bucket, error)
+	FindTrashedBucketsBefore(ctx context.Context, before primitive.DateTime) ([]models.Bucket, error)
+	FindAllBucketUIDsByUserID(ctx context.Context, userID string) ([]string, error)
+	SetBucketRequireTwoFactor(ctx context.Context, uid string, required bool) error
 }
 
 type IBucketItemRepository interface {
-	FindBucketItemsPaged(filter bson.M, opts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.BucketItem, utils.PageInfo, error)
-	FindBucketItems(bucketUID string) ([]models.BucketItem, error)
-	CreateBucketItem(bucketItem *models.BucketItem) (primitive.ObjectID, error)
-	UpdateBucketItem(bucketItem *models.BucketItem, key string) error
-	IncrementIntItem(bucketUID string, key string, amount int) error
-	FindBucketItemByID(id string) (*models.BucketItem, error)
-	FindBucketItemByKeyName(bucketUID string, key string) (*models.BucketItem, error)
-	DeleteBucketItemByKeyName(bucketUID string, key string) error
-	DeleteBucketItemById(id string) error
-	DeleteBucketItemsById(ids []string) error
-	DeleteBucketItems(bucketUID string) error
+	FindBucketItemsPaged(ctx context.Context, filter bson.M, opts *options.FindOptions, paginationParams utils.PaginationParams) ([]models.BucketItem, utils.PageInfo, error)
+	FindBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error)
+	CreateBucketItem(ctx context.Context, bucketItem *models.BucketItem) (primitive.ObjectID, error)
+	UpdateBucketItem(ctx context.Context, bucketItem *models.BucketItem, key string) error
+	IncrementIntItem(ctx context.Context, bucketUID string, key string, amount int) error
+	FindBucketItemByID(ctx context.Context, id string) (*models.BucketItem, error)
+	FindBucketItemByKeyName(ctx context.Context, bucketUID string, key string) (*models.BucketItem, error)
+	DeleteBucketItemByKeyName(ctx context.Context, bucketUID string, key string) error
+	DeleteBucketItemById(ctx context.Context, id string) error
+	DeleteBucketItemsById(ctx context.Context, ids []string) error
+	DeleteBucketItems(ctx context.Context, bucketUID string) error
+	GetCompressionStats(ctx context.Context, bucketUID string) (*models.BucketItemCompressionStats, error)
+	CompressBucketItems(ctx context.Context, bucketUID string) (int64, error)
+	TrashBucketItemByKeyName(ctx context.Context, bucketUID string, key string, deletedAt primitive.DateTime) error
+	TrashBucketItems(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) error
+	RestoreBucketItemByID(ctx context.Context, id string) error
+	RestoreBucketItems(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) error
+	FindTrashedBucketItemByID(ctx context.Context, id string) (*models.BucketItem, error)
+	FindTrashedBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error)
+	PurgeTrashedBucketItems(ctx context.Context, before primitive.DateTime) (int64, error)
+	AnonymizeUserBucketItems(ctx context.Context, userID string) (int64, error)
+	MarkExpiredBucketItems(ctx context.Context, now primitive.DateTime, limit int64) ([]models.BucketItem, error)
+	PurgeExpiredBucketItems(ctx context.Context, before primitive.DateTime) ([]models.BucketItem, error)
+}
+
+type IBucketItemBlobRepository interface {
+	UploadBlob(ctx context.Context, filename string, source io.Reader, metadata bson.M) (primitive.ObjectID, error)
+	OpenBlob(ctx context.Context, fileID primitive.ObjectID) (io.ReadCloser, error)
+	DeleteBlob(ctx context.Context, fileID primitive.ObjectID) error
+	CopyBlob(ctx context.Context, fileID primitive.ObjectID) (primitive.ObjectID, error)
+}
+
+type IBucketSnapshotRepository interface {
+	CreateSnapshot(ctx context.Context, snapshot *models.BucketSnapshot, items []models.BucketItem) (primitive.ObjectID, error)
+	FindSnapshotByName(ctx context.Context, bucketUID string, name string) (*models.BucketSnapshot, error)
+	FindSnapshotsByBucketUID(ctx context.Context, bucketUID string) ([]models.BucketSnapshot, error)
+	FindSnapshotItems(ctx context.Context, snapshotID primitive.ObjectID) ([]models.BucketItem, error)
+	DeleteSnapshot(ctx context.Context, snapshotID primitive.ObjectID) error
+	DeleteBucketSnapshots(ctx context.Context, bucketUID string) error
+}
+
+type IWebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) (primitive.ObjectID, error)
+	FindWebhookByID(ctx context.Context, id string) (*models.Webhook, error)
+	FindWebhooksByBucketUID(ctx context.Context, bucketUID string) ([]models.Webhook, error)
+	FindWebhooksByEvent(ctx context.Context, bucketUID string, event string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	DeleteBucketWebhooks(ctx context.Context, bucketUID string) error
+}
+
+type IWebhookDeliveryRepository interface {
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (primitive.ObjectID, error)
+	FindDeliveryByID(ctx context.Context, id string) (*models.WebhookDelivery, error)
+	FindDeliveriesByWebhookID(ctx context.Context, webhookID string, limit int64) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	DeleteWebhookDeliveries(ctx context.Context, webhookID string) error
+}
+
+type IBucketChangeRepository interface {
+	RecordChanges(ctx context.Context, bucketUID string, op string, keys ...string) error
+	FindChanges(ctx context.Context, bucketUID string, since int64, limit int64) ([]models.BucketChange, error)
+	LatestChangeSeq(ctx context.Context, bucketUID string) (int64, error)
+	DeleteBucketChanges(ctx context.Context, bucketUID string) error
+}
+
+type IAuditEventRepository interface {
+	CreateAuditEvent(ctx context.Context, event *models.AuditEvent) (primitive.ObjectID, error)
+	FindAuditEventsPaged(ctx context.Context, filter bson.M, paginationParams utils.PaginationParams) ([]models.AuditEvent, utils.PageInfo, error)
+	IterateAuditEvents(ctx context.Context, filter bson.M, fn func(event *models.AuditEvent) error) error
+}
+
+type IUsageRepository interface {
+	IncrementUsage(ctx context.Context, userID primitive.ObjectID, bucketUID string, delta models.UsageDelta, quota models.Quota) error
+	FindUserUsage(ctx context.Context, userID primitive.ObjectID) (*models.Usage, error)
+	FindBucketUsage(ctx context.Context, bucketUID string) (*models.Usage, error)
+	FindBucketUsages(ctx context.Context, userID primitive.ObjectID) ([]models.Usage, error)
+	DeleteBucketUsage(ctx context.Context, bucketUID string) error
+	DeleteUserUsage(ctx context.Context, userID primitive.ObjectID) error
+	ComputeBucketUsage(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) (models.UsageDelta, error)
+	UpdateUserQuota(ctx context.Context, userID primitive.ObjectID, quota *models.Quota) error
+	RecomputeUsage(ctx context.Context) error
+}
+
+type ILoginAttemptRepository interface {
+	FindLoginAttempts(ctx context.Context, ids ...string) ([]models.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, id string, window time.Duration) (*models.LoginAttempt, error)
+	LockLogin(ctx context.Context, id string, lockedUntil time.Time, window time.Duration) error
+	DeleteLoginAttempts(ctx context.Context, ids ...string) error
+}
+
+type ISessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) (primitive.ObjectID, error)
+	FindSessionByID(ctx context.Context, id string) (*models.Session, error)
+	FindActiveSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error)
+	RotateSession(ctx context.Context, session *models.Session, previousHash string) error
+	RevokeSession(ctx context.Context, id primitive.ObjectID, reason string) error
+	RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, reason string) (int64, error)
+	DeleteUserSessions(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+type ITwoFactorRepository interface {
+	FindTwoFactor(ctx context.Context, userID primitive.ObjectID) (*models.TwoFactor, error)
+	SavePendingSecret(ctx context.Context, userID primitive.ObjectID, secret string) error
+	EnableTwoFactor(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error
+	UseTwoFactorStep(ctx context.Context, userID primitive.ObjectID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID primitive.ObjectID, recoveryCodes []string) error
+	DeleteTwoFactor(ctx context.Context, userID primitive.ObjectID) error
 }
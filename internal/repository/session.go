@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	sessionCollectionName = "sessions"
+)
+
+type SessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSessionRepository(cfg *config.Config, dbClient *mongo.Client) ISessionRepository {
+	sessionCollection := dbClient.Database(cfg.DbName).Collection(sessionCollectionName)
+	return &SessionRepository{
+		collection: sessionCollection,
+	}
+}
+
+// filter of the sessions that are not revoked
+var notRevokedFilter = primitive.E{Key: "revoked_at", Value: bson.D{primitive.E{Key: "$exists", Value: false}}}
+
+// Save a new session
+func (r *SessionRepository) CreateSession(ctx context.Context, session *models.Session) (primitive.ObjectID, error) {
+	result, err := r.collection.InsertOne(ctx, session)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating session")
+		return primitive.ObjectID{}, models.ErrCreatingSession
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// Find a session by its ID
+func (r *SessionRepository) FindSessionByID(ctx context.Context, id string) (*models.Session, error) {
+	session := &models.Session{}
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, models.ErrSessionNotFound
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
+	if err := r.collection.FindOne(ctx, filter).Decode(session); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, models.ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+// Returns the sessions of a user that are neither revoked nor expired, most recently used first
+func (r *SessionRepository) FindActiveSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	filter := bson.D{
+		primitive.E{Key: "user_id", Value: userID},
+		notRevokedFilter,
+		primitive.E{Key: "expires_at", Value: bson.D{primitive.E{Key: "$gt", Value: primitive.NewDateTimeFromTime(time.Now())}}},
+	}
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "last_used_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error finding sessions")
+		return nil, models.ErrFindingSessions
+	}
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, models.ErrFindingSessions
+	}
+	return sessions, nil
+}
+
+// Replaces the refresh token of a session, only if its current token is the one with the previous hash
+// returns ErrRefreshTokenReused if the token was replaced in the meantime, or ErrSessionRevoked if the session was revoked
+func (r *SessionRepository) RotateSession(ctx context.Context, session *models.Session, previousHash string) error {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: session.ID},
+		primitive.E{Key: "token_hash", Value: previousHash},
+		notRevokedFilter,
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "token_hash", Value: session.TokenHash},
+		primitive.E{Key: "ip", Value: session.IP},
+		primitive.E{Key: "user_agent", Value: session.UserAgent},
+		primitive.E{Key: "last_used_at", Value: session.LastUsedAt},
+		primitive.E{Key: "expires_at", Value: session.ExpiresAt},
+	}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error rotating session: %s", session.ID.Hex())
+		return models.ErrUpdatingSession
+	}
+	if result.MatchedCount == 0 {
+		current, err := r.FindSessionByID(ctx, session.ID.Hex())
+		if err != nil {
+			return err
+		}
+		if current.RevokedAt != 0 {
+			return models.ErrSessionRevoked
+		}
+		return models.ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// Revokes a session, the revoked sessions are kept until they expire
+func (r *SessionRepository) RevokeSession(ctx context.Context, id primitive.ObjectID, reason string) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: id}, notRevokedFilter}
+	_, err := r.collection.UpdateOne(ctx, filter, revokeSessionUpdate(reason))
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error revoking session: %s", id.Hex())
+		return models.ErrUpdatingSession
+	}
+	return nil
+}
+
+// Revokes all the sessions of a user, returns the number of revoked sessions
+func (r *SessionRepository) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID, reason string) (int64, error) {
+	filter := bson.D{primitive.E{Key: "user_id", Value: userID}, notRevokedFilter}
+	result, err := r.collection.UpdateMany(ctx, filter, revokeSessionUpdate(reason))
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error revoking sessions of user: %s", userID.Hex())
+		return 0, models.ErrUpdatingSession
+	}
+	return result.ModifiedCount, nil
+}
+
+// Deletes all the sessions of a user, returns the number of deleted sessions
+func (r *SessionRepository) DeleteUserSessions(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	filter := bson.D{primitive.E{Key: "user_id", Value: userID}}
+	result, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting sessions of user: %s", userID.Hex())
+		return 0, models.ErrDeletingSessions
+	}
+	return result.DeletedCount, nil
+}
+
+func revokeSessionUpdate(reason string) bson.D {
+	return bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "revoked_at", Value: primitive.NewDateTimeFromTime(time.Now())},
+		primitive.E{Key: "revoked_reason", Value: reason},
+	}}}
+}
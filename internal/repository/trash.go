@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Filters for documents that are in or out of the trash
+// buckets and bucket items are moved to the trash by setting their 'deleted_at' field
+var (
+	notTrashedFilter = primitive.E{Key: "deleted_at", Value: bson.D{primitive.E{Key: "$exists", Value: false}}}
+	trashedFilter    = primitive.E{Key: "deleted_at", Value: bson.D{primitive.E{Key: "$exists", Value: true}}}
+)
+
+// Returns the filter for trashed documents that were deleted before a cutoff time
+func trashedBeforeFilter(before primitive.DateTime) primitive.E {
+	return primitive.E{Key: "deleted_at", Value: bson.D{primitive.E{Key: "$lt", Value: before}}}
+}
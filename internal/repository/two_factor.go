@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	twoFactorCollectionName = "twofactors"
+)
+
+type TwoFactorRepository struct {
+	collection *mongo.Collection
+}
+
+func NewTwoFactorRepository(cfg *config.Config, dbClient *mongo.Client) ITwoFactorRepository {
+	twoFactorCollection := dbClient.Database(cfg.DbName).Collection(twoFactorCollectionName)
+	return &TwoFactorRepository{
+		collection: twoFactorCollection,
+	}
+}
+
+// Find the two-factor authentication of a user
+func (r *TwoFactorRepository) FindTwoFactor(ctx context.Context, userID primitive.ObjectID) (*models.TwoFactor, error) {
+	twoFactor := &models.TwoFactor{}
+	filter := bson.D{primitive.E{Key: "_id", Value: userID}}
+	if err := r.collection.FindOne(ctx, filter).Decode(twoFactor); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, models.ErrTwoFactorNotEnabled
+		}
+		logrus.WithContext(ctx).WithError(err).Error("error finding two-factor authentication")
+		return nil, models.ErrFindingTwoFactor
+	}
+	return twoFactor, nil
+}
+
+// Saves the secret of a new enrollment, replacing the one of a previous enrollment that was not confirmed
+// the secret of an enabled two-factor authentication is left untouched
+func (r *TwoFactorRepository) SavePendingSecret(ctx context.Context, userID primitive.ObjectID, secret string) error {
+	now := primitive.NewDateTimeFromTime(time.Now())
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: userID},
+		primitive.E{Key: "secret", Value: bson.D{primitive.E{Key: "$exists", Value: false}}},
+	}
+	update := bson.D{
+		primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "pending_secret", Value: secret},
+			primitive.E{Key: "updated_at", Value: now},
+		}},
+		primitive.E{Key: "$setOnInsert", Value: bson.D{
+			primitive.E{Key: "last_used_step", Value: 0},
+			primitive.E{Key: "created_at", Value: now},
+		}},
+	}
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	// the upsert conflicts with the document of an enabled two-factor authentication
+	if mongo.IsDuplicateKeyError(err) {
+		return models.ErrTwoFactorAlreadyEnabled
+	}
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error saving two-factor secret")
+		return models.ErrUpdatingTwoFactor
+	}
+	return nil
+}
+
+// Confirms an enrollment, the pending secret becomes the secret of the user
+// fails with ErrTwoFactorNotEnrolled if the pending secret was replaced in the meantime
+func (r *TwoFactorRepository) EnableTwoFactor(ctx context.Context, userID primitive.ObjectID, secret string, recoveryCodes []string, step int64) error {
+	now := primitive.NewDateTimeFromTime(time.Now())
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: userID},
+		primitive.E{Key: "pending_secret", Value: secret},
+	}
+	update := bson.D{
+		primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "secret", Value: secret},
+			primitive.E{Key: "recovery_codes", Value: recoveryCodes},
+			primitive.E{Key: "last_used_step", Value: step},
+			primitive.E{Key: "enabled_at", Value: now},
+			primitive.E{Key: "updated_at", Value: now},
+		}},
+		primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "pending_secret", Value: ""}}},
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error enabling two-factor authentication")
+		return models.ErrUpdatingTwoFactor
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrTwoFactorNotEnrolled
+	}
+	return nil
+}
+
+// Records the time step of an accepted code, only if it is past the last one, so that a code cannot be replayed
+func (r *TwoFactorRepository) UseTwoFactorStep(ctx context.Context, userID primitive.ObjectID, step int64) error {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: userID},
+		primitive.E{Key: "last_used_step", Value: bson.D{primitive.E{Key: "$lt", Value: step}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "last_used_step", Value: step}}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error recording two-factor code")
+		return models.ErrUpdatingTwoFactor
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Removes a recovery code by its hash, fails with ErrInvalidTwoFactorCode if it is unknown or was already used
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userID primitive.ObjectID, codeHash string) error {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: userID},
+		primitive.E{Key: "recovery_codes", Value: codeHash},
+	}
+	update := bson.D{primitive.E{Key: "$pull", Value: bson.D{primitive.E{Key: "recovery_codes", Value: codeHash}}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error using recovery code")
+		return models.ErrUpdatingTwoFactor
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Replaces the recovery codes of an enabled two-factor authentication
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID primitive.ObjectID, recoveryCodes []string) error {
+	filter := bson.D{
+		primitive.E{Key: "_id", Value: userID},
+		primitive.E{Key: "secret", Value: bson.D{primitive.E{Key: "$exists", Value: true}}},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "recovery_codes", Value: recoveryCodes},
+		primitive.E{Key: "updated_at", Value: primitive.NewDateTimeFromTime(time.Now())},
+	}}}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error replacing recovery codes")
+		return models.ErrUpdatingTwoFactor
+	}
+	if result.MatchedCount == 0 {
+		return models.ErrTwoFactorNotEnabled
+	}
+	return nil
+}
+
+// Deletes the two-factor authentication of a user, with its secret and recovery codes
+func (r *TwoFactorRepository) DeleteTwoFactor(ctx context.Context, userID primitive.ObjectID) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: userID}}
+	if _, err := r.collection.DeleteOne(ctx, filter); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting two-factor authentication")
+		return models.ErrUpdatingTwoFactor
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	usageCollectionName = "usages"
+)
+
+type UsageRepository struct {
+	collection           *mongo.Collection
+	bucketCollection     *mongo.Collection
+	bucketItemCollection *mongo.Collection
+}
+
+func NewUsageRepository(cfg *config.Config, dbClient *mongo.Client) IUsageRepository {
+	db := dbClient.Database(cfg.DbName)
+	return &UsageRepository{
+		collection:           db.Collection(usageCollectionName),
+		bucketCollection:     db.Collection(bucketCollectionName),
+		bucketItemCollection: db.Collection(bucketItemCollectionName),
+	}
+}
+
+func userUsageID(userID primitive.ObjectID) string {
+	return "user:" + userID.Hex()
+}
+
+func bucketUsageID(bucketUID string) string {
+	return "bucket:" + bucketUID
+}
+
+// Adds a delta to the usage of a user and, if the bucket UID is set, to the usage of the bucket
+// the increases are applied only if they fit in the limits of the quota, a limit of 0 is unlimited,
+// so that concurrent writes cannot exceed it together, the usage is left unchanged when a limit is exceeded
+func (r *UsageRepository) IncrementUsage(ctx context.Context, userID primitive.ObjectID, bucketUID string, delta models.UsageDelta, quota models.Quota) error {
+	now := primitive.NewDateTimeFromTime(time.Now())
+	userFilter := bson.D{primitive.E{Key: "_id", Value: userUsageID(userID)}}
+	userFilter, bucketsOk := withinLimit(userFilter, "buckets", delta.Buckets, quota.MaxBuckets)
+	userFilter, bytesOk := withinLimit(userFilter, "bytes", delta.Bytes, quota.MaxStorageBytes)
+	bucketFilter := bson.D{primitive.E{Key: "_id", Value: bucketUsageID(bucketUID)}}
+	bucketFilter, itemsOk := withinLimit(bucketFilter, "items", delta.Items, quota.MaxItemsPerBucket)
+	switch {
+	case !bucketsOk:
+		return models.ErrBucketQuotaExceeded
+	case !bytesOk:
+		return models.ErrStorageQuotaExceeded
+	case !itemsOk && bucketUID != "":
+		return models.ErrItemQuotaExceeded
+	}
+
+	if bucketUID != "" {
+		err := r.incrementWithinLimits(ctx, bucketFilter, bucketUsageUpdate(userID, bucketUID, delta, now))
+		if errors.Is(err, errUsageLimitExceeded) {
+			return models.ErrItemQuotaExceeded
+		}
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("error updating usage of bucket: %s", bucketUID)
+			return models.ErrUpdatingUsage
+		}
+	}
+	err := r.incrementWithinLimits(ctx, userFilter, bson.D{
+		primitive.E{Key: "$inc", Value: bson.D{
+			primitive.E{Key: "buckets", Value: delta.Buckets},
+			primitive.E{Key: "items", Value: delta.Items},
+			primitive.E{Key: "bytes", Value: delta.Bytes},
+		}},
+		primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "user_id", Value: userID},
+			primitive.E{Key: "updated_at", Value: now},
+		}},
+	})
+	if err == nil {
+		return nil
+	}
+	// the usage of the bucket is given back when the one of the user is refused
+	if bucketUID != "" {
+		undo := bucketUsageUpdate(userID, bucketUID, delta.Negate(), now)
+		if _, undoErr := r.collection.UpdateOne(ctx, bson.D{primitive.E{Key: "_id", Value: bucketUsageID(bucketUID)}}, undo); undoErr != nil {
+			logrus.WithContext(ctx).WithError(undoErr).Errorf("error undoing usage of bucket: %s", bucketUID)
+		}
+	}
+	if errors.Is(err, errUsageLimitExceeded) {
+		return r.exceededUserLimit(ctx, userID, delta, quota)
+	}
+	logrus.WithContext(ctx).WithError(err).Error("error updating usage")
+	return models.ErrUpdatingUsage
+}
+
+// Returns the update adding a delta to the usage of a bucket
+func bucketUsageUpdate(userID primitive.ObjectID, bucketUID string, delta models.UsageDelta, now primitive.DateTime) bson.D {
+	return bson.D{
+		primitive.E{Key: "$inc", Value: bson.D{
+			primitive.E{Key: "items", Value: delta.Items},
+			primitive.E{Key: "bytes", Value: delta.Bytes},
+		}},
+		primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "user_id", Value: userID},
+			primitive.E{Key: "bucket_uid", Value: bucketUID},
+			primitive.E{Key: "updated_at", Value: now},
+		}},
+	}
+}
+
+// the conditional increment of a usage did not match, a limit of the quota is exceeded
+var errUsageLimitExceeded = errors.New("usage limit exceeded")
+
+// Adds the condition that a counter increased by delta stays within limit to a usage filter
+// Returns false when the delta alone exceeds the limit
+func withinLimit(filter bson.D, field string, delta int64, limit int64) (bson.D, bool) {
+	if limit <= 0 || delta <= 0 {
+		return filter, true
+	}
+	if delta > limit {
+		return filter, false
+	}
+	return append(filter, primitive.E{Key: field, Value: bson.D{primitive.E{Key: "$lte", Value: limit - delta}}}), true
+}
+
+// Applies the increment of a usage if it matches the conditions of the filter, a missing usage is created
+// a usage that exists without matching the conditions is refused by the unique _id of the upsert
+func (r *UsageRepository) incrementWithinLimits(ctx context.Context, filter bson.D, update bson.D) error {
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	// the usage may also have been created concurrently, the increment is tried again on it
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errUsageLimitExceeded
+	}
+	return nil
+}
+
+// Returns the error of the limit of a user that a refused increment exceeds
+func (r *UsageRepository) exceededUserLimit(ctx context.Context, userID primitive.ObjectID, delta models.UsageDelta, quota models.Quota) error {
+	usage, err := r.FindUserUsage(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if quota.MaxBuckets > 0 && delta.Buckets > 0 && usage.Buckets+delta.Buckets > quota.MaxBuckets {
+		return models.ErrBucketQuotaExceeded
+	}
+	return models.ErrStorageQuotaExceeded
+}
+
+// Finds the usage of a user, a user without any usage gets an empty one
+func (r *UsageRepository) FindUserUsage(ctx context.Context, userID primitive.ObjectID) (*models.Usage, error) {
+	return r.findUsage(ctx, userUsageID(userID), &models.Usage{UserID: userID})
+}
+
+// Finds the usage of a bucket, a bucket without any usage gets an empty one
+func (r *UsageRepository) FindBucketUsage(ctx context.Context, bucketUID string) (*models.Usage, error) {
+	return r.findUsage(ctx, bucketUsageID(bucketUID), &models.Usage{BucketUID: bucketUID})
+}
+
+func (r *UsageRepository) findUsage(ctx context.Context, id string, empty *models.Usage) (*models.Usage, error) {
+	usage := &models.Usage{}
+	err := r.collection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(usage)
+	if err == mongo.ErrNoDocuments {
+		return empty, nil
+	}
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error finding usage: %s", id)
+		return nil, models.ErrFindingUsage
+	}
+	return usage, nil
+}
+
+// Finds the usage of the buckets of a user
+func (r *UsageRepository) FindBucketUsages(ctx context.Context, userID primitive.ObjectID) ([]models.Usage, error) {
+	filter := bson.D{
+		primitive.E{Key: "user_id", Value: userID},
+		primitive.E{Key: "bucket_uid", Value: bson.D{primitive.E{Key: "$exists", Value: true}}},
+	}
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "bytes", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error finding bucket usages")
+		return nil, models.ErrFindingUsage
+	}
+	usages := []models.Usage{}
+	if err := cursor.All(ctx, &usages); err != nil {
+		return nil, models.ErrFindingUsage
+	}
+	return usages, nil
+}
+
+// Deletes the usage of a bucket
+func (r *UsageRepository) DeleteBucketUsage(ctx context.Context, bucketUID string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.D{primitive.E{Key: "_id", Value: bucketUsageID(bucketUID)}})
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting usage of bucket: %s", bucketUID)
+		return models.ErrUpdatingUsage
+	}
+	return nil
+}
+
+// Deletes the usage and the quota override of a user, along with the usage of its buckets
+func (r *UsageRepository) DeleteUserUsage(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.D{primitive.E{Key: "user_id", Value: userID}})
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting usage of user: %s", userID.Hex())
+		return models.ErrUpdatingUsage
+	}
+	return nil
+}
+
+// Sets the quota override of a user, a nil quota removes it
+func (r *UsageRepository) UpdateUserQuota(ctx context.Context, userID primitive.ObjectID, quota *models.Quota) error {
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "user_id", Value: userID},
+		primitive.E{Key: "quota", Value: quota},
+	}}}
+	if quota == nil {
+		update = bson.D{
+			primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "user_id", Value: userID}}},
+			primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "quota", Value: ""}}},
+		}
+	}
+	opts := options.Update().SetUpsert(true)
+	_, err := r.collection.UpdateOne(ctx, bson.D{primitive.E{Key: "_id", Value: userUsageID(userID)}}, update, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error updating user quota")
+		return models.ErrUpdatingUsage
+	}
+	return nil
+}
+
+// number of bytes of a bucket item counted in the usage, see models.BucketItem.UsageBytes
+var bucketItemUsageBytes = bson.D{primitive.E{Key: "$cond", Value: bson.A{
+	bson.D{primitive.E{Key: "$eq", Value: bson.A{"$type", models.BucketItemTypeBinary}}},
+	bson.D{primitive.E{Key: "$ifNull", Value: bson.A{"$size", 0}}},
+	bson.D{primitive.E{Key: "$ifNull", Value: bson.A{
+		"$raw_size",
+		bson.D{primitive.E{Key: "$bsonSize", Value: bson.D{primitive.E{Key: "v", Value: "$data"}}}},
+	}}},
+}}}
+
+// Recomputes the usage of all the users and buckets from the stored buckets and bucket items,
+// it corrects the drift of the counters left by the writes made outside the services, e.g. the purges
+// the usages of the deleted users and buckets are removed, the quota overrides are kept
+func (r *UsageRepository) RecomputeUsage(ctx context.Context) error {
+	start := primitive.NewDateTimeFromTime(time.Now())
+	itemUsages, err := r.aggregateBucketItemUsages(ctx, bson.D{notTrashedFilter})
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error aggregating bucket item usages")
+		return models.ErrComputingUsage
+	}
+	cursor, err := r.bucketCollection.Find(ctx, bson.D{notTrashedFilter},
+		options.Find().SetProjection(bson.D{primitive.E{Key: "uid", Value: 1}, primitive.E{Key: "user_id", Value: 1}}))
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error finding buckets")
+		return models.ErrComputingUsage
+	}
+	defer cursor.Close(ctx)
+
+	users := map[primitive.ObjectID]*models.Usage{}
+	writes := []mongo.WriteModel{}
+	for cursor.Next(ctx) {
+		bucket := models.Bucket{}
+		if err := cursor.Decode(&bucket); err != nil {
+			return models.ErrComputingUsage
+		}
+		usage := itemUsages[bucket.UID]
+		writes = append(writes, setUsageModel(bucketUsageID(bucket.UID), bson.D{
+			primitive.E{Key: "user_id", Value: bucket.UserID},
+			primitive.E{Key: "bucket_uid", Value: bucket.UID},
+			primitive.E{Key: "items", Value: usage.Items},
+			primitive.E{Key: "bytes", Value: usage.Bytes},
+			primitive.E{Key: "updated_at", Value: start},
+		}))
+		user, ok := users[bucket.UserID]
+		if !ok {
+			user = &models.Usage{UserID: bucket.UserID}
+			users[bucket.UserID] = user
+		}
+		user.Buckets++
+		user.Items += usage.Items
+		user.Bytes += usage.Bytes
+	}
+	if err := cursor.Err(); err != nil {
+		return models.ErrComputingUsage
+	}
+	for userID, usage := range users {
+		writes = append(writes, setUsageModel(userUsageID(userID), bson.D{
+			primitive.E{Key: "user_id", Value: userID},
+			primitive.E{Key: "buckets", Value: usage.Buckets},
+			primitive.E{Key: "items", Value: usage.Items},
+			primitive.E{Key: "bytes", Value: usage.Bytes},
+			primitive.E{Key: "updated_at", Value: start},
+		}))
+	}
+	if len(writes) > 0 {
+		if _, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("error writing recomputed usages")
+			return models.ErrComputingUsage
+		}
+	}
+	// the users without buckets are reset rather than removed, they may hold a quota override
+	_, err = r.collection.UpdateMany(ctx,
+		bson.D{
+			primitive.E{Key: "updated_at", Value: bson.D{primitive.E{Key: "$lt", Value: start}}},
+			primitive.E{Key: "bucket_uid", Value: bson.D{primitive.E{Key: "$exists", Value: false}}},
+		},
+		bson.D{primitive.E{Key: "$set", Value: bson.D{
+			primitive.E{Key: "buckets", Value: 0},
+			primitive.E{Key: "items", Value: 0},
+			primitive.E{Key: "bytes", Value: 0},
+			primitive.E{Key: "updated_at", Value: start},
+		}}},
+	)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error resetting user usages")
+		return models.ErrComputingUsage
+	}
+	_, err = r.collection.DeleteMany(ctx, bson.D{
+		primitive.E{Key: "updated_at", Value: bson.D{primitive.E{Key: "$lt", Value: start}}},
+		primitive.E{Key: "bucket_uid", Value: bson.D{primitive.E{Key: "$exists", Value: true}}},
+	})
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting stale bucket usages")
+		return models.ErrComputingUsage
+	}
+	return nil
+}
+
+// Computes the usage of the items of a bucket, the items trashed at deletedAt or, if it is zero, the items that are not trashed
+func (r *UsageRepository) ComputeBucketUsage(ctx context.Context, bucketUID string, deletedAt primitive.DateTime) (models.UsageDelta, error) {
+	trashFilter := notTrashedFilter
+	if deletedAt != 0 {
+		trashFilter = primitive.E{Key: "deleted_at", Value: deletedAt}
+	}
+	usages, err := r.aggregateBucketItemUsages(ctx, bson.D{
+		primitive.E{Key: "bucket_uid", Value: bucketUID},
+		trashFilter,
+	})
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error computing usage of bucket: %s", bucketUID)
+		return models.UsageDelta{}, models.ErrComputingUsage
+	}
+	usage := usages[bucketUID]
+	return models.UsageDelta{Items: usage.Items, Bytes: usage.Bytes}, nil
+}
+
+// Returns the number of items and bytes of the bucket items matching a filter, by bucket UID
+func (r *UsageRepository) aggregateBucketItemUsages(ctx context.Context, filter bson.D) (map[string]models.Usage, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{primitive.E{Key: "$match", Value: filter}},
+		bson.D{primitive.E{Key: "$group", Value: bson.D{
+			primitive.E{Key: "_id", Value: "$bucket_uid"},
+			primitive.E{Key: "items", Value: bson.D{primitive.E{Key: "$sum", Value: 1}}},
+			primitive.E{Key: "bytes", Value: bson.D{primitive.E{Key: "$sum", Value: bucketItemUsageBytes}}},
+		}}},
+	}
+	cursor, err := r.bucketItemCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	results := []models.Usage{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	usages := make(map[string]models.Usage, len(results))
+	for _, usage := range results {
+		usages[usage.ID] = usage
+	}
+	return usages, nil
+}
+
+// Returns the write setting the fields of a usage, the usage is created if needed
+func setUsageModel(id string, fields bson.D) mongo.WriteModel {
+	return mongo.NewUpdateOneModel().
+		SetFilter(bson.D{primitive.E{Key: "_id", Value: id}}).
+		SetUpdate(bson.D{primitive.E{Key: "$set", Value: fields}}).
+		SetUpsert(true)
+}
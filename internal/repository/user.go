@@ -27,51 +27,53 @@ var userDetailsProjection = bson.D{
 	primitive.E{Key: "username", Value: 1},
 	primitive.E{Key: "password", Value: 1},
 	primitive.E{Key: "email_verified", Value: 1},
+	primitive.E{Key: "two_factor_enabled", Value: 1},
 	primitive.E{Key: "created_at", Value: 1},
 	primitive.E{Key: "updated_at", Value: 1},
 }
 
 type UserRepository struct {
 	collection *mongo.Collection
-	ctx        context.Context
 }
 
 func NewUserRepository(cfg *config.Config, dbClient *mongo.Client) IUserRepository {
 	userCollection := dbClient.Database(cfg.DbName).Collection(userCollectionName)
 	return &UserRepository{
 		collection: userCollection,
-		ctx:        context.TODO(),
 	}
 }
 
 // Create a new user
-func (r *UserRepository) CreateUser(user *models.User) error {
-	_, err := r.collection.InsertOne(r.ctx, user)
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	_, err := r.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.NewConflictError(models.ErrUserAlreadyExists, "email", user.Email)
+	}
 	if err != nil {
-		logrus.WithError(err).Error("error creating user")
+		logrus.WithContext(ctx).WithError(err).Error("error creating user")
 		return fmt.Errorf("error creating user: %s", err.Error())
 	}
 	return nil
 }
 
 // Find a user by email
-func (r *UserRepository) FindUserByEmail(email string) (*models.User, error) {
+func (r *UserRepository) FindUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	user := &models.User{}
 
 	filter := bson.D{primitive.E{Key: "email", Value: email}}
 	opts := options.FindOne().SetProjection(userDetailsProjection)
-	if err := r.collection.FindOne(r.ctx, filter, opts).Decode(user); err != nil {
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(user); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, models.ErrUserNotFound
 		}
 		return nil, err
 	}
-	logrus.Info("found user: ", user)
+	logrus.WithContext(ctx).WithField("user_id", user.ID.Hex()).Debug("found user")
 	return user, nil
 }
 
 // Find a user by id
-func (r *UserRepository) FindUserById(id string) (*models.User, error) {
+func (r *UserRepository) FindUserById(ctx context.Context, id string) (*models.User, error) {
 	user := &models.User{}
 	ID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -79,28 +81,28 @@ func (r *UserRepository) FindUserById(id string) (*models.User, error) {
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
 	opts := options.FindOne().SetProjection(userDetailsProjection)
-	if err := r.collection.FindOne(r.ctx, filter, opts).Decode(user); err != nil {
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(user); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, models.ErrUserNotFound
 		}
 		return nil, err
 	}
-	logrus.Info("found user: ", user)
+	logrus.WithContext(ctx).WithField("user_id", user.ID.Hex()).Debug("found user")
 	return user, nil
 }
 
 // Returns all the users
-func (r *UserRepository) FindAllUsers() ([]models.User, error) {
+func (r *UserRepository) FindAllUsers(ctx context.Context) ([]models.User, error) {
 	users := []models.User{}
 	filter := bson.D{}
 
 	opts := options.Find().SetProjection(userDetailsProjection)
-	cursor, err := r.collection.Find(r.ctx, filter, opts)
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
-		logrus.WithError(err).Errorf("cannot find many")
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find many")
 		return nil, models.ErrUsersNotFound
 	}
-	if err = cursor.All(r.ctx, &users); err != nil {
+	if err = cursor.All(ctx, &users); err != nil {
 		return nil, models.ErrUsersNotFound
 	}
 	// logrus.Debug("users: ", users)
@@ -108,7 +110,7 @@ func (r *UserRepository) FindAllUsers() ([]models.User, error) {
 }
 
 // Update a user's account details
-func (r *UserRepository) UpdateUser(user *models.User) error {
+func (r *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
 	filter := bson.D{primitive.E{Key: "_id", Value: user.ID}}
 	uByte, err := bson.Marshal(user)
 	if err != nil {
@@ -120,23 +122,41 @@ func (r *UserRepository) UpdateUser(user *models.User) error {
 	}
 
 	opts := options.Update().SetUpsert(true)
-	_, err = r.collection.UpdateOne(r.ctx, filter, bson.D{primitive.E{Key: "$set", Value: update}}, opts)
+	_, err = r.collection.UpdateOne(ctx, filter, bson.D{primitive.E{Key: "$set", Value: update}}, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		return models.NewConflictError(models.ErrUserAlreadyExists, "email", user.Email)
+	}
 	if err != nil {
-		logrus.WithError(err).Error("error updating user")
+		logrus.WithContext(ctx).WithError(err).Error("error updating user")
+		return models.ErrUpdatingUser
+	}
+	return nil
+}
+
+// Sets whether the user has two-factor authentication enabled
+// the flag is unset rather than set to false, like the other omitted fields of the users
+func (r *UserRepository) SetUserTwoFactorEnabled(ctx context.Context, userID primitive.ObjectID, enabled bool) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: userID}}
+	update := bson.D{primitive.E{Key: "$unset", Value: bson.D{primitive.E{Key: "two_factor_enabled", Value: ""}}}}
+	if enabled {
+		update = bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "two_factor_enabled", Value: true}}}}
+	}
+	if _, err := r.collection.UpdateOne(ctx, filter, update); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error updating user two-factor authentication")
 		return models.ErrUpdatingUser
 	}
 	return nil
 }
 
-func (r *UserRepository) DeleteUser(userId string) error {
+func (r *UserRepository) DeleteUser(ctx context.Context, userId string) error {
 	ID, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return err
 	}
 	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
-	result, err := r.collection.DeleteOne(r.ctx, filter)
+	result, err := r.collection.DeleteOne(ctx, filter)
 	if err != nil {
-		logrus.WithError(err).Error("error deleting user")
+		logrus.WithContext(ctx).WithError(err).Error("error deleting user")
 		return models.ErrDeletingUser
 	}
 	if result.DeletedCount == 0 {
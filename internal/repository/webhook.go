@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keeper/internal/config"
+	"keeper/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	webhookCollectionName = "webhooks"
+)
+
+type WebhookRepository struct {
+	collection         *mongo.Collection
+	deliveryCollection *mongo.Collection
+}
+
+func NewWebhookRepository(cfg *config.Config, dbClient *mongo.Client) IWebhookRepository {
+	db := dbClient.Database(cfg.DbName)
+	return &WebhookRepository{
+		collection:         db.Collection(webhookCollectionName),
+		deliveryCollection: db.Collection(webhookDeliveryCollectionName),
+	}
+}
+
+// Save a new webhook
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, webhook *models.Webhook) (primitive.ObjectID, error) {
+	result, err := r.collection.InsertOne(ctx, webhook)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating webhook")
+		return primitive.ObjectID{}, fmt.Errorf("error creating webhook: %s", err.Error())
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// Find a webhook by its ID
+func (r *WebhookRepository) FindWebhookByID(ctx context.Context, id string) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
+	if err := r.collection.FindOne(ctx, filter).Decode(webhook); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, models.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Returns the webhooks of a bucket
+func (r *WebhookRepository) FindWebhooksByBucketUID(ctx context.Context, bucketUID string) ([]models.Webhook, error) {
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}}
+	return r.findWebhooks(ctx, filter)
+}
+
+// Returns the webhooks of a bucket that are subscribed to an event
+func (r *WebhookRepository) FindWebhooksByEvent(ctx context.Context, bucketUID string, event string) ([]models.Webhook, error) {
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}, primitive.E{Key: "events", Value: event}}
+	return r.findWebhooks(ctx, filter)
+}
+
+func (r *WebhookRepository) findWebhooks(ctx context.Context, filter bson.D) ([]models.Webhook, error) {
+	webhooks := []models.Webhook{}
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find webhooks")
+		return nil, models.ErrWebhooksNotFound
+	}
+	if err = cursor.All(ctx, &webhooks); err != nil {
+		return nil, models.ErrWebhooksNotFound
+	}
+	return webhooks, nil
+}
+
+// Delete a webhook by its ID
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, id string) error {
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
+	result, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting webhook")
+		return models.ErrDeletingWebhook
+	}
+	if result.DeletedCount == 0 {
+		return models.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Deletes all the webhooks of a bucket along with their deliveries
+// the deliveries go first so that none is left behind on failure
+func (r *WebhookRepository) DeleteBucketWebhooks(ctx context.Context, bucketUID string) error {
+	filter := bson.D{primitive.E{Key: "bucket_uid", Value: bucketUID}}
+	if _, err := r.deliveryCollection.DeleteMany(ctx, filter); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting webhook deliveries of bucket: %s", bucketUID)
+		return models.ErrDeletingWebhook
+	}
+	if _, err := r.collection.DeleteMany(ctx, filter); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting webhooks of bucket: %s", bucketUID)
+		return models.ErrDeletingWebhook
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keeper/internal/config"
+	"keeper/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	webhookDeliveryCollectionName = "webhookdeliveries"
+)
+
+type WebhookDeliveryRepository struct {
+	collection *mongo.Collection
+}
+
+func NewWebhookDeliveryRepository(cfg *config.Config, dbClient *mongo.Client) IWebhookDeliveryRepository {
+	webhookDeliveryCollection := dbClient.Database(cfg.DbName).Collection(webhookDeliveryCollectionName)
+	return &WebhookDeliveryRepository{
+		collection: webhookDeliveryCollection,
+	}
+}
+
+// Save a new webhook delivery
+func (r *WebhookDeliveryRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) (primitive.ObjectID, error) {
+	result, err := r.collection.InsertOne(ctx, delivery)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating webhook delivery")
+		return primitive.ObjectID{}, fmt.Errorf("error creating webhook delivery: %s", err.Error())
+	}
+	return result.InsertedID.(primitive.ObjectID), nil
+}
+
+// Find a webhook delivery by its ID
+func (r *WebhookDeliveryRepository) FindDeliveryByID(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	ID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "_id", Value: ID}}
+	if err := r.collection.FindOne(ctx, filter).Decode(delivery); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, models.ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Returns the most recent deliveries of a webhook
+// Accepts the webhook ID and the maximum number of deliveries to return
+func (r *WebhookDeliveryRepository) FindDeliveriesByWebhookID(ctx context.Context, webhookID string, limit int64) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	ID, err := primitive.ObjectIDFromHex(webhookID)
+	if err != nil {
+		return nil, models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "webhook_id", Value: ID}}
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "created_at", Value: -1}}).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("cannot find webhook deliveries")
+		return nil, models.ErrWebhookDeliveryNotFound
+	}
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, models.ErrWebhookDeliveryNotFound
+	}
+	return deliveries, nil
+}
+
+// Records the outcome of a delivery attempt
+func (r *WebhookDeliveryRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	filter := bson.D{primitive.E{Key: "_id", Value: delivery.ID}}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "status", Value: delivery.Status},
+		primitive.E{Key: "attempts", Value: delivery.Attempts},
+		primitive.E{Key: "status_code", Value: delivery.StatusCode},
+		primitive.E{Key: "error", Value: delivery.Error},
+		primitive.E{Key: "updated_at", Value: delivery.UpdatedAt},
+		primitive.E{Key: "delivered_at", Value: delivery.DeliveredAt},
+	}}}
+	if _, err := r.collection.UpdateOne(ctx, filter, update); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error updating webhook delivery")
+		return models.ErrUpdatingWebhookDelivery
+	}
+	return nil
+}
+
+// Deletes all the deliveries of a webhook
+func (r *WebhookDeliveryRepository) DeleteWebhookDeliveries(ctx context.Context, webhookID string) error {
+	ID, err := primitive.ObjectIDFromHex(webhookID)
+	if err != nil {
+		return models.ErrInvalidObjectID
+	}
+	filter := bson.D{primitive.E{Key: "webhook_id", Value: ID}}
+	if _, err := r.collection.DeleteMany(ctx, filter); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error deleting webhook deliveries")
+		return models.ErrDeletingWebhook
+	}
+	return nil
+}
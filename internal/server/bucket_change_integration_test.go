@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"keeper/internal/repository"
+	"sync"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Test that the changes recorded concurrently get every sequence number once, without gaps
+func (s *ServerIntegrationTestSuite) TestBucketChanges_ConcurrentSequence() {
+	bucketUID := primitive.NewObjectID().Hex()
+	changeRepo := repository.NewBucketChangeRepository(s.Cfg, s.DbConn.Client)
+	const writers, keysPerWriter = 20, 3
+
+	// act
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			keys := make([]string, keysPerWriter)
+			for k := range keys {
+				keys[k] = fmt.Sprintf("key-%d-%d", i, k)
+			}
+			errs <- changeRepo.RecordChanges(context.Background(), bucketUID, "set", keys...)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	changes, findErr := changeRepo.FindChanges(context.Background(), bucketUID, 0, writers*keysPerWriter+1)
+	latest, latestErr := changeRepo.LatestChangeSeq(context.Background(), bucketUID)
+
+	// assert
+	for err := range errs {
+		assert.Nil(s.T(), err)
+	}
+	assert.Nil(s.T(), findErr)
+	assert.Nil(s.T(), latestErr)
+	assert.Len(s.T(), changes, writers*keysPerWriter)
+	for i, change := range changes {
+		assert.Equal(s.T(), int64(i+1), change.Seq)
+	}
+	assert.Equal(s.T(), int64(writers*keysPerWriter), latest)
+}
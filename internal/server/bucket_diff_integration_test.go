@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that the bucket compared to by a diff needs the item read access, as the bucket of the path does
+func (s *ServerIntegrationTestSuite) TestBucketDiff_OtherBucketItemReadAccess() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	readable, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	// the items of this bucket are not readable with an api key
+	unreadable, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BucketPermissionsList{models.BucketPermissionPublicReadBucket})
+	assert.Nil(s.T(), err)
+
+	diff := func(from string, to string) int {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/%s/diff?to=%s", BASE_URL, from, to), nil)
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+		recorder := httptest.NewRecorder()
+		s.Server.Server.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// assert
+	assert.Equal(s.T(), http.StatusOK, diff(readable.UID, readable.UID))
+	assert.Equal(s.T(), http.StatusForbidden, diff(readable.UID, unreadable.UID))
+	assert.Equal(s.T(), http.StatusForbidden, diff(unreadable.UID, readable.UID))
+	assert.Equal(s.T(), http.StatusNotFound, diff(readable.UID, "missing"))
+}
+
+// Test that a merge needs the item read access of its source,
+// and the item delete access of its target when it replaces or trashes items
+func (s *ServerIntegrationTestSuite) TestBucketMerge_Access() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	source, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	unreadable, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BucketPermissionsList{models.BucketPermissionPublicReadBucket})
+	assert.Nil(s.T(), err)
+	// the items of the target can be written but not deleted with an api key
+	target, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BucketPermissionsList{
+		models.BucketPermissionPublicReadBucket,
+		models.BucketPermissionPublicReadItem,
+		models.BucketPermissionPublicWriteItem,
+	})
+	assert.Nil(s.T(), err)
+
+	merge := func(data dto.MergeBucketInputDTO) int {
+		body, _ := json.Marshal(data)
+		request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/bucket/%s/merge", BASE_URL, target.UID), bytes.NewReader(body))
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+		request.Header.Add("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		s.Server.Server.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// assert
+	assert.Equal(s.T(), http.StatusForbidden, merge(dto.MergeBucketInputDTO{SourceBucketUID: unreadable.UID, Strategy: models.MergeStrategySkip}))
+	assert.Equal(s.T(), http.StatusForbidden, merge(dto.MergeBucketInputDTO{SourceBucketUID: source.UID, Strategy: models.MergeStrategyOverwrite}))
+	assert.Equal(s.T(), http.StatusForbidden, merge(dto.MergeBucketInputDTO{SourceBucketUID: source.UID, Strategy: models.MergeStrategySkip, DeleteMissing: true}))
+	assert.Equal(s.T(), http.StatusOK, merge(dto.MergeBucketInputDTO{SourceBucketUID: source.UID, Strategy: models.MergeStrategySkip}))
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/repository"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// number of requests racing to create the same document
+const concurrentRequests = 20
+
+// Sends the same request concurrently
+// Returns the number of responses by status code and the problems of the conflict responses
+func (s *ServerIntegrationTestSuite) hammer(newRequest func() *http.Request) (map[int]int, []models.Problem) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	codes := map[int]int{}
+	conflicts := []models.Problem{}
+	start := make(chan struct{})
+	for i := 0; i < concurrentRequests; i++ {
+		request := newRequest()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			recorder := httptest.NewRecorder()
+			s.Server.Server.ServeHTTP(recorder, request)
+			mu.Lock()
+			defer mu.Unlock()
+			codes[recorder.Code]++
+			if recorder.Code == http.StatusConflict {
+				resp := models.Problem{}
+				json.Unmarshal(recorder.Body.Bytes(), &resp)
+				conflicts = append(conflicts, resp)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return codes, conflicts
+}
+
+// Test that concurrent creations of a bucket item key create a single item
+func (s *ServerIntegrationTestSuite) TestConflict_ConcurrentCreateBucketItem() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+
+	url := fmt.Sprintf("%s/item/%s", BASE_URL, testBucket.UID)
+	body, err := json.Marshal(&dto.CreateBucketItemInputDTO{
+		Key:  "racing-key",
+		Data: "string value",
+	})
+	assert.Nil(s.T(), err)
+
+	// act
+	codes, conflicts := s.hammer(func() *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+		request.Header.Add("Content-Type", "application/json")
+		return request
+	})
+
+	// assert
+	assert.Equal(s.T(), 1, codes[http.StatusCreated])
+	assert.Equal(s.T(), concurrentRequests-1, codes[http.StatusConflict])
+	for _, conflict := range conflicts {
+		assert.Equal(s.T(), models.ErrorCode("bucket_item_already_exists"), conflict.Code)
+		assert.Equal(s.T(), models.ErrBucketItemAlreadyExists.Error(), conflict.Detail)
+		assert.Equal(s.T(), "key", conflict.Field)
+	}
+	bucketItems, err := repository.NewBucketItemRepository(s.Cfg, s.DbConn.Client).FindBucketItems(context.Background(), testBucket.UID)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), bucketItems, 1)
+}
+
+// Test that concurrent registrations of an email create a single user
+func (s *ServerIntegrationTestSuite) TestConflict_ConcurrentRegister() {
+	url := fmt.Sprintf("%s/auth/register", BASE_URL)
+	body, err := json.Marshal(&dto.CreateUserInputDTO{
+		Firstname: "Racing",
+		Lastname:  "User",
+		Username:  "racing-user",
+		Email:     "racing-user@example.com",
+		Password:  "Secret12345!",
+	})
+	assert.Nil(s.T(), err)
+
+	// act
+	codes, conflicts := s.hammer(func() *http.Request {
+		request, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		request.Header.Add("Content-Type", "application/json")
+		return request
+	})
+
+	// assert
+	assert.Equal(s.T(), 1, codes[http.StatusCreated])
+	assert.Equal(s.T(), concurrentRequests-1, codes[http.StatusConflict])
+	for _, conflict := range conflicts {
+		assert.Equal(s.T(), models.ErrorCode("user_already_exists"), conflict.Code)
+		assert.Equal(s.T(), models.ErrUserAlreadyExists.Error(), conflict.Detail)
+		assert.Equal(s.T(), "email", conflict.Field)
+	}
+}
@@ -0,0 +1,397 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"keeper/internal/auth"
+	"keeper/internal/auth/auth_realm"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"keeper/internal/pkg/metrics"
+	"keeper/internal/pkg/ratelimit"
+	"keeper/internal/repository"
+	"keeper/internal/services"
+	"keeper/internal/validators"
+	"keeper/pkg/kipapb"
+	"keeper/pkg/log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC API served next to the HTTP API, it authenticates with the same credentials
+// and enforces the same api key and bucket permissions
+type GRPCServer struct {
+	Server      *grpc.Server
+	Cfg         *config.Config
+	Middlewares *Middleware
+	shutdown    chan struct{} // closed to end the open watch streams
+}
+
+// route group of the rate limits of the authenticated gRPC calls
+const grpcRateLimitGroup = "grpc"
+
+// Permissions required by a gRPC method when it is called with an api key,
+// mirroring the middlewares of the matching HTTP route
+type grpcMethodAccess struct {
+	apiKeyPermission models.APIKeyPermission
+	bucketPermission models.BucketPermission // empty when the method is not scoped to a bucket
+}
+
+var grpcMethodAccessRules = map[string]grpcMethodAccess{
+	"/kipa.v1.BucketService/CreateBucket":   {apiKeyPermission: models.APIKeyPermissionWriteBucket},
+	"/kipa.v1.BucketService/GetBucket":      {apiKeyPermission: models.APIKeyPermissionReadBucket, bucketPermission: models.BucketPermissionPublicReadBucket},
+	"/kipa.v1.BucketService/ListBuckets":    {apiKeyPermission: models.APIKeyPermissionReadBucket},
+	"/kipa.v1.BucketService/UpdateBucket":   {apiKeyPermission: models.APIKeyPermissionWriteBucket, bucketPermission: models.BucketPermissionPublicWriteBucket},
+	"/kipa.v1.BucketService/DeleteBucket":   {apiKeyPermission: models.APIKeyPermissionDeleteBucket, bucketPermission: models.BucketPermissionPublicDeleteBucket},
+	"/kipa.v1.ItemService/CreateItem":       {apiKeyPermission: models.APIKeyPermissionWriteItem, bucketPermission: models.BucketPermissionPublicWriteItem},
+	"/kipa.v1.ItemService/GetItem":          {apiKeyPermission: models.APIKeyPermissionReadItem, bucketPermission: models.BucketPermissionPublicReadItem},
+	"/kipa.v1.ItemService/ListItems":        {apiKeyPermission: models.APIKeyPermissionReadItem, bucketPermission: models.BucketPermissionPublicReadItem},
+	"/kipa.v1.ItemService/UpdateItem":       {apiKeyPermission: models.APIKeyPermissionWriteItem, bucketPermission: models.BucketPermissionPublicWriteItem},
+	"/kipa.v1.ItemService/DeleteItem":       {apiKeyPermission: models.APIKeyPermissionDeleteItem, bucketPermission: models.BucketPermissionPublicDeleteItem},
+	"/kipa.v1.ItemService/IncrementItem":    {apiKeyPermission: models.APIKeyPermissionWriteItem, bucketPermission: models.BucketPermissionPublicWriteItem},
+	"/kipa.v1.ItemService/BatchGetItems":    {apiKeyPermission: models.APIKeyPermissionReadItem, bucketPermission: models.BucketPermissionPublicReadItem},
+	"/kipa.v1.ItemService/BatchSetItems":    {apiKeyPermission: models.APIKeyPermissionWriteItem, bucketPermission: models.BucketPermissionPublicWriteItem},
+	"/kipa.v1.ItemService/BatchDeleteItems": {apiKeyPermission: models.APIKeyPermissionDeleteItem, bucketPermission: models.BucketPermissionPublicDeleteItem},
+	"/kipa.v1.ItemService/Watch":            {apiKeyPermission: models.APIKeyPermissionReadItem, bucketPermission: models.BucketPermissionPublicReadItem},
+}
+
+// authenticated caller of a gRPC method
+type grpcAuthInfo struct {
+	user        *models.User
+	credType    auth.CredentialType
+	maskID      string // mask ID of the api key credential
+	permissions models.APIKeyPermissionsList
+	rateLimit   *models.APIKeyRateLimit // rate limit of the api key credential
+}
+
+type grpcAuthCtxKey struct{}
+
+// requests scoped to a bucket
+type bucketScopedRequest interface {
+	GetBucketUid() string
+}
+
+func NewGRPCServer(cfg *config.Config, dbClient *mongo.Client, middlewares *Middleware) *GRPCServer {
+	s := &GRPCServer{
+		Cfg:         cfg,
+		Middlewares: middlewares,
+		shutdown:    make(chan struct{}),
+	}
+	s.Server = grpc.NewServer(
+		grpc.UnaryInterceptor(s.unaryAuthInterceptor),
+		grpc.StreamInterceptor(s.streamAuthInterceptor),
+	)
+
+	bucketRepo := repository.NewBucketRepository(cfg, dbClient)
+	bucketItemRepo := repository.NewBucketItemRepository(cfg, dbClient)
+	bucketSnapshotRepo := repository.NewBucketSnapshotRepository(cfg, dbClient)
+	blobRepo := repository.NewBucketItemBlobRepository(cfg, dbClient)
+	bucketChangeRepo := repository.NewBucketChangeRepository(cfg, dbClient)
+	auditRepo := repository.NewAuditEventRepository(cfg, dbClient)
+	usageRepo := repository.NewUsageRepository(cfg, dbClient)
+	webhookRepo := repository.NewWebhookRepository(cfg, dbClient)
+	webhookSvc := services.NewWebhookService(cfg, webhookRepo, repository.NewWebhookDeliveryRepository(cfg, dbClient), bucketRepo)
+	kipapb.RegisterBucketServiceServer(s.Server, &grpcBucketService{
+		bucketSvc: services.NewBucketService(cfg, bucketRepo, bucketItemRepo, bucketSnapshotRepo, blobRepo, auditRepo, usageRepo, webhookRepo, webhookSvc),
+		validator: validators.NewValidator(),
+	})
+	kipapb.RegisterItemServiceServer(s.Server, &grpcItemService{
+		bucketItemSvc:   services.NewBucketItemService(cfg, bucketItemRepo, bucketRepo, blobRepo, auditRepo, usageRepo, webhookSvc),
+		bucketChangeSvc: services.NewBucketChangeService(cfg, bucketRepo, bucketChangeRepo),
+		validator:       validators.NewValidator(),
+		shutdown:        s.shutdown,
+	})
+	return s
+}
+
+// Start serving the gRPC API, blocks until the server is stopped
+func (s *GRPCServer) Start() error {
+	PORT := s.Cfg.GRPCPort
+
+	if PORT == "" {
+		PORT = "5051"
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", PORT))
+	if err != nil {
+		return err
+	}
+	logrus.Infof("gRPC server listening on %s", lis.Addr().String())
+	return s.Server.Serve(lis)
+}
+
+// Stops the gRPC server once the in-flight calls have completed,
+// the calls still running when the context is done are cancelled
+func (s *GRPCServer) Shutdown(ctx context.Context) {
+	close(s.shutdown)
+	stopped := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Server.Stop()
+	}
+}
+
+// Returns ctx tagged with the request ID of the 'x-request-id' metadata of the call or a generated one,
+// the ID is sent back in the header of the call
+func grpcRequestContext(ctx context.Context, setHeader func(metadata.MD) error) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	sent := ""
+	if values := md.Get("x-request-id"); len(values) > 0 {
+		sent = values[0]
+	}
+	id := requestID(sent)
+	ctx = log.WithRequestID(ctx, id)
+	if err := setHeader(metadata.Pairs("x-request-id", id)); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error setting the request id header")
+	}
+	return ctx
+}
+
+func (s *GRPCServer) unaryAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	setHeader := func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }
+	ctx = grpcRequestContext(ctx, setHeader)
+	if err := s.rateLimitIP(ctx, setHeader); err != nil {
+		return nil, err
+	}
+	authInfo, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = s.auditContext(ctx, authInfo)
+	if err := s.rateLimit(ctx, authInfo, setHeader); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, info.FullMethod, authInfo, req); err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, grpcAuthCtxKey{}, authInfo), req)
+}
+
+func (s *GRPCServer) streamAuthInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := grpcRequestContext(ss.Context(), ss.SetHeader)
+	if err := s.rateLimitIP(ctx, ss.SetHeader); err != nil {
+		return err
+	}
+	authInfo, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = s.auditContext(ctx, authInfo)
+	if err := s.rateLimit(ctx, authInfo, ss.SetHeader); err != nil {
+		return err
+	}
+	// the bucket of a streaming call is only known once its request is received
+	return handler(srv, &authorizedServerStream{
+		ServerStream: ss,
+		ctx:          context.WithValue(ctx, grpcAuthCtxKey{}, authInfo),
+		authorize: func(req interface{}) error {
+			return s.authorize(ctx, info.FullMethod, authInfo, req)
+		},
+	})
+}
+
+// Rate limits a call by the IP of its peer, before it is authenticated
+func (s *GRPCServer) rateLimitIP(ctx context.Context, setHeader func(metadata.MD) error) error {
+	m := s.Middlewares
+	if !m.Cfg.RateLimitEnabled || m.ipRateLimit == nil {
+		return nil
+	}
+	return s.takeRateLimit(ctx, "ip:"+peerIP(ctx), *m.ipRateLimit, setHeader)
+}
+
+// Rate limits an authenticated call by its api key or user, the calls are counted in the grpc group
+// and the rate limit of an api key replaces the limit of the group
+func (s *GRPCServer) rateLimit(ctx context.Context, authInfo *grpcAuthInfo, setHeader func(metadata.MD) error) error {
+	limit, ok := s.Middlewares.rateLimitFor(grpcRateLimitGroup, authInfo.rateLimit)
+	if !ok {
+		return nil
+	}
+	key := rateLimitKey(services.AuditActorFromContext(ctx), peerIP(ctx))
+	return s.takeRateLimit(ctx, grpcRateLimitGroup+":"+key, limit, setHeader)
+}
+
+// Takes a token of the bucket of key, the rate limit headers of the HTTP API are sent in the header of the call
+func (s *GRPCServer) takeRateLimit(ctx context.Context, key string, limit ratelimit.Limit, setHeader func(metadata.MD) error) error {
+	header := http.Header{}
+	err := s.Middlewares.takeRateLimit(ctx, key, limit, header)
+	md := metadata.MD{}
+	for name, values := range header {
+		md.Set(strings.ToLower(name), values...)
+	}
+	if len(md) > 0 {
+		if err := setHeader(md); err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("error setting the rate limit header")
+		}
+	}
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, models.ErrRateLimitExceeded.Error())
+	}
+	return nil
+}
+
+// Returns the IP of the peer of a call
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		return host
+	}
+	return ip
+}
+
+// Returns ctx with the audit actor of an authenticated call, its address is the one of the peer
+func (s *GRPCServer) auditContext(ctx context.Context, authInfo *grpcAuthInfo) context.Context {
+	actor := models.AuditActor{IP: peerIP(ctx)}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("user-agent"); len(values) > 0 {
+		actor.UserAgent = values[0]
+	}
+	ctx = services.WithAuditActor(ctx, actor)
+	return s.Middlewares.withAuditActor(ctx, authInfo.user, authInfo.credType, authInfo.maskID)
+}
+
+// Authenticates the credential passed in the 'authorization' metadata of the call
+func (s *GRPCServer) authenticate(ctx context.Context) (*grpcAuthInfo, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+	}
+	cred, err := parseAuthHeader(values[0])
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to get auth from request metadata")
+		metrics.IncAuthFailure(metrics.CredentialTypeInvalid)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	authRealm := auth_realm.NewAuthRealm(s.Cfg, s.Middlewares.ApiKeyRepository, s.Middlewares.UserRepository, s.Middlewares.SessionRepository)
+	authResponse, err := authRealm.Authenticate(ctx, cred)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error authenticating user with type: %s", cred.Type.String())
+		metrics.IncAuthFailure(cred.Type.String())
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return &grpcAuthInfo{
+		user:        authResponse.User,
+		credType:    cred.Type,
+		maskID:      apiKeyMaskID(cred),
+		permissions: authResponse.Permissions,
+		rateLimit:   authResponse.RateLimit,
+	}, nil
+}
+
+// Checks the api key and bucket permissions required by a method, only api keys are restricted,
+// and the two-factor authentication required by the bucket of the method
+func (s *GRPCServer) authorize(ctx context.Context, method string, authInfo *grpcAuthInfo, req interface{}) error {
+	isAPIKey := authInfo.credType == auth.CredentialTypeAPIKey
+	rule, ok := grpcMethodAccessRules[method]
+	if isAPIKey {
+		if !ok {
+			return status.Errorf(codes.PermissionDenied, "method %s cannot be called with an api key", method)
+		}
+		if !authInfo.permissions.Contains(rule.apiKeyPermission) {
+			return status.Errorf(codes.PermissionDenied, "%s permission is required.", rule.apiKeyPermission.String())
+		}
+	}
+	if rule.bucketPermission == "" {
+		return nil
+	}
+	// the bucket is only needed for the api key permissions or the two-factor requirement
+	if !isAPIKey && authInfo.user.TwoFactorEnabled {
+		return nil
+	}
+	scoped, ok := req.(bucketScopedRequest)
+	if !ok {
+		return status.Error(codes.InvalidArgument, "bucket uid is required")
+	}
+	bucket, err := s.Middlewares.BucketRepository.FindBucketByUID(ctx, scoped.GetBucketUid())
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("bucket does not exist")
+		return status.Error(codes.NotFound, "bucket does not exist")
+	}
+	if isAPIKey && !bucket.Permissions.Contains(rule.bucketPermission) {
+		return status.Errorf(codes.PermissionDenied, "%s permission is required.", rule.bucketPermission.String())
+	}
+	if bucketTwoFactorDenied(bucket, authInfo.user, !isReadBucketPermission(rule.bucketPermission)) {
+		return status.Error(codes.PermissionDenied, models.ErrTwoFactorRequired.Error())
+	}
+	return nil
+}
+
+// Reports whether a bucket permission only grants reads
+func isReadBucketPermission(permission models.BucketPermission) bool {
+	return permission == models.BucketPermissionPublicReadBucket || permission == models.BucketPermissionPublicReadItem
+}
+
+// Returns the authenticated caller of a gRPC method
+func authInfoFromContext(ctx context.Context) (*grpcAuthInfo, error) {
+	authInfo, ok := ctx.Value(grpcAuthCtxKey{}).(*grpcAuthInfo)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return authInfo, nil
+}
+
+// server stream authorizing the first request received from the client
+type authorizedServerStream struct {
+	grpc.ServerStream
+	ctx        context.Context
+	authorize  func(req interface{}) error
+	authorized bool
+}
+
+func (s *authorizedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if !s.authorized {
+		if err := s.authorize(m); err != nil {
+			return err
+		}
+		s.authorized = true
+	}
+	return nil
+}
+
+// gRPC codes of the HTTP statuses of the API errors
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// Maps the errors returned by the services to gRPC status errors, as the HTTP errors are mapped to statuses
+// the unmapped errors are internal errors whose message is logged instead of returned
+func grpcError(err error) error {
+	apiErr := models.ToAPIError(err, http.StatusInternalServerError)
+	code, ok := grpcCodes[apiErr.Status]
+	if !ok {
+		logrus.WithError(err).Error("error handling gRPC call")
+		return status.Error(codes.Internal, apiErr.Problem("").Detail)
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"keeper/internal/models"
+	"keeper/internal/pkg/ratelimit"
+	"keeper/internal/repository"
+	"keeper/internal/server/testdb"
+	"keeper/pkg/kipapb"
+	"net"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve the gRPC API over an in-memory listener and return a client connection to it
+func (s *ServerIntegrationTestSuite) dialGRPC() *grpc.ClientConn {
+	lis := bufconn.Listen(1024 * 1024)
+	go s.Server.GRPC.Server.Serve(lis)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.Nil(s.T(), err)
+	return conn
+}
+
+// Test 'get bucket item' over gRPC
+func (s *ServerIntegrationTestSuite) TestGRPC_GetItem() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+
+	// seed api key for authorization
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+
+	// seed a new bucket and bucket item
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucketItem, err := testdb.SeedBucketItem(s.DbConn.Client, s.Cfg, testUser.ID, testBucket.ID, testBucket.UID, "string")
+	assert.Nil(s.T(), err)
+
+	conn := s.dialGRPC()
+	defer conn.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", fmt.Sprintf("Bearer %s", key))
+
+	// act
+	item, err := kipapb.NewItemServiceClient(conn).GetItem(ctx, &kipapb.GetItemRequest{
+		BucketUid: testBucket.UID,
+		Key:       testBucketItem.Key,
+	})
+
+	// assert
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), testBucketItem.ID.Hex(), item.GetId())
+	assert.Equal(s.T(), "string", item.GetData().GetStringValue())
+}
+
+// Test that gRPC calls enforce the api key permissions
+func (s *ServerIntegrationTestSuite) TestGRPC_RequiresAPIKeyPermission() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+
+	// seed an api key that cannot delete items
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKeyPermissionsList{models.APIKeyPermissionReadItem})
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+
+	conn := s.dialGRPC()
+	defer conn.Close()
+	client := kipapb.NewItemServiceClient(conn)
+
+	// act: call without credentials
+	_, err = client.DeleteItem(context.Background(), &kipapb.DeleteItemRequest{BucketUid: testBucket.UID, Key: "key"})
+	assert.Equal(s.T(), codes.Unauthenticated, status.Code(err))
+
+	// act: call without the delete item permission
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", fmt.Sprintf("Bearer %s", key))
+	_, err = client.DeleteItem(ctx, &kipapb.DeleteItemRequest{BucketUid: testBucket.UID, Key: "key"})
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+}
+
+// Test that gRPC calls enforce the two-factor authentication required by a bucket
+func (s *ServerIntegrationTestSuite) TestGRPC_RequiresBucketTwoFactor() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucketItem, err := testdb.SeedBucketItem(s.DbConn.Client, s.Cfg, testUser.ID, testBucket.ID, testBucket.UID, "string")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), repository.NewBucketRepository(s.Cfg, s.DbConn.Client).SetBucketRequireTwoFactor(context.Background(), testBucket.UID, true))
+
+	conn := s.dialGRPC()
+	defer conn.Close()
+	client := kipapb.NewItemServiceClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", fmt.Sprintf("Bearer %s", key))
+
+	// the owner without two-factor authentication can neither read, write nor watch the bucket
+	_, err = client.GetItem(ctx, &kipapb.GetItemRequest{BucketUid: testBucket.UID, Key: testBucketItem.Key})
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+	_, err = client.DeleteItem(ctx, &kipapb.DeleteItemRequest{BucketUid: testBucket.UID, Key: testBucketItem.Key})
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+	stream, err := client.Watch(ctx, &kipapb.WatchRequest{BucketUid: testBucket.UID})
+	assert.Nil(s.T(), err)
+	_, err = stream.Recv()
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+
+	// and can once it is enabled
+	assert.Nil(s.T(), repository.NewUserRepository(s.Cfg, s.DbConn.Client).SetUserTwoFactorEnabled(context.Background(), testUser.ID, true))
+	item, err := client.GetItem(ctx, &kipapb.GetItemRequest{BucketUid: testBucket.UID, Key: testBucketItem.Key})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), testBucketItem.ID.Hex(), item.GetId())
+}
+
+// Test that gRPC calls are rate limited by IP before they are authenticated, then by their caller
+func (s *ServerIntegrationTestSuite) TestGRPC_RateLimit() {
+	s.Server.Middlewares.rateLimitStore = ratelimit.NewMemoryStore()
+	s.Server.Middlewares.ipRateLimit = &ratelimit.Limit{Requests: 2, Period: time.Minute}
+	defer func() { s.Server.Middlewares.ipRateLimit = parseIPRateLimit(s.Cfg.RateLimitIP) }()
+	conn := s.dialGRPC()
+	defer conn.Close()
+	client := kipapb.NewItemServiceClient(conn)
+
+	// the invalid credentials are limited by IP
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer invalid")
+	request := &kipapb.GetItemRequest{BucketUid: "any-bucket", Key: "key"}
+	grpcCodes := []codes.Code{}
+	for i := 0; i < 3; i++ {
+		_, err := client.GetItem(ctx, request)
+		grpcCodes = append(grpcCodes, status.Code(err))
+	}
+	assert.Equal(s.T(), []codes.Code{codes.Unauthenticated, codes.Unauthenticated, codes.ResourceExhausted}, grpcCodes)
+
+	// the authenticated calls are limited by api key in the grpc group
+	s.Server.Middlewares.rateLimitStore = ratelimit.NewMemoryStore()
+	s.Server.Middlewares.ipRateLimit = nil
+	s.Server.Middlewares.rateLimits[grpcRateLimitGroup] = ratelimit.Limit{Requests: 1, Period: time.Minute}
+	defer delete(s.Server.Middlewares.rateLimits, grpcRateLimitGroup)
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", fmt.Sprintf("Bearer %s", key))
+	request = &kipapb.GetItemRequest{BucketUid: testBucket.UID, Key: "missing"}
+	_, err = client.GetItem(ctx, request)
+	assert.NotEqual(s.T(), codes.ResourceExhausted, status.Code(err))
+	_, err = client.GetItem(ctx, request)
+	assert.Equal(s.T(), codes.ResourceExhausted, status.Code(err))
+}
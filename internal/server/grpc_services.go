@@ -0,0 +1,432 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/services"
+	"keeper/internal/validators"
+	"keeper/pkg/kipapb"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// interval between two polls of the change feed of a watched bucket
+const grpcWatchPollInterval = time.Second
+
+// gRPC bucket service backed by the bucket service of the HTTP API
+type grpcBucketService struct {
+	kipapb.UnimplementedBucketServiceServer
+	bucketSvc services.IBucketService
+	validator *validators.Validator
+}
+
+// gRPC item service backed by the bucket item and change feed services of the HTTP API
+type grpcItemService struct {
+	kipapb.UnimplementedItemServiceServer
+	bucketItemSvc   services.IBucketItemService
+	bucketChangeSvc services.IBucketChangeService
+	validator       *validators.Validator
+	shutdown        <-chan struct{}
+}
+
+func (s *grpcBucketService) CreateBucket(ctx context.Context, req *kipapb.CreateBucketRequest) (*kipapb.Bucket, error) {
+	authInfo, err := authInfoFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := dto.CreateBucketInputDTO{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Permissions: toBucketPermissions(req.GetPermissions()),
+	}
+	if err := s.validator.Validate(data); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp, err := s.bucketSvc.CreateBucket(ctx, data, authInfo.user.ID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &kipapb.Bucket{
+		Id:          resp.ID.Hex(),
+		Uid:         resp.UID,
+		UserId:      authInfo.user.ID.Hex(),
+		Name:        resp.Name,
+		Description: resp.Description,
+		Permissions: fromBucketPermissions(resp.Permissions),
+		CreatedAt:   toTimestamp(resp.CreatedAt),
+	}, nil
+}
+
+func (s *grpcBucketService) GetBucket(ctx context.Context, req *kipapb.GetBucketRequest) (*kipapb.Bucket, error) {
+	bucket, err := s.bucketSvc.FindBucketByUID(ctx, req.GetBucketUid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProtoBucket(bucket), nil
+}
+
+func (s *grpcBucketService) ListBuckets(ctx context.Context, req *kipapb.ListBucketsRequest) (*kipapb.ListBucketsResponse, error) {
+	authInfo, err := authInfoFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := s.bucketSvc.ListUserBuckets(ctx, authInfo.user.ID.Hex())
+	if err != nil && !errors.Is(err, models.ErrBucketsNotFound) {
+		return nil, grpcError(err)
+	}
+	resp := &kipapb.ListBucketsResponse{Buckets: make([]*kipapb.Bucket, 0, len(buckets))}
+	for i := range buckets {
+		resp.Buckets = append(resp.Buckets, toProtoBucket(&buckets[i]))
+	}
+	return resp, nil
+}
+
+func (s *grpcBucketService) UpdateBucket(ctx context.Context, req *kipapb.UpdateBucketRequest) (*kipapb.UpdateBucketResponse, error) {
+	data := dto.UpdateBucketInputDTO{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Permissions: toBucketPermissions(req.GetPermissions()),
+	}
+	if err := s.validator.Validate(data); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.bucketSvc.UpdateBucket(ctx, req.GetBucketUid(), data); err != nil {
+		return nil, grpcError(err)
+	}
+	return &kipapb.UpdateBucketResponse{}, nil
+}
+
+func (s *grpcBucketService) DeleteBucket(ctx context.Context, req *kipapb.DeleteBucketRequest) (*kipapb.DeleteBucketResponse, error) {
+	if err := s.bucketSvc.DeleteBucket(ctx, req.GetBucketUid()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &kipapb.DeleteBucketResponse{}, nil
+}
+
+func (s *grpcItemService) CreateItem(ctx context.Context, req *kipapb.CreateItemRequest) (*kipapb.Item, error) {
+	authInfo, err := authInfoFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := dto.CreateBucketItemInputDTO{
+		Key:  req.GetKey(),
+		Data: req.GetData().AsInterface(),
+		TTL:  int(req.GetTtl()),
+	}
+	if err := s.validator.Validate(data); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp, err := s.bucketItemSvc.CreateBucketItem(ctx, data, authInfo.user.ID, req.GetBucketUid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProtoItem(&models.BucketItem{
+		ID:        resp.ID,
+		BucketUID: resp.BucketUID,
+		Key:       resp.Key,
+		Data:      resp.Data,
+		TTL:       resp.TTL,
+		Type:      resp.Type,
+		CreatedAt: resp.CreatedAt,
+	})
+}
+
+func (s *grpcItemService) GetItem(ctx context.Context, req *kipapb.GetItemRequest) (*kipapb.Item, error) {
+	bucketItem, err := s.bucketItemSvc.FindBucketItemByKeyName(ctx, req.GetBucketUid(), req.GetKey())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toProtoItem(bucketItem)
+}
+
+func (s *grpcItemService) ListItems(ctx context.Context, req *kipapb.ListItemsRequest) (*kipapb.ListItemsResponse, error) {
+	bucketItems, err := s.bucketItemSvc.ListBucketItems(ctx, req.GetBucketUid())
+	if err != nil && !errors.Is(err, models.ErrBucketItemsNotFound) {
+		return nil, grpcError(err)
+	}
+	items, err := toProtoItems(bucketItems)
+	if err != nil {
+		return nil, err
+	}
+	return &kipapb.ListItemsResponse{Items: items}, nil
+}
+
+func (s *grpcItemService) UpdateItem(ctx context.Context, req *kipapb.UpdateItemRequest) (*kipapb.UpdateItemResponse, error) {
+	if err := s.updateItem(ctx, req.GetBucketUid(), req.GetKey(), req.GetData(), req.GetTtl()); err != nil {
+		return nil, err
+	}
+	return &kipapb.UpdateItemResponse{}, nil
+}
+
+func (s *grpcItemService) DeleteItem(ctx context.Context, req *kipapb.DeleteItemRequest) (*kipapb.DeleteItemResponse, error) {
+	if err := s.bucketItemSvc.DeleteBucketItemByKeyName(ctx, req.GetBucketUid(), req.GetKey()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &kipapb.DeleteItemResponse{}, nil
+}
+
+func (s *grpcItemService) IncrementItem(ctx context.Context, req *kipapb.IncrementItemRequest) (*kipapb.IncrementItemResponse, error) {
+	if err := s.bucketItemSvc.IncrementIntValue(ctx, req.GetBucketUid(), req.GetKey(), int(req.GetAmount())); err != nil {
+		return nil, grpcError(err)
+	}
+	bucketItem, err := s.bucketItemSvc.FindBucketItemByKeyName(ctx, req.GetBucketUid(), req.GetKey())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	item, err := toProtoItem(bucketItem)
+	if err != nil {
+		return nil, err
+	}
+	return &kipapb.IncrementItemResponse{Item: item}, nil
+}
+
+func (s *grpcItemService) BatchGetItems(ctx context.Context, req *kipapb.BatchGetItemsRequest) (*kipapb.BatchGetItemsResponse, error) {
+	resp := &kipapb.BatchGetItemsResponse{}
+	for _, key := range req.GetKeys() {
+		bucketItem, err := s.bucketItemSvc.FindBucketItemByKeyName(ctx, req.GetBucketUid(), key)
+		if errors.Is(err, models.ErrBucketItemNotFound) {
+			resp.MissingKeys = append(resp.MissingKeys, key)
+			continue
+		}
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		item, err := toProtoItem(bucketItem)
+		if err != nil {
+			return nil, err
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	return resp, nil
+}
+
+func (s *grpcItemService) BatchSetItems(ctx context.Context, req *kipapb.BatchSetItemsRequest) (*kipapb.BatchSetItemsResponse, error) {
+	authInfo, err := authInfoFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &kipapb.BatchSetItemsResponse{}
+	for _, value := range req.GetItems() {
+		_, err := s.bucketItemSvc.FindBucketItemByKeyName(ctx, req.GetBucketUid(), value.GetKey())
+		if err != nil && !errors.Is(err, models.ErrBucketItemNotFound) {
+			return nil, grpcError(err)
+		}
+		if err == nil {
+			if err := s.updateItem(ctx, req.GetBucketUid(), value.GetKey(), value.GetData(), value.GetTtl()); err != nil {
+				return nil, err
+			}
+			resp.UpdatedKeys = append(resp.UpdatedKeys, value.GetKey())
+			continue
+		}
+		data := dto.CreateBucketItemInputDTO{
+			Key:  value.GetKey(),
+			Data: value.GetData().AsInterface(),
+			TTL:  int(value.GetTtl()),
+		}
+		if err := s.validator.Validate(data); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if _, err := s.bucketItemSvc.CreateBucketItem(ctx, data, authInfo.user.ID, req.GetBucketUid()); err != nil {
+			return nil, grpcError(err)
+		}
+		resp.CreatedKeys = append(resp.CreatedKeys, value.GetKey())
+	}
+	return resp, nil
+}
+
+func (s *grpcItemService) BatchDeleteItems(ctx context.Context, req *kipapb.BatchDeleteItemsRequest) (*kipapb.BatchDeleteItemsResponse, error) {
+	if err := s.bucketItemSvc.DeleteBucketItemsByKeyName(ctx, req.GetBucketUid(), req.GetKeys()); err != nil {
+		return nil, grpcError(err)
+	}
+	return &kipapb.BatchDeleteItemsResponse{}, nil
+}
+
+func (s *grpcItemService) Watch(req *kipapb.WatchRequest, stream kipapb.ItemService_WatchServer) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(grpcWatchPollInterval)
+	defer ticker.Stop()
+	since := req.GetSince()
+	for {
+		page, err := s.bucketChangeSvc.ListBucketChanges(ctx, req.GetBucketUid(), since, 0)
+		if err != nil {
+			return grpcError(err)
+		}
+		for _, change := range page.Changes {
+			resp := &kipapb.Change{
+				Seq:       change.Seq,
+				BucketUid: change.BucketUID,
+				Op:        change.Op,
+				Key:       change.Key,
+				CreatedAt: toTimestamp(change.CreatedAt),
+			}
+			if req.GetIncludeValues() && change.Op == models.BucketChangeOpSet {
+				bucketItem, err := s.bucketItemSvc.FindBucketItemByKeyName(ctx, change.BucketUID, change.Key)
+				if err != nil && !errors.Is(err, models.ErrBucketItemNotFound) {
+					return grpcError(err)
+				}
+				// the item may have been deleted by a later change
+				if err == nil {
+					if resp.Item, err = toProtoItem(bucketItem); err != nil {
+						return err
+					}
+				}
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+		since = page.NextCursor
+		// keep paging without waiting while a backlog of changes is replayed
+		if page.HasMore && len(page.Changes) > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.shutdown:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		case <-ticker.C:
+		}
+	}
+}
+
+// Updates the value of an item, keeping its key
+func (s *grpcItemService) updateItem(ctx context.Context, bucketUID string, key string, value *structpb.Value, ttl int32) error {
+	data := dto.UpdateBucketItemInputDTO{
+		Key:  key,
+		Data: value.AsInterface(),
+		TTL:  int(ttl),
+	}
+	if err := s.validator.Validate(data); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.bucketItemSvc.UpdateBucketItemByKeyName(ctx, data, bucketUID, key); err != nil {
+		return grpcError(err)
+	}
+	return nil
+}
+
+func toProtoBucket(bucket *dto.BucketDetailsOutput) *kipapb.Bucket {
+	return &kipapb.Bucket{
+		Id:          bucket.ID.Hex(),
+		Uid:         bucket.UID,
+		UserId:      bucket.UserID.Hex(),
+		Name:        bucket.Name,
+		Description: bucket.Description,
+		Permissions: fromBucketPermissions(bucket.Permissions),
+		CreatedAt:   toTimestamp(bucket.CreatedAt),
+		UpdatedAt:   toTimestamp(bucket.UpdatedAt),
+	}
+}
+
+func toProtoItem(bucketItem *models.BucketItem) (*kipapb.Item, error) {
+	item := &kipapb.Item{
+		Id:          bucketItem.ID.Hex(),
+		BucketUid:   bucketItem.BucketUID,
+		Key:         bucketItem.Key,
+		Ttl:         int32(bucketItem.TTL),
+		Type:        bucketItem.Type,
+		ContentType: bucketItem.ContentType,
+		Size:        bucketItem.Size,
+		CreatedAt:   toTimestamp(bucketItem.CreatedAt),
+		UpdatedAt:   toTimestamp(bucketItem.UpdatedAt),
+	}
+	if bucketItem.Type == models.BucketItemTypeBinary {
+		return item, nil
+	}
+	data, err := toProtoValue(bucketItem.Data)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to encode the value of '%s': %s", bucketItem.Key, err.Error())
+	}
+	item.Data = data
+	return item, nil
+}
+
+func toProtoItems(bucketItems []models.BucketItem) ([]*kipapb.Item, error) {
+	items := make([]*kipapb.Item, 0, len(bucketItems))
+	for i := range bucketItems {
+		item, err := toProtoItem(&bucketItems[i])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Converts an item value decoded from the database to a protobuf value
+func toProtoValue(data interface{}) (*structpb.Value, error) {
+	return structpb.NewValue(normalizeValue(data))
+}
+
+// Converts the bson types of a decoded value to the types supported by structpb
+func normalizeValue(data interface{}) interface{} {
+	switch v := data.(type) {
+	case primitive.D:
+		m := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			m[e.Key] = normalizeValue(e.Value)
+		}
+		return m
+	case primitive.M:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalizeValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalizeValue(e)
+		}
+		return m
+	case primitive.A:
+		return normalizeValue([]interface{}(v))
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = normalizeValue(e)
+		}
+		return l
+	case primitive.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	case primitive.ObjectID:
+		return v.Hex()
+	case primitive.Binary:
+		return base64.StdEncoding.EncodeToString(v.Data)
+	case primitive.Decimal128:
+		return v.String()
+	}
+	return data
+}
+
+func toTimestamp(t primitive.DateTime) *timestamppb.Timestamp {
+	if t == 0 {
+		return nil
+	}
+	return timestamppb.New(t.Time())
+}
+
+func toBucketPermissions(permissions []string) models.BucketPermissionsList {
+	list := make(models.BucketPermissionsList, 0, len(permissions))
+	for _, p := range permissions {
+		list = append(list, models.BucketPermission(p))
+	}
+	return list
+}
+
+func fromBucketPermissions(permissions models.BucketPermissionsList) []string {
+	list := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		list = append(list, p.String())
+	}
+	return list
+}
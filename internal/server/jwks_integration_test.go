@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"keeper/internal/auth/jwt"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that the key set is published, empty while the tokens are signed with the HS256 secrets
+func (s *ServerIntegrationTestSuite) TestJWKS() {
+	request, _ := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	recorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, request)
+
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	jwks := jwt.JWKS{}
+	assert.Nil(s.T(), json.Unmarshal(recorder.Body.Bytes(), &jwks))
+	assert.Empty(s.T(), jwks.Keys)
+}
+
+// Test that a token of one type cannot be used as another
+func (s *ServerIntegrationTestSuite) TestAuth_TokenTypes() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	tokens := s.login(testUser.Email, "Secret12345!")
+
+	// a refresh token is not an access token
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/auth/user", BASE_URL), nil)
+	request.Header.Add("Authorization", "Bearer "+tokens.RefreshToken)
+	recorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, request)
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+
+	// and an access token is not a refresh token
+	recorder = httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, newRefreshRequest(tokens.AccessToken))
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/server/testdb"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns a login request for an email and a password
+func newLoginRequest(email string, password string) *http.Request {
+	body, _ := json.Marshal(&dto.LoginUserInputDTO{Email: email, Password: password})
+	request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/auth/login", BASE_URL), bytes.NewReader(body))
+	request.Header.Add("Content-Type", "application/json")
+	return request
+}
+
+// Test that an unknown email and a wrong password fail the same way and that repeated failures lock the account
+func (s *ServerIntegrationTestSuite) TestAuth_LoginLockout() {
+	s.Cfg.LoginMaxFailures = 2
+	defer func() { s.Cfg.LoginMaxFailures = 5 }()
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+
+	recorder, unknownEmail := s.sendForProblem(newLoginRequest("unknown-"+testUser.Email, "Secret12345!"))
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+	_, wrongPassword := s.sendForProblem(newLoginRequest(testUser.Email, "Wrong12345!"))
+	assert.Equal(s.T(), unknownEmail.Code, wrongPassword.Code)
+	assert.Equal(s.T(), unknownEmail.Detail, wrongPassword.Detail)
+
+	// the second failure locks the account, even for the right password
+	s.sendForProblem(newLoginRequest(testUser.Email, "Wrong12345!"))
+	recorder, problem := s.sendForProblem(newLoginRequest(testUser.Email, "Secret12345!"))
+	assert.Equal(s.T(), http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("login_locked"), problem.Code)
+}
+
+// Test that the failures of an IP are counted by the IP of the connection, a spoofed X-Forwarded-For header
+// does not give a client a new count
+func (s *ServerIntegrationTestSuite) TestAuth_LoginLockoutSpoofedForwardedFor() {
+	s.Cfg.LoginMaxFailuresPerIP = 2
+	defer func() { s.Cfg.LoginMaxFailuresPerIP = 20 }()
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	sendFrom := func(forwardedFor string, email string, password string) (int, models.ErrorCode) {
+		request := newLoginRequest(email, password)
+		request.RemoteAddr = "203.0.113.50:1234"
+		request.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		recorder, problem := s.sendForProblem(request)
+		return recorder.Code, problem.Code
+	}
+
+	sendFrom("198.51.100.1", "unknown-1-"+testUser.Email, "Wrong12345!")
+	sendFrom("198.51.100.2", "unknown-2-"+testUser.Email, "Wrong12345!")
+
+	// act
+	code, problemCode := sendFrom("198.51.100.3", testUser.Email, "Secret12345!")
+
+	// assert
+	assert.Equal(s.T(), http.StatusTooManyRequests, code)
+	assert.Equal(s.T(), models.ErrorCode("login_locked"), problemCode)
+}
@@ -5,15 +5,20 @@ import (
 	"fmt"
 	"keeper/internal/config"
 	"keeper/internal/handlers"
+	"keeper/internal/pkg/metrics"
+	"keeper/internal/queue"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	_ "keeper/internal/docs"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -23,6 +28,8 @@ type Server struct {
 	Cfg         *config.Config
 	Handler     *handlers.Handler
 	Middlewares *Middleware
+	GRPC        *GRPCServer
+	Metrics     *http.Server // served on its own port, kept off the public network
 }
 
 // @title Kipa
@@ -46,10 +53,9 @@ type Server struct {
 // @schemes http
 func NewServer(cfg *config.Config, dbClient *mongo.Client) *Server {
 	e := echo.New()
+	e.HTTPErrorHandler = handlers.HTTPErrorHandler
+	e.IPExtractor = ipExtractor(cfg.TrustedProxies)
 	// middlewares
-	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
-		Format: "\n[${status}]: ${method} ${uri}",
-	}))
 	e.Use(middleware.Recover())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		Skipper:      middleware.DefaultSkipper,
@@ -57,18 +63,60 @@ func NewServer(cfg *config.Config, dbClient *mongo.Client) *Server {
 		AllowMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
 	}))
 
-	// swagger
-	e.GET("/docs/*", echoSwagger.WrapHandler)
-
 	handler := handlers.NewHandler(cfg, dbClient)
 	middlewares := NewMiddleware(cfg, dbClient)
+	e.Use(middlewares.RequestID)
+	e.Use(middlewares.AuditActor)
+	e.Use(middlewares.AccessLog)
+	e.Use(middlewares.Tracing)
+	e.Use(middlewares.Metrics)
+	e.Use(middlewares.RateLimitIP)
+	e.Use(middlewares.Timeout)
+
+	// swagger
+	e.GET("/docs/*", echoSwagger.WrapHandler)
+	// prometheus
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler(queue.NewRedisQueue(cfg).Inspector()))
 
 	return &Server{
 		Server:      e,
 		Cfg:         cfg,
 		Handler:     handler,
 		Middlewares: middlewares,
+		GRPC:        NewGRPCServer(cfg, dbClient, middlewares),
+		Metrics: &http.Server{
+			Addr:              fmt.Sprintf(":%s", cfg.MetricsPort),
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// Returns the extractor of the client IPs, the X-Forwarded-For header is only trusted when sent by one of the
+// proxies, IPs or CIDR ranges, and the IP of the connection is used as is when no proxy is configured
+func ipExtractor(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if ip := net.ParseIP(proxy); ip != nil {
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			logrus.WithError(err).Warnf("ignoring invalid trusted proxy: %s", proxy)
+			continue
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
 	}
+	return echo.ExtractIPFromXFFHeader(options...)
 }
 
 func (s *Server) RegisterRoutes() {
@@ -77,12 +125,11 @@ func (s *Server) RegisterRoutes() {
 	InitAPIKeyRoutes(s)
 	InitBucketRoutes(s)
 	InitBucketItemRoutes(s)
+	InitAdminRoutes(s)
 	InitPublicRoutes(s)
 }
 
-// TODO: Add graceful shutdown
-
-// Start the server
+// Start the HTTP and gRPC servers, both are shut down gracefully on interrupt
 func (s *Server) Start() {
 	PORT := s.Cfg.Port
 
@@ -96,6 +143,16 @@ func (s *Server) Start() {
 			s.Server.Logger.Fatal("shutting down the server")
 		}
 	}()
+	go func() {
+		if err := s.GRPC.Start(); err != nil {
+			s.Server.Logger.Fatal("shutting down the gRPC server")
+		}
+	}()
+	go func() {
+		if err := s.Metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.Server.Logger.Fatal("shutting down the metrics server")
+		}
+	}()
 
 	// Wait for interrupt signal to gracefully shutdown the server with a timeout of 10 seconds.
 	// Use a buffered channel to avoid missing signals as recommended for signal.Notify
@@ -104,7 +161,16 @@ func (s *Server) Start() {
 	<-quit
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+	grpcStopped := make(chan struct{})
+	go func() {
+		s.GRPC.Shutdown(ctx)
+		close(grpcStopped)
+	}()
 	if err := s.Server.Shutdown(ctx); err != nil {
 		s.Server.Logger.Fatal(err)
 	}
+	if err := s.Metrics.Shutdown(ctx); err != nil {
+		s.Server.Logger.Fatal(err)
+	}
+	<-grpcStopped
 }
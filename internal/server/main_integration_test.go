@@ -2,12 +2,14 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"keeper/internal/auth/jwt"
 	"keeper/internal/config"
 	"keeper/internal/dto"
+	"keeper/internal/migrations"
 	"keeper/internal/models"
 	"keeper/internal/repository"
 	"keeper/internal/server/testdb"
@@ -56,6 +58,10 @@ func (s *ServerIntegrationTestSuite) SetupSuite() {
 		userRepo: repository.NewUserRepository(s.Cfg, s.DbConn.Client),
 	}
 	s.DbConn.CleanDB(s.Cfg.DbName)
+	// the tests run against the indexes and unique constraints of the server
+	if _, err := migrations.Run(context.Background(), s.DbConn.Client.Database(s.Cfg.DbName)); err != nil {
+		logrus.WithError(err).Fatal("failed to migrate the test database")
+	}
 }
 
 // this runs before each test in the suite is run
@@ -152,7 +158,7 @@ func (s *ServerIntegrationTestSuite) TestAuth_RegisterExistingUser() {
 	recorder := httptest.NewRecorder()
 	s.Server.Server.ServeHTTP(recorder, request)
 	// assert
-	assert.Equal(s.T(), http.StatusBadRequest, recorder.Code)
+	assert.Equal(s.T(), http.StatusConflict, recorder.Code)
 }
 
 // Test 'login user'
@@ -200,7 +206,7 @@ func (s *ServerIntegrationTestSuite) TestAuth_GetAuthUser() {
 	assert.Nil(s.T(), err)
 
 	// generate access token
-	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo)
+	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo, nil)
 	accessToken, err := jwtSvc.GenerateAccessToken(map[string]interface{}{"email": testUser.Email, "id": testUser.ID})
 	assert.Nil(s.T(), err)
 
@@ -226,17 +232,15 @@ func (s *ServerIntegrationTestSuite) TestAuth_RefreshToken() {
 	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
 	assert.Nil(s.T(), err)
 
-	// generate refresh token
-	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo)
-	refreshToken, err := jwtSvc.GenerateRefreshToken(map[string]interface{}{"email": testUser.Email, "id": testUser.ID})
-	assert.Nil(s.T(), err)
+	// log in to start a session
+	tokens := s.login(testUser.Email, "Secret12345!")
 
 	// arrange
 	// construct the endpoint
 	url := fmt.Sprintf("%s/auth/refresh-token", BASE_URL)
 	request, err := http.NewRequest(http.MethodPost, url, nil)
 
-	request.Header.Add("x-refresh-token", refreshToken)
+	request.Header.Add("x-refresh-token", tokens.RefreshToken)
 	assert.Nil(s.T(), err)
 
 	// act: test getting authenticated user
@@ -311,7 +315,7 @@ func (s *ServerIntegrationTestSuite) TestUser_UpdateUser() {
 	assert.Nil(s.T(), err)
 
 	// generate access token
-	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo)
+	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo, nil)
 	accessToken, err := jwtSvc.GenerateAccessToken(map[string]interface{}{"email": testUser.Email, "id": testUser.ID})
 	assert.Nil(s.T(), err)
 
@@ -338,7 +342,7 @@ func (s *ServerIntegrationTestSuite) TestUser_UpdateUser() {
 	assert.Equal(s.T(), http.StatusOK, recorder.Code)
 
 	// check the user
-	user, err := s.Repo.userRepo.FindUserByEmail(testUser.Email)
+	user, err := s.Repo.userRepo.FindUserByEmail(context.Background(), testUser.Email)
 	assert.Nil(s.T(), err)
 	assert.Equal(s.T(), user.Firstname, "updated-firstname")
 	assert.Equal(s.T(), user.Username, "updated-username")
@@ -350,7 +354,7 @@ func (s *ServerIntegrationTestSuite) TestUser_DeleteUser() {
 	assert.Nil(s.T(), err)
 
 	// generate access token
-	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo)
+	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo, nil)
 	accessToken, err := jwtSvc.GenerateAccessToken(map[string]interface{}{"email": testUser.Email, "id": testUser.ID})
 	assert.Nil(s.T(), err)
 
@@ -371,7 +375,7 @@ func (s *ServerIntegrationTestSuite) TestUser_DeleteUser() {
 	assert.Equal(s.T(), http.StatusOK, recorder.Code)
 
 	// check the user
-	_, err = s.Repo.userRepo.FindUserByEmail(testUser.Email)
+	_, err = s.Repo.userRepo.FindUserByEmail(context.Background(), testUser.Email)
 	assert.Equal(s.T(), err, models.ErrUserNotFound)
 }
 
@@ -381,7 +385,7 @@ func (s *ServerIntegrationTestSuite) TestAPIKey_Create() {
 	assert.Nil(s.T(), err)
 
 	// generate access token
-	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo)
+	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo, nil)
 	accessToken, err := jwtSvc.GenerateAccessToken(map[string]interface{}{"email": testUser.Email, "id": testUser.ID})
 	assert.Nil(s.T(), err)
 
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"fmt"
+	"keeper/internal/models"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that the metrics endpoint reports the requests and the operations of their bucket
+// only the existing buckets the request was allowed on are counted, and the endpoint is not on the API port
+func (s *ServerIntegrationTestSuite) TestMetrics_Endpoint() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/%s", BASE_URL, testBucket.UID), nil)
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+	s.Server.Server.ServeHTTP(httptest.NewRecorder(), request)
+	unknownBucket, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/%s", BASE_URL, "unknown-bucket"), nil)
+	unknownBucket.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+	s.Server.Server.ServeHTTP(httptest.NewRecorder(), unknownBucket)
+	unauthenticated, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/%s", BASE_URL, "spoofed-bucket"), nil)
+	s.Server.Server.ServeHTTP(httptest.NewRecorder(), unauthenticated)
+
+	// act
+	recorder := httptest.NewRecorder()
+	s.Server.Metrics.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	apiRecorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(apiRecorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// assert
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(s.T(), body, `kipa_http_requests_total{method="GET",route="/api/v1/bucket/:bucketUID",status="200"}`)
+	assert.Contains(s.T(), body, fmt.Sprintf(`kipa_bucket_operations_total{bucket="%s",operation="read"} 1`, testBucket.UID))
+	assert.NotContains(s.T(), body, "unknown-bucket")
+	assert.NotContains(s.T(), body, "spoofed-bucket")
+	assert.Contains(s.T(), body, "kipa_mongo_operation_duration_seconds_count")
+	assert.Equal(s.T(), http.StatusNotFound, apiRecorder.Code)
+}
@@ -1,63 +1,171 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"keeper/internal/auth"
 	"keeper/internal/auth/auth_realm"
 	"keeper/internal/config"
+	"keeper/internal/dto"
 	"keeper/internal/models"
+	"keeper/internal/pkg/compression"
+	"keeper/internal/pkg/metrics"
+	"keeper/internal/pkg/ratelimit"
+	"keeper/internal/pkg/tracing"
 	"keeper/internal/repository"
+	"keeper/internal/services"
 	"keeper/internal/utils"
+	"keeper/pkg/log"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/dchest/uniuri"
+	"github.com/go-redis/redis/v8"
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Middleware struct {
-	Cfg              *config.Config
-	UserRepository   repository.IUserRepository
-	ApiKeyRepository repository.IAPIKeyRepository
-	BucketRepository repository.IBucketRepository
+	Cfg               *config.Config
+	UserRepository    repository.IUserRepository
+	ApiKeyRepository  repository.IAPIKeyRepository
+	BucketRepository  repository.IBucketRepository
+	SessionRepository repository.ISessionRepository
+	AuditService      services.IAuditService
+	routeTimeouts     map[string]time.Duration
+	rateLimitStore    ratelimit.Store
+	rateLimits        map[string]ratelimit.Limit
+	ipRateLimit       *ratelimit.Limit // limit of the requests of an IP before they are authenticated, nil when disabled
 }
 
 var (
 	apiKeyPermissionsCtxKey = "apikey_permissions"
+	apiKeyRateLimitCtxKey   = "apikey_rate_limit"
 	credTypeCtxKey          = "cred_type"
+	sessionIDCtxKey         = "session_id"
+	bucketCtxKey            = "bucket"
 )
 
 func NewMiddleware(cfg *config.Config, dbClient *mongo.Client) *Middleware {
 	userRepository := repository.NewUserRepository(cfg, dbClient)
 	apiKeyRepository := repository.NewAPIKeyRepository(cfg, dbClient)
 	bucketRepository := repository.NewBucketRepository(cfg, dbClient)
+	sessionRepository := repository.NewSessionRepository(cfg, dbClient)
+	auditService := services.NewAuditService(cfg, repository.NewAuditEventRepository(cfg, dbClient), bucketRepository)
 	return &Middleware{
-		Cfg:              cfg,
-		UserRepository:   userRepository,
-		ApiKeyRepository: apiKeyRepository,
-		BucketRepository: bucketRepository,
+		Cfg:               cfg,
+		UserRepository:    userRepository,
+		ApiKeyRepository:  apiKeyRepository,
+		BucketRepository:  bucketRepository,
+		SessionRepository: sessionRepository,
+		AuditService:      auditService,
+		routeTimeouts:     parseRouteTimeouts(cfg.RouteTimeouts),
+		rateLimitStore:    newRateLimitStore(cfg),
+		rateLimits:        parseRateLimits(cfg.RateLimitDefault, cfg.RateLimitGroups),
+		ipRateLimit:       parseIPRateLimit(cfg.RateLimitIP),
 	}
 }
 
+// Returns the store of the rate limits, Redis shares the limits between the servers
+// and the memory store takes over when it is not configured or unreachable
+func newRateLimitStore(cfg *config.Config) ratelimit.Store {
+	memoryStore := ratelimit.NewMemoryStore()
+	if cfg.RateLimitBackend != "redis" || cfg.RedisHost == "" {
+		return memoryStore
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:        fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
+		DialTimeout: time.Second,
+	})
+	return ratelimit.NewFallbackStore(ratelimit.NewRedisStore(client), memoryStore)
+}
+
+// Parses the rate limits of the route groups, each one formatted as "group=requests/period",
+// e.g. "item=1200/1m", the default limit is stored under the empty group
+func parseRateLimits(defaultLimit string, entries []string) map[string]ratelimit.Limit {
+	limits := map[string]ratelimit.Limit{}
+	if limit, err := ratelimit.ParseLimit(defaultLimit); err == nil {
+		limits[""] = limit
+	} else {
+		logrus.Warnf("ignoring invalid default rate limit: %s", defaultLimit)
+	}
+	for _, entry := range entries {
+		group, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+		limit, err := ratelimit.ParseLimit(value)
+		if !found || err != nil {
+			logrus.Warnf("ignoring invalid rate limit: %s", entry)
+			continue
+		}
+		limits[strings.TrimSpace(group)] = limit
+	}
+	return limits
+}
+
+// Parses the rate limit of the IPs, an empty limit disables it
+func parseIPRateLimit(value string) *ratelimit.Limit {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		logrus.Warnf("ignoring invalid IP rate limit: %s", value)
+		return nil
+	}
+	return &limit
+}
+
+// Parses the route timeouts, each one formatted as "METHOD /route/path=seconds"
+// with the path of the route as registered, e.g. "POST /api/v1/item/:bucketUID/:key/binary=300"
+func parseRouteTimeouts(entries []string) map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	for _, entry := range entries {
+		route, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+		seconds, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || err != nil || seconds < 0 {
+			logrus.Warnf("ignoring invalid route timeout: %s", entry)
+			continue
+		}
+		method, path, _ := strings.Cut(strings.TrimSpace(route), " ")
+		timeouts[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = time.Duration(seconds) * time.Second
+	}
+	return timeouts
+}
+
 // middleware for protecting auth routes
 func (m *Middleware) RequireAuth(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		cred, err := getAuthFromRequest(c)
 		if err != nil {
-			logrus.WithError(err).Error("failed to get auth from request")
+			logrus.WithContext(c.Request().Context()).WithError(err).Error("failed to get auth from request")
+			metrics.IncAuthFailure(metrics.CredentialTypeInvalid)
 			return echo.ErrUnauthorized
 		}
-		authRealm := auth_realm.NewAuthRealm(m.Cfg, m.ApiKeyRepository, m.UserRepository)
-		authResponse, err := authRealm.Authenticate(cred)
+		authRealm := auth_realm.NewAuthRealm(m.Cfg, m.ApiKeyRepository, m.UserRepository, m.SessionRepository)
+		authResponse, err := authRealm.Authenticate(c.Request().Context(), cred)
 		if err != nil {
-			logrus.WithError(err).Errorf("error authenticating user with type: %s", cred.Type.String())
+			logrus.WithContext(c.Request().Context()).WithError(err).Errorf("error authenticating user with type: %s", cred.Type.String())
+			metrics.IncAuthFailure(cred.Type.String())
 			return echo.ErrUnauthorized
 		}
 		c.Set("user", authResponse.User)
 		c.Set(apiKeyPermissionsCtxKey, authResponse.Permissions)
+		c.Set(apiKeyRateLimitCtxKey, authResponse.RateLimit)
 		c.Set(credTypeCtxKey, cred.Type)
+		c.Set(sessionIDCtxKey, authResponse.SessionID)
+		ctx := m.withAuditActor(c.Request().Context(), authResponse.User, cred.Type, apiKeyMaskID(cred))
+		c.SetRequest(c.Request().WithContext(ctx))
 		return next(c)
 	}
 }
@@ -68,22 +176,34 @@ func (m *Middleware) RequireRefreshToken(next echo.HandlerFunc) echo.HandlerFunc
 		refreshToken := c.Request().Header.Get("x-refresh-token")
 		// validate the auth header structure
 		if len(strings.Split(refreshToken, ".")) != 3 {
-			return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-				"message": "invalid refresh token structure",
-			})
+			return models.NewAPIError(http.StatusUnauthorized, errors.New("invalid refresh token structure"))
 		}
 		cred := &auth.Credential{
 			Type: auth.CredentialTypeRefreshJWT,
 			JWT:  refreshToken,
 		}
-		authRealm := auth_realm.NewAuthRealm(m.Cfg, m.ApiKeyRepository, m.UserRepository)
-		authResponse, err := authRealm.Authenticate(cred)
+		authRealm := auth_realm.NewAuthRealm(m.Cfg, m.ApiKeyRepository, m.UserRepository, m.SessionRepository)
+		authResponse, err := authRealm.Authenticate(c.Request().Context(), cred)
 		if err != nil {
-			return c.JSON(http.StatusUnauthorized, map[string]interface{}{
-				"message": err.Error(),
-			})
+			metrics.IncAuthFailure(cred.Type.String())
+			return models.NewAPIError(http.StatusUnauthorized, err)
 		}
 		c.Set("user", authResponse.User)
+		c.Set(sessionIDCtxKey, authResponse.SessionID)
+		ctx := m.withAuditActor(c.Request().Context(), authResponse.User, cred.Type, "")
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// middleware for protecting admin routes, admin routes cannot be accessed with an api key
+func (m *Middleware) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, ok := c.Get("user").(*models.User)
+		credType := c.Get(credTypeCtxKey).(auth.CredentialType)
+		if !ok || !user.IsAdmin() || credType == auth.CredentialTypeAPIKey {
+			return models.NewAPIError(http.StatusForbidden, errors.New("admin access is required."))
+		}
 		return next(c)
 	}
 }
@@ -97,13 +217,10 @@ func (m *Middleware) RequireAPIKeyBucketWritePermission(next echo.HandlerFunc) e
 			// check if the api key permissions contains the permission to write bucket
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionWriteBucket)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to write to bucket, %s permission is required.",
-						models.APIKeyPermissionWriteBucket.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to write to bucket, %s permission is required.",
+					models.APIKeyPermissionWriteBucket.String(),
+				))
 			}
 		}
 		return next(c)
@@ -119,13 +236,10 @@ func (m *Middleware) RequireAPIKeyBucketReadPermission(next echo.HandlerFunc) ec
 			// check if the api key permissions contains the permission to read bucket
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionReadBucket)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to read bucket, %s permission is required.",
-						models.APIKeyPermissionReadBucket.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to read bucket, %s permission is required.",
+					models.APIKeyPermissionReadBucket.String(),
+				))
 			}
 		}
 		return next(c)
@@ -141,13 +255,10 @@ func (m *Middleware) RequireAPIKeyBucketDeletePermission(next echo.HandlerFunc)
 			// check if the api key permissions contains the permission to delete bucket
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionDeleteBucket)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to delete bucket, %s permission is required.",
-						models.APIKeyPermissionReadBucket.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to delete bucket, %s permission is required.",
+					models.APIKeyPermissionReadBucket.String(),
+				))
 			}
 		}
 		return next(c)
@@ -163,13 +274,10 @@ func (m *Middleware) RequireAPIKeyReadItemPermission(next echo.HandlerFunc) echo
 			// check if the api key permissions contains the permission to read item
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionReadItem)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to read bucket item, %s permission is required.",
-						models.APIKeyPermissionReadItem.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to read bucket item, %s permission is required.",
+					models.APIKeyPermissionReadItem.String(),
+				))
 			}
 		}
 		return next(c)
@@ -185,13 +293,10 @@ func (m *Middleware) RequireAPIKeyWriteItemPermission(next echo.HandlerFunc) ech
 			// check if the api key permissions contains the permission to write item
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionWriteItem)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to write to bucket item, %s permission is required.",
-						models.APIKeyPermissionWriteItem.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to write to bucket item, %s permission is required.",
+					models.APIKeyPermissionWriteItem.String(),
+				))
 			}
 		}
 		return next(c)
@@ -207,13 +312,10 @@ func (m *Middleware) RequireAPIKeyDeleteItemPermission(next echo.HandlerFunc) ec
 			// check if the api key permissions contains the permission to delete item
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionDeleteItem)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to delete bucket item, %s permission is required.",
-						models.APIKeyPermissionDeleteItem.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to delete bucket item, %s permission is required.",
+					models.APIKeyPermissionDeleteItem.String(),
+				))
 			}
 		}
 		return next(c)
@@ -229,13 +331,10 @@ func (m *Middleware) RequireAPIKeyReadUserPermission(next echo.HandlerFunc) echo
 			// check if the api key permissions contains the permission to read user
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionReadUser)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to read user, %s permission is required.",
-						models.APIKeyPermissionReadUser.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to read user, %s permission is required.",
+					models.APIKeyPermissionReadUser.String(),
+				))
 			}
 		}
 		return next(c)
@@ -251,13 +350,10 @@ func (m *Middleware) RequireAPIKeyWriteUserPermission(next echo.HandlerFunc) ech
 			// check if the api key permissions contains the permission to read user
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionWriteUser)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to write user, %s permission is required.",
-						models.APIKeyPermissionWriteUser.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to write user, %s permission is required.",
+					models.APIKeyPermissionWriteUser.String(),
+				))
 			}
 		}
 		return next(c)
@@ -273,13 +369,10 @@ func (m *Middleware) RequireAPIKeyDeleteUserPermission(next echo.HandlerFunc) ec
 			// check if the api key permissions contains the permission to read user
 			check := apiKeyPermissions.Contains(models.APIKeyPermissionDeleteUser)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to delete user, %s permission is required.",
-						models.APIKeyPermissionDeleteUser.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to delete user, %s permission is required.",
+					models.APIKeyPermissionDeleteUser.String(),
+				))
 			}
 		}
 		return next(c)
@@ -294,23 +387,17 @@ func (m *Middleware) RequireBucketWriteAccess(next echo.HandlerFunc) echo.Handle
 		// check if the auth header credential type is an API Key
 		if credType == auth.CredentialTypeAPIKey {
 			// fetch the bucket from the uid
-			bucket, err := m.BucketRepository.FindBucketByUID(bucketUID)
+			bucket, err := m.BucketRepository.FindBucketByUID(c.Request().Context(), bucketUID)
 			if err != nil {
-				logrus.WithError(err).Error("bucket does not exist")
-				return c.JSON(http.StatusBadRequest, models.ErrorResponse{
-					Status: false,
-					Error:  "bucket does not exist",
-				})
+				logrus.WithContext(c.Request().Context()).WithError(err).Error("bucket does not exist")
+				return models.NewAPIError(http.StatusNotFound, models.ErrBucketNotFound)
 			}
 			check := bucket.Permissions.Contains(models.BucketPermissionPublicWriteBucket)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to write to bucket, %s permission is required.",
-						models.BucketPermissionPublicWriteBucket.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to write to bucket, %s permission is required.",
+					models.BucketPermissionPublicWriteBucket.String(),
+				))
 			}
 		}
 		return next(c)
@@ -325,23 +412,17 @@ func (m *Middleware) RequireBucketReadAccess(next echo.HandlerFunc) echo.Handler
 		// check if the auth header credential type is an API Key
 		if credType == auth.CredentialTypeAPIKey {
 			// fetch the bucket from the uid
-			bucket, err := m.BucketRepository.FindBucketByUID(bucketUID)
+			bucket, err := m.BucketRepository.FindBucketByUID(c.Request().Context(), bucketUID)
 			if err != nil {
-				logrus.WithError(err).Error("bucket does not exist")
-				return c.JSON(http.StatusBadRequest, models.ErrorResponse{
-					Status: false,
-					Error:  "bucket does not exist",
-				})
+				logrus.WithContext(c.Request().Context()).WithError(err).Error("bucket does not exist")
+				return models.NewAPIError(http.StatusNotFound, models.ErrBucketNotFound)
 			}
 			check := bucket.Permissions.Contains(models.BucketPermissionPublicReadBucket)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to read bucket, %s permission is required.",
-						models.BucketPermissionPublicReadBucket.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to read bucket, %s permission is required.",
+					models.BucketPermissionPublicReadBucket.String(),
+				))
 			}
 		}
 		return next(c)
@@ -356,23 +437,17 @@ func (m *Middleware) RequireBucketDeleteAccess(next echo.HandlerFunc) echo.Handl
 		// check if the auth header credential type is an API Key
 		if credType == auth.CredentialTypeAPIKey {
 			// fetch the bucket from the uid
-			bucket, err := m.BucketRepository.FindBucketByUID(bucketUID)
+			bucket, err := m.BucketRepository.FindBucketByUID(c.Request().Context(), bucketUID)
 			if err != nil {
-				logrus.WithError(err).Error("bucket does not exist")
-				return c.JSON(http.StatusBadRequest, models.ErrorResponse{
-					Status: false,
-					Error:  "bucket does not exist",
-				})
+				logrus.WithContext(c.Request().Context()).WithError(err).Error("bucket does not exist")
+				return models.NewAPIError(http.StatusNotFound, models.ErrBucketNotFound)
 			}
 			check := bucket.Permissions.Contains(models.BucketPermissionPublicDeleteBucket)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to delete bucket, %s permission is required.",
-						models.BucketPermissionPublicDeleteBucket.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to delete bucket, %s permission is required.",
+					models.BucketPermissionPublicDeleteBucket.String(),
+				))
 			}
 		}
 		return next(c)
@@ -387,23 +462,17 @@ func (m *Middleware) RequireBucketItemWriteAccess(next echo.HandlerFunc) echo.Ha
 		// check if the auth header credential type is an API Key
 		if credType == auth.CredentialTypeAPIKey {
 			// fetch the bucket from the uid
-			bucket, err := m.BucketRepository.FindBucketByUID(bucketUID)
+			bucket, err := m.BucketRepository.FindBucketByUID(c.Request().Context(), bucketUID)
 			if err != nil {
-				logrus.WithError(err).Error("bucket does not exist")
-				return c.JSON(http.StatusBadRequest, models.ErrorResponse{
-					Status: false,
-					Error:  "bucket does not exist",
-				})
+				logrus.WithContext(c.Request().Context()).WithError(err).Error("bucket does not exist")
+				return models.NewAPIError(http.StatusNotFound, models.ErrBucketNotFound)
 			}
 			check := bucket.Permissions.Contains(models.BucketPermissionPublicWriteItem)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to write bucket item, %s permission is required.",
-						models.BucketPermissionPublicWriteItem.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to write bucket item, %s permission is required.",
+					models.BucketPermissionPublicWriteItem.String(),
+				))
 			}
 		}
 		return next(c)
@@ -413,28 +482,22 @@ func (m *Middleware) RequireBucketItemWriteAccess(next echo.HandlerFunc) echo.Ha
 // Middleware for protecting bucket item read access
 func (m *Middleware) RequireBucketItemReadAccess(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		bucketUID := c.Param("bucketUID")
+		bucketUID := bucketUIDFromRequest(c)
 		credType := c.Get(credTypeCtxKey).(auth.CredentialType)
 		// check if the auth header credential type is an API Key
 		if credType == auth.CredentialTypeAPIKey {
 			// fetch the bucket from the uid
-			bucket, err := m.BucketRepository.FindBucketByUID(bucketUID)
+			bucket, err := m.BucketRepository.FindBucketByUID(c.Request().Context(), bucketUID)
 			if err != nil {
-				logrus.WithError(err).Error("bucket does not exist")
-				return c.JSON(http.StatusBadRequest, models.ErrorResponse{
-					Status: false,
-					Error:  "bucket does not exist",
-				})
+				logrus.WithContext(c.Request().Context()).WithError(err).Error("bucket does not exist")
+				return models.NewAPIError(http.StatusNotFound, models.ErrBucketNotFound)
 			}
 			check := bucket.Permissions.Contains(models.BucketPermissionPublicReadItem)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to read bucket item, %s permission is required.",
-						models.BucketPermissionPublicReadItem.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to read bucket item, %s permission is required.",
+					models.BucketPermissionPublicReadItem.String(),
+				))
 			}
 		}
 		return next(c)
@@ -449,32 +512,456 @@ func (m *Middleware) RequireBucketItemDeleteAccess(next echo.HandlerFunc) echo.H
 		// check if the auth header credential type is an API Key
 		if credType == auth.CredentialTypeAPIKey {
 			// fetch the bucket from the uid
-			bucket, err := m.BucketRepository.FindBucketByUID(bucketUID)
+			bucket, err := m.BucketRepository.FindBucketByUID(c.Request().Context(), bucketUID)
 			if err != nil {
-				logrus.WithError(err).Error("bucket does not exist")
-				return c.JSON(http.StatusBadRequest, models.ErrorResponse{
-					Status: false,
-					Error:  "bucket does not exist",
-				})
+				logrus.WithContext(c.Request().Context()).WithError(err).Error("bucket does not exist")
+				return models.NewAPIError(http.StatusNotFound, models.ErrBucketNotFound)
 			}
 			check := bucket.Permissions.Contains(models.BucketPermissionPublicDeleteItem)
 			if !check {
-				return c.JSON(http.StatusForbidden, models.ErrorResponse{
-					Status: false,
-					Error: fmt.Sprintf(
-						"unable to delete bucket item, %s permission is required.",
-						models.BucketPermissionPublicDeleteItem.String(),
-					),
-				})
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to delete bucket item, %s permission is required.",
+					models.BucketPermissionPublicDeleteItem.String(),
+				))
 			}
 		}
 		return next(c)
 	}
 }
 
+// Middleware for protecting the item read access of the bucket compared to by a diff,
+// it is checked as the bucket of the path is
+func (m *Middleware) RequireDiffBucketItemReadAccess(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if err := m.checkBucketItemReadAccess(c, c.QueryParam("to")); err != nil {
+			return err
+		}
+		return next(c)
+	}
+}
+
+// Middleware for protecting a merge, the source bucket needs the item read access
+// and the merges replacing or trashing the items of the target need its item delete access
+func (m *Middleware) RequireMergeBucketAccess(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		data := dto.MergeBucketInputDTO{}
+		if err := peekBody(c, &data); err != nil {
+			return err
+		}
+		source := data.SourceBucketUID
+		if source == "" {
+			source = c.Param("bucketUID")
+		}
+		if err := m.checkBucketItemReadAccess(c, source); err != nil {
+			return err
+		}
+		if data.Strategy == models.MergeStrategyOverwrite || data.DeleteMissing {
+			return m.RequireBucketItemDeleteAccess(m.RequireAPIKeyDeleteItemPermission(next))(c)
+		}
+		return next(c)
+	}
+}
+
+// Checks the item read access of a bucket read by a request besides the bucket of its path,
+// with the bucket permissions required from the api keys and the two-factor authentication required by the bucket
+func (m *Middleware) checkBucketItemReadAccess(c echo.Context, bucketUID string) error {
+	if bucketUID == "" {
+		return nil
+	}
+	bucket, err := m.BucketRepository.FindBucketByUID(c.Request().Context(), bucketUID)
+	if err != nil {
+		logrus.WithContext(c.Request().Context()).WithError(err).Error("bucket does not exist")
+		return models.NewAPIError(http.StatusNotFound, models.ErrBucketNotFound)
+	}
+	if credType := c.Get(credTypeCtxKey).(auth.CredentialType); credType == auth.CredentialTypeAPIKey {
+		for _, permission := range []models.BucketPermission{models.BucketPermissionPublicReadBucket, models.BucketPermissionPublicReadItem} {
+			if !bucket.Permissions.Contains(permission) {
+				return models.NewAPIError(http.StatusForbidden, fmt.Errorf(
+					"unable to read bucket item, %s permission is required.",
+					permission.String(),
+				))
+			}
+		}
+	}
+	if user, ok := c.Get("user").(*models.User); ok && bucketTwoFactorDenied(bucket, user, false) {
+		return models.NewAPIError(http.StatusForbidden, models.ErrTwoFactorRequired)
+	}
+	return nil
+}
+
+// Binds the body of a request without consuming it, the handler binds it again
+func peekBody(c echo.Context, data interface{}) error {
+	req := c.Request()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	err = (&echo.DefaultBinder{}).BindBody(c, data)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return err
+}
+
+// Middleware requiring two-factor authentication on the buckets that require it
+// the owner needs it to access the bucket at all, the other users to change it
+// the bucket found is kept in the context, so that only the existing buckets are counted in the metrics
+func (m *Middleware) RequireBucketTwoFactor(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		bucketUID := bucketUIDFromRequest(c)
+		user, ok := c.Get("user").(*models.User)
+		if bucketUID == "" || !ok {
+			return next(c)
+		}
+		bucket, err := m.BucketRepository.FindBucketByUID(c.Request().Context(), bucketUID)
+		// a missing bucket is reported by the handlers
+		if err != nil {
+			return next(c)
+		}
+		c.Set(bucketCtxKey, bucket)
+		method := c.Request().Method
+		if bucketTwoFactorDenied(bucket, user, method != http.MethodGet && method != http.MethodHead) {
+			return models.NewAPIError(http.StatusForbidden, models.ErrTwoFactorRequired)
+		}
+		return next(c)
+	}
+}
+
+// Returns the bucket UID of the path, or of the bucket_uid query of the paged listings
+func bucketUIDFromRequest(c echo.Context) string {
+	if bucketUID := c.Param("bucketUID"); bucketUID != "" {
+		return bucketUID
+	}
+	return c.QueryParam("bucket_uid")
+}
+
+// Reports whether a bucket requiring two-factor authentication denies a user without it,
+// the owner is denied any access and the other users are denied the writes
+func bucketTwoFactorDenied(bucket *models.Bucket, user *models.User, write bool) bool {
+	if !bucket.RequireTwoFactor || user.TwoFactorEnabled {
+		return false
+	}
+	return bucket.UserID == user.ID || write
+}
+
+// Middleware for compressing responses with the encoding negotiated from the Accept-Encoding header
+func (m *Middleware) CompressResponse(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		res := c.Response()
+		res.Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		algorithm := compression.Negotiate(c.Request().Header.Get(echo.HeaderAcceptEncoding))
+		if algorithm == "" {
+			return next(c)
+		}
+		w := &compressResponseWriter{ResponseWriter: res.Writer, algorithm: algorithm}
+		res.Writer = w
+		defer func() {
+			if err := w.Close(); err != nil {
+				logrus.WithContext(c.Request().Context()).WithError(err).Error("error closing compressed response writer")
+			}
+			res.Writer = w.ResponseWriter
+		}()
+		return next(c)
+	}
+}
+
+// Middleware for bounding the time spent on a request, the deadline is set on the request context
+// passed down to the services and repositories, a timeout of 0 disables it
+func (m *Middleware) Timeout(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		timeout, ok := m.routeTimeouts[c.Request().Method+" "+c.Path()]
+		if !ok {
+			timeout = time.Duration(m.Cfg.RequestTimeoutSeconds) * time.Second
+		}
+		if timeout <= 0 {
+			return next(c)
+		}
+		ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+		defer cancel()
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// Middleware for rate limiting the requests of a route group with token buckets, it is placed after the
+// auth middlewares so that the requests are counted by api key, then by user, then by IP for the public routes
+// the rate limit of an api key replaces the limit of the group
+func (m *Middleware) RateLimit(group string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			override, _ := c.Get(apiKeyRateLimitCtxKey).(*models.APIKeyRateLimit)
+			limit, ok := m.rateLimitFor(group, override)
+			if !ok {
+				return next(c)
+			}
+			ctx := c.Request().Context()
+			key := rateLimitKey(services.AuditActorFromContext(ctx), c.RealIP())
+			if err := m.takeRateLimit(ctx, group+":"+key, limit, c.Response().Header()); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// Middleware for rate limiting the requests by IP before they are authenticated,
+// so that the requests with invalid credentials are limited too
+func (m *Middleware) RateLimitIP(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !m.Cfg.RateLimitEnabled || m.ipRateLimit == nil {
+			return next(c)
+		}
+		if err := m.takeRateLimit(c.Request().Context(), "ip:"+c.RealIP(), *m.ipRateLimit, c.Response().Header()); err != nil {
+			return err
+		}
+		return next(c)
+	}
+}
+
+// Returns the rate limit of a route group, the rate limit of an api key replaces it when it is lower,
+// so that the owner of a key cannot raise its own limit above the one of the group
+func (m *Middleware) rateLimitFor(group string, override *models.APIKeyRateLimit) (ratelimit.Limit, bool) {
+	if !m.Cfg.RateLimitEnabled {
+		return ratelimit.Limit{}, false
+	}
+	limit, ok := m.rateLimits[group]
+	if !ok {
+		limit, ok = m.rateLimits[""]
+	}
+	if override == nil || override.Requests <= 0 || override.PeriodSeconds <= 0 {
+		return limit, ok
+	}
+	keyLimit := ratelimit.Limit{Requests: override.Requests, Period: time.Duration(override.PeriodSeconds) * time.Second}
+	// neither the burst nor the rate of the key may exceed the ones of the group
+	if ok && (keyLimit.Requests > limit.Requests || int64(keyLimit.Requests)*int64(limit.Period) > int64(limit.Requests)*int64(keyLimit.Period)) {
+		return limit, true
+	}
+	return keyLimit, true
+}
+
+// Takes a token of the bucket of key and sets the rate limit headers,
+// returns the too many requests error once the bucket is empty
+func (m *Middleware) takeRateLimit(ctx context.Context, key string, limit ratelimit.Limit, header http.Header) error {
+	result, err := m.rateLimitStore.Take(ctx, key, limit)
+	if err != nil {
+		// the requests are let through rather than failing with the store
+		logrus.WithContext(ctx).WithError(err).Error("error taking rate limit token")
+		return nil
+	}
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	if !result.Allowed {
+		header.Set(echo.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		return models.NewAPIError(http.StatusTooManyRequests, models.ErrRateLimitExceeded)
+	}
+	return nil
+}
+
+// Returns the key of the token bucket of a request, the api key, user or IP it comes from
+func rateLimitKey(actor models.AuditActor, ip string) string {
+	if actor.APIKeyMaskID != "" {
+		return "api_key:" + actor.APIKeyMaskID
+	}
+	if !actor.UserID.IsZero() {
+		return "user:" + actor.UserID.Hex()
+	}
+	return "ip:" + ip
+}
+
+// Returns a duration in whole seconds, rounded up
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// accepted format of the request IDs sent by the clients, the other ones are replaced
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Returns the request ID sent by a client if it is valid, a new one otherwise
+func requestID(sent string) string {
+	if requestIDPattern.MatchString(sent) {
+		return sent
+	}
+	return uniuri.NewLen(32)
+}
+
+// Middleware for identifying the requests with the X-Request-ID header sent by the client or a generated one,
+// the ID is returned in the response and tags the logs and the tasks of the request
+func (m *Middleware) RequestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := requestID(c.Request().Header.Get(echo.HeaderXRequestID))
+		c.Response().Header().Set(echo.HeaderXRequestID, id)
+		c.SetRequest(c.Request().WithContext(log.WithRequestID(c.Request().Context(), id)))
+		return next(c)
+	}
+}
+
+// Middleware for attributing the audit events of the requests to their client,
+// the authenticated user is added once the credential of the request is checked
+// the IP is the one of the connection, or the one forwarded by a trusted proxy, as it also counts the failed logins
+func (m *Middleware) AuditActor(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ctx := services.WithAuditActor(c.Request().Context(), models.AuditActor{
+			IP:        c.RealIP(),
+			UserAgent: c.Request().UserAgent(),
+		})
+		c.SetRequest(c.Request().WithContext(ctx))
+		return next(c)
+	}
+}
+
+// Returns ctx with its audit actor set to an authenticated user, the uses of an api key are recorded in the audit log
+func (m *Middleware) withAuditActor(ctx context.Context, user *models.User, credType auth.CredentialType, maskID string) context.Context {
+	actor := services.AuditActorFromContext(ctx)
+	actor.UserID = user.ID
+	actor.CredentialType = credType.String()
+	actor.APIKeyMaskID = maskID
+	ctx = services.WithAuditActor(ctx, actor)
+	if credType == auth.CredentialTypeAPIKey {
+		m.AuditService.RecordAuditEvent(ctx, &models.AuditEvent{Action: models.AuditActionAPIKeyUse})
+	}
+	return ctx
+}
+
+// Returns the mask ID of an api key credential, the public part of the key, empty for the other credentials
+func apiKeyMaskID(cred *auth.Credential) string {
+	if cred.Type != auth.CredentialTypeAPIKey {
+		return ""
+	}
+	parts := strings.Split(cred.APIKey, utils.APIKeySeperator)
+	if len(parts) != 3 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Middleware for logging the requests as JSON, the query strings are left out as they may hold tokens
+func (m *Middleware) AccessLog(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+		if err != nil {
+			// renders the error so that its status is the one logged
+			c.Error(err)
+		}
+		req := c.Request()
+		res := c.Response()
+		logrus.WithContext(req.Context()).WithFields(logrus.Fields{
+			"method":     req.Method,
+			"route":      c.Path(),
+			"path":       req.URL.Path,
+			"status":     res.Status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"bytes_in":   req.ContentLength,
+			"bytes_out":  res.Size,
+			"remote_ip":  c.RealIP(),
+			"user_agent": req.UserAgent(),
+		}).Info("request")
+		return err
+	}
+}
+
+// Middleware for tracing the requests, the span continues the trace context of the request headers
+// and is the parent of the spans of the services and repositories
+func (m *Middleware) Tracing(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		route := c.Path()
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := tracing.Start(ctx, req.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(req.Method),
+				semconv.HTTPRoute(route),
+				semconv.HTTPTarget(req.URL.Path),
+			),
+		)
+		defer span.End()
+		c.SetRequest(req.WithContext(ctx))
+
+		err := next(c)
+		if err != nil {
+			// renders the error so that its status is the one recorded
+			c.Error(err)
+		}
+		status := c.Response().Status
+		span.SetAttributes(semconv.HTTPStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		return err
+	}
+}
+
+// Middleware for recording the latency and status of the requests, and the operations of the bucket they were allowed on
+func (m *Middleware) Metrics(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+		if err != nil {
+			// renders the error so that its status is the one recorded
+			c.Error(err)
+		}
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request().Method, route, c.Response().Status, time.Since(start))
+		// the bucket label is only given the existing buckets the request was allowed to operate on,
+		// so that the UIDs sent by unauthenticated or unauthorized clients never create series
+		if bucket, ok := c.Get(bucketCtxKey).(*models.Bucket); ok && c.Response().Status < http.StatusBadRequest {
+			metrics.IncBucketOperation(bucket.UID, metrics.BucketOperation(c.Request().Method))
+		}
+		return err
+	}
+}
+
+// compresses the response body, the encoder is only created once a body is written
+// so that empty responses are not given a compression frame
+type compressResponseWriter struct {
+	http.ResponseWriter
+	algorithm string
+	encoder   io.WriteCloser
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if code != http.StatusNoContent && code != http.StatusNotModified {
+		w.Header().Del(echo.HeaderContentLength)
+		w.Header().Set(echo.HeaderContentEncoding, w.algorithm)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.encoder == nil {
+		if w.Header().Get(echo.HeaderContentType) == "" {
+			w.Header().Set(echo.HeaderContentType, http.DetectContentType(b))
+		}
+		encoder, err := compression.NewWriter(w.algorithm, w.ResponseWriter)
+		if err != nil {
+			return 0, err
+		}
+		w.encoder = encoder
+	}
+	return w.encoder.Write(b)
+}
+
+func (w *compressResponseWriter) Close() error {
+	if w.encoder == nil {
+		return nil
+	}
+	return w.encoder.Close()
+}
+
 // Returns the auth credential type and details from the request
 func getAuthFromRequest(c echo.Context) (*auth.Credential, error) {
-	authHeader := c.Request().Header.Get("Authorization")
+	return parseAuthHeader(c.Request().Header.Get("Authorization"))
+}
+
+// Returns the auth credential type and details from an authorization header value
+func parseAuthHeader(authHeader string) (*auth.Credential, error) {
 	// split the auth header
 	authInfo := strings.Split(authHeader, " ")
 	// validate the auth header structure
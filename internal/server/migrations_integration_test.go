@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"keeper/internal/migrations"
+	"keeper/internal/models"
+	"keeper/internal/repository"
+	"keeper/internal/server/testdb"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Test that the applied migrations are recorded and not applied again
+func (s *ServerIntegrationTestSuite) TestMigrations_AppliedOnce() {
+	db := s.DbConn.Client.Database(s.Cfg.DbName)
+
+	// act
+	applied, err := migrations.Run(context.Background(), db)
+	assert.Nil(s.T(), err)
+	statuses, statusErr := migrations.Status(context.Background(), db)
+
+	// assert
+	assert.Empty(s.T(), applied)
+	assert.Nil(s.T(), statusErr)
+	assert.Len(s.T(), statuses, len(migrations.All()))
+	for _, status := range statuses {
+		assert.True(s.T(), status.Applied)
+	}
+}
+
+// Test that a bucket item key cannot be stored twice, while a trashed item keeps its key
+func (s *ServerIntegrationTestSuite) TestMigrations_UniqueBucketItemKey() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucketItem, err := testdb.SeedBucketItem(s.DbConn.Client, s.Cfg, testUser.ID, testBucket.ID, testBucket.UID, "string")
+	assert.Nil(s.T(), err)
+	bucketItemRepo := repository.NewBucketItemRepository(s.Cfg, s.DbConn.Client)
+	newBucketItem := func() *models.BucketItem {
+		return &models.BucketItem{
+			BucketID:  testBucket.ID,
+			BucketUID: testBucket.UID,
+			Key:       testBucketItem.Key,
+			Data:      "duplicate",
+			Type:      "string",
+			CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		}
+	}
+
+	// act
+	_, duplicateErr := bucketItemRepo.CreateBucketItem(context.Background(), newBucketItem())
+	trashErr := bucketItemRepo.TrashBucketItemByKeyName(context.Background(), testBucket.UID, testBucketItem.Key, primitive.NewDateTimeFromTime(time.Now()))
+	_, recreateErr := bucketItemRepo.CreateBucketItem(context.Background(), newBucketItem())
+
+	// assert
+	assert.NotNil(s.T(), duplicateErr)
+	assert.Nil(s.T(), trashErr)
+	assert.Nil(s.T(), recreateErr)
+}
+
+// Test that the users sharing an email and the buckets sharing a uid are set apart before the unique indexes are built
+func (s *ServerIntegrationTestSuite) TestMigrations_DuplicatesSetApart() {
+	db := s.DbConn.Client.Database(fmt.Sprintf("%s_duplicates", s.Cfg.DbName))
+	defer db.Drop(context.Background())
+	ctx := context.Background()
+	users := []interface{}{
+		bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "email", Value: "ada@example.com"}},
+		bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "email", Value: "ada@example.com"}},
+	}
+	older, newer := primitive.NewObjectID(), primitive.NewObjectID()
+	buckets := []interface{}{
+		bson.D{{Key: "_id", Value: older}, {Key: "uid", Value: "shared"}},
+		bson.D{{Key: "_id", Value: newer}, {Key: "uid", Value: "shared"}},
+	}
+	items := []interface{}{
+		bson.D{{Key: "bucket_id", Value: older}, {Key: "bucket_uid", Value: "shared"}, {Key: "key", Value: "retries"}},
+		bson.D{{Key: "bucket_id", Value: newer}, {Key: "bucket_uid", Value: "shared"}, {Key: "key", Value: "retries"}},
+	}
+	_, err := db.Collection("users").InsertMany(ctx, users)
+	assert.Nil(s.T(), err)
+	_, err = db.Collection("buckets").InsertMany(ctx, buckets)
+	assert.Nil(s.T(), err)
+	_, err = db.Collection("bucketitems").InsertMany(ctx, items)
+	assert.Nil(s.T(), err)
+
+	// act
+	_, err = migrations.Run(ctx, db)
+
+	// assert
+	assert.Nil(s.T(), err)
+	emails, err := db.Collection("users").Distinct(ctx, "email", bson.D{})
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), emails, 2)
+	assert.Contains(s.T(), emails, "ada@example.com")
+	var kept, renamed models.Bucket
+	assert.Nil(s.T(), db.Collection("buckets").FindOne(ctx, bson.D{{Key: "_id", Value: older}}).Decode(&kept))
+	assert.Nil(s.T(), db.Collection("buckets").FindOne(ctx, bson.D{{Key: "_id", Value: newer}}).Decode(&renamed))
+	assert.Equal(s.T(), "shared", kept.UID)
+	assert.NotEqual(s.T(), "shared", renamed.UID)
+	// the items follow their bucket
+	var item models.BucketItem
+	assert.Nil(s.T(), db.Collection("bucketitems").FindOne(ctx, bson.D{{Key: "bucket_id", Value: newer}}).Decode(&item))
+	assert.Equal(s.T(), renamed.UID, item.BucketUID)
+}
+
+// Test that a server waits for the migration lock, and takes over a lock whose lease ran out
+func (s *ServerIntegrationTestSuite) TestMigrations_Lock() {
+	db := s.DbConn.Client.Database(s.Cfg.DbName)
+	ctx := context.Background()
+	locks := db.Collection("migrationlocks")
+	_, err := locks.InsertOne(ctx, bson.D{
+		{Key: "_id", Value: "migrations"},
+		{Key: "owner", Value: "another server"},
+		{Key: "expires_at", Value: primitive.NewDateTimeFromTime(time.Now().Add(time.Minute))},
+	})
+	assert.Nil(s.T(), err)
+
+	// act
+	waitCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	_, waitErr := migrations.Run(waitCtx, db)
+	_, err = locks.UpdateOne(ctx, bson.D{{Key: "_id", Value: "migrations"}}, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "expires_at", Value: primitive.NewDateTimeFromTime(time.Now().Add(-time.Second))},
+	}}})
+	assert.Nil(s.T(), err)
+	_, takeOverErr := migrations.Run(ctx, db)
+
+	// assert
+	assert.ErrorIs(s.T(), waitErr, context.DeadlineExceeded)
+	assert.Nil(s.T(), takeOverErr)
+	// the lock is released once the migrations are done
+	count, err := locks.CountDocuments(ctx, bson.D{})
+	assert.Nil(s.T(), err)
+	assert.Zero(s.T(), count)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"keeper/internal/auth/jwt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Sends a request and decodes the problem of the response
+func (s *ServerIntegrationTestSuite) sendForProblem(request *http.Request) (*httptest.ResponseRecorder, models.Problem) {
+	recorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, request)
+	problem := models.Problem{}
+	json.Unmarshal(recorder.Body.Bytes(), &problem)
+	assert.Equal(s.T(), models.MIMEProblemJSON, recorder.Header().Get("Content-Type"))
+	assert.Equal(s.T(), recorder.Code, problem.Status)
+	return recorder, problem
+}
+
+// Test that a validation failure reports every invalid field
+func (s *ServerIntegrationTestSuite) TestProblem_ValidationFailed() {
+	url := fmt.Sprintf("%s/auth/register", BASE_URL)
+	body, err := json.Marshal(&dto.CreateUserInputDTO{
+		Firstname: "Problem",
+		Lastname:  "User",
+		Email:     "not an email",
+		Password:  "secret",
+	})
+	assert.Nil(s.T(), err)
+	request, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	request.Header.Add("Content-Type", "application/json")
+
+	// act
+	recorder, problem := s.sendForProblem(request)
+
+	// assert
+	assert.Equal(s.T(), http.StatusBadRequest, recorder.Code)
+	assert.Equal(s.T(), models.CodeValidationFailed, problem.Code)
+	assert.Equal(s.T(), "/api/v1/auth/register", problem.Instance)
+	assert.Len(s.T(), problem.Errors, 2)
+	assert.Equal(s.T(), "email", problem.Errors[0].Field)
+	assert.Equal(s.T(), "password", problem.Errors[1].Field)
+}
+
+// Test that a missing bucket is reported the same way by the middlewares and the handlers
+func (s *ServerIntegrationTestSuite) TestProblem_BucketNotFound() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	jwtSvc := jwt.NewJwtService(s.Cfg, s.Repo.userRepo, nil)
+	accessToken, err := jwtSvc.GenerateAccessToken(map[string]interface{}{"email": testUser.Email, "id": testUser.ID})
+	assert.Nil(s.T(), err)
+	url := fmt.Sprintf("%s/bucket/missing-bucket", BASE_URL)
+
+	// the bucket access middleware checks the buckets of the API keys,
+	// the handler the ones of the access tokens
+	for _, credential := range []string{key, accessToken} {
+		request, _ := http.NewRequest(http.MethodGet, url, nil)
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", credential))
+
+		// act
+		recorder, problem := s.sendForProblem(request)
+
+		// assert
+		assert.Equal(s.T(), http.StatusNotFound, recorder.Code)
+		assert.Equal(s.T(), models.ErrorCode("bucket_not_found"), problem.Code)
+		assert.Equal(s.T(), models.ErrBucketNotFound.Error(), problem.Detail)
+	}
+}
+
+// Test that the invalid requests rejected by the services are bad requests, the other errors being server errors
+func (s *ServerIntegrationTestSuite) TestProblem_BadRequest() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucketItem, err := testdb.SeedBucketItem(s.DbConn.Client, s.Cfg, testUser.ID, testBucket.ID, testBucket.UID, "string")
+	assert.Nil(s.T(), err)
+	invalidQuery, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/items/%s?size[gt]=large", BASE_URL, testBucket.UID), nil)
+	nonNumericIncrement, _ := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/item/%s/%s", BASE_URL, testBucket.UID, testBucketItem.Key), strings.NewReader("+5"))
+
+	for _, request := range []*http.Request{invalidQuery, nonNumericIncrement} {
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+
+		// act
+		recorder, problem := s.sendForProblem(request)
+
+		// assert
+		assert.Equal(s.T(), http.StatusBadRequest, recorder.Code)
+		assert.Equal(s.T(), models.ErrorCode("bad_request"), problem.Code)
+		assert.NotEmpty(s.T(), problem.Detail)
+	}
+}
+
+// Test that the errors of echo itself are problems too
+func (s *ServerIntegrationTestSuite) TestProblem_Unauthorized() {
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/any-bucket", BASE_URL), nil)
+
+	// act
+	recorder, problem := s.sendForProblem(request)
+
+	// assert
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("unauthorized"), problem.Code)
+}
+
+// Test that the detail of a server error does not disclose its error
+func (s *ServerIntegrationTestSuite) TestProblem_InternalErrorDetail() {
+	path := "/test/internal-error"
+	s.Server.Server.GET(path, func(c echo.Context) error {
+		return errors.New("connection to mongo-0.internal:27017 refused")
+	})
+	request, _ := http.NewRequest(http.MethodGet, path, nil)
+
+	// act
+	recorder, problem := s.sendForProblem(request)
+
+	// assert
+	assert.Equal(s.T(), http.StatusInternalServerError, recorder.Code)
+	assert.Equal(s.T(), models.CodeInternal, problem.Code)
+	assert.NotEmpty(s.T(), problem.Detail)
+	assert.NotContains(s.T(), problem.Detail, "mongo")
+}
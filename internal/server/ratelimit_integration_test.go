@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"keeper/internal/models"
+	"keeper/internal/pkg/ratelimit"
+	"keeper/internal/repository"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that the requests of a route group are rejected once its limit is reached
+func (s *ServerIntegrationTestSuite) TestRateLimit_RouteGroupLimit() {
+	s.Server.Middlewares.rateLimitStore = ratelimit.NewMemoryStore()
+	s.Server.Middlewares.rateLimits["public"] = ratelimit.Limit{Requests: 1, Period: time.Minute}
+	defer delete(s.Server.Middlewares.rateLimits, "public")
+
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/public/healthcheck", BASE_URL), nil)
+	recorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, request)
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	assert.Equal(s.T(), "1", recorder.Header().Get("RateLimit-Limit"))
+	assert.Equal(s.T(), "0", recorder.Header().Get("RateLimit-Remaining"))
+
+	// act
+	request, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/public/healthcheck", BASE_URL), nil)
+	recorder, problem := s.sendForProblem(request)
+
+	// assert
+	assert.Equal(s.T(), http.StatusTooManyRequests, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("rate_limit_exceeded"), problem.Code)
+	assert.Equal(s.T(), "60", recorder.Header().Get("Retry-After"))
+}
+
+// Test that the rate limit of an api key replaces the limit of the route group
+func (s *ServerIntegrationTestSuite) TestRateLimit_APIKeyLimit() {
+	s.Server.Middlewares.rateLimitStore = ratelimit.NewMemoryStore()
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	apiKey, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	apiKey.RateLimit = &models.APIKeyRateLimit{Requests: 1, PeriodSeconds: 30}
+	err = repository.NewAPIKeyRepository(s.Cfg, s.DbConn.Client).UpdateAPIKey(context.Background(), apiKey)
+	assert.Nil(s.T(), err)
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/%s", BASE_URL, testBucket.UID), nil)
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+		recorder := httptest.NewRecorder()
+		s.Server.Server.ServeHTTP(recorder, request)
+		codes = append(codes, recorder.Code)
+	}
+
+	// assert
+	assert.Equal(s.T(), []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+// Test that the rate limit of an api key cannot raise the limit of the route group
+func (s *ServerIntegrationTestSuite) TestRateLimit_APIKeyLimitCappedByGroup() {
+	s.Server.Middlewares.rateLimitStore = ratelimit.NewMemoryStore()
+	s.Server.Middlewares.rateLimits["bucket"] = ratelimit.Limit{Requests: 1, Period: time.Minute}
+	defer delete(s.Server.Middlewares.rateLimits, "bucket")
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	apiKey, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	apiKey.RateLimit = &models.APIKeyRateLimit{Requests: 100, PeriodSeconds: 60}
+	err = repository.NewAPIKeyRepository(s.Cfg, s.DbConn.Client).UpdateAPIKey(context.Background(), apiKey)
+	assert.Nil(s.T(), err)
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/%s", BASE_URL, testBucket.UID), nil)
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+		recorder := httptest.NewRecorder()
+		s.Server.Server.ServeHTTP(recorder, request)
+		codes = append(codes, recorder.Code)
+	}
+
+	// assert
+	assert.Equal(s.T(), []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+// Test that the X-Forwarded-For header is only trusted from the configured proxies,
+// so that a client cannot get new buckets by spoofing it
+func (s *ServerIntegrationTestSuite) TestRateLimit_SpoofedForwardedFor() {
+	s.Server.Middlewares.rateLimitStore = ratelimit.NewMemoryStore()
+	s.Server.Middlewares.rateLimits["public"] = ratelimit.Limit{Requests: 1, Period: time.Minute}
+	defer delete(s.Server.Middlewares.rateLimits, "public")
+
+	codes := []int{}
+	for _, forwardedFor := range []string{"198.51.100.1", "198.51.100.2"} {
+		request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/public/healthcheck", BASE_URL), nil)
+		request.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		recorder := httptest.NewRecorder()
+		s.Server.Server.ServeHTTP(recorder, request)
+		codes = append(codes, recorder.Code)
+	}
+	assert.Equal(s.T(), []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+
+	// behind a trusted proxy the client IP is taken from the header, other clients are not trusted
+	extractIP := ipExtractor([]string{"192.0.2.1", "10.0.0.0/8"})
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(echo.HeaderXForwardedFor, "198.51.100.1")
+	assert.Equal(s.T(), "198.51.100.1", extractIP(request))
+	request.RemoteAddr = "203.0.113.9:1234"
+	assert.Equal(s.T(), "203.0.113.9", extractIP(request))
+}
+
+// Test that the requests are limited by IP before they are authenticated, so that invalid credentials are limited too
+func (s *ServerIntegrationTestSuite) TestRateLimit_IPLimitBeforeAuth() {
+	s.Server.Middlewares.rateLimitStore = ratelimit.NewMemoryStore()
+	s.Server.Middlewares.ipRateLimit = &ratelimit.Limit{Requests: 2, Period: time.Minute}
+	defer func() { s.Server.Middlewares.ipRateLimit = parseIPRateLimit(s.Cfg.RateLimitIP) }()
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/%s", BASE_URL, "any-bucket"), nil)
+		request.Header.Add("Authorization", "Bearer invalid")
+		recorder := httptest.NewRecorder()
+		s.Server.Server.ServeHTTP(recorder, request)
+		codes = append(codes, recorder.Code)
+	}
+
+	// assert
+	assert.Equal(s.T(), []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes)
+}
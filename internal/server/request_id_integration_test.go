@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that a valid request ID is sent back and an invalid one is replaced
+func (s *ServerIntegrationTestSuite) TestRequestID() {
+	tt := []struct {
+		name string
+		sent string
+		want string
+	}{
+		{name: "keeps a valid request id", sent: "7f3c2a.retry-1", want: "7f3c2a.retry-1"},
+		{name: "generates a missing request id", sent: ""},
+		{name: "replaces an invalid request id", sent: "id with spaces\n"},
+	}
+	for _, tc := range tt {
+		s.Run(tc.name, func() {
+			request := httptest.NewRequest(http.MethodGet, "/docs/index.html", nil)
+			if tc.sent != "" {
+				request.Header.Set(echo.HeaderXRequestID, tc.sent)
+			}
+			recorder := httptest.NewRecorder()
+			s.Server.Server.ServeHTTP(recorder, request)
+
+			got := recorder.Header().Get(echo.HeaderXRequestID)
+			if tc.want != "" {
+				assert.Equal(s.T(), tc.want, got)
+				return
+			}
+			assert.Regexp(s.T(), requestIDPattern, got)
+			assert.NotEqual(s.T(), tc.sent, got)
+		})
+	}
+}
@@ -5,14 +5,25 @@ func InitAuthRoutes(s *Server) {
 	authRoutes := s.Server.Group("api/v1/auth")
 	protectedAuthRoutes := authRoutes.Group("")
 	{
-		protectedAuthRoutes.Use(s.Middlewares.RequireAuth)
+		protectedAuthRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RateLimit("auth"))
 		protectedAuthRoutes.GET("/user", s.Handler.AuthHandler.GetAuthUser)
+		protectedAuthRoutes.POST("/logout", s.Handler.AuthHandler.Logout)
+		protectedAuthRoutes.POST("/logout-all", s.Handler.AuthHandler.LogoutAll)
+		protectedAuthRoutes.GET("/sessions", s.Handler.AuthHandler.GetSessions)
+		protectedAuthRoutes.POST("/2fa/enroll", s.Handler.AuthHandler.EnrollTwoFactor)
+		protectedAuthRoutes.POST("/2fa/verify", s.Handler.AuthHandler.ConfirmTwoFactor)
+		protectedAuthRoutes.POST("/2fa/disable", s.Handler.AuthHandler.DisableTwoFactor)
+		protectedAuthRoutes.POST("/2fa/recovery-codes", s.Handler.AuthHandler.RegenerateRecoveryCodes)
 	}
-	authRoutes.POST("/refresh-token", s.Handler.AuthHandler.RefreshToken, s.Middlewares.RequireRefreshToken)
-	authRoutes.POST("/register", s.Handler.AuthHandler.Register)
-	authRoutes.POST("/login", s.Handler.AuthHandler.Login)
-	authRoutes.POST("/forgot-password", s.Handler.AuthHandler.ForgotPassword)
-	authRoutes.POST("/reset-password", s.Handler.AuthHandler.ResetPassword)
+	authRoutes.POST("/refresh-token", s.Handler.AuthHandler.RefreshToken,
+		s.Middlewares.RequireRefreshToken,
+		s.Middlewares.RateLimit("auth"))
+	authRoutes.POST("/register", s.Handler.AuthHandler.Register, s.Middlewares.RateLimit("auth"))
+	authRoutes.POST("/login", s.Handler.AuthHandler.Login, s.Middlewares.RateLimit("auth"))
+	authRoutes.POST("/login/2fa", s.Handler.AuthHandler.LoginTwoFactor, s.Middlewares.RateLimit("auth"))
+	authRoutes.POST("/forgot-password", s.Handler.AuthHandler.ForgotPassword, s.Middlewares.RateLimit("auth"))
+	authRoutes.POST("/reset-password", s.Handler.AuthHandler.ResetPassword, s.Middlewares.RateLimit("auth"))
+	authRoutes.POST("/unlock", s.Handler.AuthHandler.UnlockLogin, s.Middlewares.RateLimit("auth"))
 }
 
 // User account management routes
@@ -21,7 +32,7 @@ func InitUserRoutes(s *Server) {
 	usersRoutes := s.Server.Group("api/v1/users")
 	protectedUserRoutes := userRoutes.Group("")
 	{
-		protectedUserRoutes.Use(s.Middlewares.RequireAuth)
+		protectedUserRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RateLimit("user"))
 		protectedUserRoutes.PUT("",
 			s.Handler.UserHandler.UpdateUser,
 			s.Middlewares.RequireAPIKeyWriteUserPermission)
@@ -31,9 +42,24 @@ func InitUserRoutes(s *Server) {
 		protectedUserRoutes.DELETE("",
 			s.Handler.UserHandler.DeleteUser,
 			s.Middlewares.RequireAPIKeyDeleteUserPermission)
+		protectedUserRoutes.GET("/deletion",
+			s.Handler.UserHandler.GetUserDeletionStatus,
+			s.Middlewares.RequireAPIKeyReadUserPermission)
+		protectedUserRoutes.DELETE("/deletion",
+			s.Handler.UserHandler.CancelUserDeletion,
+			s.Middlewares.RequireAPIKeyDeleteUserPermission)
+		protectedUserRoutes.GET("/audit",
+			s.Handler.AuditHandler.ListUserAuditEvents,
+			s.Middlewares.RequireAPIKeyReadUserPermission)
+		protectedUserRoutes.GET("/audit/export",
+			s.Handler.AuditHandler.ExportUserAuditEvents,
+			s.Middlewares.RequireAPIKeyReadUserPermission)
+		protectedUserRoutes.GET("/usage",
+			s.Handler.UsageHandler.GetUserUsage,
+			s.Middlewares.RequireAPIKeyReadUserPermission)
 	}
-	usersRoutes.GET("/:userId", s.Handler.UserHandler.GetUserByID)
-	usersRoutes.GET("", s.Handler.UserHandler.GetAllUsers)
+	usersRoutes.GET("/:userId", s.Handler.UserHandler.GetUserByID, s.Middlewares.RateLimit("user"))
+	usersRoutes.GET("", s.Handler.UserHandler.GetAllUsers, s.Middlewares.RateLimit("user"))
 }
 
 // API Key management routes
@@ -42,7 +68,7 @@ func InitAPIKeyRoutes(s *Server) {
 	apiKeyRoutes := s.Server.Group("/api/v1/api_key")
 	protectedAPIKeyRoutes := apiKeyRoutes.Group("")
 	{
-		protectedAPIKeyRoutes.Use(s.Middlewares.RequireAuth)
+		protectedAPIKeyRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RateLimit("api_key"))
 		protectedAPIKeyRoutes.GET("/:apiKeyId", s.Handler.APIKeyHandler.FindAPIKeyByID)
 		protectedAPIKeyRoutes.POST("", s.Handler.APIKeyHandler.CreateAPIKey)
 		protectedAPIKeyRoutes.PUT("/:apiKeyId", s.Handler.APIKeyHandler.UpdateAPIKey)
@@ -51,7 +77,7 @@ func InitAPIKeyRoutes(s *Server) {
 	}
 	protectedAPIKeysRoutes := apiKeysRoutes.Group("")
 	{
-		protectedAPIKeysRoutes.Use(s.Middlewares.RequireAuth)
+		protectedAPIKeysRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RateLimit("api_key"))
 		protectedAPIKeysRoutes.GET("", s.Handler.APIKeyHandler.FindUserAPIKeys)
 		protectedAPIKeysRoutes.PUT("/revoke", s.Handler.APIKeyHandler.RevokeAPIKeys)
 		protectedAPIKeysRoutes.DELETE("", s.Handler.APIKeyHandler.DeleteAPIKeys)
@@ -64,7 +90,7 @@ func InitBucketRoutes(s *Server) {
 	bucketsRoutes := s.Server.Group("/api/v1/buckets")
 	protectedBucketRoutes := bucketRoutes.Group("")
 	{
-		protectedBucketRoutes.Use(s.Middlewares.RequireAuth)
+		protectedBucketRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RateLimit("bucket"), s.Middlewares.RequireBucketTwoFactor)
 		protectedBucketRoutes.POST("",
 			s.Handler.BucketHandler.CreateBucket,
 			s.Middlewares.RequireAPIKeyBucketWritePermission,
@@ -84,10 +110,111 @@ func InitBucketRoutes(s *Server) {
 			s.Middlewares.RequireBucketDeleteAccess,
 			s.Middlewares.RequireAPIKeyBucketDeletePermission,
 		)
+		// only the owner can require two-factor authentication on its bucket
+		protectedBucketRoutes.PUT("/:bucketUID/two-factor",
+			s.Handler.BucketHandler.SetBucketTwoFactor,
+			s.Middlewares.RequireAPIKeyBucketWritePermission,
+		)
+		// trashed buckets are only restorable by their owner
+		protectedBucketRoutes.POST("/:bucketUID/restore",
+			s.Handler.BucketHandler.RestoreBucket,
+			s.Middlewares.RequireAPIKeyBucketDeletePermission,
+		)
+		protectedBucketRoutes.POST("/:bucketUID/clone",
+			s.Handler.BucketHandler.CloneBucket,
+			s.Middlewares.RequireBucketReadAccess,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+			s.Middlewares.RequireBucketItemReadAccess,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+			s.Middlewares.RequireAPIKeyBucketWritePermission,
+		)
+		protectedBucketRoutes.GET("/:bucketUID/snapshots",
+			s.Handler.BucketHandler.ListBucketSnapshots,
+			s.Middlewares.RequireBucketReadAccess,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+			s.Middlewares.RequireBucketItemReadAccess,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+		)
+		protectedBucketRoutes.POST("/:bucketUID/snapshots",
+			s.Handler.BucketHandler.CreateBucketSnapshot,
+			s.Middlewares.RequireBucketWriteAccess,
+			s.Middlewares.RequireAPIKeyBucketWritePermission,
+		)
+		protectedBucketRoutes.GET("/:bucketUID/snapshots/:name",
+			s.Handler.BucketHandler.FindBucketSnapshot,
+			s.Middlewares.RequireBucketReadAccess,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+			s.Middlewares.RequireBucketItemReadAccess,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+		)
+		protectedBucketRoutes.DELETE("/:bucketUID/snapshots/:name",
+			s.Handler.BucketHandler.DeleteBucketSnapshot,
+			s.Middlewares.RequireBucketDeleteAccess,
+			s.Middlewares.RequireAPIKeyBucketDeletePermission,
+		)
+		protectedBucketRoutes.GET("/:bucketUID/diff",
+			s.Handler.BucketHandler.DiffBuckets,
+			s.Middlewares.RequireBucketReadAccess,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+			s.Middlewares.RequireBucketItemReadAccess,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+			s.Middlewares.RequireDiffBucketItemReadAccess,
+		)
+		protectedBucketRoutes.POST("/:bucketUID/merge",
+			s.Handler.BucketHandler.MergeBuckets,
+			s.Middlewares.RequireBucketItemWriteAccess,
+			s.Middlewares.RequireAPIKeyWriteItemPermission,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+			s.Middlewares.RequireMergeBucketAccess,
+		)
+		protectedBucketRoutes.GET("/:bucketUID/changes",
+			s.Handler.BucketChangeHandler.ListBucketChanges,
+			s.Middlewares.RequireBucketItemReadAccess,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+		)
+		// the audit log of a bucket is only readable by its owner
+		protectedBucketRoutes.GET("/:bucketUID/audit",
+			s.Handler.AuditHandler.ListBucketAuditEvents,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+		)
+		protectedBucketRoutes.GET("/:bucketUID/audit/export",
+			s.Handler.AuditHandler.ExportBucketAuditEvents,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+		)
+		protectedBucketRoutes.GET("/:bucketUID/webhooks",
+			s.Handler.WebhookHandler.ListWebhooks,
+			s.Middlewares.RequireBucketReadAccess,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+		)
+		protectedBucketRoutes.POST("/:bucketUID/webhooks",
+			s.Handler.WebhookHandler.CreateWebhook,
+			s.Middlewares.RequireBucketWriteAccess,
+			s.Middlewares.RequireAPIKeyBucketWritePermission,
+		)
+		protectedBucketRoutes.DELETE("/:bucketUID/webhooks/:webhookID",
+			s.Handler.WebhookHandler.DeleteWebhook,
+			s.Middlewares.RequireBucketDeleteAccess,
+			s.Middlewares.RequireAPIKeyBucketDeletePermission,
+		)
+		protectedBucketRoutes.GET("/:bucketUID/webhooks/:webhookID/deliveries",
+			s.Handler.WebhookHandler.ListWebhookDeliveries,
+			s.Middlewares.RequireBucketReadAccess,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+		)
+		protectedBucketRoutes.POST("/:bucketUID/webhooks/:webhookID/deliveries/:deliveryID/replay",
+			s.Handler.WebhookHandler.ReplayWebhookDelivery,
+			s.Middlewares.RequireBucketWriteAccess,
+			s.Middlewares.RequireAPIKeyBucketWritePermission,
+		)
+		protectedBucketRoutes.POST("/:bucketUID/webhooks/:webhookID/test",
+			s.Handler.WebhookHandler.SendTestEvent,
+			s.Middlewares.RequireBucketWriteAccess,
+			s.Middlewares.RequireAPIKeyBucketWritePermission,
+		)
 	}
 	protectedBucketsRoutes := bucketsRoutes.Group("")
 	{
-		protectedBucketsRoutes.Use(s.Middlewares.RequireAuth)
+		protectedBucketsRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RateLimit("bucket"))
 		protectedBucketsRoutes.GET("/all",
 			s.Handler.BucketHandler.ListUserBuckets,
 			s.Middlewares.RequireAPIKeyBucketReadPermission,
@@ -96,6 +223,10 @@ func InitBucketRoutes(s *Server) {
 			s.Handler.BucketHandler.ListUserBucketsPaged,
 			s.Middlewares.RequireAPIKeyBucketReadPermission,
 		)
+		protectedBucketsRoutes.GET("/trash",
+			s.Handler.BucketHandler.ListTrashedBuckets,
+			s.Middlewares.RequireAPIKeyBucketReadPermission,
+		)
 	}
 }
 
@@ -105,7 +236,7 @@ func InitBucketItemRoutes(s *Server) {
 	bucketItemsRoutes := s.Server.Group("/api/v1/items")
 	protectedBucketItemRoutes := bucketItemRoutes.Group("")
 	{
-		protectedBucketItemRoutes.Use(s.Middlewares.RequireAuth)
+		protectedBucketItemRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RateLimit("item"), s.Middlewares.RequireBucketTwoFactor)
 		protectedBucketItemRoutes.POST("/:bucketUID",
 			s.Handler.BucketItemHandler.CreateBucketItem,
 			s.Middlewares.RequireBucketItemWriteAccess,
@@ -115,6 +246,7 @@ func InitBucketItemRoutes(s *Server) {
 			s.Handler.BucketItemHandler.FindBucketItemByKeyName,
 			s.Middlewares.RequireBucketItemReadAccess,
 			s.Middlewares.RequireAPIKeyReadItemPermission,
+			s.Middlewares.CompressResponse,
 		)
 		protectedBucketItemRoutes.PUT("/:bucketUID/:key",
 			s.Handler.BucketItemHandler.UpdateBucketItemByKeyName,
@@ -126,30 +258,75 @@ func InitBucketItemRoutes(s *Server) {
 			s.Middlewares.RequireBucketItemDeleteAccess,
 			s.Middlewares.RequireAPIKeyDeleteItemPermission,
 		)
+		protectedBucketItemRoutes.POST("/:bucketUID/:key/binary",
+			s.Handler.BucketItemHandler.CreateBinaryBucketItem,
+			s.Middlewares.RequireBucketItemWriteAccess,
+			s.Middlewares.RequireAPIKeyWriteItemPermission,
+		)
+		protectedBucketItemRoutes.GET("/:bucketUID/:key/binary",
+			s.Handler.BucketItemHandler.DownloadBinaryBucketItem,
+			s.Middlewares.RequireBucketItemReadAccess,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+		)
 	}
 	protectedBucketItemsRoutes := bucketItemsRoutes.Group("")
 	{
-		protectedBucketItemsRoutes.Use(s.Middlewares.RequireAuth)
+		protectedBucketItemsRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RateLimit("item"), s.Middlewares.RequireBucketTwoFactor)
 		protectedBucketItemsRoutes.GET("",
 			s.Handler.BucketItemHandler.ListBucketItemsPaged,
 			s.Middlewares.RequireBucketItemReadAccess,
 			s.Middlewares.RequireAPIKeyReadItemPermission,
+			s.Middlewares.CompressResponse,
 		)
 		protectedBucketItemsRoutes.GET("/:bucketUID",
 			s.Handler.BucketItemHandler.ListBucketItemsByBucketUID,
 			s.Middlewares.RequireBucketItemReadAccess,
 			s.Middlewares.RequireAPIKeyReadItemPermission,
+			s.Middlewares.CompressResponse,
+		)
+		protectedBucketItemsRoutes.GET("/:bucketUID/compression",
+			s.Handler.BucketItemHandler.GetCompressionStats,
+			s.Middlewares.RequireBucketItemReadAccess,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+		)
+		protectedBucketItemsRoutes.POST("/:bucketUID/compression",
+			s.Handler.BucketItemHandler.CompressBucketItems,
+			s.Middlewares.RequireBucketItemWriteAccess,
+			s.Middlewares.RequireAPIKeyWriteItemPermission,
 		)
+		protectedBucketItemsRoutes.GET("/:bucketUID/trash",
+			s.Handler.BucketItemHandler.ListTrashedBucketItems,
+			s.Middlewares.RequireBucketItemReadAccess,
+			s.Middlewares.RequireAPIKeyReadItemPermission,
+		)
+		protectedBucketItemsRoutes.POST("/:bucketUID/trash/:itemID/restore",
+			s.Handler.BucketItemHandler.RestoreBucketItem,
+			s.Middlewares.RequireBucketItemDeleteAccess,
+			s.Middlewares.RequireAPIKeyDeleteItemPermission,
+		)
+	}
+}
+
+// Administration routes
+func InitAdminRoutes(s *Server) {
+	adminRoutes := s.Server.Group("/api/v1/admin")
+	{
+		adminRoutes.Use(s.Middlewares.RequireAuth, s.Middlewares.RequireAdmin, s.Middlewares.RateLimit("admin"))
+		adminRoutes.DELETE("/bucket/:bucketUID", s.Handler.BucketHandler.PermanentlyDeleteBucket)
+		adminRoutes.DELETE("/item/:bucketUID/:itemID", s.Handler.BucketItemHandler.PermanentlyDeleteBucketItem)
+		adminRoutes.PUT("/user/:userId/quota", s.Handler.UsageHandler.UpdateUserQuota)
 	}
 }
 
 // Public/Miscellaneous routes
 func InitPublicRoutes(s *Server) {
-	publicRoutes := s.Server.Group("/api/v1/public")
+	publicRoutes := s.Server.Group("/api/v1/public", s.Middlewares.RateLimit("public"))
 	// healthcheck
 	publicRoutes.GET("/healthcheck", s.Handler.PublicRoutesHandler.HealthCheck)
 	// returns an array of api key permissions
 	publicRoutes.GET("/apikey-permissions", s.Handler.PublicRoutesHandler.GetAPIKeyPermissionsList)
 	// returns an array of bucket-level permissions
 	publicRoutes.GET("/bucket-permissions", s.Handler.PublicRoutesHandler.GetBucketPermissionsList)
+	// public keys of the tokens, for the services verifying them offline
+	s.Server.GET("/.well-known/jwks.json", s.Handler.PublicRoutesHandler.GetJWKS, s.Middlewares.RateLimit("public"))
 }
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Logs a user in and returns its tokens
+func (s *ServerIntegrationTestSuite) login(email string, password string) dto.LoginUserOutputDTO {
+	recorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, newLoginRequest(email, password))
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	resp := struct {
+		Data dto.LoginUserOutputDTO `json:"data"`
+	}{}
+	assert.Nil(s.T(), json.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+// Returns a refresh request for a refresh token
+func newRefreshRequest(refreshToken string) *http.Request {
+	request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/auth/refresh-token", BASE_URL), nil)
+	request.Header.Add("x-refresh-token", refreshToken)
+	return request
+}
+
+// Test that a refresh replaces the refresh token and that reusing the replaced one revokes the session
+func (s *ServerIntegrationTestSuite) TestSession_RefreshTokenReuse() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	tokens := s.login(testUser.Email, "Secret12345!")
+
+	recorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, newRefreshRequest(tokens.RefreshToken))
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	resp := struct {
+		Data dto.RefreshTokenOutputDTO `json:"data"`
+	}{}
+	assert.Nil(s.T(), json.Unmarshal(recorder.Body.Bytes(), &resp))
+	assert.NotEqual(s.T(), tokens.RefreshToken, resp.Data.RefreshToken)
+
+	// the replaced token revokes the session, and with it the token replacing it
+	recorder, problem := s.sendForProblem(newRefreshRequest(tokens.RefreshToken))
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("refresh_token_reused"), problem.Code)
+	recorder, _ = s.sendForProblem(newRefreshRequest(resp.Data.RefreshToken))
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+}
+
+// Test that the sessions are listed and that the access token of a session is rejected after logging out
+func (s *ServerIntegrationTestSuite) TestSession_Logout() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	tokens := s.login(testUser.Email, "Secret12345!")
+	s.login(testUser.Email, "Secret12345!")
+
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/auth/sessions", BASE_URL), nil)
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", tokens.AccessToken))
+	recorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, request)
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	resp := struct {
+		Data []dto.SessionOutputDTO `json:"data"`
+	}{}
+	assert.Nil(s.T(), json.Unmarshal(recorder.Body.Bytes(), &resp))
+	assert.Len(s.T(), resp.Data, 2)
+
+	request, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("%s/auth/logout", BASE_URL), nil)
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", tokens.AccessToken))
+	recorder = httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, request)
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+
+	request, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("%s/auth/user", BASE_URL), nil)
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", tokens.AccessToken))
+	recorder = httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, request)
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+}
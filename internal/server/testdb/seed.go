@@ -1,6 +1,7 @@
 package testdb
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"keeper/internal/config"
@@ -37,7 +38,7 @@ func SeedUser(dbClient *mongo.Client, cfg *config.Config) (*models.User, error)
 		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
 		UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
 	}
-	err = userRepo.CreateUser(newUser)
+	err = userRepo.CreateUser(context.Background(), newUser)
 	if err != nil {
 		logrus.WithError(err).Fatal("error seeding new user")
 		return &models.User{}, err
@@ -75,7 +76,7 @@ func SeedAPIKey(dbClient *mongo.Client, cfg *config.Config, userID primitive.Obj
 		UpdatedAt:   primitive.NewDateTimeFromTime(time.Now()),
 		Permissions: permissions,
 	}
-	_, err = apiKeyRepo.CreateAPIKey(apiKey)
+	_, err = apiKeyRepo.CreateAPIKey(context.Background(), apiKey)
 	if err != nil {
 		logrus.WithError(err).Error("error seeding new api key")
 		return &models.APIKey{}, "", err
@@ -99,7 +100,7 @@ func SeedBucket(dbClient *mongo.Client, cfg *config.Config, userID primitive.Obj
 		Permissions: permissions,
 	}
 
-	_, err := bucketRepo.CreateBucket(newBucket)
+	_, err := bucketRepo.CreateBucket(context.Background(), newBucket)
 	if err != nil {
 		logrus.WithError(err).Error("error seeding new bucket")
 		return &models.Bucket{}, err
@@ -125,7 +126,7 @@ func SeedBucketItem(dbClient *mongo.Client, cfg *config.Config, userID primitive
 		UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
 	}
 
-	_, err := bucketItemRepo.CreateBucketItem(newBucketItem)
+	_, err := bucketItemRepo.CreateBucketItem(context.Background(), newBucketItem)
 	if err != nil {
 		logrus.WithError(err).Error("failed to seed new bucket item")
 		return &models.BucketItem{}, err
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"fmt"
+	"keeper/internal/models"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that a request running past the timeout of its route is reported as a timeout
+func (s *ServerIntegrationTestSuite) TestTimeout_RouteTimeout() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	route := "GET /api/v1/bucket/:bucketUID"
+	s.Server.Middlewares.routeTimeouts[route] = time.Nanosecond
+	defer delete(s.Server.Middlewares.routeTimeouts, route)
+
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/bucket/%s", BASE_URL, testBucket.UID), nil)
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+
+	// act
+	recorder, problem := s.sendForProblem(request)
+
+	// assert
+	assert.Equal(s.T(), http.StatusGatewayTimeout, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("request_timeout"), problem.Code)
+}
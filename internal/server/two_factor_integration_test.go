@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/pkg/totp"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns an authenticated JSON request
+func newAuthJSONRequest(method string, url string, accessToken string, data interface{}) *http.Request {
+	body, _ := json.Marshal(data)
+	request, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Authorization", "Bearer "+accessToken)
+	return request
+}
+
+// Test the enrollment, the two-step login and the buckets requiring two-factor authentication
+func (s *ServerIntegrationTestSuite) TestTwoFactor() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BucketPermissionsList{})
+	assert.Nil(s.T(), err)
+	tokens := s.login(testUser.Email, "Secret12345!")
+	bucketURL := fmt.Sprintf("%s/bucket/%s", BASE_URL, testBucket.UID)
+
+	// the requirement needs the owner to have two-factor authentication
+	recorder, problem := s.sendForProblem(newAuthJSONRequest(http.MethodPut, bucketURL+"/two-factor", tokens.AccessToken, map[string]bool{"required": true}))
+	assert.Equal(s.T(), http.StatusForbidden, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("two_factor_required"), problem.Code)
+
+	// enroll and verify
+	recorder = httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, newAuthJSONRequest(http.MethodPost, fmt.Sprintf("%s/auth/2fa/enroll", BASE_URL), tokens.AccessToken, nil))
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	enrollment := struct {
+		Data dto.TwoFactorEnrollOutputDTO `json:"data"`
+	}{}
+	assert.Nil(s.T(), json.Unmarshal(recorder.Body.Bytes(), &enrollment))
+	code, _ := totp.Code(enrollment.Data.Secret, totp.Step(time.Now()))
+	recorder = httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, newAuthJSONRequest(http.MethodPost, fmt.Sprintf("%s/auth/2fa/verify", BASE_URL), tokens.AccessToken, dto.TwoFactorCodeInputDTO{Code: code}))
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	recoveryCodes := struct {
+		Data dto.RecoveryCodesOutputDTO `json:"data"`
+	}{}
+	assert.Nil(s.T(), json.Unmarshal(recorder.Body.Bytes(), &recoveryCodes))
+	assert.Len(s.T(), recoveryCodes.Data.RecoveryCodes, 10)
+
+	// the password alone no longer issues tokens
+	login := s.login(testUser.Email, "Secret12345!")
+	assert.True(s.T(), login.TwoFactorRequired)
+	assert.Empty(s.T(), login.AccessToken)
+
+	// the code was already used by the verification, a recovery code is used once
+	loginTwoFactorURL := fmt.Sprintf("%s/auth/login/2fa", BASE_URL)
+	recorder, problem = s.sendForProblem(newAuthJSONRequest(http.MethodPost, loginTwoFactorURL, "", dto.TwoFactorLoginInputDTO{Token: login.TwoFactorToken, Code: code}))
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("invalid_two_factor_code"), problem.Code)
+	recoveryLogin := dto.TwoFactorLoginInputDTO{Token: login.TwoFactorToken, RecoveryCode: recoveryCodes.Data.RecoveryCodes[0]}
+	recorder = httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, newAuthJSONRequest(http.MethodPost, loginTwoFactorURL, "", recoveryLogin))
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	twoFactorTokens := struct {
+		Data dto.LoginUserOutputDTO `json:"data"`
+	}{}
+	assert.Nil(s.T(), json.Unmarshal(recorder.Body.Bytes(), &twoFactorTokens))
+	assert.NotEmpty(s.T(), twoFactorTokens.Data.AccessToken)
+	recorder, _ = s.sendForProblem(newAuthJSONRequest(http.MethodPost, loginTwoFactorURL, "", recoveryLogin))
+	assert.Equal(s.T(), http.StatusUnauthorized, recorder.Code)
+
+	// the owner can now require two-factor authentication on the bucket
+	recorder = httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, newAuthJSONRequest(http.MethodPut, bucketURL+"/two-factor", twoFactorTokens.Data.AccessToken, map[string]bool{"required": true}))
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+
+	// once disabled, the owner cannot access the bucket anymore
+	recorder = httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, newAuthJSONRequest(http.MethodPost, fmt.Sprintf("%s/auth/2fa/disable", BASE_URL), twoFactorTokens.Data.AccessToken,
+		dto.TwoFactorReauthInputDTO{Password: "Secret12345!", RecoveryCode: recoveryCodes.Data.RecoveryCodes[1]}))
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	recorder, problem = s.sendForProblem(newAuthJSONRequest(http.MethodGet, bucketURL, twoFactorTokens.Data.AccessToken, nil))
+	assert.Equal(s.T(), http.StatusForbidden, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("two_factor_required"), problem.Code)
+
+	// including through the paged listing of the items, which takes the bucket from its query
+	itemsURL := fmt.Sprintf("%s/items?bucket_uid=%s", BASE_URL, testBucket.UID)
+	recorder, problem = s.sendForProblem(newAuthJSONRequest(http.MethodGet, itemsURL, twoFactorTokens.Data.AccessToken, nil))
+	assert.Equal(s.T(), http.StatusForbidden, recorder.Code)
+	assert.Equal(s.T(), models.ErrorCode("two_factor_required"), problem.Code)
+	recorder, _ = s.sendForProblem(newAuthJSONRequest(http.MethodGet, fmt.Sprintf("%s/items", BASE_URL), twoFactorTokens.Data.AccessToken, nil))
+	assert.Equal(s.T(), http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/repository"
+	"keeper/internal/server/testdb"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Test that the writes going over the item quota are rejected and that the usage reports the accepted ones
+func (s *ServerIntegrationTestSuite) TestUsage_ItemQuota() {
+	s.Cfg.QuotaMaxItemsPerBucket = 1
+	defer func() { s.Cfg.QuotaMaxItemsPerBucket = 0 }()
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	testBucket, err := testdb.SeedBucket(s.DbConn.Client, s.Cfg, testUser.ID, models.BUCKET_PERMISSIONS)
+	assert.Nil(s.T(), err)
+
+	codes := []int{}
+	for _, itemKey := range []string{"first", "second"} {
+		body, _ := json.Marshal(&dto.CreateBucketItemInputDTO{Key: itemKey, Data: "string value"})
+		request, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/item/%s", BASE_URL, testBucket.UID), bytes.NewReader(body))
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+		request.Header.Add("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		s.Server.Server.ServeHTTP(recorder, request)
+		codes = append(codes, recorder.Code)
+	}
+	assert.Equal(s.T(), []int{http.StatusCreated, http.StatusForbidden}, codes)
+
+	// act
+	request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/user/usage", BASE_URL), nil)
+	request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+	recorder := httptest.NewRecorder()
+	s.Server.Server.ServeHTTP(recorder, request)
+
+	// assert
+	assert.Equal(s.T(), http.StatusOK, recorder.Code)
+	resp := struct {
+		Data dto.UserUsageOutputDTO `json:"data"`
+	}{}
+	assert.Nil(s.T(), json.Unmarshal(recorder.Body.Bytes(), &resp))
+	assert.Equal(s.T(), int64(1), resp.Data.Items)
+	assert.Equal(s.T(), int64(1), resp.Data.Quota.MaxItemsPerBucket)
+	assert.Len(s.T(), resp.Data.Usages, 1)
+}
+
+// Test that the usage increments racing for the last room of a quota do not exceed it together
+func (s *ServerIntegrationTestSuite) TestUsage_ConcurrentIncrements() {
+	userID := primitive.NewObjectID()
+	bucketUID := userID.Hex()
+	usageRepo := repository.NewUsageRepository(s.Cfg, s.DbConn.Client)
+	quota := models.Quota{MaxItemsPerBucket: 5, MaxStorageBytes: 1000}
+	const writers = 20
+
+	// act
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- usageRepo.IncrementUsage(context.Background(), userID, bucketUID, models.UsageDelta{Items: 1, Bytes: 100}, quota)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	userUsage, userErr := usageRepo.FindUserUsage(context.Background(), userID)
+	bucketUsage, bucketErr := usageRepo.FindBucketUsage(context.Background(), bucketUID)
+
+	// assert
+	accepted := 0
+	for err := range errs {
+		if err == nil {
+			accepted++
+			continue
+		}
+		assert.ErrorIs(s.T(), err, models.ErrItemQuotaExceeded)
+	}
+	assert.Equal(s.T(), 5, accepted)
+	assert.Nil(s.T(), userErr)
+	assert.Nil(s.T(), bucketErr)
+	assert.Equal(s.T(), int64(5), userUsage.Items)
+	assert.Equal(s.T(), int64(500), userUsage.Bytes)
+	assert.Equal(s.T(), int64(5), bucketUsage.Items)
+	assert.Equal(s.T(), int64(500), bucketUsage.Bytes)
+}
@@ -1,12 +1,14 @@
 package services
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"keeper/internal/config"
 	"keeper/internal/dto"
 	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
 	"keeper/internal/repository"
 	"keeper/internal/utils"
 	"time"
@@ -18,38 +20,47 @@ import (
 
 type APIKeyService struct {
 	apiKeyRepo repository.IAPIKeyRepository
+	auditRepo  repository.IAuditEventRepository
 	Cfg        *config.Config
 }
 
 type IAPIKeyService interface {
-	CreateAPIKey(data dto.CreateAPIKeyInputDTO, userID primitive.ObjectID) (dto.CreateAPIKeyOutputDTO, error)
-	FindAPIKeyByID(id string) (*models.APIKey, error)
-	FindUserAPIKeys(userID string) ([]models.APIKey, error)
-	UpdateAPIKey(id string, data dto.UpdateAPIKeyInputDTO) error
-	RevokeAPIKey(id string) error
-	RevokeAPIKeys(ids []string) error
-	DeleteAPIKey(id string) error
-	DeleteAPIKeys(ids []string) error
+	CreateAPIKey(ctx context.Context, data dto.CreateAPIKeyInputDTO, userID primitive.ObjectID) (dto.CreateAPIKeyOutputDTO, error)
+	FindAPIKeyByID(ctx context.Context, id string) (*models.APIKey, error)
+	FindUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	UpdateAPIKey(ctx context.Context, id string, data dto.UpdateAPIKeyInputDTO) error
+	RevokeAPIKey(ctx context.Context, id string) error
+	RevokeAPIKeys(ctx context.Context, ids []string) error
+	DeleteAPIKey(ctx context.Context, id string) error
+	DeleteAPIKeys(ctx context.Context, ids []string) error
 }
 
-func NewAPIKeyService(cfg *config.Config, apiKeyRepo repository.IAPIKeyRepository) IAPIKeyService {
+var (
+	ErrAPIKeyExpiresAtInPast  = models.NewBadRequestError(errors.New("api key expires_at cannot be before now"))
+	ErrInvalidAPIKeyRateLimit = models.NewBadRequestError(errors.New("api key rate limit requests cannot be negative and period_seconds must be greater than 0"))
+)
+
+func NewAPIKeyService(cfg *config.Config, apiKeyRepo repository.IAPIKeyRepository, auditRepo repository.IAuditEventRepository) IAPIKeyService {
 	return &APIKeyService{
 		Cfg:        cfg,
 		apiKeyRepo: apiKeyRepo,
+		auditRepo:  auditRepo,
 	}
 }
 
-func (a *APIKeyService) CreateAPIKey(data dto.CreateAPIKeyInputDTO, userID primitive.ObjectID) (dto.CreateAPIKeyOutputDTO, error) {
+func (a *APIKeyService) CreateAPIKey(ctx context.Context, data dto.CreateAPIKeyInputDTO, userID primitive.ObjectID) (dto.CreateAPIKeyOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.CreateAPIKey")
+	defer span.End()
 	// check expiry date of the API Key
 	if data.ExpiresAt.Before(time.Now()) {
-		return dto.CreateAPIKeyOutputDTO{}, errors.New("api key expires_at cannot be before now")
+		return dto.CreateAPIKeyOutputDTO{}, ErrAPIKeyExpiresAtInPast
 	}
 	// Generate mask and key
 	maskID, key := utils.GenerateAPIKey()
 	// Generate a salt secret
 	salt, err := utils.GenerateSecret()
 	if err != nil {
-		logrus.Errorf("error generating salt secret: %s", err.Error())
+		logrus.WithContext(ctx).Errorf("error generating salt secret: %s", err.Error())
 		return dto.CreateAPIKeyOutputDTO{}, err
 	}
 	// Generate a hash of the key using the salt as a secret
@@ -81,11 +92,20 @@ func (a *APIKeyService) CreateAPIKey(data dto.CreateAPIKeyInputDTO, userID primi
 	}
 
 	// save to database
-	id, err := a.apiKeyRepo.CreateAPIKey(newAPIKey)
+	id, err := a.apiKeyRepo.CreateAPIKey(ctx, newAPIKey)
 	if err != nil {
-		logrus.WithError(err).Error("could not save api key to database")
+		logrus.WithContext(ctx).WithError(err).Error("could not save api key to database")
 		return dto.CreateAPIKeyOutputDTO{}, err
 	}
+	recordAuditEvent(ctx, a.auditRepo, &models.AuditEvent{
+		Action: models.AuditActionAPIKeyCreate,
+		Target: maskID,
+		After: map[string]interface{}{
+			"name":        data.Name,
+			"permissions": permissions,
+			"expires_at":  newAPIKey.ExpiresAt,
+		},
+	})
 
 	return dto.CreateAPIKeyOutputDTO{
 		ID:          id,
@@ -97,28 +117,39 @@ func (a *APIKeyService) CreateAPIKey(data dto.CreateAPIKeyInputDTO, userID primi
 	}, nil
 }
 
-func (a *APIKeyService) FindAPIKeyByID(id string) (*models.APIKey, error) {
-	apiKey, err := a.apiKeyRepo.FindAPIKeyByID(id)
+func (a *APIKeyService) FindAPIKeyByID(ctx context.Context, id string) (*models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.FindAPIKeyByID")
+	defer span.End()
+	apiKey, err := a.apiKeyRepo.FindAPIKeyByID(ctx, id)
 	if err != nil {
 		return &models.APIKey{}, err
 	}
 	return apiKey, nil
 }
 
-func (a *APIKeyService) FindUserAPIKeys(userID string) ([]models.APIKey, error) {
-	apiKeys, err := a.apiKeyRepo.FindUserAPIKeys(userID)
+func (a *APIKeyService) FindUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.FindUserAPIKeys")
+	defer span.End()
+	apiKeys, err := a.apiKeyRepo.FindUserAPIKeys(ctx, userID)
 	if err != nil {
 		return []models.APIKey{}, err
 	}
 	return apiKeys, nil
 }
 
-func (a *APIKeyService) UpdateAPIKey(id string, data dto.UpdateAPIKeyInputDTO) error {
+func (a *APIKeyService) UpdateAPIKey(ctx context.Context, id string, data dto.UpdateAPIKeyInputDTO) error {
+	ctx, span := tracing.Start(ctx, "APIKeyService.UpdateAPIKey")
+	defer span.End()
+	// a limit of 0 requests removes the limit of the key, whatever its period
+	if limit := data.RateLimit; limit != nil && (limit.Requests < 0 || limit.PeriodSeconds < 0 || (limit.Requests > 0 && limit.PeriodSeconds == 0)) {
+		return ErrInvalidAPIKeyRateLimit
+	}
 	apiKey := &models.APIKey{
 		Name:        data.Name,
 		Role:        data.Role,
 		KeyType:     data.KeyType,
 		Permissions: data.Permissions,
+		RateLimit:   data.RateLimit,
 	}
 	ID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -126,40 +157,61 @@ func (a *APIKeyService) UpdateAPIKey(id string, data dto.UpdateAPIKeyInputDTO) e
 	}
 	apiKey.ID = ID
 	apiKey.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-	if err := a.apiKeyRepo.UpdateAPIKey(apiKey); err != nil {
+	if err := a.apiKeyRepo.UpdateAPIKey(ctx, apiKey); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (a *APIKeyService) RevokeAPIKey(id string) error {
-	err := a.apiKeyRepo.RevokeAPIKey(id)
+func (a *APIKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "APIKeyService.RevokeAPIKey")
+	defer span.End()
+	err := a.apiKeyRepo.RevokeAPIKey(ctx, id)
 	if err != nil {
 		return err
 	}
+	a.recordRevocations(ctx, id)
 	return nil
 }
 
-func (a *APIKeyService) RevokeAPIKeys(ids []string) error {
-	err := a.apiKeyRepo.RevokeAPIKeys(ids)
+func (a *APIKeyService) RevokeAPIKeys(ctx context.Context, ids []string) error {
+	ctx, span := tracing.Start(ctx, "APIKeyService.RevokeAPIKeys")
+	defer span.End()
+	err := a.apiKeyRepo.RevokeAPIKeys(ctx, ids)
 	if err != nil {
 		return err
 	}
+	a.recordRevocations(ctx, ids...)
 	return nil
 }
 
-func (a *APIKeyService) DeleteAPIKey(id string) error {
-	err := a.apiKeyRepo.DeleteAPIKey(id)
+func (a *APIKeyService) DeleteAPIKey(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "APIKeyService.DeleteAPIKey")
+	defer span.End()
+	err := a.apiKeyRepo.DeleteAPIKey(ctx, id)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (a *APIKeyService) DeleteAPIKeys(ids []string) error {
-	err := a.apiKeyRepo.DeleteAPIKeys(ids)
+func (a *APIKeyService) DeleteAPIKeys(ctx context.Context, ids []string) error {
+	ctx, span := tracing.Start(ctx, "APIKeyService.DeleteAPIKeys")
+	defer span.End()
+	err := a.apiKeyRepo.DeleteAPIKeys(ctx, ids)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// Records the revocation of api keys in the audit log, one event per key
+func (a *APIKeyService) recordRevocations(ctx context.Context, ids ...string) {
+	for _, id := range ids {
+		recordAuditEvent(ctx, a.auditRepo, &models.AuditEvent{
+			Action: models.AuditActionAPIKeyRevoke,
+			Target: id,
+			After:  map[string]interface{}{"revoked": true},
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"keeper/internal/config"
 	"keeper/internal/dto"
@@ -20,7 +21,7 @@ func provideMockAPIKeyService(mockAPIKeyRepo repository.IAPIKeyRepository) IAPIK
 	mockCfg := &config.Config{
 		Env: "test",
 	}
-	return NewAPIKeyService(mockCfg, mockAPIKeyRepo)
+	return NewAPIKeyService(mockCfg, mockAPIKeyRepo, nil)
 }
 
 var testAPIKey models.APIKey = models.APIKey{
@@ -55,7 +56,7 @@ func TestAPIKeyService_FindAPIKeyByID(t *testing.T) {
 				id: "abcd",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().FindAPIKeyByID(gomock.Any()).
+				apiKeyRepo.EXPECT().FindAPIKeyByID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&testAPIKey, nil)
 			},
 			want:    &testAPIKey,
@@ -67,7 +68,7 @@ func TestAPIKeyService_FindAPIKeyByID(t *testing.T) {
 				id: "abcd",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().FindAPIKeyByID(gomock.Any()).
+				apiKeyRepo.EXPECT().FindAPIKeyByID(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("failed to find api key"))
 			},
 			want:       nil,
@@ -83,7 +84,7 @@ func TestAPIKeyService_FindAPIKeyByID(t *testing.T) {
 			}
 
 			apiKeySvc := provideMockAPIKeyService(apiKeyRepo)
-			apiKey, err := apiKeySvc.FindAPIKeyByID(tc.args.id)
+			apiKey, err := apiKeySvc.FindAPIKeyByID(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -128,7 +129,7 @@ func TestAPIKeyService_FindUserAPIKeys(t *testing.T) {
 				id: "abcd",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().FindUserAPIKeys(gomock.Any()).
+				apiKeyRepo.EXPECT().FindUserAPIKeys(gomock.Any(), gomock.Any()).
 					Times(1).Return(testAPIKeys, nil)
 			},
 			want:    testAPIKeys,
@@ -140,7 +141,7 @@ func TestAPIKeyService_FindUserAPIKeys(t *testing.T) {
 				id: "abcd",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().FindUserAPIKeys(gomock.Any()).
+				apiKeyRepo.EXPECT().FindUserAPIKeys(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("failed to find user api keys"))
 			},
 			want:       nil,
@@ -156,7 +157,7 @@ func TestAPIKeyService_FindUserAPIKeys(t *testing.T) {
 			}
 
 			apiKeySvc := provideMockAPIKeyService(apiKeyRepo)
-			apiKeys, err := apiKeySvc.FindUserAPIKeys(tc.args.id)
+			apiKeys, err := apiKeySvc.FindUserAPIKeys(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -205,7 +206,7 @@ func TestAPIKeyService_CreateAPIKey(t *testing.T) {
 				userId: testUserId,
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().CreateAPIKey(gomock.Any()).
+				apiKeyRepo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).
 					Times(1).Return(testUserId, nil)
 			},
 			want: dto.CreateAPIKeyOutputDTO{
@@ -221,7 +222,7 @@ func TestAPIKeyService_CreateAPIKey(t *testing.T) {
 				userId: primitive.NewObjectID(),
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().CreateAPIKey(gomock.Any()).
+				apiKeyRepo.EXPECT().CreateAPIKey(gomock.Any(), gomock.Any()).
 					Times(1).Return(primitive.ObjectID{}, errors.New("failed to create api key"))
 			},
 			want:       dto.CreateAPIKeyOutputDTO{},
@@ -252,7 +253,7 @@ func TestAPIKeyService_CreateAPIKey(t *testing.T) {
 			}
 
 			apiKeySvc := provideMockAPIKeyService(apiKeyRepo)
-			out, err := apiKeySvc.CreateAPIKey(tc.args.data, tc.args.userId)
+			out, err := apiKeySvc.CreateAPIKey(context.Background(), tc.args.data, tc.args.userId)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -291,7 +292,7 @@ func TestAPIKeyService_UpdateAPIKey(t *testing.T) {
 				id:   "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().UpdateAPIKey(gomock.Any()).
+				apiKeyRepo.EXPECT().UpdateAPIKey(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -303,7 +304,7 @@ func TestAPIKeyService_UpdateAPIKey(t *testing.T) {
 				id:   "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().UpdateAPIKey(gomock.Any()).
+				apiKeyRepo.EXPECT().UpdateAPIKey(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to update api key"))
 			},
 			wantErr:    true,
@@ -319,6 +320,51 @@ func TestAPIKeyService_UpdateAPIKey(t *testing.T) {
 			wantErr:    true,
 			wantErrMsg: models.ErrInvalidObjectID.Error(),
 		},
+		{
+			name: "should_successfully_update_apikey_rate_limit",
+			args: args{
+				data: dto.UpdateAPIKeyInputDTO{RateLimit: &models.APIKeyRateLimit{Requests: 100, PeriodSeconds: 60}},
+				id:   "62fa734bfc1cdb7f06a3bf6f",
+			},
+			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
+				apiKeyRepo.EXPECT().UpdateAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(func(_ context.Context, apiKey *models.APIKey) error {
+					require.Equal(t, &models.APIKeyRateLimit{Requests: 100, PeriodSeconds: 60}, apiKey.RateLimit)
+					return nil
+				})
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_fail_update_apikey_rate_limit_without_period",
+			args: args{
+				data: dto.UpdateAPIKeyInputDTO{RateLimit: &models.APIKeyRateLimit{Requests: 100}},
+				id:   "62fa734bfc1cdb7f06a3bf6f",
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrInvalidAPIKeyRateLimit.Error(),
+		},
+		{
+			name: "should_fail_update_apikey_rate_limit_negative_requests",
+			args: args{
+				data: dto.UpdateAPIKeyInputDTO{RateLimit: &models.APIKeyRateLimit{Requests: -1, PeriodSeconds: 60}},
+				id:   "62fa734bfc1cdb7f06a3bf6f",
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrInvalidAPIKeyRateLimit.Error(),
+		},
+		{
+			name: "should_fail_update_apikey_rate_limit_negative_period",
+			args: args{
+				data: dto.UpdateAPIKeyInputDTO{RateLimit: &models.APIKeyRateLimit{PeriodSeconds: -60}},
+				id:   "62fa734bfc1cdb7f06a3bf6f",
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrInvalidAPIKeyRateLimit.Error(),
+		},
 	}
 
 	for _, tc := range tt {
@@ -328,7 +374,7 @@ func TestAPIKeyService_UpdateAPIKey(t *testing.T) {
 			}
 
 			apiKeySvc := provideMockAPIKeyService(apiKeyRepo)
-			err := apiKeySvc.UpdateAPIKey(tc.args.id, tc.args.data)
+			err := apiKeySvc.UpdateAPIKey(context.Background(), tc.args.id, tc.args.data)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -363,7 +409,7 @@ func TestAPIKeyService_RevokeAPIKey(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().RevokeAPIKey(gomock.Any()).
+				apiKeyRepo.EXPECT().RevokeAPIKey(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -374,7 +420,7 @@ func TestAPIKeyService_RevokeAPIKey(t *testing.T) {
 				id: "abcd",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().RevokeAPIKey(gomock.Any()).
+				apiKeyRepo.EXPECT().RevokeAPIKey(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("error revoking api key"))
 			},
 			wantErr:    true,
@@ -389,7 +435,7 @@ func TestAPIKeyService_RevokeAPIKey(t *testing.T) {
 			}
 
 			apiKeySvc := provideMockAPIKeyService(apiKeyRepo)
-			err := apiKeySvc.RevokeAPIKey(tc.args.id)
+			err := apiKeySvc.RevokeAPIKey(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -424,7 +470,7 @@ func TestAPIKeyService_RevokeAPIKeys(t *testing.T) {
 				id: []string{"62fa734bfc1cdb7f06a3bf6f"},
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().RevokeAPIKeys(gomock.Any()).
+				apiKeyRepo.EXPECT().RevokeAPIKeys(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -435,7 +481,7 @@ func TestAPIKeyService_RevokeAPIKeys(t *testing.T) {
 				id: []string{"abcd"},
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().RevokeAPIKeys(gomock.Any()).
+				apiKeyRepo.EXPECT().RevokeAPIKeys(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("error revoking api keys"))
 			},
 			wantErr:    true,
@@ -450,7 +496,7 @@ func TestAPIKeyService_RevokeAPIKeys(t *testing.T) {
 			}
 
 			apiKeySvc := provideMockAPIKeyService(apiKeyRepo)
-			err := apiKeySvc.RevokeAPIKeys(tc.args.id)
+			err := apiKeySvc.RevokeAPIKeys(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -485,7 +531,7 @@ func TestAPIKeyService_DeleteAPIKey(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().DeleteAPIKey(gomock.Any()).
+				apiKeyRepo.EXPECT().DeleteAPIKey(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -496,7 +542,7 @@ func TestAPIKeyService_DeleteAPIKey(t *testing.T) {
 				id: "abcd",
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().DeleteAPIKey(gomock.Any()).
+				apiKeyRepo.EXPECT().DeleteAPIKey(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("error deleting api key"))
 			},
 			wantErr:    true,
@@ -511,7 +557,7 @@ func TestAPIKeyService_DeleteAPIKey(t *testing.T) {
 			}
 
 			apiKeySvc := provideMockAPIKeyService(apiKeyRepo)
-			err := apiKeySvc.DeleteAPIKey(tc.args.id)
+			err := apiKeySvc.DeleteAPIKey(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -546,7 +592,7 @@ func TestAPIKeyService_DeleteAPIKeys(t *testing.T) {
 				ids: []string{"62fa734bfc1cdb7f06a3bf6f"},
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().DeleteAPIKeys(gomock.Any()).
+				apiKeyRepo.EXPECT().DeleteAPIKeys(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -557,7 +603,7 @@ func TestAPIKeyService_DeleteAPIKeys(t *testing.T) {
 				ids: []string{"abcd"},
 			},
 			stubFn: func(apiKeyRepo *mocks.MockIAPIKeyRepository) {
-				apiKeyRepo.EXPECT().DeleteAPIKeys(gomock.Any()).
+				apiKeyRepo.EXPECT().DeleteAPIKeys(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("error deleting api keys"))
 			},
 			wantErr:    true,
@@ -572,7 +618,7 @@ func TestAPIKeyService_DeleteAPIKeys(t *testing.T) {
 			}
 
 			apiKeySvc := provideMockAPIKeyService(apiKeyRepo)
-			err := apiKeySvc.DeleteAPIKeys(tc.args.ids)
+			err := apiKeySvc.DeleteAPIKeys(context.Background(), tc.args.ids)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/repository"
+	"keeper/internal/utils"
+	"keeper/pkg/log"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditService struct {
+	auditRepo  repository.IAuditEventRepository
+	bucketRepo repository.IBucketRepository
+	cfg        *config.Config
+}
+
+type IAuditService interface {
+	RecordAuditEvent(ctx context.Context, event *models.AuditEvent)
+	ListUserAuditEvents(ctx context.Context, userID string, queryParams url.Values) ([]models.AuditEvent, utils.PageInfo, error)
+	ListBucketAuditEvents(ctx context.Context, bucketUID string, userID string, queryParams url.Values) ([]models.AuditEvent, utils.PageInfo, error)
+	ExportUserAuditEvents(ctx context.Context, userID string, queryParams url.Values, w io.Writer) error
+	ExportBucketAuditEvents(ctx context.Context, bucketUID string, userID string, queryParams url.Values, w io.Writer) error
+}
+
+var (
+	ErrInvalidAuditTimeRange = models.NewBadRequestError(errors.New("audit 'from' and 'to' must be RFC 3339 times"))
+)
+
+func NewAuditService(cfg *config.Config, auditRepo repository.IAuditEventRepository, bucketRepo repository.IBucketRepository) IAuditService {
+	return &AuditService{
+		auditRepo:  auditRepo,
+		bucketRepo: bucketRepo,
+		cfg:        cfg,
+	}
+}
+
+type auditActorCtxKey struct{}
+
+// Returns ctx carrying the actor of the request it serves, the audit events recorded with ctx are attributed to it
+func WithAuditActor(ctx context.Context, actor models.AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorCtxKey{}, actor)
+}
+
+// Returns the actor carried by ctx, empty if none
+func AuditActorFromContext(ctx context.Context) models.AuditActor {
+	actor, _ := ctx.Value(auditActorCtxKey{}).(models.AuditActor)
+	return actor
+}
+
+// Records an event in the audit log, the actor fields left empty are the ones of the actor of ctx
+// events that cannot be recorded are only logged, the audited action has already happened
+func recordAuditEvent(ctx context.Context, auditRepo repository.IAuditEventRepository, event *models.AuditEvent) {
+	// the workers change data on their own, their actions are not audited
+	if auditRepo == nil {
+		return
+	}
+	actor := AuditActorFromContext(ctx)
+	if event.UserID.IsZero() {
+		event.UserID = actor.UserID
+	}
+	if event.CredentialType == "" {
+		event.CredentialType = actor.CredentialType
+	}
+	if event.APIKeyMaskID == "" {
+		event.APIKeyMaskID = actor.APIKeyMaskID
+	}
+	event.IP = actor.IP
+	event.UserAgent = actor.UserAgent
+	event.RequestID = log.RequestID(ctx)
+	event.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	auditRepo.CreateAuditEvent(ctx, event)
+}
+
+// Records an event in the audit log
+func (a *AuditService) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) {
+	recordAuditEvent(ctx, a.auditRepo, event)
+}
+
+// Lists a page of the audit events of a user, most recent first
+// Accepts the user ID and the query params: page, perPage, action, key, credential_type, api_key_mask_id, from and to
+func (a *AuditService) ListUserAuditEvents(ctx context.Context, userID string, queryParams url.Values) ([]models.AuditEvent, utils.PageInfo, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.ListUserAuditEvents")
+	defer span.End()
+	filter, err := userAuditFilter(userID, queryParams)
+	if err != nil {
+		return nil, utils.PageInfo{}, err
+	}
+	return a.listAuditEvents(ctx, filter, queryParams)
+}
+
+// Lists a page of the audit events of a bucket, most recent first, only its owner can read them
+// Accepts the bucket UID, the ID of the user reading them and the query params of ListUserAuditEvents
+func (a *AuditService) ListBucketAuditEvents(ctx context.Context, bucketUID string, userID string, queryParams url.Values) ([]models.AuditEvent, utils.PageInfo, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.ListBucketAuditEvents")
+	defer span.End()
+	filter, err := a.bucketAuditFilter(ctx, bucketUID, userID, queryParams)
+	if err != nil {
+		return nil, utils.PageInfo{}, err
+	}
+	return a.listAuditEvents(ctx, filter, queryParams)
+}
+
+// Writes the audit events of a user to w as newline-delimited JSON, oldest first
+func (a *AuditService) ExportUserAuditEvents(ctx context.Context, userID string, queryParams url.Values, w io.Writer) error {
+	ctx, span := tracing.Start(ctx, "AuditService.ExportUserAuditEvents")
+	defer span.End()
+	filter, err := userAuditFilter(userID, queryParams)
+	if err != nil {
+		return err
+	}
+	return a.exportAuditEvents(ctx, filter, w)
+}
+
+// Writes the audit events of a bucket to w as newline-delimited JSON, oldest first, only its owner can read them
+func (a *AuditService) ExportBucketAuditEvents(ctx context.Context, bucketUID string, userID string, queryParams url.Values, w io.Writer) error {
+	ctx, span := tracing.Start(ctx, "AuditService.ExportBucketAuditEvents")
+	defer span.End()
+	filter, err := a.bucketAuditFilter(ctx, bucketUID, userID, queryParams)
+	if err != nil {
+		return err
+	}
+	return a.exportAuditEvents(ctx, filter, w)
+}
+
+func (a *AuditService) listAuditEvents(ctx context.Context, filter bson.M, queryParams url.Values) ([]models.AuditEvent, utils.PageInfo, error) {
+	paginationParams, err := utils.ParsePaginationQueryParams(queryParams)
+	if err != nil {
+		return nil, utils.PageInfo{}, models.NewBadRequestError(err)
+	}
+	return a.auditRepo.FindAuditEventsPaged(ctx, filter, paginationParams)
+}
+
+func (a *AuditService) exportAuditEvents(ctx context.Context, filter bson.M, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	return a.auditRepo.IterateAuditEvents(ctx, filter, func(event *models.AuditEvent) error {
+		return encoder.Encode(event)
+	})
+}
+
+// Returns the filter of the audit events of a user
+func userAuditFilter(userID string, queryParams url.Values) (bson.M, error) {
+	ID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, models.ErrInvalidObjectID
+	}
+	return auditFilter(bson.M{"user_id": ID}, queryParams)
+}
+
+// Returns the filter of the audit events of a bucket after checking that the user owns it
+// the bucket of another user is not found rather than forbidden so that its existence is not revealed
+func (a *AuditService) bucketAuditFilter(ctx context.Context, bucketUID string, userID string, queryParams url.Values) (bson.M, error) {
+	if utils.IsStringEmpty(bucketUID) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	bucket, err := a.bucketRepo.FindBucketByUID(ctx, bucketUID)
+	if err != nil {
+		return nil, err
+	}
+	if bucket.UserID.Hex() != userID {
+		return nil, models.ErrBucketNotFound
+	}
+	return auditFilter(bson.M{"bucket_uid": bucketUID}, queryParams)
+}
+
+// Adds the filters of the query params to the filter of an audit log
+func auditFilter(filter bson.M, queryParams url.Values) (bson.M, error) {
+	for _, field := range []string{"action", "key", "credential_type", "api_key_mask_id"} {
+		if value := queryParams.Get(field); value != "" {
+			filter[field] = value
+		}
+	}
+	createdAt := bson.M{}
+	for param, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+		value := queryParams.Get(param)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, ErrInvalidAuditTimeRange
+		}
+		createdAt[op] = primitive.NewDateTimeFromTime(at)
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+	return filter, nil
+}
+
+// Records a change of a bucket item in the audit log, before or after is nil if the item did not exist
+func recordItemAuditEvent(ctx context.Context, auditRepo repository.IAuditEventRepository, action string, bucketUID string, key string, before *models.BucketItem, after *models.BucketItem) {
+	recordAuditEvent(ctx, auditRepo, &models.AuditEvent{
+		Action:    action,
+		BucketUID: bucketUID,
+		Key:       key,
+		Before:    bucketItemAuditSummary(before),
+		After:     bucketItemAuditSummary(after),
+	})
+}
+
+// Summarizes a bucket item for the audit log, its value is left out so that the log holds no secrets
+func bucketItemAuditSummary(item *models.BucketItem) map[string]interface{} {
+	if item == nil {
+		return nil
+	}
+	summary := map[string]interface{}{}
+	if item.Type != "" {
+		summary["type"] = item.Type
+	}
+	if item.TTL != 0 {
+		summary["ttl"] = item.TTL
+	}
+	if item.Size != 0 {
+		summary["size"] = item.Size
+	}
+	if item.Hash != "" {
+		summary["hash"] = item.Hash
+	}
+	return summary
+}
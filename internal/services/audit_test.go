@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"keeper/internal/utils"
+	"keeper/pkg/log"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func provideAuditService(mockAuditRepo *mocks.MockIAuditEventRepository, mockBucketRepo *mocks.MockIBucketRepository) IAuditService {
+	cfg := &config.Config{
+		Env: "test",
+	}
+	return NewAuditService(cfg, mockAuditRepo, mockBucketRepo)
+}
+
+func TestAuditService_RecordAuditEvent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	auditRepo := mocks.NewMockIAuditEventRepository(ctrl)
+	actor := models.AuditActor{
+		UserID:         primitive.NewObjectID(),
+		CredentialType: "api_key",
+		APIKeyMaskID:   "0123456789abcdef",
+		IP:             "203.0.113.7",
+		UserAgent:      "kipa-cli/1.0",
+	}
+	ctx := log.WithRequestID(WithAuditActor(context.Background(), actor), "request-1")
+
+	var recorded *models.AuditEvent
+	auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, event *models.AuditEvent) (primitive.ObjectID, error) {
+		recorded = event
+		return primitive.NewObjectID(), nil
+	})
+
+	provideAuditService(auditRepo, nil).RecordAuditEvent(ctx, &models.AuditEvent{
+		Action:    models.AuditActionItemUpdate,
+		BucketUID: "12345",
+		Key:       "a",
+	})
+
+	require.NotNil(t, recorded)
+	require.Equal(t, actor, recorded.AuditActor)
+	require.Equal(t, "request-1", recorded.RequestID)
+	require.Equal(t, models.AuditActionItemUpdate, recorded.Action)
+	require.NotZero(t, recorded.CreatedAt)
+}
+
+func TestAuditService_ListBucketAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	auditRepo := mocks.NewMockIAuditEventRepository(ctrl)
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	ownerID := primitive.NewObjectID()
+
+	tt := []struct {
+		name        string
+		userID      string
+		queryParams url.Values
+		stubFn      func(auditRepo *mocks.MockIAuditEventRepository, bucketRepo *mocks.MockIBucketRepository)
+		wantErr     bool
+		wantErrMsg  string
+	}{
+		{
+			name:        "should_successfully_list_bucket_audit_events",
+			userID:      ownerID.Hex(),
+			queryParams: url.Values{"action": {models.AuditActionItemUpdate}, "from": {"2023-01-01T00:00:00Z"}},
+			stubFn: func(auditRepo *mocks.MockIAuditEventRepository, bucketRepo *mocks.MockIBucketRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345", UserID: ownerID}, nil)
+				auditRepo.EXPECT().FindAuditEventsPaged(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(func(_ context.Context, filter bson.M, _ utils.PaginationParams) ([]models.AuditEvent, utils.PageInfo, error) {
+					require.Equal(t, "12345", filter["bucket_uid"])
+					require.Equal(t, models.AuditActionItemUpdate, filter["action"])
+					require.Contains(t, filter["created_at"], "$gte")
+					return []models.AuditEvent{{Action: models.AuditActionItemUpdate}}, utils.PageInfo{TotalItems: 1}, nil
+				})
+			},
+			wantErr: false,
+		},
+		{
+			name:        "should_fail_list_bucket_audit_events_of_another_user",
+			userID:      primitive.NewObjectID().Hex(),
+			queryParams: url.Values{},
+			stubFn: func(auditRepo *mocks.MockIAuditEventRepository, bucketRepo *mocks.MockIBucketRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345", UserID: ownerID}, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketNotFound.Error(),
+		},
+		{
+			name:        "should_fail_list_bucket_audit_events_invalid_time_range",
+			userID:      ownerID.Hex(),
+			queryParams: url.Values{"to": {"yesterday"}},
+			stubFn: func(auditRepo *mocks.MockIAuditEventRepository, bucketRepo *mocks.MockIBucketRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345", UserID: ownerID}, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: ErrInvalidAuditTimeRange.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(auditRepo, bucketRepo)
+			}
+			auditSvc := provideAuditService(auditRepo, bucketRepo)
+			events, _, err := auditSvc.ListBucketAuditEvents(context.Background(), "12345", tc.userID, tc.queryParams)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, tc.wantErrMsg, err.Error())
+				return
+			}
+			require.Nil(t, err)
+			require.Len(t, events, 1)
+		})
+	}
+}
+
+func TestAuditService_ExportUserAuditEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	auditRepo := mocks.NewMockIAuditEventRepository(ctrl)
+	userID := primitive.NewObjectID()
+	auditRepo.EXPECT().IterateAuditEvents(gomock.Any(), bson.M{"user_id": userID}, gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, _ bson.M, fn func(event *models.AuditEvent) error) error {
+		for _, action := range []string{models.AuditActionLogin, models.AuditActionAPIKeyCreate} {
+			if err := fn(&models.AuditEvent{Action: action, AuditActor: models.AuditActor{UserID: userID}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	out := &bytes.Buffer{}
+	err := provideAuditService(auditRepo, nil).ExportUserAuditEvents(context.Background(), userID.Hex(), url.Values{}, out)
+
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	for i, action := range []string{models.AuditActionLogin, models.AuditActionAPIKeyCreate} {
+		event := models.AuditEvent{}
+		require.Nil(t, json.Unmarshal([]byte(lines[i]), &event))
+		require.Equal(t, action, event.Action)
+	}
+}
+
+func TestAuditService_LoginEvents(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userRepo := mocks.NewMockIUserRepository(ctrl)
+	auditRepo := mocks.NewMockIAuditEventRepository(ctrl)
+	hashedPassword, _ := utils.HashPassword("secret")
+	user := &models.User{ID: primitive.NewObjectID(), Email: "testuser@gmail.com", Password: hashedPassword}
+	cfg := &config.Config{
+		Env:                      "test",
+		JwtSecretKey:             "secret",
+		AccessTokenJwtExpiresIn:  "30m",
+		RefreshTokenJwtExpiresIn: "7d",
+	}
+	authSvc := NewAuthService(cfg, userRepo, auditRepo, nil, nil, nil)
+
+	tt := []struct {
+		name       string
+		password   string
+		wantAction string
+	}{
+		{name: "should_record_login", password: "secret", wantAction: models.AuditActionLogin},
+		{name: "should_record_failed_login", password: "wrong", wantAction: models.AuditActionLoginFailed},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			userRepo.EXPECT().FindUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+			var recorded *models.AuditEvent
+			auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).
+				Times(1).DoAndReturn(func(_ context.Context, event *models.AuditEvent) (primitive.ObjectID, error) {
+				recorded = event
+				return primitive.NewObjectID(), nil
+			})
+			ctx := WithAuditActor(context.Background(), models.AuditActor{IP: "203.0.113.7"})
+
+			authSvc.Login(ctx, dto.LoginUserInputDTO{Email: user.Email, Password: tc.password})
+
+			require.NotNil(t, recorded)
+			require.Equal(t, tc.wantAction, recorded.Action)
+			require.Equal(t, user.ID, recorded.UserID)
+			require.Equal(t, models.AuditCredentialTypePassword, recorded.CredentialType)
+			require.Equal(t, "203.0.113.7", recorded.IP)
+			require.Equal(t, user.Email, recorded.Target)
+		})
+	}
+}
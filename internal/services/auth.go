@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"keeper/internal/auth/jwt"
@@ -8,6 +9,7 @@ import (
 	"keeper/internal/dto"
 	"keeper/internal/models"
 	"keeper/internal/pkg/mailer"
+	"keeper/internal/pkg/tracing"
 	"keeper/internal/queue"
 	"keeper/internal/queue/tasks"
 	"keeper/internal/repository"
@@ -20,72 +22,113 @@ import (
 )
 
 type AuthService struct {
-	userRepo repository.IUserRepository
-	jwtSvc   jwt.IJwtService
-	cfg      *config.Config
-	queue    *queue.RedisQueue
+	userRepo         repository.IUserRepository
+	auditRepo        repository.IAuditEventRepository
+	loginAttemptRepo repository.ILoginAttemptRepository
+	sessionRepo      repository.ISessionRepository
+	twoFactorRepo    repository.ITwoFactorRepository
+	jwtSvc           jwt.IJwtService
+	cfg              *config.Config
+	queue            *queue.RedisQueue
 }
 
 type IAuthService interface {
-	Login(data dto.LoginUserInputDTO) (*dto.LoginUserOutputDTO, error)
-	RefreshToken(user *models.User) (*dto.RefreshTokenOutputDTO, error)
-	ForgotPassword(data dto.ForgotPasswordInputDTO) error
-	ResetPassword(data dto.ResetPasswordInputDTO) error
+	Login(ctx context.Context, data dto.LoginUserInputDTO) (*dto.LoginUserOutputDTO, error)
+	RefreshToken(ctx context.Context, user *models.User, refreshToken string) (*dto.RefreshTokenOutputDTO, error)
+	ForgotPassword(ctx context.Context, data dto.ForgotPasswordInputDTO) error
+	ResetPassword(ctx context.Context, data dto.ResetPasswordInputDTO) error
+	UnlockLogin(ctx context.Context, data dto.UnlockLoginInputDTO) error
+	Logout(ctx context.Context, user *models.User, sessionID string) error
+	LogoutAll(ctx context.Context, user *models.User) (int64, error)
+	GetSessions(ctx context.Context, user *models.User, currentSessionID string) ([]dto.SessionOutputDTO, error)
+	LoginTwoFactor(ctx context.Context, data dto.TwoFactorLoginInputDTO) (*dto.LoginUserOutputDTO, error)
+	EnrollTwoFactor(ctx context.Context, user *models.User) (*dto.TwoFactorEnrollOutputDTO, error)
+	ConfirmTwoFactor(ctx context.Context, user *models.User, data dto.TwoFactorCodeInputDTO) (*dto.RecoveryCodesOutputDTO, error)
+	DisableTwoFactor(ctx context.Context, user *models.User, data dto.TwoFactorReauthInputDTO) error
+	RegenerateRecoveryCodes(ctx context.Context, user *models.User, data dto.TwoFactorReauthInputDTO) (*dto.RecoveryCodesOutputDTO, error)
 }
 
-func NewAuthService(cfg *config.Config, userRepo repository.IUserRepository) IAuthService {
-	jwtSvc := jwt.NewJwtService(cfg, userRepo)
+func NewAuthService(cfg *config.Config, userRepo repository.IUserRepository, auditRepo repository.IAuditEventRepository, loginAttemptRepo repository.ILoginAttemptRepository, sessionRepo repository.ISessionRepository, twoFactorRepo repository.ITwoFactorRepository) IAuthService {
+	jwtSvc := jwt.NewJwtService(cfg, userRepo, sessionRepo)
 	queue := queue.NewRedisQueue(cfg)
 	return &AuthService{
-		userRepo: userRepo,
-		cfg:      cfg,
-		jwtSvc:   jwtSvc,
-		queue:    queue,
+		userRepo:         userRepo,
+		auditRepo:        auditRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		sessionRepo:      sessionRepo,
+		twoFactorRepo:    twoFactorRepo,
+		cfg:              cfg,
+		jwtSvc:           jwtSvc,
+		queue:            queue,
 	}
 }
 
 // error constants
 var (
-	ErrEmailIsEmpty    = errors.New("email cannot be empty")
-	ErrPasswordIsEmpty = errors.New("password cannot be empty")
+	ErrEmailIsEmpty    = models.NewBadRequestError(errors.New("email cannot be empty"))
+	ErrPasswordIsEmpty = models.NewBadRequestError(errors.New("password cannot be empty"))
 )
 
 // Login user
 // returns access and refresh token
-func (s *AuthService) Login(data dto.LoginUserInputDTO) (*dto.LoginUserOutputDTO, error) {
+func (s *AuthService) Login(ctx context.Context, data dto.LoginUserInputDTO) (*dto.LoginUserOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	defer span.End()
 	if utils.IsStringEmpty(data.Email) {
 		return &dto.LoginUserOutputDTO{}, ErrEmailIsEmpty
 	}
 	if utils.IsStringEmpty(data.Password) {
 		return &dto.LoginUserOutputDTO{}, ErrPasswordIsEmpty
 	}
+	// the locked logins are rejected before the password is checked
+	if err := s.checkLoginLock(ctx, data.Email); err != nil {
+		return &dto.LoginUserOutputDTO{}, err
+	}
 	// find the user assigned to input email
-	user, err := s.userRepo.FindUserByEmail(data.Email)
+	user, err := s.userRepo.FindUserByEmail(ctx, data.Email)
 	if err != nil && !errors.Is(err, models.ErrUserNotFound) {
 		return &dto.LoginUserOutputDTO{}, err
 	}
+	// an unknown email and a wrong password fail the same way, so that the emails cannot be enumerated
 	if user == nil {
-		return &dto.LoginUserOutputDTO{}, models.ErrUserNotFound
+		// compare against a dummy hash so that an unknown email takes as long as a wrong password
+		_ = utils.ComparePasswordHash(data.Password, dummyPasswordHash())
+		s.recordLogin(ctx, models.AuditActionLoginFailed, data.Email, primitive.NilObjectID)
+		s.recordLoginFailure(ctx, data.Email, nil)
+		return &dto.LoginUserOutputDTO{}, models.ErrInvalidCredentials
 	}
 
 	// compare passwords
 	err = utils.ComparePasswordHash(data.Password, user.Password)
 	if err != nil {
-		return &dto.LoginUserOutputDTO{}, models.ErrIncorrectPassword
+		s.recordLogin(ctx, models.AuditActionLoginFailed, data.Email, user.ID)
+		s.recordLoginFailure(ctx, data.Email, user)
+		return &dto.LoginUserOutputDTO{}, models.ErrInvalidCredentials
 	}
 
-	// if passwords match, generate access and refresh token
-	payload := map[string]interface{}{"id": user.ID, "email": user.Email}
-	accessToken, err := s.jwtSvc.GenerateAccessToken(payload)
-	if err != nil {
-		logrus.WithError(err).Error("error generating access token")
-		return &dto.LoginUserOutputDTO{}, errors.New("error generating access token")
+	// with two-factor authentication, the password is exchanged for a short-lived token, and the tokens are only issued once a code is given
+	if user.TwoFactorEnabled && s.twoFactorRepo != nil {
+		twoFactorToken, err := s.jwtSvc.GenerateTwoFactorToken(map[string]interface{}{
+			"id":    user.ID.Hex(),
+			"email": user.Email,
+		})
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("error generating two-factor token")
+			return &dto.LoginUserOutputDTO{}, errors.New("error generating two-factor token")
+		}
+		return &dto.LoginUserOutputDTO{
+			TwoFactorRequired: true,
+			TwoFactorToken:    twoFactorToken,
+		}, nil
 	}
-	refreshToken, err := s.jwtSvc.GenerateRefreshToken(payload)
+
+	// if passwords match, start a session and generate its access and refresh token
+	accessToken, refreshToken, err := s.startSession(ctx, user)
 	if err != nil {
-		logrus.WithError(err).Error("error generating refresh token")
-		return &dto.LoginUserOutputDTO{}, errors.New("error generating refresh token")
+		return &dto.LoginUserOutputDTO{}, err
 	}
+	s.recordLogin(ctx, models.AuditActionLogin, data.Email, user.ID)
+	s.resetLoginFailures(ctx, data.Email)
 	return &dto.LoginUserOutputDTO{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
@@ -93,30 +136,45 @@ func (s *AuthService) Login(data dto.LoginUserInputDTO) (*dto.LoginUserOutputDTO
 }
 
 // Refresh token
-// returns a refreshed access token
-func (s *AuthService) RefreshToken(user *models.User) (*dto.RefreshTokenOutputDTO, error) {
+// returns a refreshed access token and the refresh token replacing the given one
+func (s *AuthService) RefreshToken(ctx context.Context, user *models.User, refreshToken string) (*dto.RefreshTokenOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.RefreshToken")
+	defer span.End()
 	if utils.IsStringEmpty(user.Email) {
 		return &dto.RefreshTokenOutputDTO{}, ErrEmailIsEmpty
 	}
 	if utils.IsStringEmpty(user.Password) {
 		return &dto.RefreshTokenOutputDTO{}, ErrPasswordIsEmpty
 	}
-	payload := map[string]interface{}{"id": user.ID, "email": user.Email}
-	accessToken, err := s.jwtSvc.GenerateAccessToken(payload)
+	// without sessions, the refresh token only refreshes the access token
+	if s.sessionRepo == nil {
+		payload := map[string]interface{}{"id": user.ID, "email": user.Email}
+		accessToken, err := s.jwtSvc.GenerateAccessToken(payload)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Error("error generating access token")
+			return &dto.RefreshTokenOutputDTO{}, errors.New("error generating access token")
+		}
+		s.recordTokenRefresh(ctx, user)
+		return &dto.RefreshTokenOutputDTO{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+	}
+	accessToken, newRefreshToken, err := s.rotateSession(ctx, user, refreshToken)
 	if err != nil {
-		logrus.WithError(err).Error("error generating access token")
-		return &dto.RefreshTokenOutputDTO{}, errors.New("error generating access token")
+		return &dto.RefreshTokenOutputDTO{}, err
 	}
+	s.recordTokenRefresh(ctx, user)
 	return &dto.RefreshTokenOutputDTO{
-		AccessToken: accessToken,
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
 	}, nil
 }
 
 // Forgot password
 // Accepts the user's email
-func (s *AuthService) ForgotPassword(data dto.ForgotPasswordInputDTO) error {
+func (s *AuthService) ForgotPassword(ctx context.Context, data dto.ForgotPasswordInputDTO) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ForgotPassword")
+	defer span.End()
 	// find the user
-	user, err := s.userRepo.FindUserByEmail(data.Email)
+	user, err := s.userRepo.FindUserByEmail(ctx, data.Email)
 	if err != nil {
 		return err
 	}
@@ -134,7 +192,7 @@ func (s *AuthService) ForgotPassword(data dto.ForgotPasswordInputDTO) error {
 	// send the reset password link to the user's mail
 	if s.cfg.Env != "test" {
 		if s.cfg.WithWorkers {
-			logrus.Info("sending with workers")
+			logrus.WithContext(ctx).Info("sending with workers")
 			sendResetPasswordMailTask, err := tasks.NewUserResetPasswordMailTask(
 				user.Email,
 				fmt.Sprintf("%s %s", user.Firstname, user.Lastname),
@@ -149,12 +207,12 @@ func (s *AuthService) ForgotPassword(data dto.ForgotPasswordInputDTO) error {
 			)
 
 			if err != nil {
-				logrus.WithError(err).Error(err.Error())
+				logrus.WithContext(ctx).WithError(err).Error(err.Error())
 			}
 
-			s.queue.Add(sendResetPasswordMailTask, asynq.Queue("critical"))
+			s.queue.Add(ctx, sendResetPasswordMailTask, asynq.Queue("critical"))
 		} else {
-			logrus.Info("sending without workers")
+			logrus.WithContext(ctx).Info("sending without workers")
 			mailSvc := mailer.NewMailer(s.cfg)
 
 			err = mailSvc.SendResetPasswordMail(
@@ -171,10 +229,10 @@ func (s *AuthService) ForgotPassword(data dto.ForgotPasswordInputDTO) error {
 			)
 
 			if err != nil {
-				logrus.WithError(err).Error(err.Error())
+				logrus.WithContext(ctx).WithError(err).Error(err.Error())
 				return err
 			}
-			logrus.Info("Successfully sent reset password link")
+			logrus.WithContext(ctx).Info("Successfully sent reset password link")
 			return nil
 		}
 	}
@@ -183,8 +241,10 @@ func (s *AuthService) ForgotPassword(data dto.ForgotPasswordInputDTO) error {
 
 // Reset password
 // Accepts the reset password token and the new password
-func (s *AuthService) ResetPassword(data dto.ResetPasswordInputDTO) error {
-	claims, err := s.jwtSvc.DecodeToken(data.Token, s.cfg.ResetPasswordTokenSecretKey)
+func (s *AuthService) ResetPassword(ctx context.Context, data dto.ResetPasswordInputDTO) error {
+	ctx, span := tracing.Start(ctx, "AuthService.ResetPassword")
+	defer span.End()
+	claims, err := s.jwtSvc.DecodeToken(data.Token, jwt.TokenTypeResetPassword)
 	if err != nil {
 		return nil
 	}
@@ -206,8 +266,54 @@ func (s *AuthService) ResetPassword(data dto.ResetPasswordInputDTO) error {
 	user.ID = ID
 	user.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 	// update the password
-	if err := s.userRepo.UpdateUser(user); err != nil {
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
 		return err
 	}
+	// the sessions opened with the previous password are closed
+	if s.sessionRepo != nil {
+		if _, err := s.sessionRepo.RevokeUserSessions(ctx, user.ID, models.SessionRevokedPasswordReset); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// Unlock login
+// Accepts the unlock token mailed to the user when its account was locked
+func (s *AuthService) UnlockLogin(ctx context.Context, data dto.UnlockLoginInputDTO) error {
+	ctx, span := tracing.Start(ctx, "AuthService.UnlockLogin")
+	defer span.End()
+	claims, err := s.jwtSvc.DecodeToken(data.Token, jwt.TokenTypeLoginUnlock)
+	if err != nil {
+		return models.ErrInvalidUnlockToken
+	}
+	email, _ := claims.Payload["email"].(string)
+	if purpose, _ := claims.Payload["purpose"].(string); purpose != loginUnlockTokenPurpose || utils.IsStringEmpty(email) {
+		return models.ErrInvalidUnlockToken
+	}
+	if s.loginAttemptRepo != nil {
+		if err := s.loginAttemptRepo.DeleteLoginAttempts(ctx, models.LoginAttemptEmailID(email)); err != nil {
+			return err
+		}
+	}
+	userID, _ := claims.Payload["id"].(string)
+	ID, _ := primitive.ObjectIDFromHex(userID)
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action:     models.AuditActionLoginUnlocked,
+		AuditActor: models.AuditActor{UserID: ID},
+		Target:     email,
+	})
+	return nil
+}
+
+// Records a login attempt in the audit log of the user owning the email, if any
+func (s *AuthService) recordLogin(ctx context.Context, action string, email string, userID primitive.ObjectID) {
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action: action,
+		AuditActor: models.AuditActor{
+			UserID:         userID,
+			CredentialType: models.AuditCredentialTypePassword,
+		},
+		Target: email,
+	})
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"keeper/internal/config"
 	"keeper/internal/dto"
 	"keeper/internal/mocks"
@@ -20,7 +21,7 @@ func provideMockAuthService(mockUserRepo *mocks.MockIUserRepository) IAuthServic
 		AccessTokenJwtExpiresIn:  "30m",
 		RefreshTokenJwtExpiresIn: "7d",
 	}
-	return NewAuthService(cfg, mockUserRepo)
+	return NewAuthService(cfg, mockUserRepo, nil, nil, nil, nil)
 }
 
 func TestAuthService_Login(t *testing.T) {
@@ -53,7 +54,7 @@ func TestAuthService_Login(t *testing.T) {
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
 				hashedPassword, _ := utils.HashPassword(userData.Password)
-				userRepo.EXPECT().FindUserByEmail(gomock.Any()).
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.User{
 					Password: hashedPassword,
 				}, nil)
@@ -90,11 +91,26 @@ func TestAuthService_Login(t *testing.T) {
 				data: userData,
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().FindUserByEmail(gomock.Any()).
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, models.ErrUserNotFound)
 			},
 			wantErr:    true,
-			wantErrMsg: models.ErrUserNotFound.Error(),
+			wantErrMsg: models.ErrInvalidCredentials.Error(),
+		},
+		{
+			name: "should_fail_login_user_incorrect_password",
+			args: args{
+				data: userData,
+			},
+			stubFn: func(userRepo *mocks.MockIUserRepository) {
+				hashedPassword, _ := utils.HashPassword("another secret")
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.User{
+					Password: hashedPassword,
+				}, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrInvalidCredentials.Error(),
 		},
 	}
 
@@ -105,7 +121,7 @@ func TestAuthService_Login(t *testing.T) {
 			}
 
 			authSvc := provideMockAuthService(userRepo)
-			out, err := authSvc.Login(tc.args.data)
+			out, err := authSvc.Login(context.Background(), tc.args.data)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -181,7 +197,7 @@ func TestAuthService_RefreshToken(t *testing.T) {
 			}
 
 			authSvc := provideMockAuthService(userRepo)
-			out, err := authSvc.RefreshToken(tc.args.user)
+			out, err := authSvc.RefreshToken(context.Background(), tc.args.user, "refresh-token")
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
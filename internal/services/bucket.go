@@ -1,11 +1,14 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"keeper/internal/config"
 	"keeper/internal/dto"
 	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/queue"
 	"keeper/internal/repository"
 	"keeper/internal/utils"
 	"net/url"
@@ -17,38 +20,65 @@ import (
 )
 
 type BucketService struct {
-	bucketRepo     repository.IBucketRepository
-	bucketItemRepo repository.IBucketItemRepository
-	cfg            *config.Config
+	bucketRepo         repository.IBucketRepository
+	bucketItemRepo     repository.IBucketItemRepository
+	bucketSnapshotRepo repository.IBucketSnapshotRepository
+	blobRepo           repository.IBucketItemBlobRepository
+	auditRepo          repository.IAuditEventRepository
+	usageRepo          repository.IUsageRepository
+	webhookRepo        repository.IWebhookRepository
+	webhookSvc         IWebhookService
+	cfg                *config.Config
+	queue              *queue.RedisQueue
 }
 
 type IBucketService interface {
-	CreateBucket(data dto.CreateBucketInputDTO, userID primitive.ObjectID) (*dto.CreateBucketOutputDTO, error)
-	FindBucketByID(id string) (*dto.BucketDetailsOutput, error)
-	FindBucketByUID(uid string) (*dto.BucketDetailsOutput, error)
-	ListUserBuckets(userID string) ([]dto.BucketDetailsOutput, error)
-	ListUserBucketsPaged(userID string, queryParams url.Values) ([]dto.BucketDetailsOutput, utils.PageInfo, error)
-	UpdateBucket(uid string, data dto.UpdateBucketInputDTO) error
-	DeleteBucket(uid string) error
+	CreateBucket(ctx context.Context, data dto.CreateBucketInputDTO, userID primitive.ObjectID) (*dto.CreateBucketOutputDTO, error)
+	FindBucketByID(ctx context.Context, id string) (*dto.BucketDetailsOutput, error)
+	FindBucketByUID(ctx context.Context, uid string) (*dto.BucketDetailsOutput, error)
+	ListUserBuckets(ctx context.Context, userID string) ([]dto.BucketDetailsOutput, error)
+	ListUserBucketsPaged(ctx context.Context, userID string, queryParams url.Values) ([]dto.BucketDetailsOutput, utils.PageInfo, error)
+	UpdateBucket(ctx context.Context, uid string, data dto.UpdateBucketInputDTO) error
+	SetBucketTwoFactor(ctx context.Context, uid string, user *models.User, required bool) error
+	DeleteBucket(ctx context.Context, uid string) error
+	ListTrashedBuckets(ctx context.Context, userID string) ([]models.Bucket, error)
+	RestoreBucket(ctx context.Context, uid string, userID primitive.ObjectID) error
+	PermanentlyDeleteBucket(ctx context.Context, uid string) error
+	CloneBucket(ctx context.Context, uid string, data dto.CloneBucketInputDTO, userID primitive.ObjectID) (*dto.CreateBucketOutputDTO, error)
+	CreateBucketSnapshot(ctx context.Context, uid string, data dto.CreateBucketSnapshotInputDTO, userID primitive.ObjectID) (*models.BucketSnapshot, error)
+	ListBucketSnapshots(ctx context.Context, uid string) ([]models.BucketSnapshot, error)
+	FindBucketSnapshot(ctx context.Context, uid string, name string) (*dto.BucketSnapshotDetailsOutput, error)
+	DeleteBucketSnapshot(ctx context.Context, uid string, name string) error
+	DiffBuckets(ctx context.Context, from dto.BucketRefDTO, to dto.BucketRefDTO) (*dto.BucketDiffOutputDTO, error)
+	MergeBuckets(ctx context.Context, uid string, data dto.MergeBucketInputDTO, userID primitive.ObjectID) (*dto.MergeBucketOutputDTO, error)
 }
 
-func NewBucketService(cfg *config.Config, bucketRepo repository.IBucketRepository, bucketItemRepo repository.IBucketItemRepository) IBucketService {
+func NewBucketService(cfg *config.Config, bucketRepo repository.IBucketRepository, bucketItemRepo repository.IBucketItemRepository, bucketSnapshotRepo repository.IBucketSnapshotRepository, blobRepo repository.IBucketItemBlobRepository, auditRepo repository.IAuditEventRepository, usageRepo repository.IUsageRepository, webhookRepo repository.IWebhookRepository, webhookSvc IWebhookService) IBucketService {
 	return &BucketService{
-		bucketRepo:     bucketRepo,
-		bucketItemRepo: bucketItemRepo,
-		cfg:            cfg,
+		bucketRepo:         bucketRepo,
+		bucketItemRepo:     bucketItemRepo,
+		bucketSnapshotRepo: bucketSnapshotRepo,
+		blobRepo:           blobRepo,
+		auditRepo:          auditRepo,
+		usageRepo:          usageRepo,
+		webhookRepo:        webhookRepo,
+		webhookSvc:         webhookSvc,
+		cfg:                cfg,
+		queue:              queue.NewRedisQueue(cfg),
 	}
 }
 
 var (
-	ErrBucketNameIsEmpty = errors.New("bucket name cannot be empty")
-	ErrBucketUIDIsEmpty  = errors.New("bucket uid cannot be empty")
-	ErrBucketIDIsEmpty   = errors.New("bucket id cannot be empty")
-	ErrUserIDIsEmpty     = errors.New("user id cannot be empty")
+	ErrBucketNameIsEmpty = models.NewBadRequestError(errors.New("bucket name cannot be empty"))
+	ErrBucketUIDIsEmpty  = models.NewBadRequestError(errors.New("bucket uid cannot be empty"))
+	ErrBucketIDIsEmpty   = models.NewBadRequestError(errors.New("bucket id cannot be empty"))
+	ErrUserIDIsEmpty     = models.NewBadRequestError(errors.New("user id cannot be empty"))
 )
 
 // Service for creating a new bucket
-func (b *BucketService) CreateBucket(data dto.CreateBucketInputDTO, userID primitive.ObjectID) (*dto.CreateBucketOutputDTO, error) {
+func (b *BucketService) CreateBucket(ctx context.Context, data dto.CreateBucketInputDTO, userID primitive.ObjectID) (*dto.CreateBucketOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.CreateBucket")
+	defer span.End()
 	if utils.IsStringEmpty(data.Name) {
 		return &dto.CreateBucketOutputDTO{}, ErrBucketNameIsEmpty
 	}
@@ -60,6 +90,9 @@ func (b *BucketService) CreateBucket(data dto.CreateBucketInputDTO, userID primi
 	} else {
 		permissions = data.Permissions
 	}
+	if err := reserveUsage(ctx, b.cfg, b.usageRepo, userID, "", models.UsageDelta{Buckets: 1}, 0); err != nil {
+		return &dto.CreateBucketOutputDTO{}, err
+	}
 
 	newBucket := &models.Bucket{
 		Name:        data.Name,
@@ -72,9 +105,10 @@ func (b *BucketService) CreateBucket(data dto.CreateBucketInputDTO, userID primi
 	newBucket.UID = newBucketUID
 	newBucket.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
 	newBucket.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-	id, err := b.bucketRepo.CreateBucket(newBucket)
+	id, err := b.bucketRepo.CreateBucket(ctx, newBucket)
 	if err != nil {
-		logrus.WithError(err).Error("error saving bucket to database")
+		recordUsage(ctx, b.usageRepo, userID, "", models.UsageDelta{Buckets: -1})
+		logrus.WithContext(ctx).WithError(err).Error("error saving bucket to database")
 		return &dto.CreateBucketOutputDTO{}, fmt.Errorf("error saving bucket to database: %s", err.Error())
 	}
 
@@ -89,91 +123,100 @@ func (b *BucketService) CreateBucket(data dto.CreateBucketInputDTO, userID primi
 }
 
 // Service for returning a bucket's details by ID
-func (b *BucketService) FindBucketByID(id string) (*dto.BucketDetailsOutput, error) {
+func (b *BucketService) FindBucketByID(ctx context.Context, id string) (*dto.BucketDetailsOutput, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.FindBucketByID")
+	defer span.End()
 	if utils.IsStringEmpty(id) {
 		return &dto.BucketDetailsOutput{}, ErrBucketIDIsEmpty
 	}
-	bucket, err := b.bucketRepo.FindBucketByID(id)
+	bucket, err := b.bucketRepo.FindBucketByID(ctx, id)
 	if err != nil {
 		return &dto.BucketDetailsOutput{}, err
 	}
 	// find the bucket items
-	bucketItems, err := b.bucketItemRepo.FindBucketItems(bucket.UID)
+	bucketItems, err := b.bucketItemRepo.FindBucketItems(ctx, bucket.UID)
 	if err != nil && !errors.Is(err, models.ErrBucketItemNotFound) {
 		return &dto.BucketDetailsOutput{}, err
 	}
 	return &dto.BucketDetailsOutput{
-		ID:          bucket.ID,
-		UID:         bucket.UID,
-		UserID:      bucket.UserID,
-		Name:        bucket.Name,
-		Description: bucket.Description,
-		Permissions: bucket.Permissions,
-		CreatedAt:   bucket.CreatedAt,
-		UpdatedAt:   bucket.UpdatedAt,
-		BucketItems: bucketItems,
+		ID:               bucket.ID,
+		UID:              bucket.UID,
+		UserID:           bucket.UserID,
+		Name:             bucket.Name,
+		Description:      bucket.Description,
+		Permissions:      bucket.Permissions,
+		RequireTwoFactor: bucket.RequireTwoFactor,
+		CreatedAt:        bucket.CreatedAt,
+		UpdatedAt:        bucket.UpdatedAt,
+		BucketItems:      bucketItems,
 	}, nil
 }
 
 // Service for returning a bucket's details by UID
-func (b *BucketService) FindBucketByUID(uid string) (*dto.BucketDetailsOutput, error) {
+func (b *BucketService) FindBucketByUID(ctx context.Context, uid string) (*dto.BucketDetailsOutput, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.FindBucketByUID")
+	defer span.End()
 	if utils.IsStringEmpty(uid) {
 		return &dto.BucketDetailsOutput{}, ErrBucketUIDIsEmpty
 	}
-	bucket, err := b.bucketRepo.FindBucketByUID(uid)
+	bucket, err := b.bucketRepo.FindBucketByUID(ctx, uid)
 	if err != nil {
 		return &dto.BucketDetailsOutput{}, err
 	}
 	// find the bucket items
-	bucketItems, err := b.bucketItemRepo.FindBucketItems(bucket.UID)
+	bucketItems, err := b.bucketItemRepo.FindBucketItems(ctx, bucket.UID)
 	if err != nil && !errors.Is(err, models.ErrBucketItemsNotFound) {
 		return &dto.BucketDetailsOutput{}, err
 	}
 	return &dto.BucketDetailsOutput{
-		ID:          bucket.ID,
-		UID:         bucket.UID,
-		UserID:      bucket.UserID,
-		Name:        bucket.Name,
-		Description: bucket.Description,
-		Permissions: bucket.Permissions,
-		CreatedAt:   bucket.CreatedAt,
-		UpdatedAt:   bucket.UpdatedAt,
-		BucketItems: bucketItems,
+		ID:               bucket.ID,
+		UID:              bucket.UID,
+		UserID:           bucket.UserID,
+		Name:             bucket.Name,
+		Description:      bucket.Description,
+		Permissions:      bucket.Permissions,
+		RequireTwoFactor: bucket.RequireTwoFactor,
+		CreatedAt:        bucket.CreatedAt,
+		UpdatedAt:        bucket.UpdatedAt,
+		BucketItems:      bucketItems,
 	}, nil
 }
 
 // Service for listing all a user's buckets with bucket items (using pagination and filtering)
-func (b *BucketService) ListUserBucketsPaged(userID string, queryParams url.Values) ([]dto.BucketDetailsOutput, utils.PageInfo, error) {
+func (b *BucketService) ListUserBucketsPaged(ctx context.Context, userID string, queryParams url.Values) ([]dto.BucketDetailsOutput, utils.PageInfo, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.ListUserBucketsPaged")
+	defer span.End()
 	if utils.IsStringEmpty(userID) {
 		return nil, utils.PageInfo{}, ErrUserIDIsEmpty
 	}
 	userBucketDetailsOutput := []dto.BucketDetailsOutput{}
 	filter, findOpts, paginationParams, err := utils.ParseRequestQueryParams(queryParams)
 	if err != nil {
-		return nil, utils.PageInfo{}, err
+		return nil, utils.PageInfo{}, models.NewBadRequestError(err)
 	}
 	// find all user's buckets
-	userBuckets, pageInfo, err := b.bucketRepo.FindBucketsByUserIDPaged(userID, filter, findOpts, paginationParams)
+	userBuckets, pageInfo, err := b.bucketRepo.FindBucketsByUserIDPaged(ctx, userID, filter, findOpts, paginationParams)
 	if err != nil {
 		return nil, utils.PageInfo{}, err
 	}
 	// construct the bucket detals response
 	for _, bucket := range userBuckets {
-		bucketItems, err := b.bucketItemRepo.FindBucketItems(bucket.UID)
+		bucketItems, err := b.bucketItemRepo.FindBucketItems(ctx, bucket.UID)
 		if err != nil && !errors.Is(err, models.ErrBucketItemsNotFound) {
-			logrus.WithError(err).Errorf("error fetching bucket items for bucket: %s", bucket.UID)
+			logrus.WithContext(ctx).WithError(err).Errorf("error fetching bucket items for bucket: %s", bucket.UID)
 			return nil, utils.PageInfo{}, err
 		}
 		bucketDetails := dto.BucketDetailsOutput{
-			ID:          bucket.ID,
-			UID:         bucket.UID,
-			UserID:      bucket.UserID,
-			Name:        bucket.Name,
-			Description: bucket.Description,
-			Permissions: bucket.Permissions,
-			CreatedAt:   bucket.CreatedAt,
-			UpdatedAt:   bucket.UpdatedAt,
-			BucketItems: bucketItems,
+			ID:               bucket.ID,
+			UID:              bucket.UID,
+			UserID:           bucket.UserID,
+			Name:             bucket.Name,
+			Description:      bucket.Description,
+			Permissions:      bucket.Permissions,
+			RequireTwoFactor: bucket.RequireTwoFactor,
+			CreatedAt:        bucket.CreatedAt,
+			UpdatedAt:        bucket.UpdatedAt,
+			BucketItems:      bucketItems,
 		}
 		// append single bucket's details to the final response
 		userBucketDetailsOutput = append(userBucketDetailsOutput, bucketDetails)
@@ -182,33 +225,36 @@ func (b *BucketService) ListUserBucketsPaged(userID string, queryParams url.Valu
 }
 
 // Service for listing all a user's buckets with bucket items
-func (b *BucketService) ListUserBuckets(userID string) ([]dto.BucketDetailsOutput, error) {
+func (b *BucketService) ListUserBuckets(ctx context.Context, userID string) ([]dto.BucketDetailsOutput, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.ListUserBuckets")
+	defer span.End()
 	if utils.IsStringEmpty(userID) {
 		return nil, ErrUserIDIsEmpty
 	}
 	userBucketDetailsOutput := []dto.BucketDetailsOutput{}
 	// find all user's buckets
-	userBuckets, err := b.bucketRepo.FindBucketsByUserID(userID)
+	userBuckets, err := b.bucketRepo.FindBucketsByUserID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 	// construct the bucket detals response
 	for _, bucket := range userBuckets {
-		bucketItems, err := b.bucketItemRepo.FindBucketItems(bucket.UID)
+		bucketItems, err := b.bucketItemRepo.FindBucketItems(ctx, bucket.UID)
 		if err != nil && !errors.Is(err, models.ErrBucketItemsNotFound) {
-			logrus.WithError(err).Errorf("error fetching bucket items for bucket: %s", bucket.UID)
+			logrus.WithContext(ctx).WithError(err).Errorf("error fetching bucket items for bucket: %s", bucket.UID)
 			return nil, err
 		}
 		bucketDetails := dto.BucketDetailsOutput{
-			ID:          bucket.ID,
-			UID:         bucket.UID,
-			UserID:      bucket.UserID,
-			Name:        bucket.Name,
-			Description: bucket.Description,
-			Permissions: bucket.Permissions,
-			CreatedAt:   bucket.CreatedAt,
-			UpdatedAt:   bucket.UpdatedAt,
-			BucketItems: bucketItems,
+			ID:               bucket.ID,
+			UID:              bucket.UID,
+			UserID:           bucket.UserID,
+			Name:             bucket.Name,
+			Description:      bucket.Description,
+			Permissions:      bucket.Permissions,
+			RequireTwoFactor: bucket.RequireTwoFactor,
+			CreatedAt:        bucket.CreatedAt,
+			UpdatedAt:        bucket.UpdatedAt,
+			BucketItems:      bucketItems,
 		}
 		// append single bucket's details to the final response
 		userBucketDetailsOutput = append(userBucketDetailsOutput, bucketDetails)
@@ -216,7 +262,9 @@ func (b *BucketService) ListUserBuckets(userID string) ([]dto.BucketDetailsOutpu
 	return userBucketDetailsOutput, nil
 }
 
-func (b *BucketService) UpdateBucket(uid string, data dto.UpdateBucketInputDTO) error {
+func (b *BucketService) UpdateBucket(ctx context.Context, uid string, data dto.UpdateBucketInputDTO) error {
+	ctx, span := tracing.Start(ctx, "BucketService.UpdateBucket")
+	defer span.End()
 	if utils.IsStringEmpty(uid) {
 		return ErrBucketUIDIsEmpty
 	}
@@ -226,26 +274,204 @@ func (b *BucketService) UpdateBucket(uid string, data dto.UpdateBucketInputDTO)
 		Permissions: data.Permissions,
 		UID:         uid,
 	}
+	// the previous permissions are kept for the audit log
+	var previous *models.Bucket
+	if data.Permissions != nil {
+		bucket, err := b.bucketRepo.FindBucketByUID(ctx, uid)
+		if err != nil {
+			return err
+		}
+		previous = bucket
+	}
 	updatedBucket.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-	err := b.bucketRepo.UpdateBucket(updatedBucket)
+	err := b.bucketRepo.UpdateBucket(ctx, updatedBucket)
 	if err != nil {
 		return err
 	}
+	if previous != nil {
+		recordAuditEvent(ctx, b.auditRepo, &models.AuditEvent{
+			Action:    models.AuditActionBucketPermissions,
+			BucketUID: uid,
+			Before:    map[string]interface{}{"permissions": previous.Permissions},
+			After:     map[string]interface{}{"permissions": data.Permissions},
+		})
+	}
 	return nil
 }
 
-func (b *BucketService) DeleteBucket(uid string) error {
+// Service for requiring (or no longer requiring) two-factor authentication to access a bucket
+func (b *BucketService) SetBucketTwoFactor(ctx context.Context, uid string, user *models.User, required bool) error {
+	ctx, span := tracing.Start(ctx, "BucketService.SetBucketTwoFactor")
+	defer span.End()
 	if utils.IsStringEmpty(uid) {
 		return ErrBucketUIDIsEmpty
 	}
+	bucket, err := b.bucketRepo.FindBucketByUID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	// only the owner can change the requirement
+	if bucket.UserID != user.ID {
+		return models.ErrBucketNotFound
+	}
+	// the owner could not access the bucket anymore without two-factor authentication
+	if required && !user.TwoFactorEnabled {
+		return models.ErrTwoFactorRequired
+	}
+	if bucket.RequireTwoFactor == required {
+		return nil
+	}
+	if err := b.bucketRepo.SetBucketRequireTwoFactor(ctx, uid, required); err != nil {
+		return err
+	}
+	recordAuditEvent(ctx, b.auditRepo, &models.AuditEvent{
+		Action:    models.AuditActionBucketTwoFactor,
+		BucketUID: uid,
+		Before:    map[string]interface{}{"require_two_factor": bucket.RequireTwoFactor},
+		After:     map[string]interface{}{"require_two_factor": required},
+	})
+	return nil
+}
+
+// Service for moving a bucket and its items to the trash
+// the bucket can be restored until it is purged after the trash retention period
+func (b *BucketService) DeleteBucket(ctx context.Context, uid string) error {
+	ctx, span := tracing.Start(ctx, "BucketService.DeleteBucket")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return ErrBucketUIDIsEmpty
+	}
+	var bucket *models.Bucket
+	if b.usageRepo != nil {
+		found, err := b.bucketRepo.FindBucketByUID(ctx, uid)
+		if err != nil {
+			return err
+		}
+		bucket = found
+	}
+	// the bucket and its items share the time of deletion so they can be restored together
+	deletedAt := primitive.NewDateTimeFromTime(time.Now())
+	err := b.bucketRepo.TrashBucketByUID(ctx, uid, deletedAt)
+	if err != nil {
+		return err
+	}
+	err = b.bucketItemRepo.TrashBucketItems(ctx, uid, deletedAt)
+	if !bucketItemsWritten(err) {
+		return err
+	}
+	if bucket != nil {
+		b.releaseBucketUsage(ctx, bucket)
+	}
+	return err
+}
+
+// Service for listing a user's trashed buckets
+func (b *BucketService) ListTrashedBuckets(ctx context.Context, userID string) ([]models.Bucket, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.ListTrashedBuckets")
+	defer span.End()
+	if utils.IsStringEmpty(userID) {
+		return nil, ErrUserIDIsEmpty
+	}
+	buckets, err := b.bucketRepo.FindTrashedBucketsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// Service for restoring a trashed bucket along with the items that were trashed with it
+func (b *BucketService) RestoreBucket(ctx context.Context, uid string, userID primitive.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "BucketService.RestoreBucket")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return ErrBucketUIDIsEmpty
+	}
+	bucket, err := b.bucketRepo.FindTrashedBucketByUID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	// only the owner can restore a bucket
+	if bucket.UserID != userID {
+		return models.ErrBucketNotFound
+	}
+	// the bucket is restored with the items trashed along with it
+	var usage models.UsageDelta
+	if b.usageRepo != nil {
+		usage, err = b.usageRepo.ComputeBucketUsage(ctx, uid, bucket.DeletedAt)
+		if err != nil {
+			return err
+		}
+		usage.Buckets = 1
+	}
+	if err := reserveUsage(ctx, b.cfg, b.usageRepo, bucket.UserID, uid, usage, 0); err != nil {
+		return err
+	}
+	err = b.bucketItemRepo.RestoreBucketItems(ctx, uid, bucket.DeletedAt)
+	if bucketItemsWritten(err) {
+		if restoreErr := b.bucketRepo.RestoreBucketByUID(ctx, uid); restoreErr != nil {
+			err = restoreErr
+		}
+	}
+	if !bucketItemsWritten(err) {
+		recordUsage(ctx, b.usageRepo, bucket.UserID, uid, usage.Negate())
+	}
+	return err
+}
+
+// Service for permanently deleting a bucket with all its items, snapshots and webhooks, skipping the trash
+func (b *BucketService) PermanentlyDeleteBucket(ctx context.Context, uid string) error {
+	ctx, span := tracing.Start(ctx, "BucketService.PermanentlyDeleteBucket")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return ErrBucketUIDIsEmpty
+	}
+	// a trashed bucket is already left out of the usage of its owner
+	var bucket *models.Bucket
+	if b.usageRepo != nil {
+		found, err := b.bucketRepo.FindBucketByUID(ctx, uid)
+		if err != nil && !errors.Is(err, models.ErrBucketNotFound) {
+			return err
+		}
+		bucket = found
+	}
+	// delete all the bucket snapshots
+	err := b.bucketSnapshotRepo.DeleteBucketSnapshots(ctx, uid)
+	if err != nil {
+		return err
+	}
+	// delete all the bucket webhooks and their deliveries
+	if b.webhookRepo != nil {
+		if err := b.webhookRepo.DeleteBucketWebhooks(ctx, uid); err != nil {
+			return err
+		}
+	}
 	// delete all the bucket items
-	err := b.bucketItemRepo.DeleteBucketItems(uid)
+	err = b.bucketItemRepo.DeleteBucketItems(ctx, uid)
 	if err != nil {
 		return err
 	}
-	err = b.bucketRepo.DeleteBucketByUID(uid)
+	err = b.bucketRepo.DeleteBucketByUID(ctx, uid)
 	if err != nil {
 		return err
 	}
+	if bucket != nil {
+		b.releaseBucketUsage(ctx, bucket)
+	}
 	return nil
 }
+
+// Removes a bucket and its items from the usage of its owner
+func (b *BucketService) releaseBucketUsage(ctx context.Context, bucket *models.Bucket) {
+	bucketUsage, err := b.usageRepo.FindBucketUsage(ctx, bucket.UID)
+	if err != nil {
+		return
+	}
+	recordUsage(ctx, b.usageRepo, bucket.UserID, "", models.UsageDelta{
+		Buckets: -1,
+		Items:   -bucketUsage.Items,
+		Bytes:   -bucketUsage.Bytes,
+	})
+	if err := b.usageRepo.DeleteBucketUsage(ctx, bucket.UID); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error deleting usage of bucket: %s", bucket.UID)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/repository"
+	"keeper/internal/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BucketChangeService struct {
+	bucketRepo       repository.IBucketRepository
+	bucketChangeRepo repository.IBucketChangeRepository
+	cfg              *config.Config
+}
+
+type IBucketChangeService interface {
+	ListBucketChanges(ctx context.Context, bucketUID string, since int64, limit int64) (*dto.BucketChangesOutputDTO, error)
+}
+
+const (
+	defaultBucketChangesLimit = 100
+	maxBucketChangesLimit     = 1000
+	// how long a missing sequence number is waited for before it is skipped,
+	// sequence numbers are reserved before their change is written so a concurrent write may still be in flight
+	bucketChangeGapTimeout = 5 * time.Second
+)
+
+var (
+	ErrInvalidChangeCursor = models.NewBadRequestError(errors.New("change cursor must not be negative"))
+)
+
+func NewBucketChangeService(cfg *config.Config, bucketRepo repository.IBucketRepository, bucketChangeRepo repository.IBucketChangeRepository) IBucketChangeService {
+	return &BucketChangeService{
+		bucketRepo:       bucketRepo,
+		bucketChangeRepo: bucketChangeRepo,
+		cfg:              cfg,
+	}
+}
+
+// Returns a page of the change feed of a bucket
+// Accepts the bucket UID, the sequence number to resume after and the maximum number of changes
+// the page stops before a missing sequence number so that a consumer never skips a change that is still being written,
+// and starts with a reset when the changes after the cursor have expired
+func (b *BucketChangeService) ListBucketChanges(ctx context.Context, bucketUID string, since int64, limit int64) (*dto.BucketChangesOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "BucketChangeService.ListBucketChanges")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	if since < 0 {
+		return nil, ErrInvalidChangeCursor
+	}
+	if limit <= 0 {
+		limit = defaultBucketChangesLimit
+	}
+	if limit > maxBucketChangesLimit {
+		limit = maxBucketChangesLimit
+	}
+	if _, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID); err != nil {
+		return nil, err
+	}
+	latestSeq, err := b.bucketChangeRepo.LatestChangeSeq(ctx, bucketUID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := b.bucketChangeRepo.FindChanges(ctx, bucketUID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := since
+	page := make([]models.BucketChange, 0, len(changes)+1)
+	expiredSeq, err := b.expiredChangeSeq(ctx, bucketUID, since, latestSeq, changes)
+	if err != nil {
+		return nil, err
+	}
+	// the consumer cannot replay the expired changes, it resyncs the whole bucket instead
+	if expiredSeq > since {
+		page = append(page, models.BucketChange{
+			BucketUID: bucketUID,
+			Seq:       expiredSeq,
+			Op:        models.BucketChangeOpReset,
+			CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		})
+		cursor = expiredSeq
+	}
+	for _, change := range changes {
+		if int64(len(page)) == limit {
+			break
+		}
+		// a change that was never written leaves a gap for good once the timeout has passed
+		if change.Seq != cursor+1 && time.Since(change.CreatedAt.Time()) < bucketChangeGapTimeout {
+			break
+		}
+		page = append(page, change)
+		cursor = change.Seq
+	}
+	return &dto.BucketChangesOutputDTO{
+		BucketUID:  bucketUID,
+		Changes:    page,
+		NextCursor: cursor,
+		HasMore:    cursor < latestSeq,
+		LatestSeq:  latestSeq,
+	}, nil
+}
+
+// Returns the sequence number of the last change after a cursor that has expired, the cursor if none has
+// the changes of a bucket expire in order so the ones before its oldest change are gone
+func (b *BucketChangeService) expiredChangeSeq(ctx context.Context, bucketUID string, since int64, latestSeq int64, changes []models.BucketChange) (int64, error) {
+	if since >= latestSeq || (len(changes) > 0 && changes[0].Seq == since+1) {
+		return since, nil
+	}
+	oldest, err := b.bucketChangeRepo.FindChanges(ctx, bucketUID, 0, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(oldest) == 0 {
+		return latestSeq, nil
+	}
+	// a recent change may follow one that is still being written rather than expired ones
+	if oldest[0].Seq <= since+1 || time.Since(oldest[0].CreatedAt.Time()) < bucketChangeGapTimeout {
+		return since, nil
+	}
+	return oldest[0].Seq - 1, nil
+}
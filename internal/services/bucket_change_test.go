@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"keeper/internal/config"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func provideBucketChangeService(mockBucketRepo *mocks.MockIBucketRepository, mockBucketChangeRepo *mocks.MockIBucketChangeRepository) IBucketChangeService {
+	cfg := &config.Config{
+		Env: "test",
+	}
+	return NewBucketChangeService(cfg, mockBucketRepo, mockBucketChangeRepo)
+}
+
+func TestBucketChangeService_ListBucketChanges(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketChangeRepo := mocks.NewMockIBucketChangeRepository(ctrl)
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	old := primitive.NewDateTimeFromTime(time.Now().Add(-time.Minute))
+
+	type args struct {
+		uid   string
+		since int64
+	}
+
+	tt := []struct {
+		name           string
+		args           args
+		stubFn         func(bucketRepo *mocks.MockIBucketRepository, bucketChangeRepo *mocks.MockIBucketChangeRepository)
+		wantSeqs       []int64
+		wantNextCursor int64
+		wantHasMore    bool
+		wantErr        bool
+		wantErrMsg     string
+	}{
+		{
+			name: "should_successfully_list_bucket_changes",
+			args: args{uid: "12345", since: 2},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketChangeRepo *mocks.MockIBucketChangeRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+				bucketChangeRepo.EXPECT().LatestChangeSeq(gomock.Any(), "12345").
+					Times(1).Return(int64(4), nil)
+				bucketChangeRepo.EXPECT().FindChanges(gomock.Any(), "12345", int64(2), int64(defaultBucketChangesLimit)).
+					Times(1).Return([]models.BucketChange{
+					{Seq: 3, Op: models.BucketChangeOpSet, Key: "a", CreatedAt: now},
+					{Seq: 4, Op: models.BucketChangeOpDelete, Key: "b", CreatedAt: now},
+				}, nil)
+			},
+			wantSeqs:       []int64{3, 4},
+			wantNextCursor: 4,
+			wantHasMore:    false,
+		},
+		{
+			name: "should_stop_before_recent_gap",
+			args: args{uid: "12345", since: 0},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketChangeRepo *mocks.MockIBucketChangeRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+				bucketChangeRepo.EXPECT().LatestChangeSeq(gomock.Any(), "12345").
+					Times(1).Return(int64(3), nil)
+				// change 2 may still be in flight
+				bucketChangeRepo.EXPECT().FindChanges(gomock.Any(), "12345", int64(0), int64(defaultBucketChangesLimit)).
+					Times(1).Return([]models.BucketChange{
+					{Seq: 1, Op: models.BucketChangeOpSet, Key: "a", CreatedAt: now},
+					{Seq: 3, Op: models.BucketChangeOpSet, Key: "c", CreatedAt: now},
+				}, nil)
+			},
+			wantSeqs:       []int64{1},
+			wantNextCursor: 1,
+			wantHasMore:    true,
+		},
+		{
+			name: "should_skip_stale_gap",
+			args: args{uid: "12345", since: 0},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketChangeRepo *mocks.MockIBucketChangeRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+				bucketChangeRepo.EXPECT().LatestChangeSeq(gomock.Any(), "12345").
+					Times(1).Return(int64(3), nil)
+				bucketChangeRepo.EXPECT().FindChanges(gomock.Any(), "12345", int64(0), int64(defaultBucketChangesLimit)).
+					Times(1).Return([]models.BucketChange{
+					{Seq: 1, Op: models.BucketChangeOpSet, Key: "a", CreatedAt: old},
+					{Seq: 3, Op: models.BucketChangeOpSet, Key: "c", CreatedAt: old},
+				}, nil)
+			},
+			wantSeqs:       []int64{1, 3},
+			wantNextCursor: 3,
+			wantHasMore:    false,
+		},
+		{
+			name: "should_reset_before_expired_changes",
+			args: args{uid: "12345", since: 2},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketChangeRepo *mocks.MockIBucketChangeRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+				bucketChangeRepo.EXPECT().LatestChangeSeq(gomock.Any(), "12345").
+					Times(1).Return(int64(6), nil)
+				// changes 3 and 4 have expired
+				bucketChangeRepo.EXPECT().FindChanges(gomock.Any(), "12345", int64(2), int64(defaultBucketChangesLimit)).
+					Times(1).Return([]models.BucketChange{
+					{Seq: 5, Op: models.BucketChangeOpSet, Key: "e", CreatedAt: old},
+					{Seq: 6, Op: models.BucketChangeOpSet, Key: "f", CreatedAt: old},
+				}, nil)
+				bucketChangeRepo.EXPECT().FindChanges(gomock.Any(), "12345", int64(0), int64(1)).
+					Times(1).Return([]models.BucketChange{
+					{Seq: 5, Op: models.BucketChangeOpSet, Key: "e", CreatedAt: old},
+				}, nil)
+			},
+			wantSeqs:       []int64{4, 5, 6},
+			wantNextCursor: 6,
+			wantHasMore:    false,
+		},
+		{
+			name: "should_reset_when_every_change_expired",
+			args: args{uid: "12345", since: 2},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketChangeRepo *mocks.MockIBucketChangeRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+				bucketChangeRepo.EXPECT().LatestChangeSeq(gomock.Any(), "12345").
+					Times(1).Return(int64(6), nil)
+				bucketChangeRepo.EXPECT().FindChanges(gomock.Any(), "12345", int64(2), int64(defaultBucketChangesLimit)).
+					Times(1).Return([]models.BucketChange{}, nil)
+				bucketChangeRepo.EXPECT().FindChanges(gomock.Any(), "12345", int64(0), int64(1)).
+					Times(1).Return([]models.BucketChange{}, nil)
+			},
+			wantSeqs:       []int64{6},
+			wantNextCursor: 6,
+			wantHasMore:    false,
+		},
+		{
+			name:       "should_fail_list_bucket_changes_negative_cursor",
+			args:       args{uid: "12345", since: -1},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrInvalidChangeCursor.Error(),
+		},
+		{
+			name: "should_fail_list_bucket_changes_bucket_not_found",
+			args: args{uid: "12345", since: 0},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketChangeRepo *mocks.MockIBucketChangeRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(nil, models.ErrBucketNotFound)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketNotFound.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketChangeRepo)
+			}
+
+			bucketChangeSvc := provideBucketChangeService(bucketRepo, bucketChangeRepo)
+			resp, err := bucketChangeSvc.ListBucketChanges(context.Background(), tc.args.uid, tc.args.since, 0)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+			seqs := []int64{}
+			for _, change := range resp.Changes {
+				seqs = append(seqs, change.Seq)
+			}
+			require.Equal(t, tc.wantSeqs, seqs)
+			require.Equal(t, tc.wantNextCursor, resp.NextCursor)
+			require.Equal(t, tc.wantHasMore, resp.HasMore)
+		})
+	}
+}
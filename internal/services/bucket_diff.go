@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/utils"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrMergeIntoItself = models.NewBadRequestError(errors.New("cannot merge a bucket into itself"))
+)
+
+// Service for comparing the items of two buckets or snapshots
+// Returns the keys added, removed and changed going from 'from' to 'to'
+func (b *BucketService) DiffBuckets(ctx context.Context, from dto.BucketRefDTO, to dto.BucketRefDTO) (*dto.BucketDiffOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.DiffBuckets")
+	defer span.End()
+	fromItems, err := b.findBucketRefItems(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	toItems, err := b.findBucketRefItems(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	diff := diffBucketItems(fromItems, toItems)
+	diff.From = from
+	diff.To = to
+	return diff, nil
+}
+
+// Service for merging the items of a bucket or snapshot into a bucket
+// the keys that differ are resolved with the merge strategy, replaced and deleted items are moved to the trash
+func (b *BucketService) MergeBuckets(ctx context.Context, uid string, data dto.MergeBucketInputDTO, userID primitive.ObjectID) (*dto.MergeBucketOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.MergeBuckets")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	strategy := data.Strategy
+	if utils.IsStringEmpty(strategy) {
+		strategy = models.MergeStrategyFail
+	}
+	source := dto.BucketRefDTO{BucketUID: data.SourceBucketUID, Snapshot: data.SourceSnapshot}
+	if utils.IsStringEmpty(source.BucketUID) {
+		source.BucketUID = uid
+	}
+	if source.BucketUID == uid && utils.IsStringEmpty(source.Snapshot) {
+		return nil, ErrMergeIntoItself
+	}
+	target, err := b.bucketRepo.FindBucketByUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := b.DiffBuckets(ctx, dto.BucketRefDTO{BucketUID: uid}, source)
+	if err != nil {
+		return nil, err
+	}
+	if strategy == models.MergeStrategyFail && len(diff.Changed) > 0 {
+		keys := make([]string, 0, len(diff.Changed))
+		for _, changed := range diff.Changed {
+			keys = append(keys, changed.Key)
+		}
+		return nil, fmt.Errorf("%w: %s", models.ErrMergeConflict, strings.Join(keys, ", "))
+	}
+
+	// the usage of the merge is reserved as a whole, once it is done the reservation is corrected
+	// by the usage of the writes that went through, even if it stops midway
+	reserved := mergeUsage(diff, strategy, data.DeleteMissing)
+	if err := reserveUsage(ctx, b.cfg, b.usageRepo, target.UserID, uid, reserved, mergeLargestValueBytes(diff, strategy)); err != nil {
+		return nil, err
+	}
+	var applied models.UsageDelta
+	defer func() {
+		recordUsage(ctx, b.usageRepo, target.UserID, uid, models.UsageDelta{
+			Items: applied.Items - reserved.Items,
+			Bytes: applied.Bytes - reserved.Bytes,
+		})
+	}()
+
+	result := &dto.MergeBucketOutputDTO{
+		Created: []string{},
+		Updated: []string{},
+		Deleted: []string{},
+		Skipped: []string{},
+	}
+	deletedAt := primitive.NewDateTimeFromTime(time.Now())
+	// the merge goes on when only the change feed misses a write, the error is returned once it is done
+	var recordErr error
+	for _, added := range diff.Added {
+		if err := b.mergeBucketItem(ctx, target, *added.To, userID); !bucketItemsWritten(err) {
+			return result, err
+		} else if err != nil {
+			recordErr = err
+		}
+		applied.Items++
+		applied.Bytes += added.To.UsageBytes()
+		recordItemAuditEvent(ctx, b.auditRepo, models.AuditActionItemCreate, uid, added.Key, nil, added.To)
+		publishItemEvent(ctx, b.cfg, b.queue, b.webhookSvc, models.WebhookEventItemCreated, mergedItem(target, added.To))
+		result.Created = append(result.Created, added.Key)
+	}
+	for _, changed := range diff.Changed {
+		if strategy == models.MergeStrategySkip {
+			result.Skipped = append(result.Skipped, changed.Key)
+			continue
+		}
+		// the replaced value is moved to the trash so that it can still be recovered,
+		// the key is unique among the live items so the replacement can only be created once it is trashed
+		if err := b.bucketItemRepo.TrashBucketItemByKeyName(ctx, uid, changed.Key, deletedAt); !bucketItemsWritten(err) {
+			return result, err
+		} else if err != nil {
+			recordErr = err
+		}
+		if err := b.mergeBucketItem(ctx, target, *changed.To, userID); !bucketItemsWritten(err) {
+			// the replaced value is restored, the key is not left without a value
+			if restoreErr := b.bucketItemRepo.RestoreBucketItemByID(ctx, changed.From.ID.Hex()); !bucketItemsWritten(restoreErr) {
+				logrus.WithContext(ctx).WithError(restoreErr).Errorf("error restoring bucket item %s replaced by a failed merge", changed.Key)
+				applied.Items--
+				applied.Bytes -= changed.From.UsageBytes()
+			}
+			return result, err
+		} else if err != nil {
+			recordErr = err
+		}
+		applied.Bytes += changed.To.UsageBytes() - changed.From.UsageBytes()
+		recordItemAuditEvent(ctx, b.auditRepo, models.AuditActionItemUpdate, uid, changed.Key, changed.From, changed.To)
+		publishItemEvent(ctx, b.cfg, b.queue, b.webhookSvc, models.WebhookEventItemUpdated, mergedItem(target, changed.To))
+		result.Updated = append(result.Updated, changed.Key)
+	}
+	if data.DeleteMissing {
+		for _, removed := range diff.Removed {
+			if err := b.bucketItemRepo.TrashBucketItemByKeyName(ctx, uid, removed.Key, deletedAt); !bucketItemsWritten(err) {
+				return result, err
+			} else if err != nil {
+				recordErr = err
+			}
+			applied.Items--
+			applied.Bytes -= removed.From.UsageBytes()
+			recordItemAuditEvent(ctx, b.auditRepo, models.AuditActionItemDelete, uid, removed.Key, removed.From, nil)
+			publishItemEvent(ctx, b.cfg, b.queue, b.webhookSvc, models.WebhookEventItemDeleted, &models.BucketItem{BucketUID: uid, Key: removed.Key})
+			result.Deleted = append(result.Deleted, removed.Key)
+		}
+	}
+	return result, recordErr
+}
+
+// Returns the change of the usage of the merge target once a diff is merged into it
+func mergeUsage(diff *dto.BucketDiffOutputDTO, strategy string, deleteMissing bool) models.UsageDelta {
+	var usage models.UsageDelta
+	for _, added := range diff.Added {
+		usage.Items++
+		usage.Bytes += added.To.UsageBytes()
+	}
+	if strategy != models.MergeStrategySkip {
+		for _, changed := range diff.Changed {
+			usage.Bytes += changed.To.UsageBytes() - changed.From.UsageBytes()
+		}
+	}
+	if deleteMissing {
+		for _, removed := range diff.Removed {
+			usage.Items--
+			usage.Bytes -= removed.From.UsageBytes()
+		}
+	}
+	return usage
+}
+
+// Returns the largest value written by the merge of a diff, in bytes
+func mergeLargestValueBytes(diff *dto.BucketDiffOutputDTO, strategy string) int64 {
+	written := make([]models.BucketItem, 0, len(diff.Added)+len(diff.Changed))
+	for _, added := range diff.Added {
+		written = append(written, *added.To)
+	}
+	if strategy != models.MergeStrategySkip {
+		for _, changed := range diff.Changed {
+			written = append(written, *changed.To)
+		}
+	}
+	return largestValueBytes(written)
+}
+
+// Returns a source bucket item as it is stored in the merge target, for its events
+func mergedItem(target *models.Bucket, item *models.BucketItem) *models.BucketItem {
+	merged := *item
+	merged.BucketUID = target.UID
+	return &merged
+}
+
+// Stores a copy of a source bucket item in the merge target
+func (b *BucketService) mergeBucketItem(ctx context.Context, target *models.Bucket, item models.BucketItem, userID primitive.ObjectID) error {
+	copied, err := b.copyBucketItem(ctx, item)
+	if err != nil {
+		return err
+	}
+	copied.BucketID = target.ID
+	copied.BucketUID = target.UID
+	copied.UserID = userID
+	_, err = b.bucketItemRepo.CreateBucketItem(ctx, &copied)
+	if !bucketItemsWritten(err) {
+		b.deleteCopiedBlobs([]models.BucketItem{copied})
+	}
+	return err
+}
+
+// Compares two lists of bucket items by key, the entries of each list are sorted by key
+func diffBucketItems(fromItems []models.BucketItem, toItems []models.BucketItem) *dto.BucketDiffOutputDTO {
+	diff := &dto.BucketDiffOutputDTO{
+		Added:   []dto.BucketItemDiffDTO{},
+		Removed: []dto.BucketItemDiffDTO{},
+		Changed: []dto.BucketItemDiffDTO{},
+	}
+	fromByKey := make(map[string]*models.BucketItem, len(fromItems))
+	for i := range fromItems {
+		fromByKey[fromItems[i].Key] = &fromItems[i]
+	}
+	toByKey := make(map[string]*models.BucketItem, len(toItems))
+	for i := range toItems {
+		toByKey[toItems[i].Key] = &toItems[i]
+	}
+	for key, to := range toByKey {
+		from, ok := fromByKey[key]
+		if !ok {
+			diff.Added = append(diff.Added, dto.BucketItemDiffDTO{Key: key, To: to})
+			continue
+		}
+		if !bucketItemValuesEqual(from, to) {
+			diff.Changed = append(diff.Changed, dto.BucketItemDiffDTO{Key: key, From: from, To: to})
+		}
+	}
+	for key, from := range fromByKey {
+		if _, ok := toByKey[key]; !ok {
+			diff.Removed = append(diff.Removed, dto.BucketItemDiffDTO{Key: key, From: from})
+		}
+	}
+	for _, entries := range [][]dto.BucketItemDiffDTO{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	}
+	return diff
+}
+
+// Checks if two bucket items hold the same value
+// binary values are compared by their hash, since large ones are not loaded
+func bucketItemValuesEqual(a *models.BucketItem, b *models.BucketItem) bool {
+	if a.Type != b.Type || a.TTL != b.TTL || a.ContentType != b.ContentType {
+		return false
+	}
+	if a.Hash != "" || b.Hash != "" {
+		return a.Hash == b.Hash
+	}
+	return reflect.DeepEqual(a.Data, b.Data)
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/dto"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBucketService_DiffBuckets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	bucketSnapshotRepo := mocks.NewMockIBucketSnapshotRepository(ctrl)
+
+	stagingItems := []models.BucketItem{
+		{Key: "feature_flags", Data: primitive.D{{Key: "dark_mode", Value: true}}},
+		{Key: "api_url", Data: "https://staging.example.com"},
+		{Key: "retries", Data: int32(3)},
+	}
+	prodItems := []models.BucketItem{
+		{Key: "feature_flags", Data: primitive.D{{Key: "dark_mode", Value: false}}},
+		{Key: "api_url", Data: "https://staging.example.com"},
+		{Key: "timeout", Data: int32(30)},
+	}
+
+	type args struct {
+		from dto.BucketRefDTO
+		to   dto.BucketRefDTO
+	}
+
+	tt := []struct {
+		name        string
+		args        args
+		stubFn      func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, bucketSnapshotRepo *mocks.MockIBucketSnapshotRepository)
+		wantAdded   []string
+		wantRemoved []string
+		wantChanged []string
+		wantErr     bool
+		wantErrMsg  string
+	}{
+		{
+			name: "should_successfully_diff_buckets",
+			args: args{
+				from: dto.BucketRefDTO{BucketUID: "staging"},
+				to:   dto.BucketRefDTO{BucketUID: "prod"},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, bucketSnapshotRepo *mocks.MockIBucketSnapshotRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(2).Return(&models.Bucket{}, nil)
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "staging").
+					Times(1).Return(stagingItems, nil)
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "prod").
+					Times(1).Return(prodItems, nil)
+			},
+			wantAdded:   []string{"timeout"},
+			wantRemoved: []string{"retries"},
+			wantChanged: []string{"feature_flags"},
+		},
+		{
+			name: "should_successfully_diff_snapshot_with_bucket",
+			args: args{
+				from: dto.BucketRefDTO{BucketUID: "staging", Snapshot: "v1"},
+				to:   dto.BucketRefDTO{BucketUID: "staging"},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, bucketSnapshotRepo *mocks.MockIBucketSnapshotRepository) {
+				snapshotID := primitive.NewObjectID()
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "staging").
+					Times(2).Return(&models.Bucket{}, nil)
+				bucketSnapshotRepo.EXPECT().FindSnapshotByName(gomock.Any(), "staging", "v1").
+					Times(1).Return(&models.BucketSnapshot{ID: snapshotID}, nil)
+				bucketSnapshotRepo.EXPECT().FindSnapshotItems(gomock.Any(), snapshotID).
+					Times(1).Return(stagingItems, nil)
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "staging").
+					Times(1).Return(stagingItems, nil)
+			},
+			wantAdded:   []string{},
+			wantRemoved: []string{},
+			wantChanged: []string{},
+		},
+		{
+			name: "should_fail_diff_snapshot_not_found",
+			args: args{
+				from: dto.BucketRefDTO{BucketUID: "staging", Snapshot: "v2"},
+				to:   dto.BucketRefDTO{BucketUID: "prod"},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, bucketSnapshotRepo *mocks.MockIBucketSnapshotRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "staging").
+					Times(1).Return(&models.Bucket{}, nil)
+				bucketSnapshotRepo.EXPECT().FindSnapshotByName(gomock.Any(), "staging", "v2").
+					Times(1).Return(nil, models.ErrSnapshotNotFound)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrSnapshotNotFound.Error(),
+		},
+		{
+			name: "should_fail_diff_empty_bucket_uid",
+			args: args{
+				from: dto.BucketRefDTO{},
+				to:   dto.BucketRefDTO{BucketUID: "prod"},
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrBucketUIDIsEmpty.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo, bucketSnapshotRepo)
+			}
+
+			bucketSvc := provideBucketServiceWithSnapshotRepo(bucketRepo, bucketItemRepo, bucketSnapshotRepo, nil)
+			diff, err := bucketSvc.DiffBuckets(context.Background(), tc.args.from, tc.args.to)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, tc.wantAdded, diffKeys(diff.Added))
+			require.Equal(t, tc.wantRemoved, diffKeys(diff.Removed))
+			require.Equal(t, tc.wantChanged, diffKeys(diff.Changed))
+		})
+	}
+}
+
+func TestBucketService_MergeBuckets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	bucketSnapshotRepo := mocks.NewMockIBucketSnapshotRepository(ctrl)
+
+	userID := primitive.NewObjectID()
+	prod := &models.Bucket{ID: primitive.NewObjectID(), UID: "prod"}
+	errMergeWriteFailed := errors.New("write failed")
+	stagingItems := []models.BucketItem{
+		{Key: "api_url", Data: "https://api.example.com"},
+		{Key: "retries", Data: int32(5)},
+	}
+	prodItems := []models.BucketItem{
+		{Key: "api_url", Data: "https://api.example.com"},
+		{ID: primitive.NewObjectID(), Key: "retries", Data: int32(3)},
+		{Key: "legacy", Data: true},
+	}
+
+	// stubs the lookups of a merge of the staging bucket into the prod bucket
+	stubMergeDiff := func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+		bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "prod").
+			Times(2).Return(prod, nil)
+		bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "staging").
+			Times(1).Return(&models.Bucket{UID: "staging"}, nil)
+		bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "prod").
+			Times(1).Return(prodItems, nil)
+		bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "staging").
+			Times(1).Return(stagingItems, nil)
+	}
+
+	type args struct {
+		uid  string
+		data dto.MergeBucketInputDTO
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository)
+		want       *dto.MergeBucketOutputDTO
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_merge_buckets_overwrite",
+			args: args{
+				uid:  "prod",
+				data: dto.MergeBucketInputDTO{SourceBucketUID: "staging", Strategy: models.MergeStrategyOverwrite, DeleteMissing: true},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				stubMergeDiff(bucketRepo, bucketItemRepo)
+				// the replaced value is trashed before the source value is stored
+				gomock.InOrder(
+					bucketItemRepo.EXPECT().TrashBucketItemByKeyName(gomock.Any(), "prod", "retries", gomock.Any()).
+						Times(1).Return(nil),
+					bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+						Times(1).DoAndReturn(func(_ context.Context, bucketItem *models.BucketItem) (primitive.ObjectID, error) {
+						require.Equal(t, "prod", bucketItem.BucketUID)
+						require.Equal(t, prod.ID, bucketItem.BucketID)
+						require.Equal(t, userID, bucketItem.UserID)
+						require.Equal(t, int32(5), bucketItem.Data)
+						return primitive.NewObjectID(), nil
+					}),
+				)
+				bucketItemRepo.EXPECT().TrashBucketItemByKeyName(gomock.Any(), "prod", "legacy", gomock.Any()).
+					Times(1).Return(nil)
+			},
+			want: &dto.MergeBucketOutputDTO{
+				Created: []string{},
+				Updated: []string{"retries"},
+				Deleted: []string{"legacy"},
+				Skipped: []string{},
+			},
+		},
+		{
+			name: "should_restore_the_replaced_item_when_its_replacement_fails",
+			args: args{
+				uid:  "prod",
+				data: dto.MergeBucketInputDTO{SourceBucketUID: "staging", Strategy: models.MergeStrategyOverwrite},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				stubMergeDiff(bucketRepo, bucketItemRepo)
+				gomock.InOrder(
+					bucketItemRepo.EXPECT().TrashBucketItemByKeyName(gomock.Any(), "prod", "retries", gomock.Any()).
+						Times(1).Return(nil),
+					bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+						Times(1).Return(primitive.NilObjectID, errMergeWriteFailed),
+					bucketItemRepo.EXPECT().RestoreBucketItemByID(gomock.Any(), prodItems[1].ID.Hex()).
+						Times(1).Return(nil),
+				)
+			},
+			wantErr:    true,
+			wantErrMsg: errMergeWriteFailed.Error(),
+		},
+		{
+			name: "should_successfully_merge_buckets_skip",
+			args: args{
+				uid:  "prod",
+				data: dto.MergeBucketInputDTO{SourceBucketUID: "staging", Strategy: models.MergeStrategySkip},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				stubMergeDiff(bucketRepo, bucketItemRepo)
+			},
+			want: &dto.MergeBucketOutputDTO{
+				Created: []string{},
+				Updated: []string{},
+				Deleted: []string{},
+				Skipped: []string{"retries"},
+			},
+		},
+		{
+			name: "should_fail_merge_buckets_conflict",
+			args: args{
+				uid:  "prod",
+				data: dto.MergeBucketInputDTO{SourceBucketUID: "staging"},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				stubMergeDiff(bucketRepo, bucketItemRepo)
+			},
+			wantErr:    true,
+			wantErrMsg: "merge conflict: retries",
+		},
+		{
+			name: "should_fail_merge_bucket_into_itself",
+			args: args{
+				uid:  "prod",
+				data: dto.MergeBucketInputDTO{},
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrMergeIntoItself.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo)
+			}
+
+			bucketSvc := provideBucketServiceWithSnapshotRepo(bucketRepo, bucketItemRepo, bucketSnapshotRepo, nil)
+			result, err := bucketSvc.MergeBuckets(context.Background(), tc.args.uid, tc.args.data, userID)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, tc.want, result)
+		})
+	}
+}
+
+// returns the keys of a list of diff entries
+func diffKeys(entries []dto.BucketItemDiffDTO) []string {
+	keys := []string{}
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	return keys
+}
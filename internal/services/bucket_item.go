@@ -1,57 +1,93 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"keeper/internal/config"
 	"keeper/internal/dto"
 	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/queue"
+	"keeper/internal/queue/tasks"
 	"keeper/internal/repository"
 	"keeper/internal/utils"
 	"net/url"
 	"time"
 
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type BucketItemService struct {
 	bucketItemRepo repository.IBucketItemRepository
 	bucketRepo     repository.IBucketRepository
+	blobRepo       repository.IBucketItemBlobRepository
+	auditRepo      repository.IAuditEventRepository
+	usageRepo      repository.IUsageRepository
+	webhookSvc     IWebhookService
 	cfg            *config.Config
+	queue          *queue.RedisQueue
 }
 
 type IBucketItemService interface {
-	CreateBucketItem(data dto.CreateBucketItemInputDTO, userID primitive.ObjectID, bucketUID string) (*dto.CreateBucketItemOutputDTO, error)
-	UpdateBucketItemByKeyName(data dto.UpdateBucketItemInputDTO, bucketUID string, key string) error
-	DeleteBucketItemById(id string) error
-	DeleteBucketItemsById(id []string) error
-	DeleteBucketItems(bucketUID string) error
-	DeleteBucketItemByKeyName(bucketUID string, key string) error
-	DeleteBucketItemsByKeyName(bucketUID string, keys []string) error
-	IncrementIntValue(bucketUID string, key string, amount int) error
-	FindBucketItemByID(id string) (*models.BucketItem, error)
-	FindBucketItemByKeyName(bucketUID string, key string) (*models.BucketItem, error)
-	ListBucketItems(bucketUID string) ([]models.BucketItem, error)
-	ListBucketItemsPaged(queryParams url.Values) ([]models.BucketItem, utils.PageInfo, error)
-}
-
-func NewBucketItemService(cfg *config.Config, bucketItemRepo repository.IBucketItemRepository, bucketRepo repository.IBucketRepository) IBucketItemService {
+	CreateBucketItem(ctx context.Context, data dto.CreateBucketItemInputDTO, userID primitive.ObjectID, bucketUID string) (*dto.CreateBucketItemOutputDTO, error)
+	CreateBinaryBucketItem(ctx context.Context, data dto.CreateBinaryBucketItemInputDTO, source io.Reader, userID primitive.ObjectID, bucketUID string) (*dto.CreateBucketItemOutputDTO, error)
+	OpenBinaryBucketItem(ctx context.Context, bucketUID string, key string) (*dto.BinaryBucketItemContentDTO, error)
+	UpdateBucketItemByKeyName(ctx context.Context, data dto.UpdateBucketItemInputDTO, bucketUID string, key string) error
+	DeleteBucketItemById(ctx context.Context, id string) error
+	DeleteBucketItemsById(ctx context.Context, id []string) error
+	DeleteBucketItems(ctx context.Context, bucketUID string) error
+	DeleteBucketItemByKeyName(ctx context.Context, bucketUID string, key string) error
+	DeleteBucketItemsByKeyName(ctx context.Context, bucketUID string, keys []string) error
+	IncrementIntValue(ctx context.Context, bucketUID string, key string, amount int) error
+	FindBucketItemByID(ctx context.Context, id string) (*models.BucketItem, error)
+	FindBucketItemByKeyName(ctx context.Context, bucketUID string, key string) (*models.BucketItem, error)
+	ListBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error)
+	ListBucketItemsPaged(ctx context.Context, queryParams url.Values) ([]models.BucketItem, utils.PageInfo, error)
+	GetCompressionStats(ctx context.Context, bucketUID string) (*models.BucketItemCompressionStats, error)
+	CompressBucketItems(ctx context.Context, bucketUID string) error
+	ExpireBucketItems(ctx context.Context) (int64, error)
+	PurgeExpiredBucketItems(ctx context.Context) (int64, error)
+	ListTrashedBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error)
+	RestoreBucketItem(ctx context.Context, bucketUID string, id string) error
+	PermanentlyDeleteBucketItem(ctx context.Context, bucketUID string, id string) error
+}
+
+func NewBucketItemService(cfg *config.Config, bucketItemRepo repository.IBucketItemRepository, bucketRepo repository.IBucketRepository, blobRepo repository.IBucketItemBlobRepository, auditRepo repository.IAuditEventRepository, usageRepo repository.IUsageRepository, webhookSvc IWebhookService) IBucketItemService {
 	return &BucketItemService{
 		bucketItemRepo: bucketItemRepo,
 		bucketRepo:     bucketRepo,
+		blobRepo:       blobRepo,
+		auditRepo:      auditRepo,
+		usageRepo:      usageRepo,
+		webhookSvc:     webhookSvc,
 		cfg:            cfg,
+		queue:          queue.NewRedisQueue(cfg),
 	}
 }
 
 var (
-	ErrKeyIsEmpty          = errors.New("bucket item key cannot be empty")
-	ErrBucketItemIDIsEmpty = errors.New("bucket item id cannot be empty")
+	ErrKeyIsEmpty          = models.NewBadRequestError(errors.New("bucket item key cannot be empty"))
+	ErrBucketItemIDIsEmpty = models.NewBadRequestError(errors.New("bucket item id cannot be empty"))
 )
 
+// number of expired bucket items marked at a time by the expiry sweep
+const bucketItemExpiryBatchSize = 500
+
 // Creates a new bucket item
 // Accepts the bucket item input data, user ID, and bucket UID
 // Returns a success response and error
-func (b *BucketItemService) CreateBucketItem(data dto.CreateBucketItemInputDTO, userID primitive.ObjectID, bucketUID string) (*dto.CreateBucketItemOutputDTO, error) {
+func (b *BucketItemService) CreateBucketItem(ctx context.Context, data dto.CreateBucketItemInputDTO, userID primitive.ObjectID, bucketUID string) (*dto.CreateBucketItemOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.CreateBucketItem")
+	defer span.End()
 	// validation
 	if utils.IsStringEmpty(data.Key) {
 		return &dto.CreateBucketItemOutputDTO{}, ErrKeyIsEmpty
@@ -60,21 +96,17 @@ func (b *BucketItemService) CreateBucketItem(data dto.CreateBucketItemInputDTO,
 		return &dto.CreateBucketItemOutputDTO{}, ErrBucketUIDIsEmpty
 	}
 	// check if the bucket UID exists
-	bucket, err := b.bucketRepo.FindBucketByUID(bucketUID)
+	bucket, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID)
 
 	if err != nil {
 		return &dto.CreateBucketItemOutputDTO{}, err
 	}
 
-	// enforce unique key
-	_, err = b.bucketItemRepo.FindBucketItemByKeyName(bucketUID, data.Key)
-
-	if !errors.Is(err, models.ErrBucketItemNotFound) {
-		return &dto.CreateBucketItemOutputDTO{}, fmt.Errorf("key '%s' already exists", data.Key)
+	// enforce unique key, concurrent creations are settled by the unique index on the keys
+	if err := b.checkKeyIsFree(ctx, bucketUID, data.Key); err != nil {
+		return &dto.CreateBucketItemOutputDTO{}, err
 	}
 
-	fmt.Println(data.Data, utils.TypeOf(data.Data))
-
 	// compute the data type
 	dataType := utils.TypeOf(data.Data)
 
@@ -87,12 +119,20 @@ func (b *BucketItemService) CreateBucketItem(data dto.CreateBucketItemInputDTO,
 		TTL:       data.TTL,
 		Type:      dataType,
 	}
+	// the usage is charged to the owner of the bucket
+	usage := models.UsageDelta{Items: 1, Bytes: newBucketItem.UsageBytes()}
+	if err := reserveUsage(ctx, b.cfg, b.usageRepo, bucket.UserID, bucketUID, usage, usage.Bytes); err != nil {
+		return &dto.CreateBucketItemOutputDTO{}, err
+	}
 	newBucketItem.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
 	newBucketItem.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-	id, err := b.bucketItemRepo.CreateBucketItem(newBucketItem)
-	if err != nil {
+	id, err := b.bucketItemRepo.CreateBucketItem(ctx, newBucketItem)
+	if !bucketItemsWritten(err) {
+		recordUsage(ctx, b.usageRepo, bucket.UserID, bucketUID, usage.Negate())
 		return &dto.CreateBucketItemOutputDTO{}, err
 	}
+	b.publishEvent(ctx, models.WebhookEventItemCreated, newBucketItem)
+	recordItemAuditEvent(ctx, b.auditRepo, models.AuditActionItemCreate, bucketUID, newBucketItem.Key, nil, newBucketItem)
 	return &dto.CreateBucketItemOutputDTO{
 		ID:        id,
 		BucketUID: bucketUID,
@@ -101,13 +141,174 @@ func (b *BucketItemService) CreateBucketItem(data dto.CreateBucketItemInputDTO,
 		TTL:       data.TTL,
 		Type:      dataType,
 		CreatedAt: newBucketItem.CreatedAt,
+	}, err
+}
+
+// Creates a new binary bucket item from a stream
+// values larger than the configured inline threshold are stored in GridFS, smaller values are stored inline
+// Accepts the binary item input data, the value stream, user ID, and bucket UID
+// Returns a success response and error
+func (b *BucketItemService) CreateBinaryBucketItem(ctx context.Context, data dto.CreateBinaryBucketItemInputDTO, source io.Reader, userID primitive.ObjectID, bucketUID string) (*dto.CreateBucketItemOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.CreateBinaryBucketItem")
+	defer span.End()
+	// validation
+	if utils.IsStringEmpty(data.Key) {
+		return &dto.CreateBucketItemOutputDTO{}, ErrKeyIsEmpty
+	}
+	if utils.IsStringEmpty(bucketUID) {
+		return &dto.CreateBucketItemOutputDTO{}, ErrBucketUIDIsEmpty
+	}
+	// check if the bucket UID exists
+	bucket, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID)
+	if err != nil {
+		return &dto.CreateBucketItemOutputDTO{}, err
+	}
+
+	// enforce unique key before uploading the value
+	if err := b.checkKeyIsFree(ctx, bucketUID, data.Key); err != nil {
+		return &dto.CreateBucketItemOutputDTO{}, err
+	}
+	// the item count is checked before the upload, the size once the value is read
+	if err := checkQuota(ctx, b.cfg, b.usageRepo, bucket.UserID, bucketUID, models.UsageDelta{Items: 1}, 0); err != nil {
+		return &dto.CreateBucketItemOutputDTO{}, err
+	}
+
+	contentType := data.ContentType
+	if utils.IsStringEmpty(contentType) {
+		contentType = "application/octet-stream"
+	}
+	newBucketItem := &models.BucketItem{
+		UserID:      userID,
+		BucketUID:   bucketUID,
+		BucketID:    bucket.ID,
+		Key:         data.Key,
+		TTL:         data.TTL,
+		Type:        models.BucketItemTypeBinary,
+		ContentType: contentType,
+	}
+
+	// read up to the inline threshold, values that fit are stored in the bucket item document
+	hasher := sha256.New()
+	maxSize := int64(b.cfg.BinaryItemMaxSize)
+	limitedSource := io.LimitReader(source, maxSize+1)
+	head, err := io.ReadAll(io.LimitReader(limitedSource, int64(b.cfg.BinaryItemInlineThreshold)+1))
+	if err != nil {
+		return &dto.CreateBucketItemOutputDTO{}, err
+	}
+	if len(head) <= b.cfg.BinaryItemInlineThreshold {
+		hasher.Write(head)
+		newBucketItem.Data = head
+		newBucketItem.Size = int64(len(head))
+	} else {
+		// stream the value into GridFS, hashing and counting the bytes on the way
+		counter := &countingWriter{}
+		stream := io.TeeReader(io.MultiReader(bytes.NewReader(head), limitedSource), io.MultiWriter(hasher, counter))
+		fileID, err := b.blobRepo.UploadBlob(ctx,
+			fmt.Sprintf("%s/%s", bucketUID, data.Key),
+			stream,
+			bson.M{"bucket_uid": bucketUID, "key": data.Key, "content_type": contentType},
+		)
+		if err != nil {
+			return &dto.CreateBucketItemOutputDTO{}, err
+		}
+		if counter.n > maxSize {
+			b.blobRepo.DeleteBlob(ctx, fileID)
+			return &dto.CreateBucketItemOutputDTO{}, models.ErrBucketItemTooLarge
+		}
+		newBucketItem.FileID = fileID
+		newBucketItem.Size = counter.n
+	}
+	if newBucketItem.Size > maxSize {
+		return &dto.CreateBucketItemOutputDTO{}, models.ErrBucketItemTooLarge
+	}
+	usage := models.UsageDelta{Items: 1, Bytes: newBucketItem.UsageBytes()}
+	if err := reserveUsage(ctx, b.cfg, b.usageRepo, bucket.UserID, bucketUID, usage, usage.Bytes); err != nil {
+		if newBucketItem.IsStoredInGridFS() {
+			b.blobRepo.DeleteBlob(ctx, newBucketItem.FileID)
+		}
+		return &dto.CreateBucketItemOutputDTO{}, err
+	}
+	newBucketItem.Hash = hexDigest(hasher)
+	newBucketItem.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	newBucketItem.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	id, err := b.bucketItemRepo.CreateBucketItem(ctx, newBucketItem)
+	if !bucketItemsWritten(err) {
+		recordUsage(ctx, b.usageRepo, bucket.UserID, bucketUID, usage.Negate())
+		if newBucketItem.IsStoredInGridFS() {
+			b.blobRepo.DeleteBlob(ctx, newBucketItem.FileID)
+		}
+		return &dto.CreateBucketItemOutputDTO{}, err
+	}
+	b.publishEvent(ctx, models.WebhookEventItemCreated, newBucketItem)
+	recordItemAuditEvent(ctx, b.auditRepo, models.AuditActionItemCreate, bucketUID, newBucketItem.Key, nil, newBucketItem)
+	return &dto.CreateBucketItemOutputDTO{
+		ID:          id,
+		BucketUID:   bucketUID,
+		Key:         data.Key,
+		TTL:         data.TTL,
+		Type:        newBucketItem.Type,
+		ContentType: newBucketItem.ContentType,
+		Size:        newBucketItem.Size,
+		Hash:        newBucketItem.Hash,
+		CreatedAt:   newBucketItem.CreatedAt,
+	}, err
+}
+
+// Opens the content of a binary bucket item for streaming
+// Accepts the bucket UID and key name values
+// Returns the seekable content with its metadata and an error
+func (b *BucketItemService) OpenBinaryBucketItem(ctx context.Context, bucketUID string, key string) (*dto.BinaryBucketItemContentDTO, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.OpenBinaryBucketItem")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return &dto.BinaryBucketItemContentDTO{}, ErrBucketUIDIsEmpty
+	}
+	if utils.IsStringEmpty(key) {
+		return &dto.BinaryBucketItemContentDTO{}, ErrKeyIsEmpty
+	}
+	bucketItem, err := b.bucketItemRepo.FindBucketItemByKeyName(ctx, bucketUID, key)
+	if err != nil {
+		return &dto.BinaryBucketItemContentDTO{}, err
+	}
+	if bucketItem.IsExpired() {
+		return &dto.BinaryBucketItemContentDTO{}, models.ErrBucketItemExpired
+	}
+	if bucketItem.Type != models.BucketItemTypeBinary {
+		return &dto.BinaryBucketItemContentDTO{}, models.ErrBucketItemNotBinary
+	}
+
+	var content io.ReadSeekCloser
+	if bucketItem.IsStoredInGridFS() {
+		fileID := bucketItem.FileID
+		content = &blobReadSeeker{
+			size: bucketItem.Size,
+			open: func() (io.ReadCloser, error) {
+				return b.blobRepo.OpenBlob(ctx, fileID)
+			},
+		}
+	} else {
+		data, ok := bucketItem.Data.(primitive.Binary)
+		if !ok {
+			return &dto.BinaryBucketItemContentDTO{}, models.ErrBucketItemNotBinary
+		}
+		content = nopSeekCloser{bytes.NewReader(data.Data)}
+	}
+	return &dto.BinaryBucketItemContentDTO{
+		Key:         bucketItem.Key,
+		ContentType: bucketItem.ContentType,
+		Size:        bucketItem.Size,
+		Hash:        bucketItem.Hash,
+		UpdatedAt:   bucketItem.UpdatedAt.Time(),
+		Content:     content,
 	}, nil
 }
 
 // Update a bucket by the key name
 // Accepts the update data, bucket UID and key
 // Returns an error
-func (b *BucketItemService) UpdateBucketItemByKeyName(data dto.UpdateBucketItemInputDTO, bucketUID string, key string) error {
+func (b *BucketItemService) UpdateBucketItemByKeyName(ctx context.Context, data dto.UpdateBucketItemInputDTO, bucketUID string, key string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.UpdateBucketItemByKeyName")
+	defer span.End()
 	if utils.IsStringEmpty(bucketUID) {
 		return ErrBucketUIDIsEmpty
 	}
@@ -120,38 +321,79 @@ func (b *BucketItemService) UpdateBucketItemByKeyName(data dto.UpdateBucketItemI
 		Data:      data.Data,
 		TTL:       data.TTL,
 	}
-	err := b.bucketItemRepo.UpdateBucketItem(updatedBucketItem, key)
+	// the usage changes by the size difference of the value, an update of a missing key creates the item
+	owner, current, err := b.findItemUsage(ctx, bucketUID, key)
 	if err != nil {
 		return err
 	}
-	return nil
+	var usage models.UsageDelta
+	if current.Items == 0 {
+		usage = models.UsageDelta{Items: 1, Bytes: updatedBucketItem.UsageBytes()}
+	} else if data.Data != nil {
+		usage.Bytes = updatedBucketItem.UsageBytes() - current.Bytes
+	}
+	if err := reserveUsage(ctx, b.cfg, b.usageRepo, owner, bucketUID, usage, updatedBucketItem.UsageBytes()); err != nil {
+		return err
+	}
+	err = b.bucketItemRepo.UpdateBucketItem(ctx, updatedBucketItem, key)
+	if !bucketItemsWritten(err) {
+		recordUsage(ctx, b.usageRepo, owner, bucketUID, usage.Negate())
+		return err
+	}
+	b.publishEvent(ctx, models.WebhookEventItemUpdated, updatedBucketItem)
+	after := bucketItemAuditSummary(&models.BucketItem{TTL: data.TTL})
+	if data.Data != nil {
+		after["type"] = utils.TypeOf(data.Data)
+	}
+	// a renamed item is recorded under its previous key
+	if data.Key != "" && data.Key != key {
+		after["key"] = data.Key
+	}
+	recordAuditEvent(ctx, b.auditRepo, &models.AuditEvent{
+		Action:    models.AuditActionItemUpdate,
+		BucketUID: bucketUID,
+		Key:       key,
+		After:     after,
+	})
+	return err
 }
 
 // Increments/decrements a key value by an amount
 // Accepts the bucket UID, item key, and amount
 // Returns an error
-func (b *BucketItemService) IncrementIntValue(bucketUID string, key string, amount int) error {
+func (b *BucketItemService) IncrementIntValue(ctx context.Context, bucketUID string, key string, amount int) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.IncrementIntValue")
+	defer span.End()
 	if utils.IsStringEmpty(bucketUID) {
 		return ErrBucketUIDIsEmpty
 	}
 	if utils.IsStringEmpty(key) {
 		return ErrKeyIsEmpty
 	}
-	err := b.bucketItemRepo.IncrementIntItem(bucketUID, key, amount)
-	if err != nil {
+	err := b.bucketItemRepo.IncrementIntItem(ctx, bucketUID, key, amount)
+	if !bucketItemsWritten(err) {
 		return err
 	}
-	return nil
+	b.publishEvent(ctx, models.WebhookEventItemUpdated, &models.BucketItem{BucketUID: bucketUID, Key: key})
+	recordAuditEvent(ctx, b.auditRepo, &models.AuditEvent{
+		Action:    models.AuditActionItemUpdate,
+		BucketUID: bucketUID,
+		Key:       key,
+		After:     map[string]interface{}{"increment": amount},
+	})
+	return err
 }
 
 // Find a bucket item by the bucket item ID
 // Accepts the string value of the bucket item object ID
 // Returns the found bucket item and an error
-func (b *BucketItemService) FindBucketItemByID(id string) (*models.BucketItem, error) {
+func (b *BucketItemService) FindBucketItemByID(ctx context.Context, id string) (*models.BucketItem, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.FindBucketItemByID")
+	defer span.End()
 	if utils.IsStringEmpty(id) {
 		return &models.BucketItem{}, ErrBucketItemIDIsEmpty
 	}
-	bucketItem, err := b.bucketItemRepo.FindBucketItemByID(id)
+	bucketItem, err := b.bucketItemRepo.FindBucketItemByID(ctx, id)
 	if err != nil {
 		return &models.BucketItem{}, err
 	}
@@ -161,14 +403,16 @@ func (b *BucketItemService) FindBucketItemByID(id string) (*models.BucketItem, e
 // Find a bucket item by the key name
 // Accepts the bucket UID and key name values
 // Returns the found bucket item and an error
-func (b *BucketItemService) FindBucketItemByKeyName(bucketUID string, key string) (*models.BucketItem, error) {
+func (b *BucketItemService) FindBucketItemByKeyName(ctx context.Context, bucketUID string, key string) (*models.BucketItem, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.FindBucketItemByKeyName")
+	defer span.End()
 	if utils.IsStringEmpty(bucketUID) {
 		return &models.BucketItem{}, ErrBucketUIDIsEmpty
 	}
 	if utils.IsStringEmpty(key) {
 		return &models.BucketItem{}, ErrKeyIsEmpty
 	}
-	bucketItem, err := b.bucketItemRepo.FindBucketItemByKeyName(bucketUID, key)
+	bucketItem, err := b.bucketItemRepo.FindBucketItemByKeyName(ctx, bucketUID, key)
 	if err != nil {
 		return &models.BucketItem{}, err
 	}
@@ -178,12 +422,21 @@ func (b *BucketItemService) FindBucketItemByKeyName(bucketUID string, key string
 // Lists all the bucket items based on the parsed query values
 // Accepts the query params passed in the request object
 // Returns the found bucket items and an error
-func (b *BucketItemService) ListBucketItemsPaged(queryParams url.Values) ([]models.BucketItem, utils.PageInfo, error) {
+func (b *BucketItemService) ListBucketItemsPaged(ctx context.Context, queryParams url.Values) ([]models.BucketItem, utils.PageInfo, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.ListBucketItemsPaged")
+	defer span.End()
+	// the items are listed from the bucket of the bucket_uid query, which is checked by the middlewares
+	bucketUID := queryParams.Get("bucket_uid")
+	if utils.IsStringEmpty(bucketUID) {
+		return []models.BucketItem{}, utils.PageInfo{}, ErrBucketUIDIsEmpty
+	}
 	filter, findOpts, paginationParams, err := utils.ParseRequestQueryParams(queryParams)
 	if err != nil {
-		return []models.BucketItem{}, utils.PageInfo{}, err
+		return []models.BucketItem{}, utils.PageInfo{}, models.NewBadRequestError(err)
 	}
-	bucketItems, pageInfo, err := b.bucketItemRepo.FindBucketItemsPaged(filter, findOpts, paginationParams)
+	// an operator filter on the bucket uid cannot widen the listing to other buckets
+	filter["bucket_uid"] = bucketUID
+	bucketItems, pageInfo, err := b.bucketItemRepo.FindBucketItemsPaged(ctx, filter, findOpts, paginationParams)
 	if err != nil {
 		return []models.BucketItem{}, utils.PageInfo{}, err
 	}
@@ -193,11 +446,13 @@ func (b *BucketItemService) ListBucketItemsPaged(queryParams url.Values) ([]mode
 // List all the bucket items for a specific bucket
 // Accepts the bucket UID
 // Returns the found bucket items and an error
-func (b *BucketItemService) ListBucketItems(bucketUID string) ([]models.BucketItem, error) {
+func (b *BucketItemService) ListBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.ListBucketItems")
+	defer span.End()
 	if utils.IsStringEmpty(bucketUID) {
 		return []models.BucketItem{}, ErrBucketUIDIsEmpty
 	}
-	bucketItems, err := b.bucketItemRepo.FindBucketItems(bucketUID)
+	bucketItems, err := b.bucketItemRepo.FindBucketItems(ctx, bucketUID)
 	if err != nil {
 		return []models.BucketItem{}, err
 	}
@@ -207,11 +462,13 @@ func (b *BucketItemService) ListBucketItems(bucketUID string) ([]models.BucketIt
 // Delete a single bucket item by ID
 // Accepts the string value of the bucket item's object ID
 // Returns an error
-func (b *BucketItemService) DeleteBucketItemById(id string) error {
+func (b *BucketItemService) DeleteBucketItemById(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.DeleteBucketItemById")
+	defer span.End()
 	if utils.IsStringEmpty(id) {
 		return ErrBucketItemIDIsEmpty
 	}
-	err := b.bucketItemRepo.DeleteBucketItemById(id)
+	err := b.bucketItemRepo.DeleteBucketItemById(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -221,57 +478,424 @@ func (b *BucketItemService) DeleteBucketItemById(id string) error {
 // Delete multiple bucket items
 // Accepts a list of object IDs of the desired buckets
 // Returns an error
-func (b *BucketItemService) DeleteBucketItemsById(ids []string) error {
-	err := b.bucketItemRepo.DeleteBucketItemsById(ids)
+func (b *BucketItemService) DeleteBucketItemsById(ctx context.Context, ids []string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.DeleteBucketItemsById")
+	defer span.End()
+	err := b.bucketItemRepo.DeleteBucketItemsById(ctx, ids)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// Delete bucket item by key name
+// Delete bucket item by key name, the bucket item is moved to the trash
 // Accepts the bucket UID and key name for the desired bucket item
 // Returns an error
-func (b *BucketItemService) DeleteBucketItemByKeyName(bucketUID string, key string) error {
+func (b *BucketItemService) DeleteBucketItemByKeyName(ctx context.Context, bucketUID string, key string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.DeleteBucketItemByKeyName")
+	defer span.End()
 	if utils.IsStringEmpty(bucketUID) {
 		return ErrBucketUIDIsEmpty
 	}
 	if utils.IsStringEmpty(key) {
 		return ErrKeyIsEmpty
 	}
-	err := b.bucketItemRepo.DeleteBucketItemByKeyName(bucketUID, key)
+	owner, usage, err := b.findItemUsage(ctx, bucketUID, key)
 	if err != nil {
 		return err
 	}
-	return nil
+	err = b.bucketItemRepo.TrashBucketItemByKeyName(ctx, bucketUID, key, primitive.NewDateTimeFromTime(time.Now()))
+	if !bucketItemsWritten(err) {
+		return err
+	}
+	recordUsage(ctx, b.usageRepo, owner, bucketUID, usage.Negate())
+	b.publishEvent(ctx, models.WebhookEventItemDeleted, &models.BucketItem{BucketUID: bucketUID, Key: key})
+	recordItemAuditEvent(ctx, b.auditRepo, models.AuditActionItemDelete, bucketUID, key, nil, nil)
+	return err
 }
 
-// Delete bucket items by key name
+// Delete bucket items by key name, the bucket items are moved to the trash
 // Accepts the bucket UID and key names for the desired bucket items
 // Returns an error
-func (b *BucketItemService) DeleteBucketItemsByKeyName(bucketUID string, keys []string) error {
+func (b *BucketItemService) DeleteBucketItemsByKeyName(ctx context.Context, bucketUID string, keys []string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.DeleteBucketItemsByKeyName")
+	defer span.End()
 	if utils.IsStringEmpty(bucketUID) {
 		return ErrBucketUIDIsEmpty
 	}
+	deletedAt := primitive.NewDateTimeFromTime(time.Now())
+	var recordErr error
 	for _, key := range keys {
-		err := b.bucketItemRepo.DeleteBucketItemByKeyName(bucketUID, key)
+		owner, usage, err := b.findItemUsage(ctx, bucketUID, key)
 		if err != nil {
 			return err
 		}
+		err = b.bucketItemRepo.TrashBucketItemByKeyName(ctx, bucketUID, key, deletedAt)
+		if !bucketItemsWritten(err) {
+			return err
+		}
+		if err != nil {
+			recordErr = err
+		}
+		recordUsage(ctx, b.usageRepo, owner, bucketUID, usage.Negate())
+		b.publishEvent(ctx, models.WebhookEventItemDeleted, &models.BucketItem{BucketUID: bucketUID, Key: key})
+		recordItemAuditEvent(ctx, b.auditRepo, models.AuditActionItemDelete, bucketUID, key, nil, nil)
 	}
-	return nil
+	return recordErr
 }
 
 // Deletes all the bucket items for a specific bucket
 // Accepts the bucket UID of the bucket
 // Returns an error
-func (b *BucketItemService) DeleteBucketItems(bucketUID string) error {
+func (b *BucketItemService) DeleteBucketItems(ctx context.Context, bucketUID string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.DeleteBucketItems")
+	defer span.End()
 	if utils.IsStringEmpty(bucketUID) {
 		return ErrBucketUIDIsEmpty
 	}
-	err := b.bucketItemRepo.DeleteBucketItems(bucketUID)
+	// the items are looked up first, their deletion is published once they are gone
+	bucketItems, err := b.bucketItemRepo.FindBucketItems(ctx, bucketUID)
+	if err != nil && !errors.Is(err, models.ErrBucketItemsNotFound) {
+		return err
+	}
+	err = b.bucketItemRepo.DeleteBucketItems(ctx, bucketUID)
 	if err != nil {
 		return err
 	}
+	for i := range bucketItems {
+		b.publishEvent(ctx, models.WebhookEventItemDeleted, &models.BucketItem{BucketUID: bucketUID, Key: bucketItems[i].Key})
+	}
 	return nil
 }
+
+// List the trashed bucket items of a bucket
+// Accepts the bucket UID
+// Returns the trashed bucket items and an error
+func (b *BucketItemService) ListTrashedBucketItems(ctx context.Context, bucketUID string) ([]models.BucketItem, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.ListTrashedBucketItems")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return []models.BucketItem{}, ErrBucketUIDIsEmpty
+	}
+	bucketItems, err := b.bucketItemRepo.FindTrashedBucketItems(ctx, bucketUID)
+	if err != nil {
+		return []models.BucketItem{}, err
+	}
+	return bucketItems, nil
+}
+
+// Restores a trashed bucket item
+// the restore fails if an item with the same key was created after the item was trashed
+// Accepts the bucket UID and the bucket item ID
+// Returns an error
+func (b *BucketItemService) RestoreBucketItem(ctx context.Context, bucketUID string, id string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.RestoreBucketItem")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return ErrBucketUIDIsEmpty
+	}
+	if utils.IsStringEmpty(id) {
+		return ErrBucketItemIDIsEmpty
+	}
+	bucketItem, err := b.bucketItemRepo.FindTrashedBucketItemByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if bucketItem.BucketUID != bucketUID {
+		return models.ErrBucketItemNotFound
+	}
+	// enforce unique key
+	if err := b.checkKeyIsFree(ctx, bucketUID, bucketItem.Key); err != nil {
+		return err
+	}
+	var owner primitive.ObjectID
+	usage := models.UsageDelta{Items: 1, Bytes: bucketItem.UsageBytes()}
+	if b.usageRepo != nil {
+		bucket, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID)
+		if err != nil {
+			return err
+		}
+		owner = bucket.UserID
+	}
+	if err := reserveUsage(ctx, b.cfg, b.usageRepo, owner, bucketUID, usage, usage.Bytes); err != nil {
+		return err
+	}
+	err = b.bucketItemRepo.RestoreBucketItemByID(ctx, id)
+	if !bucketItemsWritten(err) {
+		recordUsage(ctx, b.usageRepo, owner, bucketUID, usage.Negate())
+		return err
+	}
+	b.publishEvent(ctx, models.WebhookEventItemCreated, bucketItem)
+	recordItemAuditEvent(ctx, b.auditRepo, models.AuditActionItemRestore, bucketUID, bucketItem.Key, nil, bucketItem)
+	return err
+}
+
+// Returns whether the bucket items were written despite an error of the repository,
+// only the change feed of their bucket is then missing the write
+func bucketItemsWritten(err error) bool {
+	return err == nil || errors.Is(err, models.ErrRecordingBucketChange)
+}
+
+// Checks that no bucket item of a bucket holds a key
+// Returns a conflict error when the key is taken
+func (b *BucketItemService) checkKeyIsFree(ctx context.Context, bucketUID string, key string) error {
+	_, err := b.bucketItemRepo.FindBucketItemByKeyName(ctx, bucketUID, key)
+	if err == nil {
+		return models.NewConflictError(models.ErrBucketItemAlreadyExists, "key", key)
+	}
+	if !errors.Is(err, models.ErrBucketItemNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Returns the owner of a bucket and the usage of the item holding a key, an empty usage if there is none
+// nothing is looked up when the usage is not tracked
+func (b *BucketItemService) findItemUsage(ctx context.Context, bucketUID string, key string) (primitive.ObjectID, models.UsageDelta, error) {
+	if b.usageRepo == nil {
+		return primitive.NilObjectID, models.UsageDelta{}, nil
+	}
+	bucket, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID)
+	if err != nil {
+		return primitive.NilObjectID, models.UsageDelta{}, err
+	}
+	bucketItem, err := b.bucketItemRepo.FindBucketItemByKeyName(ctx, bucketUID, key)
+	if errors.Is(err, models.ErrBucketItemNotFound) {
+		return bucket.UserID, models.UsageDelta{}, nil
+	}
+	if err != nil {
+		return primitive.NilObjectID, models.UsageDelta{}, err
+	}
+	return bucket.UserID, models.UsageDelta{Items: 1, Bytes: bucketItem.UsageBytes()}, nil
+}
+
+// Permanently deletes a bucket item, whether it is in the trash or not
+// Accepts the bucket UID and the bucket item ID
+// Returns an error
+func (b *BucketItemService) PermanentlyDeleteBucketItem(ctx context.Context, bucketUID string, id string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.PermanentlyDeleteBucketItem")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return ErrBucketUIDIsEmpty
+	}
+	if utils.IsStringEmpty(id) {
+		return ErrBucketItemIDIsEmpty
+	}
+	bucketItem, err := b.bucketItemRepo.FindTrashedBucketItemByID(ctx, id)
+	if errors.Is(err, models.ErrBucketItemNotFound) {
+		bucketItem, err = b.bucketItemRepo.FindBucketItemByID(ctx, id)
+	}
+	if err != nil {
+		return err
+	}
+	if bucketItem.BucketUID != bucketUID {
+		return models.ErrBucketItemNotFound
+	}
+	err = b.bucketItemRepo.DeleteBucketItemById(ctx, id)
+	if !bucketItemsWritten(err) {
+		return err
+	}
+	// the trashed items are already left out of the usage
+	if b.usageRepo != nil && !bucketItem.IsTrashed() {
+		if bucket, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID); err == nil {
+			usage := models.UsageDelta{Items: 1, Bytes: bucketItem.UsageBytes()}
+			recordUsage(ctx, b.usageRepo, bucket.UserID, bucketUID, usage.Negate())
+		}
+	}
+	// the deletion of a trashed item was published when it was trashed
+	if !bucketItem.IsTrashed() {
+		b.publishEvent(ctx, models.WebhookEventItemDeleted, &models.BucketItem{BucketUID: bucketUID, Key: bucketItem.Key})
+	}
+	recordAuditEvent(ctx, b.auditRepo, &models.AuditEvent{
+		Action:    models.AuditActionItemDelete,
+		BucketUID: bucketUID,
+		Key:       bucketItem.Key,
+		Before:    bucketItemAuditSummary(bucketItem),
+		After:     map[string]interface{}{"permanent": true},
+	})
+	return err
+}
+
+// Returns the compression statistics of the items in a bucket
+// Accepts the bucket UID
+// Returns the compression statistics and an error
+func (b *BucketItemService) GetCompressionStats(ctx context.Context, bucketUID string) (*models.BucketItemCompressionStats, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.GetCompressionStats")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	if _, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID); err != nil {
+		return nil, err
+	}
+	return b.bucketItemRepo.GetCompressionStats(ctx, bucketUID)
+}
+
+// Enqueues a job that compresses the existing items of a bucket
+// Accepts the bucket UID
+// Returns an error
+func (b *BucketItemService) CompressBucketItems(ctx context.Context, bucketUID string) error {
+	ctx, span := tracing.Start(ctx, "BucketItemService.CompressBucketItems")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return ErrBucketUIDIsEmpty
+	}
+	if _, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID); err != nil {
+		return err
+	}
+	task, err := tasks.NewCompressBucketItemsTask(bucketUID)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating compress bucket items task")
+		return models.ErrEnqueuingTask
+	}
+	// the migration is not urgent
+	if err := b.queue.Add(ctx, task, asynq.Queue("low")); err != nil {
+		return models.ErrEnqueuingTask
+	}
+	return nil
+}
+
+// Marks the bucket items whose TTL has run out as expired and publishes their expiry
+// Returns the number of expired bucket items and an error
+func (b *BucketItemService) ExpireBucketItems(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.ExpireBucketItems")
+	defer span.End()
+	var count int64
+	now := primitive.NewDateTimeFromTime(time.Now())
+	for {
+		// the bucket items are returned along with an error recording their changes
+		bucketItems, err := b.bucketItemRepo.MarkExpiredBucketItems(ctx, now, bucketItemExpiryBatchSize)
+		for i := range bucketItems {
+			b.publishEvent(ctx, models.WebhookEventItemExpired, &bucketItems[i])
+		}
+		count += int64(len(bucketItems))
+		if err != nil {
+			return count, err
+		}
+		if len(bucketItems) < bucketItemExpiryBatchSize {
+			return count, nil
+		}
+	}
+}
+
+// Permanently deletes the bucket items whose TTL has run out, including the trashed ones
+// the usage of the purged items is given back to the owners of their buckets
+// Returns the number of purged bucket items and an error
+func (b *BucketItemService) PurgeExpiredBucketItems(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "BucketItemService.PurgeExpiredBucketItems")
+	defer span.End()
+	// the bucket items are returned along with an error recording their changes
+	bucketItems, err := b.bucketItemRepo.PurgeExpiredBucketItems(ctx, primitive.NewDateTimeFromTime(time.Now()))
+	if !bucketItemsWritten(err) {
+		return 0, err
+	}
+	usage := make(map[string]models.UsageDelta)
+	for i := range bucketItems {
+		// the trashed items are already left out of the usage
+		if bucketItems[i].IsTrashed() {
+			continue
+		}
+		delta := usage[bucketItems[i].BucketUID]
+		delta.Items--
+		delta.Bytes -= bucketItems[i].UsageBytes()
+		usage[bucketItems[i].BucketUID] = delta
+	}
+	if b.usageRepo != nil {
+		for bucketUID, delta := range usage {
+			bucket, err := b.bucketRepo.FindBucketByUID(ctx, bucketUID)
+			if err != nil {
+				logrus.WithContext(ctx).WithError(err).Errorf("error finding the owner of bucket %s, its usage is corrected by the next recompute", bucketUID)
+				continue
+			}
+			recordUsage(ctx, b.usageRepo, bucket.UserID, bucketUID, delta)
+		}
+	}
+	return int64(len(bucketItems)), err
+}
+
+// Publishes a bucket item event to the webhooks of the bucket
+func (b *BucketItemService) publishEvent(ctx context.Context, eventType string, bucketItem *models.BucketItem) {
+	publishItemEvent(ctx, b.cfg, b.queue, b.webhookSvc, eventType, bucketItem)
+}
+
+// counts the bytes written through it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// returns the hex encoded digest of a hash
+func hexDigest(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error { return nil }
+
+// blobReadSeeker makes a GridFS download stream seekable, as needed for serving range requests
+// the stream is (re)opened lazily on the first read after a seek and skips ahead to the offset
+type blobReadSeeker struct {
+	open   func() (io.ReadCloser, error)
+	size   int64
+	offset int64
+	stream io.ReadCloser
+}
+
+func (r *blobReadSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.stream == nil {
+		stream, err := r.open()
+		if err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(io.Discard, stream, r.offset); err != nil {
+			stream.Close()
+			return 0, err
+		}
+		r.stream = stream
+	}
+	n, err := r.stream.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *blobReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	if abs != r.offset && r.stream != nil {
+		r.stream.Close()
+		r.stream = nil
+	}
+	r.offset = abs
+	return abs, nil
+}
+
+func (r *blobReadSeeker) Close() error {
+	if r.stream == nil {
+		return nil
+	}
+	err := r.stream.Close()
+	r.stream = nil
+	return err
+}
@@ -1,24 +1,38 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
 	"keeper/internal/config"
 	"keeper/internal/dto"
 	"keeper/internal/mocks"
 	"keeper/internal/models"
+	"keeper/internal/utils"
+	"net/url"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // provide the bucket item service
 func provideBucketItemService(mockBucketItemRepo *mocks.MockIBucketItemRepository, mockBucketRepo *mocks.MockIBucketRepository) IBucketItemService {
+	return provideBucketItemServiceWithBlobRepo(mockBucketItemRepo, mockBucketRepo, nil)
+}
+
+// provide the bucket item service with a blob repository for binary items
+func provideBucketItemServiceWithBlobRepo(mockBucketItemRepo *mocks.MockIBucketItemRepository, mockBucketRepo *mocks.MockIBucketRepository, mockBlobRepo *mocks.MockIBucketItemBlobRepository) IBucketItemService {
 	cfg := &config.Config{
-		Env: "test",
+		Env:                       "test",
+		BinaryItemInlineThreshold: 8,
+		BinaryItemMaxSize:         32,
 	}
-	return NewBucketItemService(cfg, mockBucketItemRepo, mockBucketRepo)
+	return NewBucketItemService(cfg, mockBucketItemRepo, mockBucketRepo, mockBlobRepo, nil, nil, nil)
 }
 
 func TestBucketItemService_CreateBucketItem(t *testing.T) {
@@ -52,13 +66,13 @@ func TestBucketItemService_CreateBucketItem(t *testing.T) {
 				bucketUID: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByUID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.Bucket{
 					ID: primitive.NewObjectID(),
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, models.ErrBucketItemNotFound)
-				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any()).
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
 					Times(1).Return(primitive.NewObjectID(), nil)
 			},
 			want: &dto.CreateBucketItemOutputDTO{
@@ -77,19 +91,46 @@ func TestBucketItemService_CreateBucketItem(t *testing.T) {
 				bucketUID: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByUID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.Bucket{
 					ID: primitive.NewObjectID(),
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, models.ErrBucketItemNotFound)
-				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any()).
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
 					Times(1).Return(primitive.ObjectID{}, errors.New("failed to create bucket item"))
 			},
 			want:       &dto.CreateBucketItemOutputDTO{},
 			wantErr:    true,
 			wantErrMsg: "failed to create bucket item",
 		},
+		{
+			name: "should_create_bucket_item_change_not_recorded",
+			args: args{
+				data: dto.CreateBucketItemInputDTO{
+					Key: "test",
+				},
+				userID:    primitive.NewObjectID(),
+				bucketUID: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					ID: primitive.NewObjectID(),
+				}, nil)
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketItemNotFound)
+				// the item is written, only its change is missing from the change feed
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+					Times(1).Return(primitive.NewObjectID(), models.ErrRecordingBucketChange)
+			},
+			want: &dto.CreateBucketItemOutputDTO{
+				Key:       "test",
+				BucketUID: "12345",
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrRecordingBucketChange.Error(),
+		},
 		{
 			name: "should_fail_create_bucket_item_empty_key",
 			args: args{
@@ -114,18 +155,42 @@ func TestBucketItemService_CreateBucketItem(t *testing.T) {
 				bucketUID: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByUID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.Bucket{
 					ID: primitive.NewObjectID(),
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.BucketItem{
 					ID: primitive.NewObjectID(),
 				}, nil)
 			},
 			want:       &dto.CreateBucketItemOutputDTO{},
 			wantErr:    true,
-			wantErrMsg: "key 'test' already exists",
+			wantErrMsg: models.ErrBucketItemAlreadyExists.Error(),
+		},
+		{
+			name: "should_fail_create_bucket_item_concurrent_duplicate_key",
+			args: args{
+				data: dto.CreateBucketItemInputDTO{
+					Key: "test",
+				},
+				userID:    primitive.NewObjectID(),
+				bucketUID: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					ID: primitive.NewObjectID(),
+				}, nil)
+				// the key is created by another request between the check and the insert
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketItemNotFound)
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+					Times(1).Return(primitive.ObjectID{}, models.NewConflictError(models.ErrBucketItemAlreadyExists, "key", "test"))
+			},
+			want:       &dto.CreateBucketItemOutputDTO{},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketItemAlreadyExists.Error(),
 		},
 	}
 
@@ -136,10 +201,11 @@ func TestBucketItemService_CreateBucketItem(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			out, err := bucketItemSvc.CreateBucketItem(tc.args.data, tc.args.userID, tc.args.bucketUID)
+			out, err := bucketItemSvc.CreateBucketItem(context.Background(), tc.args.data, tc.args.userID, tc.args.bucketUID)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
+				require.Equal(t, tc.want.Key, out.Key)
 				return
 			}
 
@@ -149,6 +215,288 @@ func TestBucketItemService_CreateBucketItem(t *testing.T) {
 	}
 }
 
+func TestBucketItemService_CreateBinaryBucketItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	blobRepo := mocks.NewMockIBucketItemBlobRepository(ctrl)
+
+	// drains the uploaded stream like GridFS would
+	uploadBlob := func(_ context.Context, filename string, source io.Reader, metadata bson.M) (primitive.ObjectID, error) {
+		if _, err := io.Copy(io.Discard, source); err != nil {
+			return primitive.ObjectID{}, err
+		}
+		return primitive.NewObjectID(), nil
+	}
+
+	type args struct {
+		data      dto.CreateBinaryBucketItemInputDTO
+		value     []byte
+		userID    primitive.ObjectID
+		bucketUID string
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository)
+		want       *dto.CreateBucketItemOutputDTO
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_create_inline_binary_bucket_item",
+			args: args{
+				data:      dto.CreateBinaryBucketItemInputDTO{Key: "test"},
+				value:     []byte("small"),
+				userID:    primitive.NewObjectID(),
+				bucketUID: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					ID: primitive.NewObjectID(),
+				}, nil)
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketItemNotFound)
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+					Times(1).Return(primitive.NewObjectID(), nil)
+			},
+			want: &dto.CreateBucketItemOutputDTO{
+				Key:         "test",
+				Type:        models.BucketItemTypeBinary,
+				ContentType: "application/octet-stream",
+				Size:        5,
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_successfully_create_gridfs_binary_bucket_item",
+			args: args{
+				data:      dto.CreateBinaryBucketItemInputDTO{Key: "test", ContentType: "application/pdf"},
+				value:     []byte("larger than the threshold"),
+				userID:    primitive.NewObjectID(),
+				bucketUID: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					ID: primitive.NewObjectID(),
+				}, nil)
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketItemNotFound)
+				blobRepo.EXPECT().UploadBlob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(uploadBlob)
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+					Times(1).Return(primitive.NewObjectID(), nil)
+			},
+			want: &dto.CreateBucketItemOutputDTO{
+				Key:         "test",
+				Type:        models.BucketItemTypeBinary,
+				ContentType: "application/pdf",
+				Size:        25,
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_fail_create_binary_bucket_item_too_large",
+			args: args{
+				data:      dto.CreateBinaryBucketItemInputDTO{Key: "test"},
+				value:     bytes.Repeat([]byte("a"), 64),
+				userID:    primitive.NewObjectID(),
+				bucketUID: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					ID: primitive.NewObjectID(),
+				}, nil)
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketItemNotFound)
+				blobRepo.EXPECT().UploadBlob(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(uploadBlob)
+				blobRepo.EXPECT().DeleteBlob(gomock.Any(), gomock.Any()).
+					Times(1).Return(nil)
+			},
+			want:       &dto.CreateBucketItemOutputDTO{},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketItemTooLarge.Error(),
+		},
+		{
+			name: "should_fail_create_binary_bucket_item_duplicate_key",
+			args: args{
+				data:      dto.CreateBinaryBucketItemInputDTO{Key: "test"},
+				value:     []byte("small"),
+				userID:    primitive.NewObjectID(),
+				bucketUID: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					ID: primitive.NewObjectID(),
+				}, nil)
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItem{
+					ID: primitive.NewObjectID(),
+				}, nil)
+			},
+			want:       &dto.CreateBucketItemOutputDTO{},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketItemAlreadyExists.Error(),
+		},
+		{
+			name: "should_fail_create_binary_bucket_item_empty_key",
+			args: args{
+				data:      dto.CreateBinaryBucketItemInputDTO{Key: ""},
+				value:     []byte("small"),
+				userID:    primitive.NewObjectID(),
+				bucketUID: "12345",
+			},
+			stubFn:     nil,
+			want:       &dto.CreateBucketItemOutputDTO{},
+			wantErr:    true,
+			wantErrMsg: ErrKeyIsEmpty.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo, blobRepo)
+			}
+
+			bucketItemSvc := provideBucketItemServiceWithBlobRepo(bucketItemRepo, bucketRepo, blobRepo)
+			out, err := bucketItemSvc.CreateBinaryBucketItem(context.Background(), tc.args.data, bytes.NewReader(tc.args.value), tc.args.userID, tc.args.bucketUID)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, tc.want.Key, out.Key)
+			require.Equal(t, tc.want.Type, out.Type)
+			require.Equal(t, tc.want.ContentType, out.ContentType)
+			require.Equal(t, tc.want.Size, out.Size)
+			require.Len(t, out.Hash, 64)
+		})
+	}
+}
+
+func TestBucketItemService_OpenBinaryBucketItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	blobRepo := mocks.NewMockIBucketItemBlobRepository(ctrl)
+
+	type args struct {
+		bucketUID string
+		key       string
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository)
+		want       string
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_open_inline_binary_bucket_item",
+			args: args{bucketUID: "12345", key: "test"},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItem{
+					Key:  "test",
+					Type: models.BucketItemTypeBinary,
+					Data: primitive.Binary{Data: []byte("inline value")},
+					Size: 12,
+				}, nil)
+			},
+			want:    "value",
+			wantErr: false,
+		},
+		{
+			name: "should_successfully_open_gridfs_binary_bucket_item",
+			args: args{bucketUID: "12345", key: "test"},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItem{
+					Key:    "test",
+					Type:   models.BucketItemTypeBinary,
+					FileID: primitive.NewObjectID(),
+					Size:   12,
+				}, nil)
+				blobRepo.EXPECT().OpenBlob(gomock.Any(), gomock.Any()).
+					Times(1).Return(io.NopCloser(bytes.NewReader([]byte("gridfs value"))), nil)
+			},
+			want:    "value",
+			wantErr: false,
+		},
+		{
+			name: "should_fail_open_binary_bucket_item_not_binary",
+			args: args{bucketUID: "12345", key: "test"},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItem{
+					Key:  "test",
+					Type: "string",
+					Data: "value",
+				}, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketItemNotBinary.Error(),
+		},
+		{
+			name: "should_fail_open_binary_bucket_item_not_found",
+			args: args{bucketUID: "12345", key: "test"},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketItemNotFound)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketItemNotFound.Error(),
+		},
+		{
+			name:       "should_fail_open_binary_bucket_item_empty_key",
+			args:       args{bucketUID: "12345", key: ""},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrKeyIsEmpty.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo, blobRepo)
+			}
+
+			bucketItemSvc := provideBucketItemServiceWithBlobRepo(bucketItemRepo, bucketRepo, blobRepo)
+			out, err := bucketItemSvc.OpenBinaryBucketItem(context.Background(), tc.args.bucketUID, tc.args.key)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+			defer out.Content.Close()
+			// seek past the first word, as range requests do
+			_, err = out.Content.Seek(7, io.SeekStart)
+			require.Nil(t, err)
+			content, err := io.ReadAll(out.Content)
+			require.Nil(t, err)
+			require.Equal(t, tc.want, string(content))
+		})
+	}
+}
+
 func TestBucketItemService_FindBucketItemByID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -174,7 +522,7 @@ func TestBucketItemService_FindBucketItemByID(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().FindBucketItemByID(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItemByID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.BucketItem{
 					ID:        primitive.NewObjectID(),
 					BucketUID: "12345",
@@ -197,7 +545,7 @@ func TestBucketItemService_FindBucketItemByID(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().FindBucketItemByID(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItemByID(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("failed to find bucket item by id"))
 			},
 			wantErr:    true,
@@ -212,7 +560,7 @@ func TestBucketItemService_FindBucketItemByID(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			out, err := bucketItemSvc.FindBucketItemByID(tc.args.id)
+			out, err := bucketItemSvc.FindBucketItemByID(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -252,7 +600,7 @@ func TestBucketItemService_FindBucketItemByKeyName(t *testing.T) {
 				key:       "key",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.BucketItem{
 					ID:        primitive.NewObjectID(),
 					BucketUID: "12345",
@@ -287,7 +635,7 @@ func TestBucketItemService_FindBucketItemByKeyName(t *testing.T) {
 				key:       "key",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("failed to find bucket item by key name"))
 			},
 			wantErr:    true,
@@ -302,7 +650,7 @@ func TestBucketItemService_FindBucketItemByKeyName(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			out, err := bucketItemSvc.FindBucketItemByKeyName(tc.args.bucketUID, tc.args.key)
+			out, err := bucketItemSvc.FindBucketItemByKeyName(context.Background(), tc.args.bucketUID, tc.args.key)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -340,7 +688,7 @@ func TestBucketItemService_ListBucketItems(t *testing.T) {
 				bucketUID: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.BucketItem{
 					{
 						ID: primitive.NewObjectID(),
@@ -364,7 +712,7 @@ func TestBucketItemService_ListBucketItems(t *testing.T) {
 				bucketUID: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.BucketItem{}, errors.New("failed to list bucket items"))
 			},
 			wantErr:    true,
@@ -379,7 +727,7 @@ func TestBucketItemService_ListBucketItems(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			out, err := bucketItemSvc.ListBucketItems(tc.args.bucketUID)
+			out, err := bucketItemSvc.ListBucketItems(context.Background(), tc.args.bucketUID)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -422,7 +770,7 @@ func TestBucketItemService_UpdateBucketItem(t *testing.T) {
 				},
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().UpdateBucketItem(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().UpdateBucketItem(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -463,7 +811,7 @@ func TestBucketItemService_UpdateBucketItem(t *testing.T) {
 				},
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().UpdateBucketItem(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().UpdateBucketItem(gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to update bucket item"))
 			},
 			wantErr:    true,
@@ -478,7 +826,7 @@ func TestBucketItemService_UpdateBucketItem(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			err := bucketItemSvc.UpdateBucketItemByKeyName(tc.args.data, tc.args.bucketUID, tc.args.key)
+			err := bucketItemSvc.UpdateBucketItemByKeyName(context.Background(), tc.args.data, tc.args.bucketUID, tc.args.key)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -514,7 +862,7 @@ func TestBucketItemService_DeleteBucketItemById(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItemById(gomock.Any()).
+				bucketItemRepo.EXPECT().DeleteBucketItemById(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -534,7 +882,7 @@ func TestBucketItemService_DeleteBucketItemById(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItemById(gomock.Any()).
+				bucketItemRepo.EXPECT().DeleteBucketItemById(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to delete bucket item by id"))
 			},
 			wantErr:    true,
@@ -549,7 +897,7 @@ func TestBucketItemService_DeleteBucketItemById(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			err := bucketItemSvc.DeleteBucketItemById(tc.args.id)
+			err := bucketItemSvc.DeleteBucketItemById(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -587,7 +935,7 @@ func TestBucketItemService_DeleteBucketItemsById(t *testing.T) {
 				},
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItemsById(gomock.Any()).
+				bucketItemRepo.EXPECT().DeleteBucketItemsById(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -600,7 +948,7 @@ func TestBucketItemService_DeleteBucketItemsById(t *testing.T) {
 				},
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItemsById(gomock.Any()).
+				bucketItemRepo.EXPECT().DeleteBucketItemsById(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to delete bucket items"))
 			},
 			wantErr:    true,
@@ -615,7 +963,7 @@ func TestBucketItemService_DeleteBucketItemsById(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			err := bucketItemSvc.DeleteBucketItemsById(tc.args.ids)
+			err := bucketItemSvc.DeleteBucketItemsById(context.Background(), tc.args.ids)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -651,7 +999,9 @@ func TestBucketItemService_DeleteBucketItems(t *testing.T) {
 				bucketUID: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "12345").
+					Times(1).Return([]models.BucketItem{{BucketUID: "12345", Key: "retries"}}, nil)
+				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -669,7 +1019,9 @@ func TestBucketItemService_DeleteBucketItems(t *testing.T) {
 				bucketUID: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "12345").
+					Times(1).Return(nil, models.ErrBucketItemsNotFound)
+				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to delete bucket items"))
 			},
 			wantErr:    true,
@@ -684,7 +1036,7 @@ func TestBucketItemService_DeleteBucketItems(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			err := bucketItemSvc.DeleteBucketItems(tc.args.bucketUID)
+			err := bucketItemSvc.DeleteBucketItems(context.Background(), tc.args.bucketUID)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -722,7 +1074,7 @@ func TestBucketItemService_DeleteBucketItemByKeyName(t *testing.T) {
 				key:       "key",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().TrashBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -754,7 +1106,7 @@ func TestBucketItemService_DeleteBucketItemByKeyName(t *testing.T) {
 				key:       "key",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().TrashBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to delete bucket item"))
 			},
 			wantErr:    true,
@@ -769,7 +1121,7 @@ func TestBucketItemService_DeleteBucketItemByKeyName(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			err := bucketItemSvc.DeleteBucketItemByKeyName(tc.args.bucketUID, tc.args.key)
+			err := bucketItemSvc.DeleteBucketItemByKeyName(context.Background(), tc.args.bucketUID, tc.args.key)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -807,7 +1159,7 @@ func TestBucketItemService_DeleteBucketItemsByKeyName(t *testing.T) {
 				keys:      []string{"key1"},
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().TrashBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -829,7 +1181,7 @@ func TestBucketItemService_DeleteBucketItemsByKeyName(t *testing.T) {
 				keys:      []string{"key1"},
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItemByKeyName(gomock.Any(), gomock.Any()).
+				bucketItemRepo.EXPECT().TrashBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to delete bucket item"))
 			},
 			wantErr:    true,
@@ -844,7 +1196,7 @@ func TestBucketItemService_DeleteBucketItemsByKeyName(t *testing.T) {
 			}
 
 			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
-			err := bucketItemSvc.DeleteBucketItemsByKeyName(tc.args.bucketUID, tc.args.keys)
+			err := bucketItemSvc.DeleteBucketItemsByKeyName(context.Background(), tc.args.bucketUID, tc.args.keys)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -859,3 +1211,263 @@ func TestBucketItemService_DeleteBucketItemsByKeyName(t *testing.T) {
 func TestBucketItemService_IncrementIntValue(t *testing.T) {
 	require.Nil(t, nil)
 }
+
+func TestBucketItemService_GetCompressionStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+
+	type args struct {
+		bucketUID string
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository)
+		want       *models.BucketItemCompressionStats
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_get_compression_stats",
+			args: args{
+				bucketUID: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					ID: primitive.NewObjectID(),
+				}, nil)
+				bucketItemRepo.EXPECT().GetCompressionStats(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItemCompressionStats{
+					BucketUID:       "12345",
+					CompressedItems: 2,
+					RawSize:         8192,
+					StoredSize:      1024,
+					Ratio:           8,
+				}, nil)
+			},
+			want: &models.BucketItemCompressionStats{
+				BucketUID:       "12345",
+				CompressedItems: 2,
+				RawSize:         8192,
+				StoredSize:      1024,
+				Ratio:           8,
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_fail_get_compression_stats_empty_bucket_uid",
+			args: args{
+				bucketUID: "",
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrBucketUIDIsEmpty.Error(),
+		},
+		{
+			name: "should_fail_get_compression_stats_bucket_not_found",
+			args: args{
+				bucketUID: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketNotFound)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketNotFound.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo)
+			}
+
+			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
+			out, err := bucketItemSvc.GetCompressionStats(context.Background(), tc.args.bucketUID)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, tc.want, out)
+		})
+	}
+}
+
+func TestBucketItemService_RestoreBucketItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+
+	type args struct {
+		bucketUID string
+		id        string
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository)
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_restore_bucket_item",
+			args: args{
+				bucketUID: "12345",
+				id:        primitive.NewObjectID().Hex(),
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketItemRepo.EXPECT().FindTrashedBucketItemByID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItem{
+					BucketUID: "12345",
+					Key:       "key",
+				}, nil)
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketItemNotFound)
+				bucketItemRepo.EXPECT().RestoreBucketItemByID(gomock.Any(), gomock.Any()).
+					Times(1).Return(nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_fail_restore_bucket_item_empty_id",
+			args: args{
+				bucketUID: "12345",
+				id:        "",
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrBucketItemIDIsEmpty.Error(),
+		},
+		{
+			name: "should_fail_restore_bucket_item_different_bucket",
+			args: args{
+				bucketUID: "12345",
+				id:        primitive.NewObjectID().Hex(),
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketItemRepo.EXPECT().FindTrashedBucketItemByID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItem{
+					BucketUID: "67890",
+					Key:       "key",
+				}, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketItemNotFound.Error(),
+		},
+		{
+			name: "should_fail_restore_bucket_item_key_exists",
+			args: args{
+				bucketUID: "12345",
+				id:        primitive.NewObjectID().Hex(),
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketItemRepo.EXPECT().FindTrashedBucketItemByID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItem{
+					BucketUID: "12345",
+					Key:       "key",
+				}, nil)
+				bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.BucketItem{
+					ID: primitive.NewObjectID(),
+				}, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketItemAlreadyExists.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo)
+			}
+
+			bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
+			err := bucketItemSvc.RestoreBucketItem(context.Background(), tc.args.bucketUID, tc.args.id)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+		})
+	}
+}
+
+func TestBucketItemService_ExpireBucketItems(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+
+	fullBatch := make([]models.BucketItem, bucketItemExpiryBatchSize)
+	// a full batch is followed by another lookup
+	gomock.InOrder(
+		bucketItemRepo.EXPECT().MarkExpiredBucketItems(gomock.Any(), gomock.Any(), int64(bucketItemExpiryBatchSize)).
+			Times(1).Return(fullBatch, nil),
+		bucketItemRepo.EXPECT().MarkExpiredBucketItems(gomock.Any(), gomock.Any(), int64(bucketItemExpiryBatchSize)).
+			Times(1).Return([]models.BucketItem{{BucketUID: "12345", Key: "session", TTL: 60}}, nil),
+	)
+
+	bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
+	count, err := bucketItemSvc.ExpireBucketItems(context.Background())
+
+	require.Nil(t, err)
+	require.Equal(t, int64(bucketItemExpiryBatchSize+1), count)
+}
+
+func TestBucketItemService_ListBucketItemsPaged(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	bucketItemSvc := provideBucketItemService(bucketItemRepo, bucketRepo)
+
+	tt := []struct {
+		name    string
+		query   url.Values
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name:    "should_fail_without_bucket_uid",
+			query:   url.Values{"key[matches]": []string{"db"}},
+			stubFn:  func() {},
+			wantErr: ErrBucketUIDIsEmpty,
+		},
+		{
+			name:  "should_list_the_items_of_the_bucket_only",
+			query: url.Values{"bucket_uid": []string{"bucket"}, "bucket_uid[matches]": []string{".*"}},
+			stubFn: func() {
+				bucketItemRepo.EXPECT().FindBucketItemsPaged(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, filter bson.M, _ *options.FindOptions, _ utils.PaginationParams) ([]models.BucketItem, utils.PageInfo, error) {
+						require.Equal(t, "bucket", filter["bucket_uid"])
+						return []models.BucketItem{}, utils.PageInfo{}, nil
+					})
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stubFn()
+			_, _, err := bucketItemSvc.ListBucketItemsPaged(context.Background(), tc.query)
+			require.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/utils"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrSnapshotNameIsEmpty = models.NewBadRequestError(errors.New("snapshot name cannot be empty"))
+)
+
+// how long the clean up of a failed clone may take
+const cleanupTimeout = time.Minute
+
+// Returns the context for cleaning up after a failed operation,
+// it is detached from the request as the failure may be the request being cancelled
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), cleanupTimeout)
+}
+
+// Service for cloning a bucket, or one of its snapshots, into a new bucket owned by the user
+// the clone keeps the permissions and the items of the source bucket
+func (b *BucketService) CloneBucket(ctx context.Context, uid string, data dto.CloneBucketInputDTO, userID primitive.ObjectID) (*dto.CreateBucketOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.CloneBucket")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	source, err := b.bucketRepo.FindBucketByUID(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	items, err := b.findBucketRefItems(ctx, dto.BucketRefDTO{BucketUID: uid, Snapshot: data.Snapshot})
+	if err != nil {
+		return nil, err
+	}
+	// the clone is checked as a whole before anything is copied, its items are reserved once the bucket is created
+	usage := bucketItemsUsage(items)
+	if err := checkQuota(ctx, b.cfg, b.usageRepo, userID, "", models.UsageDelta{Buckets: 1, Items: usage.Items, Bytes: usage.Bytes}, largestValueBytes(items)); err != nil {
+		return nil, err
+	}
+	description := data.Description
+	if utils.IsStringEmpty(description) {
+		description = source.Description
+	}
+	clone, err := b.CreateBucket(ctx, dto.CreateBucketInputDTO{
+		Name:        data.Name,
+		Description: description,
+		Permissions: source.Permissions,
+	}, userID)
+	if err != nil {
+		return nil, err
+	}
+	// the usage reserved for the clone is given back along with the bucket when it is discarded
+	if err := reserveUsage(ctx, b.cfg, b.usageRepo, userID, clone.UID, usage, largestValueBytes(items)); err != nil {
+		b.discardBucketClone(clone.UID)
+		return nil, err
+	}
+	for _, item := range items {
+		copied, err := b.copyBucketItem(ctx, item)
+		if err != nil {
+			b.discardBucketClone(clone.UID)
+			return nil, err
+		}
+		copied.BucketID = clone.ID
+		copied.BucketUID = clone.UID
+		copied.UserID = userID
+		// a copy that was written is deleted with the clone
+		if _, err := b.bucketItemRepo.CreateBucketItem(ctx, &copied); err != nil {
+			if !bucketItemsWritten(err) {
+				b.deleteCopiedBlobs([]models.BucketItem{copied})
+			}
+			b.discardBucketClone(clone.UID)
+			return nil, err
+		}
+		publishItemEvent(ctx, b.cfg, b.queue, b.webhookSvc, models.WebhookEventItemCreated, &copied)
+	}
+	return clone, nil
+}
+
+// Removes a partially cloned bucket
+func (b *BucketService) discardBucketClone(uid string) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	if err := b.PermanentlyDeleteBucket(ctx, uid); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error discarding partial bucket clone: %s", uid)
+	}
+}
+
+// Service for taking a named, read-only snapshot of the items of a bucket
+func (b *BucketService) CreateBucketSnapshot(ctx context.Context, uid string, data dto.CreateBucketSnapshotInputDTO, userID primitive.ObjectID) (*models.BucketSnapshot, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.CreateBucketSnapshot")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	if utils.IsStringEmpty(data.Name) {
+		return nil, ErrSnapshotNameIsEmpty
+	}
+	_, err := b.bucketSnapshotRepo.FindSnapshotByName(ctx, uid, data.Name)
+	if err == nil {
+		return nil, models.NewConflictError(models.ErrSnapshotAlreadyExists, "name", data.Name)
+	}
+	if !errors.Is(err, models.ErrSnapshotNotFound) {
+		return nil, err
+	}
+	items, err := b.findBucketRefItems(ctx, dto.BucketRefDTO{BucketUID: uid})
+	if err != nil {
+		return nil, err
+	}
+	copiedItems := make([]models.BucketItem, 0, len(items))
+	for _, item := range items {
+		copied, err := b.copyBucketItem(ctx, item)
+		if err != nil {
+			b.deleteCopiedBlobs(copiedItems)
+			return nil, err
+		}
+		// snapshot items keep their original IDs so that they can be traced back
+		copied.ID = item.ID
+		copiedItems = append(copiedItems, copied)
+	}
+	snapshot := &models.BucketSnapshot{
+		BucketUID:   uid,
+		UserID:      userID,
+		Name:        data.Name,
+		Description: data.Description,
+		CreatedAt:   primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if _, err := b.bucketSnapshotRepo.CreateSnapshot(ctx, snapshot, copiedItems); err != nil {
+		b.deleteCopiedBlobs(copiedItems)
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Service for listing the snapshots of a bucket
+func (b *BucketService) ListBucketSnapshots(ctx context.Context, uid string) ([]models.BucketSnapshot, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.ListBucketSnapshots")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	return b.bucketSnapshotRepo.FindSnapshotsByBucketUID(ctx, uid)
+}
+
+// Service for returning a bucket snapshot along with its items
+func (b *BucketService) FindBucketSnapshot(ctx context.Context, uid string, name string) (*dto.BucketSnapshotDetailsOutput, error) {
+	ctx, span := tracing.Start(ctx, "BucketService.FindBucketSnapshot")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	if utils.IsStringEmpty(name) {
+		return nil, ErrSnapshotNameIsEmpty
+	}
+	snapshot, err := b.bucketSnapshotRepo.FindSnapshotByName(ctx, uid, name)
+	if err != nil {
+		return nil, err
+	}
+	items, err := b.bucketSnapshotRepo.FindSnapshotItems(ctx, snapshot.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.BucketSnapshotDetailsOutput{
+		BucketSnapshot: *snapshot,
+		BucketItems:    items,
+	}, nil
+}
+
+// Service for deleting a bucket snapshot
+func (b *BucketService) DeleteBucketSnapshot(ctx context.Context, uid string, name string) error {
+	ctx, span := tracing.Start(ctx, "BucketService.DeleteBucketSnapshot")
+	defer span.End()
+	if utils.IsStringEmpty(uid) {
+		return ErrBucketUIDIsEmpty
+	}
+	if utils.IsStringEmpty(name) {
+		return ErrSnapshotNameIsEmpty
+	}
+	snapshot, err := b.bucketSnapshotRepo.FindSnapshotByName(ctx, uid, name)
+	if err != nil {
+		return err
+	}
+	return b.bucketSnapshotRepo.DeleteSnapshot(ctx, snapshot.ID)
+}
+
+// Returns the items of a bucket, or of one of its snapshots
+func (b *BucketService) findBucketRefItems(ctx context.Context, ref dto.BucketRefDTO) ([]models.BucketItem, error) {
+	if utils.IsStringEmpty(ref.BucketUID) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	if _, err := b.bucketRepo.FindBucketByUID(ctx, ref.BucketUID); err != nil {
+		return nil, err
+	}
+	if utils.IsStringEmpty(ref.Snapshot) {
+		items, err := b.bucketItemRepo.FindBucketItems(ctx, ref.BucketUID)
+		if err != nil && !errors.Is(err, models.ErrBucketItemsNotFound) {
+			return nil, err
+		}
+		return items, nil
+	}
+	snapshot, err := b.bucketSnapshotRepo.FindSnapshotByName(ctx, ref.BucketUID, ref.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return b.bucketSnapshotRepo.FindSnapshotItems(ctx, snapshot.ID)
+}
+
+// Returns a copy of a bucket item that can be stored elsewhere
+// the blob of a large binary item is copied as well, since a blob is deleted along with its item
+func (b *BucketService) copyBucketItem(ctx context.Context, item models.BucketItem) (models.BucketItem, error) {
+	copied := item
+	copied.ID = primitive.ObjectID{}
+	copied.DeletedAt = 0
+	copied.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	if !item.FileID.IsZero() {
+		fileID, err := b.blobRepo.CopyBlob(ctx, item.FileID)
+		if err != nil {
+			return models.BucketItem{}, err
+		}
+		copied.FileID = fileID
+	}
+	return copied, nil
+}
+
+// Deletes the blobs copied for bucket items that could not be stored
+func (b *BucketService) deleteCopiedBlobs(items []models.BucketItem) {
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	for _, item := range items {
+		if item.FileID.IsZero() {
+			continue
+		}
+		if err := b.blobRepo.DeleteBlob(ctx, item.FileID); err != nil && !errors.Is(err, models.ErrBlobNotFound) {
+			logrus.WithContext(ctx).WithError(err).Errorf("error deleting copied blob: %s", item.FileID.Hex())
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"keeper/internal/dto"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBucketService_CreateBucketSnapshot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	bucketSnapshotRepo := mocks.NewMockIBucketSnapshotRepository(ctrl)
+	blobRepo := mocks.NewMockIBucketItemBlobRepository(ctrl)
+
+	userID := primitive.NewObjectID()
+	fileID := primitive.NewObjectID()
+	copiedFileID := primitive.NewObjectID()
+
+	type args struct {
+		uid  string
+		data dto.CreateBucketSnapshotInputDTO
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, bucketSnapshotRepo *mocks.MockIBucketSnapshotRepository, blobRepo *mocks.MockIBucketItemBlobRepository)
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_create_bucket_snapshot",
+			args: args{
+				uid:  "12345",
+				data: dto.CreateBucketSnapshotInputDTO{Name: "v1"},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, bucketSnapshotRepo *mocks.MockIBucketSnapshotRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketSnapshotRepo.EXPECT().FindSnapshotByName(gomock.Any(), "12345", "v1").
+					Times(1).Return(nil, models.ErrSnapshotNotFound)
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "12345").
+					Times(1).Return([]models.BucketItem{
+					{Key: "api_url", Data: "https://api.example.com"},
+					{Key: "logo", Type: models.BucketItemTypeBinary, FileID: fileID},
+				}, nil)
+				// the snapshot gets its own copy of the blob of a large binary item
+				blobRepo.EXPECT().CopyBlob(gomock.Any(), fileID).
+					Times(1).Return(copiedFileID, nil)
+				bucketSnapshotRepo.EXPECT().CreateSnapshot(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(func(_ context.Context, snapshot *models.BucketSnapshot, items []models.BucketItem) (primitive.ObjectID, error) {
+					require.Equal(t, "v1", snapshot.Name)
+					require.Equal(t, userID, snapshot.UserID)
+					require.Len(t, items, 2)
+					require.Equal(t, copiedFileID, items[1].FileID)
+					return primitive.NewObjectID(), nil
+				})
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_fail_create_bucket_snapshot_already_exists",
+			args: args{
+				uid:  "12345",
+				data: dto.CreateBucketSnapshotInputDTO{Name: "v1"},
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository, bucketSnapshotRepo *mocks.MockIBucketSnapshotRepository, blobRepo *mocks.MockIBucketItemBlobRepository) {
+				bucketSnapshotRepo.EXPECT().FindSnapshotByName(gomock.Any(), "12345", "v1").
+					Times(1).Return(&models.BucketSnapshot{Name: "v1"}, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrSnapshotAlreadyExists.Error(),
+		},
+		{
+			name: "should_fail_create_bucket_snapshot_empty_name",
+			args: args{
+				uid:  "12345",
+				data: dto.CreateBucketSnapshotInputDTO{},
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrSnapshotNameIsEmpty.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo, bucketSnapshotRepo, blobRepo)
+			}
+
+			bucketSvc := provideBucketServiceWithSnapshotRepo(bucketRepo, bucketItemRepo, bucketSnapshotRepo, blobRepo)
+			snapshot, err := bucketSvc.CreateBucketSnapshot(context.Background(), tc.args.uid, tc.args.data, userID)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, tc.args.data.Name, snapshot.Name)
+		})
+	}
+}
+
+func TestBucketService_CloneBucket(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+
+	userID := primitive.NewObjectID()
+	cloneID := primitive.NewObjectID()
+	source := &models.Bucket{
+		UID:         "staging",
+		Description: "staging config",
+		Permissions: models.BucketPermissionsList{models.BucketPermissionPublicReadBucket},
+	}
+
+	bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "staging").
+		Times(2).Return(source, nil)
+	bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), "staging").
+		Times(1).Return([]models.BucketItem{{ID: primitive.NewObjectID(), Key: "api_url", Data: "https://api.example.com"}}, nil)
+	bucketRepo.EXPECT().CreateBucket(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, bucket *models.Bucket) (primitive.ObjectID, error) {
+		require.Equal(t, source.Permissions, bucket.Permissions)
+		require.Equal(t, source.Description, bucket.Description)
+		return cloneID, nil
+	})
+	bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, bucketItem *models.BucketItem) (primitive.ObjectID, error) {
+		require.True(t, bucketItem.ID.IsZero())
+		require.Equal(t, cloneID, bucketItem.BucketID)
+		require.Equal(t, userID, bucketItem.UserID)
+		return primitive.NewObjectID(), nil
+	})
+
+	bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
+	clone, err := bucketSvc.CloneBucket(context.Background(), "staging", dto.CloneBucketInputDTO{Name: "staging-copy"}, userID)
+
+	require.Nil(t, err)
+	require.Equal(t, cloneID, clone.ID)
+	require.Equal(t, "staging-copy", clone.Name)
+}
@@ -1,12 +1,14 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"keeper/internal/config"
 	"keeper/internal/dto"
 	"keeper/internal/mocks"
 	"keeper/internal/models"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -15,10 +17,15 @@ import (
 
 // provide the bucket service
 func provideBucketService(mockBucketRepo *mocks.MockIBucketRepository, mockBucketItemRepo *mocks.MockIBucketItemRepository) IBucketService {
+	return provideBucketServiceWithSnapshotRepo(mockBucketRepo, mockBucketItemRepo, nil, nil)
+}
+
+// provide the bucket service with the repositories used for snapshots and copies of binary items
+func provideBucketServiceWithSnapshotRepo(mockBucketRepo *mocks.MockIBucketRepository, mockBucketItemRepo *mocks.MockIBucketItemRepository, mockBucketSnapshotRepo *mocks.MockIBucketSnapshotRepository, mockBlobRepo *mocks.MockIBucketItemBlobRepository) IBucketService {
 	cfg := &config.Config{
 		Env: "test",
 	}
-	return NewBucketService(cfg, mockBucketRepo, mockBucketItemRepo)
+	return NewBucketService(cfg, mockBucketRepo, mockBucketItemRepo, mockBucketSnapshotRepo, mockBlobRepo, nil, nil, nil, nil)
 }
 
 func TestBucketService_CreateBucket(t *testing.T) {
@@ -50,7 +57,7 @@ func TestBucketService_CreateBucket(t *testing.T) {
 				userID: primitive.NewObjectID(),
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository) {
-				bucketRepo.EXPECT().CreateBucket(gomock.Any()).
+				bucketRepo.EXPECT().CreateBucket(gomock.Any(), gomock.Any()).
 					Times(1).Return(primitive.NewObjectID(), nil)
 			},
 			want: &dto.CreateBucketOutputDTO{
@@ -80,7 +87,7 @@ func TestBucketService_CreateBucket(t *testing.T) {
 				userID: primitive.NewObjectID(),
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository) {
-				bucketRepo.EXPECT().CreateBucket(gomock.Any()).
+				bucketRepo.EXPECT().CreateBucket(gomock.Any(), gomock.Any()).
 					Times(1).Return(primitive.ObjectID{}, errors.New("error"))
 			},
 			want:       nil,
@@ -96,7 +103,7 @@ func TestBucketService_CreateBucket(t *testing.T) {
 			}
 
 			bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
-			out, err := bucketSvc.CreateBucket(tc.args.data, tc.args.userID)
+			out, err := bucketSvc.CreateBucket(context.Background(), tc.args.data, tc.args.userID)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -133,12 +140,12 @@ func TestBucketService_FindBucketByID(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.Bucket{
 					ID:  primitive.NewObjectID(),
 					UID: "12345",
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.BucketItem{
 					{
 						ID: primitive.NewObjectID(),
@@ -162,7 +169,7 @@ func TestBucketService_FindBucketByID(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByID(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("failed to find bucket"))
 			},
 			wantErr:    true,
@@ -174,12 +181,12 @@ func TestBucketService_FindBucketByID(t *testing.T) {
 				id: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.Bucket{
 					ID:  primitive.NewObjectID(),
 					UID: "12345",
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("failed to find bucket items"))
 			},
 			wantErr:    true,
@@ -194,7 +201,7 @@ func TestBucketService_FindBucketByID(t *testing.T) {
 			}
 
 			bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
-			out, err := bucketSvc.FindBucketByID(tc.args.id)
+			out, err := bucketSvc.FindBucketByID(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -232,12 +239,12 @@ func TestBucketService_FindBucketByUID(t *testing.T) {
 				uid: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByUID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.Bucket{
 					ID:  primitive.NewObjectID(),
 					UID: "12345",
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.BucketItem{
 					{
 						ID: primitive.NewObjectID(),
@@ -261,7 +268,7 @@ func TestBucketService_FindBucketByUID(t *testing.T) {
 				uid: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByUID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("failed to find bucket by uid"))
 			},
 			wantErr:    true,
@@ -273,12 +280,12 @@ func TestBucketService_FindBucketByUID(t *testing.T) {
 				uid: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketByUID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.Bucket{
 					ID:  primitive.NewObjectID(),
 					UID: "12345",
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.BucketItem{}, errors.New("failed to find bucket items"))
 			},
 			wantErr:    true,
@@ -293,7 +300,7 @@ func TestBucketService_FindBucketByUID(t *testing.T) {
 			}
 
 			bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
-			out, err := bucketSvc.FindBucketByUID(tc.args.uid)
+			out, err := bucketSvc.FindBucketByUID(context.Background(), tc.args.uid)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -330,14 +337,14 @@ func TestBucketService_ListUserBuckets(t *testing.T) {
 				userID: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketsByUserID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketsByUserID(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.Bucket{
 					{
 						ID:  primitive.NewObjectID(),
 						UID: "12345",
 					},
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.BucketItem{
 					{
 						ID: primitive.NewObjectID(),
@@ -352,7 +359,7 @@ func TestBucketService_ListUserBuckets(t *testing.T) {
 				userID: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketsByUserID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketsByUserID(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.Bucket{}, errors.New("failed to find buckets"))
 			},
 			wantErr:    true,
@@ -364,14 +371,14 @@ func TestBucketService_ListUserBuckets(t *testing.T) {
 				userID: "62fa734bfc1cdb7f06a3bf6f",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().FindBucketsByUserID(gomock.Any()).
+				bucketRepo.EXPECT().FindBucketsByUserID(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.Bucket{
 					{
 						ID:  primitive.NewObjectID(),
 						UID: "12345",
 					},
 				}, nil)
-				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().FindBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return([]models.BucketItem{}, errors.New("failed to find bucket items"))
 			},
 			wantErr:    true,
@@ -386,7 +393,7 @@ func TestBucketService_ListUserBuckets(t *testing.T) {
 			}
 
 			bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
-			out, err := bucketSvc.ListUserBuckets(tc.args.userID)
+			out, err := bucketSvc.ListUserBuckets(context.Background(), tc.args.userID)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -427,7 +434,7 @@ func TestBucketService_UpdateBucket(t *testing.T) {
 				},
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().UpdateBucket(gomock.Any()).
+				bucketRepo.EXPECT().UpdateBucket(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -453,7 +460,7 @@ func TestBucketService_UpdateBucket(t *testing.T) {
 				},
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketRepo.EXPECT().UpdateBucket(gomock.Any()).
+				bucketRepo.EXPECT().UpdateBucket(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to update bucket"))
 			},
 			wantErr:    true,
@@ -468,7 +475,7 @@ func TestBucketService_UpdateBucket(t *testing.T) {
 			}
 
 			bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
-			err := bucketSvc.UpdateBucket(tc.args.uid, tc.args.data)
+			err := bucketSvc.UpdateBucket(context.Background(), tc.args.uid, tc.args.data)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -491,6 +498,200 @@ func TestBucketService_DeleteBucket(t *testing.T) {
 		uid string
 	}
 
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository)
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_move_bucket_to_trash",
+			args: args{
+				uid: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().TrashBucketByUID(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil)
+				bucketItemRepo.EXPECT().TrashBucketItems(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_fail_delete_bucket_empty_uid",
+			args: args{
+				uid: "",
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrBucketUIDIsEmpty.Error(),
+		},
+		{
+			name: "should_fail_delete_bucket_not_found",
+			args: args{
+				uid: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().TrashBucketByUID(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(models.ErrBucketNotFound)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketNotFound.Error(),
+		},
+		{
+			name: "should_fail_delete_bucket_could_not_trash_bucket_items",
+			args: args{
+				uid: "12345",
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().TrashBucketByUID(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(nil)
+				bucketItemRepo.EXPECT().TrashBucketItems(gomock.Any(), gomock.Any(), gomock.Any()).
+					Times(1).Return(models.ErrTrashingBucketItem)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrTrashingBucketItem.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo)
+			}
+
+			bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
+			err := bucketSvc.DeleteBucket(context.Background(), tc.args.uid)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+		})
+	}
+}
+
+func TestBucketService_RestoreBucket(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+
+	ownerID := primitive.NewObjectID()
+	deletedAt := primitive.NewDateTimeFromTime(time.Now())
+
+	type args struct {
+		uid    string
+		userID primitive.ObjectID
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository)
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_restore_bucket",
+			args: args{
+				uid:    "12345",
+				userID: ownerID,
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().FindTrashedBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					UID:       "12345",
+					UserID:    ownerID,
+					DeletedAt: deletedAt,
+				}, nil)
+				// only the items trashed along with the bucket are restored
+				bucketItemRepo.EXPECT().RestoreBucketItems(gomock.Any(), "12345", deletedAt).
+					Times(1).Return(nil)
+				bucketRepo.EXPECT().RestoreBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_fail_restore_bucket_empty_uid",
+			args: args{
+				uid:    "",
+				userID: ownerID,
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrBucketUIDIsEmpty.Error(),
+		},
+		{
+			name: "should_fail_restore_bucket_not_owner",
+			args: args{
+				uid:    "12345",
+				userID: primitive.NewObjectID(),
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().FindTrashedBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(&models.Bucket{
+					UID:       "12345",
+					UserID:    ownerID,
+					DeletedAt: deletedAt,
+				}, nil)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketNotFound.Error(),
+		},
+		{
+			name: "should_fail_restore_bucket_not_in_trash",
+			args: args{
+				uid:    "12345",
+				userID: ownerID,
+			},
+			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
+				bucketRepo.EXPECT().FindTrashedBucketByUID(gomock.Any(), gomock.Any()).
+					Times(1).Return(nil, models.ErrBucketNotFound)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketNotFound.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(bucketRepo, bucketItemRepo)
+			}
+
+			bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
+			err := bucketSvc.RestoreBucket(context.Background(), tc.args.uid, tc.args.userID)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+		})
+	}
+}
+
+func TestBucketService_PermanentlyDeleteBucket(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	bucketSnapshotRepo := mocks.NewMockIBucketSnapshotRepository(ctrl)
+	bucketSnapshotRepo.EXPECT().DeleteBucketSnapshots(gomock.Any(), gomock.Any()).
+		AnyTimes().Return(nil)
+
+	type args struct {
+		uid string
+	}
+
 	tt := []struct {
 		name       string
 		args       args
@@ -504,9 +705,9 @@ func TestBucketService_DeleteBucket(t *testing.T) {
 				uid: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
-				bucketRepo.EXPECT().DeleteBucketByUID(gomock.Any()).
+				bucketRepo.EXPECT().DeleteBucketByUID(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -526,7 +727,7 @@ func TestBucketService_DeleteBucket(t *testing.T) {
 				uid: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to delete bucket items"))
 			},
 			wantErr:    true,
@@ -538,9 +739,9 @@ func TestBucketService_DeleteBucket(t *testing.T) {
 				uid: "12345",
 			},
 			stubFn: func(bucketRepo *mocks.MockIBucketRepository, bucketItemRepo *mocks.MockIBucketItemRepository) {
-				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any()).
+				bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
-				bucketRepo.EXPECT().DeleteBucketByUID(gomock.Any()).
+				bucketRepo.EXPECT().DeleteBucketByUID(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to delete bucket"))
 			},
 			wantErr:    true,
@@ -554,8 +755,8 @@ func TestBucketService_DeleteBucket(t *testing.T) {
 				tc.stubFn(bucketRepo, bucketItemRepo)
 			}
 
-			bucketSvc := provideBucketService(bucketRepo, bucketItemRepo)
-			err := bucketSvc.DeleteBucket(tc.args.uid)
+			bucketSvc := provideBucketServiceWithSnapshotRepo(bucketRepo, bucketItemRepo, bucketSnapshotRepo, nil)
+			err := bucketSvc.PermanentlyDeleteBucket(context.Background(), tc.args.uid)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -566,3 +767,22 @@ func TestBucketService_DeleteBucket(t *testing.T) {
 		})
 	}
 }
+
+func TestBucketService_PermanentlyDeleteBucket_DeletesWebhooks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	bucketSnapshotRepo := mocks.NewMockIBucketSnapshotRepository(ctrl)
+	webhookRepo := mocks.NewMockIWebhookRepository(ctrl)
+	gomock.InOrder(
+		bucketSnapshotRepo.EXPECT().DeleteBucketSnapshots(gomock.Any(), "12345").Times(1).Return(nil),
+		webhookRepo.EXPECT().DeleteBucketWebhooks(gomock.Any(), "12345").Times(1).Return(nil),
+		bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), "12345").Times(1).Return(nil),
+		bucketRepo.EXPECT().DeleteBucketByUID(gomock.Any(), "12345").Times(1).Return(nil),
+	)
+
+	bucketSvc := NewBucketService(&config.Config{Env: "test"}, bucketRepo, bucketItemRepo, bucketSnapshotRepo, nil, nil, nil, webhookRepo, nil)
+	require.Nil(t, bucketSvc.PermanentlyDeleteBucket(context.Background(), "12345"))
+}
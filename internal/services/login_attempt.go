@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"keeper/internal/models"
+	"keeper/internal/pkg/mailer"
+	"keeper/internal/queue/tasks"
+	"keeper/internal/utils"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+)
+
+// purpose claim of the login unlock tokens
+const loginUnlockTokenPurpose = "login_unlock"
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// Returns the hash compared with the password of a login to an unknown email
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = utils.HashPassword("keeper-dummy-password")
+	})
+	return dummyHash
+}
+
+// Returns the lockout following a number of failures, the lockout doubles with each failure past the threshold
+// no lockout is returned under the threshold or when the threshold is 0
+func loginLockout(failures int, threshold int, base time.Duration, max time.Duration) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	exponent := failures - threshold
+	if exponent > 30 {
+		exponent = 30
+	}
+	lockout := base << exponent
+	if max > 0 && lockout > max {
+		return max
+	}
+	return lockout
+}
+
+// Returns the IDs of the login attempts of an email and of the IP address of the request, with their thresholds
+// the IP is the one of the connection or the one forwarded by a trusted proxy, so that it cannot be spoofed
+func (s *AuthService) loginAttemptIDs(ctx context.Context, email string) map[string]int {
+	ids := map[string]int{models.LoginAttemptEmailID(email): s.cfg.LoginMaxFailures}
+	if ip := AuditActorFromContext(ctx).IP; ip != "" {
+		ids[models.LoginAttemptIPID(ip)] = s.cfg.LoginMaxFailuresPerIP
+	}
+	return ids
+}
+
+// Returns ErrLoginLocked if the logins to the email, or from the IP address of the request, are locked
+func (s *AuthService) checkLoginLock(ctx context.Context, email string) error {
+	if s.loginAttemptRepo == nil {
+		return nil
+	}
+	ids := []string{}
+	for id := range s.loginAttemptIDs(ctx, email) {
+		ids = append(ids, id)
+	}
+	attempts, err := s.loginAttemptRepo.FindLoginAttempts(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, attempt := range attempts {
+		if attempt.LockedUntil.Time().After(now) {
+			return models.ErrLoginLocked
+		}
+	}
+	return nil
+}
+
+// Counts a failed login to the email and from the IP address of the request, and locks the ones reaching their threshold
+// the failures that cannot be recorded are only logged, the login has already failed
+func (s *AuthService) recordLoginFailure(ctx context.Context, email string, user *models.User) {
+	if s.loginAttemptRepo == nil {
+		return
+	}
+	window := time.Duration(s.cfg.LoginFailureWindowMinutes) * time.Minute
+	base := time.Duration(s.cfg.LoginLockoutBaseSeconds) * time.Second
+	max := time.Duration(s.cfg.LoginLockoutMaxSeconds) * time.Second
+	emailID := models.LoginAttemptEmailID(email)
+	for id, threshold := range s.loginAttemptIDs(ctx, email) {
+		attempt, err := s.loginAttemptRepo.RecordLoginFailure(ctx, id, window)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("error recording login failure: %s", id)
+			continue
+		}
+		lockout := loginLockout(attempt.Failures, threshold, base, max)
+		if lockout == 0 {
+			continue
+		}
+		if err := s.loginAttemptRepo.LockLogin(ctx, id, time.Now().Add(lockout), window); err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("error locking login: %s", id)
+			continue
+		}
+		event := &models.AuditEvent{
+			Action:     models.AuditActionLoginLocked,
+			AuditActor: models.AuditActor{CredentialType: models.AuditCredentialTypePassword},
+			Target:     email,
+			After:      map[string]interface{}{"failures": attempt.Failures, "lockout_seconds": int(lockout.Seconds())},
+		}
+		if id != emailID {
+			event.Target = AuditActorFromContext(ctx).IP
+		}
+		if user != nil {
+			event.UserID = user.ID
+		}
+		recordAuditEvent(ctx, s.auditRepo, event)
+		if id == emailID && user != nil {
+			s.sendLoginUnlockMail(ctx, user, lockout)
+		}
+	}
+}
+
+// Unlocks the logins to an email after a successful login
+func (s *AuthService) resetLoginFailures(ctx context.Context, email string) {
+	if s.loginAttemptRepo == nil {
+		return
+	}
+	if err := s.loginAttemptRepo.DeleteLoginAttempts(ctx, models.LoginAttemptEmailID(email)); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error resetting login failures")
+	}
+}
+
+// Sends the link unlocking the logins of a locked account to its owner
+func (s *AuthService) sendLoginUnlockMail(ctx context.Context, user *models.User, lockout time.Duration) {
+	if s.cfg.Env == "test" {
+		return
+	}
+	payload := map[string]interface{}{
+		"id":      user.ID.Hex(),
+		"email":   user.Email,
+		"purpose": loginUnlockTokenPurpose,
+	}
+	unlockToken, err := s.jwtSvc.GenerateLoginUnlockToken(payload)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error generating login unlock token")
+		return
+	}
+	name := fmt.Sprintf("%s %s", user.Firstname, user.Lastname)
+	templateData := struct {
+		Name      string
+		URL       string
+		LockedFor string
+	}{
+		Name:      name,
+		URL:       fmt.Sprintf("%s/unlock-account?token=%s", s.cfg.ClientURL, unlockToken),
+		LockedFor: fmt.Sprintf("%d minute(s)", int(math.Ceil(lockout.Minutes()))),
+	}
+	subject := "Your Kipa Account has been locked"
+	if s.cfg.WithWorkers {
+		task, err := tasks.NewLoginUnlockMailTask(user.Email, name, subject, templateData)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).Error(err.Error())
+			return
+		}
+		s.queue.Add(ctx, task, asynq.Queue("critical"))
+		return
+	}
+	if err := mailer.NewMailer(s.cfg).SendLoginUnlockMail(user.Email, name, subject, templateData); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error sending login unlock mail")
+	}
+}
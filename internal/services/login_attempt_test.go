@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"keeper/internal/auth/jwt"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"keeper/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func provideLoginLockoutConfig() *config.Config {
+	return &config.Config{
+		Env:                         "test",
+		JwtSecretKey:                "secret",
+		AccessTokenJwtExpiresIn:     "30m",
+		RefreshTokenJwtExpiresIn:    "7d",
+		LoginMaxFailures:            3,
+		LoginMaxFailuresPerIP:       10,
+		LoginFailureWindowMinutes:   15,
+		LoginLockoutBaseSeconds:     60,
+		LoginLockoutMaxSeconds:      3600,
+		LoginUnlockTokenSecretKey:   "unlock-secret",
+		LoginUnlockTokenExpiresIn:   "1h",
+		ResetPasswordTokenSecretKey: "reset-secret",
+		ResetPasswordTokenExpiresIn: "1h",
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	tt := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{name: "should_not_lock_under_the_threshold", failures: 2, want: 0},
+		{name: "should_lock_for_the_base_duration_at_the_threshold", failures: 3, want: time.Minute},
+		{name: "should_double_the_lockout_past_the_threshold", failures: 5, want: 4 * time.Minute},
+		{name: "should_cap_the_lockout", failures: 40, want: time.Hour},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, loginLockout(tc.failures, 3, time.Minute, time.Hour))
+		})
+	}
+	require.Zero(t, loginLockout(100, 0, time.Minute, time.Hour))
+}
+
+func TestAuthService_Login_Lockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userRepo := mocks.NewMockIUserRepository(ctrl)
+	auditRepo := mocks.NewMockIAuditEventRepository(ctrl)
+	loginAttemptRepo := mocks.NewMockILoginAttemptRepository(ctrl)
+	authSvc := NewAuthService(provideLoginLockoutConfig(), userRepo, auditRepo, loginAttemptRepo, nil, nil)
+	hashedPassword, _ := utils.HashPassword("secret")
+	user := &models.User{ID: primitive.NewObjectID(), Email: "testuser@gmail.com", Password: hashedPassword}
+	emailID := models.LoginAttemptEmailID(user.Email)
+	ipID := models.LoginAttemptIPID("203.0.113.7")
+
+	tt := []struct {
+		name     string
+		password string
+		stubFn   func()
+		wantErr  error
+	}{
+		{
+			name:     "should_fail_login_locked",
+			password: "secret",
+			stubFn: func() {
+				loginAttemptRepo.EXPECT().FindLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).Return([]models.LoginAttempt{{
+					ID:          emailID,
+					Failures:    3,
+					LockedUntil: primitive.NewDateTimeFromTime(time.Now().Add(time.Minute)),
+				}}, nil)
+			},
+			wantErr: models.ErrLoginLocked,
+		},
+		{
+			name:     "should_lock_login_reaching_the_threshold",
+			password: "wrong",
+			stubFn: func() {
+				loginAttemptRepo.EXPECT().FindLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).Return([]models.LoginAttempt{}, nil)
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+				loginAttemptRepo.EXPECT().RecordLoginFailure(gomock.Any(), emailID, 15*time.Minute).
+					Times(1).Return(&models.LoginAttempt{ID: emailID, Failures: 3}, nil)
+				loginAttemptRepo.EXPECT().RecordLoginFailure(gomock.Any(), ipID, 15*time.Minute).
+					Times(1).Return(&models.LoginAttempt{ID: ipID, Failures: 3}, nil)
+				loginAttemptRepo.EXPECT().LockLogin(gomock.Any(), emailID, gomock.Any(), 15*time.Minute).
+					Times(1).DoAndReturn(func(_ context.Context, _ string, lockedUntil time.Time, _ time.Duration) error {
+					require.WithinDuration(t, time.Now().Add(time.Minute), lockedUntil, time.Second)
+					return nil
+				})
+				auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).
+					Times(2).DoAndReturn(func(_ context.Context, event *models.AuditEvent) (primitive.ObjectID, error) {
+					require.Contains(t, []string{models.AuditActionLoginFailed, models.AuditActionLoginLocked}, event.Action)
+					require.Equal(t, user.ID, event.UserID)
+					return primitive.NewObjectID(), nil
+				})
+			},
+			wantErr: models.ErrInvalidCredentials,
+		},
+		{
+			name:     "should_count_failures_of_unknown_emails",
+			password: "wrong",
+			stubFn: func() {
+				loginAttemptRepo.EXPECT().FindLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).Return([]models.LoginAttempt{}, nil)
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), user.Email).Times(1).Return(nil, models.ErrUserNotFound)
+				loginAttemptRepo.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), 15*time.Minute).
+					Times(2).Return(&models.LoginAttempt{Failures: 1}, nil)
+				auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).Times(1).Return(primitive.NewObjectID(), nil)
+			},
+			wantErr: models.ErrInvalidCredentials,
+		},
+		{
+			name:     "should_reset_failures_on_login",
+			password: "secret",
+			stubFn: func() {
+				loginAttemptRepo.EXPECT().FindLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).Return([]models.LoginAttempt{{ID: emailID, Failures: 2}}, nil)
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+				auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).Times(1).Return(primitive.NewObjectID(), nil)
+				loginAttemptRepo.EXPECT().DeleteLoginAttempts(gomock.Any(), emailID).Times(1).Return(nil)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stubFn()
+			ctx := WithAuditActor(context.Background(), models.AuditActor{IP: "203.0.113.7"})
+			_, err := authSvc.Login(ctx, dto.LoginUserInputDTO{Email: user.Email, Password: tc.password})
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+		})
+	}
+}
+
+func TestAuthService_UnlockLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := provideLoginLockoutConfig()
+	loginAttemptRepo := mocks.NewMockILoginAttemptRepository(ctrl)
+	authSvc := NewAuthService(cfg, nil, nil, loginAttemptRepo, nil, nil)
+	jwtSvc := jwt.NewJwtService(cfg, nil, nil)
+	userID := primitive.NewObjectID()
+
+	unlockToken, err := jwtSvc.GenerateLoginUnlockToken(map[string]interface{}{
+		"id":      userID.Hex(),
+		"email":   "TestUser@gmail.com",
+		"purpose": loginUnlockTokenPurpose,
+	})
+	require.Nil(t, err)
+	// a token signed with another secret, or for another purpose, does not unlock
+	resetToken, err := jwtSvc.GenerateResetPasswordToken(map[string]interface{}{"id": userID.Hex(), "email": "testuser@gmail.com"})
+	require.Nil(t, err)
+	otherToken, err := jwtSvc.GenerateLoginUnlockToken(map[string]interface{}{"id": userID.Hex(), "email": "testuser@gmail.com"})
+	require.Nil(t, err)
+
+	tt := []struct {
+		name    string
+		token   string
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name:  "should_unlock_login",
+			token: unlockToken,
+			stubFn: func() {
+				loginAttemptRepo.EXPECT().DeleteLoginAttempts(gomock.Any(), "email:testuser@gmail.com").Times(1).Return(nil)
+			},
+		},
+		{name: "should_fail_unlock_login_reset_password_token", token: resetToken, wantErr: models.ErrInvalidUnlockToken},
+		{name: "should_fail_unlock_login_token_without_purpose", token: otherToken, wantErr: models.ErrInvalidUnlockToken},
+		{name: "should_fail_unlock_login_malformed_token", token: "not-a-token", wantErr: models.ErrInvalidUnlockToken},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn()
+			}
+			err := authSvc.UnlockLogin(context.Background(), dto.UnlockLoginInputDTO{Token: tc.token})
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+		})
+	}
+}
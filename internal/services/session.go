@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/auth/jwt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/utils"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Generates the access and refresh token of a session
+// the nonce makes the refresh tokens of a session differ, even when they are issued in the same second
+func (s *AuthService) generateSessionTokens(user *models.User, sessionID primitive.ObjectID) (string, string, error) {
+	payload := map[string]interface{}{"id": user.ID, "email": user.Email}
+	if !sessionID.IsZero() {
+		payload["sid"] = sessionID.Hex()
+	}
+	accessToken, err := s.jwtSvc.GenerateAccessToken(payload)
+	if err != nil {
+		return "", "", errors.New("error generating access token")
+	}
+	refreshPayload := map[string]interface{}{"id": user.ID, "email": user.Email}
+	if !sessionID.IsZero() {
+		refreshPayload["sid"] = sessionID.Hex()
+		refreshPayload["nonce"] = uniuri.NewLen(16)
+	}
+	refreshToken, err := s.jwtSvc.GenerateRefreshToken(refreshPayload)
+	if err != nil {
+		return "", "", errors.New("error generating refresh token")
+	}
+	return accessToken, refreshToken, nil
+}
+
+// Returns the expiry of a refresh token
+func (s *AuthService) refreshTokenExpiry(refreshToken string) (primitive.DateTime, error) {
+	claims, err := s.jwtSvc.DecodeToken(refreshToken, jwt.TokenTypeRefresh)
+	if err != nil {
+		return 0, err
+	}
+	return primitive.NewDateTimeFromTime(time.Unix(claims.ExpiresAt, 0)), nil
+}
+
+// Starts a session for a user that just logged in, returns its access and refresh token
+func (s *AuthService) startSession(ctx context.Context, user *models.User) (string, string, error) {
+	if s.sessionRepo == nil {
+		return s.generateSessionTokens(user, primitive.NilObjectID)
+	}
+	actor := AuditActorFromContext(ctx)
+	now := primitive.NewDateTimeFromTime(time.Now())
+	session := &models.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     user.ID,
+		IP:         actor.IP,
+		UserAgent:  actor.UserAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	accessToken, refreshToken, err := s.generateSessionTokens(user, session.ID)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error generating session tokens")
+		return "", "", err
+	}
+	session.TokenHash = utils.HashToken(refreshToken)
+	if session.ExpiresAt, err = s.refreshTokenExpiry(refreshToken); err != nil {
+		return "", "", err
+	}
+	if _, err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// Replaces the refresh token of a session, a refresh token that was already replaced revokes the session
+func (s *AuthService) rotateSession(ctx context.Context, user *models.User, refreshToken string) (string, string, error) {
+	claims, err := s.jwtSvc.DecodeToken(refreshToken, jwt.TokenTypeRefresh)
+	if err != nil {
+		return "", "", models.ErrSessionRevoked
+	}
+	// the refresh tokens issued before the sessions cannot be refreshed
+	sessionID, _ := claims.Payload["sid"].(string)
+	if sessionID == "" {
+		return "", "", models.ErrSessionRevoked
+	}
+	session, err := s.sessionRepo.FindSessionByID(ctx, sessionID)
+	if errors.Is(err, models.ErrSessionNotFound) {
+		return "", "", models.ErrSessionRevoked
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if session.UserID != user.ID || !session.IsActive(time.Now()) {
+		return "", "", models.ErrSessionRevoked
+	}
+	previousHash := utils.HashToken(refreshToken)
+	if session.TokenHash != previousHash {
+		s.revokeReusedSession(ctx, session)
+		return "", "", models.ErrRefreshTokenReused
+	}
+
+	accessToken, newRefreshToken, err := s.generateSessionTokens(user, session.ID)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error generating session tokens")
+		return "", "", err
+	}
+	actor := AuditActorFromContext(ctx)
+	session.TokenHash = utils.HashToken(newRefreshToken)
+	session.IP = actor.IP
+	session.UserAgent = actor.UserAgent
+	session.LastUsedAt = primitive.NewDateTimeFromTime(time.Now())
+	if session.ExpiresAt, err = s.refreshTokenExpiry(newRefreshToken); err != nil {
+		return "", "", err
+	}
+	// the token was replaced by a concurrent refresh with the same token
+	if err := s.sessionRepo.RotateSession(ctx, session, previousHash); err != nil {
+		if errors.Is(err, models.ErrRefreshTokenReused) {
+			s.revokeReusedSession(ctx, session)
+		}
+		return "", "", err
+	}
+	return accessToken, newRefreshToken, nil
+}
+
+// Revokes a session whose replaced refresh token was used again, the token may have been stolen
+func (s *AuthService) revokeReusedSession(ctx context.Context, session *models.Session) {
+	if err := s.sessionRepo.RevokeSession(ctx, session.ID, models.SessionRevokedTokenReuse); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error revoking session: %s", session.ID.Hex())
+	}
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action:     models.AuditActionTokenReuse,
+		AuditActor: models.AuditActor{UserID: session.UserID},
+		Target:     session.ID.Hex(),
+	})
+}
+
+func (s *AuthService) recordTokenRefresh(ctx context.Context, user *models.User) {
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action:     models.AuditActionTokenRefresh,
+		AuditActor: models.AuditActor{UserID: user.ID},
+	})
+}
+
+// Logout
+// Revokes a session of the user, its access and refresh tokens are rejected from then on
+func (s *AuthService) Logout(ctx context.Context, user *models.User, sessionID string) error {
+	ctx, span := tracing.Start(ctx, "AuthService.Logout")
+	defer span.End()
+	if s.sessionRepo == nil || utils.IsStringEmpty(sessionID) {
+		return models.ErrSessionNotFound
+	}
+	session, err := s.sessionRepo.FindSessionByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != user.ID {
+		return models.ErrSessionNotFound
+	}
+	if err := s.sessionRepo.RevokeSession(ctx, session.ID, models.SessionRevokedLogout); err != nil {
+		return err
+	}
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action:     models.AuditActionLogout,
+		AuditActor: models.AuditActor{UserID: user.ID},
+		Target:     session.ID.Hex(),
+	})
+	return nil
+}
+
+// Logout all
+// Revokes all the sessions of the user, returns the number of revoked sessions
+func (s *AuthService) LogoutAll(ctx context.Context, user *models.User) (int64, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.LogoutAll")
+	defer span.End()
+	if s.sessionRepo == nil {
+		return 0, nil
+	}
+	revoked, err := s.sessionRepo.RevokeUserSessions(ctx, user.ID, models.SessionRevokedLogoutAll)
+	if err != nil {
+		return 0, err
+	}
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action:     models.AuditActionLogoutAll,
+		AuditActor: models.AuditActor{UserID: user.ID},
+		After:      map[string]interface{}{"sessions": revoked},
+	})
+	return revoked, nil
+}
+
+// Get sessions
+// Returns the active sessions of the user, the session of the request is flagged as current
+func (s *AuthService) GetSessions(ctx context.Context, user *models.User, currentSessionID string) ([]dto.SessionOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.GetSessions")
+	defer span.End()
+	out := []dto.SessionOutputDTO{}
+	if s.sessionRepo == nil {
+		return out, nil
+	}
+	sessions, err := s.sessionRepo.FindActiveSessions(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		out = append(out, dto.SessionOutputDTO{
+			ID:         session.ID.Hex(),
+			IP:         session.IP,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID.Hex() == currentSessionID,
+		})
+	}
+	return out, nil
+}
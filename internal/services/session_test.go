@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"keeper/internal/auth/jwt"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"keeper/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func provideSessionConfig() *config.Config {
+	return &config.Config{
+		Env:                      "test",
+		JwtSecretKey:             "secret",
+		AccessTokenJwtExpiresIn:  "30m",
+		RefreshTokenJwtExpiresIn: "7d",
+	}
+}
+
+func TestAuthService_Login_StartsSession(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userRepo := mocks.NewMockIUserRepository(ctrl)
+	sessionRepo := mocks.NewMockISessionRepository(ctrl)
+	authSvc := NewAuthService(provideSessionConfig(), userRepo, nil, nil, sessionRepo, nil)
+	hashedPassword, _ := utils.HashPassword("secret")
+	user := &models.User{ID: primitive.NewObjectID(), Email: "testuser@gmail.com", Password: hashedPassword}
+
+	userRepo.EXPECT().FindUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+	var created *models.Session
+	sessionRepo.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, session *models.Session) (primitive.ObjectID, error) {
+		created = session
+		return session.ID, nil
+	})
+	ctx := WithAuditActor(context.Background(), models.AuditActor{IP: "203.0.113.7", UserAgent: "Mozilla/5.0"})
+
+	out, err := authSvc.Login(ctx, dto.LoginUserInputDTO{Email: user.Email, Password: "secret"})
+
+	require.Nil(t, err)
+	require.Equal(t, user.ID, created.UserID)
+	require.Equal(t, "203.0.113.7", created.IP)
+	require.Equal(t, "Mozilla/5.0", created.UserAgent)
+	require.Equal(t, utils.HashToken(out.RefreshToken), created.TokenHash)
+	require.True(t, created.IsActive(time.Now()))
+	claims, err := jwt.NewJwtService(provideSessionConfig(), nil, nil).DecodeToken(out.AccessToken, jwt.TokenTypeAccess)
+	require.Nil(t, err)
+	require.Equal(t, created.ID.Hex(), claims.Payload["sid"])
+}
+
+func TestAuthService_RefreshToken_Rotation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := provideSessionConfig()
+	sessionRepo := mocks.NewMockISessionRepository(ctrl)
+	auditRepo := mocks.NewMockIAuditEventRepository(ctrl)
+	authSvc := NewAuthService(cfg, nil, auditRepo, nil, sessionRepo, nil)
+	jwtSvc := jwt.NewJwtService(cfg, nil, nil)
+	user := &models.User{ID: primitive.NewObjectID(), Email: "testuser@gmail.com", Password: "hashed"}
+	sessionID := primitive.NewObjectID()
+	payload := map[string]interface{}{"id": user.ID, "email": user.Email, "sid": sessionID.Hex(), "nonce": "first"}
+	refreshToken, _ := jwtSvc.GenerateRefreshToken(payload)
+	payload["nonce"] = "second"
+	replacedToken, _ := jwtSvc.GenerateRefreshToken(payload)
+	legacyToken, _ := jwtSvc.GenerateRefreshToken(map[string]interface{}{"id": user.ID, "email": user.Email})
+	activeSession := func() *models.Session {
+		return &models.Session{
+			ID:        sessionID,
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(refreshToken),
+			ExpiresAt: primitive.NewDateTimeFromTime(time.Now().Add(time.Hour)),
+		}
+	}
+
+	tt := []struct {
+		name    string
+		token   string
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name:  "should_rotate_refresh_token",
+			token: refreshToken,
+			stubFn: func() {
+				sessionRepo.EXPECT().FindSessionByID(gomock.Any(), sessionID.Hex()).Times(1).Return(activeSession(), nil)
+				sessionRepo.EXPECT().RotateSession(gomock.Any(), gomock.Any(), utils.HashToken(refreshToken)).
+					Times(1).DoAndReturn(func(_ context.Context, session *models.Session, _ string) error {
+					require.NotEqual(t, utils.HashToken(refreshToken), session.TokenHash)
+					return nil
+				})
+				auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).Times(1).Return(primitive.NewObjectID(), nil)
+			},
+		},
+		{
+			name:  "should_revoke_session_reused_refresh_token",
+			token: replacedToken,
+			stubFn: func() {
+				sessionRepo.EXPECT().FindSessionByID(gomock.Any(), sessionID.Hex()).Times(1).Return(activeSession(), nil)
+				sessionRepo.EXPECT().RevokeSession(gomock.Any(), sessionID, models.SessionRevokedTokenReuse).Times(1).Return(nil)
+				auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).
+					Times(1).DoAndReturn(func(_ context.Context, event *models.AuditEvent) (primitive.ObjectID, error) {
+					require.Equal(t, models.AuditActionTokenReuse, event.Action)
+					return primitive.NewObjectID(), nil
+				})
+			},
+			wantErr: models.ErrRefreshTokenReused,
+		},
+		{
+			name:  "should_revoke_session_concurrent_rotation",
+			token: refreshToken,
+			stubFn: func() {
+				sessionRepo.EXPECT().FindSessionByID(gomock.Any(), sessionID.Hex()).Times(1).Return(activeSession(), nil)
+				sessionRepo.EXPECT().RotateSession(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(models.ErrRefreshTokenReused)
+				sessionRepo.EXPECT().RevokeSession(gomock.Any(), sessionID, models.SessionRevokedTokenReuse).Times(1).Return(nil)
+				auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).Times(1).Return(primitive.NewObjectID(), nil)
+			},
+			wantErr: models.ErrRefreshTokenReused,
+		},
+		{
+			name:  "should_fail_revoked_session",
+			token: refreshToken,
+			stubFn: func() {
+				session := activeSession()
+				session.RevokedAt = primitive.NewDateTimeFromTime(time.Now())
+				sessionRepo.EXPECT().FindSessionByID(gomock.Any(), sessionID.Hex()).Times(1).Return(session, nil)
+			},
+			wantErr: models.ErrSessionRevoked,
+		},
+		{
+			name:    "should_fail_refresh_token_without_session",
+			token:   legacyToken,
+			stubFn:  func() {},
+			wantErr: models.ErrSessionRevoked,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stubFn()
+			out, err := authSvc.RefreshToken(context.Background(), user, tc.token)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			require.NotEmpty(t, out.AccessToken)
+			require.NotEqual(t, tc.token, out.RefreshToken)
+		})
+	}
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sessionRepo := mocks.NewMockISessionRepository(ctrl)
+	authSvc := NewAuthService(provideSessionConfig(), nil, nil, nil, sessionRepo, nil)
+	user := &models.User{ID: primitive.NewObjectID()}
+	session := &models.Session{ID: primitive.NewObjectID(), UserID: user.ID}
+	otherSession := &models.Session{ID: primitive.NewObjectID(), UserID: primitive.NewObjectID()}
+
+	sessionRepo.EXPECT().FindSessionByID(gomock.Any(), session.ID.Hex()).Times(1).Return(session, nil)
+	sessionRepo.EXPECT().RevokeSession(gomock.Any(), session.ID, models.SessionRevokedLogout).Times(1).Return(nil)
+	require.Nil(t, authSvc.Logout(context.Background(), user, session.ID.Hex()))
+
+	// the sessions of the other users cannot be revoked
+	sessionRepo.EXPECT().FindSessionByID(gomock.Any(), otherSession.ID.Hex()).Times(1).Return(otherSession, nil)
+	require.ErrorIs(t, authSvc.Logout(context.Background(), user, otherSession.ID.Hex()), models.ErrSessionNotFound)
+
+	// an api key has no session
+	require.ErrorIs(t, authSvc.Logout(context.Background(), user, ""), models.ErrSessionNotFound)
+
+	sessionRepo.EXPECT().RevokeUserSessions(gomock.Any(), user.ID, models.SessionRevokedLogoutAll).Times(1).Return(int64(3), nil)
+	revoked, err := authSvc.LogoutAll(context.Background(), user)
+	require.Nil(t, err)
+	require.Equal(t, int64(3), revoked)
+}
+
+func TestAuthService_GetSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sessionRepo := mocks.NewMockISessionRepository(ctrl)
+	authSvc := NewAuthService(provideSessionConfig(), nil, nil, nil, sessionRepo, nil)
+	user := &models.User{ID: primitive.NewObjectID()}
+	current := models.Session{ID: primitive.NewObjectID(), UserID: user.ID, IP: "203.0.113.7", UserAgent: "Mozilla/5.0"}
+	other := models.Session{ID: primitive.NewObjectID(), UserID: user.ID, IP: "198.51.100.2"}
+
+	sessionRepo.EXPECT().FindActiveSessions(gomock.Any(), user.ID).Times(1).Return([]models.Session{current, other}, nil)
+
+	sessions, err := authSvc.GetSessions(context.Background(), user, current.ID.Hex())
+	require.Nil(t, err)
+	require.Len(t, sessions, 2)
+	require.True(t, sessions[0].Current)
+	require.Equal(t, "Mozilla/5.0", sessions[0].UserAgent)
+	require.False(t, sessions[1].Current)
+	require.Equal(t, "198.51.100.2", sessions[1].IP)
+}
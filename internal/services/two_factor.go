@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/auth/jwt"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/pkg/totp"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// issuer shown by the authenticator apps
+	twoFactorIssuer = "Kipa"
+	// number of recovery codes given when two-factor authentication is enabled
+	recoveryCodesCount = 10
+)
+
+var recoveryCodeChars = []byte("abcdefghijklmnopqrstuvwxyz0123456789")
+
+// Returns new recovery codes and their hashes, the codes are formatted as xxxxx-xxxxx
+func generateRecoveryCodes() ([]string, []string) {
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		code := uniuri.NewLenChars(10, recoveryCodeChars)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes
+}
+
+// Returns the hash of a recovery code, ignoring its case and separators
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return utils.HashToken(code)
+}
+
+// Verifies a two-factor code, or a recovery code, of a user
+// each code can only be used once
+func (s *AuthService) verifyTwoFactor(ctx context.Context, userID primitive.ObjectID, code string, recoveryCode string) error {
+	twoFactor, err := s.twoFactorRepo.FindTwoFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !twoFactor.IsEnabled() {
+		return models.ErrTwoFactorNotEnabled
+	}
+	if !utils.IsStringEmpty(recoveryCode) {
+		if err := s.twoFactorRepo.UseRecoveryCode(ctx, userID, hashRecoveryCode(recoveryCode)); err != nil {
+			return err
+		}
+		recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+			Action:     models.AuditActionRecoveryCodeUse,
+			AuditActor: models.AuditActor{UserID: userID},
+			After:      map[string]interface{}{"recovery_codes": len(twoFactor.RecoveryCodes) - 1},
+		})
+		return nil
+	}
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return models.ErrInvalidTwoFactorCode
+	}
+	// a code is rejected once it, or a later one, was used
+	return s.twoFactorRepo.UseTwoFactorStep(ctx, userID, step)
+}
+
+// Verifies the password and a two-factor or recovery code of a user, before its two-factor authentication is changed
+func (s *AuthService) reauthenticate(ctx context.Context, user *models.User, data dto.TwoFactorReauthInputDTO) error {
+	if err := utils.ComparePasswordHash(data.Password, user.Password); err != nil {
+		return models.ErrIncorrectPassword
+	}
+	return s.verifyTwoFactor(ctx, user.ID, data.Code, data.RecoveryCode)
+}
+
+// Login with two-factor authentication
+// Exchanges the two-factor token returned by the login and a two-factor or recovery code for the access and refresh token
+func (s *AuthService) LoginTwoFactor(ctx context.Context, data dto.TwoFactorLoginInputDTO) (*dto.LoginUserOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.LoginTwoFactor")
+	defer span.End()
+	if s.twoFactorRepo == nil {
+		return &dto.LoginUserOutputDTO{}, models.ErrInvalidTwoFactorToken
+	}
+	claims, err := s.jwtSvc.DecodeToken(data.Token, jwt.TokenTypeTwoFactor)
+	if err != nil {
+		return &dto.LoginUserOutputDTO{}, models.ErrInvalidTwoFactorToken
+	}
+	userID, _ := claims.Payload["id"].(string)
+	email, _ := claims.Payload["email"].(string)
+	if utils.IsStringEmpty(userID) || utils.IsStringEmpty(email) {
+		return &dto.LoginUserOutputDTO{}, models.ErrInvalidTwoFactorToken
+	}
+	// the wrong codes count towards the lockout of the login
+	if err := s.checkLoginLock(ctx, email); err != nil {
+		return &dto.LoginUserOutputDTO{}, err
+	}
+	user, err := s.userRepo.FindUserById(ctx, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return &dto.LoginUserOutputDTO{}, models.ErrInvalidTwoFactorToken
+		}
+		return &dto.LoginUserOutputDTO{}, err
+	}
+	// the two-factor authentication was disabled since the token was issued
+	if !user.TwoFactorEnabled {
+		return &dto.LoginUserOutputDTO{}, models.ErrInvalidTwoFactorToken
+	}
+	if err := s.verifyTwoFactor(ctx, user.ID, data.Code, data.RecoveryCode); err != nil {
+		if errors.Is(err, models.ErrInvalidTwoFactorCode) {
+			s.recordLogin(ctx, models.AuditActionLoginFailed, email, user.ID)
+			s.recordLoginFailure(ctx, email, user)
+		}
+		return &dto.LoginUserOutputDTO{}, err
+	}
+
+	accessToken, refreshToken, err := s.startSession(ctx, user)
+	if err != nil {
+		return &dto.LoginUserOutputDTO{}, err
+	}
+	s.recordLogin(ctx, models.AuditActionLogin, email, user.ID)
+	s.resetLoginFailures(ctx, email)
+	return &dto.LoginUserOutputDTO{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// Enroll two-factor authentication
+// Returns a new secret and its provisioning URI, the two-factor authentication is enabled once a code of the secret is confirmed
+func (s *AuthService) EnrollTwoFactor(ctx context.Context, user *models.User) (*dto.TwoFactorEnrollOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.EnrollTwoFactor")
+	defer span.End()
+	if user.TwoFactorEnabled {
+		return &dto.TwoFactorEnrollOutputDTO{}, models.ErrTwoFactorAlreadyEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return &dto.TwoFactorEnrollOutputDTO{}, err
+	}
+	if err := s.twoFactorRepo.SavePendingSecret(ctx, user.ID, secret); err != nil {
+		return &dto.TwoFactorEnrollOutputDTO{}, err
+	}
+	return &dto.TwoFactorEnrollOutputDTO{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(secret, twoFactorIssuer, user.Email),
+	}, nil
+}
+
+// Confirm two-factor authentication
+// Enables the two-factor authentication with a code of the enrolled secret, returns the recovery codes
+func (s *AuthService) ConfirmTwoFactor(ctx context.Context, user *models.User, data dto.TwoFactorCodeInputDTO) (*dto.RecoveryCodesOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ConfirmTwoFactor")
+	defer span.End()
+	if user.TwoFactorEnabled {
+		return &dto.RecoveryCodesOutputDTO{}, models.ErrTwoFactorAlreadyEnabled
+	}
+	twoFactor, err := s.twoFactorRepo.FindTwoFactor(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrTwoFactorNotEnabled) {
+			return &dto.RecoveryCodesOutputDTO{}, models.ErrTwoFactorNotEnrolled
+		}
+		return &dto.RecoveryCodesOutputDTO{}, err
+	}
+	if utils.IsStringEmpty(twoFactor.PendingSecret) {
+		return &dto.RecoveryCodesOutputDTO{}, models.ErrTwoFactorNotEnrolled
+	}
+	step, ok := totp.Validate(twoFactor.PendingSecret, data.Code, time.Now())
+	if !ok {
+		return &dto.RecoveryCodesOutputDTO{}, models.ErrInvalidTwoFactorCode
+	}
+	codes, hashes := generateRecoveryCodes()
+	if err := s.twoFactorRepo.EnableTwoFactor(ctx, user.ID, twoFactor.PendingSecret, hashes, step); err != nil {
+		return &dto.RecoveryCodesOutputDTO{}, err
+	}
+	if err := s.userRepo.SetUserTwoFactorEnabled(ctx, user.ID, true); err != nil {
+		return &dto.RecoveryCodesOutputDTO{}, err
+	}
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action:     models.AuditActionTwoFactorEnable,
+		AuditActor: models.AuditActor{UserID: user.ID},
+	})
+	return &dto.RecoveryCodesOutputDTO{RecoveryCodes: codes}, nil
+}
+
+// Disable two-factor authentication
+// Requires the password and a two-factor or recovery code of the user
+func (s *AuthService) DisableTwoFactor(ctx context.Context, user *models.User, data dto.TwoFactorReauthInputDTO) error {
+	ctx, span := tracing.Start(ctx, "AuthService.DisableTwoFactor")
+	defer span.End()
+	if !user.TwoFactorEnabled {
+		return models.ErrTwoFactorNotEnabled
+	}
+	if err := s.reauthenticate(ctx, user, data); err != nil {
+		return err
+	}
+	// the flag is cleared first, so that the logins never require a deleted secret
+	if err := s.userRepo.SetUserTwoFactorEnabled(ctx, user.ID, false); err != nil {
+		return err
+	}
+	if err := s.twoFactorRepo.DeleteTwoFactor(ctx, user.ID); err != nil {
+		return err
+	}
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action:     models.AuditActionTwoFactorDisable,
+		AuditActor: models.AuditActor{UserID: user.ID},
+	})
+	return nil
+}
+
+// Regenerate recovery codes
+// Replaces the recovery codes of the user, requires its password and a two-factor or recovery code
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, user *models.User, data dto.TwoFactorReauthInputDTO) (*dto.RecoveryCodesOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.RegenerateRecoveryCodes")
+	defer span.End()
+	if !user.TwoFactorEnabled {
+		return &dto.RecoveryCodesOutputDTO{}, models.ErrTwoFactorNotEnabled
+	}
+	if err := s.reauthenticate(ctx, user, data); err != nil {
+		return &dto.RecoveryCodesOutputDTO{}, err
+	}
+	codes, hashes := generateRecoveryCodes()
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return &dto.RecoveryCodesOutputDTO{}, err
+	}
+	recordAuditEvent(ctx, s.auditRepo, &models.AuditEvent{
+		Action:     models.AuditActionRecoveryCodesNew,
+		AuditActor: models.AuditActor{UserID: user.ID},
+	})
+	return &dto.RecoveryCodesOutputDTO{RecoveryCodes: codes}, nil
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"keeper/internal/auth/jwt"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"keeper/internal/pkg/totp"
+	"keeper/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func provideTwoFactorConfig() *config.Config {
+	return &config.Config{
+		Env:                      "test",
+		JwtSecretKey:             "secret",
+		AccessTokenJwtExpiresIn:  "30m",
+		RefreshTokenJwtExpiresIn: "7d",
+		TwoFactorTokenExpiresIn:  "5m",
+	}
+}
+
+func TestAuthService_Login_TwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cfg := provideTwoFactorConfig()
+	userRepo := mocks.NewMockIUserRepository(ctrl)
+	twoFactorRepo := mocks.NewMockITwoFactorRepository(ctrl)
+	authSvc := NewAuthService(cfg, userRepo, nil, nil, nil, twoFactorRepo)
+	jwtSvc := jwt.NewJwtService(cfg, nil, nil)
+	hashedPassword, _ := utils.HashPassword("secret")
+	user := &models.User{ID: primitive.NewObjectID(), Email: "testuser@gmail.com", Password: hashedPassword, TwoFactorEnabled: true}
+	secret, _ := totp.GenerateSecret()
+	twoFactor := &models.TwoFactor{UserID: user.ID, Secret: secret, RecoveryCodes: []string{hashRecoveryCode("abcde-12345")}}
+	step := totp.Step(time.Now())
+	code, _ := totp.Code(secret, step)
+
+	// the password alone only returns a two-factor token
+	userRepo.EXPECT().FindUserByEmail(gomock.Any(), user.Email).Times(1).Return(user, nil)
+	out, err := authSvc.Login(context.Background(), dto.LoginUserInputDTO{Email: user.Email, Password: "secret"})
+	require.Nil(t, err)
+	require.True(t, out.TwoFactorRequired)
+	require.Empty(t, out.AccessToken)
+	require.Empty(t, out.RefreshToken)
+	twoFactorToken := out.TwoFactorToken
+	accessToken, _ := jwtSvc.GenerateAccessToken(map[string]interface{}{"id": user.ID.Hex(), "email": user.Email})
+
+	tt := []struct {
+		name    string
+		data    dto.TwoFactorLoginInputDTO
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name: "should_login_with_two_factor_code",
+			data: dto.TwoFactorLoginInputDTO{Token: twoFactorToken, Code: code},
+			stubFn: func() {
+				userRepo.EXPECT().FindUserById(gomock.Any(), user.ID.Hex()).Times(1).Return(user, nil)
+				twoFactorRepo.EXPECT().FindTwoFactor(gomock.Any(), user.ID).Times(1).Return(twoFactor, nil)
+				twoFactorRepo.EXPECT().UseTwoFactorStep(gomock.Any(), user.ID, step).Times(1).Return(nil)
+			},
+		},
+		{
+			name: "should_login_with_recovery_code",
+			data: dto.TwoFactorLoginInputDTO{Token: twoFactorToken, RecoveryCode: "ABCDE12345"},
+			stubFn: func() {
+				userRepo.EXPECT().FindUserById(gomock.Any(), user.ID.Hex()).Times(1).Return(user, nil)
+				twoFactorRepo.EXPECT().FindTwoFactor(gomock.Any(), user.ID).Times(1).Return(twoFactor, nil)
+				twoFactorRepo.EXPECT().UseRecoveryCode(gomock.Any(), user.ID, hashRecoveryCode("abcde-12345")).Times(1).Return(nil)
+			},
+		},
+		{
+			name: "should_fail_reused_two_factor_code",
+			data: dto.TwoFactorLoginInputDTO{Token: twoFactorToken, Code: code},
+			stubFn: func() {
+				userRepo.EXPECT().FindUserById(gomock.Any(), user.ID.Hex()).Times(1).Return(user, nil)
+				twoFactorRepo.EXPECT().FindTwoFactor(gomock.Any(), user.ID).Times(1).Return(twoFactor, nil)
+				twoFactorRepo.EXPECT().UseTwoFactorStep(gomock.Any(), user.ID, step).Times(1).Return(models.ErrInvalidTwoFactorCode)
+			},
+			wantErr: models.ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "should_fail_wrong_two_factor_code",
+			data: dto.TwoFactorLoginInputDTO{Token: twoFactorToken, Code: "abcdef"},
+			stubFn: func() {
+				userRepo.EXPECT().FindUserById(gomock.Any(), user.ID.Hex()).Times(1).Return(user, nil)
+				twoFactorRepo.EXPECT().FindTwoFactor(gomock.Any(), user.ID).Times(1).Return(twoFactor, nil)
+			},
+			wantErr: models.ErrInvalidTwoFactorCode,
+		},
+		{
+			name:    "should_fail_access_token_as_two_factor_token",
+			data:    dto.TwoFactorLoginInputDTO{Token: accessToken, Code: code},
+			stubFn:  func() {},
+			wantErr: models.ErrInvalidTwoFactorToken,
+		},
+		{
+			name: "should_fail_two_factor_disabled_since_login",
+			data: dto.TwoFactorLoginInputDTO{Token: twoFactorToken, Code: code},
+			stubFn: func() {
+				userRepo.EXPECT().FindUserById(gomock.Any(), user.ID.Hex()).Times(1).
+					Return(&models.User{ID: user.ID, Email: user.Email}, nil)
+			},
+			wantErr: models.ErrInvalidTwoFactorToken,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stubFn()
+			out, err := authSvc.LoginTwoFactor(context.Background(), tc.data)
+			require.Equal(t, tc.wantErr, err)
+			if tc.wantErr == nil {
+				require.NotEmpty(t, out.AccessToken)
+				require.NotEmpty(t, out.RefreshToken)
+			}
+		})
+	}
+}
+
+func TestAuthService_ConfirmTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userRepo := mocks.NewMockIUserRepository(ctrl)
+	twoFactorRepo := mocks.NewMockITwoFactorRepository(ctrl)
+	auditRepo := mocks.NewMockIAuditEventRepository(ctrl)
+	authSvc := NewAuthService(provideTwoFactorConfig(), userRepo, auditRepo, nil, nil, twoFactorRepo)
+	user := &models.User{ID: primitive.NewObjectID(), Email: "testuser@gmail.com"}
+
+	twoFactorRepo.EXPECT().SavePendingSecret(gomock.Any(), user.ID, gomock.Any()).Times(1).Return(nil)
+	enrollment, err := authSvc.EnrollTwoFactor(context.Background(), user)
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(enrollment.ProvisioningURI, "otpauth://totp/Kipa:testuser@gmail.com?"))
+	step := totp.Step(time.Now())
+	code, _ := totp.Code(enrollment.Secret, step)
+	pending := &models.TwoFactor{UserID: user.ID, PendingSecret: enrollment.Secret}
+
+	tt := []struct {
+		name    string
+		code    string
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name: "should_fail_wrong_code",
+			code: "000000",
+			stubFn: func() {
+				twoFactorRepo.EXPECT().FindTwoFactor(gomock.Any(), user.ID).Times(1).Return(pending, nil)
+			},
+			wantErr: models.ErrInvalidTwoFactorCode,
+		},
+		{
+			name: "should_fail_not_enrolled",
+			code: code,
+			stubFn: func() {
+				twoFactorRepo.EXPECT().FindTwoFactor(gomock.Any(), user.ID).Times(1).Return(nil, models.ErrTwoFactorNotEnabled)
+			},
+			wantErr: models.ErrTwoFactorNotEnrolled,
+		},
+		{
+			name: "should_enable_two_factor",
+			code: code,
+			stubFn: func() {
+				twoFactorRepo.EXPECT().FindTwoFactor(gomock.Any(), user.ID).Times(1).Return(pending, nil)
+				twoFactorRepo.EXPECT().EnableTwoFactor(gomock.Any(), user.ID, enrollment.Secret, gomock.Len(recoveryCodesCount), step).Times(1).Return(nil)
+				userRepo.EXPECT().SetUserTwoFactorEnabled(gomock.Any(), user.ID, true).Times(1).Return(nil)
+				auditRepo.EXPECT().CreateAuditEvent(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(_ context.Context, event *models.AuditEvent) (primitive.ObjectID, error) {
+						require.Equal(t, models.AuditActionTwoFactorEnable, event.Action)
+						return primitive.NewObjectID(), nil
+					})
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stubFn()
+			out, err := authSvc.ConfirmTwoFactor(context.Background(), user, dto.TwoFactorCodeInputDTO{Code: tc.code})
+			require.Equal(t, tc.wantErr, err)
+			if tc.wantErr == nil {
+				require.Len(t, out.RecoveryCodes, recoveryCodesCount)
+				require.Regexp(t, "^[a-z0-9]{5}-[a-z0-9]{5}$", out.RecoveryCodes[0])
+			}
+		})
+	}
+}
+
+func TestAuthService_DisableTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userRepo := mocks.NewMockIUserRepository(ctrl)
+	twoFactorRepo := mocks.NewMockITwoFactorRepository(ctrl)
+	authSvc := NewAuthService(provideTwoFactorConfig(), userRepo, nil, nil, nil, twoFactorRepo)
+	hashedPassword, _ := utils.HashPassword("secret")
+	user := &models.User{ID: primitive.NewObjectID(), Email: "testuser@gmail.com", Password: hashedPassword, TwoFactorEnabled: true}
+	secret, _ := totp.GenerateSecret()
+	twoFactor := &models.TwoFactor{UserID: user.ID, Secret: secret}
+	step := totp.Step(time.Now())
+	code, _ := totp.Code(secret, step)
+
+	tt := []struct {
+		name    string
+		user    *models.User
+		data    dto.TwoFactorReauthInputDTO
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name:    "should_fail_incorrect_password",
+			user:    user,
+			data:    dto.TwoFactorReauthInputDTO{Password: "wrong", Code: code},
+			stubFn:  func() {},
+			wantErr: models.ErrIncorrectPassword,
+		},
+		{
+			name:    "should_fail_two_factor_not_enabled",
+			user:    &models.User{ID: user.ID, Password: hashedPassword},
+			data:    dto.TwoFactorReauthInputDTO{Password: "secret", Code: code},
+			stubFn:  func() {},
+			wantErr: models.ErrTwoFactorNotEnabled,
+		},
+		{
+			name: "should_disable_two_factor",
+			user: user,
+			data: dto.TwoFactorReauthInputDTO{Password: "secret", Code: code},
+			stubFn: func() {
+				twoFactorRepo.EXPECT().FindTwoFactor(gomock.Any(), user.ID).Times(1).Return(twoFactor, nil)
+				twoFactorRepo.EXPECT().UseTwoFactorStep(gomock.Any(), user.ID, step).Times(1).Return(nil)
+				userRepo.EXPECT().SetUserTwoFactorEnabled(gomock.Any(), user.ID, false).Times(1).Return(nil)
+				twoFactorRepo.EXPECT().DeleteTwoFactor(gomock.Any(), user.ID).Times(1).Return(nil)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stubFn()
+			err := authSvc.DisableTwoFactor(context.Background(), tc.user, tc.data)
+			require.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestBucketService_SetBucketTwoFactor(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketSvc := NewBucketService(&config.Config{Env: "test"}, bucketRepo, nil, nil, nil, nil, nil, nil, nil)
+	owner := &models.User{ID: primitive.NewObjectID(), TwoFactorEnabled: true}
+	bucket := &models.Bucket{UID: "bucket", UserID: owner.ID}
+
+	tt := []struct {
+		name    string
+		user    *models.User
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name: "should_require_two_factor",
+			user: owner,
+			stubFn: func() {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), bucket.UID).Times(1).Return(bucket, nil)
+				bucketRepo.EXPECT().SetBucketRequireTwoFactor(gomock.Any(), bucket.UID, true).Times(1).Return(nil)
+			},
+		},
+		{
+			name: "should_fail_owner_without_two_factor",
+			user: &models.User{ID: owner.ID},
+			stubFn: func() {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), bucket.UID).Times(1).Return(bucket, nil)
+			},
+			wantErr: models.ErrTwoFactorRequired,
+		},
+		{
+			name: "should_fail_not_owner",
+			user: &models.User{ID: primitive.NewObjectID(), TwoFactorEnabled: true},
+			stubFn: func() {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), bucket.UID).Times(1).Return(bucket, nil)
+			},
+			wantErr: models.ErrBucketNotFound,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.stubFn()
+			err := bucketSvc.SetBucketTwoFactor(context.Background(), bucket.UID, tc.user, true)
+			require.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/repository"
+	"keeper/internal/utils"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type UsageService struct {
+	usageRepo repository.IUsageRepository
+	userRepo  repository.IUserRepository
+	cfg       *config.Config
+}
+
+type IUsageService interface {
+	GetUserUsage(ctx context.Context, userID string) (*dto.UserUsageOutputDTO, error)
+	UpdateUserQuota(ctx context.Context, userID string, data dto.UpdateUserQuotaInputDTO) error
+	RecomputeUsage(ctx context.Context) error
+	MoveBucketUsage(ctx context.Context, bucketUID string, from primitive.ObjectID, to primitive.ObjectID) error
+}
+
+func NewUsageService(cfg *config.Config, usageRepo repository.IUsageRepository, userRepo repository.IUserRepository) IUsageService {
+	return &UsageService{
+		usageRepo: usageRepo,
+		userRepo:  userRepo,
+		cfg:       cfg,
+	}
+}
+
+// Returns the quota of the deployment, applied to the users without an override
+func defaultQuota(cfg *config.Config) models.Quota {
+	return models.Quota{
+		MaxBuckets:        int64(cfg.QuotaMaxBuckets),
+		MaxItemsPerBucket: int64(cfg.QuotaMaxItemsPerBucket),
+		MaxValueBytes:     int64(cfg.QuotaMaxValueBytes),
+		MaxStorageBytes:   int64(cfg.QuotaMaxStorageBytes),
+	}
+}
+
+// Checks that a write adding delta to the usage of a user, and of one of its buckets, fits in the quota of the user
+// valueBytes is the size of the largest value written, only the increases of the usage are checked
+// the check alone does not hold against concurrent writes, see reserveUsage
+func checkQuota(ctx context.Context, cfg *config.Config, usageRepo repository.IUsageRepository, userID primitive.ObjectID, bucketUID string, delta models.UsageDelta, valueBytes int64) error {
+	_, err := findQuota(ctx, cfg, usageRepo, userID, bucketUID, delta, valueBytes)
+	return err
+}
+
+// Returns the quota of a user once a write adding delta to its usage is checked against it, see checkQuota
+func findQuota(ctx context.Context, cfg *config.Config, usageRepo repository.IUsageRepository, userID primitive.ObjectID, bucketUID string, delta models.UsageDelta, valueBytes int64) (models.Quota, error) {
+	// the workers write without quotas
+	if usageRepo == nil {
+		return models.Quota{}, nil
+	}
+	usage, err := usageRepo.FindUserUsage(ctx, userID)
+	if err != nil {
+		return models.Quota{}, err
+	}
+	quota := defaultQuota(cfg).Override(usage.Quota)
+	if quota.MaxValueBytes > 0 && valueBytes > quota.MaxValueBytes {
+		return quota, models.ErrValueQuotaExceeded
+	}
+	if quota.MaxBuckets > 0 && delta.Buckets > 0 && usage.Buckets+delta.Buckets > quota.MaxBuckets {
+		return quota, models.ErrBucketQuotaExceeded
+	}
+	if quota.MaxStorageBytes > 0 && delta.Bytes > 0 && usage.Bytes+delta.Bytes > quota.MaxStorageBytes {
+		return quota, models.ErrStorageQuotaExceeded
+	}
+	if quota.MaxItemsPerBucket > 0 && delta.Items > 0 {
+		// the items written without a bucket UID are the ones of a new bucket
+		var items int64
+		if bucketUID != "" {
+			bucketUsage, err := usageRepo.FindBucketUsage(ctx, bucketUID)
+			if err != nil {
+				return quota, err
+			}
+			items = bucketUsage.Items
+		}
+		if items+delta.Items > quota.MaxItemsPerBucket {
+			return quota, models.ErrItemQuotaExceeded
+		}
+	}
+	return quota, nil
+}
+
+// Checks a write against the quota of a user, see checkQuota, and adds its delta to the usage before it happens
+// the increment enforces the limits again so that concurrent writes cannot exceed them together,
+// the usage of a write that fails is given back with recordUsage and the negated delta
+func reserveUsage(ctx context.Context, cfg *config.Config, usageRepo repository.IUsageRepository, userID primitive.ObjectID, bucketUID string, delta models.UsageDelta, valueBytes int64) error {
+	quota, err := findQuota(ctx, cfg, usageRepo, userID, bucketUID, delta, valueBytes)
+	if err != nil || usageRepo == nil || delta == (models.UsageDelta{}) {
+		return err
+	}
+	return usageRepo.IncrementUsage(ctx, userID, bucketUID, delta, quota)
+}
+
+// Adds delta to the usage of a user and of one of its buckets
+// the usage that cannot be recorded is only logged, the write has already happened and the counters are recomputed later
+func recordUsage(ctx context.Context, usageRepo repository.IUsageRepository, userID primitive.ObjectID, bucketUID string, delta models.UsageDelta) {
+	if usageRepo == nil || delta == (models.UsageDelta{}) {
+		return
+	}
+	if err := usageRepo.IncrementUsage(ctx, userID, bucketUID, delta, models.Quota{}); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error recording usage of bucket: %s", bucketUID)
+	}
+}
+
+// Returns the usage of the items of a list
+func bucketItemsUsage(items []models.BucketItem) models.UsageDelta {
+	delta := models.UsageDelta{Items: int64(len(items))}
+	for i := range items {
+		delta.Bytes += items[i].UsageBytes()
+	}
+	return delta
+}
+
+// Returns the largest value of a list of bucket items, in bytes
+func largestValueBytes(items []models.BucketItem) int64 {
+	var largest int64
+	for i := range items {
+		if size := items[i].UsageBytes(); size > largest {
+			largest = size
+		}
+	}
+	return largest
+}
+
+// Returns the usage of a user with the usage of each of its buckets and its quota
+func (u *UsageService) GetUserUsage(ctx context.Context, userID string) (*dto.UserUsageOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "UsageService.GetUserUsage")
+	defer span.End()
+	if utils.IsStringEmpty(userID) {
+		return nil, ErrUserIDIsEmpty
+	}
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, models.ErrUserNotFound
+	}
+	usage, err := u.usageRepo.FindUserUsage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	bucketUsages, err := u.usageRepo.FindBucketUsages(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.UserUsageOutputDTO{
+		Buckets: usage.Buckets,
+		Items:   usage.Items,
+		Bytes:   usage.Bytes,
+		Quota:   defaultQuota(u.cfg).Override(usage.Quota),
+		Usages:  bucketUsages,
+	}, nil
+}
+
+// Sets the quota override of a user, an override with all its limits at 0 is removed
+func (u *UsageService) UpdateUserQuota(ctx context.Context, userID string, data dto.UpdateUserQuotaInputDTO) error {
+	ctx, span := tracing.Start(ctx, "UsageService.UpdateUserQuota")
+	defer span.End()
+	if utils.IsStringEmpty(userID) {
+		return ErrUserIDIsEmpty
+	}
+	user, err := u.userRepo.FindUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	quota := &models.Quota{
+		MaxBuckets:        data.MaxBuckets,
+		MaxItemsPerBucket: data.MaxItemsPerBucket,
+		MaxValueBytes:     data.MaxValueBytes,
+		MaxStorageBytes:   data.MaxStorageBytes,
+	}
+	if *quota == (models.Quota{}) {
+		quota = nil
+	}
+	return u.usageRepo.UpdateUserQuota(ctx, user.ID, quota)
+}
+
+// Recomputes the usage of all the users and buckets from the stored data
+func (u *UsageService) RecomputeUsage(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "UsageService.RecomputeUsage")
+	defer span.End()
+	return u.usageRepo.RecomputeUsage(ctx)
+}
+
+// Moves the usage of a bucket from its owner to a new one, the bucket is checked against the quota of the new owner
+// the bucket keeps its own usage, it is only given to the new owner
+func (u *UsageService) MoveBucketUsage(ctx context.Context, bucketUID string, from primitive.ObjectID, to primitive.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "UsageService.MoveBucketUsage")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return ErrBucketUIDIsEmpty
+	}
+	bucketUsage, err := u.usageRepo.FindBucketUsage(ctx, bucketUID)
+	if err != nil {
+		return err
+	}
+	// the items are checked as the ones of a new bucket of the new owner
+	delta := models.UsageDelta{Buckets: 1, Items: bucketUsage.Items, Bytes: bucketUsage.Bytes}
+	if err := reserveUsage(ctx, u.cfg, u.usageRepo, to, "", delta, 0); err != nil {
+		return err
+	}
+	recordUsage(ctx, u.usageRepo, from, "", delta.Negate())
+	// an empty delta only sets the owner of the usage of the bucket
+	return u.usageRepo.IncrementUsage(ctx, to, bucketUID, models.UsageDelta{}, models.Quota{})
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func provideQuotaConfig() *config.Config {
+	return &config.Config{
+		Env:                    "test",
+		QuotaMaxBuckets:        2,
+		QuotaMaxItemsPerBucket: 10,
+		QuotaMaxValueBytes:     64,
+		QuotaMaxStorageBytes:   1024,
+	}
+}
+
+func TestBucketItemService_CreateBucketItem_Quota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	usageRepo := mocks.NewMockIUsageRepository(ctrl)
+	service := NewBucketItemService(provideQuotaConfig(), bucketItemRepo, bucketRepo, nil, nil, usageRepo, nil)
+	ownerID := primitive.NewObjectID()
+	callerID := primitive.NewObjectID()
+
+	tt := []struct {
+		name    string
+		data    dto.CreateBucketItemInputDTO
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name: "should_create_bucket_item_and_charge_the_bucket_owner",
+			data: dto.CreateBucketItemInputDTO{Key: "a", Data: "value"},
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), ownerID).
+					Times(1).Return(&models.Usage{UserID: ownerID, Bytes: 100}, nil)
+				usageRepo.EXPECT().FindBucketUsage(gomock.Any(), "12345").
+					Times(1).Return(&models.Usage{BucketUID: "12345", Items: 9}, nil)
+				usageRepo.EXPECT().IncrementUsage(gomock.Any(), ownerID, "12345", gomock.Any(), defaultQuota(provideQuotaConfig())).
+					Times(1).DoAndReturn(func(_ context.Context, _ primitive.ObjectID, _ string, delta models.UsageDelta, _ models.Quota) error {
+					require.Equal(t, int64(1), delta.Items)
+					require.Positive(t, delta.Bytes)
+					return nil
+				})
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+					Times(1).Return(primitive.NewObjectID(), nil)
+			},
+		},
+		{
+			name: "should_fail_quota_taken_by_a_concurrent_write",
+			data: dto.CreateBucketItemInputDTO{Key: "a", Data: "value"},
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), ownerID).
+					Times(1).Return(&models.Usage{UserID: ownerID}, nil)
+				usageRepo.EXPECT().FindBucketUsage(gomock.Any(), "12345").
+					Times(1).Return(&models.Usage{BucketUID: "12345", Items: 9}, nil)
+				usageRepo.EXPECT().IncrementUsage(gomock.Any(), ownerID, "12345", gomock.Any(), gomock.Any()).
+					Times(1).Return(models.ErrItemQuotaExceeded)
+			},
+			wantErr: models.ErrItemQuotaExceeded,
+		},
+		{
+			name: "should_release_the_usage_of_an_item_not_created",
+			data: dto.CreateBucketItemInputDTO{Key: "a", Data: "value"},
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), ownerID).
+					Times(1).Return(&models.Usage{UserID: ownerID}, nil)
+				usageRepo.EXPECT().FindBucketUsage(gomock.Any(), "12345").
+					Times(1).Return(&models.Usage{BucketUID: "12345"}, nil)
+				reserve := usageRepo.EXPECT().IncrementUsage(gomock.Any(), ownerID, "12345", gomock.Any(), gomock.Any()).
+					Times(1).Return(nil)
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+					Times(1).After(reserve).Return(primitive.NilObjectID, models.ErrBucketItemAlreadyExists)
+				usageRepo.EXPECT().IncrementUsage(gomock.Any(), ownerID, "12345", gomock.Any(), models.Quota{}).
+					Times(1).After(reserve).DoAndReturn(func(_ context.Context, _ primitive.ObjectID, _ string, delta models.UsageDelta, _ models.Quota) error {
+					require.Equal(t, int64(-1), delta.Items)
+					require.Negative(t, delta.Bytes)
+					return nil
+				})
+			},
+			wantErr: models.ErrBucketItemAlreadyExists,
+		},
+		{
+			name: "should_fail_item_quota_exceeded",
+			data: dto.CreateBucketItemInputDTO{Key: "a", Data: "value"},
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), ownerID).
+					Times(1).Return(&models.Usage{UserID: ownerID}, nil)
+				usageRepo.EXPECT().FindBucketUsage(gomock.Any(), "12345").
+					Times(1).Return(&models.Usage{BucketUID: "12345", Items: 10}, nil)
+			},
+			wantErr: models.ErrItemQuotaExceeded,
+		},
+		{
+			name: "should_fail_storage_quota_exceeded",
+			data: dto.CreateBucketItemInputDTO{Key: "a", Data: "value"},
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), ownerID).
+					Times(1).Return(&models.Usage{UserID: ownerID, Bytes: 1020}, nil)
+			},
+			wantErr: models.ErrStorageQuotaExceeded,
+		},
+		{
+			name: "should_fail_value_quota_exceeded",
+			data: dto.CreateBucketItemInputDTO{Key: "a", Data: "a value larger than the sixty four bytes allowed by the quota"},
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), ownerID).
+					Times(1).Return(&models.Usage{UserID: ownerID}, nil)
+			},
+			wantErr: models.ErrValueQuotaExceeded,
+		},
+		{
+			name: "should_create_bucket_item_with_lifted_quota",
+			data: dto.CreateBucketItemInputDTO{Key: "a", Data: "value"},
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), ownerID).
+					Times(1).Return(&models.Usage{UserID: ownerID, Bytes: 1020, Quota: &models.Quota{MaxStorageBytes: -1}}, nil)
+				usageRepo.EXPECT().FindBucketUsage(gomock.Any(), "12345").
+					Times(1).Return(&models.Usage{BucketUID: "12345"}, nil)
+				usageRepo.EXPECT().IncrementUsage(gomock.Any(), ownerID, "12345", gomock.Any(), models.Quota{MaxBuckets: 2, MaxItemsPerBucket: 10, MaxValueBytes: 64}).
+					Times(1).Return(nil)
+				bucketItemRepo.EXPECT().CreateBucketItem(gomock.Any(), gomock.Any()).
+					Times(1).Return(primitive.NewObjectID(), nil)
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+				Times(1).Return(&models.Bucket{ID: primitive.NewObjectID(), UID: "12345", UserID: ownerID}, nil)
+			bucketItemRepo.EXPECT().FindBucketItemByKeyName(gomock.Any(), "12345", tc.data.Key).
+				Times(1).Return(nil, models.ErrBucketItemNotFound)
+			tc.stubFn()
+			_, err := service.CreateBucketItem(context.Background(), tc.data, callerID, "12345")
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+		})
+	}
+}
+
+func TestBucketService_CreateBucket_Quota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	usageRepo := mocks.NewMockIUsageRepository(ctrl)
+	service := NewBucketService(provideQuotaConfig(), bucketRepo, nil, nil, nil, nil, usageRepo, nil, nil)
+	userID := primitive.NewObjectID()
+
+	usageRepo.EXPECT().FindUserUsage(gomock.Any(), userID).
+		Times(1).Return(&models.Usage{UserID: userID, Buckets: 1}, nil)
+	bucketRepo.EXPECT().CreateBucket(gomock.Any(), gomock.Any()).
+		Times(1).Return(primitive.NewObjectID(), nil)
+	usageRepo.EXPECT().IncrementUsage(gomock.Any(), userID, "", models.UsageDelta{Buckets: 1}, defaultQuota(provideQuotaConfig())).
+		Times(1).Return(nil)
+	_, err := service.CreateBucket(context.Background(), dto.CreateBucketInputDTO{Name: "first"}, userID)
+	require.Nil(t, err)
+
+	usageRepo.EXPECT().FindUserUsage(gomock.Any(), userID).
+		Times(1).Return(&models.Usage{UserID: userID, Buckets: 2}, nil)
+	_, err = service.CreateBucket(context.Background(), dto.CreateBucketInputDTO{Name: "second"}, userID)
+	require.ErrorIs(t, err, models.ErrBucketQuotaExceeded)
+}
+
+func TestBucketItemService_PurgeExpiredBucketItems_Usage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketItemRepo := mocks.NewMockIBucketItemRepository(ctrl)
+	usageRepo := mocks.NewMockIUsageRepository(ctrl)
+	service := NewBucketItemService(provideQuotaConfig(), bucketItemRepo, bucketRepo, nil, nil, usageRepo, nil)
+	ownerID := primitive.NewObjectID()
+	live := models.BucketItem{BucketUID: "12345", Key: "a", Type: models.BucketItemTypeBinary, Size: 40}
+	trashed := models.BucketItem{BucketUID: "67890", Key: "b", Type: models.BucketItemTypeBinary, Size: 10, DeletedAt: primitive.NewDateTimeFromTime(time.Now())}
+
+	bucketItemRepo.EXPECT().PurgeExpiredBucketItems(gomock.Any(), gomock.Any()).
+		Times(1).Return([]models.BucketItem{live, trashed}, nil)
+	bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+		Times(1).Return(&models.Bucket{UID: "12345", UserID: ownerID}, nil)
+	// the trashed item is already left out of the usage
+	usageRepo.EXPECT().IncrementUsage(gomock.Any(), ownerID, "12345", models.UsageDelta{Items: -1, Bytes: -40}, models.Quota{}).
+		Times(1).Return(nil)
+
+	count, err := service.PurgeExpiredBucketItems(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, int64(2), count)
+}
+
+func TestUsageService_GetUserUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	usageRepo := mocks.NewMockIUsageRepository(ctrl)
+	service := NewUsageService(provideQuotaConfig(), usageRepo, nil)
+	userID := primitive.NewObjectID()
+
+	usageRepo.EXPECT().FindUserUsage(gomock.Any(), userID).
+		Times(1).Return(&models.Usage{
+		UserID:  userID,
+		Buckets: 1,
+		Items:   3,
+		Bytes:   120,
+		Quota:   &models.Quota{MaxBuckets: 5, MaxStorageBytes: -1},
+	}, nil)
+	usageRepo.EXPECT().FindBucketUsages(gomock.Any(), userID).
+		Times(1).Return([]models.Usage{{UserID: userID, BucketUID: "12345", Items: 3, Bytes: 120}}, nil)
+
+	usage, err := service.GetUserUsage(context.Background(), userID.Hex())
+	require.Nil(t, err)
+	require.Equal(t, int64(120), usage.Bytes)
+	require.Len(t, usage.Usages, 1)
+	// the override replaces the defaults it sets and lifts the negative ones
+	require.Equal(t, models.Quota{
+		MaxBuckets:        5,
+		MaxItemsPerBucket: 10,
+		MaxValueBytes:     64,
+		MaxStorageBytes:   0,
+	}, usage.Quota)
+}
+
+func TestUsageService_UpdateUserQuota(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	usageRepo := mocks.NewMockIUsageRepository(ctrl)
+	userRepo := mocks.NewMockIUserRepository(ctrl)
+	service := NewUsageService(provideQuotaConfig(), usageRepo, userRepo)
+	user := &models.User{ID: primitive.NewObjectID()}
+
+	userRepo.EXPECT().FindUserById(gomock.Any(), user.ID.Hex()).Times(2).Return(user, nil)
+	usageRepo.EXPECT().UpdateUserQuota(gomock.Any(), user.ID, &models.Quota{MaxBuckets: 50}).Times(1).Return(nil)
+	err := service.UpdateUserQuota(context.Background(), user.ID.Hex(), dto.UpdateUserQuotaInputDTO{MaxBuckets: 50})
+	require.Nil(t, err)
+
+	// an empty override is removed
+	usageRepo.EXPECT().UpdateUserQuota(gomock.Any(), user.ID, nil).Times(1).Return(nil)
+	err = service.UpdateUserQuota(context.Background(), user.ID.Hex(), dto.UpdateUserQuotaInputDTO{})
+	require.Nil(t, err)
+}
+
+func TestUsageService_MoveBucketUsage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	usageRepo := mocks.NewMockIUsageRepository(ctrl)
+	service := NewUsageService(provideQuotaConfig(), usageRepo, nil)
+	from := primitive.NewObjectID()
+	to := primitive.NewObjectID()
+	moved := models.UsageDelta{Buckets: 1, Items: 3, Bytes: 300}
+
+	tt := []struct {
+		name    string
+		stubFn  func()
+		wantErr error
+	}{
+		{
+			name: "should_move_the_usage_to_the_new_owner",
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), to).
+					Times(1).Return(&models.Usage{UserID: to, Buckets: 1}, nil)
+				usageRepo.EXPECT().IncrementUsage(gomock.Any(), to, "", moved, defaultQuota(provideQuotaConfig())).
+					Times(1).Return(nil)
+				usageRepo.EXPECT().IncrementUsage(gomock.Any(), from, "", moved.Negate(), models.Quota{}).
+					Times(1).Return(nil)
+				usageRepo.EXPECT().IncrementUsage(gomock.Any(), to, "12345", models.UsageDelta{}, models.Quota{}).
+					Times(1).Return(nil)
+			},
+		},
+		{
+			name: "should_fail_bucket_quota_of_the_new_owner_exceeded",
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), to).
+					Times(1).Return(&models.Usage{UserID: to, Buckets: 2}, nil)
+			},
+			wantErr: models.ErrBucketQuotaExceeded,
+		},
+		{
+			name: "should_fail_storage_quota_of_the_new_owner_taken_concurrently",
+			stubFn: func() {
+				usageRepo.EXPECT().FindUserUsage(gomock.Any(), to).
+					Times(1).Return(&models.Usage{UserID: to}, nil)
+				usageRepo.EXPECT().IncrementUsage(gomock.Any(), to, "", moved, gomock.Any()).
+					Times(1).Return(models.ErrStorageQuotaExceeded)
+			},
+			wantErr: models.ErrStorageQuotaExceeded,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			usageRepo.EXPECT().FindBucketUsage(gomock.Any(), "12345").
+				Times(1).Return(&models.Usage{BucketUID: "12345", Items: 3, Bytes: 300}, nil)
+			tc.stubFn()
+			err := service.MoveBucketUsage(context.Background(), "12345", from, to)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.Nil(t, err)
+		})
+	}
+}
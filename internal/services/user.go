@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"keeper/internal/auth/jwt"
@@ -8,6 +10,7 @@ import (
 	"keeper/internal/dto"
 	"keeper/internal/models"
 	"keeper/internal/pkg/mailer"
+	"keeper/internal/pkg/tracing"
 	"keeper/internal/queue"
 	"keeper/internal/queue/tasks"
 	"keeper/internal/repository"
@@ -20,48 +23,80 @@ import (
 )
 
 type UserService struct {
-	userRepo repository.IUserRepository
-	jwtSvc   jwt.IJwtService
-	cfg      *config.Config
-	queue    *queue.RedisQueue
+	userRepo           repository.IUserRepository
+	bucketRepo         repository.IBucketRepository
+	bucketItemRepo     repository.IBucketItemRepository
+	bucketSnapshotRepo repository.IBucketSnapshotRepository
+	apiKeyRepo         repository.IAPIKeyRepository
+	twoFactorRepo      repository.ITwoFactorRepository
+	sessionRepo        repository.ISessionRepository
+	usageRepo          repository.IUsageRepository
+	loginAttemptRepo   repository.ILoginAttemptRepository
+	webhookRepo        repository.IWebhookRepository
+	jwtSvc             jwt.IJwtService
+	cfg                *config.Config
+	queue              *queue.RedisQueue
 }
 
 type IUserService interface {
-	Register(data dto.CreateUserInputDTO) error
-	FindUserByID(id string) (*models.User, error)
-	FindAllUsers() ([]models.User, error)
-	UpdateUser(id string, data dto.UpdateUserInputDTO) error
-	UpdateUserPassword(id string, data dto.UpdateUserPasswordInputDTO) error
-	DeleteUser(id string) error
-	VerifyEmail(data dto.VerifyEmailInputDTO) error
+	Register(ctx context.Context, data dto.CreateUserInputDTO) error
+	FindUserByID(ctx context.Context, id string) (*models.User, error)
+	FindAllUsers(ctx context.Context) ([]models.User, error)
+	UpdateUser(ctx context.Context, id string, data dto.UpdateUserInputDTO) error
+	UpdateUserPassword(ctx context.Context, id string, data dto.UpdateUserPasswordInputDTO, currentSessionID string) error
+	DeleteUser(ctx context.Context, id string) (*dto.UserDeletionStatusOutputDTO, error)
+	GetUserDeletionStatus(ctx context.Context, id string) (*dto.UserDeletionStatusOutputDTO, error)
+	CancelUserDeletion(ctx context.Context, id string) error
+	DeleteUserData(ctx context.Context, id string, onProgress func(progress models.UserDeletionProgress)) error
+	VerifyEmail(ctx context.Context, data dto.VerifyEmailInputDTO) error
 }
 
-func NewUserService(cfg *config.Config, userRepo repository.IUserRepository) IUserService {
-	jwtSvc := jwt.NewJwtService(cfg, userRepo)
+// user deletion tasks are enqueued with a fixed ID so that a user has at most one pending deletion
+const userDeletionQueue = "default"
+
+func userDeletionTaskID(userID string) string {
+	return fmt.Sprintf("user-deletion:%s", userID)
+}
+
+// NewUserService builds the user service, every repository is required
+func NewUserService(cfg *config.Config, userRepo repository.IUserRepository, bucketRepo repository.IBucketRepository, bucketItemRepo repository.IBucketItemRepository, bucketSnapshotRepo repository.IBucketSnapshotRepository, apiKeyRepo repository.IAPIKeyRepository, twoFactorRepo repository.ITwoFactorRepository, sessionRepo repository.ISessionRepository, usageRepo repository.IUsageRepository, loginAttemptRepo repository.ILoginAttemptRepository, webhookRepo repository.IWebhookRepository) IUserService {
+	jwtSvc := jwt.NewJwtService(cfg, userRepo, nil)
 	queue := queue.NewRedisQueue(cfg)
 	return &UserService{
-		userRepo: userRepo,
-		cfg:      cfg,
-		jwtSvc:   jwtSvc,
-		queue:    queue,
+		userRepo:           userRepo,
+		bucketRepo:         bucketRepo,
+		bucketItemRepo:     bucketItemRepo,
+		bucketSnapshotRepo: bucketSnapshotRepo,
+		apiKeyRepo:         apiKeyRepo,
+		twoFactorRepo:      twoFactorRepo,
+		sessionRepo:        sessionRepo,
+		usageRepo:          usageRepo,
+		loginAttemptRepo:   loginAttemptRepo,
+		webhookRepo:        webhookRepo,
+		cfg:                cfg,
+		jwtSvc:             jwtSvc,
+		queue:              queue,
 	}
 }
 
 // Create a new user
-func (s *UserService) Register(data dto.CreateUserInputDTO) error {
+func (s *UserService) Register(ctx context.Context, data dto.CreateUserInputDTO) error {
+	ctx, span := tracing.Start(ctx, "UserService.Register")
+	defer span.End()
 	if data.Email == "" {
-		return errors.New("email must not be empty")
+		return ErrEmailIsEmpty
 	}
 	if data.Password == "" {
-		return errors.New("password must not be empty")
+		return ErrPasswordIsEmpty
 	}
-	existingUser, err := s.userRepo.FindUserByEmail(data.Email)
+	existingUser, err := s.userRepo.FindUserByEmail(ctx, data.Email)
 	// check if the user already exists
 	if err != nil && !errors.Is(err, models.ErrUserNotFound) {
 		return err
 	}
+	// concurrent registrations are settled by the unique index on the emails
 	if existingUser != nil {
-		return models.ErrUserAlreadyExists
+		return models.NewConflictError(models.ErrUserAlreadyExists, "email", data.Email)
 	}
 	newUser := &models.User{
 		Firstname: data.Firstname,
@@ -79,7 +114,7 @@ func (s *UserService) Register(data dto.CreateUserInputDTO) error {
 	newUser.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
 	newUser.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 	// save the user in the database
-	if err := s.userRepo.CreateUser(newUser); err != nil {
+	if err := s.userRepo.CreateUser(ctx, newUser); err != nil {
 		return err
 	}
 	// send the email verification mail
@@ -90,7 +125,7 @@ func (s *UserService) Register(data dto.CreateUserInputDTO) error {
 			"id":    newUser.ID.Hex(),
 		})
 		if err != nil {
-			logrus.WithError(err).Error(err.Error())
+			logrus.WithContext(ctx).WithError(err).Error(err.Error())
 			return fmt.Errorf("error generating email verification token: %s", err.Error())
 		}
 		emailVerificationLink := fmt.Sprintf("%s/verify-email?token=%s", s.cfg.ClientURL, emailVerificationToken)
@@ -110,10 +145,10 @@ func (s *UserService) Register(data dto.CreateUserInputDTO) error {
 			)
 
 			if err != nil {
-				logrus.WithError(err).Error(err.Error())
+				logrus.WithContext(ctx).WithError(err).Error(err.Error())
 			}
 
-			s.queue.Add(sendVerificationMailTask, asynq.Queue("critical"))
+			s.queue.Add(ctx, sendVerificationMailTask, asynq.Queue("critical"))
 			return nil
 		} else {
 			mailSvc := mailer.NewMailer(s.cfg)
@@ -130,9 +165,9 @@ func (s *UserService) Register(data dto.CreateUserInputDTO) error {
 			)
 
 			if err != nil {
-				logrus.WithError(err).Error(err.Error())
+				logrus.WithContext(ctx).WithError(err).Error(err.Error())
 			}
-			logrus.Info("Successfully sent email verification link")
+			logrus.WithContext(ctx).Info("Successfully sent email verification link")
 			return nil
 		}
 
@@ -142,8 +177,10 @@ func (s *UserService) Register(data dto.CreateUserInputDTO) error {
 
 // Verify a user's email
 // Accepts the email verification token
-func (s *UserService) VerifyEmail(data dto.VerifyEmailInputDTO) error {
-	claims, err := s.jwtSvc.DecodeToken(data.Token, s.cfg.EmailVerificationTokenSecretKey)
+func (s *UserService) VerifyEmail(ctx context.Context, data dto.VerifyEmailInputDTO) error {
+	ctx, span := tracing.Start(ctx, "UserService.VerifyEmail")
+	defer span.End()
+	claims, err := s.jwtSvc.DecodeToken(data.Token, jwt.TokenTypeEmailVerification)
 	if err != nil {
 		return nil
 	}
@@ -161,8 +198,8 @@ func (s *UserService) VerifyEmail(data dto.VerifyEmailInputDTO) error {
 	user.ID = ID
 	user.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 	// update the user's email verification status
-	if err := s.userRepo.UpdateUser(user); err != nil {
-		logrus.WithError(err).Error(err.Error())
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		logrus.WithContext(ctx).WithError(err).Error(err.Error())
 		return fmt.Errorf("error verifying email: %s", err.Error())
 	}
 
@@ -171,8 +208,10 @@ func (s *UserService) VerifyEmail(data dto.VerifyEmailInputDTO) error {
 
 // Returns the user data for the passed ID
 // Accepts the user ID
-func (s *UserService) FindUserByID(id string) (*models.User, error) {
-	user, err := s.userRepo.FindUserById(id)
+func (s *UserService) FindUserByID(ctx context.Context, id string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.FindUserByID")
+	defer span.End()
+	user, err := s.userRepo.FindUserById(ctx, id)
 	if err != nil {
 		return &models.User{}, err
 	}
@@ -180,8 +219,10 @@ func (s *UserService) FindUserByID(id string) (*models.User, error) {
 }
 
 // Returns all the users in the database
-func (s *UserService) FindAllUsers() ([]models.User, error) {
-	users, err := s.userRepo.FindAllUsers()
+func (s *UserService) FindAllUsers(ctx context.Context) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.FindAllUsers")
+	defer span.End()
+	users, err := s.userRepo.FindAllUsers(ctx)
 	if err != nil {
 		return []models.User{}, err
 	}
@@ -190,7 +231,9 @@ func (s *UserService) FindAllUsers() ([]models.User, error) {
 
 // Update a user's details
 // Accepts the user ID and the update user data
-func (s *UserService) UpdateUser(id string, data dto.UpdateUserInputDTO) error {
+func (s *UserService) UpdateUser(ctx context.Context, id string, data dto.UpdateUserInputDTO) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUser")
+	defer span.End()
 	user := &models.User{
 		Firstname: data.Firstname,
 		Lastname:  data.Lastname,
@@ -202,15 +245,17 @@ func (s *UserService) UpdateUser(id string, data dto.UpdateUserInputDTO) error {
 	}
 	user.ID = ID
 	user.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-	if err := s.userRepo.UpdateUser(user); err != nil {
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Update a user's password
-// Accepts the user ID and the new password
-func (s *UserService) UpdateUserPassword(id string, data dto.UpdateUserPasswordInputDTO) error {
+// Update a user's password, the other sessions of the user are revoked
+// Accepts the user ID, the new password and the ID of the session changing it, empty if there is none
+func (s *UserService) UpdateUserPassword(ctx context.Context, id string, data dto.UpdateUserPasswordInputDTO, currentSessionID string) error {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateUserPassword")
+	defer span.End()
 	// validation
 	if utils.IsStringEmpty(id) {
 		return ErrUserIDIsEmpty
@@ -232,17 +277,224 @@ func (s *UserService) UpdateUserPassword(id string, data dto.UpdateUserPasswordI
 	}
 	user.ID = ID
 	user.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
-	if err := s.userRepo.UpdateUser(user); err != nil {
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	// the sessions opened with the previous password are closed, the one changing it is kept
+	if utils.IsStringEmpty(currentSessionID) {
+		_, err := s.sessionRepo.RevokeUserSessions(ctx, ID, models.SessionRevokedPasswordChange)
 		return err
 	}
+	sessions, err := s.sessionRepo.FindActiveSessions(ctx, ID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID.Hex() == currentSessionID {
+			continue
+		}
+		if err := s.sessionRepo.RevokeSession(ctx, session.ID, models.SessionRevokedPasswordChange); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// Delete a user from the database
+// Delete a user's account along with the user's buckets, items and API keys
+// with workers enabled, the deletion runs as a background task after the configured grace period
+// Accepts the user ID
+// Returns the status of the deletion
+func (s *UserService) DeleteUser(ctx context.Context, id string) (*dto.UserDeletionStatusOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser")
+	defer span.End()
+	if utils.IsStringEmpty(id) {
+		return nil, ErrUserIDIsEmpty
+	}
+	if !s.cfg.WithWorkers {
+		if err := s.DeleteUserData(ctx, id, nil); err != nil {
+			return nil, err
+		}
+		return &dto.UserDeletionStatusOutputDTO{State: asynq.TaskStateCompleted.String()}, nil
+	}
+	if err := s.enqueueUserDeletion(ctx, id); err != nil {
+		if !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return nil, models.ErrEnqueuingTask
+		}
+		// a failed deletion is replaced, any other existing deletion is reported as is
+		status, err := s.GetUserDeletionStatus(ctx, id)
+		if err != nil || status.State != asynq.TaskStateArchived.String() {
+			return status, err
+		}
+		if err := s.queue.Inspector().DeleteTask(userDeletionQueue, userDeletionTaskID(id)); err != nil {
+			logrus.WithContext(ctx).WithError(err).Errorf("error removing failed user deletion: %s", id)
+			return nil, models.ErrEnqueuingTask
+		}
+		if err := s.enqueueUserDeletion(ctx, id); err != nil {
+			return nil, models.ErrEnqueuingTask
+		}
+	}
+	return s.GetUserDeletionStatus(ctx, id)
+}
+
+// Enqueues the deletion task of a user
+func (s *UserService) enqueueUserDeletion(ctx context.Context, id string) error {
+	task, err := tasks.NewDeleteUserTask(id)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating user deletion task")
+		return err
+	}
+	gracePeriod := time.Duration(s.cfg.UserDeletionGracePeriodHours) * time.Hour
+	_, err = s.queue.Enqueue(ctx, task,
+		asynq.Queue(userDeletionQueue),
+		asynq.TaskID(userDeletionTaskID(id)),
+		asynq.ProcessIn(gracePeriod),
+		// completed deletions are kept for a while so that their progress can still be inspected
+		asynq.Retention(24*time.Hour),
+	)
+	return err
+}
+
+// Returns the status of a user's account deletion
+// Accepts the user ID
+func (s *UserService) GetUserDeletionStatus(ctx context.Context, id string) (*dto.UserDeletionStatusOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUserDeletionStatus")
+	defer span.End()
+	if utils.IsStringEmpty(id) {
+		return nil, ErrUserIDIsEmpty
+	}
+	info, err := s.queue.Inspector().GetTaskInfo(userDeletionQueue, userDeletionTaskID(id))
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			return nil, models.ErrUserDeletionNotScheduled
+		}
+		logrus.WithContext(ctx).WithError(err).Errorf("error fetching user deletion: %s", id)
+		return nil, err
+	}
+	status := &dto.UserDeletionStatusOutputDTO{
+		State:       info.State.String(),
+		Cancellable: info.State != asynq.TaskStateActive && info.State != asynq.TaskStateCompleted,
+	}
+	if info.State == asynq.TaskStateScheduled || info.State == asynq.TaskStateRetry {
+		status.ScheduledAt = &info.NextProcessAt
+	}
+	if len(info.Result) > 0 {
+		progress := &models.UserDeletionProgress{}
+		if err := json.Unmarshal(info.Result, progress); err == nil {
+			status.Progress = progress
+		}
+	}
+	return status, nil
+}
+
+// Cancel a user's account deletion that has not started yet
 // Accepts the user ID
-func (s *UserService) DeleteUser(id string) error {
-	if err := s.userRepo.DeleteUser(id); err != nil {
+func (s *UserService) CancelUserDeletion(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "UserService.CancelUserDeletion")
+	defer span.End()
+	status, err := s.GetUserDeletionStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !status.Cancellable {
+		return models.ErrUserDeletionInProgress
+	}
+	if err := s.queue.Inspector().DeleteTask(userDeletionQueue, userDeletionTaskID(id)); err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return models.ErrUserDeletionNotScheduled
+		}
+		logrus.WithContext(ctx).WithError(err).Errorf("error cancelling user deletion: %s", id)
+		return models.ErrUserDeletionInProgress
+	}
+	return nil
+}
+
+// Delete a user along with everything the user owns
+// the user's API keys, sessions, buckets (including the trashed ones, their snapshots and webhooks), usage, login attempts and two-factor state are deleted
+// while the items the user wrote to other users' buckets are kept without an author
+// every step can safely be run again, so a failed deletion can be retried
+// Accepts the user ID and an optional callback that receives the progress of the deletion
+func (s *UserService) DeleteUserData(ctx context.Context, id string, onProgress func(progress models.UserDeletionProgress)) error {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUserData")
+	defer span.End()
+	if utils.IsStringEmpty(id) {
+		return ErrUserIDIsEmpty
+	}
+	userID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return models.ErrInvalidObjectID
+	}
+	progress := models.UserDeletionProgress{}
+	report := func(stage string) {
+		progress.Stage = stage
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+
+	// the API keys and the sessions go first so that nothing can be read or written during the deletion
+	report(models.UserDeletionStageDeletingAPIKeys)
+	apiKeysDeleted, err := s.apiKeyRepo.DeleteUserAPIKeys(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.sessionRepo.DeleteUserSessions(ctx, userID); err != nil {
+		return err
+	}
+	progress.APIKeysDeleted = apiKeysDeleted
+
+	bucketUIDs, err := s.bucketRepo.FindAllBucketUIDsByUserID(ctx, id)
+	if err != nil {
+		return err
+	}
+	progress.BucketsTotal = len(bucketUIDs)
+	report(models.UserDeletionStageDeletingBuckets)
+	for _, bucketUID := range bucketUIDs {
+		if err := s.bucketSnapshotRepo.DeleteBucketSnapshots(ctx, bucketUID); err != nil {
+			return err
+		}
+		if err := s.webhookRepo.DeleteBucketWebhooks(ctx, bucketUID); err != nil {
+			return err
+		}
+		// the items are deleted first so that no orphaned items are left behind on failure
+		if err := s.bucketItemRepo.DeleteBucketItems(ctx, bucketUID); err != nil {
+			return err
+		}
+		if err := s.bucketRepo.DeleteBucketByUID(ctx, bucketUID); err != nil {
+			return err
+		}
+		progress.BucketsDeleted++
+		report(models.UserDeletionStageDeletingBuckets)
+	}
+
+	report(models.UserDeletionStageAnonymizingItems)
+	itemsAnonymized, err := s.bucketItemRepo.AnonymizeUserBucketItems(ctx, id)
+	if err != nil {
+		return err
+	}
+	progress.ItemsAnonymized = itemsAnonymized
+
+	report(models.UserDeletionStageDeletingUser)
+	// the usage of the buckets is deleted along with the usage of the user
+	if err := s.usageRepo.DeleteUserUsage(ctx, userID); err != nil {
+		return err
+	}
+	// the failed logins are counted by email, a user that is already gone has none left
+	user, err := s.userRepo.FindUserById(ctx, id)
+	if err != nil && !errors.Is(err, models.ErrUserNotFound) {
+		return err
+	}
+	if user != nil {
+		if err := s.loginAttemptRepo.DeleteLoginAttempts(ctx, models.LoginAttemptEmailID(user.Email)); err != nil {
+			return err
+		}
+	}
+	// the TOTP secret and the recovery codes are deleted before the user they protect
+	if err := s.twoFactorRepo.DeleteTwoFactor(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.userRepo.DeleteUser(ctx, id); err != nil {
 		return err
 	}
+	report(models.UserDeletionStageCompleted)
 	return nil
 }
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"keeper/internal/config"
 	"keeper/internal/dto"
@@ -14,19 +15,49 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// mocks of the repositories of the user service
+type userRepoMocks struct {
+	userRepo           *mocks.MockIUserRepository
+	bucketRepo         *mocks.MockIBucketRepository
+	bucketItemRepo     *mocks.MockIBucketItemRepository
+	bucketSnapshotRepo *mocks.MockIBucketSnapshotRepository
+	apiKeyRepo         *mocks.MockIAPIKeyRepository
+	twoFactorRepo      *mocks.MockITwoFactorRepository
+	sessionRepo        *mocks.MockISessionRepository
+	usageRepo          *mocks.MockIUsageRepository
+	loginAttemptRepo   *mocks.MockILoginAttemptRepository
+	webhookRepo        *mocks.MockIWebhookRepository
+}
+
+func newUserRepoMocks(ctrl *gomock.Controller) *userRepoMocks {
+	return &userRepoMocks{
+		userRepo:           mocks.NewMockIUserRepository(ctrl),
+		bucketRepo:         mocks.NewMockIBucketRepository(ctrl),
+		bucketItemRepo:     mocks.NewMockIBucketItemRepository(ctrl),
+		bucketSnapshotRepo: mocks.NewMockIBucketSnapshotRepository(ctrl),
+		apiKeyRepo:         mocks.NewMockIAPIKeyRepository(ctrl),
+		twoFactorRepo:      mocks.NewMockITwoFactorRepository(ctrl),
+		sessionRepo:        mocks.NewMockISessionRepository(ctrl),
+		usageRepo:          mocks.NewMockIUsageRepository(ctrl),
+		loginAttemptRepo:   mocks.NewMockILoginAttemptRepository(ctrl),
+		webhookRepo:        mocks.NewMockIWebhookRepository(ctrl),
+	}
+}
+
 // provide user service
-func provideUserService(mockUserRepo *mocks.MockIUserRepository) IUserService {
+func provideUserService(repos *userRepoMocks) IUserService {
 	cfg := &config.Config{
 		Env: "test",
 	}
-	return NewUserService(cfg, mockUserRepo)
+	return NewUserService(cfg, repos.userRepo, repos.bucketRepo, repos.bucketItemRepo, repos.bucketSnapshotRepo, repos.apiKeyRepo, repos.twoFactorRepo, repos.sessionRepo, repos.usageRepo, repos.loginAttemptRepo, repos.webhookRepo)
 }
 
 func TestUserService_Register(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	userRepo := mocks.NewMockIUserRepository(ctrl)
+	repos := newUserRepoMocks(ctrl)
+	userRepo := repos.userRepo
 
 	input := dto.CreateUserInputDTO{
 		Firstname: "bola",
@@ -53,9 +84,9 @@ func TestUserService_Register(t *testing.T) {
 				data: input,
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().CreateUser(gomock.Any()).
+				userRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
-				userRepo.EXPECT().FindUserByEmail(gomock.Any()).
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, models.ErrUserNotFound)
 			},
 		},
@@ -65,7 +96,7 @@ func TestUserService_Register(t *testing.T) {
 				data: input,
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().FindUserByEmail(gomock.Any()).
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), gomock.Any()).
 					Times(1).Return(&models.User{
 					ID: primitive.NewObjectID(),
 				}, nil)
@@ -79,9 +110,9 @@ func TestUserService_Register(t *testing.T) {
 				data: input,
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().CreateUser(gomock.Any()).
+				userRepo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to register new user"))
-				userRepo.EXPECT().FindUserByEmail(gomock.Any()).
+				userRepo.EXPECT().FindUserByEmail(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, models.ErrUserNotFound)
 			},
 			wantErr:    true,
@@ -98,7 +129,7 @@ func TestUserService_Register(t *testing.T) {
 			},
 			stubFn:     nil,
 			wantErr:    true,
-			wantErrMsg: "email must not be empty",
+			wantErrMsg: ErrEmailIsEmpty.Error(),
 		},
 		{
 			name: "should_fail_register_new_user_empty_password",
@@ -111,7 +142,7 @@ func TestUserService_Register(t *testing.T) {
 			},
 			stubFn:     nil,
 			wantErr:    true,
-			wantErrMsg: "password must not be empty",
+			wantErrMsg: ErrPasswordIsEmpty.Error(),
 		},
 	}
 
@@ -121,8 +152,8 @@ func TestUserService_Register(t *testing.T) {
 				tc.stubFn(userRepo)
 			}
 
-			userSvc := provideUserService(userRepo)
-			err := userSvc.Register(tc.args.data)
+			userSvc := provideUserService(repos)
+			err := userSvc.Register(context.Background(), tc.args.data)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -138,7 +169,8 @@ func TestUserService_FindUserByID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	userRepo := mocks.NewMockIUserRepository(ctrl)
+	repos := newUserRepoMocks(ctrl)
+	userRepo := repos.userRepo
 
 	type args struct {
 		id string
@@ -166,7 +198,7 @@ func TestUserService_FindUserByID(t *testing.T) {
 				id: "12345",
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().FindUserById(gomock.Any()).
+				userRepo.EXPECT().FindUserById(gomock.Any(), gomock.Any()).
 					Times(1).Return(testUser, nil)
 			},
 			wantErr: false,
@@ -177,7 +209,7 @@ func TestUserService_FindUserByID(t *testing.T) {
 				id: "12345",
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().FindUserById(gomock.Any()).
+				userRepo.EXPECT().FindUserById(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil, errors.New("failed to find user by id"))
 			},
 			wantErr:    true,
@@ -191,8 +223,8 @@ func TestUserService_FindUserByID(t *testing.T) {
 				tc.stubFn(userRepo)
 			}
 
-			userSvc := provideUserService(userRepo)
-			user, err := userSvc.FindUserByID(tc.args.id)
+			userSvc := provideUserService(repos)
+			user, err := userSvc.FindUserByID(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -212,7 +244,8 @@ func TestUserService_FindAllUsers(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	userRepo := mocks.NewMockIUserRepository(ctrl)
+	repos := newUserRepoMocks(ctrl)
+	userRepo := repos.userRepo
 
 	testUsers := []models.User{
 		{
@@ -233,7 +266,7 @@ func TestUserService_FindAllUsers(t *testing.T) {
 		{
 			name: "should_successfully_find_all_users",
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().FindAllUsers().
+				userRepo.EXPECT().FindAllUsers(gomock.Any()).
 					Times(1).Return(testUsers, nil)
 			},
 			wantErr: false,
@@ -241,7 +274,7 @@ func TestUserService_FindAllUsers(t *testing.T) {
 		{
 			name: "should_fail_find_all_users",
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().FindAllUsers().
+				userRepo.EXPECT().FindAllUsers(gomock.Any()).
 					Times(1).Return([]models.User{}, errors.New("failed to find all users"))
 			},
 			wantErr:    true,
@@ -255,8 +288,8 @@ func TestUserService_FindAllUsers(t *testing.T) {
 				tc.stubFn(userRepo)
 			}
 
-			userSvc := provideUserService(userRepo)
-			users, err := userSvc.FindAllUsers()
+			userSvc := provideUserService(repos)
+			users, err := userSvc.FindAllUsers(context.Background())
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -274,7 +307,8 @@ func TestUserService_UpdateUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	userRepo := mocks.NewMockIUserRepository(ctrl)
+	repos := newUserRepoMocks(ctrl)
+	userRepo := repos.userRepo
 
 	type args struct {
 		id   string
@@ -298,7 +332,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 				},
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().UpdateUser(gomock.Any()).
+				userRepo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).
 					Times(1).Return(nil)
 			},
 			wantErr: false,
@@ -313,7 +347,7 @@ func TestUserService_UpdateUser(t *testing.T) {
 				},
 			},
 			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().UpdateUser(gomock.Any()).
+				userRepo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).
 					Times(1).Return(errors.New("failed to update user"))
 			},
 			wantErr:    true,
@@ -340,8 +374,8 @@ func TestUserService_UpdateUser(t *testing.T) {
 				tc.stubFn(userRepo)
 			}
 
-			userSvc := provideUserService(userRepo)
-			err := userSvc.UpdateUser(tc.args.id, tc.args.data)
+			userSvc := provideUserService(repos)
+			err := userSvc.UpdateUser(context.Background(), tc.args.id, tc.args.data)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -354,14 +388,105 @@ func TestUserService_UpdateUser(t *testing.T) {
 }
 
 func TestUserService_UpdateUserPassword(t *testing.T) {
-	require.Nil(t, nil)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repos := newUserRepoMocks(ctrl)
+	userRepo, sessionRepo := repos.userRepo, repos.sessionRepo
+
+	userID := primitive.NewObjectID()
+	current := models.Session{ID: primitive.NewObjectID(), UserID: userID}
+	other := models.Session{ID: primitive.NewObjectID(), UserID: userID}
+
+	type args struct {
+		id               string
+		data             dto.UpdateUserPasswordInputDTO
+		currentSessionID string
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(userRepo *mocks.MockIUserRepository, sessionRepo *mocks.MockISessionRepository)
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_update_password_and_revoke_the_other_sessions",
+			args: args{id: userID.Hex(), data: dto.UpdateUserPasswordInputDTO{Password: "n3w-p4ssword"}, currentSessionID: current.ID.Hex()},
+			stubFn: func(userRepo *mocks.MockIUserRepository, sessionRepo *mocks.MockISessionRepository) {
+				gomock.InOrder(
+					userRepo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).
+						Times(1).Return(nil),
+					sessionRepo.EXPECT().FindActiveSessions(gomock.Any(), userID).
+						Times(1).Return([]models.Session{current, other}, nil),
+					sessionRepo.EXPECT().RevokeSession(gomock.Any(), other.ID, models.SessionRevokedPasswordChange).
+						Times(1).Return(nil),
+				)
+			},
+			wantErr: false,
+		},
+		{
+			name: "should_successfully_update_password_and_revoke_every_session_without_a_current_one",
+			args: args{id: userID.Hex(), data: dto.UpdateUserPasswordInputDTO{Password: "n3w-p4ssword"}},
+			stubFn: func(userRepo *mocks.MockIUserRepository, sessionRepo *mocks.MockISessionRepository) {
+				gomock.InOrder(
+					userRepo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).
+						Times(1).Return(nil),
+					sessionRepo.EXPECT().RevokeUserSessions(gomock.Any(), userID, models.SessionRevokedPasswordChange).
+						Times(1).Return(int64(2), nil),
+				)
+			},
+			wantErr: false,
+		},
+		{
+			name:       "should_fail_update_password_empty_password",
+			args:       args{id: userID.Hex()},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrPasswordIsEmpty.Error(),
+		},
+		{
+			name: "should_fail_update_password_when_the_sessions_cannot_be_revoked",
+			args: args{id: userID.Hex(), data: dto.UpdateUserPasswordInputDTO{Password: "n3w-p4ssword"}, currentSessionID: current.ID.Hex()},
+			stubFn: func(userRepo *mocks.MockIUserRepository, sessionRepo *mocks.MockISessionRepository) {
+				userRepo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).Return(nil)
+				sessionRepo.EXPECT().FindActiveSessions(gomock.Any(), userID).
+					Times(1).Return(nil, models.ErrFindingSessions)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrFindingSessions.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(userRepo, sessionRepo)
+			}
+
+			userSvc := provideUserService(repos)
+			err := userSvc.UpdateUserPassword(context.Background(), tc.args.id, tc.args.data, tc.args.currentSessionID)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, tc.wantErrMsg, err.Error())
+				return
+			}
+
+			require.Nil(t, err)
+		})
+	}
 }
 
 func TestUserService_DeleteUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	userRepo := mocks.NewMockIUserRepository(ctrl)
+	repos := newUserRepoMocks(ctrl)
+
+	userID := "62fa734bfc1cdb7f06a3bf6f"
+	userObjectID, _ := primitive.ObjectIDFromHex(userID)
 
 	type args struct {
 		id string
@@ -370,39 +495,116 @@ func TestUserService_DeleteUser(t *testing.T) {
 	tt := []struct {
 		name       string
 		args       args
-		stubFn     func(userRepo *mocks.MockIUserRepository)
+		stubFn     func(repos *userRepoMocks)
 		wantErr    bool
 		wantErrMsg string
 	}{
 		{
-			name: "should_successfully_delete_user",
-			args: args{id: "62fa734bfc1cdb7f06a3bf6f"},
-			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().DeleteUser(gomock.Any()).
-					Times(1).Return(nil)
+			name: "should_successfully_delete_user_and_owned_data",
+			args: args{id: userID},
+			stubFn: func(repos *userRepoMocks) {
+				gomock.InOrder(
+					repos.apiKeyRepo.EXPECT().DeleteUserAPIKeys(gomock.Any(), userID).
+						Times(1).Return(int64(2), nil),
+					repos.sessionRepo.EXPECT().DeleteUserSessions(gomock.Any(), userObjectID).
+						Times(1).Return(int64(1), nil),
+					repos.bucketRepo.EXPECT().FindAllBucketUIDsByUserID(gomock.Any(), userID).
+						Times(1).Return([]string{"bucket-1", "bucket-2"}, nil),
+					repos.bucketSnapshotRepo.EXPECT().DeleteBucketSnapshots(gomock.Any(), "bucket-1").
+						Times(1).Return(nil),
+					repos.webhookRepo.EXPECT().DeleteBucketWebhooks(gomock.Any(), "bucket-1").
+						Times(1).Return(nil),
+					repos.bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), "bucket-1").
+						Times(1).Return(nil),
+					repos.bucketRepo.EXPECT().DeleteBucketByUID(gomock.Any(), "bucket-1").
+						Times(1).Return(nil),
+					repos.bucketSnapshotRepo.EXPECT().DeleteBucketSnapshots(gomock.Any(), "bucket-2").
+						Times(1).Return(nil),
+					repos.webhookRepo.EXPECT().DeleteBucketWebhooks(gomock.Any(), "bucket-2").
+						Times(1).Return(nil),
+					repos.bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), "bucket-2").
+						Times(1).Return(nil),
+					repos.bucketRepo.EXPECT().DeleteBucketByUID(gomock.Any(), "bucket-2").
+						Times(1).Return(nil),
+					repos.bucketItemRepo.EXPECT().AnonymizeUserBucketItems(gomock.Any(), userID).
+						Times(1).Return(int64(3), nil),
+					repos.usageRepo.EXPECT().DeleteUserUsage(gomock.Any(), userObjectID).
+						Times(1).Return(nil),
+					repos.userRepo.EXPECT().FindUserById(gomock.Any(), userID).
+						Times(1).Return(&models.User{ID: userObjectID, Email: "user@example.com"}, nil),
+					repos.loginAttemptRepo.EXPECT().DeleteLoginAttempts(gomock.Any(), "email:user@example.com").
+						Times(1).Return(nil),
+					repos.twoFactorRepo.EXPECT().DeleteTwoFactor(gomock.Any(), userObjectID).
+						Times(1).Return(nil),
+					repos.userRepo.EXPECT().DeleteUser(gomock.Any(), userID).
+						Times(1).Return(nil),
+				)
 			},
 			wantErr: false,
 		},
+		{
+			name: "should_fail_delete_user_when_bucket_deletion_fails",
+			args: args{id: userID},
+			stubFn: func(repos *userRepoMocks) {
+				repos.apiKeyRepo.EXPECT().DeleteUserAPIKeys(gomock.Any(), userID).
+					Times(1).Return(int64(0), nil)
+				repos.sessionRepo.EXPECT().DeleteUserSessions(gomock.Any(), userObjectID).
+					Times(1).Return(int64(0), nil)
+				repos.bucketRepo.EXPECT().FindAllBucketUIDsByUserID(gomock.Any(), userID).
+					Times(1).Return([]string{"bucket-1"}, nil)
+				repos.bucketSnapshotRepo.EXPECT().DeleteBucketSnapshots(gomock.Any(), "bucket-1").
+					Times(1).Return(nil)
+				repos.webhookRepo.EXPECT().DeleteBucketWebhooks(gomock.Any(), "bucket-1").
+					Times(1).Return(nil)
+				repos.bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), "bucket-1").
+					Times(1).Return(models.ErrDeletingBucketItems)
+				repos.userRepo.EXPECT().DeleteUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrDeletingBucketItems.Error(),
+		},
 		{
 			name: "should_fail_delete_user",
-			args: args{id: "abcde"},
-			stubFn: func(userRepo *mocks.MockIUserRepository) {
-				userRepo.EXPECT().DeleteUser(gomock.Any()).
+			args: args{id: userID},
+			stubFn: func(repos *userRepoMocks) {
+				repos.apiKeyRepo.EXPECT().DeleteUserAPIKeys(gomock.Any(), userID).
+					Times(1).Return(int64(0), nil)
+				repos.sessionRepo.EXPECT().DeleteUserSessions(gomock.Any(), userObjectID).
+					Times(1).Return(int64(0), nil)
+				repos.bucketRepo.EXPECT().FindAllBucketUIDsByUserID(gomock.Any(), userID).
+					Times(1).Return([]string{}, nil)
+				repos.bucketItemRepo.EXPECT().AnonymizeUserBucketItems(gomock.Any(), userID).
+					Times(1).Return(int64(0), nil)
+				repos.usageRepo.EXPECT().DeleteUserUsage(gomock.Any(), userObjectID).
+					Times(1).Return(nil)
+				// the failed logins of a user that is already gone are left to expire
+				repos.userRepo.EXPECT().FindUserById(gomock.Any(), userID).
+					Times(1).Return(nil, models.ErrUserNotFound)
+				repos.twoFactorRepo.EXPECT().DeleteTwoFactor(gomock.Any(), userObjectID).
+					Times(1).Return(nil)
+				repos.userRepo.EXPECT().DeleteUser(gomock.Any(), userID).
 					Times(1).Return(errors.New("failed to delete user"))
 			},
 			wantErr:    true,
 			wantErrMsg: "failed to delete user",
 		},
+		{
+			name:       "should_fail_delete_user_with_empty_id",
+			args:       args{id: ""},
+			wantErr:    true,
+			wantErrMsg: ErrUserIDIsEmpty.Error(),
+		},
 	}
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			if tc.stubFn != nil {
-				tc.stubFn(userRepo)
+				tc.stubFn(repos)
 			}
 
-			userSvc := provideUserService(userRepo)
-			err := userSvc.DeleteUser(tc.args.id)
+			userSvc := provideUserService(repos)
+			status, err := userSvc.DeleteUser(context.Background(), tc.args.id)
 			if tc.wantErr {
 				require.NotNil(t, err)
 				require.Equal(t, err.Error(), tc.wantErrMsg)
@@ -410,6 +612,57 @@ func TestUserService_DeleteUser(t *testing.T) {
 			}
 
 			require.Nil(t, err)
+			require.Equal(t, "completed", status.State)
 		})
 	}
 }
+
+func TestUserService_DeleteUserData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	repos := newUserRepoMocks(ctrl)
+	userRepo, bucketRepo, bucketItemRepo, bucketSnapshotRepo, apiKeyRepo := repos.userRepo, repos.bucketRepo, repos.bucketItemRepo, repos.bucketSnapshotRepo, repos.apiKeyRepo
+	twoFactorRepo, sessionRepo, usageRepo, loginAttemptRepo, webhookRepo := repos.twoFactorRepo, repos.sessionRepo, repos.usageRepo, repos.loginAttemptRepo, repos.webhookRepo
+
+	userID := "62fa734bfc1cdb7f06a3bf6f"
+	userObjectID, _ := primitive.ObjectIDFromHex(userID)
+	apiKeyRepo.EXPECT().DeleteUserAPIKeys(gomock.Any(), userID).Times(1).Return(int64(1), nil)
+	sessionRepo.EXPECT().DeleteUserSessions(gomock.Any(), userObjectID).Times(1).Return(int64(2), nil)
+	bucketRepo.EXPECT().FindAllBucketUIDsByUserID(gomock.Any(), userID).Times(1).Return([]string{"bucket-1"}, nil)
+	bucketSnapshotRepo.EXPECT().DeleteBucketSnapshots(gomock.Any(), "bucket-1").Times(1).Return(nil)
+	webhookRepo.EXPECT().DeleteBucketWebhooks(gomock.Any(), "bucket-1").Times(1).Return(nil)
+	bucketItemRepo.EXPECT().DeleteBucketItems(gomock.Any(), "bucket-1").Times(1).Return(nil)
+	bucketRepo.EXPECT().DeleteBucketByUID(gomock.Any(), "bucket-1").Times(1).Return(nil)
+	bucketItemRepo.EXPECT().AnonymizeUserBucketItems(gomock.Any(), userID).Times(1).Return(int64(4), nil)
+	usageRepo.EXPECT().DeleteUserUsage(gomock.Any(), userObjectID).Times(1).Return(nil)
+	userRepo.EXPECT().FindUserById(gomock.Any(), userID).Times(1).Return(&models.User{ID: userObjectID, Email: "User@Example.com"}, nil)
+	loginAttemptRepo.EXPECT().DeleteLoginAttempts(gomock.Any(), "email:user@example.com").Times(1).Return(nil)
+	twoFactorRepo.EXPECT().DeleteTwoFactor(gomock.Any(), userObjectID).Times(1).Return(nil)
+	userRepo.EXPECT().DeleteUser(gomock.Any(), userID).Times(1).Return(nil)
+
+	stages := []string{}
+	var last models.UserDeletionProgress
+	userSvc := provideUserService(repos)
+	err := userSvc.DeleteUserData(context.Background(), userID, func(progress models.UserDeletionProgress) {
+		stages = append(stages, progress.Stage)
+		last = progress
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		models.UserDeletionStageDeletingAPIKeys,
+		models.UserDeletionStageDeletingBuckets,
+		models.UserDeletionStageDeletingBuckets,
+		models.UserDeletionStageAnonymizingItems,
+		models.UserDeletionStageDeletingUser,
+		models.UserDeletionStageCompleted,
+	}, stages)
+	require.Equal(t, models.UserDeletionProgress{
+		Stage:           models.UserDeletionStageCompleted,
+		APIKeysDeleted:  1,
+		BucketsTotal:    1,
+		BucketsDeleted:  1,
+		ItemsAnonymized: 4,
+	}, last)
+}
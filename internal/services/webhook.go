@@ -0,0 +1,430 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"keeper/internal/pkg/tracing"
+	"keeper/internal/pkg/webhook"
+	"keeper/internal/queue"
+	"keeper/internal/queue/tasks"
+	"keeper/internal/repository"
+	"keeper/internal/utils"
+	"keeper/pkg/log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dchest/uniuri"
+	"github.com/hibiken/asynq"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WebhookService struct {
+	webhookRepo         repository.IWebhookRepository
+	webhookDeliveryRepo repository.IWebhookDeliveryRepository
+	bucketRepo          repository.IBucketRepository
+	httpClient          *http.Client
+	lookupHost          func(ctx context.Context, host string) ([]net.IPAddr, error) // resolves the hosts of the webhook urls
+	cfg                 *config.Config
+	queue               *queue.RedisQueue
+}
+
+type IWebhookService interface {
+	CreateWebhook(ctx context.Context, bucketUID string, data dto.CreateWebhookInputDTO, userID primitive.ObjectID) (*dto.CreateWebhookOutputDTO, error)
+	ListWebhooks(ctx context.Context, bucketUID string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, bucketUID string, id string) error
+	ListWebhookDeliveries(ctx context.Context, bucketUID string, id string) ([]models.WebhookDelivery, error)
+	ReplayWebhookDelivery(ctx context.Context, bucketUID string, id string, deliveryID string) (*models.WebhookDelivery, error)
+	SendTestEvent(ctx context.Context, bucketUID string, id string) (*models.WebhookDelivery, error)
+	DispatchEvent(ctx context.Context, event models.WebhookEvent) error
+	DeliverWebhook(ctx context.Context, deliveryID string, lastAttempt bool) error
+}
+
+// number of deliveries returned by the delivery log
+const webhookDeliveryLogSize = 100
+
+// how long the dispatch of an event to the webhooks of a bucket may take without the workers
+const webhookDispatchTimeout = 30 * time.Second
+
+var (
+	ErrWebhookIDIsEmpty      = models.NewBadRequestError(errors.New("webhook id cannot be empty"))
+	ErrInvalidWebhookURL     = models.NewBadRequestError(errors.New("webhook url must be an absolute http or https url"))
+	ErrInvalidWebhookEvent   = models.NewBadRequestError(errors.New("invalid webhook event"))
+	ErrWebhookDeliveryFailed = errors.New("webhook delivery failed")
+)
+
+func NewWebhookService(cfg *config.Config, webhookRepo repository.IWebhookRepository, webhookDeliveryRepo repository.IWebhookDeliveryRepository, bucketRepo repository.IBucketRepository) IWebhookService {
+	return &WebhookService{
+		webhookRepo:         webhookRepo,
+		webhookDeliveryRepo: webhookDeliveryRepo,
+		bucketRepo:          bucketRepo,
+		httpClient:          webhook.NewHTTPClient(time.Duration(cfg.WebhookTimeoutSeconds)*time.Second, cfg.WebhookAllowPrivateNetworks),
+		lookupHost:          net.DefaultResolver.LookupIPAddr,
+		cfg:                 cfg,
+		queue:               queue.NewRedisQueue(cfg),
+	}
+}
+
+// Registers a webhook on a bucket
+// the url must not resolve to a private or reserved address, unless the private networks are allowed
+// the signing secret of the webhook is only returned here
+func (w *WebhookService) CreateWebhook(ctx context.Context, bucketUID string, data dto.CreateWebhookInputDTO, userID primitive.ObjectID) (*dto.CreateWebhookOutputDTO, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.CreateWebhook")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	endpoint, err := url.Parse(data.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+	if !w.cfg.WebhookAllowPrivateNetworks {
+		if err := webhook.CheckHost(ctx, w.lookupHost, endpoint.Hostname()); err != nil {
+			if errors.Is(err, webhook.ErrForbiddenAddress) {
+				return nil, err
+			}
+			logrus.WithContext(ctx).WithError(err).Warn("error resolving webhook url")
+			return nil, ErrInvalidWebhookURL
+		}
+	}
+	events := data.Events
+	if len(events) == 0 {
+		events = models.WEBHOOK_EVENTS
+	}
+	for _, event := range events {
+		if !utils.Contains(models.WEBHOOK_EVENTS, event) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidWebhookEvent, event)
+		}
+	}
+	if _, err := w.bucketRepo.FindBucketByUID(ctx, bucketUID); err != nil {
+		return nil, err
+	}
+	newWebhook := &models.Webhook{
+		BucketUID: bucketUID,
+		UserID:    userID,
+		URL:       endpoint.String(),
+		Events:    events,
+		Secret:    "whsec_" + uniuri.NewLen(32),
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	id, err := w.webhookRepo.CreateWebhook(ctx, newWebhook)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.CreateWebhookOutputDTO{
+		ID:        id,
+		BucketUID: bucketUID,
+		URL:       newWebhook.URL,
+		Events:    newWebhook.Events,
+		Secret:    newWebhook.Secret,
+		CreatedAt: newWebhook.CreatedAt,
+	}, nil
+}
+
+// Lists the webhooks of a bucket
+func (w *WebhookService) ListWebhooks(ctx context.Context, bucketUID string) ([]models.Webhook, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListWebhooks")
+	defer span.End()
+	if utils.IsStringEmpty(bucketUID) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	return w.webhookRepo.FindWebhooksByBucketUID(ctx, bucketUID)
+}
+
+// Deletes a webhook along with its delivery log
+func (w *WebhookService) DeleteWebhook(ctx context.Context, bucketUID string, id string) error {
+	ctx, span := tracing.Start(ctx, "WebhookService.DeleteWebhook")
+	defer span.End()
+	hook, err := w.findBucketWebhook(ctx, bucketUID, id)
+	if err != nil {
+		return err
+	}
+	if err := w.webhookRepo.DeleteWebhook(ctx, hook.ID.Hex()); err != nil {
+		return err
+	}
+	return w.webhookDeliveryRepo.DeleteWebhookDeliveries(ctx, hook.ID.Hex())
+}
+
+// Returns the most recent deliveries of a webhook
+func (w *WebhookService) ListWebhookDeliveries(ctx context.Context, bucketUID string, id string) ([]models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListWebhookDeliveries")
+	defer span.End()
+	hook, err := w.findBucketWebhook(ctx, bucketUID, id)
+	if err != nil {
+		return nil, err
+	}
+	return w.webhookDeliveryRepo.FindDeliveriesByWebhookID(ctx, hook.ID.Hex(), webhookDeliveryLogSize)
+}
+
+// Sends the payload of a past delivery again as a new delivery
+func (w *WebhookService) ReplayWebhookDelivery(ctx context.Context, bucketUID string, id string, deliveryID string) (*models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ReplayWebhookDelivery")
+	defer span.End()
+	hook, err := w.findBucketWebhook(ctx, bucketUID, id)
+	if err != nil {
+		return nil, err
+	}
+	original, err := w.webhookDeliveryRepo.FindDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if original.WebhookID != hook.ID {
+		return nil, models.ErrWebhookDeliveryNotFound
+	}
+	delivery, err := w.createDelivery(ctx, hook, original.Event, original.Payload, original.ID)
+	if err != nil {
+		return nil, err
+	}
+	return delivery, w.enqueueDelivery(ctx, hook, delivery)
+}
+
+// Sends a test event to a webhook, whatever the events it is subscribed to
+func (w *WebhookService) SendTestEvent(ctx context.Context, bucketUID string, id string) (*models.WebhookDelivery, error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.SendTestEvent")
+	defer span.End()
+	hook, err := w.findBucketWebhook(ctx, bucketUID, id)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(models.WebhookEvent{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      models.WebhookEventTest,
+		BucketUID: bucketUID,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery, err := w.createDelivery(ctx, hook, models.WebhookEventTest, string(payload), primitive.ObjectID{})
+	if err != nil {
+		return nil, err
+	}
+	return delivery, w.enqueueDelivery(ctx, hook, delivery)
+}
+
+// Creates a delivery of an event for every webhook of the bucket subscribed to it
+func (w *WebhookService) DispatchEvent(ctx context.Context, event models.WebhookEvent) error {
+	ctx, span := tracing.Start(ctx, "WebhookService.DispatchEvent")
+	defer span.End()
+	hooks, err := w.webhookRepo.FindWebhooksByEvent(ctx, event.BucketUID, event.Type)
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	for i := range hooks {
+		delivery, err := w.createDelivery(ctx, &hooks[i], event.Type, string(payload), primitive.ObjectID{})
+		if err != nil {
+			return err
+		}
+		if err := w.enqueueDelivery(ctx, &hooks[i], delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sends a webhook delivery and records the outcome of the attempt
+// Accepts the delivery ID and whether no retry follows a failure of this attempt
+// Returns an error if the delivery failed
+func (w *WebhookService) DeliverWebhook(ctx context.Context, deliveryID string, lastAttempt bool) error {
+	ctx, span := tracing.Start(ctx, "WebhookService.DeliverWebhook")
+	defer span.End()
+	delivery, err := w.webhookDeliveryRepo.FindDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.Status == models.WebhookDeliveryStatusSucceeded {
+		return nil
+	}
+	hook, err := w.webhookRepo.FindWebhookByID(ctx, delivery.WebhookID.Hex())
+	if err != nil {
+		if errors.Is(err, models.ErrWebhookNotFound) {
+			// the webhook was deleted, there is nowhere left to deliver to
+			logrus.WithContext(ctx).Warnf("dropping delivery %s of deleted webhook %s", deliveryID, delivery.WebhookID.Hex())
+			return nil
+		}
+		return err
+	}
+	return w.deliver(ctx, hook, delivery, lastAttempt)
+}
+
+// Posts the payload of a delivery to the webhook endpoint
+func (w *WebhookService) deliver(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery, lastAttempt bool) error {
+	timestamp := time.Now().Unix()
+	body := []byte(delivery.Payload)
+
+	delivery.Attempts++
+	delivery.StatusCode = 0
+	delivery.Error = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Kipa-Webhook/1.0")
+		req.Header.Set(webhook.HeaderEvent, delivery.Event)
+		req.Header.Set(webhook.HeaderDelivery, delivery.ID.Hex())
+		req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign(hook.Secret, timestamp, body))
+		var resp *http.Response
+		resp, err = w.httpClient.Do(req)
+		if err == nil {
+			// the response body is drained so that the connection can be reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			delivery.StatusCode = resp.StatusCode
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				err = fmt.Errorf("unexpected response status %d", resp.StatusCode)
+			}
+		}
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	delivery.UpdatedAt = now
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliveryStatusSucceeded
+		delivery.DeliveredAt = now
+	case lastAttempt:
+		delivery.Status = models.WebhookDeliveryStatusFailed
+		delivery.Error = err.Error()
+	default:
+		delivery.Status = models.WebhookDeliveryStatusRetrying
+		delivery.Error = err.Error()
+	}
+	if updateErr := w.webhookDeliveryRepo.UpdateDelivery(ctx, delivery); updateErr != nil {
+		return updateErr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrWebhookDeliveryFailed, err.Error())
+	}
+	return nil
+}
+
+// Saves a new pending delivery to the delivery log
+func (w *WebhookService) createDelivery(ctx context.Context, hook *models.Webhook, event string, payload string, replayOf primitive.ObjectID) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{
+		WebhookID: hook.ID,
+		BucketUID: hook.BucketUID,
+		Event:     event,
+		Payload:   payload,
+		Status:    models.WebhookDeliveryStatusPending,
+		ReplayOf:  replayOf,
+		CreatedAt: primitive.NewDateTimeFromTime(time.Now()),
+		UpdatedAt: primitive.NewDateTimeFromTime(time.Now()),
+	}
+	id, err := w.webhookDeliveryRepo.CreateDelivery(ctx, delivery)
+	if err != nil {
+		return nil, err
+	}
+	delivery.ID = id
+	return delivery, nil
+}
+
+// Enqueues a delivery, asynq retries failed deliveries with an exponential backoff
+// without the workers, the delivery is attempted once in the background so that the request is not held by the endpoint
+func (w *WebhookService) enqueueDelivery(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) error {
+	if !w.cfg.WithWorkers {
+		// the delivery outlives the request, it gets its own copies and a context detached from the request
+		hook, delivery := *hook, *delivery
+		deliveryCtx, cancel := context.WithTimeout(log.WithRequestID(context.Background(), log.RequestID(ctx)), w.deliveryTimeout())
+		go func() {
+			defer cancel()
+			if err := w.deliver(deliveryCtx, &hook, &delivery, true); err != nil {
+				logrus.WithContext(deliveryCtx).WithError(err).Warnf("failed to deliver webhook: %s", delivery.ID.Hex())
+			}
+		}()
+		return nil
+	}
+	task, err := tasks.NewDeliverWebhookTask(delivery.ID.Hex())
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating deliver webhook task")
+		return models.ErrEnqueuingTask
+	}
+	if err := w.queue.Add(ctx, task, asynq.MaxRetry(w.cfg.WebhookMaxRetries)); err != nil {
+		return models.ErrEnqueuingTask
+	}
+	return nil
+}
+
+// Returns how long a delivery attempt may take, the request to the endpoint and the update of the delivery log
+func (w *WebhookService) deliveryTimeout() time.Duration {
+	return time.Duration(w.cfg.WebhookTimeoutSeconds)*time.Second + 10*time.Second
+}
+
+// Finds a webhook, making sure it belongs to the bucket
+func (w *WebhookService) findBucketWebhook(ctx context.Context, bucketUID string, id string) (*models.Webhook, error) {
+	if utils.IsStringEmpty(bucketUID) {
+		return nil, ErrBucketUIDIsEmpty
+	}
+	if utils.IsStringEmpty(id) {
+		return nil, ErrWebhookIDIsEmpty
+	}
+	hook, err := w.webhookRepo.FindWebhookByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hook.BucketUID != bucketUID {
+		return nil, models.ErrWebhookNotFound
+	}
+	return hook, nil
+}
+
+// Publishes a bucket item event to the webhooks of the bucket
+// the event is fanned out to the webhooks by the workers, without them it is dispatched in the background
+// by the webhook service, which then attempts each delivery once
+func publishItemEvent(ctx context.Context, cfg *config.Config, q *queue.RedisQueue, webhookSvc IWebhookService, eventType string, bucketItem *models.BucketItem) {
+	if !cfg.WithWorkers && webhookSvc == nil {
+		return
+	}
+	item := &models.WebhookEventItem{
+		Key:  bucketItem.Key,
+		Type: bucketItem.Type,
+		TTL:  bucketItem.TTL,
+	}
+	// binary values are left out of the event, they can be downloaded instead
+	if bucketItem.Type != models.BucketItemTypeBinary {
+		item.Data = bucketItem.Data
+	}
+	event := models.WebhookEvent{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      eventType,
+		BucketUID: bucketItem.BucketUID,
+		CreatedAt: time.Now().UTC(),
+		Item:      item,
+	}
+	if !cfg.WithWorkers {
+		// the dispatch outlives the request, it gets a context detached from the request
+		dispatchCtx, cancel := context.WithTimeout(log.WithRequestID(context.Background(), log.RequestID(ctx)), webhookDispatchTimeout)
+		go func() {
+			defer cancel()
+			if err := webhookSvc.DispatchEvent(dispatchCtx, event); err != nil {
+				logrus.WithContext(dispatchCtx).WithError(err).Errorf("error dispatching %s event for bucket: %s", eventType, event.BucketUID)
+			}
+		}()
+		return
+	}
+	task, err := tasks.NewDispatchWebhookEventTask(event)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("error creating dispatch webhook event task")
+		return
+	}
+	if err := q.Add(ctx, task); err != nil {
+		logrus.WithContext(ctx).WithError(err).Errorf("error publishing %s event for bucket: %s", eventType, bucketItem.BucketUID)
+	}
+}
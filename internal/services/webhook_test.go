@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"keeper/internal/config"
+	"keeper/internal/dto"
+	"keeper/internal/mocks"
+	"keeper/internal/models"
+	"keeper/internal/pkg/webhook"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// provide the webhook service, the private networks are allowed as the test receivers listen on the loopback
+func provideWebhookService(mockWebhookRepo *mocks.MockIWebhookRepository, mockWebhookDeliveryRepo *mocks.MockIWebhookDeliveryRepository, mockBucketRepo *mocks.MockIBucketRepository) IWebhookService {
+	cfg := &config.Config{
+		Env:                         "test",
+		WebhookMaxRetries:           3,
+		WebhookTimeoutSeconds:       5,
+		WebhookAllowPrivateNetworks: true,
+	}
+	return NewWebhookService(cfg, mockWebhookRepo, mockWebhookDeliveryRepo, mockBucketRepo)
+}
+
+func TestWebhookService_CreateWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webhookRepo := mocks.NewMockIWebhookRepository(ctrl)
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+
+	type args struct {
+		uid  string
+		data dto.CreateWebhookInputDTO
+	}
+
+	tt := []struct {
+		name       string
+		args       args
+		stubFn     func(webhookRepo *mocks.MockIWebhookRepository, bucketRepo *mocks.MockIBucketRepository)
+		wantEvents []string
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name: "should_successfully_create_webhook_for_every_event",
+			args: args{
+				uid:  "12345",
+				data: dto.CreateWebhookInputDTO{URL: "https://example.com/hooks"},
+			},
+			stubFn: func(webhookRepo *mocks.MockIWebhookRepository, bucketRepo *mocks.MockIBucketRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+				webhookRepo.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).
+					Times(1).Return(primitive.NewObjectID(), nil)
+			},
+			wantEvents: models.WEBHOOK_EVENTS,
+		},
+		{
+			name: "should_fail_create_webhook_invalid_url",
+			args: args{
+				uid:  "12345",
+				data: dto.CreateWebhookInputDTO{URL: "ftp://example.com/hooks"},
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: ErrInvalidWebhookURL.Error(),
+		},
+		{
+			name: "should_fail_create_webhook_invalid_event",
+			args: args{
+				uid:  "12345",
+				data: dto.CreateWebhookInputDTO{URL: "https://example.com/hooks", Events: []string{"item.renamed"}},
+			},
+			stubFn:     nil,
+			wantErr:    true,
+			wantErrMsg: "invalid webhook event: item.renamed",
+		},
+		{
+			name: "should_fail_create_webhook_bucket_not_found",
+			args: args{
+				uid:  "12345",
+				data: dto.CreateWebhookInputDTO{URL: "https://example.com/hooks", Events: []string{models.WebhookEventItemDeleted}},
+			},
+			stubFn: func(webhookRepo *mocks.MockIWebhookRepository, bucketRepo *mocks.MockIBucketRepository) {
+				bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+					Times(1).Return(nil, models.ErrBucketNotFound)
+			},
+			wantErr:    true,
+			wantErrMsg: models.ErrBucketNotFound.Error(),
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.stubFn != nil {
+				tc.stubFn(webhookRepo, bucketRepo)
+			}
+
+			webhookSvc := provideWebhookService(webhookRepo, nil, bucketRepo)
+			resp, err := webhookSvc.CreateWebhook(context.Background(), tc.args.uid, tc.args.data, primitive.NewObjectID())
+			if tc.wantErr {
+				require.NotNil(t, err)
+				require.Equal(t, err.Error(), tc.wantErrMsg)
+				return
+			}
+
+			require.Nil(t, err)
+			require.Equal(t, tc.wantEvents, resp.Events)
+			require.NotEmpty(t, resp.Secret)
+		})
+	}
+}
+
+func TestWebhookService_CreateWebhook_ForbiddenAddresses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webhookRepo := mocks.NewMockIWebhookRepository(ctrl)
+	bucketRepo := mocks.NewMockIBucketRepository(ctrl)
+	bucketRepo.EXPECT().FindBucketByUID(gomock.Any(), "12345").
+		Times(1).Return(&models.Bucket{UID: "12345"}, nil)
+	webhookRepo.EXPECT().CreateWebhook(gomock.Any(), gomock.Any()).
+		Times(1).Return(primitive.NewObjectID(), nil)
+
+	webhookSvc := NewWebhookService(&config.Config{Env: "test", WebhookTimeoutSeconds: 5}, webhookRepo, nil, bucketRepo).(*WebhookService)
+	webhookSvc.lookupHost = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "hooks.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		case "internal.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("10.0.0.12")}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tt := []struct {
+		url     string
+		wantErr error
+	}{
+		{url: "https://hooks.example.com/hooks"},
+		{url: "https://internal.example.com/hooks", wantErr: webhook.ErrForbiddenAddress},
+		{url: "http://127.0.0.1:8080/hooks", wantErr: webhook.ErrForbiddenAddress},
+		{url: "http://169.254.169.254/latest/meta-data", wantErr: webhook.ErrForbiddenAddress},
+		{url: "http://[::ffff:192.168.1.1]/hooks", wantErr: webhook.ErrForbiddenAddress},
+		{url: "https://unknown.example.com/hooks", wantErr: ErrInvalidWebhookURL},
+	}
+	for _, tc := range tt {
+		t.Run(tc.url, func(t *testing.T) {
+			_, err := webhookSvc.CreateWebhook(context.Background(), "12345", dto.CreateWebhookInputDTO{URL: tc.url}, primitive.NewObjectID())
+			require.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestWebhookService_DeliverWebhook_ForbiddenAddress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webhookRepo := mocks.NewMockIWebhookRepository(ctrl)
+	webhookDeliveryRepo := mocks.NewMockIWebhookDeliveryRepository(ctrl)
+
+	// the host of the webhook now resolves to the loopback, the delivery is refused when connecting
+	received := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer receiver.Close()
+
+	hook := &models.Webhook{ID: primitive.NewObjectID(), BucketUID: "12345", URL: receiver.URL, Secret: "secret"}
+	delivery := &models.WebhookDelivery{ID: primitive.NewObjectID(), WebhookID: hook.ID, Event: models.WebhookEventItemCreated}
+	webhookDeliveryRepo.EXPECT().FindDeliveryByID(gomock.Any(), delivery.ID.Hex()).
+		Times(1).Return(delivery, nil)
+	webhookRepo.EXPECT().FindWebhookByID(gomock.Any(), hook.ID.Hex()).
+		Times(1).Return(hook, nil)
+	webhookDeliveryRepo.EXPECT().UpdateDelivery(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, updated *models.WebhookDelivery) error {
+		require.Equal(t, models.WebhookDeliveryStatusFailed, updated.Status)
+		require.Contains(t, updated.Error, webhook.ErrForbiddenAddress.Error())
+		return nil
+	})
+
+	webhookSvc := NewWebhookService(&config.Config{Env: "test", WebhookTimeoutSeconds: 5}, webhookRepo, webhookDeliveryRepo, nil)
+	err := webhookSvc.DeliverWebhook(context.Background(), delivery.ID.Hex(), true)
+	require.ErrorIs(t, err, ErrWebhookDeliveryFailed)
+	require.False(t, received)
+}
+
+func TestWebhookService_DeliverWebhook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webhookRepo := mocks.NewMockIWebhookRepository(ctrl)
+	webhookDeliveryRepo := mocks.NewMockIWebhookDeliveryRepository(ctrl)
+
+	// the receiver verifies the signature of every delivery
+	status := http.StatusOK
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := webhook.Verify("secret", r.Header.Get(webhook.HeaderSignature), r.Header.Get(webhook.HeaderTimestamp), body, time.Minute)
+		require.Nil(t, err)
+		require.Equal(t, models.WebhookEventItemCreated, r.Header.Get(webhook.HeaderEvent))
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	hook := &models.Webhook{ID: primitive.NewObjectID(), BucketUID: "12345", URL: receiver.URL, Secret: "secret"}
+	newDelivery := func() *models.WebhookDelivery {
+		return &models.WebhookDelivery{
+			ID:        primitive.NewObjectID(),
+			WebhookID: hook.ID,
+			Event:     models.WebhookEventItemCreated,
+			Payload:   `{"type":"item.created"}`,
+			Status:    models.WebhookDeliveryStatusPending,
+		}
+	}
+
+	tt := []struct {
+		name        string
+		status      int
+		lastAttempt bool
+		wantStatus  string
+		wantErr     bool
+	}{
+		{
+			name:       "should_successfully_deliver_webhook",
+			status:     http.StatusNoContent,
+			wantStatus: models.WebhookDeliveryStatusSucceeded,
+		},
+		{
+			name:       "should_retry_failed_webhook_delivery",
+			status:     http.StatusInternalServerError,
+			wantStatus: models.WebhookDeliveryStatusRetrying,
+			wantErr:    true,
+		},
+		{
+			name:        "should_fail_webhook_delivery_on_last_attempt",
+			status:      http.StatusBadGateway,
+			lastAttempt: true,
+			wantStatus:  models.WebhookDeliveryStatusFailed,
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			status = tc.status
+			delivery := newDelivery()
+			webhookDeliveryRepo.EXPECT().FindDeliveryByID(gomock.Any(), delivery.ID.Hex()).
+				Times(1).Return(delivery, nil)
+			webhookRepo.EXPECT().FindWebhookByID(gomock.Any(), hook.ID.Hex()).
+				Times(1).Return(hook, nil)
+			webhookDeliveryRepo.EXPECT().UpdateDelivery(gomock.Any(), gomock.Any()).
+				Times(1).DoAndReturn(func(_ context.Context, updated *models.WebhookDelivery) error {
+				require.Equal(t, tc.wantStatus, updated.Status)
+				require.Equal(t, tc.status, updated.StatusCode)
+				require.Equal(t, 1, updated.Attempts)
+				return nil
+			})
+
+			webhookSvc := provideWebhookService(webhookRepo, webhookDeliveryRepo, nil)
+			err := webhookSvc.DeliverWebhook(context.Background(), delivery.ID.Hex(), tc.lastAttempt)
+			if tc.wantErr {
+				require.ErrorIs(t, err, ErrWebhookDeliveryFailed)
+				return
+			}
+			require.Nil(t, err)
+		})
+	}
+}
+
+func TestWebhookService_ReplayWebhookDelivery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webhookRepo := mocks.NewMockIWebhookRepository(ctrl)
+	webhookDeliveryRepo := mocks.NewMockIWebhookDeliveryRepository(ctrl)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	hook := &models.Webhook{ID: primitive.NewObjectID(), BucketUID: "12345", URL: receiver.URL, Secret: "secret"}
+	original := &models.WebhookDelivery{
+		ID:        primitive.NewObjectID(),
+		WebhookID: hook.ID,
+		Event:     models.WebhookEventItemDeleted,
+		Payload:   `{"type":"item.deleted"}`,
+		Status:    models.WebhookDeliveryStatusFailed,
+	}
+
+	webhookRepo.EXPECT().FindWebhookByID(gomock.Any(), hook.ID.Hex()).
+		Times(2).Return(hook, nil)
+	webhookDeliveryRepo.EXPECT().FindDeliveryByID(gomock.Any(), original.ID.Hex()).
+		Times(1).Return(original, nil)
+	webhookDeliveryRepo.EXPECT().CreateDelivery(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, delivery *models.WebhookDelivery) (primitive.ObjectID, error) {
+		require.Equal(t, original.ID, delivery.ReplayOf)
+		require.Equal(t, original.Payload, delivery.Payload)
+		return primitive.NewObjectID(), nil
+	})
+	// without the workers the replay is delivered in the background
+	delivered := make(chan string, 1)
+	webhookDeliveryRepo.EXPECT().UpdateDelivery(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, delivery *models.WebhookDelivery) error {
+		delivered <- delivery.Status
+		return nil
+	})
+
+	webhookSvc := provideWebhookService(webhookRepo, webhookDeliveryRepo, nil)
+	delivery, err := webhookSvc.ReplayWebhookDelivery(context.Background(), "12345", hook.ID.Hex(), original.ID.Hex())
+	require.Nil(t, err)
+	require.Equal(t, models.WebhookDeliveryStatusPending, delivery.Status)
+	select {
+	case status := <-delivered:
+		require.Equal(t, models.WebhookDeliveryStatusSucceeded, status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the replay was not delivered")
+	}
+
+	// a webhook of another bucket is not found
+	_, err = webhookSvc.ReplayWebhookDelivery(context.Background(), "67890", hook.ID.Hex(), original.ID.Hex())
+	require.Equal(t, models.ErrWebhookNotFound, err)
+}
+
+func TestWebhookService_DeliverWebhook_Cancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webhookRepo := mocks.NewMockIWebhookRepository(ctrl)
+	webhookDeliveryRepo := mocks.NewMockIWebhookDeliveryRepository(ctrl)
+
+	// the request to a slow endpoint ends with the context of the delivery
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer receiver.Close()
+	defer close(release)
+
+	hook := &models.Webhook{ID: primitive.NewObjectID(), BucketUID: "12345", URL: receiver.URL, Secret: "secret"}
+	delivery := &models.WebhookDelivery{ID: primitive.NewObjectID(), WebhookID: hook.ID, Event: models.WebhookEventItemCreated}
+	webhookDeliveryRepo.EXPECT().FindDeliveryByID(gomock.Any(), delivery.ID.Hex()).
+		Times(1).Return(delivery, nil)
+	webhookRepo.EXPECT().FindWebhookByID(gomock.Any(), hook.ID.Hex()).
+		Times(1).Return(hook, nil)
+	webhookDeliveryRepo.EXPECT().UpdateDelivery(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, updated *models.WebhookDelivery) error {
+		require.Equal(t, models.WebhookDeliveryStatusRetrying, updated.Status)
+		require.Contains(t, updated.Error, context.DeadlineExceeded.Error())
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	webhookSvc := provideWebhookService(webhookRepo, webhookDeliveryRepo, nil)
+	start := time.Now()
+	err := webhookSvc.DeliverWebhook(ctx, delivery.ID.Hex(), false)
+	require.ErrorIs(t, err, ErrWebhookDeliveryFailed)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestPublishItemEvent_WithoutWorkers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	webhookRepo := mocks.NewMockIWebhookRepository(ctrl)
+	webhookDeliveryRepo := mocks.NewMockIWebhookDeliveryRepository(ctrl)
+
+	// without the workers the event is still delivered, in the background
+	received := make(chan string, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(webhook.HeaderEvent)
+	}))
+	defer receiver.Close()
+
+	hook := models.Webhook{ID: primitive.NewObjectID(), BucketUID: "12345", URL: receiver.URL, Secret: "secret"}
+	webhookRepo.EXPECT().FindWebhooksByEvent(gomock.Any(), "12345", models.WebhookEventItemDeleted).
+		Times(1).Return([]models.Webhook{hook}, nil)
+	webhookDeliveryRepo.EXPECT().CreateDelivery(gomock.Any(), gomock.Any()).
+		Times(1).Return(primitive.NewObjectID(), nil)
+	updated := make(chan string, 1)
+	webhookDeliveryRepo.EXPECT().UpdateDelivery(gomock.Any(), gomock.Any()).
+		Times(1).DoAndReturn(func(_ context.Context, delivery *models.WebhookDelivery) error {
+		updated <- delivery.Status
+		return nil
+	})
+
+	webhookSvc := provideWebhookService(webhookRepo, webhookDeliveryRepo, nil)
+	publishItemEvent(context.Background(), &config.Config{Env: "test"}, nil, webhookSvc, models.WebhookEventItemDeleted, &models.BucketItem{BucketUID: "12345", Key: "retries"})
+
+	select {
+	case event := <-received:
+		require.Equal(t, models.WebhookEventItemDeleted, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the event was not delivered")
+	}
+	select {
+	case status := <-updated:
+		require.Equal(t, models.WebhookDeliveryStatusSucceeded, status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delivery was not recorded")
+	}
+}
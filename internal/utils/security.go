@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"math/rand"
 	"strings"
 
@@ -90,3 +92,9 @@ func GenerateAPIKey() (string, string) {
 
 	return mask, apiKey.String()
 }
+
+// Returns the hex encoded sha256 of a token, to store the tokens that are only compared
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
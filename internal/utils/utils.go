@@ -42,6 +42,8 @@ func TypeOf(data interface{}) string {
 		return "number"
 	case bool:
 		return "bool"
+	case nil:
+		return "null"
 	default:
 		r := reflect.TypeOf(t)
 		if r == reflect.TypeOf(make([]interface{}, 0)) {
@@ -2,8 +2,11 @@ package validators
 
 import (
 	"errors"
+	"keeper/internal/models"
 	"net/mail"
+	"reflect"
 	"regexp"
+	"strings"
 
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
@@ -39,14 +42,55 @@ func NewValidator() *Validator {
 	}
 }
 
+// Validates a struct, returns a models.ValidationError holding every invalid field
 func (v *Validator) Validate(i interface{}) error {
 	err := v.validator.Struct(i)
 	if err == nil {
 		return nil
 	}
-	errs := err.(validator.ValidationErrors)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	fields := make([]models.FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, models.FieldError{
+			Field:   jsonFieldName(reflect.TypeOf(i), fe.StructNamespace()),
+			Rule:    fe.Tag(),
+			Message: fe.Translate(v.trans),
+		})
+	}
+	return models.NewValidationError(fields)
+}
 
-	return errors.New(errs[0].Translate(v.trans))
+// Returns the JSON path of a field from its struct namespace, e.g. CreateUserInputDTO.Email is email
+func jsonFieldName(t reflect.Type, namespace string) string {
+	path := []string{}
+	// the first segment is the name of the struct
+	for _, segment := range strings.Split(namespace, ".")[1:] {
+		name, index := segment, ""
+		if i := strings.Index(segment, "["); i >= 0 {
+			name, index = segment[:i], segment[i:]
+		}
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			path = append(path, segment)
+			continue
+		}
+		field, ok := t.FieldByName(name)
+		if !ok {
+			path = append(path, segment)
+			continue
+		}
+		t = field.Type
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+			name = tag
+		}
+		path = append(path, name+index)
+	}
+	return strings.Join(path, ".")
 }
 
 // Custom validation functions
@@ -0,0 +1,64 @@
+package validators
+
+import (
+	"errors"
+	"keeper/internal/dto"
+	"keeper/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      interface{}
+		wantFields []models.FieldError
+	}{
+		{
+			name: "should_validate_valid_input",
+			input: &dto.CreateUserInputDTO{
+				Firstname: "Similoluwa",
+				Lastname:  "Okunowo",
+				Email:     "me@gmail.com",
+				Password:  "Secret12345!",
+			},
+		},
+		{
+			name: "should_report_every_invalid_field_by_json_name",
+			input: &dto.CreateUserInputDTO{
+				Firstname: "Similoluwa",
+				Lastname:  "O",
+				Email:     "not an email",
+				Password:  "secret",
+			},
+			wantFields: []models.FieldError{
+				{Field: "lastname", Rule: "min", Message: "Lastname must be at least 2 characters in length"},
+				{Field: "email", Rule: "email", Message: "Email must be a valid email address"},
+				{Field: "password", Rule: "password", Message: "Password must contain at least one uppercase letter, lower case letter, number, and special character."},
+			},
+		},
+		{
+			name:  "should_report_slice_fields",
+			input: &dto.APIKeysIDsInputDTO{Ids: []string{}},
+			wantFields: []models.FieldError{
+				{Field: "ids", Rule: "min", Message: "Ids must contain at least 1 item"},
+			},
+		},
+	}
+	v := NewValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(tt.input)
+			if tt.wantFields == nil {
+				assert.Nil(t, err)
+				return
+			}
+			var validationErr *models.ValidationError
+			assert.True(t, errors.As(err, &validationErr))
+			assert.True(t, errors.Is(err, models.ErrValidationFailed))
+			assert.Equal(t, tt.wantFields, validationErr.Fields)
+			assert.Equal(t, tt.wantFields[0].Message, err.Error())
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Creates an API key for the authenticated user, the returned key is only available at creation
+func (c *Client) CreateAPIKey(ctx context.Context, input APIKeyInput) (*APIKey, error) {
+	apiKey := &APIKey{}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api_key", body: input}, apiKey); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+func (c *Client) GetAPIKey(ctx context.Context, id string) (*APIKey, error) {
+	apiKey := &APIKey{}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api_key/" + url.PathEscape(id)}, apiKey); err != nil {
+		return nil, err
+	}
+	return apiKey, nil
+}
+
+// Lists the API keys of the authenticated user
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	apiKeys := []APIKey{}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api_keys"}, &apiKeys); err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+func (c *Client) UpdateAPIKey(ctx context.Context, id string, input APIKeyInput) error {
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/api_key/" + url.PathEscape(id), body: input}, nil)
+	return err
+}
+
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/api_key/" + url.PathEscape(id) + "/revoke"}, nil)
+	return err
+}
+
+func (c *Client) DeleteAPIKey(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/api_key/" + url.PathEscape(id)}, nil)
+	return err
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Registers a new user
+func (c *Client) Register(ctx context.Context, input RegisterInput) error {
+	_, err := c.do(ctx, request{method: http.MethodPost, path: "/auth/register", body: input, noAuth: true}, nil)
+	return err
+}
+
+// Logs a user in, the client then authenticates with the returned access token
+// and refreshes it with the refresh token once it expires.
+// With two-factor authentication, only a two-factor token is returned, the login is completed with LoginTwoFactor
+func (c *Client) Login(ctx context.Context, email string, password string) (*Tokens, error) {
+	body := map[string]string{"email": email, "password": password}
+	tokens := &Tokens{}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/auth/login", body: body, noAuth: true}, tokens); err != nil {
+		return nil, err
+	}
+	if !tokens.TwoFactorRequired {
+		c.SetTokens(*tokens)
+	}
+	return tokens, nil
+}
+
+// Completes a login with the two-factor token it returned and a two-factor code, or a recovery code
+// the recovery codes are formatted as xxxxx-xxxxx
+func (c *Client) LoginTwoFactor(ctx context.Context, twoFactorToken string, code string) (*Tokens, error) {
+	body := map[string]string{"token": twoFactorToken, "code": code}
+	if len(code) != 6 {
+		body = map[string]string{"token": twoFactorToken, "recovery_code": code}
+	}
+	tokens := &Tokens{}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/auth/login/2fa", body: body, noAuth: true}, tokens); err != nil {
+		return nil, err
+	}
+	c.SetTokens(*tokens)
+	return tokens, nil
+}
+
+// Exchanges the refresh token of the client for a new access token and refresh token,
+// the previous refresh token is rejected from then on
+func (c *Client) RefreshToken(ctx context.Context) (*Tokens, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	return c.refresh(ctx)
+}
+
+func (c *Client) refresh(ctx context.Context) (*Tokens, error) {
+	c.mu.RLock()
+	refreshToken := c.refreshToken
+	c.mu.RUnlock()
+	header := http.Header{}
+	header.Set("x-refresh-token", refreshToken)
+	tokens := &Tokens{}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/auth/refresh-token", header: header, noAuth: true}, tokens); err != nil {
+		return nil, err
+	}
+	// a server without sessions keeps the refresh token
+	if tokens.RefreshToken == "" {
+		tokens.RefreshToken = refreshToken
+	}
+	c.SetTokens(*tokens)
+	return tokens, nil
+}
+
+// Logs out the session of the client, its access and refresh tokens are rejected from then on
+func (c *Client) Logout(ctx context.Context) error {
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/auth/logout"}, nil); err != nil {
+		return err
+	}
+	c.SetTokens(Tokens{})
+	return nil
+}
+
+// Logs out all the sessions of the user, returns the number of revoked sessions
+func (c *Client) LogoutAll(ctx context.Context) (int64, error) {
+	out := struct {
+		Sessions int64 `json:"sessions"`
+	}{}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/auth/logout-all"}, &out); err != nil {
+		return 0, err
+	}
+	c.SetTokens(Tokens{})
+	return out.Sessions, nil
+}
+
+// Returns the active sessions of the user
+func (c *Client) ListSessions(ctx context.Context) ([]Session, error) {
+	sessions := []Session{}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/auth/sessions"}, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Sets the access and refresh tokens the client authenticates with
+func (c *Client) SetTokens(tokens Tokens) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.accessToken = tokens.AccessToken
+	c.refreshToken = tokens.RefreshToken
+}
+
+// Returns the authenticated user
+func (c *Client) GetAuthUser(ctx context.Context) (*User, error) {
+	user := &User{}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/auth/user"}, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Returns the access and refresh tokens the client authenticates with,
+// the access token changes when it is refreshed
+func (c *Client) Tokens() Tokens {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Tokens{AccessToken: c.accessToken, RefreshToken: c.refreshToken}
+}
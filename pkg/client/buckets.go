@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func (c *Client) CreateBucket(ctx context.Context, input BucketInput) (*Bucket, error) {
+	bucket := &Bucket{}
+	query := url.Values{"full": {"true"}}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/bucket", query: query, body: input}, bucket); err != nil {
+		return nil, err
+	}
+	return bucket, nil
+}
+
+// Returns a bucket and its items
+func (c *Client) GetBucket(ctx context.Context, uid string) (*Bucket, error) {
+	bucket := &Bucket{}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/bucket/" + url.PathEscape(uid)}, bucket); err != nil {
+		return nil, err
+	}
+	return bucket, nil
+}
+
+// Updates the non-empty fields of a bucket
+func (c *Client) UpdateBucket(ctx context.Context, uid string, input BucketInput) error {
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/bucket/" + url.PathEscape(uid), body: input}, nil)
+	return err
+}
+
+// Requires, or no longer requires, two-factor authentication on a bucket, only its owner can change it
+func (c *Client) SetBucketTwoFactor(ctx context.Context, uid string, required bool) error {
+	body := map[string]bool{"required": required}
+	_, err := c.do(ctx, request{method: http.MethodPut, path: "/bucket/" + url.PathEscape(uid) + "/two-factor", body: body}, nil)
+	return err
+}
+
+// Moves a bucket and its items to the trash
+func (c *Client) DeleteBucket(ctx context.Context, uid string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: "/bucket/" + url.PathEscape(uid)}, nil)
+	return err
+}
+
+// Returns a page of the buckets of the authenticated user
+func (c *Client) ListBuckets(ctx context.Context, opts *ListOptions) ([]Bucket, *PageInfo, error) {
+	buckets := []Bucket{}
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/buckets", query: opts.values()}, &buckets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buckets, resp.PageInfo, nil
+}
+
+// Returns all the buckets of the authenticated user, fetching them page by page
+func (c *Client) ListAllBuckets(ctx context.Context, opts *ListOptions) ([]Bucket, error) {
+	return listAll(ctx, opts, c.ListBuckets)
+}
+
+// Fetches every page of a list starting from the page of opts
+func listAll[T any](ctx context.Context, opts *ListOptions, list func(context.Context, *ListOptions) ([]T, *PageInfo, error)) ([]T, error) {
+	pageOpts := ListOptions{Page: 1}
+	if opts != nil {
+		pageOpts = *opts
+	}
+	if pageOpts.Page < 1 {
+		pageOpts.Page = 1
+	}
+	all := []T{}
+	for {
+		page, pageInfo, err := list(ctx, &pageOpts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if pageInfo == nil || !pageInfo.HasNextPage || len(page) == 0 {
+			return all, nil
+		}
+		pageOpts.Page++
+	}
+}
+
+// Returns the query params of the list options
+func (o *ListOptions) values() url.Values {
+	query := url.Values{}
+	if o == nil {
+		return query
+	}
+	if o.Page > 0 {
+		query.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PerPage > 0 {
+		query.Set("perPage", strconv.Itoa(o.PerPage))
+	}
+	for _, sortBy := range o.SortBy {
+		query.Add("sortBy", sortBy)
+	}
+	for k, v := range o.Filters {
+		query.Set(k, v)
+	}
+	return query
+}
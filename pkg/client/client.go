@@ -0,0 +1,339 @@
+// Package client is the Go client for the Kipa HTTP API.
+//
+// A client authenticates with either an API key or the access token of a user:
+//
+//	c := client.New("http://localhost:5050", client.WithAPIKey(key))
+//	item, err := c.GetItem(ctx, bucketUID, "counter")
+//	if errors.Is(err, client.ErrBucketItemNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+	defaultTimeout    = 30 * time.Second
+)
+
+// Client of the Kipa HTTP API, safe for concurrent use
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu           sync.RWMutex
+	apiKey       string
+	accessToken  string
+	refreshToken string
+	// serializes the refreshes, a refresh token is rotated and cannot be used twice
+	refreshMu sync.Mutex
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// Authenticates the requests with an API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// Authenticates the requests with the access token of a user
+func WithAccessToken(token string) Option {
+	return func(c *Client) {
+		c.accessToken = token
+	}
+}
+
+// Sets the HTTP client used to send the requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// Sets how many times a failed request is retried and the bounds of the exponential backoff between attempts,
+// a maxRetries of 0 disables retries
+func WithRetry(maxRetries int, minBackoff time.Duration, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// Returns a client for the API served at baseURL, e.g. "http://localhost:5050"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// envelope of the API responses
+type response struct {
+	Status   bool            `json:"status"`
+	Message  string          `json:"message"`
+	Data     json.RawMessage `json:"data"`
+	PageInfo *PageInfo       `json:"page_info"`
+}
+
+// body of the error responses, the problem details of the API or the message of a bare error
+type problem struct {
+	Code    string       `json:"code"`
+	Detail  string       `json:"detail"`
+	Title   string       `json:"title"`
+	Field   string       `json:"field"`
+	Errors  []FieldError `json:"errors"`
+	Error   string       `json:"error"` // servers predating the problem details
+	Message string       `json:"message"`
+}
+
+// a request to the API
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        interface{}
+	rawBody     []byte // sent as is with rawBodyType instead of the JSON encoded body
+	rawBodyType string
+	header      http.Header
+	noAuth      bool
+	raw         bool // the response body is the data itself rather than the envelope
+}
+
+// Sends a request, retrying it on transient failures, and decodes the data of the response into out
+func (c *Client) do(ctx context.Context, req request, out interface{}) (*response, error) {
+	var body []byte
+	contentType := ""
+	if req.rawBody != nil {
+		body = req.rawBody
+		contentType = req.rawBodyType
+	} else if req.body != nil {
+		encoded, err := json.Marshal(req.body)
+		if err != nil {
+			return nil, fmt.Errorf("client: encoding request body: %w", err)
+		}
+		body = encoded
+		contentType = "application/json"
+	}
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		usedToken := c.credential()
+		resp, retryAfter, err := c.send(ctx, req, body, contentType)
+		// an expired access token is refreshed once with the refresh token of the login
+		if errors.Is(err, ErrUnauthorized) && !refreshed && c.canRefresh(req) {
+			refreshed = true
+			if refreshErr := c.refreshExpired(ctx, usedToken); refreshErr == nil {
+				attempt--
+				continue
+			}
+		}
+		if err == nil {
+			if out != nil && len(resp.Data) > 0 {
+				if err := json.Unmarshal(resp.Data, out); err != nil {
+					return nil, fmt.Errorf("client: decoding response data: %w", err)
+				}
+			}
+			return resp, nil
+		}
+		if attempt >= c.maxRetries || !isRetryable(req.method, err) {
+			return nil, err
+		}
+		wait := c.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Sends a single attempt of a request
+// Returns the decoded response, the delay requested by a Retry-After header and an error
+func (c *Client) send(ctx context.Context, req request, body []byte, contentType string) (*response, time.Duration, error) {
+	u := c.baseURL + "/api/v1" + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("client: building request: %w", err)
+	}
+	for k, v := range req.header {
+		httpReq.Header[k] = v
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if !req.noAuth {
+		if token := c.credential(); token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, 0, &transportError{err: err}
+	}
+	defer httpResp.Body.Close()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, 0, &transportError{err: err}
+	}
+
+	if req.raw && httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
+		return &response{Status: true, Data: data}, 0, nil
+	}
+	if httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
+		resp := &response{}
+		// some endpoints respond with a bare JSON value instead of the envelope
+		if len(data) > 0 && json.Unmarshal(data, resp) != nil {
+			resp = &response{Data: data}
+		}
+		return resp, 0, nil
+	}
+	p := problem{}
+	json.Unmarshal(data, &p)
+	message := p.Detail
+	if message == "" {
+		message = p.Error
+	}
+	if message == "" {
+		message = p.Message
+	}
+	if message == "" {
+		message = http.StatusText(httpResp.StatusCode)
+	}
+	return nil, parseRetryAfter(httpResp.Header.Get("Retry-After")), &Error{
+		StatusCode: httpResp.StatusCode,
+		Code:       p.Code,
+		Message:    message,
+		Field:      p.Field,
+		Errors:     p.Errors,
+	}
+}
+
+// Returns the credential sent in the Authorization header, an API key takes precedence over an access token
+func (c *Client) credential() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.apiKey != "" {
+		return c.apiKey
+	}
+	return c.accessToken
+}
+
+// Refreshes the access token a request failed with,
+// unless a concurrent request already refreshed it
+func (c *Client) refreshExpired(ctx context.Context, expiredToken string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if c.credential() != expiredToken {
+		return nil
+	}
+	_, err := c.refresh(ctx)
+	return err
+}
+
+// Checks if a request failing with a 401 may be sent again after refreshing the access token
+func (c *Client) canRefresh(req request) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return !req.noAuth && c.apiKey == "" && c.refreshToken != ""
+}
+
+// Returns the exponential backoff with full jitter before retrying an attempt
+func (c *Client) backoff(attempt int) time.Duration {
+	max := c.minBackoff << attempt
+	if max <= 0 || max > c.maxBackoff {
+		max = c.maxBackoff
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)) + 1)
+}
+
+// Checks if a failed attempt can be retried,
+// requests that are not idempotent are only retried when the server did not handle them
+func isRetryable(method string, err error) bool {
+	idempotent := method != http.MethodPost && method != http.MethodPatch
+	switch e := err.(type) {
+	case *transportError:
+		return idempotent
+	case *Error:
+		switch e.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusInternalServerError:
+			return idempotent
+		}
+	}
+	return false
+}
+
+// Parses a Retry-After header holding a number of seconds or an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// error sending a request or reading its response
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return "client: " + e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
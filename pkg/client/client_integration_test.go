@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"keeper/internal/config"
+	"keeper/internal/models"
+	"keeper/internal/server"
+	"keeper/internal/server/testdb"
+	mongoutils "keeper/pkg/mongo"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// runs the client against an in-process server backed by the test database
+type ClientIntegrationTestSuite struct {
+	suite.Suite
+	Cfg    *config.Config
+	DbConn mongoutils.Connection
+	Server *httptest.Server
+}
+
+func (s *ClientIntegrationTestSuite) SetupSuite() {
+	s.Cfg = config.NewTest()
+	s.DbConn = mongoutils.NewConnection(s.Cfg)
+	srv := server.NewServer(s.Cfg, s.DbConn.Client)
+	srv.RegisterRoutes()
+	s.Server = httptest.NewServer(srv.Server)
+	s.DbConn.CleanDB(s.Cfg.DbName)
+}
+
+func (s *ClientIntegrationTestSuite) TearDownSuite() {
+	s.Server.Close()
+	s.DbConn.CleanDB(s.Cfg.DbName)
+	s.DbConn.Disconnect()
+}
+
+// Test logging in and managing api keys with the access token
+func (s *ClientIntegrationTestSuite) TestClient_LoginAndAPIKeys() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	ctx := context.Background()
+	c := New(s.Server.URL)
+
+	_, err = c.Login(ctx, testUser.Email, "Secret12345!")
+	assert.Nil(s.T(), err)
+	user, err := c.GetAuthUser(ctx)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), testUser.ID.Hex(), user.ID)
+
+	apiKey, err := c.CreateAPIKey(ctx, APIKeyInput{Name: "sdk-key", Permissions: []string{"read:bucket"}})
+	assert.Nil(s.T(), err)
+	assert.NotEmpty(s.T(), apiKey.Key)
+	apiKeys, err := c.ListAPIKeys(ctx)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), apiKeys, 1)
+	assert.Nil(s.T(), c.DeleteAPIKey(ctx, apiKey.ID))
+}
+
+// Test the bucket and item operations with an api key
+func (s *ClientIntegrationTestSuite) TestClient_BucketsAndItems() {
+	testUser, err := testdb.SeedUser(s.DbConn.Client, s.Cfg)
+	assert.Nil(s.T(), err)
+	_, key, err := testdb.SeedAPIKey(s.DbConn.Client, s.Cfg, testUser.ID, models.APIKEY_PERMISSIONS)
+	assert.Nil(s.T(), err)
+	ctx := context.Background()
+	c := New(s.Server.URL, WithAPIKey(key))
+
+	bucket, err := c.CreateBucket(ctx, BucketInput{Name: "sdk-bucket", Description: "created by the sdk", Permissions: []string{"public:read:item", "public:write:item", "public:delete:item", "public:read"}})
+	assert.Nil(s.T(), err)
+	assert.NotEmpty(s.T(), bucket.UID)
+
+	_, err = c.CreateItem(ctx, bucket.UID, ItemInput{Key: "counter", Data: 1})
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), c.IncrementItem(ctx, bucket.UID, "counter", 4))
+	var counter int
+	assert.Nil(s.T(), c.GetItemValue(ctx, bucket.UID, "counter", &counter))
+	assert.Equal(s.T(), 5, counter)
+
+	items, err := c.ListItems(ctx, bucket.UID)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), items, 1)
+
+	assert.Nil(s.T(), c.DeleteItem(ctx, bucket.UID, "counter"))
+	_, err = c.GetItem(ctx, bucket.UID, "counter")
+	assert.True(s.T(), errors.Is(err, ErrBucketItemNotFound), "got error %v", err)
+}
+
+func TestClientIntegrationTestSuite(t *testing.T) {
+	if os.Getenv("MONGODB_HOST") == "" {
+		t.Skip("MONGODB_HOST is not set, skipping the client integration tests")
+	}
+	suite.Run(t, new(ClientIntegrationTestSuite))
+}
@@ -0,0 +1,280 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"keeper/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_ErrorsMatchServerErrors(t *testing.T) {
+	serverErrors := map[error]error{
+		ErrUserNotFound:            models.ErrUserNotFound,
+		ErrUsersNotFound:           models.ErrUsersNotFound,
+		ErrUserAlreadyExists:       models.ErrUserAlreadyExists,
+		ErrBucketAlreadyExists:     models.ErrBucketAlreadyExists,
+		ErrBucketItemAlreadyExists: models.ErrBucketItemAlreadyExists,
+		ErrInvalidObjectID:         models.ErrInvalidObjectID,
+		ErrAPIKeyNotFound:          models.ErrAPIKeyNotFound,
+		ErrAPIKeysNotFound:         models.ErrAPIKeysNotFound,
+		ErrBucketNotFound:          models.ErrBucketNotFound,
+		ErrBucketsNotFound:         models.ErrBucketsNotFound,
+		ErrBucketItemNotFound:      models.ErrBucketItemNotFound,
+		ErrBucketItemsNotFound:     models.ErrBucketItemsNotFound,
+		ErrBucketItemExpired:       models.ErrBucketItemExpired,
+		ErrBucketItemTooLarge:      models.ErrBucketItemTooLarge,
+		ErrBucketItemNotBinary:     models.ErrBucketItemNotBinary,
+		ErrSnapshotNotFound:        models.ErrSnapshotNotFound,
+		ErrSnapshotAlreadyExists:   models.ErrSnapshotAlreadyExists,
+		ErrMergeConflict:           models.ErrMergeConflict,
+		ErrWebhookNotFound:         models.ErrWebhookNotFound,
+		ErrWebhookDeliveryNotFound: models.ErrWebhookDeliveryNotFound,
+		ErrValidationFailed:        models.ErrValidationFailed,
+		ErrInvalidTwoFactorCode:    models.ErrInvalidTwoFactorCode,
+		ErrTwoFactorRequired:       models.ErrTwoFactorRequired,
+		ErrInvalidCredentials:      models.ErrInvalidCredentials,
+		ErrLoginLocked:             models.ErrLoginLocked,
+		ErrSessionRevoked:          models.ErrSessionRevoked,
+		ErrRefreshTokenReused:      models.ErrRefreshTokenReused,
+		ErrRateLimitExceeded:       models.ErrRateLimitExceeded,
+		ErrBucketQuotaExceeded:     models.ErrBucketQuotaExceeded,
+		ErrItemQuotaExceeded:       models.ErrItemQuotaExceeded,
+		ErrStorageQuotaExceeded:    models.ErrStorageQuotaExceeded,
+		ErrValueQuotaExceeded:      models.ErrValueQuotaExceeded,
+	}
+	assert.Len(t, serverErrors, len(apiErrors))
+	for clientErr, serverErr := range serverErrors {
+		assert.Equal(t, serverErr.Error(), clientErr.Error())
+		assert.Equal(t, string(models.ToAPIError(serverErr, 0).Code), apiErrors[clientErr])
+	}
+}
+
+func TestClient_ErrorsMatchPreviousServerErrors(t *testing.T) {
+	// earlier servers told a wrong password apart on login
+	for _, err := range []error{
+		&Error{StatusCode: http.StatusUnauthorized, Code: "incorrect_password", Message: "incorrect password"},
+		&Error{StatusCode: http.StatusUnauthorized, Message: "incorrect password"},
+	} {
+		assert.True(t, errors.Is(err, ErrInvalidCredentials))
+		assert.True(t, errors.Is(err, ErrIncorrectPassword))
+		assert.False(t, errors.Is(err, ErrLoginLocked))
+	}
+	assert.False(t, errors.Is(&Error{StatusCode: http.StatusNotFound, Code: "bucket_not_found"}, ErrInvalidCredentials))
+}
+
+func TestClient_Do(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		handler   func(attempt int32, w http.ResponseWriter, r *http.Request)
+		wantCalls int32
+		wantErr   error
+	}{
+		{
+			name:   "should_retry_unavailable_responses",
+			method: http.MethodGet,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				if attempt < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`{"status":true,"data":{"uid":"bucket"}}`))
+			},
+			wantCalls: 3,
+		},
+		{
+			name:   "should_not_retry_failed_posts",
+			method: http.MethodPost,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantCalls: 1,
+			wantErr:   ErrServer,
+		},
+		{
+			name:   "should_give_up_after_max_retries",
+			method: http.MethodGet,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantCalls: 4,
+			wantErr:   ErrServer,
+		},
+		{
+			name:   "should_map_api_errors",
+			method: http.MethodGet,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"bucket not found","code":"bucket_not_found"}`))
+			},
+			wantCalls: 1,
+			wantErr:   ErrBucketNotFound,
+		},
+		{
+			name:   "should_map_api_errors_by_code",
+			method: http.MethodGet,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"status":404,"detail":"the bucket is gone","code":"bucket_not_found"}`))
+			},
+			wantCalls: 1,
+			wantErr:   ErrBucketNotFound,
+		},
+		{
+			name:   "should_map_legacy_api_errors",
+			method: http.MethodGet,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"status":false,"error":"bucket not found"}`))
+			},
+			wantCalls: 1,
+			wantErr:   ErrBucketNotFound,
+		},
+		{
+			name:   "should_map_conflicts",
+			method: http.MethodPost,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+				w.Write([]byte(`{"status":409,"detail":"bucket item already exists","code":"bucket_item_already_exists","field":"key"}`))
+			},
+			wantCalls: 1,
+			wantErr:   ErrConflict,
+		},
+		{
+			name:   "should_map_validation_errors",
+			method: http.MethodPost,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"status":400,"detail":"Name is a required field","code":"validation_failed","errors":[{"field":"name","rule":"required","message":"Name is a required field"}]}`))
+			},
+			wantCalls: 1,
+			wantErr:   ErrValidationFailed,
+		},
+		{
+			name:   "should_map_echo_errors",
+			method: http.MethodGet,
+			handler: func(attempt int32, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"message":"Unauthorized"}`))
+			},
+			wantCalls: 1,
+			wantErr:   ErrUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.handler(atomic.AddInt32(&calls, 1), w, r)
+			}))
+			defer srv.Close()
+			c := New(srv.URL, WithAPIKey("key"), WithRetry(3, time.Millisecond, 5*time.Millisecond))
+			bucket := &Bucket{}
+			_, err := c.do(context.Background(), request{method: tt.method, path: "/bucket/bucket"}, bucket)
+			assert.Equal(t, tt.wantCalls, atomic.LoadInt32(&calls))
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got error %v", err)
+				assert.False(t, errors.Is(err, ErrUserNotFound))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, "bucket", bucket.UID)
+		})
+	}
+}
+
+func TestClient_RefreshesExpiredAccessToken(t *testing.T) {
+	var refreshes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/auth/refresh-token" && r.Header.Get("x-refresh-token") == "refresh":
+			atomic.AddInt32(&refreshes, 1)
+			time.Sleep(10 * time.Millisecond)
+			w.Write([]byte(`{"status":true,"data":{"access_token":"fresh","refresh_token":"rotated"}}`))
+		case r.Header.Get("Authorization") == "Bearer fresh":
+			w.Write([]byte(`{"status":true,"data":{"email":"me@gmail.com"}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Unauthorized"}`))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+	c.SetTokens(Tokens{AccessToken: "expired", RefreshToken: "refresh"})
+
+	// the concurrent requests refresh the rotated refresh token only once
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			user, err := c.GetAuthUser(context.Background())
+			if err == nil && user.Email != "me@gmail.com" {
+				err = errors.New("unexpected user")
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, <-errs)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+	assert.Equal(t, Tokens{AccessToken: "fresh", RefreshToken: "rotated"}, c.Tokens())
+}
+
+func TestClient_ListAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Write([]byte(`{"status":true,"data":[{"uid":"a"},{"uid":"b"}],"page_info":{"current_page":1,"has_next_page":true}}`))
+		default:
+			w.Write([]byte(`{"status":true,"data":[{"uid":"c"}],"page_info":{"current_page":2,"has_next_page":false}}`))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL, WithAPIKey("key"))
+
+	buckets, err := c.ListAllBuckets(context.Background(), &ListOptions{PerPage: 2})
+
+	assert.Nil(t, err)
+	assert.Len(t, buckets, 3)
+	assert.Equal(t, "c", buckets[2].UID)
+}
+
+func TestClient_LoginTwoFactor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.URL.Path == "/api/v1/auth/login":
+			w.Write([]byte(`{"status":true,"data":{"two_factor_required":true,"two_factor_token":"2fa"}}`))
+		case r.URL.Path == "/api/v1/auth/login/2fa" && body["token"] == "2fa" && (body["code"] == "123456" || body["recovery_code"] == "abcde-12345"):
+			w.Write([]byte(`{"status":true,"data":{"access_token":"access","refresh_token":"refresh"}}`))
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"status":401,"code":"invalid_two_factor_code","detail":"invalid two-factor code"}`))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+
+	// the password alone does not authenticate the client
+	tokens, err := c.Login(context.Background(), "me@gmail.com", "secret")
+	assert.Nil(t, err)
+	assert.True(t, tokens.TwoFactorRequired)
+	assert.Equal(t, "", c.credential())
+
+	_, err = c.LoginTwoFactor(context.Background(), tokens.TwoFactorToken, "000000")
+	assert.True(t, errors.Is(err, ErrInvalidTwoFactorCode), "got error %v", err)
+	_, err = c.LoginTwoFactor(context.Background(), tokens.TwoFactorToken, "abcde-12345")
+	assert.Nil(t, err)
+	_, err = c.LoginTwoFactor(context.Background(), tokens.TwoFactorToken, "123456")
+	assert.Nil(t, err)
+	assert.Equal(t, "access", c.credential())
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by the API, they match the errors of the server with errors.Is
+var (
+	ErrUserNotFound            = errors.New("user not found")
+	ErrUsersNotFound           = errors.New("users not found")
+	ErrUserAlreadyExists       = errors.New("user already exists")
+	ErrBucketAlreadyExists     = errors.New("bucket already exists")
+	ErrBucketItemAlreadyExists = errors.New("bucket item already exists")
+	ErrInvalidObjectID         = errors.New("invalid object id")
+	ErrAPIKeyNotFound          = errors.New("api key not found")
+	ErrAPIKeysNotFound         = errors.New("api keys not found")
+	ErrBucketNotFound          = errors.New("bucket not found")
+	ErrBucketsNotFound         = errors.New("buckets not found")
+	ErrBucketItemNotFound      = errors.New("bucket item not found")
+	ErrBucketItemsNotFound     = errors.New("bucket items not found")
+	ErrBucketItemExpired       = errors.New("bucket item has expired")
+	ErrBucketItemTooLarge      = errors.New("bucket item value exceeds the maximum size")
+	ErrBucketItemNotBinary     = errors.New("bucket item is not a binary value")
+	ErrSnapshotNotFound        = errors.New("snapshot not found")
+	ErrSnapshotAlreadyExists   = errors.New("snapshot already exists")
+	ErrMergeConflict           = errors.New("merge conflict")
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrValidationFailed        = errors.New("validation failed")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is required by the bucket")
+	ErrInvalidCredentials      = errors.New("invalid email or password")
+	ErrLoginLocked             = errors.New("too many failed login attempts, try again later")
+	ErrSessionRevoked          = errors.New("session has been revoked or has expired")
+	ErrRefreshTokenReused      = errors.New("refresh token has already been used")
+	ErrRateLimitExceeded       = errors.New("rate limit exceeded")
+	ErrBucketQuotaExceeded     = errors.New("bucket quota exceeded")
+	ErrItemQuotaExceeded       = errors.New("bucket item quota exceeded")
+	ErrStorageQuotaExceeded    = errors.New("storage quota exceeded")
+	ErrValueQuotaExceeded      = errors.New("bucket item value exceeds the value size quota")
+
+	// Deprecated: a failed login returns ErrInvalidCredentials, whether the email or the password is wrong
+	ErrIncorrectPassword = ErrInvalidCredentials
+
+	// matched by the status code of the response
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// codes of the API errors, see the problem details of the responses
+var apiErrors = map[error]string{
+	ErrUserNotFound:            "user_not_found",
+	ErrUsersNotFound:           "users_not_found",
+	ErrUserAlreadyExists:       "user_already_exists",
+	ErrBucketAlreadyExists:     "bucket_already_exists",
+	ErrBucketItemAlreadyExists: "bucket_item_already_exists",
+	ErrInvalidObjectID:         "invalid_object_id",
+	ErrAPIKeyNotFound:          "api_key_not_found",
+	ErrAPIKeysNotFound:         "api_keys_not_found",
+	ErrBucketNotFound:          "bucket_not_found",
+	ErrBucketsNotFound:         "buckets_not_found",
+	ErrBucketItemNotFound:      "bucket_item_not_found",
+	ErrBucketItemsNotFound:     "bucket_items_not_found",
+	ErrBucketItemExpired:       "bucket_item_expired",
+	ErrBucketItemTooLarge:      "bucket_item_too_large",
+	ErrBucketItemNotBinary:     "bucket_item_not_binary",
+	ErrSnapshotNotFound:        "snapshot_not_found",
+	ErrSnapshotAlreadyExists:   "snapshot_already_exists",
+	ErrMergeConflict:           "merge_conflict",
+	ErrWebhookNotFound:         "webhook_not_found",
+	ErrWebhookDeliveryNotFound: "webhook_delivery_not_found",
+	ErrValidationFailed:        "validation_failed",
+	ErrInvalidTwoFactorCode:    "invalid_two_factor_code",
+	ErrTwoFactorRequired:       "two_factor_required",
+	ErrInvalidCredentials:      "invalid_credentials",
+	ErrLoginLocked:             "login_locked",
+	ErrSessionRevoked:          "session_revoked",
+	ErrRefreshTokenReused:      "refresh_token_reused",
+	ErrRateLimitExceeded:       "rate_limit_exceeded",
+	ErrBucketQuotaExceeded:     "bucket_quota_exceeded",
+	ErrItemQuotaExceeded:       "item_quota_exceeded",
+	ErrStorageQuotaExceeded:    "storage_quota_exceeded",
+	ErrValueQuotaExceeded:      "value_quota_exceeded",
+}
+
+// API errors returned by earlier servers in place of the current ones, by their code and message
+var previousAPIErrors = map[error]struct {
+	code    string
+	message string
+}{
+	ErrInvalidCredentials: {code: "incorrect_password", message: "incorrect password"},
+}
+
+// Error response of the API
+type Error struct {
+	StatusCode int
+	Code       string // stable machine-readable code, e.g. bucket_not_found
+	Message    string
+	Field      string       // the unique field of a conflict
+	Errors     []FieldError // the invalid fields of a validation failure
+}
+
+// Validation failure of a single field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("kipa: %s (status %d)", e.Message, e.StatusCode)
+}
+
+// Matches the API errors from the code of the response, and the status errors from its status code
+func (e *Error) Is(target error) bool {
+	if code, ok := apiErrors[target]; ok {
+		previous, hasPrevious := previousAPIErrors[target]
+		// servers predating the error codes are matched by message
+		if e.Code == "" {
+			return e.Message == target.Error() || hasPrevious && e.Message == previous.message
+		}
+		return e.Code == code || hasPrevious && e.Code == previous.code
+	}
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
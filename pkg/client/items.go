@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func itemPath(bucketUID string, key string) string {
+	return "/item/" + url.PathEscape(bucketUID) + "/" + url.PathEscape(key)
+}
+
+func (c *Client) CreateItem(ctx context.Context, bucketUID string, input ItemInput) (*Item, error) {
+	item := &Item{}
+	query := url.Values{"full": {"true"}}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/item/" + url.PathEscape(bucketUID), query: query, body: input}, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (c *Client) GetItem(ctx context.Context, bucketUID string, key string) (*Item, error) {
+	item := &Item{}
+	query := url.Values{"full": {"true"}}
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: itemPath(bucketUID, key), query: query}, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Decodes the value of an item into out
+func (c *Client) GetItemValue(ctx context.Context, bucketUID string, key string, out interface{}) error {
+	var data json.RawMessage
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: itemPath(bucketUID, key), raw: true}, &data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Replaces the value of an item
+func (c *Client) UpdateItem(ctx context.Context, bucketUID string, key string, input ItemInput) error {
+	if input.Key == "" {
+		input.Key = key
+	}
+	_, err := c.do(ctx, request{method: http.MethodPut, path: itemPath(bucketUID, key), body: input}, nil)
+	return err
+}
+
+// Increments the integer value of an item by amount, a negative amount decrements it
+func (c *Client) IncrementItem(ctx context.Context, bucketUID string, key string, amount int64) error {
+	body := []byte(strconv.FormatInt(amount, 10))
+	_, err := c.do(ctx, request{method: http.MethodPut, path: itemPath(bucketUID, key), rawBody: body, rawBodyType: "text/plain"}, nil)
+	return err
+}
+
+// Moves an item to the trash
+func (c *Client) DeleteItem(ctx context.Context, bucketUID string, key string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: itemPath(bucketUID, key)}, nil)
+	return err
+}
+
+// Returns all the items of a bucket
+func (c *Client) ListItems(ctx context.Context, bucketUID string) ([]Item, error) {
+	items := []Item{}
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/items/" + url.PathEscape(bucketUID)}, &items)
+	if err != nil && !errors.Is(err, ErrBucketItemsNotFound) {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Returns a page of the items of a bucket, the paged listing requires the access token of a user
+func (c *Client) ListItemsPage(ctx context.Context, bucketUID string, opts *ListOptions) ([]Item, *PageInfo, error) {
+	query := opts.values()
+	query.Set("bucket_uid", bucketUID)
+	items := []Item{}
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: "/items", query: query}, &items)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, resp.PageInfo, nil
+}
+
+// Returns all the items of a bucket, fetching them page by page
+func (c *Client) ListAllItems(ctx context.Context, bucketUID string, opts *ListOptions) ([]Item, error) {
+	return listAll(ctx, opts, func(ctx context.Context, opts *ListOptions) ([]Item, *PageInfo, error) {
+		return c.ListItemsPage(ctx, bucketUID, opts)
+	})
+}
+
+// Creates an item, or replaces its value when the key already exists
+// Returns true when the item was created
+func (c *Client) SetItem(ctx context.Context, bucketUID string, input ItemInput) (bool, error) {
+	_, err := c.GetItem(ctx, bucketUID, input.Key)
+	if errors.Is(err, ErrBucketItemNotFound) {
+		_, err = c.CreateItem(ctx, bucketUID, input)
+		// the key was created concurrently, its value is replaced instead
+		if !errors.Is(err, ErrBucketItemAlreadyExists) {
+			return err == nil, err
+		}
+	} else if err != nil {
+		return false, err
+	}
+	return false, c.UpdateItem(ctx, bucketUID, input.Key, input)
+}
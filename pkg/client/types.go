@@ -0,0 +1,116 @@
+package client
+
+import "time"
+
+type User struct {
+	ID            string    `json:"id"`
+	Firstname     string    `json:"firstname"`
+	Lastname      string    `json:"lastname"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type RegisterInput struct {
+	Firstname string `json:"firstname"`
+	Lastname  string `json:"lastname"`
+	Email     string `json:"email"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password"`
+}
+
+type Tokens struct {
+	AccessToken       string `json:"access_token"`
+	RefreshToken      string `json:"refresh_token"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"` // the login is completed with LoginTwoFactor
+	TwoFactorToken    string `json:"two_factor_token,omitempty"`
+}
+
+type Session struct {
+	ID         string    `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // the session the client authenticates with
+}
+
+type APIKey struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Name        string    `json:"name"`
+	Key         string    `json:"key,omitempty"` // only returned when the key is created
+	KeyType     string    `json:"key_type"`
+	Role        string    `json:"role"`
+	Revoked     bool      `json:"revoked"`
+	Permissions []string  `json:"permissions"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type APIKeyInput struct {
+	Name        string     `json:"name"`
+	KeyType     string     `json:"key_type,omitempty"`
+	Role        string     `json:"role,omitempty"`
+	Permissions []string   `json:"permissions,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+type Bucket struct {
+	ID               string    `json:"id"`
+	UID              string    `json:"uid"`
+	UserID           string    `json:"user_id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	Permissions      []string  `json:"permissions"`
+	RequireTwoFactor bool      `json:"require_two_factor"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Items            []Item    `json:"bucket_items,omitempty"`
+}
+
+type BucketInput struct {
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+type Item struct {
+	ID          string      `json:"id"`
+	UserID      string      `json:"user_id"`
+	BucketUID   string      `json:"bucket_uid"`
+	Key         string      `json:"key"`
+	Data        interface{} `json:"data"`
+	TTL         int         `json:"ttl"`
+	Type        string      `json:"type"`
+	ContentType string      `json:"content_type,omitempty"`
+	Size        int64       `json:"size,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+type ItemInput struct {
+	Key  string      `json:"key"`
+	Data interface{} `json:"data"`
+	TTL  int         `json:"ttl,omitempty"` // in seconds, 0 never expires
+}
+
+type PageInfo struct {
+	TotalItems  int64 `json:"total_items"`
+	TotalPages  int64 `json:"total_pages"`
+	CurrentPage int64 `json:"current_page"`
+	HasNextPage bool  `json:"has_next_page"`
+}
+
+// Paging, sorting and filtering of a list
+type ListOptions struct {
+	Page    int               // starts at 1
+	PerPage int               // defaults to 20
+	SortBy  []string          // field names, prefixed with '-' for a descending order
+	Filters map[string]string // e.g. {"name[matches]": "test"}
+}
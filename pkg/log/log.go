@@ -1,11 +1,13 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func init() {
@@ -19,11 +21,56 @@ func init() {
 		},
 	}
 
-	logrus.SetFormatter(formatter)
+	logrus.SetFormatter(&redactingFormatter{next: formatter})
 	logrus.SetLevel(logrus.DebugLevel)
+	logrus.AddHook(contextHook{})
 }
 
 func formatFilePath(path string) string {
 	arr := strings.Split(path, "/")
 	return arr[len(arr)-1]
 }
+
+// Sets the level of the logs, e.g. "info" or "debug"
+func SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(lvl)
+	return nil
+}
+
+type requestIDCtxKey struct{}
+
+// Returns ctx carrying the ID of the request it serves, the logs given ctx are tagged with it
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, requestID)
+}
+
+// Returns the ID of the request served with ctx, empty when there is none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return requestID
+}
+
+// adds the request ID and the trace of the context of an entry, i.e. logrus.WithContext(ctx), to its fields
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if requestID := RequestID(entry.Context); requestID != "" {
+		entry.Data["request_id"] = requestID
+	}
+	if spanCtx := trace.SpanContextFromContext(entry.Context); spanCtx.IsValid() {
+		entry.Data["trace_id"] = spanCtx.TraceID().String()
+		entry.Data["span_id"] = spanCtx.SpanID().String()
+	}
+	return nil
+}
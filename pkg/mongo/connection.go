@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"keeper/internal/config"
+	"keeper/internal/pkg/metrics"
+	"keeper/internal/pkg/tracing"
 	"time"
 
 	_ "keeper/pkg/log"
 
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -28,8 +31,7 @@ func NewConnection(cfg *config.Config) Connection {
 	} else {
 		MONGO_CONN_URI = cfg.MongoDbProdConnUri
 	}
-	fmt.Println(MONGO_CONN_URI)
-	clientOpts := options.Client().ApplyURI(MONGO_CONN_URI)
+	clientOpts := options.Client().ApplyURI(MONGO_CONN_URI).SetMonitor(combineMonitors(metrics.MongoMonitor(), tracing.MongoMonitor()))
 	// context: to cancel the connection operation if it times out
 	ctx, cancel := context.WithTimeout(
 		context.Background(),
@@ -69,3 +71,24 @@ func (c *Connection) CleanDB(dbName string) {
 		logrus.WithError(err).Fatal("failed to clean db")
 	}
 }
+
+// Returns a command monitor notifying each of the monitors, the client only accepts one
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				m.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				m.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				m.Failed(ctx, e)
+			}
+		},
+	}
+}